@@ -0,0 +1,313 @@
+package cluster
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// Arrow IPC header types and column types of Schema.fbs, which ReadArrowColumns tells apart
+const (
+	arrowDictionaryBatch = 2
+	arrowTypeNull        = 1
+	arrowTypeFloat       = 3
+	arrowTypeBinary      = 4
+	arrowTypeUtf8        = 5
+	arrowTypeList        = 12
+	arrowTypeLargeBinary = 19
+	arrowTypeLargeUtf8   = 20
+	arrowTypeBinaryView  = 23
+)
+
+// maxArrowMetadata limits size of message metadata, so broken lengths don't allocate too much
+const maxArrowMetadata = 1 << 26
+
+// arrowMagic starts and ends Arrow IPC files, the stream follows it padded to 8 bytes
+var arrowMagic = []byte("ARROW1")
+
+// arrowField is the column of the schema, kind and width tell how values are read
+type arrowField struct {
+	name    string
+	kind    uint8 //arrowTypeInt or arrowTypeFloat for numeric columns
+	width   int   //bytes of value of numeric columns
+	signed  bool
+	buffers int //buffers of the column in record batches
+	numeric bool
+}
+
+// ReadArrowColumns reads record batches of Apache Arrow IPC stream or file, e.g. written by pyarrow or polars,
+// for ClusterColumns without GeoPoint for each row: lon and lat are names of coordinate columns and other numeric
+// columns are properties. Integer and floating point columns of any width are numeric, others are skipped.
+// Values are read in place from the body of the batch. Nested, dictionary encoded coordinate columns and compressed
+// batches are not supported. Parquet files should be converted to Arrow first, e.g. by pyarrow.parquet.read_table
+// and pyarrow.ipc.new_stream, or read to Float64Column by Arrow Parquet readers.
+func ReadArrowColumns(r io.Reader, lon, lat string) ([]ColumnBatch, error) {
+	br := bufio.NewReader(r)
+	if magic, err := br.Peek(len(arrowMagic)); err == nil && bytes.Equal(magic, arrowMagic) {
+		br.Discard(8)
+	}
+	var fields []arrowField
+	var batches []ColumnBatch
+	for {
+		headerType, header, body, err := readArrowMessage(br)
+		if err == io.EOF {
+			if fields == nil {
+				return nil, errors.New("gocluster: arrow stream has no schema")
+			}
+			return batches, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch headerType {
+		case arrowSchema:
+			if fields != nil {
+				return nil, errors.New("gocluster: arrow stream has several schemas")
+			}
+			if fields, err = arrowFields(header, lon, lat); err != nil {
+				return nil, err
+			}
+		case arrowRecordBatch:
+			if fields == nil {
+				return nil, errors.New("gocluster: arrow record batch before schema")
+			}
+			batch, err := arrowBatch(header, body, fields, lon, lat)
+			if err != nil {
+				return nil, fmt.Errorf("gocluster: arrow record batch %d: %v", len(batches), err)
+			}
+			batches = append(batches, batch)
+		case arrowDictionaryBatch:
+			//dictionaries of skipped columns
+		default:
+			return nil, fmt.Errorf("gocluster: unsupported arrow message type %d", headerType)
+		}
+	}
+}
+
+// readArrowMessage reads encapsulated message, its header table and body, io.EOF is returned at the end of stream
+func readArrowMessage(r *bufio.Reader) (headerType uint8, header fbTable, body []byte, err error) {
+	var prefix [4]byte
+	if _, err = io.ReadFull(r, prefix[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			err = errors.New("gocluster: truncated arrow message")
+		}
+		return
+	}
+	size := binary.LittleEndian.Uint32(prefix[:])
+	if size == 0xFFFFFFFF {
+		//continuation marker of format 0.15+, the size follows it
+		if _, err = io.ReadFull(r, prefix[:]); err != nil {
+			return 0, fbTable{}, nil, errors.New("gocluster: truncated arrow message")
+		}
+		size = binary.LittleEndian.Uint32(prefix[:])
+	}
+	if size == 0 {
+		return 0, fbTable{}, nil, io.EOF
+	}
+	if size > maxArrowMetadata {
+		return 0, fbTable{}, nil, fmt.Errorf("gocluster: arrow message metadata of %d bytes is too large", size)
+	}
+	meta := make([]byte, size)
+	if _, err = io.ReadFull(r, meta); err != nil {
+		return 0, fbTable{}, nil, errors.New("gocluster: truncated arrow message")
+	}
+
+	var bodyLength uint64
+	err = func() (err error) {
+		defer fbRecover(&err)
+		message := fbRoot(meta)
+		headerType = message.uint8(1, 0)
+		header, _ = message.table(2)
+		bodyLength = message.uint64(3, 0)
+		return nil
+	}()
+	if err != nil {
+		return 0, fbTable{}, nil, fmt.Errorf("gocluster: arrow message: %v", err)
+	}
+	if bodyLength > math.MaxInt32 {
+		return 0, fbTable{}, nil, fmt.Errorf("gocluster: arrow message body of %d bytes is too large", bodyLength)
+	}
+	//body is read in chunks, so broken length fails at the end of stream before it's allocated
+	var buf bytes.Buffer
+	if n, _ := io.CopyN(&buf, r, int64(bodyLength)); n != int64(bodyLength) {
+		return 0, fbTable{}, nil, errors.New("gocluster: truncated arrow message body")
+	}
+	return headerType, header, buf.Bytes(), nil
+}
+
+// arrowFields returns columns of Schema table, coordinate columns should be numeric
+func arrowFields(schema fbTable, lon, lat string) (fields []arrowField, err error) {
+	defer fbRecover(&err)
+	if schema.buf == nil {
+		return nil, errors.New("gocluster: arrow schema message has no schema")
+	}
+	if endianness := schema.uint16(0, 0); endianness != 0 {
+		return nil, errors.New("gocluster: big endian arrow stream is not supported")
+	}
+	for _, t := range schema.tables(1) {
+		f := arrowField{name: t.string(0), kind: t.uint8(2, 0)}
+		_, dictionary := t.table(4)
+		if _, n := t.vector(5, 4); n > 0 || f.kind == arrowTypeList {
+			return nil, fmt.Errorf("gocluster: nested arrow column %q is not supported", f.name)
+		}
+		switch {
+		case f.kind == arrowTypeNull:
+			f.buffers = 0
+		case f.kind == arrowTypeBinary || f.kind == arrowTypeUtf8 || f.kind == arrowTypeLargeBinary || f.kind == arrowTypeLargeUtf8:
+			f.buffers = 3
+		case f.kind >= arrowTypeBinaryView:
+			return nil, fmt.Errorf("gocluster: arrow column %q of type %d is not supported", f.name, f.kind)
+		default:
+			f.buffers = 2
+		}
+		if typ, ok := t.table(3); ok && !dictionary {
+			switch f.kind {
+			case arrowTypeInt:
+				bits := typ.uint32(0, 0)
+				f.width, f.signed = int(bits/8), typ.uint8(1, 0) != 0
+				f.numeric = bits == 8 || bits == 16 || bits == 32 || bits == 64
+			case arrowTypeFloat:
+				//precision is HALF, SINGLE or DOUBLE, half floats are skipped
+				switch typ.uint16(0, 0) {
+				case 1:
+					f.width, f.numeric = 4, true
+				case 2:
+					f.width, f.numeric = 8, true
+				}
+			}
+		}
+		if (f.name == lon || f.name == lat) && !f.numeric {
+			return nil, fmt.Errorf("gocluster: arrow coordinate column %q is not numeric", f.name)
+		}
+		fields = append(fields, f)
+	}
+	for _, name := range []string{lon, lat} {
+		found := false
+		for _, f := range fields {
+			found = found || f.name == name
+		}
+		if !found {
+			return nil, fmt.Errorf("gocluster: arrow stream has no column %q", name)
+		}
+	}
+	return fields, nil
+}
+
+// arrowBatch returns ColumnBatch of RecordBatch table, columns refer to the body
+func arrowBatch(header fbTable, body []byte, fields []arrowField, lon, lat string) (batch ColumnBatch, err error) {
+	defer fbRecover(&err)
+	if header.buf == nil {
+		return ColumnBatch{}, errors.New("no record batch")
+	}
+	if _, ok := header.table(3); ok {
+		return ColumnBatch{}, errors.New("compressed batches are not supported")
+	}
+	length := header.uint64(0, 0)
+	nodesPos, nodes := header.vector(1, 16)
+	buffersPos, buffers := header.vector(2, 16)
+	if nodes != len(fields) {
+		return ColumnBatch{}, fmt.Errorf("%d columns, schema has %d", nodes, len(fields))
+	}
+	int64At := func(pos int) uint64 { return binary.LittleEndian.Uint64(header.buf[pos:]) }
+	if length > uint64(len(body)*8) {
+		//every row takes at least a bit of each numeric column
+		return ColumnBatch{}, fmt.Errorf("%d rows in body of %d bytes", length, len(body))
+	}
+	n := int(length)
+
+	batch.Properties = map[string]Float64Column{}
+	buffer := 0
+	for k, f := range fields {
+		if buffer+f.buffers > buffers {
+			return ColumnBatch{}, fmt.Errorf("%d buffers, columns need more", buffers)
+		}
+		first := buffer
+		buffer += f.buffers
+		if !f.numeric {
+			continue
+		}
+		if rows := int64At(nodesPos + 16*k); rows != length {
+			return ColumnBatch{}, fmt.Errorf("column %q has %d rows, batch has %d", f.name, rows, length)
+		}
+		nulls := int64At(nodesPos + 16*k + 8)
+		slice := func(i int) ([]byte, error) {
+			offset, size := int64At(buffersPos+16*i), int64At(buffersPos+16*i+8)
+			if offset > uint64(len(body)) || size > uint64(len(body))-offset {
+				return nil, fmt.Errorf("buffer of column %q is out of body", f.name)
+			}
+			return body[offset : offset+size], nil
+		}
+		column := &arrowColumn{n: n, field: f}
+		if column.validity, err = slice(first); err != nil {
+			return ColumnBatch{}, err
+		}
+		if column.values, err = slice(first + 1); err != nil {
+			return ColumnBatch{}, err
+		}
+		if nulls == 0 {
+			column.validity = nil
+		} else if len(column.validity) < (n+7)/8 {
+			return ColumnBatch{}, fmt.Errorf("column %q has %d nulls and no validity bitmap", f.name, nulls)
+		}
+		if len(column.values) < n*f.width {
+			return ColumnBatch{}, fmt.Errorf("column %q has %d bytes of %d values", f.name, len(column.values), n)
+		}
+		switch f.name {
+		case lon:
+			batch.Lon = column
+		case lat:
+			batch.Lat = column
+		default:
+			batch.Properties[f.name] = column
+		}
+	}
+	return batch, nil
+}
+
+// arrowColumn is Float64Column of numeric column of Arrow record batch
+type arrowColumn struct {
+	n        int
+	field    arrowField
+	validity []byte //nil if there are no nulls
+	values   []byte
+}
+
+// Len implements Float64Column interface
+func (c *arrowColumn) Len() int { return c.n }
+
+// IsNull implements Float64Column interface
+func (c *arrowColumn) IsNull(i int) bool {
+	return c.validity != nil && c.validity[i/8]&(1<<uint(i%8)) == 0
+}
+
+// Value implements Float64Column interface
+func (c *arrowColumn) Value(i int) float64 {
+	v := c.values[i*c.field.width:]
+	if c.field.kind == arrowTypeFloat {
+		if c.field.width == 4 {
+			return float64(math.Float32frombits(binary.LittleEndian.Uint32(v)))
+		}
+		return math.Float64frombits(binary.LittleEndian.Uint64(v))
+	}
+	switch {
+	case c.field.width == 1 && c.field.signed:
+		return float64(int8(v[0]))
+	case c.field.width == 1:
+		return float64(v[0])
+	case c.field.width == 2 && c.field.signed:
+		return float64(int16(binary.LittleEndian.Uint16(v)))
+	case c.field.width == 2:
+		return float64(binary.LittleEndian.Uint16(v))
+	case c.field.width == 4 && c.field.signed:
+		return float64(int32(binary.LittleEndian.Uint32(v)))
+	case c.field.width == 4:
+		return float64(binary.LittleEndian.Uint32(v))
+	case c.field.signed:
+		return float64(int64(binary.LittleEndian.Uint64(v)))
+	}
+	return float64(binary.LittleEndian.Uint64(v))
+}
@@ -0,0 +1,258 @@
+package cluster
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+)
+
+// testArrowColumn is the column of the test stream, values are little endian of width bytes each,
+// nulls are rows without value, utf8 columns have offsets and data buffers
+type testArrowColumn struct {
+	name   string
+	kind   uint8
+	typ    []fbField
+	width  int
+	values []float64
+	nulls  map[int]bool
+	utf8   []string
+}
+
+// writeTestArrow writes Arrow IPC stream of the schema of columns and the batch for each rows range
+func writeTestArrow(t *testing.T, columns []testArrowColumn, batches [][2]int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	bw := bufio.NewWriter(&buf)
+	fields := make([][]fbField, len(columns))
+	for i, column := range columns {
+		typ := column.typ
+		fields[i] = []fbField{
+			fbString(column.name),
+			fbUint8(1),
+			fbUint8(column.kind),
+			fbRef(func(b *fbBuilder) int { return b.table(typ) }),
+			{},
+			fbRef(func(b *fbBuilder) int { return b.tablesVector(nil) }),
+		}
+	}
+	schema := func(b *fbBuilder) int {
+		return b.table([]fbField{{}, fbRef(func(b *fbBuilder) int { return b.tablesVector(fields) })})
+	}
+	if err := writeArrowMessage(bw, arrowSchema, schema, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, rows := range batches {
+		n := rows[1] - rows[0]
+		var body []byte
+		var nodes, buffers []int64
+		appendBuffer := func(data []byte) {
+			buffers = append(buffers, int64(len(body)), int64(len(data)))
+			body = append(body, data...)
+			for len(body)%8 != 0 {
+				body = append(body, 0)
+			}
+		}
+		for _, column := range columns {
+			validity := make([]byte, (n+7)/8)
+			nulls := 0
+			for i := 0; i < n; i++ {
+				if column.nulls[rows[0]+i] {
+					nulls++
+				} else {
+					validity[i/8] |= 1 << uint(i%8)
+				}
+			}
+			nodes = append(nodes, int64(n), int64(nulls))
+			if nulls == 0 {
+				validity = nil
+			}
+			appendBuffer(validity)
+			if column.kind == arrowTypeUtf8 {
+				offsets := make([]byte, 4*(n+1))
+				var data []byte
+				for i, s := range column.utf8[rows[0]:rows[1]] {
+					data = append(data, s...)
+					binary.LittleEndian.PutUint32(offsets[4*(i+1):], uint32(len(data)))
+				}
+				appendBuffer(offsets)
+				appendBuffer(data)
+				continue
+			}
+			values := make([]byte, n*column.width)
+			for i, v := range column.values[rows[0]:rows[1]] {
+				switch {
+				case column.kind == arrowTypeFloat && column.width == 4:
+					binary.LittleEndian.PutUint32(values[4*i:], math.Float32bits(float32(v)))
+				case column.kind == arrowTypeFloat:
+					binary.LittleEndian.PutUint64(values[8*i:], math.Float64bits(v))
+				case column.width == 2:
+					binary.LittleEndian.PutUint16(values[2*i:], uint16(int16(v)))
+				default:
+					binary.LittleEndian.PutUint32(values[4*i:], uint32(int32(v)))
+				}
+			}
+			appendBuffer(values)
+		}
+		batch := func(b *fbBuilder) int {
+			return b.table([]fbField{
+				fbUint64(uint64(n)),
+				fbRef(func(b *fbBuilder) int { return b.longStructsVector(nodes, 2) }),
+				fbRef(func(b *fbBuilder) int { return b.longStructsVector(buffers, 2) }),
+			})
+		}
+		if err := writeArrowMessage(bw, arrowRecordBatch, batch, body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	bw.Write([]byte{0xFF, 0xFF, 0xFF, 0xFF, 0, 0, 0, 0})
+	if err := bw.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestReadArrowColumns(t *testing.T) {
+	points := randomPoints(1000, 9, -30, -30, 30, 30)
+	lons, lats, prices, counts := make([]float64, len(points)), make([]float64, len(points)), make([]float64, len(points)), make([]float64, len(points))
+	names := make([]string, len(points))
+	nulls := map[int]bool{}
+	for i, p := range points {
+		lons[i], lats[i] = p.GetCoordinates().Lon, p.GetCoordinates().Lat
+		prices[i], counts[i], names[i] = float64(i%50)/4, float64(i%7-3), "point"
+		if i%10 == 0 {
+			nulls[i] = true
+		}
+	}
+	double := []fbField{fbUint16(2)}
+	columns := []testArrowColumn{
+		{name: "name", kind: arrowTypeUtf8, utf8: names},
+		{name: "lon", kind: arrowTypeFloat, typ: double, width: 8, values: lons},
+		{name: "lat", kind: arrowTypeFloat, typ: double, width: 8, values: lats},
+		{name: "price", kind: arrowTypeFloat, typ: []fbField{fbUint16(1)}, width: 4, values: prices, nulls: nulls},
+		{name: "count", kind: arrowTypeInt, typ: []fbField{fbInt32(16), fbUint8(1)}, width: 2, values: counts},
+	}
+	data := writeTestArrow(t, columns, [][2]int{{0, 300}, {300, 301}, {301, 1000}})
+	//Arrow files are the stream between magic and footer
+	file := append(append([]byte("ARROW1\x00\x00"), data...), "footer"...)
+
+	for name, data := range map[string][]byte{"stream": data, "file": file} {
+		batches, err := ReadArrowColumns(bytes.NewReader(data), "lon", "lat")
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if len(batches) != 3 {
+			t.Fatalf("%s: read %d batches, want 3", name, len(batches))
+		}
+		row := 0
+		for _, batch := range batches {
+			if len(batch.Properties) != 2 {
+				t.Fatalf("%s: batch has properties %v, want price and count", name, batch.Properties)
+			}
+			for i := 0; i < batch.Lon.Len(); i++ {
+				if batch.Lon.Value(i) != lons[row] || batch.Lat.Value(i) != lats[row] {
+					t.Fatalf("%s: row %d is at %v,%v, want %v,%v", name, row, batch.Lon.Value(i), batch.Lat.Value(i), lons[row], lats[row])
+				}
+				price := batch.Properties["price"]
+				if price.IsNull(i) != nulls[row] || !nulls[row] && price.Value(i) != prices[row] {
+					t.Fatalf("%s: row %d has price %v, want %v", name, row, price.Value(i), prices[row])
+				}
+				if v := batch.Properties["count"].Value(i); v != counts[row] {
+					t.Fatalf("%s: row %d has count %v, want %v", name, row, v, counts[row])
+				}
+				row++
+			}
+		}
+		if row != len(points) {
+			t.Fatalf("%s: read %d rows, want %d", name, row, len(points))
+		}
+
+		c, err := NewClusterForZoom(3, 256, 60)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := c.ClusterColumns(batches...); err != nil {
+			t.Fatal(err)
+		}
+		want, err := NewClusterForZoom(3, 256, 60)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := want.ClusterCoordinates(lons, lats); err != nil {
+			t.Fatal(err)
+		}
+		checkSameClusters(t, c.ResultPoints, want.ResultPoints)
+		if v, ok := c.ColumnValue("price", 11); !ok || v != prices[11] {
+			t.Fatalf("%s: price of point 11 is %v, want %v", name, v, prices[11])
+		}
+		if _, ok := c.ColumnValue("price", 10); ok {
+			t.Fatalf("%s: null price of point 10 has value", name)
+		}
+	}
+}
+
+func TestReadArrowAssignments(t *testing.T) {
+	c, err := NewClusterForZoom(3, 256, 60)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.ClusterPoints(loadPlaces(t)); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := c.WriteAssignmentsArrow(&buf); err != nil {
+		t.Fatal(err)
+	}
+	batches, err := ReadArrowColumns(&buf, "point", "cluster")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(batches) != 1 {
+		t.Fatalf("read %d batches, want 1", len(batches))
+	}
+	b := batches[0]
+	assignments := c.Assignments()
+	if b.Lon.Len() != len(assignments) {
+		t.Fatalf("read %d rows, want %d", b.Lon.Len(), len(assignments))
+	}
+	for id, r := range assignments {
+		if int(b.Lon.Value(id)) != id || int(b.Lat.Value(id)) != r || int(b.Properties["cluster_id"].Value(id)) != c.ResultPoints[r].Id {
+			t.Fatalf("row %d is point %v of cluster %v, want %d of %d", id, b.Lon.Value(id), b.Lat.Value(id), id, r)
+		}
+	}
+}
+
+func TestReadArrowColumnsBroken(t *testing.T) {
+	double := []fbField{fbUint16(2)}
+	columns := []testArrowColumn{
+		{name: "lon", kind: arrowTypeFloat, typ: double, width: 8, values: []float64{1, 2, 3}},
+		{name: "lat", kind: arrowTypeFloat, typ: double, width: 8, values: []float64{4, 5, 6}},
+	}
+	data := writeTestArrow(t, columns, [][2]int{{0, 3}})
+	if _, err := ReadArrowColumns(bytes.NewReader(data), "lon", "y"); err == nil {
+		t.Error("expected error of missing column")
+	}
+	//the stream could end after any message without the end marker, the schema is the first message
+	schemaEnd := 8 + int(binary.LittleEndian.Uint32(data[4:]))
+	for end := 0; end < len(data)-8; end += 8 {
+		if end == schemaEnd {
+			continue
+		}
+		if _, err := ReadArrowColumns(bytes.NewReader(data[:end]), "lon", "lat"); err == nil {
+			t.Errorf("expected error of stream truncated at %d bytes", end)
+		}
+	}
+	huge := append([]byte(nil), data...)
+	binary.LittleEndian.PutUint32(huge[4:], 1<<30)
+	if _, err := ReadArrowColumns(bytes.NewReader(huge), "lon", "lat"); err == nil {
+		t.Error("expected error of too large metadata")
+	}
+	//every byte of the stream broken by turn is an error or a result, never a panic
+	for i := range data {
+		broken := append([]byte(nil), data...)
+		broken[i] ^= 0xA5
+		ReadArrowColumns(bytes.NewReader(broken), "lon", "lat")
+	}
+}
@@ -0,0 +1,145 @@
+package cluster
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/csv"
+	"io"
+	"strconv"
+)
+
+// assignmentColumns are columns of labeled assignments: input point index, index in ResultPoints, id and size
+// of the cluster or single point containing the point
+var assignmentColumns = []string{"point", "cluster", "cluster_id", "num_points"}
+
+// Assignments returns index in ResultPoints of the cluster or single point containing each input point,
+// parallel to the slice passed to ClusterPoints or rows of ClusterColumns, e.g. cluster labels for ML pipelines.
+// Skipped points, see Report, are in no result point, their index is -1.
+// The slice is a copy, it's not changed by UpdatePoint and RebuildDirty.
+func (c *Cluster) Assignments() []int {
+	return append([]int(nil), c.assignment...)
+}
+
+// assignmentRows returns columns of labeled assignments, see WriteAssignmentsCSV
+func (c *Cluster) assignmentRows() [4][]int64 {
+	var columns [4][]int64
+	for i := range columns {
+		columns[i] = make([]int64, len(c.assignment))
+	}
+	for id, r := range c.assignment {
+		if r < 0 {
+			columns[0][id], columns[1][id], columns[2][id], columns[3][id] = int64(id), -1, -1, 0
+			continue
+		}
+		cp := &c.ResultPoints[r]
+		columns[0][id], columns[1][id], columns[2][id], columns[3][id] = int64(id), int64(r), int64(cp.Id), int64(cp.NumPoints)
+	}
+	return columns
+}
+
+// WriteAssignmentsCSV writes CSV with header and one row for each input point in input order:
+// point index, cluster index in ResultPoints, cluster id and number of points in the cluster, 1 for single points.
+// Rows of skipped points have cluster index and id -1 and no points.
+func (c *Cluster) WriteAssignmentsCSV(w io.Writer) error {
+	if !c.Built() {
+		return notBuilt("WriteAssignmentsCSV")
+	}
+	cw := csv.NewWriter(w)
+	if err := cw.Write(assignmentColumns); err != nil {
+		return err
+	}
+	columns := c.assignmentRows()
+	row := make([]string, len(columns))
+	for id := range c.assignment {
+		for i := range columns {
+			row[i] = strconv.FormatInt(columns[i][id], 10)
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// Arrow IPC message header types and metadata version
+const (
+	arrowSchema      = 1
+	arrowRecordBatch = 3
+	arrowV5          = 4
+	arrowTypeInt     = 2
+)
+
+// WriteAssignmentsArrow writes the same columns as WriteAssignmentsCSV as Apache Arrow IPC stream of one record batch,
+// columns are non-nullable int64. It's read by pyarrow.ipc.open_stream, polars.read_ipc_stream and Arrow readers.
+func (c *Cluster) WriteAssignmentsArrow(w io.Writer) error {
+	if !c.Built() {
+		return notBuilt("WriteAssignmentsArrow")
+	}
+	bw := bufio.NewWriter(w)
+	fields := make([][]fbField, len(assignmentColumns))
+	for i, name := range assignmentColumns {
+		fields[i] = []fbField{
+			fbString(name),
+			fbUint8(0),
+			fbUint8(arrowTypeInt),
+			fbRef(func(b *fbBuilder) int { return b.table([]fbField{fbInt32(64), fbUint8(1)}) }),
+			{},
+			fbRef(func(b *fbBuilder) int { return b.tablesVector(nil) }),
+		}
+	}
+	schema := func(b *fbBuilder) int {
+		return b.table([]fbField{{}, fbRef(func(b *fbBuilder) int { return b.tablesVector(fields) })})
+	}
+	if err := writeArrowMessage(bw, arrowSchema, schema, nil); err != nil {
+		return err
+	}
+
+	n := int64(len(c.assignment))
+	var body []byte
+	var nodes, buffers []int64
+	for _, column := range c.assignmentRows() {
+		//FieldNode of length and null count, Buffers of empty validity bitmap and values
+		nodes = append(nodes, n, 0)
+		buffers = append(buffers, int64(len(body)), 0, int64(len(body)), 8*n)
+		for _, v := range column {
+			body = appendUint64(body, uint64(v))
+		}
+	}
+	batch := func(b *fbBuilder) int {
+		return b.table([]fbField{
+			fbUint64(uint64(n)),
+			fbRef(func(b *fbBuilder) int { return b.longStructsVector(nodes, 2) }),
+			fbRef(func(b *fbBuilder) int { return b.longStructsVector(buffers, 2) }),
+		})
+	}
+	if err := writeArrowMessage(bw, arrowRecordBatch, batch, body); err != nil {
+		return err
+	}
+	//end of stream
+	bw.Write([]byte{0xFF, 0xFF, 0xFF, 0xFF, 0, 0, 0, 0})
+	return bw.Flush()
+}
+
+// writeArrowMessage writes encapsulated message: continuation marker, metadata size, Message flatbuffer padded
+// to 8 bytes and the body, which is already padded
+func writeArrowMessage(w *bufio.Writer, headerType uint8, header func(b *fbBuilder) int, body []byte) error {
+	b := &fbBuilder{}
+	meta := b.finish([]fbField{fbUint16(arrowV5), fbUint8(headerType), fbRef(header), fbUint64(uint64(len(body)))})
+	for (8+len(meta))%8 != 0 {
+		meta = append(meta, 0)
+	}
+	var prefix [8]byte
+	binary.LittleEndian.PutUint32(prefix[:], 0xFFFFFFFF)
+	binary.LittleEndian.PutUint32(prefix[4:], uint32(len(meta)))
+	w.Write(prefix[:])
+	w.Write(meta)
+	_, err := w.Write(body)
+	return err
+}
+
+func appendUint64(buf []byte, v uint64) []byte {
+	var data [8]byte
+	binary.LittleEndian.PutUint64(data[:], v)
+	return append(buf, data[:]...)
+}
@@ -0,0 +1,93 @@
+package cluster
+
+import (
+	"bytes"
+	"encoding/csv"
+	"strconv"
+	"testing"
+)
+
+func TestAssignments(t *testing.T) {
+	points := randomPoints(1000, 51, -30, -30, 30, 30)
+	c, err := NewClusterForZoom(3, 256, 60)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.WriteAssignmentsCSV(&bytes.Buffer{}); err == nil {
+		t.Fatal("expected error before points are clustered")
+	}
+	if err := c.ClusterPoints(points); err != nil {
+		t.Fatal(err)
+	}
+	assignments := c.Assignments()
+	if len(assignments) != len(points) {
+		t.Fatalf("%d assignments of %d points", len(assignments), len(points))
+	}
+	for i, r := range assignments {
+		if index, ok := c.ResultIndex(i); !ok || index != r {
+			t.Fatalf("point %d is assigned to %d, ResultIndex is %d", i, r, index)
+		}
+	}
+	assignments[0] = -1
+	if c.Assignments()[0] == -1 {
+		t.Fatal("assignments are shared with the Cluster")
+	}
+
+	var buf bytes.Buffer
+	if err := c.WriteAssignmentsCSV(&buf); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != len(points)+1 || rows[0][0] != "point" || rows[0][3] != "num_points" {
+		t.Fatalf("%d rows with header %v", len(rows), rows[0])
+	}
+	for id, row := range rows[1:] {
+		r := c.assignment[id]
+		cp := &c.ResultPoints[r]
+		want := []int{id, r, cp.Id, cp.NumPoints}
+		for i, v := range row {
+			if n, err := strconv.Atoi(v); err != nil || n != want[i] {
+				t.Fatalf("row %d is %v, want %v", id, row, want)
+			}
+		}
+	}
+}
+
+func TestAssignmentsArrow(t *testing.T) {
+	points := randomPoints(300, 52, -30, -30, 30, 30)
+	c, err := NewClusterForZoom(3, 256, 60)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.ClusterPoints(points); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := c.WriteAssignmentsArrow(&buf); err != nil {
+		t.Fatal(err)
+	}
+	//the stream is read back by the Arrow reader of the package, point and cluster columns in place of coordinates
+	batches, err := ReadArrowColumns(&buf, "point", "cluster")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(batches) != 1 || batches[0].Lon.Len() != len(points) {
+		t.Fatalf("%d batches of assignments", len(batches))
+	}
+	batch := batches[0]
+	columns := []Float64Column{batch.Lon, batch.Lat, batch.Properties["cluster_id"], batch.Properties["num_points"]}
+	rows := c.assignmentRows()
+	for i, column := range columns {
+		if column == nil {
+			t.Fatalf("no column %s", assignmentColumns[i])
+		}
+		for id := range points {
+			if column.IsNull(id) || column.Value(id) != float64(rows[i][id]) {
+				t.Fatalf("column %s of row %d is %v, want %d", assignmentColumns[i], id, column.Value(id), rows[i][id])
+			}
+		}
+	}
+}
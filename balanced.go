@@ -0,0 +1,67 @@
+package cluster
+
+import (
+	"math"
+	"sort"
+)
+
+// balanced splits base points into clusters of about Capacity points each
+// Points are bisected recursively across the longer side of their bounding box, each part gets the number
+// of clusters proportional to its weight, so clusters are compact and differ from Capacity by weight of one point.
+// Epsilon and MinPoints are not used, clusters of one point are returned as is.
+func (c *Cluster) balanced() []*ClusterPoint {
+	points := make([]*ClusterPoint, len(c.basePoints))
+	copy(points, c.basePoints)
+	total := 0
+	for _, p := range points {
+		p.visited = true
+		total += p.NumPoints
+	}
+	capacity := c.Capacity
+	if capacity <= 0 {
+		capacity = total
+	}
+	var result []*ClusterPoint
+	c.bisect(points, total, (total+capacity-1)/capacity, &result)
+	return result
+}
+
+// bisect splits points of total weight into k clusters
+func (c *Cluster) bisect(points []*ClusterPoint, total, k int, result *[]*ClusterPoint) {
+	if len(points) == 0 {
+		return
+	}
+	if k <= 1 || len(points) == 1 {
+		if len(points) == 1 {
+			*result = append(*result, points[0])
+			return
+		}
+		*result = append(*result, c.newCluster(points[0], points[1:]))
+		return
+	}
+
+	minX, minY, maxX, maxY := points[0].X, points[0].Y, points[0].X, points[0].Y
+	for _, p := range points[1:] {
+		minX, maxX = math.Min(minX, p.X), math.Max(maxX, p.X)
+		minY, maxY = math.Min(minY, p.Y), math.Max(maxY, p.Y)
+	}
+	if maxX-minX >= maxY-minY {
+		sort.SliceStable(points, func(i, j int) bool { return points[i].X < points[j].X })
+	} else {
+		sort.SliceStable(points, func(i, j int) bool { return points[i].Y < points[j].Y })
+	}
+
+	//the first part takes k/2 clusters and the same share of the weight
+	left := k / 2
+	target := total * left / k
+	weight, split := 0, 0
+	for split < len(points)-1 && weight+points[split].NumPoints/2 < target {
+		weight += points[split].NumPoints
+		split++
+	}
+	if split == 0 {
+		weight, split = points[0].NumPoints, 1
+	}
+	c.bisect(points[:split], weight, left, result)
+	c.bisect(points[split:], total-weight, k-left, result)
+}
@@ -0,0 +1,29 @@
+package cluster
+
+import "testing"
+
+func TestBalanced(t *testing.T) {
+	const n = 1000
+	for _, capacity := range []int{1, 7, 50, 0} {
+		c := NewCluster(0.001)
+		c.Strategy = StrategyBalanced
+		c.Capacity = capacity
+		if err := c.ClusterPoints(randomPoints(n, 32, -60, -60, 60, 60)); err != nil {
+			t.Fatal(err)
+		}
+		checkAssignments(t, c, n)
+		//zero Capacity means one cluster of all points
+		k := n
+		if capacity > 0 {
+			k = capacity
+		}
+		if want := (n + k - 1) / k; len(c.ResultPoints) != want {
+			t.Fatalf("capacity %d: %d clusters, want %d", capacity, len(c.ResultPoints), want)
+		}
+		for _, cp := range c.ResultPoints {
+			if cp.NumPoints < k-1 || cp.NumPoints > k+1 {
+				t.Fatalf("capacity %d: cluster %d has %d points", capacity, cp.Id, cp.NumPoints)
+			}
+		}
+	}
+}
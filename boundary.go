@@ -0,0 +1,100 @@
+package cluster
+
+import (
+	"errors"
+	"math"
+)
+
+// projectedRing is the ring of Boundaries polygon in projected coordinates with its bounding box
+type projectedRing struct {
+	xs, ys                 []float64
+	minX, minY, maxX, maxY float64
+}
+
+func (r *projectedRing) contains(x, y float64) bool {
+	if x < r.minX || x > r.maxX || y < r.minY || y > r.maxY {
+		return false
+	}
+	inside := false
+	for i, j := 0, len(r.xs)-1; i < len(r.xs); j, i = i, i+1 {
+		if (r.ys[i] > y) != (r.ys[j] > y) && x < (r.xs[j]-r.xs[i])*(y-r.ys[i])/(r.ys[j]-r.ys[i])+r.xs[i] {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// checkBoundaries returns error if Boundaries are set for strategy which doesn't search neighbours by Epsilon
+func (c *Cluster) checkBoundaries() error {
+	if len(c.Boundaries) > 0 && c.Strategy != StrategyGreedy && c.Strategy != StrategyOPTICS {
+		return errors.New("gocluster: Boundaries are supported by StrategyGreedy and StrategyOPTICS only")
+	}
+	return nil
+}
+
+// projectBoundaries projects rings of Boundaries, they are projected again each time points are
+func (c *Cluster) projectBoundaries() {
+	c.boundaries = nil
+	for _, polygon := range c.Boundaries {
+		r := projectedRing{minX: math.Inf(1), minY: math.Inf(1), maxX: math.Inf(-1), maxY: math.Inf(-1)}
+		for _, v := range polygon.Ring {
+			x, y := c.projection().Project(v)
+			r.xs, r.ys = append(r.xs, x), append(r.ys, y)
+			r.minX, r.minY = math.Min(r.minX, x), math.Min(r.minY, y)
+			r.maxX, r.maxY = math.Max(r.maxX, x), math.Max(r.maxY, y)
+		}
+		c.boundaries = append(c.boundaries, r)
+	}
+}
+
+// regionOf returns 1-based index in Boundaries of the first polygon containing projected point, 0 if there is none
+func (c *Cluster) regionOf(x, y float64) int {
+	for i := range c.boundaries {
+		if c.boundaries[i].contains(x, y) {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// assignRegions sets region of base points
+func (c *Cluster) assignRegions() {
+	c.projectBoundaries()
+	for _, p := range c.basePoints {
+		p.region = c.regionOf(p.X, p.Y)
+	}
+}
+
+// appendNeighboursOf appends neighbours of the point like appendNeighbours, only ones of its region
+func (c *Cluster) appendNeighboursOf(dst []int, index spatialIndex, points []*ClusterPoint, p *ClusterPoint) []int {
+	start := len(dst)
+	dst = c.appendNeighbours(dst, index, points, p.X, p.Y)
+	if len(c.boundaries) == 0 {
+		return dst
+	}
+	n := start
+	for _, id := range dst[start:] {
+		if points[id].region == p.region {
+			dst[n] = id
+			n++
+		}
+	}
+	return dst[:n]
+}
+
+// constrainCenter moves projected center of the cluster outside of its region to the closest member
+// Members are in the region, so the center is too.
+func (c *Cluster) constrainCenter(cp *ClusterPoint) {
+	if cp.region == 0 || cp.NumPoints < 2 || c.boundaries[cp.region-1].contains(cp.X, cp.Y) {
+		return
+	}
+	best := math.Inf(1)
+	x, y := cp.X, cp.Y
+	for _, id := range cp.memberIDs {
+		p := c.basePoints[c.basePointOf(id)]
+		if d := sqDist(p.X, p.Y, cp.X, cp.Y); d < best {
+			best, x, y = d, p.X, p.Y
+		}
+	}
+	cp.X, cp.Y = x, y
+}
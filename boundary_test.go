@@ -0,0 +1,101 @@
+package cluster
+
+import (
+	"math"
+	"testing"
+)
+
+func TestBoundaries(t *testing.T) {
+	//west and east squares meet at the meridian, points outside of both are north of them
+	west := Polygon{ID: "west", Ring: []GeoCoordinates{{Lon: -10, Lat: 0}, {Lon: 0, Lat: 0}, {Lon: 0, Lat: 10}, {Lon: -10, Lat: 10}}}
+	east := Polygon{ID: "east", Ring: []GeoCoordinates{{Lon: 0, Lat: 0}, {Lon: 10, Lat: 0}, {Lon: 10, Lat: 10}, {Lon: 0, Lat: 10}}}
+	points := randomPoints(2000, 53, -10, 0, 10, 20)
+	c, err := NewClusterForZoom(6, 256, 60)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Boundaries = []Polygon{west, east}
+	if err := c.ClusterPoints(points); err != nil {
+		t.Fatal(err)
+	}
+	checkAssignments(t, c, len(points))
+	regionOf := func(coordinates GeoCoordinates) int {
+		switch {
+		case coordinates.Lat > 10:
+			return 0
+		case coordinates.Lon < 0:
+			return 1
+		}
+		return 2
+	}
+	borderClusters := 0
+	for _, cp := range c.ResultPoints {
+		region := regionOf(cp.IncludedPoints[0].GetCoordinates())
+		for _, p := range cp.IncludedPoints {
+			if regionOf(p.GetCoordinates()) != region {
+				t.Fatalf("cluster %d has members of regions %d and %d", cp.Id, region, regionOf(p.GetCoordinates()))
+			}
+		}
+		if cp.NumPoints > 1 && region > 0 && regionOf(GeoCoordinates{Lon: cp.X, Lat: cp.Y}) != region {
+			t.Fatalf("cluster %d of region %d is centered at %v,%v", cp.Id, region, cp.X, cp.Y)
+		}
+		if cp.NumPoints > 1 && region > 0 && (cp.X > -2 && cp.X < 2) {
+			borderClusters++
+		}
+	}
+	//without regions clusters cross the border
+	plain, err := NewClusterForZoom(6, 256, 60)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := plain.ClusterPoints(points); err != nil {
+		t.Fatal(err)
+	}
+	crossing := 0
+	for _, cp := range plain.ResultPoints {
+		regions := map[int]bool{}
+		for _, p := range cp.IncludedPoints {
+			regions[regionOf(p.GetCoordinates())] = true
+		}
+		if regions[1] && regions[2] {
+			crossing++
+		}
+	}
+	if crossing == 0 || borderClusters == 0 {
+		t.Fatalf("%d clusters cross the border without regions, %d clusters are near it", crossing, borderClusters)
+	}
+
+	c.Strategy = StrategyMeanShift
+	if err := c.ClusterPoints(points); err == nil {
+		t.Fatal("expected error of Boundaries with StrategyMeanShift")
+	}
+}
+
+func TestBoundariesCenter(t *testing.T) {
+	//members in both arms of U region, their center is in the notch between them
+	var ring []GeoCoordinates
+	for _, v := range [][2]float64{{0, 0}, {3, 0}, {3, 3}, {2, 3}, {2, 1}, {1, 1}, {1, 3}, {0, 3}} {
+		ring = append(ring, GeoCoordinates{Lon: v[0], Lat: v[1]})
+	}
+	points := []GeoPoint{
+		&Feature{Coordinates: GeoCoordinates{Lon: 0.6, Lat: 2.5}},
+		&Feature{Coordinates: GeoCoordinates{Lon: 2.5, Lat: 2.5}},
+		&Feature{Coordinates: GeoCoordinates{Lon: 2.4, Lat: 2.6}},
+	}
+	c := NewCluster(0.01)
+	c.Boundaries = []Polygon{{Ring: ring}}
+	if err := c.ClusterPoints(points); err != nil {
+		t.Fatal(err)
+	}
+	if len(c.ResultPoints) != 1 {
+		t.Fatalf("%d result points, want 1", len(c.ResultPoints))
+	}
+	//the center is moved to the closest member
+	cp := c.ResultPoints[0]
+	if x, y := MercatorProjection(GeoCoordinates{Lon: cp.X, Lat: cp.Y}); c.regionOf(x, y) != 1 {
+		t.Fatalf("cluster is centered at %v,%v outside of its region", cp.X, cp.Y)
+	}
+	if math.Abs(cp.X-2.4) > 1e-9 || math.Abs(cp.Y-2.6) > 1e-9 {
+		t.Fatalf("cluster is centered at %v,%v, not at the closest member", cp.X, cp.Y)
+	}
+}
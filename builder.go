@@ -0,0 +1,139 @@
+package cluster
+
+import (
+	"errors"
+	"io"
+	"time"
+)
+
+// Builder collects points for Index, options are taken from the template Cluster
+// Builder is not safe for concurrent use, but Indexes it builds are independent of it and of each other,
+// so the server could build new Index in background and swap it in while old queries finish on the previous one.
+type Builder struct {
+	template *Cluster
+	points   []GeoPoint
+}
+
+// NewBuilder creates Builder with options of the template, e.g. created by NewCluster or NewClusterForZoom,
+// stats registered with AddNumericStat are used too. The template should not be clustered itself.
+func NewBuilder(template *Cluster) *Builder {
+	return &Builder{template: template}
+}
+
+// AddPoints adds points to the next Index, points are not copied
+func (b *Builder) AddPoints(points ...GeoPoint) {
+	b.points = append(b.points, points...)
+}
+
+// Len returns number of added points
+func (b *Builder) Len() int {
+	return len(b.points)
+}
+
+// Build clusters all added points into new Index, the Builder could be used to add more points after it
+func (b *Builder) Build() (*Index, error) {
+	if b.template == nil {
+		return nil, errors.New("gocluster: builder has no template Cluster")
+	}
+	c := &Cluster{}
+	*c = *b.template
+	c.mintedIDs = nil
+	points := b.points[:len(b.points):len(b.points)]
+	if err := c.ClusterPoints(points); err != nil {
+		return nil, err
+	}
+	return &Index{c: c}, nil
+}
+
+// Index is clustered points, which are never changed, so it's safe to query concurrently
+// Slices returned by Index methods are shared and should not be modified.
+type Index struct {
+	c *Cluster
+}
+
+// ReadIndex reads Index from snapshot written by WriteSnapshot of Cluster or Index, it has the Version of written one
+func ReadIndex(r io.Reader) (*Index, error) {
+	c, err := ReadSnapshot(r)
+	if err != nil {
+		return nil, err
+	}
+	return &Index{c: c}, nil
+}
+
+// Version returns version of the Index, see Cluster.Version
+func (i *Index) Version() uint64 { return i.c.Version() }
+
+// Zoom returns zoom level of the Index
+func (i *Index) Zoom() int { return i.c.Zoom }
+
+// AllClusters returns all cluster points, see Cluster.AllClusters
+func (i *Index) AllClusters() []ClusterPoint { return i.c.AllClusters() }
+
+// Clusters returns only clusters of several points, see Cluster.Clusters
+func (i *Index) Clusters() []ClusterPoint { return i.c.Clusters() }
+
+// Singles returns points that are not merged with any other point, see Cluster.Singles
+func (i *Index) Singles() []ClusterPoint { return i.c.Singles() }
+
+// GetClusters returns clusters inside the box, see Cluster.GetClusters
+func (i *Index) GetClusters(northWest, southEast GeoCoordinates) []ClusterPoint {
+	return i.c.GetClusters(northWest, southEast)
+}
+
+// GetClustersBetween returns clusters of points with timestamps between from and to, see Cluster.GetClustersBetween
+func (i *Index) GetClustersBetween(northWest, southEast GeoCoordinates, from, to time.Time) []ClusterPoint {
+	return i.c.GetClustersBetween(northWest, southEast, from, to)
+}
+
+// GetClustersWhere returns clusters inside the box made of members matching the filter, see Cluster.GetClustersWhere
+func (i *Index) GetClustersWhere(northWest, southEast GeoCoordinates, filter PointFilter) []ClusterPoint {
+	return i.c.GetClustersWhere(northWest, southEast, filter)
+}
+
+// Grid aggregates points of the box into grid cells, see Cluster.Grid
+func (i *Index) Grid(northWest, southEast GeoCoordinates, zoom, tileSize int, opts GridOptions) ([]Polygon, error) {
+	return i.c.Grid(northWest, southEast, zoom, tileSize, opts)
+}
+
+// GridTile aggregates points of the tile into grid cells, see Cluster.GridTile
+func (i *Index) GridTile(t Tile, tileSize int, opts GridOptions) ([]Polygon, error) {
+	return i.c.GridTile(t, tileSize, opts)
+}
+
+// Density rasterizes points density of the box, see Cluster.Density
+func (i *Index) Density(northWest, southEast GeoCoordinates, zoom, tileSize int, opts HeatmapOptions) (*DensityGrid, error) {
+	return i.c.Density(northWest, southEast, zoom, tileSize, opts)
+}
+
+// DensityTile rasterizes points density of the tile, see Cluster.DensityTile
+func (i *Index) DensityTile(t Tile, tileSize int, opts HeatmapOptions) (*DensityGrid, error) {
+	return i.c.DensityTile(t, tileSize, opts)
+}
+
+// ExtractOPTICS returns clusters for smaller eps, see Cluster.ExtractOPTICS
+func (i *Index) ExtractOPTICS(eps float64) ([]ClusterPoint, error) { return i.c.ExtractOPTICS(eps) }
+
+// ExpansionBounds returns the box framing members of the cluster, see Cluster.ExpansionBounds
+func (i *Index) ExpansionBounds(id int, padding float64) (northWest, southEast GeoCoordinates, ok bool) {
+	return i.c.ExpansionBounds(id, padding)
+}
+
+// Leaves returns members of the cluster or single point with id, see Cluster.Leaves
+func (i *Index) Leaves(id, limit, offset int) ([]GeoPoint, error) {
+	return i.c.Leaves(id, limit, offset)
+}
+
+// IsCluster checks if id is the id of cluster
+func (i *Index) IsCluster(id int) bool { return i.c.IsCluster(id) }
+
+// Report returns BuildReport of points of the Index, see Cluster.Report
+func (i *Index) Report() BuildReport { return i.c.Report() }
+
+// Bytes returns approximate memory taken by the Index, input points are not counted
+func (i *Index) Bytes() int { return i.c.memoryBytes() }
+
+// WriteSnapshot writes the Index, see Cluster.WriteSnapshot
+func (i *Index) WriteSnapshot(w io.Writer) error { return i.c.WriteSnapshot(w) }
+
+// Close removes the spill file of the Index, see Cluster.Close, queries should be done, see ServingIndex.Swap
+func (i *Index) Close() error { return i.c.Close() }
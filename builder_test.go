@@ -0,0 +1,66 @@
+package cluster
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestBuilder(t *testing.T) {
+	template, err := NewClusterForZoom(4, 256, 40)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := NewBuilder(template)
+	points := randomPoints(1000, 24, -60, -60, 60, 60)
+	b.AddPoints(points[:600]...)
+	first, err := b.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.AddPoints(points[600:]...)
+	second, err := b.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Len() != len(points) {
+		t.Fatalf("builder has %d points", b.Len())
+	}
+	//indexes are independent of each other and of the template
+	if totalPoints(first.AllClusters()) != 600 || totalPoints(second.AllClusters()) != len(points) {
+		t.Fatalf("indexes have %d and %d points", totalPoints(first.AllClusters()), totalPoints(second.AllClusters()))
+	}
+	if template.ResultPoints != nil || first.Version() == second.Version() || second.Zoom() != 4 {
+		t.Fatal("indexes share the state")
+	}
+
+	c, err := NewClusterForZoom(4, 256, 40)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.ClusterPoints(points); err != nil {
+		t.Fatal(err)
+	}
+	northWest, southEast := GeoCoordinates{Lon: -20, Lat: 30}, GeoCoordinates{Lon: 40, Lat: -10}
+	if got, want := len(second.GetClusters(northWest, southEast)), len(c.GetClusters(northWest, southEast)); got != want {
+		t.Fatalf("index has %d clusters in the box, Cluster has %d", got, want)
+	}
+	if len(second.Clusters())+len(second.Singles()) != len(second.AllClusters()) {
+		t.Fatal("clusters and singles are not all clusters of the index")
+	}
+
+	var buf bytes.Buffer
+	if err := second.WriteSnapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+	restored, err := ReadIndex(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored.Version() != second.Version() || len(restored.AllClusters()) != len(second.AllClusters()) {
+		t.Fatalf("restored index has version %d and %d clusters", restored.Version(), len(restored.AllClusters()))
+	}
+
+	if _, err := NewBuilder(nil).Build(); err == nil {
+		t.Fatal("expected error of builder without template")
+	}
+}
@@ -0,0 +1,58 @@
+package cluster
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestNotBuilt(t *testing.T) {
+	c, err := NewClusterForZoom(3, 256, 40)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	northWest, southEast := GeoCoordinates{Lon: -180, Lat: 85}, GeoCoordinates{Lon: 180, Lat: -85}
+	errs := map[string]error{
+		"WriteSnapshot":        c.WriteSnapshot(&buf),
+		"WriteAssignmentsCSV":  c.WriteAssignmentsCSV(&buf),
+		"MovePoint":            c.MovePoint(0, northWest),
+		"ReclusterWithEpsilon": c.ReclusterWithEpsilon(c.Epsilon),
+	}
+	_, errs["Leaves"] = c.Leaves(0, 0, 0)
+	_, errs["Grid"] = c.Grid(northWest, southEast, 3, 256, GridOptions{})
+	_, errs["Density"] = c.Density(northWest, southEast, 3, 256, HeatmapOptions{})
+	for method, err := range errs {
+		if !errors.Is(err, ErrNotBuilt) {
+			t.Errorf("%s returned %v, want ErrNotBuilt", method, err)
+		}
+	}
+	if _, _, ok := c.ExpansionBounds(0, 0); ok {
+		t.Error("ExpansionBounds found cluster before points are clustered")
+	}
+	if c.GetClusters(northWest, southEast) != nil || c.AllClusters() != nil {
+		t.Error("queries returned points before points are clustered")
+	}
+}
+
+func TestWriteSnapshotDirty(t *testing.T) {
+	c, err := NewClusterForZoom(3, 256, 40)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.ClusterPoints(loadPlaces(t)); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.MovePoint(0, GeoCoordinates{Lon: 10, Lat: 10}); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	err = c.WriteSnapshot(&buf)
+	if !errors.Is(err, ErrDirty) || errors.Is(err, ErrNotBuilt) {
+		t.Fatalf("WriteSnapshot of dirty cluster returned %v, want ErrDirty", err)
+	}
+	c.RebuildDirty()
+	if err := c.WriteSnapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,143 @@
+package cluster
+
+import (
+	"container/list"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Cache is LRU cache of query results of hot tiles and viewports
+// Results are keyed by Version of the Cluster, so results of the changed Cluster are never returned,
+// they are evicted as least recently used ones. It's safe for concurrent use.
+type Cache struct {
+	mu    sync.Mutex
+	size  int
+	ttl   time.Duration
+	now   func() time.Time
+	lru   *list.List
+	items map[cacheKey]*list.Element
+}
+
+type cacheKey struct {
+	version uint64
+	query   string
+}
+
+type cacheEntry struct {
+	key     cacheKey
+	value   interface{}
+	expires time.Time
+}
+
+// NewCache creates Cache keeping up to size results, for ttl if it's positive
+func NewCache(size int, ttl time.Duration) *Cache {
+	return &Cache{
+		size:  size,
+		ttl:   ttl,
+		now:   time.Now,
+		lru:   list.New(),
+		items: map[cacheKey]*list.Element{},
+	}
+}
+
+// Get returns cached result of the query of the current Version of c, or the result of compute, which is cached
+// Query is any string telling apart results of the same Cluster, e.g. "mvt/4/8/5". Errors are not cached.
+// Concurrent calls for the same missing query could compute it several times.
+func (cc *Cache) Get(c *Cluster, query string, compute func() (interface{}, error)) (interface{}, error) {
+	key := cacheKey{version: c.Version(), query: query}
+	cc.mu.Lock()
+	if e, ok := cc.items[key]; ok {
+		entry := e.Value.(*cacheEntry)
+		if cc.ttl <= 0 || cc.now().Before(entry.expires) {
+			cc.lru.MoveToFront(e)
+			cc.mu.Unlock()
+			return entry.value, nil
+		}
+		cc.remove(e)
+	}
+	cc.mu.Unlock()
+
+	value, err := compute()
+	if err != nil {
+		return nil, err
+	}
+	cc.add(key, value)
+	return value, nil
+}
+
+func (cc *Cache) add(key cacheKey, value interface{}) {
+	if cc.size <= 0 {
+		return
+	}
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	if e, ok := cc.items[key]; ok {
+		cc.remove(e)
+	}
+	entry := &cacheEntry{key: key, value: value}
+	if cc.ttl > 0 {
+		entry.expires = cc.now().Add(cc.ttl)
+	}
+	cc.items[key] = cc.lru.PushFront(entry)
+	for cc.lru.Len() > cc.size {
+		cc.remove(cc.lru.Back())
+	}
+}
+
+func (cc *Cache) remove(e *list.Element) {
+	cc.lru.Remove(e)
+	delete(cc.items, e.Value.(*cacheEntry).key)
+}
+
+// Len returns number of cached results, including expired and stale ones not evicted yet
+func (cc *Cache) Len() int {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	return cc.lru.Len()
+}
+
+// Purge removes all cached results
+func (cc *Cache) Purge() {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	cc.lru.Init()
+	cc.items = map[cacheKey]*list.Element{}
+}
+
+// GetClusters returns cached result of GetClusters of c for the box
+// The slice is shared and should not be modified.
+func (cc *Cache) GetClusters(c *Cluster, northWest, southEast GeoCoordinates) []ClusterPoint {
+	query := fmt.Sprintf("bbox/%v,%v,%v,%v", northWest.Lon, southEast.Lat, southEast.Lon, northWest.Lat)
+	v, _ := cc.Get(c, query, func() (interface{}, error) {
+		return c.GetClusters(northWest, southEast), nil
+	})
+	return v.([]ClusterPoint)
+}
+
+// MVT returns cached vector tile of c with single layer, as EncodeMVT encodes it
+// The slice is shared and should not be modified.
+func (cc *Cache) MVT(c *Cluster, t Tile, layerName string) []byte {
+	return cc.MVTLayers(c, t, []MVTLayer{{Name: layerName}})
+}
+
+// MVTLayers returns cached vector tile of c with the layers, as EncodeMVTLayers encodes it
+// Tiles are cached by names and properties of layers, so layers with the same names should have the same filters.
+func (cc *Cache) MVTLayers(c *Cluster, t Tile, layers []MVTLayer) []byte {
+	var query strings.Builder
+	query.WriteString("mvt/")
+	for _, l := range layers {
+		query.WriteString(l.Name)
+		if l.Properties != nil {
+			fmt.Fprintf(&query, "%q", l.Properties)
+		}
+		query.WriteByte('/')
+	}
+	query.WriteString(t.String())
+	v, _ := cc.Get(c, query.String(), func() (interface{}, error) {
+		northWest, southEast := TileBounds(t)
+		return EncodeMVTLayers(c.GetClusters(northWest, southEast), t, layers), nil
+	})
+	return v.([]byte)
+}
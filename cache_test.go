@@ -0,0 +1,121 @@
+package cluster
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCache(t *testing.T) {
+	c := NewCluster(0.01)
+	if err := c.ClusterPoints(randomPoints(100, 25, -10, -10, 10, 10)); err != nil {
+		t.Fatal(err)
+	}
+	cache := NewCache(2, 0)
+	computed := 0
+	get := func(query string) interface{} {
+		v, err := cache.Get(c, query, func() (interface{}, error) {
+			computed++
+			return query + "-result", nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+
+	get("a")
+	get("b")
+	if v := get("a"); v != "a-result" || computed != 2 {
+		t.Fatalf("cached result is %v, computed %d times", v, computed)
+	}
+	//b is the least recently used one
+	get("c")
+	if get("a"); computed != 3 || cache.Len() != 2 {
+		t.Fatalf("a is evicted: computed %d times, %d cached", computed, cache.Len())
+	}
+	if get("b"); computed != 4 {
+		t.Fatal("b is not evicted")
+	}
+
+	//results of the new version are computed again
+	if err := c.ReclusterWithEpsilon(0.02); err != nil {
+		t.Fatal(err)
+	}
+	if get("b"); computed != 5 {
+		t.Fatal("result of the previous version is returned")
+	}
+
+	if _, err := cache.Get(c, "d", func() (interface{}, error) { return nil, errors.New("failed") }); err == nil {
+		t.Fatal("expected error of compute")
+	}
+	if get("d"); computed != 6 {
+		t.Fatal("error is cached")
+	}
+	cache.Purge()
+	if cache.Len() != 0 {
+		t.Fatalf("%d results after Purge", cache.Len())
+	}
+}
+
+func TestCacheTTL(t *testing.T) {
+	c := NewCluster(0.01)
+	if err := c.ClusterPoints(randomPoints(100, 26, -10, -10, 10, 10)); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	cache := NewCache(10, time.Minute)
+	cache.now = func() time.Time { return now }
+	computed := 0
+	compute := func() (interface{}, error) {
+		computed++
+		return computed, nil
+	}
+	cache.Get(c, "q", compute)
+	now = now.Add(59 * time.Second)
+	if v, _ := cache.Get(c, "q", compute); v != 1 {
+		t.Fatalf("result is %v before ttl", v)
+	}
+	now = now.Add(time.Second)
+	if v, _ := cache.Get(c, "q", compute); v != 2 || cache.Len() != 1 {
+		t.Fatalf("result is %v after ttl, %d cached", v, cache.Len())
+	}
+
+	//cache of zero size keeps nothing
+	empty := NewCache(0, 0)
+	empty.Get(c, "q", compute)
+	if empty.Len() != 0 {
+		t.Fatal("cache of zero size keeps results")
+	}
+}
+
+func TestCacheQueries(t *testing.T) {
+	c, err := NewClusterForZoom(4, 256, 40)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.ClusterPoints(randomPoints(1000, 27, -60, -60, 60, 60)); err != nil {
+		t.Fatal(err)
+	}
+	cache := NewCache(10, 0)
+	northWest, southEast := GeoCoordinates{Lon: -20, Lat: 30}, GeoCoordinates{Lon: 40, Lat: -10}
+	want := c.GetClusters(northWest, southEast)
+	for i := 0; i < 2; i++ {
+		if got := cache.GetClusters(c, northWest, southEast); len(got) != len(want) {
+			t.Fatalf("cached box has %d points, want %d", len(got), len(want))
+		}
+	}
+	tile := Tile{X: 8, Y: 7, Z: 4}
+	if string(cache.MVT(c, tile, "clusters")) != string(cache.MVT(c, tile, "clusters")) || cache.Len() != 2 {
+		t.Fatalf("%d results are cached", cache.Len())
+	}
+
+	//handler answers the same viewport from the cache
+	h := NewClustersHandler(Levels{4: c})
+	h.Cache = cache
+	first := serve(h, "/clusters?bbox=-20,-10,40,30&zoom=4")
+	second := serve(h, "/clusters?bbox=-20,-10,40,30&zoom=4")
+	if cache.Len() != 3 || first.Body.String() != second.Body.String() {
+		t.Fatalf("%d results are cached by handler", cache.Len())
+	}
+}
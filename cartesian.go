@@ -0,0 +1,45 @@
+package cluster
+
+// Cartesian is no projection for planar points without geographic meaning, e.g. indoor maps, CAD floor plans
+// or scatter plots: x and y are stored as Lon and Lat of GeoCoordinates as is, and Epsilon is in units of the plane.
+// Boxes of GetClusters don't wrap around the antimeridian, GetClustersXY takes them as x and y ranges.
+type Cartesian struct{}
+
+// Project implements Projection interface
+func (Cartesian) Project(coordinates GeoCoordinates) (float64, float64) {
+	return coordinates.Lon, coordinates.Lat
+}
+
+// Unproject implements Projection interface
+func (Cartesian) Unproject(x, y float64) GeoCoordinates {
+	return GeoCoordinates{Lon: x, Lat: y}
+}
+
+// XY is the planar point clustered with Cartesian projection
+type XY struct {
+	X, Y float64
+}
+
+// GetCoordinates implements GeoPoint interface, X is Lon and Y is Lat
+func (p XY) GetCoordinates() GeoCoordinates {
+	return GeoCoordinates{Lon: p.X, Lat: p.Y}
+}
+
+// NewCartesianCluster creates new Cluster with default parameters for planar points with Cartesian projection,
+// epsilon is in units of the plane. X and Y of ClusterPoint are planar coordinates too.
+func NewCartesianCluster(epsilon float64) *Cluster {
+	c := NewCluster(epsilon)
+	c.Projection = Cartesian{}
+	return c
+}
+
+// GetClustersXY returns clusters and single points inside the rectangle of planar coordinates, like GetClusters
+func (c *Cluster) GetClustersXY(minX, minY, maxX, maxY float64) []ClusterPoint {
+	return c.GetClusters(GeoCoordinates{Lon: minX, Lat: maxY}, GeoCoordinates{Lon: maxX, Lat: minY})
+}
+
+// planar returns true if points are planar, so query boxes don't cross the antimeridian
+func (c *Cluster) planar() bool {
+	_, ok := c.projection().(Cartesian)
+	return ok
+}
@@ -0,0 +1,43 @@
+package cluster
+
+import "testing"
+
+func TestCartesian(t *testing.T) {
+	//two groups of a floor plan in meters and a single point
+	points := []GeoPoint{XY{X: 0, Y: 0}, XY{X: 4, Y: 3}, XY{X: 500, Y: 200}, XY{X: 503, Y: 204}, XY{X: -300, Y: 1000}}
+	c := NewCartesianCluster(10)
+	if err := c.ClusterPoints(points); err != nil {
+		t.Fatal(err)
+	}
+	if len(c.ResultPoints) != 3 {
+		t.Fatalf("%d result points, want 3", len(c.ResultPoints))
+	}
+	//coordinates are planar ones, far from geographic range
+	want := []XY{{X: 2, Y: 1.5}, {X: 501.5, Y: 202}, {X: -300, Y: 1000}}
+	for i, cp := range c.ResultPoints {
+		if cp.X != want[i].X || cp.Y != want[i].Y {
+			t.Fatalf("result point %d is at %v,%v, want %v", i, cp.X, cp.Y, want[i])
+		}
+	}
+	if got := c.GetClustersXY(-10, -10, 600, 300); len(got) != 2 {
+		t.Fatalf("%d points in the rectangle, want 2", len(got))
+	}
+	//boxes don't wrap around, reversed range is empty
+	if got := c.GetClusters(GeoCoordinates{Lon: 600, Lat: 300}, GeoCoordinates{Lon: -10, Lat: -10}); len(got) != 0 {
+		t.Fatalf("%d points in reversed box, want none", len(got))
+	}
+	northWest, southEast, ok := c.ExpansionBounds(c.ResultPoints[0].Id, 0.5)
+	if !ok || northWest != (GeoCoordinates{Lon: -2, Lat: 4.5}) || southEast != (GeoCoordinates{Lon: 6, Lat: -1.5}) {
+		t.Fatalf("planar bounds %v %v", northWest, southEast)
+	}
+
+	//XY is registered for snapshots
+	restored := roundTrip(t, c)
+	if restored.Projection != (Cartesian{}) {
+		t.Fatalf("restored projection %v", restored.Projection)
+	}
+	checkSameResult(t, c, restored)
+	if leaves, err := restored.Leaves(c.ResultPoints[2].Id, 0, 0); err != nil || leaves[0] != points[4] {
+		t.Fatalf("restored single point is %v: %v", leaves, err)
+	}
+}
@@ -0,0 +1,34 @@
+package cluster
+
+import "fmt"
+
+// CategoryAccessor returns category of the point, ok is false if the point has none
+type CategoryAccessor func(p GeoPoint) (category string, ok bool)
+
+// PropertyCategory returns CategoryAccessor for property of GeoPointWithProperties points, e.g. *Feature
+// String values are categories as they are, other scalar values are formatted, nil and missing values are no category.
+func PropertyCategory(key string) CategoryAccessor {
+	return func(p GeoPoint) (string, bool) {
+		switch v := pointProperties(p)[key].(type) {
+		case string:
+			return v, true
+		case bool, float64, float32, int, int32, int64, uint, uint32, uint64:
+			return fmt.Sprint(v), true
+		}
+		return "", false
+	}
+}
+
+// computeCategories sets Categories of the cluster of several points to counts of Category of its members
+func (c *Cluster) computeCategories(cp *ClusterPoint) {
+	cp.Categories = nil
+	if c.Category == nil || cp.NumPoints < 2 || len(cp.IncludedPoints) == 0 {
+		return
+	}
+	cp.Categories = map[string]int{}
+	for _, p := range cp.IncludedPoints {
+		if category, ok := c.Category(p); ok {
+			cp.Categories[category]++
+		}
+	}
+}
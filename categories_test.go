@@ -0,0 +1,54 @@
+package cluster
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCategories(t *testing.T) {
+	points := randomPoints(2000, 57, -30, -30, 30, 30)
+	kinds := []interface{}{"cafe", "bar", 3.0, nil}
+	for i, p := range points {
+		p.(*Feature).Properties["kind"] = kinds[i%len(kinds)]
+	}
+	c, err := NewClusterForZoom(3, 256, 60)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Category = PropertyCategory("kind")
+	if err := c.ClusterPoints(points); err != nil {
+		t.Fatal(err)
+	}
+	for _, cp := range c.ResultPoints {
+		if cp.NumPoints == 1 {
+			if cp.Categories != nil {
+				t.Fatalf("single point %d has categories %v", cp.Id, cp.Categories)
+			}
+			continue
+		}
+		//numbers are formatted, points without category are not counted
+		want := map[string]int{}
+		for _, id := range cp.memberIDs {
+			switch id % len(kinds) {
+			case 0:
+				want["cafe"]++
+			case 1:
+				want["bar"]++
+			case 2:
+				want["3"]++
+			}
+		}
+		if !reflect.DeepEqual(cp.Categories, want) {
+			t.Fatalf("cluster %d has categories %v, want %v", cp.Id, cp.Categories, want)
+		}
+		if properties := ClusterProperties(&cp); !reflect.DeepEqual(properties["categories"], want) {
+			t.Fatalf("cluster %d has categories property %v", cp.Id, properties["categories"])
+		}
+	}
+	restored := roundTrip(t, c)
+	for i := range c.ResultPoints {
+		if !reflect.DeepEqual(restored.ResultPoints[i].Categories, c.ResultPoints[i].Categories) {
+			t.Fatalf("restored result point %d has categories %v", i, restored.ResultPoints[i].Categories)
+		}
+	}
+}
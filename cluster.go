@@ -1,9 +1,12 @@
 package cluster
 
 import (
+	"errors"
+	"fmt"
 	"math"
-
-	"github.com/MadAppGang/kdbush"
+	"sort"
+	"sync"
+	"time"
 )
 
 // GeoCoordinates represent position in the Earth
@@ -17,6 +20,23 @@ type GeoPoint interface {
 	GetCoordinates() GeoCoordinates
 }
 
+// GeoPointWithID is the point with stable id of the source system, e.g. primary key, which is reported instead of
+// the index of the point in input slice. Id should be comparable, e.g. string or int64, nil means no id.
+type GeoPointWithID interface {
+	GeoPoint
+	GetID() interface{}
+}
+
+// pointID returns id of the point if it has one, and input index otherwise
+func pointID(p GeoPoint, index int) interface{} {
+	if p, ok := p.(GeoPointWithID); ok {
+		if id := p.GetID(); id != nil {
+			return id
+		}
+	}
+	return index
+}
+
 //Struct that implements clustered points
 //could have only one point or set of points
 type ClusterPoint struct {
@@ -25,76 +45,745 @@ type ClusterPoint struct {
 	Id             int //Index for pint, Id for cluster
 	NumPoints      int
 	IncludedPoints []GeoPoint
+	//Stats of numeric values registered by AddNumericStat
+	Stats map[string]*NumericStats `json:",omitempty"`
+	//TopLeaves are members of the cluster with the highest Cluster.LeafRank
+	TopLeaves []GeoPoint `json:",omitempty"`
+	//Weight is the sum of Cluster.Weight of members, NumPoints by default
+	Weight float64
+	//Label is set by Cluster.Label for clusters of several points
+	Label string `json:",omitempty"`
+	//SampleLeaves are members embedded into query output by SampleLeaves
+	SampleLeaves []GeoPoint `json:",omitempty"`
+	//Properties are Cluster.PropertyKeys of members, clusters have only values shared by all members
+	Properties map[string]interface{} `json:",omitempty"`
+	//PointCountAbbreviated is NumPoints of clusters of several points formatted by Cluster.CountFormat, e.g. "1.2k"
+	PointCountAbbreviated string `json:",omitempty"`
+	//Categories are counts of members of clusters of several points by Cluster.Category, e.g. for pie chart markers
+	Categories map[string]int `json:",omitempty"`
+	//Layers are counts of members of clusters of several points by layer of Cluster.ClusterMerged
+	Layers map[string]int `json:",omitempty"`
+
+	memberIDs  []int     //indexes of input points, parallel to IncludedPoints
+	topLeafIDs []int     //indexes of TopLeaves input points
+	spill      *spillRef //position of IncludedPoints in the spill file, see Cluster.SpillThreshold
+	region     int       //1-based index in Cluster.Boundaries of the polygon containing the point, 0 if there is none
 }
 
 func (cp *ClusterPoint) Coordinates() (float64, float64) {
 	return cp.X, cp.Y
 }
 
+// MemberIDs returns indexes of input points of the cluster, in the same order as IncludedPoints
+// The slice is shared and should not be modified.
+func (cp *ClusterPoint) MemberIDs() []int {
+	return cp.memberIDs
+}
+
+// SourceIDs returns ids of members implementing GeoPointWithID and input indexes of other members,
+// in the same order as IncludedPoints. Unlike MemberIDs they don't change with order of input points.
+func (cp *ClusterPoint) SourceIDs() []interface{} {
+	ids := make([]interface{}, len(cp.memberIDs))
+	for i, id := range cp.memberIDs {
+		ids[i] = id
+		if i < len(cp.IncludedPoints) {
+			ids[i] = pointID(cp.IncludedPoints[i], id)
+		}
+	}
+	return ids
+}
+
 // Cluster struct get a list or stream of geo objects
 // and produce all levels of clusters
 // PointSize - pixel size of marker, affects clustering radius
 // TileSize - size of tile in pixels, affects clustering radius
+// CoordinatesMode - how projected coordinates are stored in the index, CoordinatesFloat64 by default
+// MinPoints - minimum number of points to form a cluster, points of smaller groups are returned as is
+// StatPercentiles - percentiles in [0..1] range calculated for each stat registered with AddNumericStat
+// Zoom - zoom level Epsilon is calculated for, set by NewClusterForZoom
+// DeduplicateCoordinates - collapse points with exactly the same coordinates into one weighted point
+// Strategy - clustering algorithm, StrategyGreedy by default
+// Bandwidth - kernel radius of StrategyMeanShift in projected coordinates, Epsilon is used if it's zero
+// Capacity - number of points in each cluster of StrategyBalanced
+// SeedOrder - order points are taken as cluster seeds by StrategyGreedy, SeedInput by default
+// LatitudeCorrection - Epsilon is the radius at the equator and grows with mercator scale to the poles,
+// so clusters have the same radius on the ground at any latitude, used by StrategyGreedy and StrategyOPTICS
+// GreatCircle - neighbours are points within Epsilon great-circle distance, as fraction of the equator length,
+// the index search is only a prefilter then, so points near the poles are not merged with far away ones
+// CentroidMode - how cluster center is calculated from its members, CentroidProjected by default
+// Projection - how coordinates are projected for clustering, WebMercator by default,
+// Grid, Density, PixelSnap, CenterDamping, LatitudeCorrection, GreatCircle, CentroidGeodesic and CoordinatesFixed32 need WebMercator
+// Metrics - receives build and query measurements if it's set, see ExpvarMetrics
+// Tracer - starts spans around clustering stages and queries if it's set, see LogTracer
+// TopLeaves - number of members carried by each cluster in ClusterPoint.TopLeaves, e.g. for tooltips
+// LeafRank - rank of TopLeaves members, the highest first, members are taken in input order if it's nil
+// Weight - value of the point summed into ClusterPoint.Weight, e.g. order value, it's number of points if nil
+// WeightColumn - property column of ClusterColumns summed into ClusterPoint.Weight
+// PixelSnap - cluster centers are snapped to centers of pixel grid cells at Zoom level if TileSize is set
+// PropertyKeys - properties of GeoPointWithProperties members copied to ClusterPoint.Properties and output,
+// single points are output with all properties if it's nil
+// SpillThreshold - IncludedPoints of clusters of more points are written to temporary file in SpillDir, or in os.TempDir
+// if it's empty, and read back only by Leaves, so monster clusters don't keep member lists in memory; member ids stay.
+// Members should be registered with gob.Register. ConvexHull, Spiderfy and SampleLeaves see no members of spilled clusters.
+// Close removes the file.
+// Workers - goroutines clustering StrategyGreedy seeds of 32K+ points in strips by projected x concurrently,
+// seeds near strip borders are clustered after them by one goroutine, so clusters there could differ from sequential
+// ones, but they are the same for any Workers above 1. Fewer points have neighbours prefetched ahead of the sequential
+// loop instead, clusters are the same as found by one then; densities of SeedDensity are counted concurrently too.
+// 0 or 1 means sequential
+// Boundaries - regions such as countries or service areas, points are clustered only with points of the same region,
+// the first polygon containing them, and centers of clusters outside of their region are moved to the closest member.
+// Points outside of all polygons are clustered together. Regions are assigned when points are projected by ClusterPoints.
+// MaxZoom - points of Clusters with Zoom above it are not clustered, ResultPoints are input points as they are,
+// so street level zooms show exact markers with the same API; 0 means points of all zooms are clustered, see Levels.At
+// CountFormat - units and decimal separator of ClusterPoint.PointCountAbbreviated of the locale, English by default
+// HilbertPresort - projected points are sorted along Hilbert curve before the index is built and they are clustered,
+// so neighbour queries touch points close in memory; it speeds up builds of millions of unordered points.
+// StrategyGreedy takes seeds and members in curve order instead of input order and AllClusters are in curve order too,
+// as if input was sorted by location
+// Category - category of the point, e.g. PropertyCategory("amenity"), counted into ClusterPoint.Categories of clusters,
+// so pie chart markers are rendered without fetching leaves; members of ClusterColumns have no category
+// Time - timestamp of the point for GetClustersBetween, GetTime of GeoPointWithTime points is used if it's nil
+// IndexBackend - spatial index neighbours are searched in, IndexKDTree by default, IndexGrid for evenly distributed points
+// ThinOut - drops points of over-dense cells before clustering and merges them into kept ones, so counts stay honest;
+// cells are listed in Report
+// BorderPolicy - cluster border points of StrategyOPTICS join, BorderFirstReached by default
+// CenterDamping - clusters rebuilt by RebuildDirty, UpdatePoint and ReassignPoint keep centers of clusters they replace
+// if they moved less than the pixel threshold at Zoom level, so live views don't jitter; ClusterPoints centers are exact
+// IDGenerator - mints ids of clusters instead of the ClusterIdxSeed sequence, e.g. SequentialIDs shared by several Clusters
+// or ContentHashIDs stable across rebuilds; IsCluster and ZoomOfCluster know only ids of clusters in ResultPoints then
+// Label - called once for each cluster of several points when it's finalized, the result is stored in ClusterPoint.Label,
+// e.g. reverse geocoded name of the center or the dominant category of members; Lon/Lat coordinates are set already
 type Cluster struct {
-	Epsilon      float64
-	NodeSize     int
-	ResultPoints []ClusterPoint
+	Epsilon                float64
+	Zoom                   int
+	NodeSize               int
+	MinPoints              int
+	Strategy               Strategy
+	Bandwidth              float64
+	Capacity               int
+	SeedOrder              SeedOrder
+	LatitudeCorrection     bool
+	GreatCircle            bool
+	CentroidMode           CentroidMode
+	Projection             Projection
+	CoordinatesMode        CoordinatesMode
+	DeduplicateCoordinates bool
+	StatPercentiles        []float64
+	TopLeaves              int
+	LeafRank               NumericAccessor
+	Weight                 NumericAccessor
+	WeightColumn           string
+	PixelSnap              PixelGrid
+	PropertyKeys           []string
+	SpillThreshold         int
+	SpillDir               string
+	Workers                int
+	Boundaries             []Polygon
+	MaxZoom                int
+	CountFormat            CountFormat
+	HilbertPresort         bool
+	Category               CategoryAccessor
+	Time                   TimeAccessor
+	IndexBackend           IndexBackend
+	ThinOut                ThinOut
+	BorderPolicy           BorderPolicy
+	CenterDamping          CenterDamping
+	IDGenerator            IDGenerator
+	Label                  func(cp *ClusterPoint) string
+	ResultPoints           []ClusterPoint
+	Metrics                Metrics
+	Tracer                 Tracer
 
 	ClusterIdxSeed int
-	clusterIDLast  int
+	clusterSeq     int   //sequence number of the next cluster
+	generatedIDs   bool  //ids of clusters were minted by IDGenerator, which snapshots don't keep
+	mintedIDs      []int //ids minted by IDForgetter generator, which could be in ResultPoints, see forgetIDs
+
+	//projected input points and their index, kept to recluster with other epsilon
+	basePoints []*ClusterPoint
+	baseIndex  spatialIndex
+	//index in basePoints for each input point, nil if points are neither deduplicated nor presorted
+	baseOf []int
+	//index of the cluster in ResultPoints for each input point
+	assignment []int
+	//clusters in ResultPoints to cluster again by RebuildDirty, all of them if dirtyAll is set
+	dirty    map[int]bool
+	dirtyAll bool
+
+	numericStats   []numericStat
+	columnValues   map[string][]float64 //property columns of ClusterColumns, NaN is null
+	opticsOrdering []OPTICSPoint
+	emit           func(ClusterPoint) //receives result points of ClusterPointsStream
+	streamErr      error              //error of the last ClusterPointsStream, see StreamErr
+
+	version uint64
+	results *resultIndex //index of ResultPoints for GetClusters
+	spill   *spillStore  //spilled members of ResultPoints
+
+	boundaries []projectedRing //projected rings of Boundaries
+
+	layerNames  []string //layers of ClusterMerged
+	layerStarts []int    //id of the first point of each layer
+
+	report BuildReport //points skipped and adjusted by the last clustering of input
+}
+
+// ErrNotBuilt is returned by methods called before points are clustered by ClusterPoints or ClusterColumns,
+// errors wrap it, so they should be checked with errors.Is
+var ErrNotBuilt = errors.New("gocluster: points are not clustered")
+
+// ErrDirty is returned by methods called after MovePoint before RebuildDirty, errors wrap it like ErrNotBuilt ones
+var ErrDirty = errors.New("gocluster: moved points are not clustered again")
+
+func notBuilt(method string) error {
+	return fmt.Errorf("%w, ClusterPoints should be called before %s", ErrNotBuilt, method)
+}
+
+// Built returns true if points are clustered by ClusterPoints, ClusterColumns or restored by ReadSnapshot
+func (c *Cluster) Built() bool {
+	return c.baseIndex != nil
+}
+
+// Strategy is the clustering algorithm used to build ResultPoints
+type Strategy int
+
+const (
+	// StrategyGreedy is hierarchical greedy clustering: each not yet clustered point
+	// takes all not clustered neighbours within Epsilon
+	StrategyGreedy Strategy = iota
+	// StrategyOPTICS is density based clustering, ResultPoints are clusters of the reachability ordering at Epsilon,
+	// ExtractOPTICS returns clusters for smaller epsilons without clustering again
+	StrategyOPTICS
+	// StrategyMeanShift moves cluster centers to density modes with flat kernel of Bandwidth radius,
+	// it gives more natural centers for irregular densities
+	StrategyMeanShift
+	// StrategyBalanced splits points into compact clusters of about Capacity points each, regardless of Epsilon,
+	// e.g. for territory planning and splitting delivery routes
+	StrategyBalanced
+)
+
+// CentroidMode defines how cluster centers are calculated
+type CentroidMode int
+
+const (
+	// CentroidProjected is weighted mean of mercator coordinates, it's fast and fine for small clusters
+	CentroidProjected CentroidMode = iota
+	// CentroidGeodesic is weighted mean of members on the sphere, projected back to the surface.
+	// Mean of mercator coordinates is biased to the pole for clusters of hundreds of kilometers at high latitudes.
+	CentroidGeodesic
+)
+
+// SeedOrder defines the order StrategyGreedy takes points as cluster seeds
+type SeedOrder int
+
+const (
+	// SeedInput takes seeds in input order
+	SeedInput SeedOrder = iota
+	// SeedHilbert takes seeds along Hilbert curve, so consecutive neighbour queries touch the same parts of the index
+	// for points which are not ordered by location. Clusters are different from SeedInput ones.
+	SeedHilbert
+	// SeedDensity takes seeds in descending order of the number of points within Epsilon, points of equal density
+	// in input order, so clusters are centered on dense spots and don't depend on input order much.
+	// Densities cost one more neighbour query per point.
+	SeedDensity
+)
+
+// PixelGrid is the grid of CellPx pixels on the map with tiles of TileSize pixels, CellPx is 1 if it's zero
+// Snapped markers keep their positions across rebuilds, and visually identical markers have exactly the same coordinates.
+type PixelGrid struct {
+	TileSize int
+	CellPx   float64
 }
 
 // Create new Cluster instance with default parameters:
 // NodeSize is size of the KD-tree node, 64 by default. Higher means faster indexing but slower search, and vise versa.
+// CoordinatesMode is CoordinatesFloat64, use CoordinatesFloat32 or CoordinatesFixed32 to save memory on huge datasets.
+// MinPoints is 2, so any two neighbours form a cluster.
 func NewCluster(epsilon float64) *Cluster {
 	return &Cluster{
-		Epsilon:  epsilon,
-		NodeSize: 64,
+		Epsilon:   epsilon,
+		NodeSize:  64,
+		MinPoints: 2,
 	}
 }
 
+// NewClusterForZoom creates new Cluster with default parameters and epsilon for the zoom level:
+// the radius of marker of markerPx pixels on the map with tiles of tileSize pixels.
+// Zoom should be in 0..21 range
+func NewClusterForZoom(zoom, tileSize, markerPx int) (*Cluster, error) {
+	if zoom < 0 || zoom > MaxZoomLevel {
+		return nil, fmt.Errorf("gocluster: zoom should be in 0..%d range, got %d", MaxZoomLevel, zoom)
+	}
+	if tileSize <= 0 || markerPx <= 0 {
+		return nil, fmt.Errorf("gocluster: tile size and marker size should be positive, got %d and %d", tileSize, markerPx)
+	}
+	c := NewCluster(EpsilonForZoom(zoom, tileSize, markerPx))
+	c.Zoom = zoom
+	return c, nil
+}
+
 // ClusterPoint get points and create multilevel clustered indexes
 // All points should implement GeoPoint interface
 // they are not copied, so you could not worry about memory efficiency
 // And GetCoordinates called only once for each object, so you could calc it on the fly, if you need
+// Calling it again clusters new points from scratch, nothing of the previous clustering is kept
+// Points with invalid coordinates are skipped, Report lists them and points with clamped latitudes
 func (c *Cluster) ClusterPoints(points []GeoPoint) error {
+	c.columnValues = nil
+	c.layerNames, c.layerStarts = nil, nil
+	return c.clusterInput(len(points), func(i int) GeoCoordinates { return points[i].GetCoordinates() }, points)
+}
+
+// ClusterPointsStream clusters points like ClusterPoints, but sends result points to the channel
+// as soon as they are finalized, so exporters could write output before the whole job is completed.
+// StrategyGreedy with SeedInput and MinPoints up to 2 finalizes clusters during clustering, others send all points after it.
+// The channel is closed when clustering is done, the Cluster should not be used until then.
+// Errors of ClusterPoints, e.g. of invalid options, close the channel without points, StreamErr returns them.
+func (c *Cluster) ClusterPointsStream(points []GeoPoint) <-chan ClusterPoint {
+	stream := make(chan ClusterPoint, 64)
+	c.streamErr = nil
+	go func() {
+		defer close(stream)
+		c.emit = func(cp ClusterPoint) { stream <- cp }
+		defer func() { c.emit = nil }()
+		c.streamErr = c.ClusterPoints(points)
+	}()
+	return stream
+}
+
+// StreamErr returns error of the last ClusterPointsStream, it should be called after the channel is closed
+func (c *Cluster) StreamErr() error {
+	return c.streamErr
+}
 
+// clusterInput projects n input points, builds index and clusters them
+// points are members of the result, they could be nil when input is not GeoPoint
+func (c *Cluster) clusterInput(n int, coordinates func(i int) GeoCoordinates, points []GeoPoint) error {
+	if err := c.checkOptions(); err != nil {
+		return err
+	}
+	defer c.observeBuild(n, time.Now())
+	c.indexInput(n, coordinates, points)
+	c.buildResultPoints()
+	return nil
+}
+
+// indexInput projects n input points into base points and builds their index
+func (c *Cluster) indexInput(n int, coordinates func(i int) GeoCoordinates, points []GeoPoint) {
 	//get digits number, start from next exponent
 	//if we have 78, all cluster will start from 100...
 	//if we have 986 points, all clusters ids will start from 1000
-	c.ClusterIdxSeed = int(math.Pow(10, float64(digitsCount(len(points)))))
-	c.clusterIDLast = c.ClusterIdxSeed
+	c.ClusterIdxSeed = int(math.Pow(10, float64(digitsCount(n))))
+	c.clusterSeq = 0
+	c.generatedIDs = false
 
-	clusters := translateGeoPointsToClusterPoints(points)
-	tmpIndex := kdbush.NewBush(clustersToPoints(clusters), c.NodeSize)
+	//projected coordinates are written to the index in the same pass
+	span := c.startSpan("project", "points", n)
+	index := c.newIndexBuilder(n)
+	check := c.newInputCheck(n)
+	switch {
+	case c.DeduplicateCoordinates:
+		c.basePoints, c.baseOf = translateDeduplicatedGeoPoints(n, coordinates, points, c.projection(), index, check)
+	case c.HilbertPresort:
+		c.basePoints, c.baseOf = translatePresortedGeoPoints(n, coordinates, points, c.projection(), index, check)
+	default:
+		c.basePoints, c.baseOf = translateGeoPointsToClusterPoints(n, coordinates, points, c.projection(), index, check), nil
+		if len(check.report.Skipped) > 0 {
+			c.baseOf = skippedBaseOf(n, c.basePoints)
+		}
+	}
+	c.report = check.report
+	c.assignRegions()
+	thinned := c.thinOut()
+	span.End("base_points", len(c.basePoints), "skipped", len(c.report.Skipped))
 
-	//create clusters for level up using just created index
-	clusters = c.clusterize(clusters, tmpIndex)
+	span = c.startSpan("index", "backend", c.IndexBackend, "mode", c.CoordinatesMode)
+	if c.HilbertPresort && c.DeduplicateCoordinates {
+		//duplicates are found in input order, then sorted
+		c.presortBasePoints()
+		c.baseIndex = c.indexPoints(c.basePoints)
+	} else if thinned {
+		//dropped points were added to the index while they were projected
+		c.baseIndex = c.indexPoints(c.basePoints)
+	} else {
+		c.baseIndex = index.finish()
+	}
+	span.End("bytes", c.baseIndex.Bytes())
+}
+
+// ReclusterWithEpsilon clusters the same points again with new epsilon
+// Projected points and index created by ClusterPoints are reused, so it's much faster than new Cluster
+// ResultPoints are replaced, cluster sequence numbers start from zero again
+func (c *Cluster) ReclusterWithEpsilon(eps float64) error {
+	if c.baseIndex == nil {
+		return notBuilt("ReclusterWithEpsilon")
+	}
+	if err := checkEpsilon(eps); err != nil {
+		return err
+	}
+	defer c.observeBuild(0, time.Now())
+	c.Epsilon = eps
+	c.rebuildResultPoints()
+	return nil
+}
+
+// rebuildResultPoints clusters already clustered base points from scratch
+func (c *Cluster) rebuildResultPoints() {
+	c.clusterSeq = 0
+	c.generatedIDs = false
+	for _, p := range c.basePoints {
+		p.visited = false
+	}
+	c.buildResultPoints()
+}
+
+// buildResultPoints clusters base points with current epsilon
+func (c *Cluster) buildResultPoints() {
+	span := c.startSpan("clusterize", "strategy", c.Strategy, "epsilon", c.Epsilon)
+	c.assignment = c.newAssignment()
+	c.opticsOrdering = nil
+	c.resetSpill()
+	var clusters []*ClusterPoint
+	streamed := false
+	switch {
+	case c.passthrough():
+		//each base point is the result point as is
+		clusters = c.basePoints
+	case c.Strategy == StrategyOPTICS:
+		c.opticsOrdering = c.optics()
+		for _, o := range c.opticsOrdering {
+			c.basePoints[o.ID].visited = true
+		}
+		clusters = c.extractOPTICS(c.opticsOrdering, c.Epsilon, c.nextClusterID)
+	case c.Strategy == StrategyMeanShift:
+		clusters = c.meanShift()
+	case c.Strategy == StrategyBalanced:
+		clusters = c.balanced()
+	default:
+		if c.emit != nil && c.inputOrdered() {
+			//clusters are added and emitted as soon as they are finalized
+			c.ResultPoints = nil
+			prefetch := c.prefetchNeighbours(c.basePoints, nil, c.basePoints, c.baseIndex)
+			c.clusterizeSeeds(c.basePoints, c.basePoints, c.baseIndex, prefetch, c.appendResultPoint)
+			prefetch.stop()
+			streamed = true
+			break
+		}
+		//create clusters for level up using base index
+		clusters = c.clusterize(c.basePoints, c.baseIndex)
+	}
+	if !streamed {
+		if c.Strategy != StrategyGreedy || !c.inputOrdered() {
+			sortByFirstMember(clusters)
+		}
+		c.setResultPoints(clusters)
+	}
+	c.dirty, c.dirtyAll = nil, false
+	c.resultsChanged()
+	span.End("clusters", len(c.ResultPoints))
+}
+
+// passthrough returns true if points are not clustered at Zoom above MaxZoom
+func (c *Cluster) passthrough() bool {
+	return c.MaxZoom > 0 && c.Zoom > c.MaxZoom
+}
+
+// inputOrdered returns true if greedy clustering creates result points ordered by the first member already:
+// seeds are taken in input order and groups smaller than MinPoints, whose points are kept as is, are never formed.
+// Presorted base points are in curve order, which is kept.
+func (c *Cluster) inputOrdered() bool {
+	return c.SeedOrder == SeedInput && c.MinPoints <= 2
+}
+
+// setResultPoints replaces ResultPoints with projected clusters
+func (c *Cluster) setResultPoints(clusters []*ClusterPoint) {
 	c.ResultPoints = make([]ClusterPoint, 0, len(clusters))
 	for i := range clusters {
-		cluster := *clusters[i]
-		coordinates := ReverseMercatorProjection(cluster.X, cluster.Y)
-		cluster.X = coordinates.Lon
-		cluster.Y = coordinates.Lat
-		c.ResultPoints = append(c.ResultPoints, cluster)
+		c.appendResultPoint(clusters[i])
 	}
+}
 
-	return nil
+// snapToPixels moves projected center of the cluster of several points to the center of its PixelSnap grid cell
+func (c *Cluster) snapToPixels(cp *ClusterPoint) {
+	if c.PixelSnap.TileSize <= 0 || cp.NumPoints < 2 {
+		return
+	}
+	cell := c.PixelSnap.CellPx
+	if cell <= 0 {
+		cell = 1
+	}
+	//size of the grid cell in projected coordinates
+	size := cell / (float64(c.PixelSnap.TileSize) * tileScale(c.Zoom))
+	cp.X = (math.Floor(cp.X/size) + 0.5) * size
+	cp.Y = (math.Floor(cp.Y/size) + 0.5) * size
+}
+
+// computeLabel sets Label of the cluster of several points by Label callback
+func (c *Cluster) computeLabel(cp *ClusterPoint) {
+	cp.Label = ""
+	if c.Label != nil && cp.NumPoints > 1 {
+		cp.Label = c.Label(cp)
+	}
+}
+
+// resultPoint returns projected cluster with Lon/Lat coordinates and aggregates, as ResultPoints have them
+func (c *Cluster) resultPoint(cp *ClusterPoint) ClusterPoint {
+	cluster := *cp
+	c.snapToPixels(&cluster)
+	c.constrainCenter(&cluster)
+	coordinates := c.projection().Unproject(cluster.X, cluster.Y)
+	cluster.X = coordinates.Lon
+	cluster.Y = coordinates.Lat
+	c.computeStats(&cluster)
+	c.computeTopLeaves(&cluster)
+	c.computeProperties(&cluster)
+	c.computeCategories(&cluster)
+	c.computeLayers(&cluster)
+	c.computeLabel(&cluster)
+	c.computeCountAbbreviated(&cluster)
+	return cluster
 }
 
-// AllClusters returns all cluster points
+// appendResultPoint adds projected cluster to ResultPoints with Lon/Lat coordinates
+func (c *Cluster) appendResultPoint(cp *ClusterPoint) {
+	cluster := c.resultPoint(cp)
+	c.spillMembers(&cluster)
+	for _, id := range cluster.memberIDs {
+		c.assignment[id] = len(c.ResultPoints)
+	}
+	c.ResultPoints = append(c.ResultPoints, cluster)
+	if c.emit != nil {
+		c.emit(cluster)
+	}
+}
+
+// AllClusters returns all cluster points, nil before points are clustered, see Built
+// Points are ordered by the smallest input index of their members, so single points keep input order.
+// Members of clusters are the seed followed by other members in input order. The same points and options give the same
+// result, ids and order on every run, it doesn't depend on NodeSize either. UpdatePoint and RebuildDirty change the order.
 func (c *Cluster) AllClusters() []ClusterPoint {
 	return c.ResultPoints
 }
 
+// ResultIndex returns index in ResultPoints of the cluster or single point containing input point id,
+// id is the index of the point in the slice passed to ClusterPoints or the row of ClusterColumns,
+// ok is false if it's out of range or the point is skipped, see Report
+func (c *Cluster) ResultIndex(id int) (index int, ok bool) {
+	if id < 0 || id >= len(c.assignment) || c.assignment[id] < 0 {
+		return 0, false
+	}
+	return c.assignment[id], true
+}
+
+// sortByFirstMember orders clusters by the smallest input index of their members
+// Greedy clustering takes seeds in input order, so its result is already in this order.
+func sortByFirstMember(clusters []*ClusterPoint) {
+	first := make([]int, len(clusters))
+	for i, cp := range clusters {
+		m := cp.memberIDs[0]
+		for _, id := range cp.memberIDs[1:] {
+			m = minInt(m, id)
+		}
+		first[i] = m
+	}
+	//each point is a member of one cluster, so first members are unique and the order is the same for any sort
+	if max := maxOf(first); max < 4*len(clusters) {
+		//most points are clusters or their first members, clusters are put to slots of first members
+		slots := make([]*ClusterPoint, max+1)
+		for i, cp := range clusters {
+			slots[first[i]] = cp
+		}
+		n := 0
+		for _, cp := range slots {
+			if cp != nil {
+				clusters[n] = cp
+				n++
+			}
+		}
+		return
+	}
+	sort.Sort(byFirstMember{clusters: clusters, first: first})
+}
+
+// maxOf returns the largest of values, -1 if there are none
+func maxOf(values []int) int {
+	max := -1
+	for _, v := range values {
+		max = maxInt(max, v)
+	}
+	return max
+}
+
+type byFirstMember struct {
+	clusters []*ClusterPoint
+	first    []int
+}
+
+func (s byFirstMember) Len() int           { return len(s.clusters) }
+func (s byFirstMember) Less(i, j int) bool { return s.first[i] < s.first[j] }
+func (s byFirstMember) Swap(i, j int) {
+	s.clusters[i], s.clusters[j] = s.clusters[j], s.clusters[i]
+	s.first[i], s.first[j] = s.first[j], s.first[i]
+}
+
+// Clusters returns only clusters of several points, in the same order as in AllClusters, nil before points are clustered
+// Deduplicated point with several duplicates is returned as cluster as well
+func (c *Cluster) Clusters() []ClusterPoint {
+	var result []ClusterPoint
+	for i := range c.ResultPoints {
+		if c.ResultPoints[i].NumPoints > 1 {
+			result = append(result, c.ResultPoints[i])
+		}
+	}
+	return result
+}
+
+// Singles returns points that are not merged with any other point, in the same order as in AllClusters,
+// nil before points are clustered
+func (c *Cluster) Singles() []ClusterPoint {
+	var result []ClusterPoint
+	for i := range c.ResultPoints {
+		if c.ResultPoints[i].NumPoints == 1 {
+			result = append(result, c.ResultPoints[i])
+		}
+	}
+	return result
+}
+
 //clusterize points
-func (c *Cluster) clusterize(points []*ClusterPoint, index *kdbush.KDBush) []*ClusterPoint {
-	var result []*ClusterPoint
-	r := c.Epsilon
+func (c *Cluster) clusterize(points []*ClusterPoint, index spatialIndex) []*ClusterPoint {
+	var order []int
+	switch c.SeedOrder {
+	case SeedHilbert:
+		order = hilbertOrder(points)
+	case SeedDensity:
+		order = c.densityOrder(points, index)
+	}
+	seeds := points
+	if order != nil {
+		seeds = make([]*ClusterPoint, len(points))
+		for i, o := range order {
+			seeds[i] = points[o]
+		}
+	}
+	if bounds := c.stripBounds(points); bounds != nil {
+		return c.clusterizeStrips(seeds, order, points, index, bounds)
+	}
+	prefetch := c.prefetchNeighbours(seeds, order, points, index)
+	defer prefetch.stop()
+	return c.clusterizeSeeds(seeds, points, index, prefetch, nil)
+}
+
+//presortBasePoints sorts base points along Hilbert curve into new array, so they are close in memory too,
+//baseOf maps input points to their new indexes
+func (c *Cluster) presortBasePoints() {
+	order := hilbertOrder(c.basePoints)
+	moved := make([]int, len(order))
+	sorted := make([]ClusterPoint, len(order))
+	for i, o := range order {
+		sorted[i] = *c.basePoints[o]
+		moved[o] = i
+	}
+	if c.baseOf == nil {
+		c.baseOf = make([]int, c.numInputPoints())
+		for i := range c.baseOf {
+			c.baseOf[i] = i
+		}
+	}
+	for id, b := range c.baseOf {
+		if b >= 0 {
+			c.baseOf[id] = moved[b]
+		}
+	}
+	for i := range sorted {
+		c.basePoints[i] = &sorted[i]
+	}
+}
+
+//hilbertOrder returns indexes of points sorted along Hilbert curve of projected coordinates
+func hilbertOrder(points []*ClusterPoint) []int {
+	return hilbertOrderOf(len(points), func(i int) (float64, float64) { return points[i].X, points[i].Y })
+}
+
+//hilbertOrderOf returns indexes of n projected coordinates sorted along Hilbert curve
+func hilbertOrderOf(n int, xy func(i int) (float64, float64)) []int {
+	//the curve covers bounding box of points, projections other than WebMercator are not in [0..1] range
+	minX, minY, maxX, maxY := math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)
+	for i := 0; i < n; i++ {
+		x, y := xy(i)
+		minX, maxX = math.Min(minX, x), math.Max(maxX, x)
+		minY, maxY = math.Min(minY, y), math.Max(maxY, y)
+	}
+	size := math.Max(maxX-minX, maxY-minY)
+	if size == 0 {
+		size = 1
+	}
+	//curve position in high bits and index in low ones, so points of the same cell keep input order
+	keys := make([]uint64, n)
+	for i := range keys {
+		x, y := xy(i)
+		keys[i] = uint64(hilbert(uint32((x-minX)/size*0xFFFF), uint32((y-minY)/size*0xFFFF)))<<32 | uint64(i)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	order := make([]int, n)
+	for i, k := range keys {
+		order[i] = int(uint32(k))
+	}
+	return order
+}
+
+//densityOrder returns indexes of points sorted by descending weight of neighbours within Epsilon
+func (c *Cluster) densityOrder(points []*ClusterPoint, index spatialIndex) []int {
+	density := make([]int, len(points))
+	c.parallel(len(points), func(start, end int) {
+		var neighbours []int
+		for i := start; i < end; i++ {
+			neighbours = c.appendNeighboursOf(neighbours[:0], index, points, points[i])
+			for _, id := range neighbours {
+				density[i] += points[id].NumPoints
+			}
+		}
+	})
+	order := make([]int, len(points))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool { return density[order[i]] > density[order[j]] })
+	return order
+}
+
+//clusterizeSeeds creates clusters around seeds, neighbours are searched in all points
+//finalized is called for each result point as soon as it's created, if it's not nil
+//neighbours of seeds are taken from prefetch if it's not nil
+func (c *Cluster) clusterizeSeeds(seeds, points []*ClusterPoint, index spatialIndex, prefetch *neighbourPrefetch, finalized func(*ClusterPoint)) []*ClusterPoint {
+	//there are never more clusters than seeds
+	result := make([]*ClusterPoint, 0, len(seeds))
+
+	scratch := scratchPool.Get().(*clusterizeScratch)
+	defer scratchPool.Put(scratch)
+	//visited flags of points by index, dense copy of ClusterPoint.visited, so neighbours found again
+	//by later queries are skipped without loading the point; the flag is only trusted when it's set
+	if cap(scratch.done) < len(points) {
+		scratch.done = make([]bool, len(points))
+	}
+	done := scratch.done[:len(points)]
+	for i := range done {
+		done[i] = false
+	}
+	emitted := 0
+	emit := func() {
+		if finalized != nil {
+			for _, cp := range result[emitted:] {
+				finalized(cp)
+			}
+			emitted = len(result)
+		}
+	}
 
 	//iterate all clusters
-	for pi := range points {
-		p := points[pi]
+	for pi := range seeds {
+		p := seeds[pi]
 		//skip points we have already clustered
 		if p.visited {
 			continue
@@ -102,70 +791,334 @@ func (c *Cluster) clusterize(points []*ClusterPoint, index *kdbush.KDBush) []*Cl
 		// mark this point as visited
 		p.visited = true
 
-		//find all neighbours
-		neighbourIds := index.Within(&kdbush.SimplePoint{X: p.X, Y: p.Y}, r)
-
-		nPoints := p.NumPoints
-		wx := p.X
-		wy := p.Y
-
-		var foundNeighbours []*ClusterPoint
-		includedPoints := p.IncludedPoints
+		var neighbours []int
+		if prefetch != nil {
+			neighbours = prefetch.neighbours(pi)
+			prefetch.visit(prefetch.pointOf(pi))
+		} else {
+			//find all neighbours, buffer is reused between queries
+			scratch.neighbours = c.appendNeighboursOf(scratch.neighbours[:0], index, points, p)
+			//members are taken in input order, not in order of the index, which depends on NodeSize and CoordinatesMode
+			sort.Ints(scratch.neighbours)
+			neighbours = scratch.neighbours
+		}
 
-		for j := range neighbourIds {
-			b := points[neighbourIds[j]]
+		var visit func(id int)
+		if prefetch != nil {
+			visit = prefetch.visit
+		}
+		foundNeighbours, nPoints := absorb(p, neighbours, points, done, scratch.found[:0], visit)
+		scratch.found = foundNeighbours
 
-			//Filter out neighbours, that are already processed (and processed point "p" as well)
-			if !b.visited {
-				wx += b.X
-				wy += b.Y
-				nPoints += b.NumPoints
-				b.visited = true //set the zoom to skip in other iterations
-				foundNeighbours = append(foundNeighbours, b)
-				includedPoints = append(includedPoints, b.IncludedPoints...)
-			}
+		//group is too small, keep all points as is
+		if len(foundNeighbours) > 0 && nPoints < c.MinPoints {
+			result = append(result, p)
+			result = append(result, foundNeighbours...)
+			emit()
+			continue
 		}
+
 		newCluster := p
 
 		//create new cluster
 		if len(foundNeighbours) > 0 {
-			newCluster = &ClusterPoint{}
-			newCluster.X = wx / float64(nPoints)
-			newCluster.Y = wy / float64(nPoints)
-			newCluster.NumPoints = nPoints
-			newCluster.visited = false
-			newCluster.Id = c.clusterIDLast
-			newCluster.IncludedPoints = includedPoints
-			c.clusterIDLast += 1
+			newCluster = c.newCluster(p, foundNeighbours)
 		}
 		result = append(result, newCluster)
+		emit()
+	}
+
+	//don't keep references to points in the pool
+	for i := range scratch.found {
+		scratch.found[i] = nil
 	}
 	return result
 }
 
+// absorb marks unvisited neighbours of the seed p as visited and returns them with the number of points of the group
+// done is dense copy of visited flags of points by index, visit is called for each absorbed point if it's not nil.
+func absorb(p *ClusterPoint, neighbours []int, points []*ClusterPoint, done []bool, found []*ClusterPoint, visit func(id int)) ([]*ClusterPoint, int) {
+	nPoints := p.NumPoints
+	for _, id := range neighbours {
+		if done[id] {
+			continue
+		}
+		done[id] = true
+		b := points[id]
+
+		//Filter out neighbours, that are already processed (and processed point "p" as well)
+		if !b.visited {
+			nPoints += b.NumPoints
+			b.visited = true //set the zoom to skip in other iterations
+			if visit != nil {
+				visit(id)
+			}
+			found = append(found, b)
+		}
+	}
+	return found, nPoints
+}
+
+// newCluster merges points into new cluster with weighted centroid and new id
+func (c *Cluster) newCluster(first *ClusterPoint, rest []*ClusterPoint) *ClusterPoint {
+	cluster := c.mergePoints(first, rest)
+	cluster.Id = c.nextClusterID(cluster.memberIDs)
+	return cluster
+}
+
+// mergePoints merges points into cluster with weighted centroid, its id is not set
+// members are allocated once with exact size
+func (c *Cluster) mergePoints(first *ClusterPoint, rest []*ClusterPoint) *ClusterPoint {
+	nPoints := first.NumPoints
+	wx := first.X * float64(first.NumPoints)
+	wy := first.Y * float64(first.NumPoints)
+	for _, b := range rest {
+		nPoints += b.NumPoints
+		wx += b.X * float64(b.NumPoints)
+		wy += b.Y * float64(b.NumPoints)
+	}
+
+	x, y := wx/float64(nPoints), wy/float64(nPoints)
+	if c.CentroidMode == CentroidGeodesic {
+		x, y = geodesicCentroid(first, rest, x, y)
+	}
+
+	cluster := &ClusterPoint{
+		X:              x,
+		Y:              y,
+		NumPoints:      nPoints,
+		IncludedPoints: make([]GeoPoint, 0, nPoints),
+		memberIDs:      make([]int, 0, nPoints),
+		region:         first.region,
+	}
+	cluster.IncludedPoints = append(cluster.IncludedPoints, first.IncludedPoints...)
+	cluster.memberIDs = append(cluster.memberIDs, first.memberIDs...)
+	for _, b := range rest {
+		cluster.IncludedPoints = append(cluster.IncludedPoints, b.IncludedPoints...)
+		cluster.memberIDs = append(cluster.memberIDs, b.memberIDs...)
+	}
+	return cluster
+}
+
+// geodesicCentroid returns projected weighted mean of unit vectors of points
+// x, y are returned for points spread evenly around the sphere, which have no mean
+func geodesicCentroid(first *ClusterPoint, rest []*ClusterPoint, x, y float64) (float64, float64) {
+	var vx, vy, vz float64
+	add := func(p *ClusterPoint) {
+		c := ReverseMercatorProjection(p.X, p.Y)
+		lon, lat := c.Lon*math.Pi/180, c.Lat*math.Pi/180
+		w := float64(p.NumPoints)
+		vx += w * math.Cos(lat) * math.Cos(lon)
+		vy += w * math.Cos(lat) * math.Sin(lon)
+		vz += w * math.Sin(lat)
+	}
+	add(first)
+	for _, b := range rest {
+		add(b)
+	}
+	if math.Sqrt(vx*vx+vy*vy+vz*vz) < 1e-12 {
+		return x, y
+	}
+	return MercatorProjection(GeoCoordinates{
+		Lon: math.Atan2(vy, vx) * 180 / math.Pi,
+		Lat: math.Atan2(vz, math.Hypot(vx, vy)) * 180 / math.Pi,
+	})
+}
+
+// clusterizeScratch is reusable buffers of clusterize
+type clusterizeScratch struct {
+	neighbours []int
+	found      []*ClusterPoint
+	done       []bool
+}
+
+var scratchPool = sync.Pool{
+	New: func() interface{} { return &clusterizeScratch{} },
+}
+
+// zoomBits is the number of low bits of cluster id (after ClusterIdxSeed) that store zoom level
+const zoomBits = 5
+
+// nextClusterID returns new cluster id of members, minted by IDGenerator if it's set,
+// otherwise it encodes sequence number and zoom level: ClusterIdxSeed + (sequence << 5) + zoom
+func (c *Cluster) nextClusterID(members []int) int {
+	if c.IDGenerator != nil {
+		c.generatedIDs = true
+		id := c.IDGenerator.NextID(c.Zoom, members)
+		if _, ok := c.IDGenerator.(IDForgetter); ok {
+			c.mintedIDs = append(c.mintedIDs, id)
+		}
+		return id
+	}
+	id := c.ClusterIdxSeed + c.clusterSeq<<zoomBits + c.Zoom
+	c.clusterSeq++
+	return id
+}
+
+// IsCluster returns true if id is generated cluster id, and false if it's the index of input point
+// Ids minted by IDGenerator encode nothing, so only ids of clusters in ResultPoints are known then.
+func (c *Cluster) IsCluster(id int) bool {
+	if c.IDGenerator != nil || c.generatedIDs {
+		i, ok := c.resultByID(id)
+		return ok && c.ResultPoints[i].NumPoints > 1
+	}
+	return id >= c.ClusterIdxSeed
+}
+
+// ZoomOfCluster returns zoom level encoded in the cluster id
+// Returns -1 if id is not cluster id, but index of input point
+// Ids minted by IDGenerator encode no zoom, Zoom is returned for clusters in ResultPoints then.
+func (c *Cluster) ZoomOfCluster(id int) int {
+	if !c.IsCluster(id) {
+		return -1
+	}
+	if c.IDGenerator != nil || c.generatedIDs {
+		return c.Zoom
+	}
+	return (id - c.ClusterIdxSeed) & (1<<zoomBits - 1)
+}
+
 ////////// End of Cluster implementation
 
 //translate geopoints to ClusterPoints witrh projection coordinates
-func translateGeoPointsToClusterPoints(points []GeoPoint) []*ClusterPoint {
-	var result = make([]*ClusterPoint, len(points))
-	for i, p := range points {
-		cp := ClusterPoint{
-			IncludedPoints: []GeoPoint{points[i]},
+//all points and their members are allocated in bulk, members are one element slices of shared arrays
+//projected coordinates are added to the index as well, points not accepted by check are skipped
+func translateGeoPointsToClusterPoints(n int, coordinatesOf func(i int) GeoCoordinates, points []GeoPoint, projection Projection, index indexBuilder, check *inputCheck) []*ClusterPoint {
+	var result = make([]*ClusterPoint, 0, n)
+	clusterPoints := make([]ClusterPoint, n)
+	ids := make([]int, n)
+	for i := 0; i < n; i++ {
+		coordinates := coordinatesOf(i)
+		if !check.accept(i, coordinates) {
+			continue
 		}
+		ids[i] = i
+		cp := &clusterPoints[i]
+		//full slice expressions, so append never writes into shared arrays
+		if points != nil {
+			cp.IncludedPoints = points[i : i+1 : i+1]
+		}
+		cp.memberIDs = ids[i : i+1 : i+1]
 		cp.visited = false
-		cp.X, cp.Y = MercatorProjection(p.GetCoordinates())
-		result[i] = &cp
+		cp.X, cp.Y = projection.Project(coordinates)
+		index.add(cp.X, cp.Y)
+		result = append(result, cp)
 		cp.NumPoints = 1
 		cp.Id = i
 	}
 	return result
 }
 
+//skippedBaseOf returns index of the base point for each of n input points, -1 for skipped ones,
+//base points are single input points in input order
+func skippedBaseOf(n int, basePoints []*ClusterPoint) []int {
+	baseOf := make([]int, n)
+	for i := range baseOf {
+		baseOf[i] = -1
+	}
+	for b, p := range basePoints {
+		baseOf[p.Id] = b
+	}
+	return baseOf
+}
+
+//translate geopoints to ClusterPoints sorted along Hilbert curve, see Cluster.HilbertPresort,
+//returns index of the result point for each input point, result points are added to the index in their order
+func translatePresortedGeoPoints(n int, coordinatesOf func(i int) GeoCoordinates, points []GeoPoint, projection Projection, index indexBuilder, check *inputCheck) ([]*ClusterPoint, []int) {
+	xs, ys := make([]float64, 0, n), make([]float64, 0, n)
+	accepted := make([]int, 0, n)
+	baseOf := make([]int, n)
+	for i := 0; i < n; i++ {
+		coordinates := coordinatesOf(i)
+		if !check.accept(i, coordinates) {
+			baseOf[i] = -1
+			continue
+		}
+		x, y := projection.Project(coordinates)
+		xs, ys, accepted = append(xs, x), append(ys, y), append(accepted, i)
+	}
+	n = len(accepted)
+	result := make([]*ClusterPoint, n)
+	clusterPoints := make([]ClusterPoint, n)
+	ids := make([]int, n)
+	for b, k := range hilbertOrderOf(n, func(k int) (float64, float64) { return xs[k], ys[k] }) {
+		i := accepted[k]
+		ids[b] = i
+		cp := &clusterPoints[b]
+		if points != nil {
+			cp.IncludedPoints = points[i : i+1 : i+1]
+		}
+		cp.memberIDs = ids[b : b+1 : b+1]
+		cp.X, cp.Y = xs[k], ys[k]
+		index.add(cp.X, cp.Y)
+		cp.NumPoints = 1
+		cp.Id = i
+		result[b] = cp
+		baseOf[i] = b
+	}
+	return result, baseOf
+}
+
+//translate geopoints to ClusterPoints, points with the same coordinates are collapsed into one weighted point
+//returns index of the result point for each input point, result points are added to the index
+func translateDeduplicatedGeoPoints(n int, coordinatesOf func(i int) GeoCoordinates, points []GeoPoint, projection Projection, index indexBuilder, check *inputCheck) ([]*ClusterPoint, []int) {
+	var result []*ClusterPoint
+	baseOf := make([]int, n)
+	seen := make(map[GeoCoordinates]int, n)
+	for i := 0; i < n; i++ {
+		coordinates := coordinatesOf(i)
+		if !check.accept(i, coordinates) {
+			baseOf[i] = -1
+			continue
+		}
+		if b, ok := seen[coordinates]; ok {
+			check.duplicate(i, coordinates)
+			cp := result[b]
+			cp.NumPoints++
+			if points != nil {
+				cp.IncludedPoints = append(cp.IncludedPoints, points[i])
+			}
+			cp.memberIDs = append(cp.memberIDs, i)
+			baseOf[i] = b
+			continue
+		}
+		cp := &ClusterPoint{
+			memberIDs: []int{i},
+			NumPoints: 1,
+			Id:        i,
+		}
+		if points != nil {
+			cp.IncludedPoints = []GeoPoint{points[i]}
+		}
+		cp.X, cp.Y = projection.Project(coordinates)
+		index.add(cp.X, cp.Y)
+		seen[coordinates] = len(result)
+		baseOf[i] = len(result)
+		result = append(result, cp)
+	}
+	return result, baseOf
+}
+
+// numInputPoints returns number of points passed to ClusterPoints
+func (c *Cluster) numInputPoints() int {
+	if c.baseOf != nil {
+		return len(c.baseOf)
+	}
+	return len(c.basePoints)
+}
+
+// basePointOf returns index in basePoints for input point id, -1 if the point is skipped
+func (c *Cluster) basePointOf(id int) int {
+	if c.baseOf != nil {
+		return c.baseOf[id]
+	}
+	return id
+}
+
 // longitude/latitude to spherical mercator in [0..1] range
 func MercatorProjection(coordinates GeoCoordinates) (float64, float64) {
 	x := coordinates.Lon/360.0 + 0.5
-	sin := math.Sin(coordinates.Lat * math.Pi / 180.0)
-	y := (0.5 - 0.25*math.Log((1+sin)/(1-sin))/math.Pi)
+	y := mercatorY(coordinates.Lat)
 	if y < 0 {
 		y = 0
 	}
@@ -174,6 +1127,26 @@ func MercatorProjection(coordinates GeoCoordinates) (float64, float64) {
 	}
 	return x, y
 }
+
+// mercatorY returns mercator y of the latitude, it's not clamped
+func mercatorY(lat float64) float64 {
+	sin := math.Sin(lat * math.Pi / 180.0)
+	return 0.5 - 0.25*math.Log((1+sin)/(1-sin))/math.Pi
+}
+
+// mercatorScale returns scale of mercator projection at projected y, which is 1/cos(latitude)
+func mercatorScale(y float64) float64 {
+	return math.Cosh(math.Pi * (1 - 2*y))
+}
+
+// radiusAt returns radius around projected y, scaled by mercatorScale if LatitudeCorrection is set
+func (c *Cluster) radiusAt(radius, y float64) float64 {
+	if !c.LatitudeCorrection {
+		return radius
+	}
+	return radius * mercatorScale(y)
+}
+
 func ReverseMercatorProjection(x, y float64) GeoCoordinates {
 	result := GeoCoordinates{}
 	result.Lon = (x - 0.5) * 360
@@ -186,11 +1159,3 @@ func ReverseMercatorProjection(x, y float64) GeoCoordinates {
 func digitsCount(a int) int {
 	return int(math.Floor(math.Log10(math.Abs(float64(a))))) + 1
 }
-
-func clustersToPoints(points []*ClusterPoint) []kdbush.Point {
-	result := make([]kdbush.Point, len(points))
-	for i, v := range points {
-		result[i] = v
-	}
-	return result
-}
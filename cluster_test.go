@@ -0,0 +1,610 @@
+package cluster
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestReclusterWithEpsilon(t *testing.T) {
+	points := loadPlaces(t)
+	c, err := NewClusterForZoom(4, 256, 40)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.ClusterPoints(points); err != nil {
+		t.Fatal(err)
+	}
+	index := c.baseIndex
+	fresh := NewCluster(c.Epsilon * 4)
+	if err := fresh.ClusterPoints(points); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.ReclusterWithEpsilon(c.Epsilon * 4); err != nil {
+		t.Fatal(err)
+	}
+	if c.baseIndex != index {
+		t.Fatal("base index is built again")
+	}
+	checkAssignments(t, c, len(points))
+	if len(c.ResultPoints) != len(fresh.ResultPoints) || len(c.ResultPoints) == 0 {
+		t.Fatalf("%d result points, new Cluster has %d", len(c.ResultPoints), len(fresh.ResultPoints))
+	}
+	for i := range fresh.ResultPoints {
+		got, want := &c.ResultPoints[i], &fresh.ResultPoints[i]
+		if got.NumPoints != want.NumPoints || got.X != want.X || got.Y != want.Y ||
+			!reflect.DeepEqual(got.memberIDs, want.memberIDs) {
+			t.Fatalf("result point %d differs from the one of new Cluster", i)
+		}
+	}
+
+	if err := c.ReclusterWithEpsilon(-1); err == nil {
+		t.Fatal("expected error of negative epsilon")
+	}
+	if err := NewCluster(0.1).ReclusterWithEpsilon(0.2); err == nil {
+		t.Fatal("expected error of Cluster without points")
+	}
+}
+
+func TestNewClusterForZoom(t *testing.T) {
+	c, err := NewClusterForZoom(5, 512, 40)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Zoom != 5 || c.Epsilon != 40.0/(512*32) {
+		t.Fatalf("Cluster has zoom %d and epsilon %v", c.Zoom, c.Epsilon)
+	}
+	for _, args := range [][3]int{{-1, 256, 40}, {MaxZoomLevel + 1, 256, 40}, {5, 0, 40}, {5, 256, -1}} {
+		if _, err := NewClusterForZoom(args[0], args[1], args[2]); err == nil {
+			t.Errorf("expected error of zoom %d, tile size %d and marker size %d", args[0], args[1], args[2])
+		}
+	}
+}
+
+func TestDeduplicateCoordinates(t *testing.T) {
+	//every location has three points
+	unique := randomPoints(500, 8, -30, -30, 30, 30)
+	var points []GeoPoint
+	for i := 0; i < 3; i++ {
+		for _, p := range unique {
+			points = append(points, &Feature{ID: len(points), Coordinates: p.GetCoordinates()})
+		}
+	}
+	c, err := NewClusterForZoom(4, 256, 40)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.DeduplicateCoordinates = true
+	if err := c.ClusterPoints(points); err != nil {
+		t.Fatal(err)
+	}
+	if len(c.basePoints) != len(unique) {
+		t.Fatalf("%d base points of %d locations", len(c.basePoints), len(unique))
+	}
+	checkAssignments(t, c, len(points))
+	for i := range unique {
+		if a, b, d := c.assignment[i], c.assignment[i+len(unique)], c.assignment[i+2*len(unique)]; a != b || a != d {
+			t.Fatalf("points at the same location are in result points %d, %d and %d", a, b, d)
+		}
+	}
+	//duplicates weigh in the cluster center
+	for _, cp := range c.ResultPoints {
+		var x, y float64
+		for _, p := range cp.IncludedPoints {
+			px, py := MercatorProjection(p.GetCoordinates())
+			x, y = x+px, y+py
+		}
+		x, y = x/float64(cp.NumPoints), y/float64(cp.NumPoints)
+		if gx, gy := MercatorProjection(GeoCoordinates{Lon: cp.X, Lat: cp.Y}); math.Abs(gx-x) > 1e-9 || math.Abs(gy-y) > 1e-9 {
+			t.Fatalf("cluster %d is at %v,%v, mean of members is at %v,%v", cp.Id, gx, gy, x, y)
+		}
+	}
+
+	//moved point leaves its duplicates
+	if err := c.UpdatePoint(len(unique), GeoCoordinates{Lon: 100, Lat: 60}); err != nil {
+		t.Fatal(err)
+	}
+	checkAssignments(t, c, len(points))
+	if c.assignment[0] == c.assignment[len(unique)] || c.assignment[0] != c.assignment[2*len(unique)] {
+		t.Fatal("moved point is still with its duplicates")
+	}
+}
+
+func TestClustersAndSingles(t *testing.T) {
+	c, err := NewClusterForZoom(4, 256, 40)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Clusters() != nil || c.Singles() != nil {
+		t.Fatal("Cluster without points has clusters")
+	}
+	if err := c.ClusterPoints(randomPoints(1000, 9, -60, -60, 60, 60)); err != nil {
+		t.Fatal(err)
+	}
+	clusters, singles := c.Clusters(), c.Singles()
+	if len(clusters) == 0 || len(singles) == 0 || len(clusters)+len(singles) != len(c.ResultPoints) {
+		t.Fatalf("%d clusters and %d singles of %d result points", len(clusters), len(singles), len(c.ResultPoints))
+	}
+	//both keep the order of result points
+	i, j := 0, 0
+	for _, cp := range c.ResultPoints {
+		if cp.NumPoints > 1 {
+			if clusters[i].Id != cp.Id {
+				t.Fatalf("cluster %d is %d in Clusters", cp.Id, clusters[i].Id)
+			}
+			i++
+		} else {
+			if singles[j].Id != cp.Id {
+				t.Fatalf("single point %d is %d in Singles", cp.Id, singles[j].Id)
+			}
+			j++
+		}
+	}
+}
+
+func TestZoomOfCluster(t *testing.T) {
+	const n = 1000
+	points := randomPoints(n, 10, -60, -60, 60, 60)
+	for _, zoom := range []int{0, 3, 6} {
+		c, err := NewClusterForZoom(zoom, 256, 60)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := c.ClusterPoints(points); err != nil {
+			t.Fatal(err)
+		}
+		if len(c.Clusters()) == 0 {
+			t.Fatalf("zoom %d: no clusters", zoom)
+		}
+		ids := map[int]bool{}
+		for _, cp := range c.ResultPoints {
+			if ids[cp.Id] {
+				t.Fatalf("zoom %d: id %d is repeated", zoom, cp.Id)
+			}
+			ids[cp.Id] = true
+			if cp.NumPoints == 1 {
+				if c.IsCluster(cp.Id) || c.ZoomOfCluster(cp.Id) != -1 {
+					t.Fatalf("zoom %d: point %d is cluster", zoom, cp.Id)
+				}
+				continue
+			}
+			if !c.IsCluster(cp.Id) || c.ZoomOfCluster(cp.Id) != zoom {
+				t.Fatalf("zoom %d: cluster %d is of zoom %d", zoom, cp.Id, c.ZoomOfCluster(cp.Id))
+			}
+		}
+	}
+}
+
+func TestClusteringKeepsBasePoints(t *testing.T) {
+	const n = 2000
+	c, err := NewClusterForZoom(3, 256, 60)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.ClusterPoints(randomPoints(n, 11, -30, -30, 30, 30)); err != nil {
+		t.Fatal(err)
+	}
+	first := append([]ClusterPoint(nil), c.ResultPoints...)
+	//members of base points are one element slices of shared arrays, clusters never write into them
+	for i, p := range c.basePoints {
+		if len(p.memberIDs) != 1 || p.memberIDs[0] != i || len(p.IncludedPoints) != 1 || p.IncludedPoints[0].(*Feature).ID != i {
+			t.Fatalf("base point %d has members %v", i, p.memberIDs)
+		}
+	}
+	//buffers of the previous clustering don't leak into the next one
+	if err := c.ReclusterWithEpsilon(c.Epsilon); err != nil {
+		t.Fatal(err)
+	}
+	checkAssignments(t, c, n)
+	if len(first) != len(c.ResultPoints) {
+		t.Fatalf("%d result points, %d before", len(c.ResultPoints), len(first))
+	}
+	for i := range first {
+		if !reflect.DeepEqual(first[i].memberIDs, c.ResultPoints[i].memberIDs) {
+			t.Fatalf("result point %d has other members", i)
+		}
+	}
+}
+
+func TestLatitudeCorrection(t *testing.T) {
+	_, y := MercatorProjection(GeoCoordinates{Lat: 60})
+	if s := mercatorScale(y); math.Abs(s-2) > 1e-9 {
+		t.Fatalf("mercator scale at 60° is %v, want 2", s)
+	}
+
+	//pairs of points 1.5 Epsilon apart in projected coordinates, at the equator and at 60°
+	const eps = 0.001
+	gap := 1.5 * eps * 360
+	points := []GeoPoint{
+		&Feature{Coordinates: GeoCoordinates{Lon: 0, Lat: 0}},
+		&Feature{Coordinates: GeoCoordinates{Lon: gap, Lat: 0}},
+		&Feature{Coordinates: GeoCoordinates{Lon: 0, Lat: 60}},
+		&Feature{Coordinates: GeoCoordinates{Lon: gap, Lat: 60}},
+	}
+	for _, correction := range []bool{false, true} {
+		c := NewCluster(eps)
+		c.LatitudeCorrection = correction
+		if err := c.ClusterPoints(points); err != nil {
+			t.Fatal(err)
+		}
+		if c.assignment[0] == c.assignment[1] {
+			t.Fatalf("correction %v: points at the equator are clustered", correction)
+		}
+		//radius is 2 Epsilon at 60° with correction
+		if clustered := c.assignment[2] == c.assignment[3]; clustered != correction {
+			t.Fatalf("correction %v: points at 60° are clustered %v", correction, clustered)
+		}
+	}
+}
+
+func TestCentroidGeodesic(t *testing.T) {
+	//mean of mercator coordinates is biased to the pole, mean on the sphere is in the middle of the meridian arc
+	points := []GeoPoint{
+		&Feature{Coordinates: GeoCoordinates{Lon: 0, Lat: 50}},
+		&Feature{Coordinates: GeoCoordinates{Lon: 0, Lat: 70}},
+	}
+	for _, test := range []struct {
+		mode CentroidMode
+		lat  func(float64) bool
+	}{
+		{CentroidProjected, func(lat float64) bool { return lat > 61 }},
+		{CentroidGeodesic, func(lat float64) bool { return math.Abs(lat-60) < 1e-9 }},
+	} {
+		c := NewCluster(0.5)
+		c.CentroidMode = test.mode
+		if err := c.ClusterPoints(points); err != nil {
+			t.Fatal(err)
+		}
+		if len(c.ResultPoints) != 1 || !test.lat(c.ResultPoints[0].Y) || math.Abs(c.ResultPoints[0].X) > 1e-9 {
+			t.Fatalf("mode %d: cluster is at %v,%v", test.mode, c.ResultPoints[0].X, c.ResultPoints[0].Y)
+		}
+	}
+
+	//points on the opposite sides of the sphere have no mean, projected centroid is kept
+	a, b := &ClusterPoint{X: 0.25, Y: 0.5, NumPoints: 1}, &ClusterPoint{X: 0.75, Y: 0.5, NumPoints: 1}
+	if x, y := geodesicCentroid(a, []*ClusterPoint{b}, 0.5, 0.5); x != 0.5 || y != 0.5 {
+		t.Fatalf("centroid of antipodes is %v,%v", x, y)
+	}
+}
+
+func TestLabel(t *testing.T) {
+	c, err := NewClusterForZoom(3, 256, 60)
+	if err != nil {
+		t.Fatal(err)
+	}
+	calls := 0
+	c.Label = func(cp *ClusterPoint) string {
+		calls++
+		//center is in Lon/Lat already
+		if cp.X < -30 || cp.X > 30 || cp.Y < -30 || cp.Y > 30 {
+			t.Fatalf("cluster %d is labeled at %v,%v", cp.Id, cp.X, cp.Y)
+		}
+		return fmt.Sprintf("%d points", cp.NumPoints)
+	}
+	if err := c.ClusterPoints(randomPoints(1000, 35, -30, -30, 30, 30)); err != nil {
+		t.Fatal(err)
+	}
+	if calls != len(c.Clusters()) {
+		t.Fatalf("Label is called %d times for %d clusters", calls, len(c.Clusters()))
+	}
+	for _, cp := range c.ResultPoints {
+		want := ""
+		if cp.NumPoints > 1 {
+			want = fmt.Sprintf("%d points", cp.NumPoints)
+		}
+		if cp.Label != want {
+			t.Fatalf("cluster %d has label %q, want %q", cp.Id, cp.Label, want)
+		}
+	}
+
+	data, err := MarshalGeoJSONFeature(c.Clusters()[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var feature struct {
+		Properties map[string]interface{} `json:"properties"`
+	}
+	if err := json.Unmarshal(data, &feature); err != nil {
+		t.Fatal(err)
+	}
+	if feature.Properties["label"] != c.Clusters()[0].Label {
+		t.Fatalf("GeoJSON label is %v", feature.Properties["label"])
+	}
+	if restored := roundTrip(t, c); restored.Clusters()[0].Label != c.Clusters()[0].Label {
+		t.Fatalf("restored label is %q", restored.Clusters()[0].Label)
+	}
+}
+
+func TestPixelSnap(t *testing.T) {
+	c, err := NewClusterForZoom(4, 256, 40)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.PixelSnap = PixelGrid{TileSize: 256, CellPx: 8}
+	if err := c.ClusterPoints(randomPoints(1000, 36, -30, -30, 30, 30)); err != nil {
+		t.Fatal(err)
+	}
+	for _, cp := range c.ResultPoints {
+		x, y := LonLatToPixel(GeoCoordinates{Lon: cp.X, Lat: cp.Y}, 4, 256)
+		//clusters are at centers of 8 pixel cells, single points are kept as is
+		snapped := math.Abs(math.Mod(x, 8)-4) < 1e-6 && math.Abs(math.Mod(y, 8)-4) < 1e-6
+		if cp.NumPoints > 1 && !snapped {
+			t.Fatalf("cluster %d is at pixel %v,%v", cp.Id, x, y)
+		}
+		if p := cp.IncludedPoints[0].GetCoordinates(); cp.NumPoints == 1 && (math.Abs(p.Lon-cp.X) > 1e-9 || math.Abs(p.Lat-cp.Y) > 1e-9) {
+			t.Fatalf("single point %d is moved", cp.Id)
+		}
+	}
+}
+
+func TestResultOrderAndIndex(t *testing.T) {
+	const n = 1000
+	points := randomPoints(n, 37, -30, -30, 30, 30)
+	for _, strategy := range []Strategy{StrategyGreedy, StrategyMeanShift, StrategyBalanced} {
+		c, err := NewClusterForZoom(3, 256, 60)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.Strategy = strategy
+		c.Capacity = 30
+		if err := c.ClusterPoints(points); err != nil {
+			t.Fatal(err)
+		}
+		//result points are ordered by their first input point
+		last := -1
+		for i, cp := range c.AllClusters() {
+			first := n
+			for _, id := range cp.MemberIDs() {
+				first = minInt(first, id)
+				if index, ok := c.ResultIndex(id); !ok || index != i {
+					t.Fatalf("strategy %d: point %d is in result point %d, ResultIndex is %d", strategy, id, i, index)
+				}
+			}
+			if first <= last {
+				t.Fatalf("strategy %d: result point %d starts at input point %d after %d", strategy, i, first, last)
+			}
+			last = first
+		}
+		if _, ok := c.ResultIndex(n); ok {
+			t.Fatalf("strategy %d: point out of range has result point", strategy)
+		}
+	}
+}
+
+func TestSeedHilbert(t *testing.T) {
+	//points of 4x4 grid, the curve goes through neighbour cells, duplicates keep input order
+	var grid []*ClusterPoint
+	for i := 0; i < 16; i++ {
+		grid = append(grid, &ClusterPoint{X: float64(i % 4), Y: float64(i / 4)})
+	}
+	grid = append(grid, &ClusterPoint{X: 0, Y: 0})
+	order := hilbertOrder(grid)
+	seen := map[int]bool{}
+	for k, i := range order {
+		seen[i] = true
+		if k == 0 {
+			continue
+		}
+		a, b := grid[order[k-1]], grid[i]
+		if d := math.Abs(a.X-b.X) + math.Abs(a.Y-b.Y); d > 1 {
+			t.Fatalf("points %d and %d are consecutive on the curve, %v apart", order[k-1], i, d)
+		}
+	}
+	if len(seen) != len(grid) || order[0] != 0 || order[1] != 16 {
+		t.Fatalf("curve order %v", order)
+	}
+
+	points := randomPoints(3000, 38, -60, -60, 60, 60)
+	cluster := func(order SeedOrder, points []GeoPoint) *Cluster {
+		c, err := NewClusterForZoom(4, 256, 40)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.SeedOrder = order
+		if err := c.ClusterPoints(points); err != nil {
+			t.Fatal(err)
+		}
+		checkAssignments(t, c, len(points))
+		return c
+	}
+	input, hilbert := cluster(SeedInput, points), cluster(SeedHilbert, points)
+	last := -1
+	for i, cp := range hilbert.ResultPoints {
+		first := len(points)
+		for _, id := range cp.memberIDs {
+			first = minInt(first, id)
+		}
+		if first <= last {
+			t.Fatalf("result point %d starts at input point %d after %d", i, first, last)
+		}
+		last = first
+	}
+	same := len(input.ResultPoints) == len(hilbert.ResultPoints)
+	for i := 0; same && i < len(input.ResultPoints); i++ {
+		same = reflect.DeepEqual(input.ResultPoints[i].memberIDs, hilbert.ResultPoints[i].memberIDs)
+	}
+	if same {
+		t.Fatal("seeds along the curve form the same clusters as input order")
+	}
+	checkSameResult(t, hilbert, roundTrip(t, hilbert))
+
+	//visited flags of the previous clustering don't leak into the next one
+	for _, order := range []SeedOrder{SeedInput, SeedHilbert} {
+		c := cluster(order, points)
+		if err := c.ClusterPoints(points[:500]); err != nil {
+			t.Fatal(err)
+		}
+		fresh := cluster(order, points[:500])
+		if len(c.ResultPoints) != len(fresh.ResultPoints) {
+			t.Fatalf("seed order %d: %d result points of reused cluster, want %d", order, len(c.ResultPoints), len(fresh.ResultPoints))
+		}
+		for i := range fresh.ResultPoints {
+			if !reflect.DeepEqual(c.ResultPoints[i].memberIDs, fresh.ResultPoints[i].memberIDs) {
+				t.Fatalf("seed order %d: result point %d of reused cluster differs", order, i)
+			}
+		}
+	}
+}
+
+// bareGeoPoint is the point without id
+type bareGeoPoint GeoCoordinates
+
+func (p bareGeoPoint) GetCoordinates() GeoCoordinates { return GeoCoordinates(p) }
+
+func TestSourceIDs(t *testing.T) {
+	at := GeoCoordinates{Lon: 10, Lat: 10}
+	points := []GeoPoint{
+		&Feature{ID: "a", Coordinates: at},
+		bareGeoPoint(at),
+		&Feature{Coordinates: at},
+		&Feature{ID: int64(7), Coordinates: at},
+		&Feature{ID: "single", Coordinates: GeoCoordinates{Lon: -10, Lat: -10}},
+	}
+	c, err := NewClusterForZoom(3, 256, 60)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.ClusterPoints(points); err != nil {
+		t.Fatal(err)
+	}
+	if len(c.ResultPoints) != 2 {
+		t.Fatalf("%d result points, want 2", len(c.ResultPoints))
+	}
+	//points without id are reported by input index
+	if ids := c.ResultPoints[0].SourceIDs(); !reflect.DeepEqual(ids, []interface{}{"a", 1, 2, int64(7)}) {
+		t.Fatalf("cluster source ids %v", ids)
+	}
+	data, err := MarshalGeoJSONFeature(c.ResultPoints[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var single struct{ ID interface{} }
+	if err := json.Unmarshal(data, &single); err != nil {
+		t.Fatal(err)
+	}
+	if single.ID != "single" {
+		t.Fatalf("single point feature id %v", single.ID)
+	}
+}
+
+func TestSeedDensity(t *testing.T) {
+	//chain of points 1 degree apart on the equator, middle points have more neighbours and take ends into their clusters
+	var chain []GeoPoint
+	for lon := 0; lon < 5; lon++ {
+		chain = append(chain, &Feature{Coordinates: onEquator(float64(lon))})
+	}
+	members := func(order SeedOrder) [][]int {
+		c := NewCluster(1.5 / 360)
+		c.SeedOrder = order
+		if err := c.ClusterPoints(chain); err != nil {
+			t.Fatal(err)
+		}
+		checkAssignments(t, c, len(chain))
+		var result [][]int
+		for _, cp := range c.ResultPoints {
+			result = append(result, cp.memberIDs)
+		}
+		return result
+	}
+	if got, want := members(SeedInput), [][]int{{0, 1}, {2, 3}, {4}}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("input order clusters %v, want %v", got, want)
+	}
+	if got, want := members(SeedDensity), [][]int{{1, 0, 2}, {3, 4}}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("density order clusters %v, want %v", got, want)
+	}
+}
+
+func TestReproducibleOutput(t *testing.T) {
+	points := randomPoints(3000, 48, -60, -60, 60, 60)
+	var first *Cluster
+	//the same options twice and other layouts of the index
+	for _, nodeSize := range []int{64, 64, 4, 500} {
+		c, err := NewClusterForZoom(4, 256, 40)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.NodeSize = nodeSize
+		if err := c.ClusterPoints(points); err != nil {
+			t.Fatal(err)
+		}
+		if first == nil {
+			first = c
+			continue
+		}
+		if len(c.ResultPoints) != len(first.ResultPoints) {
+			t.Fatalf("node size %d: %d result points, want %d", nodeSize, len(c.ResultPoints), len(first.ResultPoints))
+		}
+		for i := range first.ResultPoints {
+			want, got := &first.ResultPoints[i], &c.ResultPoints[i]
+			if got.Id != want.Id || got.X != want.X || got.Y != want.Y || !reflect.DeepEqual(got.memberIDs, want.memberIDs) {
+				t.Fatalf("node size %d: result point %d differs", nodeSize, i)
+			}
+		}
+	}
+}
+
+func TestHilbertPresort(t *testing.T) {
+	points := randomPoints(3000, 56, -60, -60, 60, 60)
+	newCluster := func() *Cluster {
+		c, err := NewClusterForZoom(4, 256, 40)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+	presorted := newCluster()
+	presorted.HilbertPresort = true
+	if err := presorted.ClusterPoints(points); err != nil {
+		t.Fatal(err)
+	}
+	checkAssignments(t, presorted, len(points))
+
+	//clusters are the same as of input sorted along the curve, members are known by input indexes
+	projected := make([]*ClusterPoint, len(points))
+	for i, p := range points {
+		x, y := MercatorProjection(p.GetCoordinates())
+		projected[i] = &ClusterPoint{X: x, Y: y}
+	}
+	order := hilbertOrder(projected)
+	sorted := make([]GeoPoint, len(points))
+	for i, o := range order {
+		sorted[i] = points[o]
+	}
+	want := newCluster()
+	if err := want.ClusterPoints(sorted); err != nil {
+		t.Fatal(err)
+	}
+	if len(presorted.ResultPoints) != len(want.ResultPoints) {
+		t.Fatalf("%d presorted result points, want %d", len(presorted.ResultPoints), len(want.ResultPoints))
+	}
+	for i := range want.ResultPoints {
+		got, w := &presorted.ResultPoints[i], &want.ResultPoints[i]
+		if got.NumPoints != w.NumPoints || got.X != w.X || got.Y != w.Y {
+			t.Fatalf("presorted result point %d differs", i)
+		}
+		for k, id := range w.memberIDs {
+			if got.memberIDs[k] != order[id] || got.IncludedPoints[k] != w.IncludedPoints[k] {
+				t.Fatalf("presorted result point %d has member %d, want %d", i, got.memberIDs[k], order[id])
+			}
+		}
+	}
+	if restored := roundTrip(t, presorted); !restored.HilbertPresort {
+		t.Fatal("restored cluster is not presorted")
+	}
+
+	//duplicates are merged before points are sorted
+	duplicates := append(append([]GeoPoint(nil), points...), points[:500]...)
+	deduplicated := newCluster()
+	deduplicated.HilbertPresort = true
+	deduplicated.DeduplicateCoordinates = true
+	if err := deduplicated.ClusterPoints(duplicates); err != nil {
+		t.Fatal(err)
+	}
+	checkAssignments(t, deduplicated, len(duplicates))
+	if len(deduplicated.basePoints) != len(points) {
+		t.Fatalf("%d base points of %d distinct coordinates", len(deduplicated.basePoints), len(points))
+	}
+}
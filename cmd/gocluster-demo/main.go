@@ -0,0 +1,244 @@
+// Command gocluster-demo serves the map of clusters of GeoJSON or FlatGeobuf file, to tune clustering parameters
+// before production use. The page is built into the command, radius and min points sliders cluster points again live.
+//
+// Usage:
+//
+//	gocluster-demo -in places.geojson
+//	gocluster-demo -in places.fgb -addr :9000 -radius 60 -min-points 5
+//
+// and open http://localhost:8080. The page loads Leaflet and OpenStreetMap tiles from the internet.
+//
+// Clusters are built for each zoom, radius and min points on the first request and kept for the next ones,
+// the map shows how long the build took, so the cost of parameters on the dataset is seen too.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	cluster "github.com/iahmedov/gocluster"
+)
+
+type options struct {
+	in        string
+	addr      string
+	tileSize  int
+	radius    int
+	minPoints int
+	levels    int
+}
+
+func main() {
+	var o options
+	flag.StringVar(&o.in, "in", "", "input GeoJSON or FlatGeobuf file, detected by file extension")
+	flag.StringVar(&o.addr, "addr", ":8080", "address to listen on")
+	flag.IntVar(&o.tileSize, "tile-size", 256, "tile size in pixels of the map, radius is relative to it")
+	flag.IntVar(&o.radius, "radius", 40, "initial cluster radius in pixels")
+	flag.IntVar(&o.minPoints, "min-points", 2, "initial minimum number of points to form a cluster")
+	flag.IntVar(&o.levels, "levels", 64, "number of clustered zoom levels kept for the next requests")
+	flag.Parse()
+
+	if err := run(o); err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
+
+func run(o options) error {
+	if o.in == "" {
+		return fmt.Errorf("input file should be set with -in")
+	}
+	points, err := readPoints(o.in)
+	if err != nil {
+		return err
+	}
+	s := newServer(points, o)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", s.servePage)
+	mux.HandleFunc("/info", s.serveInfo)
+	mux.HandleFunc("/clusters", s.serveClusters)
+	mux.HandleFunc("/expand", s.serveExpand)
+	log.Printf("serving %d points of %s on %s", len(points), o.in, o.addr)
+	return http.ListenAndServe(o.addr, mux)
+}
+
+func readPoints(path string) ([]cluster.GeoPoint, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if strings.ToLower(filepath.Ext(path)) == ".fgb" {
+		return cluster.LoadFlatGeobuf(f)
+	}
+	return cluster.LoadGeoJSON(f)
+}
+
+// levelKey is the zoom and parameters of clustered level
+type levelKey struct {
+	zoom, radius, minPoints int
+}
+
+// level is Cluster of levelKey, it's built once by the first request
+type level struct {
+	once    sync.Once
+	c       *cluster.Cluster
+	err     error
+	elapsed time.Duration
+}
+
+type server struct {
+	points []cluster.GeoPoint
+	o      options
+	bounds [4]float64 //west, south, east and north of points
+
+	mu     sync.Mutex
+	levels map[levelKey]*level
+	order  []levelKey //keys of levels, the oldest first
+}
+
+func newServer(points []cluster.GeoPoint, o options) *server {
+	s := &server{points: points, o: o, levels: map[levelKey]*level{}}
+	west, south, east, north := math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)
+	for _, p := range points {
+		c := p.GetCoordinates()
+		west, east = math.Min(west, c.Lon), math.Max(east, c.Lon)
+		south, north = math.Min(south, c.Lat), math.Max(north, c.Lat)
+	}
+	if west > east {
+		west, south, east, north = -180, -85, 180, 85
+	}
+	s.bounds = [4]float64{west, south, east, north}
+	return s
+}
+
+// level returns Cluster of the key, the oldest levels are dropped when there are more than o.levels of them
+func (s *server) level(key levelKey) *level {
+	s.mu.Lock()
+	l, ok := s.levels[key]
+	if !ok {
+		l = &level{}
+		s.levels[key] = l
+		s.order = append(s.order, key)
+		if len(s.order) > s.o.levels {
+			delete(s.levels, s.order[0])
+			s.order = s.order[1:]
+		}
+	}
+	s.mu.Unlock()
+
+	//levels are built outside of the lock, so requests of other levels don't wait
+	l.once.Do(func() {
+		start := time.Now()
+		l.c, l.err = cluster.NewClusterForZoom(key.zoom, s.o.tileSize, key.radius)
+		if l.err != nil {
+			return
+		}
+		l.c.MinPoints = key.minPoints
+		l.err = l.c.ClusterPoints(s.points)
+		l.elapsed = time.Since(start)
+	})
+	return l
+}
+
+// parseLevelKey parses zoom, radius and min_points parameters of the request
+func parseLevelKey(r *http.Request) (levelKey, error) {
+	query := r.URL.Query()
+	var key levelKey
+	var err error
+	if key.zoom, err = strconv.Atoi(query.Get("zoom")); err != nil || key.zoom < 0 || key.zoom > 21 {
+		return key, fmt.Errorf("invalid zoom %q", query.Get("zoom"))
+	}
+	if key.radius, err = strconv.Atoi(query.Get("radius")); err != nil || key.radius <= 0 {
+		return key, fmt.Errorf("invalid radius %q", query.Get("radius"))
+	}
+	if key.minPoints, err = strconv.Atoi(query.Get("min_points")); err != nil || key.minPoints < 1 {
+		return key, fmt.Errorf("invalid min_points %q", query.Get("min_points"))
+	}
+	return key, nil
+}
+
+func (s *server) servePage(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	io.WriteString(w, page)
+}
+
+// serveInfo answers with the number of points, their bounds and initial parameters
+func (s *server) serveInfo(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, map[string]interface{}{
+		"points":     len(s.points),
+		"bounds":     s.bounds,
+		"radius":     s.o.radius,
+		"min_points": s.o.minPoints,
+	})
+}
+
+// serveClusters answers bbox queries of ClustersHandler for clusters of the zoom, radius and min_points,
+// X-Build-Time and X-Result-Points headers describe the whole level
+func (s *server) serveClusters(w http.ResponseWriter, r *http.Request) {
+	key, err := parseLevelKey(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	l := s.level(key)
+	if l.err != nil {
+		http.Error(w, l.err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("X-Build-Time", strconv.FormatFloat(l.elapsed.Seconds()*1000, 'f', 1, 64))
+	w.Header().Set("X-Result-Points", strconv.Itoa(len(l.c.ResultPoints)))
+	h := &cluster.ClustersHandler{Cluster: func(zoom int) (*cluster.Cluster, bool) { return l.c, zoom == key.zoom }}
+	h.ServeHTTP(w, r)
+}
+
+// serveExpand answers with the box of members of the cluster id, so the map zooms into it on click
+func (s *server) serveExpand(w http.ResponseWriter, r *http.Request) {
+	key, err := parseLevelKey(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	id, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+	l := s.level(key)
+	if l.err != nil {
+		http.Error(w, l.err.Error(), http.StatusInternalServerError)
+		return
+	}
+	northWest, southEast, ok := l.c.ExpansionBounds(id, 0.1)
+	if !ok {
+		http.Error(w, fmt.Sprintf("cluster %d is not found", id), http.StatusNotFound)
+		return
+	}
+	writeJSON(w, map[string]interface{}{"bbox": [4]float64{northWest.Lon, southEast.Lat, southEast.Lon, northWest.Lat}})
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
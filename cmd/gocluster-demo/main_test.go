@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	cluster "github.com/iahmedov/gocluster"
+)
+
+// testServer returns server of places of testdata, keeping 2 levels
+func testServer(t *testing.T) *server {
+	points, err := readPoints("../../testdata/places.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return newServer(points, options{tileSize: 256, radius: 40, minPoints: 2, levels: 2})
+}
+
+// get answers GET request of url by handler, expecting status want
+func get(t *testing.T, handler http.HandlerFunc, url string, want int) *httptest.ResponseRecorder {
+	t.Helper()
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest("GET", url, nil))
+	if w.Code != want {
+		t.Fatalf("%s answered %d, want %d: %s", url, w.Code, want, w.Body)
+	}
+	return w
+}
+
+func TestServer(t *testing.T) {
+	s := testServer(t)
+	var info struct {
+		Points int        `json:"points"`
+		Bounds [4]float64 `json:"bounds"`
+		Radius int        `json:"radius"`
+	}
+	if err := json.Unmarshal(get(t, s.serveInfo, "/info", http.StatusOK).Body.Bytes(), &info); err != nil {
+		t.Fatal(err)
+	}
+	if info.Points != len(s.points) || info.Radius != 40 || info.Bounds[0] >= info.Bounds[2] || info.Bounds[1] >= info.Bounds[3] {
+		t.Fatalf("info is %+v", info)
+	}
+
+	w := get(t, s.serveClusters, "/clusters?bbox=-180,-85,180,85&zoom=2&radius=60&min_points=2", http.StatusOK)
+	var clusters struct {
+		Features []struct {
+			Properties map[string]interface{} `json:"properties"`
+		} `json:"features"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &clusters); err != nil {
+		t.Fatal(err)
+	}
+	l := s.level(levelKey{zoom: 2, radius: 60, minPoints: 2})
+	if w.Header().Get("X-Result-Points") != strconv.Itoa(len(l.c.ResultPoints)) || w.Header().Get("X-Build-Time") == "" {
+		t.Fatalf("headers of clusters are %v", w.Header())
+	}
+	if len(clusters.Features) == 0 || len(clusters.Features) > len(l.c.ResultPoints) {
+		t.Fatalf("%d features of %d result points", len(clusters.Features), len(l.c.ResultPoints))
+	}
+
+	//the cluster expands into the box of its members
+	var expand struct {
+		BBox [4]float64 `json:"bbox"`
+	}
+	id := -1
+	for _, cp := range l.c.ResultPoints {
+		if cp.NumPoints > 1 {
+			id = cp.Id
+			break
+		}
+	}
+	url := "/expand?zoom=2&radius=60&min_points=2&id=" + strconv.Itoa(id)
+	if err := json.Unmarshal(get(t, s.serveExpand, url, http.StatusOK).Body.Bytes(), &expand); err != nil {
+		t.Fatal(err)
+	}
+	if expand.BBox[0] >= expand.BBox[2] || expand.BBox[1] >= expand.BBox[3] {
+		t.Fatalf("cluster %d expands into %v", id, expand.BBox)
+	}
+	get(t, s.serveExpand, "/expand?zoom=2&radius=60&min_points=2&id=-5", http.StatusNotFound)
+
+	for _, url := range []string{
+		"/clusters?bbox=-180,-85,180,85&zoom=30&radius=60&min_points=2",
+		"/clusters?bbox=-180,-85,180,85&zoom=2&radius=0&min_points=2",
+		"/clusters?bbox=-180,-85,180,85&zoom=2&radius=60",
+	} {
+		get(t, s.serveClusters, url, http.StatusBadRequest)
+	}
+	get(t, s.servePage, "/", http.StatusOK)
+	get(t, s.servePage, "/missing", http.StatusNotFound)
+}
+
+func TestServerLevels(t *testing.T) {
+	s := testServer(t)
+	keys := []levelKey{{zoom: 1, radius: 40, minPoints: 2}, {zoom: 2, radius: 40, minPoints: 2}, {zoom: 3, radius: 40, minPoints: 2}}
+	first := s.level(keys[0])
+	if s.level(keys[0]) != first || first.c == nil || first.err != nil {
+		t.Fatal("level is not kept for the next request")
+	}
+	if first.c.MinPoints != 2 || totalPoints(first.c) != len(s.points) {
+		t.Fatalf("level of %d points has MinPoints %d", totalPoints(first.c), first.c.MinPoints)
+	}
+	//the oldest level is dropped beyond the limit
+	s.level(keys[1])
+	s.level(keys[2])
+	if len(s.levels) != 2 || s.levels[keys[0]] != nil {
+		t.Fatalf("%d levels are kept, the oldest one %v", len(s.levels), s.levels[keys[0]] != nil)
+	}
+	if s.level(keys[0]) == first {
+		t.Fatal("dropped level is not built again")
+	}
+}
+
+// totalPoints returns the number of points in result points of c
+func totalPoints(c *cluster.Cluster) int {
+	total := 0
+	for _, cp := range c.ResultPoints {
+		total += cp.NumPoints
+	}
+	return total
+}
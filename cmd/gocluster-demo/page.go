@@ -0,0 +1,127 @@
+package main
+
+// page is the map of clusters, it queries /clusters for the viewport each time the map or parameters change
+const page = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>gocluster demo</title>
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<link rel="stylesheet" href="https://unpkg.com/leaflet@1.9.4/dist/leaflet.css">
+<script src="https://unpkg.com/leaflet@1.9.4/dist/leaflet.js"></script>
+<style>
+html, body, #map { height: 100%; margin: 0; }
+#panel {
+	position: absolute; top: 10px; right: 10px; z-index: 1000; width: 240px; padding: 10px 12px;
+	background: rgba(255, 255, 255, 0.92); border-radius: 6px; box-shadow: 0 1px 5px rgba(0, 0, 0, 0.4);
+	font: 13px/1.5 sans-serif;
+}
+#panel label { display: block; margin-top: 6px; }
+#panel input { width: 100%; }
+#stats { margin-top: 8px; color: #444; }
+.cluster {
+	display: flex; align-items: center; justify-content: center; border-radius: 50%;
+	background: rgba(49, 130, 189, 0.75); border: 2px solid rgba(255, 255, 255, 0.9);
+	color: #fff; font: bold 12px sans-serif; box-sizing: border-box; cursor: pointer;
+}
+</style>
+</head>
+<body>
+<div id="map"></div>
+<div id="panel">
+	<b>gocluster</b>
+	<label>radius <span id="radius-value"></span> px
+		<input id="radius" type="range" min="5" max="200" step="5"></label>
+	<label>min points <span id="min-points-value"></span>
+		<input id="min-points" type="range" min="1" max="100" step="1"></label>
+	<div id="stats"></div>
+</div>
+<script>
+const map = L.map('map');
+L.tileLayer('https://{s}.tile.openstreetmap.org/{z}/{x}/{y}.png', {
+	maxZoom: 21, maxNativeZoom: 19, attribution: '&copy; OpenStreetMap contributors'
+}).addTo(map);
+const markers = L.layerGroup().addTo(map);
+const radius = document.getElementById('radius');
+const minPoints = document.getElementById('min-points');
+const stats = document.getElementById('stats');
+let total = 0;
+let request = 0;
+
+function parameters() {
+	return 'zoom=' + Math.round(map.getZoom()) + '&radius=' + radius.value + '&min_points=' + minPoints.value;
+}
+
+function clamp(v, min, max) {
+	return Math.max(min, Math.min(max, v));
+}
+
+function clusterIcon(count, abbreviated) {
+	const size = Math.round(24 + 8 * Math.log10(count));
+	return L.divIcon({
+		html: abbreviated, className: 'cluster', iconSize: [size, size]
+	});
+}
+
+function pointToLayer(feature, latlng) {
+	const p = feature.properties;
+	if (!p.cluster) {
+		return L.circleMarker(latlng, {radius: 5, color: '#fff', weight: 1, fillColor: '#e6550d', fillOpacity: 0.9});
+	}
+	const marker = L.marker(latlng, {icon: clusterIcon(p.point_count, p.point_count_abbreviated)});
+	marker.on('click', () => {
+		fetch('/expand?' + parameters() + '&id=' + p.cluster_id)
+			.then(r => r.ok ? r.json() : Promise.reject(r.statusText))
+			.then(e => map.fitBounds([[e.bbox[1], e.bbox[0]], [e.bbox[3], e.bbox[2]]]))
+			.catch(err => console.error(err));
+	});
+	return marker;
+}
+
+function update() {
+	document.getElementById('radius-value').textContent = radius.value;
+	document.getElementById('min-points-value').textContent = minPoints.value;
+	const b = map.getBounds();
+	const bbox = [clamp(b.getWest(), -180, 180), clamp(b.getSouth(), -85, 85),
+		clamp(b.getEast(), -180, 180), clamp(b.getNorth(), -85, 85)].join(',');
+	const current = ++request;
+	fetch('/clusters?' + parameters() + '&bbox=' + bbox).then(r => {
+		if (!r.ok) {
+			return r.text().then(text => Promise.reject(text));
+		}
+		return r.json().then(collection => ({
+			collection: collection,
+			buildTime: r.headers.get('X-Build-Time'),
+			resultPoints: r.headers.get('X-Result-Points')
+		}));
+	}).then(result => {
+		//responses of older viewports and parameters are dropped
+		if (current !== request) {
+			return;
+		}
+		markers.clearLayers();
+		L.geoJSON(result.collection, {pointToLayer: pointToLayer}).addTo(markers);
+		const clusters = result.collection.features.filter(f => f.properties.cluster).length;
+		stats.innerHTML = total + ' points<br>' + result.resultPoints + ' markers at zoom ' + Math.round(map.getZoom()) +
+			'<br>' + clusters + ' clusters and ' + (result.collection.features.length - clusters) + ' points in view' +
+			'<br>built in ' + result.buildTime + ' ms';
+	}).catch(err => {
+		stats.textContent = 'error: ' + err;
+	});
+}
+
+fetch('/info').then(r => r.json()).then(info => {
+	total = info.points;
+	radius.value = info.radius;
+	minPoints.value = info.min_points;
+	const b = info.bounds;
+	map.fitBounds([[b[1], b[0]], [b[3], b[2]]]);
+	map.on('moveend', update);
+	radius.addEventListener('input', update);
+	minPoints.addEventListener('input', update);
+	update();
+});
+</script>
+</body>
+</html>
+`
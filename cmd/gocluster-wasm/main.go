@@ -0,0 +1,40 @@
+//go:build js && wasm
+// +build js,wasm
+
+// Command gocluster-wasm exposes jscluster to JavaScript as global goclusterCluster function.
+//
+// Build it with:
+//
+//	GOOS=js GOARCH=wasm go build -o gocluster.wasm ./cmd/gocluster-wasm
+//
+// and run with wasm_exec.js of your Go distribution:
+//
+//	const {result, error} = goclusterCluster(geojsonString, JSON.stringify({zoom: 4, radius: 40}))
+//
+// result is GeoJSON FeatureCollection string of clusters, error is the message if clustering failed.
+package main
+
+import (
+	"syscall/js"
+
+	"github.com/iahmedov/gocluster/jscluster"
+)
+
+func main() {
+	js.Global().Set("goclusterCluster", js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		if len(args) < 1 {
+			return map[string]interface{}{"error": "geojson argument is required"}
+		}
+		var options []byte
+		if len(args) > 1 && args[1].Type() == js.TypeString {
+			options = []byte(args[1].String())
+		}
+		data, err := jscluster.Cluster([]byte(args[0].String()), options)
+		if err != nil {
+			return map[string]interface{}{"error": err.Error()}
+		}
+		return map[string]interface{}{"result": string(data)}
+	}))
+	//keep the program running, so the function stays available
+	select {}
+}
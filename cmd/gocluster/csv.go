@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	cluster "github.com/iahmedov/gocluster"
+)
+
+var (
+	lonColumns = []string{"lon", "lng", "long", "longitude", "x"}
+	latColumns = []string{"lat", "latitude", "y"}
+)
+
+// readCSV reads points from CSV with header, all columns except coordinates become properties
+func readCSV(r io.Reader, lonColumn, latColumn string) ([]cluster.GeoPoint, error) {
+	cr := csv.NewReader(r)
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("can't read csv header: %v", err)
+	}
+	lonIdx := findColumn(header, lonColumn, lonColumns)
+	latIdx := findColumn(header, latColumn, latColumns)
+	if lonIdx < 0 || latIdx < 0 {
+		return nil, fmt.Errorf("can't find longitude and latitude columns in csv header, use -lon-column and -lat-column")
+	}
+
+	var result []cluster.GeoPoint
+	for line := 2; ; line++ {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		lon, err := strconv.ParseFloat(strings.TrimSpace(record[lonIdx]), 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid longitude: %v", line, err)
+		}
+		lat, err := strconv.ParseFloat(strings.TrimSpace(record[latIdx]), 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid latitude: %v", line, err)
+		}
+
+		properties := make(map[string]interface{}, len(header))
+		for i, name := range header {
+			if i != lonIdx && i != latIdx && i < len(record) {
+				properties[name] = record[i]
+			}
+		}
+		result = append(result, &cluster.Feature{
+			ID:          len(result),
+			Coordinates: cluster.GeoCoordinates{Lon: lon, Lat: lat},
+			Properties:  properties,
+		})
+	}
+	return result, nil
+}
+
+func findColumn(header []string, name string, candidates []string) int {
+	if name != "" {
+		candidates = []string{name}
+	}
+	for _, c := range candidates {
+		for i, h := range header {
+			if strings.EqualFold(strings.TrimSpace(h), c) {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// writeCSV writes one row for each cluster or single point
+func writeCSV(w io.Writer, points []cluster.ClusterPoint) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"id", "lon", "lat", "num_points"}); err != nil {
+		return err
+	}
+	for _, p := range points {
+		err := cw.Write([]string{
+			strconv.Itoa(p.Id),
+			strconv.FormatFloat(p.X, 'f', -1, 64),
+			strconv.FormatFloat(p.Y, 'f', -1, 64),
+			strconv.Itoa(p.NumPoints),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,246 @@
+// Command gocluster clusters points from GeoJSON, FlatGeobuf or CSV file.
+// Output is GeoJSON, newline delimited GeoJSON, Geobuf, FlatGeobuf, CSV or directory of MVT tiles,
+// or cluster of each input point as CSV or Arrow IPC stream.
+//
+// Usage:
+//
+//	gocluster -in places.geojson -zoom 4 -radius 40 -format geojson > clusters.geojson
+//	gocluster -in places.geojson -zoom 4 -precision 5 > clusters.geojson
+//	gocluster -in places.csv -zoom 10 -format mvt -out tiles/
+//	gocluster -in places.csv -zoom 10 -format mvt -layer clusters,unclustered-points -out tiles/
+//	gocluster -in places.csv -zoom 10 -format assignments-arrow -out labels.arrow
+//
+// Epsilon is derived from zoom, radius and tile size the same way as map renderers do it:
+// radius / (tileSize * 2^zoom).
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	cluster "github.com/iahmedov/gocluster"
+)
+
+type options struct {
+	in          string
+	inputFormat string
+	out         string
+	format      string
+	zoom        int
+	radius      int
+	tileSize    int
+	minPoints   int
+	lonColumn   string
+	latColumn   string
+	layer       string
+	precision   int
+}
+
+func main() {
+	var o options
+	flag.StringVar(&o.in, "in", "-", "input file, - for stdin")
+	flag.StringVar(&o.inputFormat, "input-format", "", "input format: geojson, fgb or csv, detected by file extension by default")
+	flag.StringVar(&o.out, "out", "-", "output file, - for stdout, output directory for mvt format")
+	flag.StringVar(&o.format, "format", "geojson", "output format: geojson, ndjson, geobuf, fgb, csv, mvt, assignments-csv or assignments-arrow")
+	flag.IntVar(&o.zoom, "zoom", 0, "zoom level to cluster for, 0..21")
+	flag.IntVar(&o.radius, "radius", 40, "cluster radius in pixels")
+	flag.IntVar(&o.tileSize, "tile-size", 512, "tile size in pixels, radius is relative to it")
+	flag.IntVar(&o.minPoints, "min-points", 2, "minimum number of points to form a cluster")
+	flag.StringVar(&o.lonColumn, "lon-column", "", "csv column with longitude, detected by header by default")
+	flag.StringVar(&o.latColumn, "lat-column", "", "csv column with latitude, detected by header by default")
+	flag.StringVar(&o.layer, "layer", "clusters", "mvt layer or fgb dataset name, mvt layers of clusters and single points if names are separated by comma")
+	flag.IntVar(&o.precision, "precision", 0, "decimal digits of output coordinates and numeric properties, full precision (6 for geobuf) if it's 0")
+	flag.Parse()
+
+	if err := run(o); err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
+
+func run(o options) error {
+	c, err := cluster.NewClusterForZoom(o.zoom, o.tileSize, o.radius)
+	if err != nil {
+		return err
+	}
+
+	points, err := readPoints(o)
+	if err != nil {
+		return err
+	}
+
+	c.MinPoints = o.minPoints
+	if o.format == "ndjson" {
+		//features are written as soon as clusters are finalized
+		return writeOutput(o.out, func(w io.Writer) error {
+			bw := bufio.NewWriter(w)
+			var err error
+			for p := range c.ClusterPointsStream(points) {
+				//the stream is drained after error, so clustering goroutine finishes
+				if err != nil {
+					continue
+				}
+				var data []byte
+				if data, err = marshalFeature(p, o.precision); err == nil {
+					bw.Write(data)
+					err = bw.WriteByte('\n')
+				}
+			}
+			if err == nil {
+				err = c.StreamErr()
+			}
+			if err != nil {
+				return err
+			}
+			return bw.Flush()
+		})
+	}
+	if err := c.ClusterPoints(points); err != nil {
+		return err
+	}
+	result := c.AllClusters()
+
+	switch o.format {
+	case "geojson":
+		data, err := marshalGeoJSON(result, o.precision)
+		if err != nil {
+			return err
+		}
+		return writeOutput(o.out, func(w io.Writer) error {
+			_, err := w.Write(data)
+			return err
+		})
+	case "geobuf":
+		precision := cluster.GeobufPrecision
+		if o.precision > 0 {
+			precision = o.precision
+		}
+		data, err := cluster.EncodeGeobuf(result, precision)
+		if err != nil {
+			return err
+		}
+		return writeOutput(o.out, func(w io.Writer) error {
+			_, err := w.Write(data)
+			return err
+		})
+	case "fgb":
+		return writeOutput(o.out, func(w io.Writer) error {
+			return cluster.WriteFlatGeobuf(w, result, o.layer)
+		})
+	case "csv":
+		return writeOutput(o.out, func(w io.Writer) error {
+			return writeCSV(w, result)
+		})
+	case "assignments-csv":
+		return writeOutput(o.out, c.WriteAssignmentsCSV)
+	case "assignments-arrow":
+		return writeOutput(o.out, c.WriteAssignmentsArrow)
+	case "mvt":
+		if o.out == "-" {
+			return fmt.Errorf("mvt format requires output directory")
+		}
+		return writeMVT(o.out, o.zoom, o.layer, o.precision, result)
+	default:
+		return fmt.Errorf("unknown output format %q", o.format)
+	}
+}
+
+// marshalGeoJSON encodes points rounded to precision, full precision if it's 0
+func marshalGeoJSON(points []cluster.ClusterPoint, precision int) ([]byte, error) {
+	if precision > 0 {
+		return cluster.MarshalGeoJSONPrecision(points, precision)
+	}
+	return cluster.MarshalGeoJSON(points)
+}
+
+// marshalFeature encodes the point of ndjson output rounded to precision, full precision if it's 0
+func marshalFeature(p cluster.ClusterPoint, precision int) ([]byte, error) {
+	if precision > 0 {
+		return cluster.MarshalGeoJSONFeaturePrecision(p, precision)
+	}
+	return cluster.MarshalGeoJSONFeature(p)
+}
+
+func readPoints(o options) ([]cluster.GeoPoint, error) {
+	format := o.inputFormat
+	if format == "" {
+		switch strings.ToLower(filepath.Ext(o.in)) {
+		case ".csv":
+			format = "csv"
+		case ".fgb":
+			format = "fgb"
+		default:
+			format = "geojson"
+		}
+	}
+
+	var r io.Reader = os.Stdin
+	if o.in != "-" {
+		f, err := os.Open(o.in)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	switch format {
+	case "geojson":
+		return cluster.LoadGeoJSON(r)
+	case "fgb":
+		return cluster.LoadFlatGeobuf(r)
+	case "csv":
+		return readCSV(r, o.lonColumn, o.latColumn)
+	default:
+		return nil, fmt.Errorf("unknown input format %q", format)
+	}
+}
+
+func writeOutput(path string, write func(w io.Writer) error) error {
+	if path == "-" {
+		return write(os.Stdout)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// writeMVT writes one tile file for each non empty tile at zoom: dir/z/x/y.mvt
+func writeMVT(dir string, zoom int, layer string, precision int, points []cluster.ClusterPoint) error {
+	layers := []cluster.MVTLayer{{Name: layer}}
+	if names := strings.Split(layer, ","); len(names) == 2 {
+		layers = cluster.ClusterLayers(names[0], names[1])
+	}
+	for i := range layers {
+		layers[i].Precision = precision
+	}
+	tiles := map[cluster.Tile][]cluster.ClusterPoint{}
+	for _, p := range points {
+		t := cluster.LonLatToTile(cluster.GeoCoordinates{Lon: p.X, Lat: p.Y}, zoom)
+		tiles[t] = append(tiles[t], p)
+	}
+
+	for t, tilePoints := range tiles {
+		tileDir := filepath.Join(dir, fmt.Sprint(t.Z), fmt.Sprint(t.X))
+		if err := os.MkdirAll(tileDir, 0755); err != nil {
+			return err
+		}
+		data := cluster.EncodeMVTLayers(tilePoints, t, layers)
+		if err := ioutil.WriteFile(filepath.Join(tileDir, fmt.Sprintf("%d.mvt", t.Y)), data, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
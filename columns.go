@@ -0,0 +1,104 @@
+package cluster
+
+import (
+	"fmt"
+	"math"
+)
+
+// Float64Column is the column of float64 values, *array.Float64 of Apache Arrow satisfies it
+type Float64Column interface {
+	Len() int
+	Value(i int) float64
+	IsNull(i int) bool
+}
+
+// Float64Values is Float64Column of plain slice without nulls
+type Float64Values []float64
+
+// Len implements Float64Column interface
+func (v Float64Values) Len() int { return len(v) }
+
+// Value implements Float64Column interface
+func (v Float64Values) Value(i int) float64 { return v[i] }
+
+// IsNull implements Float64Column interface
+func (v Float64Values) IsNull(i int) bool { return false }
+
+// ColumnBatch is the record batch of points with longitude and latitude columns
+// Properties are numeric columns, their stats are stored in ClusterPoint.Stats by column name
+type ColumnBatch struct {
+	Lon        Float64Column
+	Lat        Float64Column
+	Properties map[string]Float64Column
+}
+
+// ClusterColumns clusters rows of record batches, as Arrow or Parquet readers return them, without GeoPoint for each row
+// Coordinates are projected directly from the columns, id of the point is the row number counting all batches.
+// IncludedPoints of the result are empty, members of clusters are known by ids only.
+// Batches should have the same property columns, null coordinates are reported as error, null properties are skipped.
+// Arrow IPC streams are read to batches by ReadArrowColumns, Parquet files by Arrow Parquet readers, e.g. pqarrow.
+func (c *Cluster) ClusterColumns(batches ...ColumnBatch) error {
+	var lon, lat []float64
+	values := map[string][]float64{}
+	for b, batch := range batches {
+		if batch.Lon == nil || batch.Lat == nil {
+			return fmt.Errorf("gocluster: batch %d has no coordinate columns", b)
+		}
+		n := batch.Lon.Len()
+		if batch.Lat.Len() != n {
+			return fmt.Errorf("gocluster: batch %d has %d longitudes and %d latitudes", b, n, batch.Lat.Len())
+		}
+		if b > 0 && len(batch.Properties) != len(values) {
+			return fmt.Errorf("gocluster: batch %d has different property columns", b)
+		}
+		for i := 0; i < n; i++ {
+			if batch.Lon.IsNull(i) || batch.Lat.IsNull(i) {
+				return fmt.Errorf("gocluster: batch %d row %d has null coordinates", b, i)
+			}
+			lon = append(lon, batch.Lon.Value(i))
+			lat = append(lat, batch.Lat.Value(i))
+		}
+		for name, column := range batch.Properties {
+			if _, ok := values[name]; !ok && b > 0 {
+				return fmt.Errorf("gocluster: batch %d has different property columns", b)
+			}
+			if column.Len() != n {
+				return fmt.Errorf("gocluster: batch %d column %q has %d rows, expected %d", b, name, column.Len(), n)
+			}
+			for i := 0; i < n; i++ {
+				v := math.NaN()
+				if !column.IsNull(i) {
+					v = column.Value(i)
+				}
+				values[name] = append(values[name], v)
+			}
+		}
+	}
+
+	c.columnValues = values
+	c.layerNames, c.layerStarts = nil, nil
+	return c.clusterInput(len(lon), func(i int) GeoCoordinates { return GeoCoordinates{Lon: lon[i], Lat: lat[i]} }, nil)
+}
+
+// ClusterCoordinates clusters points given by parallel slices of longitudes and latitudes, like ClusterColumns
+// without batches and properties: there are no GeoPoint interface calls or boxed values for each point,
+// which take noticeable time of clustering 10M+ points. Slices are read in place, they should not be changed until it returns.
+// Id of the point is its index in the slices, IncludedPoints of the result are empty.
+func (c *Cluster) ClusterCoordinates(lons, lats []float64) error {
+	if len(lons) != len(lats) {
+		return fmt.Errorf("gocluster: %d longitudes and %d latitudes", len(lons), len(lats))
+	}
+	c.columnValues = nil
+	c.layerNames, c.layerStarts = nil, nil
+	return c.clusterInput(len(lons), func(i int) GeoCoordinates { return GeoCoordinates{Lon: lons[i], Lat: lats[i]} }, nil)
+}
+
+// ColumnValue returns value of property column for the point id, as passed to ClusterColumns
+// ok is false for null values and unknown columns
+func (c *Cluster) ColumnValue(name string, id int) (float64, bool) {
+	values, ok := c.columnValues[name]
+	if !ok || id < 0 || id >= len(values) || math.IsNaN(values[id]) {
+		return 0, false
+	}
+	return values[id], true
+}
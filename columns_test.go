@@ -0,0 +1,46 @@
+package cluster
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestClusterCoordinates(t *testing.T) {
+	points := randomPoints(1000, 65, -30, -30, 30, 30)
+	lons, lats := make([]float64, len(points)), make([]float64, len(points))
+	for i, p := range points {
+		coordinates := p.GetCoordinates()
+		lons[i], lats[i] = coordinates.Lon, coordinates.Lat
+	}
+	want, err := NewClusterForZoom(3, 256, 60)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := want.ClusterPoints(points); err != nil {
+		t.Fatal(err)
+	}
+	c, err := NewClusterForZoom(3, 256, 60)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.ClusterCoordinates(lons, lats); err != nil {
+		t.Fatal(err)
+	}
+	checkAssignments(t, c, len(points))
+	//the same clusters as of points, without members
+	if len(c.ResultPoints) != len(want.ResultPoints) {
+		t.Fatalf("%d result points of coordinates, want %d", len(c.ResultPoints), len(want.ResultPoints))
+	}
+	for i, cp := range c.ResultPoints {
+		w := want.ResultPoints[i]
+		if cp.Id != w.Id || cp.X != w.X || cp.Y != w.Y || !reflect.DeepEqual(cp.memberIDs, w.memberIDs) {
+			t.Fatalf("result point %d of coordinates differs", i)
+		}
+		if len(cp.IncludedPoints) != 0 {
+			t.Fatalf("cluster %d has %d included points", cp.Id, len(cp.IncludedPoints))
+		}
+	}
+	if err := c.ClusterCoordinates(lons, lats[1:]); err == nil {
+		t.Fatal("expected error of slices of different length")
+	}
+}
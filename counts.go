@@ -0,0 +1,56 @@
+package cluster
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// CountFormat abbreviates point counts of clusters for display, like point_count_abbreviated of supercluster:
+// counts below 1000 as they are, then "1.2k", "35k", "3.4M". The zero value is English.
+// Units are suffixes of thousands, millions and billions, e.g. {" Tsd.", " Mio.", " Mrd."} for German;
+// Separator is the decimal separator, "." if empty.
+type CountFormat struct {
+	Units     []string
+	Separator string
+}
+
+var defaultCountUnits = []string{"k", "M", "B"}
+
+// Format returns abbreviated count: one decimal digit below 10 units, rounded to the whole unit above
+func (f CountFormat) Format(n int) string {
+	units := f.Units
+	if units == nil {
+		units = defaultCountUnits
+	}
+	if n < 1000 || len(units) == 0 {
+		return strconv.Itoa(n)
+	}
+	unit, scale := 0, 1000.0
+	for unit+1 < len(units) && float64(n) >= scale*1000 {
+		unit, scale = unit+1, scale*1000
+	}
+	v := float64(n) / scale
+	//999,950 is rounded to 1000k, which is 1M
+	if unit+1 < len(units) && math.Round(v) >= 1000 {
+		unit, v = unit+1, v/1000
+	}
+	var s string
+	if v < 10 && math.Round(v*10) < 100 {
+		s = strconv.FormatFloat(math.Round(v*10)/10, 'f', -1, 64)
+	} else {
+		s = strconv.FormatFloat(math.Round(v), 'f', 0, 64)
+	}
+	if f.Separator != "" {
+		s = strings.Replace(s, ".", f.Separator, 1)
+	}
+	return s + units[unit]
+}
+
+// computeCountAbbreviated sets PointCountAbbreviated of the cluster of several points by CountFormat
+func (c *Cluster) computeCountAbbreviated(cp *ClusterPoint) {
+	cp.PointCountAbbreviated = ""
+	if cp.NumPoints > 1 {
+		cp.PointCountAbbreviated = c.CountFormat.Format(cp.NumPoints)
+	}
+}
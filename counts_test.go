@@ -0,0 +1,61 @@
+package cluster
+
+import "testing"
+
+func TestCountFormat(t *testing.T) {
+	tests := map[int]string{
+		1: "1", 999: "999", 1000: "1k", 1249: "1.2k", 9949: "9.9k", 9950: "10k", 35400: "35k",
+		999499: "999k", 999500: "1M", 3400000: "3.4M", 2500000000: "2.5B", 2500000000000: "2500B",
+	}
+	for n, want := range tests {
+		if got := (CountFormat{}).Format(n); got != want {
+			t.Errorf("count %d is %q, want %q", n, got, want)
+		}
+	}
+	german := CountFormat{Units: []string{" Tsd.", " Mio."}, Separator: ","}
+	for n, want := range map[int]string{1500: "1,5 Tsd.", 1200000: "1,2 Mio.", 3000000000: "3000 Mio."} {
+		if got := german.Format(n); got != want {
+			t.Errorf("german count %d is %q, want %q", n, got, want)
+		}
+	}
+	if got := (CountFormat{Units: []string{}}).Format(123456); got != "123456" {
+		t.Errorf("count without units is %q", got)
+	}
+}
+
+func TestPointCountAbbreviated(t *testing.T) {
+	points := randomPoints(5000, 55, -1, -1, 1, 1)
+	c, err := NewClusterForZoom(2, 256, 60)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.CountFormat = CountFormat{Separator: ","}
+	if err := c.ClusterPoints(points); err != nil {
+		t.Fatal(err)
+	}
+	if len(c.ResultPoints) != 1 || c.ResultPoints[0].PointCountAbbreviated != "5k" {
+		t.Fatalf("%d result points, the first one of %q points", len(c.ResultPoints), c.ResultPoints[0].PointCountAbbreviated)
+	}
+	if err := c.ClusterPoints(points[:1234]); err != nil {
+		t.Fatal(err)
+	}
+	properties := ClusterProperties(&c.ResultPoints[0])
+	if properties["point_count_abbreviated"] != "1,2k" || properties["point_count"] != 1234 {
+		t.Fatalf("cluster properties %v", properties)
+	}
+	if s := roundTrip(t, c).ResultPoints[0].PointCountAbbreviated; s != "1,2k" {
+		t.Fatalf("restored abbreviated count %q", s)
+	}
+	//clusters not made by Cluster are abbreviated in English
+	properties = ClusterProperties(&ClusterPoint{NumPoints: 2500})
+	if properties["point_count_abbreviated"] != "2.5k" {
+		t.Fatalf("properties of cluster without abbreviated count %v", properties)
+	}
+	//single points have no abbreviated count
+	if err := c.ClusterPoints(points[:1]); err != nil {
+		t.Fatal(err)
+	}
+	if s := c.ResultPoints[0].PointCountAbbreviated; s != "" {
+		t.Fatalf("single point has abbreviated count %q", s)
+	}
+}
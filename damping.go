@@ -0,0 +1,76 @@
+package cluster
+
+import "math"
+
+// CenterDamping keeps centers of clusters in place across incremental updates, see Cluster.CenterDamping
+// Clusters clustered again by RebuildDirty, UpdatePoint and ReassignPoint keep the center of the cluster they replace
+// unless the new center is more than Pixels away at Zoom level with tiles of TileSize pixels,
+// so markers of live views don't jitter on every minor move; centers still follow members which move further.
+// Damping is off if TileSize is 0, Pixels is 1 if it's zero.
+type CenterDamping struct {
+	TileSize int
+	Pixels   float64
+}
+
+// dampingRadius returns CenterDamping threshold in projected coordinates, 0 if damping is off
+func (c *Cluster) dampingRadius() float64 {
+	d := c.CenterDamping
+	if d.TileSize <= 0 {
+		return 0
+	}
+	pixels := d.Pixels
+	if pixels <= 0 {
+		pixels = 1
+	}
+	return pixels / (float64(d.TileSize) * tileScale(c.Zoom))
+}
+
+// centerDamper keeps centers of clusters replaced by incremental update, by their members
+type centerDamper struct {
+	radius   float64
+	previous []*ClusterPoint //replaced result points
+	of       map[int]int     //index in previous of the replaced cluster of each member
+	used     []bool          //previous centers already kept by new clusters
+}
+
+// newCenterDamper returns damper of clusters of replaced result points, nil if damping is off
+func (c *Cluster) newCenterDamper(replaced []*ClusterPoint) *centerDamper {
+	radius := c.dampingRadius()
+	if radius <= 0 {
+		return nil
+	}
+	d := &centerDamper{radius: radius, of: map[int]int{}}
+	for _, cp := range replaced {
+		if cp.NumPoints < 2 {
+			continue
+		}
+		for _, id := range cp.memberIDs {
+			d.of[id] = len(d.previous)
+		}
+		d.previous = append(d.previous, cp)
+	}
+	d.used = make([]bool, len(d.previous))
+	return d
+}
+
+// damp returns the new cluster with projected center of the cluster it replaces, if it's close enough
+// The replaced cluster is the one of the first member, the seed of the new cluster; each one keeps one new cluster.
+// The center is moved in the copy, as clusters of duplicates are base points themselves.
+func (c *Cluster) damp(d *centerDamper, cp *ClusterPoint) *ClusterPoint {
+	if d == nil || cp.NumPoints < 2 || len(cp.memberIDs) == 0 {
+		return cp
+	}
+	i, ok := d.of[cp.memberIDs[0]]
+	if !ok || d.used[i] {
+		return cp
+	}
+	previous := d.previous[i]
+	x, y := c.projection().Project(GeoCoordinates{Lon: previous.X, Lat: previous.Y})
+	if math.IsNaN(x) || math.IsNaN(y) || sqDist(cp.X, cp.Y, x, y) > d.radius*d.radius {
+		return cp
+	}
+	d.used[i] = true
+	damped := *cp
+	damped.X, damped.Y = x, y
+	return &damped
+}
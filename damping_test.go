@@ -0,0 +1,76 @@
+package cluster
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCenterDamping(t *testing.T) {
+	points := []GeoPoint{
+		&Feature{ID: 0, Coordinates: onEquator(10)},
+		&Feature{ID: 1, Coordinates: onEquator(10.01)},
+		&Feature{ID: 2, Coordinates: onEquator(10.02)},
+		&Feature{ID: 3, Coordinates: onEquator(-10)},
+	}
+	newCluster := func(damping CenterDamping) *Cluster {
+		c, err := NewClusterForZoom(10, 256, 60)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.CenterDamping = damping
+		if err := c.ClusterPoints(points); err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+	centerOf := func(c *Cluster) float64 {
+		return c.ResultPoints[c.assignment[0]].X
+	}
+	//a pixel at zoom 10 is 0.0014 degrees, minor move shifts the center by a quarter of it
+	minor, major := onEquator(10.021), onEquator(10.05)
+
+	c := newCluster(CenterDamping{TileSize: 256})
+	before := centerOf(c)
+	if math.Abs(before-10.01) > 1e-9 {
+		t.Fatalf("clustered center is %v, want 10.01", before)
+	}
+	if err := c.UpdatePoint(2, minor); err != nil {
+		t.Fatal(err)
+	}
+	if center := centerOf(c); center != before {
+		t.Fatalf("center is moved to %v on minor move", center)
+	}
+	if err := c.UpdatePoint(2, major); err != nil {
+		t.Fatal(err)
+	}
+	if center := centerOf(c); math.Abs(center-10.02) > 1e-9 {
+		t.Fatalf("center is %v after major move, want 10.02", center)
+	}
+	restored := roundTrip(t, c)
+	if restored.CenterDamping != c.CenterDamping {
+		t.Fatalf("restored cluster has CenterDamping %+v", restored.CenterDamping)
+	}
+
+	//without damping centers follow every move
+	plain := newCluster(CenterDamping{})
+	if err := plain.UpdatePoint(2, minor); err != nil {
+		t.Fatal(err)
+	}
+	if center := centerOf(plain); math.Abs(center-(10+10.01+10.021)/3) > 1e-9 {
+		t.Fatalf("center is %v without damping", center)
+	}
+	//larger threshold keeps the center on the move
+	wide := newCluster(CenterDamping{TileSize: 256, Pixels: 20})
+	if err := wide.UpdatePoint(2, major); err != nil {
+		t.Fatal(err)
+	}
+	if center := centerOf(wide); center != before {
+		t.Fatalf("center is moved to %v within 20 pixels", center)
+	}
+
+	cartesian := NewCartesianCluster(1)
+	cartesian.CenterDamping = CenterDamping{TileSize: 256}
+	if err := cartesian.ClusterPoints(points); err == nil {
+		t.Fatal("expected error of CenterDamping without WebMercator")
+	}
+}
@@ -38,9 +38,12 @@
 // All ids of ClusterPoint that you have as result are the index of initial array of Geopoint,
 // so yu could get you point by this index
 //
-// Clusters of points are have autoincrement generated ids, started at ClusterIdxSeed
+// Clusters of points are have generated ids, started at ClusterIdxSeed
 // ClusterIdxSeed is the next power of length of input array
 //
+// Cluster id encodes zoom level of the cluster: ClusterIdxSeed + (sequence << 5) + zoom,
+// use ZoomOfCluster to get it back
+//
 // For example, if input slice of points length is 78,  ClusterIdxSeed == 100,
 // if input slice of points length is 991,  ClusterIdxSeed == 1000
 // etc
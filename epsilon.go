@@ -0,0 +1,90 @@
+package cluster
+
+import (
+	"errors"
+	"math"
+	"sort"
+)
+
+// epsilonSampleSize is the number of points k-distances are calculated for, points are sampled evenly above it
+const epsilonSampleSize = 10000
+
+// EpsilonEstimate is the result of k-distance analysis of the points
+// KDistances are sorted distances of sampled points to their k-th nearest neighbour in projected coordinates,
+// Knee is the index of the knee of this curve, Epsilon is the distance at the knee.
+type EpsilonEstimate struct {
+	Epsilon    float64
+	KDistances []float64
+	Knee       int
+}
+
+// EstimateEpsilon suggests Epsilon for the points by k-distance analysis, k is usually MinPoints
+// Points of dense groups have small distances to their k-th neighbour and noise has large ones,
+// the knee of sorted distances separates them, so Epsilon at the knee clusters dense groups only.
+// Distances are calculated for up to 10000 points sampled evenly from the input.
+func EstimateEpsilon(points []GeoPoint, k int) (EpsilonEstimate, error) {
+	if k < 1 {
+		return EpsilonEstimate{}, errors.New("gocluster: k should be positive")
+	}
+	if len(points) <= k {
+		return EpsilonEstimate{}, errors.New("gocluster: there should be more points than k")
+	}
+
+	index := newKDIndex(len(points), 64, CoordinatesFloat64)
+	xs, ys := make([]float64, len(points)), make([]float64, len(points))
+	minX, minY, maxX, maxY := math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)
+	for i, p := range points {
+		xs[i], ys[i] = MercatorProjection(p.GetCoordinates())
+		index.add(xs[i], ys[i])
+		minX, maxX = math.Min(minX, xs[i]), math.Max(maxX, xs[i])
+		minY, maxY = math.Min(minY, ys[i]), math.Max(maxY, ys[i])
+	}
+	index.build()
+
+	//radius containing k neighbours for uniform density, it's doubled until they are found
+	start := math.Sqrt(float64(k) * (maxX - minX) * (maxY - minY) / (math.Pi * float64(len(points))))
+	start = math.Max(start, 1e-9)
+
+	n := minInt(len(points), epsilonSampleSize)
+	distances := make([]float64, 0, n)
+	var found []int
+	var d2 []float64
+	for s := 0; s < n; s++ {
+		i := s * len(points) / n
+		for r := start; ; r *= 2 {
+			found = index.AppendWithin(found[:0], xs[i], ys[i], r)
+			//the point itself is found too
+			if len(found) > k || r > 2 {
+				break
+			}
+		}
+		d2 = d2[:0]
+		for _, j := range found {
+			if j != i {
+				d2 = append(d2, sqDist(xs[i], ys[i], xs[j], ys[j]))
+			}
+		}
+		sort.Float64s(d2)
+		distances = append(distances, math.Sqrt(d2[k-1]))
+	}
+	sort.Float64s(distances)
+
+	knee := kneeIndex(distances)
+	return EpsilonEstimate{Epsilon: distances[knee], KDistances: distances, Knee: knee}, nil
+}
+
+// kneeIndex returns the point of increasing curve farthest below the line between its ends, both axes normalized
+func kneeIndex(values []float64) int {
+	last := len(values) - 1
+	span := values[last] - values[0]
+	if last == 0 || span == 0 {
+		return last
+	}
+	knee, best := last, 0.0
+	for i, v := range values {
+		if d := float64(i)/float64(last) - (v-values[0])/span; d > best {
+			knee, best = i, d
+		}
+	}
+	return knee
+}
@@ -0,0 +1,59 @@
+package cluster
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestEstimateEpsilon(t *testing.T) {
+	//800 points in 4 dense blobs of 0.2° and 200 points of noise over 100°
+	random := rand.New(rand.NewSource(33))
+	points := randomPoints(200, 34, -50, -50, 50, 50)
+	for i := 0; i < 800; i++ {
+		center := float64(i%4)*20 - 30
+		points = append(points, &Feature{Coordinates: GeoCoordinates{
+			Lon: center + random.Float64()*0.2,
+			Lat: center + random.Float64()*0.2,
+		}})
+	}
+	e, err := EstimateEpsilon(points, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(e.KDistances) != len(points) || !sort.Float64sAreSorted(e.KDistances) || e.KDistances[e.Knee] != e.Epsilon {
+		t.Fatalf("estimate has %d distances, knee %d and epsilon %v", len(e.KDistances), e.Knee, e.Epsilon)
+	}
+	//epsilon is the distance of neighbours in blobs, far below distances of noise
+	blob, noise := 0.2/360, 2.0/360
+	if e.Epsilon < blob/100 || e.Epsilon > noise {
+		t.Fatalf("estimated epsilon %v, blobs are %v and noise is %v apart", e.Epsilon, blob, noise)
+	}
+	//most points are in blobs, so the knee is at the last of them
+	if e.Knee < 700 || e.Knee > 850 {
+		t.Fatalf("knee is at %d of %d distances", e.Knee, len(e.KDistances))
+	}
+	if _, err := EstimateEpsilon(points, 0); err == nil {
+		t.Fatal("expected error of zero k")
+	}
+	if _, err := EstimateEpsilon(points[:3], 3); err == nil {
+		t.Fatal("expected error of too few points")
+	}
+}
+
+func TestKneeIndex(t *testing.T) {
+	tests := []struct {
+		values []float64
+		want   int
+	}{
+		{[]float64{1, 1, 1, 1, 2, 10}, 4},
+		{[]float64{0, 0.1, 0.2, 5}, 2},
+		{[]float64{3, 3, 3}, 2},
+		{[]float64{7}, 0},
+	}
+	for _, test := range tests {
+		if knee := kneeIndex(test.values); knee != test.want {
+			t.Errorf("knee of %v is %d, want %d", test.values, knee, test.want)
+		}
+	}
+}
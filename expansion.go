@@ -0,0 +1,57 @@
+package cluster
+
+import "math"
+
+// Viewport is the center and fractional zoom of the map
+type Viewport struct {
+	Center GeoCoordinates
+	Zoom   float64
+}
+
+// ExpansionBounds returns the box framing members of the cluster or single point with id, e.g. for click to expand
+// padding is the fraction of the box size added on each side, in projected coordinates.
+// Box of single point or of members with the same coordinates is the point itself,
+// ok is false for unknown id and before points are clustered, see Built.
+func (c *Cluster) ExpansionBounds(id int, padding float64) (northWest, southEast GeoCoordinates, ok bool) {
+	if !c.Built() {
+		return northWest, southEast, false
+	}
+	i, ok := c.resultByID(id)
+	if !ok {
+		return northWest, southEast, false
+	}
+	minX, minY, maxX, maxY := math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)
+	for _, member := range c.ResultPoints[i].memberIDs {
+		p := c.basePoints[c.basePointOf(member)]
+		minX, maxX = math.Min(minX, p.X), math.Max(maxX, p.X)
+		minY, maxY = math.Min(minY, p.Y), math.Max(maxY, p.Y)
+	}
+	dx, dy := (maxX-minX)*padding, (maxY-minY)*padding
+	minX, minY, maxX, maxY = minX-dx, minY-dy, maxX+dx, maxY+dy
+	if c.mercator() {
+		minX, maxX = math.Max(0, minX), math.Min(1, maxX)
+		minY, maxY = math.Max(0, minY), math.Min(1, maxY)
+	}
+	//projections differ in direction of y, so corners are ordered by coordinates
+	a, b := c.projection().Unproject(minX, minY), c.projection().Unproject(maxX, maxY)
+	northWest = GeoCoordinates{Lon: math.Min(a.Lon, b.Lon), Lat: math.Max(a.Lat, b.Lat)}
+	southEast = GeoCoordinates{Lon: math.Max(a.Lon, b.Lon), Lat: math.Min(a.Lat, b.Lat)}
+	return northWest, southEast, true
+}
+
+// FitBounds returns the viewport of the map of width x height pixels with tiles of tileSize pixels showing the box
+// Zoom is limited by maxZoom, so boxes of single points are not zoomed in infinitely.
+func FitBounds(northWest, southEast GeoCoordinates, width, height, tileSize int, maxZoom float64) Viewport {
+	minX, minY := MercatorProjection(northWest)
+	maxX, maxY := MercatorProjection(southEast)
+	center := ReverseMercatorProjection((minX+maxX)/2, (minY+maxY)/2)
+	//the box takes the whole map at the zoom it fits both sides
+	zoom := maxZoom
+	if dx := (maxX - minX) * float64(tileSize); dx > 0 {
+		zoom = math.Min(zoom, math.Log2(float64(width)/dx))
+	}
+	if dy := (maxY - minY) * float64(tileSize); dy > 0 {
+		zoom = math.Min(zoom, math.Log2(float64(height)/dy))
+	}
+	return Viewport{Center: center, Zoom: math.Max(0, zoom)}
+}
@@ -0,0 +1,73 @@
+package cluster
+
+import (
+	"math"
+	"testing"
+)
+
+func TestExpansionBounds(t *testing.T) {
+	points := randomPoints(1000, 43, -30, -30, 30, 30)
+	c, err := NewClusterForZoom(3, 256, 60)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, ok := c.ExpansionBounds(0, 0); ok {
+		t.Fatal("expected no bounds before points are clustered")
+	}
+	if err := c.ClusterPoints(points); err != nil {
+		t.Fatal(err)
+	}
+	for _, cp := range c.ResultPoints {
+		northWest, southEast, ok := c.ExpansionBounds(cp.Id, 0)
+		if !ok {
+			t.Fatalf("no bounds of cluster %d", cp.Id)
+		}
+		//the box is the tight box of members
+		west, north, east, south := math.Inf(1), math.Inf(-1), math.Inf(-1), math.Inf(1)
+		for _, p := range cp.IncludedPoints {
+			coordinates := p.GetCoordinates()
+			west, east = math.Min(west, coordinates.Lon), math.Max(east, coordinates.Lon)
+			south, north = math.Min(south, coordinates.Lat), math.Max(north, coordinates.Lat)
+		}
+		if math.Abs(northWest.Lon-west) > 1e-9 || math.Abs(northWest.Lat-north) > 1e-9 ||
+			math.Abs(southEast.Lon-east) > 1e-9 || math.Abs(southEast.Lat-south) > 1e-9 {
+			t.Fatalf("cluster %d of %d points has bounds %v %v, want %v,%v %v,%v",
+				cp.Id, cp.NumPoints, northWest, southEast, west, north, east, south)
+		}
+		padded, paddedSouthEast, _ := c.ExpansionBounds(cp.Id, 0.1)
+		if cp.NumPoints > 1 && !(padded.Lon < northWest.Lon && padded.Lat > northWest.Lat &&
+			paddedSouthEast.Lon > southEast.Lon && paddedSouthEast.Lat < southEast.Lat) {
+			t.Fatalf("padded bounds of cluster %d are %v %v", cp.Id, padded, paddedSouthEast)
+		}
+	}
+	if _, _, ok := c.ExpansionBounds(-1, 0); ok {
+		t.Fatal("expected no bounds of unknown id")
+	}
+}
+
+func TestFitBounds(t *testing.T) {
+	tile := Tile{X: 550, Y: 335, Z: 10}
+	northWest, southEast := TileBounds(tile)
+	//the tile takes the whole map of its size at its zoom, and the map of double size one zoom later
+	for size, want := range map[int]float64{256: 10, 512: 11, 128: 9} {
+		v := FitBounds(northWest, southEast, size, size, 256, 20)
+		if math.Abs(v.Zoom-want) > 1e-9 {
+			t.Fatalf("map of %d pixels has zoom %v, want %v", size, v.Zoom, want)
+		}
+		x, y := TilePixel(v.Center, tile, 256)
+		if math.Abs(x-128) > 1e-6 || math.Abs(y-128) > 1e-6 {
+			t.Fatalf("map of %d pixels is centered at tile pixel %v,%v", size, x, y)
+		}
+	}
+	//the narrow side fits the box
+	if v := FitBounds(northWest, southEast, 512, 256, 256, 20); math.Abs(v.Zoom-10) > 1e-9 {
+		t.Fatalf("wide map has zoom %v, want 10", v.Zoom)
+	}
+	point := GeoCoordinates{Lon: 13.4, Lat: 52.5}
+	if v := FitBounds(point, point, 256, 256, 256, 16); v.Zoom != 16 {
+		t.Fatalf("box of single point has zoom %v, want max zoom", v.Zoom)
+	}
+	if v := FitBounds(GeoCoordinates{Lon: -180, Lat: 85}, GeoCoordinates{Lon: 180, Lat: -85}, 64, 64, 256, 16); v.Zoom != 0 {
+		t.Fatalf("world on small map has zoom %v, want 0", v.Zoom)
+	}
+}
@@ -0,0 +1,359 @@
+package cluster
+
+// PointFilter tells which points a faceted query should count, see Cluster.FilterClusters
+// Filters of PropertyEquals and AllOf of them are checked by the index of property values of the Cluster,
+// so clusters are filtered by their values without reading members, see FilterClusters.
+type PointFilter interface {
+	Match(p GeoPoint) bool
+}
+
+// FilterFunc is PointFilter of the function, e.g. of the predicate on several properties
+type FilterFunc func(p GeoPoint) bool
+
+// Match implements PointFilter interface
+func (f FilterFunc) Match(p GeoPoint) bool {
+	return f(p)
+}
+
+// indexedFilter is PointFilter which is checked by indexes of c instead of properties of members
+type indexedFilter interface {
+	PointFilter
+	matcher(c *Cluster) filterMatcher
+}
+
+// filterMatcher matches result points of c by their indexes in ResultPoints and members by their input ids
+type filterMatcher interface {
+	//result returns whether none or all members of the result point match
+	result(i int) (none, all bool)
+	//member returns whether the member matches, p returns the member point if it's needed, nil if it's lost
+	member(id int, p func() GeoPoint) bool
+}
+
+// matcherOf returns matcher of the filter for c, filters which are not indexed are matched by their members
+func (c *Cluster) matcherOf(filter PointFilter) filterMatcher {
+	if f, ok := filter.(indexedFilter); ok {
+		return f.matcher(c)
+	}
+	return pointMatcher{filter}
+}
+
+// pointMatcher matches members by their points, nothing is known about result points
+type pointMatcher struct {
+	filter PointFilter
+}
+
+func (m pointMatcher) result(i int) (bool, bool) {
+	return false, false
+}
+
+func (m pointMatcher) member(id int, p func() GeoPoint) bool {
+	point := p()
+	return point != nil && m.filter.Match(point)
+}
+
+// propertyEquals is PointFilter of PropertyEquals
+type propertyEquals struct {
+	key      string
+	values   []string
+	category CategoryAccessor
+}
+
+// PropertyEquals returns PointFilter of GeoPointWithProperties points, e.g. *Feature, whose property is one of values,
+// e.g. PropertyEquals("status", "active"). Property values are compared as PropertyCategory formats them.
+func PropertyEquals(key string, values ...string) PointFilter {
+	return &propertyEquals{key: key, values: values, category: PropertyCategory(key)}
+}
+
+// Match implements PointFilter interface
+func (f *propertyEquals) Match(p GeoPoint) bool {
+	v, ok := f.category(p)
+	if !ok {
+		return false
+	}
+	for _, value := range f.values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *propertyEquals) matcher(c *Cluster) filterMatcher {
+	index := c.propertyIndex(f.key, f.category)
+	m := &propertyMatcher{index: index, codes: map[int32]bool{}}
+	for _, value := range f.values {
+		if code, ok := index.codes[value]; ok {
+			m.codes[code] = true
+		}
+	}
+	return m
+}
+
+// propertyMatcher matches codes of property values of PropertyEquals filter
+type propertyMatcher struct {
+	index *propertyIndex
+	codes map[int32]bool
+}
+
+func (m *propertyMatcher) result(i int) (bool, bool) {
+	if i >= len(m.index.results) {
+		return false, false
+	}
+	matching := 0
+	for _, code := range m.index.results[i] {
+		if m.codes[code] {
+			matching++
+		}
+	}
+	return matching == 0, matching == len(m.index.results[i])
+}
+
+func (m *propertyMatcher) member(id int, p func() GeoPoint) bool {
+	return id < len(m.index.members) && m.codes[m.index.members[id]]
+}
+
+// allOf is PointFilter of AllOf, it's indexed if any of filters is
+type allOf []PointFilter
+
+// AllOf returns PointFilter of points matching all filters, e.g. facets of the map
+func AllOf(filters ...PointFilter) PointFilter {
+	return allOf(filters)
+}
+
+// Match implements PointFilter interface
+func (filters allOf) Match(p GeoPoint) bool {
+	for _, filter := range filters {
+		if !filter.Match(p) {
+			return false
+		}
+	}
+	return true
+}
+
+func (filters allOf) matcher(c *Cluster) filterMatcher {
+	matchers := make(allMatcher, len(filters))
+	for i, filter := range filters {
+		matchers[i] = c.matcherOf(filter)
+	}
+	return matchers
+}
+
+// allMatcher matches members matching all matchers
+type allMatcher []filterMatcher
+
+func (matchers allMatcher) result(i int) (bool, bool) {
+	all := true
+	for _, m := range matchers {
+		none, every := m.result(i)
+		if none {
+			return true, false
+		}
+		all = all && every
+	}
+	return false, all
+}
+
+func (matchers allMatcher) member(id int, p func() GeoPoint) bool {
+	for _, m := range matchers {
+		if !m.member(id, p) {
+			return false
+		}
+	}
+	return true
+}
+
+// propertyIndex is the index of values of the property of input points, built on the first filtered query
+// Values are numbered, members are codes of values by input ids, -1 if the point has none,
+// and results are distinct codes of members of each result point, so filters skip clusters without reading members.
+type propertyIndex struct {
+	codes   map[string]int32
+	members []int32
+	results [][]int32
+}
+
+// propertyIndex returns index of values of the property of ResultPoints, it's built on the first query of the property
+func (c *Cluster) propertyIndex(key string, category CategoryAccessor) *propertyIndex {
+	r := c.results
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if index, ok := r.properties[key]; ok {
+		return index
+	}
+	if r.properties == nil {
+		r.properties = map[string]*propertyIndex{}
+	}
+	index := c.newPropertyIndex(category)
+	r.properties[key] = index
+	return index
+}
+
+// newPropertyIndex indexes values of members of base points, points of ClusterColumns have none
+func (c *Cluster) newPropertyIndex(category CategoryAccessor) *propertyIndex {
+	index := &propertyIndex{codes: map[string]int32{}, members: make([]int32, c.numInputPoints())}
+	for id := range index.members {
+		index.members[id] = -1
+	}
+	for _, p := range c.basePoints {
+		for k := 0; k < len(p.IncludedPoints) && k < len(p.memberIDs); k++ {
+			v, ok := category(p.IncludedPoints[k])
+			if !ok {
+				continue
+			}
+			code, ok := index.codes[v]
+			if !ok {
+				code = int32(len(index.codes))
+				index.codes[v] = code
+			}
+			index.members[p.memberIDs[k]] = code
+		}
+	}
+
+	index.results = make([][]int32, len(c.ResultPoints))
+	for id, code := range index.members {
+		if id >= len(c.assignment) || c.assignment[id] < 0 || c.assignment[id] >= len(index.results) {
+			continue
+		}
+		codes := &index.results[c.assignment[id]]
+		if !containsCode(*codes, code) {
+			*codes = append(*codes, code)
+		}
+	}
+	return index
+}
+
+// containsCode returns true if codes contain the code, clusters have few distinct values of facets
+func containsCode(codes []int32, code int32) bool {
+	for _, c := range codes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// GetClustersWhere returns clusters and single points inside the box, like GetClusters, made only of members
+// matching the filter, see FilterClusters
+func (c *Cluster) GetClustersWhere(northWest, southEast GeoCoordinates, filter PointFilter) []ClusterPoint {
+	return c.FilterClusters(c.GetClusters(northWest, southEast), filter)
+}
+
+// FilterClusters returns result points of c, e.g. of GetClusters or GetClustersBetween, made only of members
+// matching the filter, so faceted maps don't need the index for each combination of filters.
+// Counts, centers and aggregates of clusters are computed again over matching members, clusters keep their ids;
+// the cluster of one matching member becomes its single point,
+// clusters without them are dropped. Leaves of the cluster id still returns all members.
+// PropertyEquals filters are checked by the index of property values built on the first query of the property:
+// result points of c without matching values are dropped and ones with only matching values are kept
+// without reading their members, other clusters match members by their values.
+// Members of ClusterColumns are not GeoPoints, so none of them match; spilled members are read from the spill file.
+// It's safe to call concurrently with other queries.
+func (c *Cluster) FilterClusters(points []ClusterPoint, filter PointFilter) []ClusterPoint {
+	defer c.startQuery("FilterClusters")()
+	result := make([]ClusterPoint, 0, len(points))
+	if len(points) == 0 || c.results == nil {
+		return result
+	}
+	matcher := c.matcherOf(filter)
+	for i := range points {
+		if cp, ok := c.filterCluster(&points[i], matcher); ok {
+			result = append(result, cp)
+		}
+	}
+	return result
+}
+
+// resultIndexOf returns index in ResultPoints of the result point, ok is false if cp is not one of them,
+// e.g. cluster of GetClustersBetween with the same id; copies of result points share their members
+func (c *Cluster) resultIndexOf(cp *ClusterPoint) (int, bool) {
+	i, ok := c.resultByID(cp.Id)
+	if !ok || len(cp.memberIDs) == 0 {
+		return 0, false
+	}
+	own := &c.ResultPoints[i]
+	return i, own.NumPoints == cp.NumPoints && len(own.memberIDs) == len(cp.memberIDs) && &own.memberIDs[0] == &cp.memberIDs[0]
+}
+
+// filterCluster returns the result point made of its members matching the filter, ok is false if there are none
+// Matching members are single points at positions of their base points, merged like clustering merges them.
+func (c *Cluster) filterCluster(cp *ClusterPoint, matcher filterMatcher) (ClusterPoint, bool) {
+	if i, ok := c.resultIndexOf(cp); ok {
+		none, all := matcher.result(i)
+		if none {
+			return ClusterPoint{}, false
+		}
+		if all {
+			return *cp, true
+		}
+	}
+	//members are read only if the matcher needs them or some of them match
+	var members []GeoPoint
+	var err error
+	loaded := false
+	load := func() {
+		if !loaded {
+			members, err = c.members(cp)
+			loaded = true
+		}
+	}
+	k := 0
+	member := func() GeoPoint {
+		load()
+		if k >= len(members) {
+			return nil
+		}
+		return members[k]
+	}
+	var matching []int
+	for ; k < len(cp.memberIDs); k++ {
+		if matcher.member(cp.memberIDs[k], member) {
+			matching = append(matching, k)
+		}
+	}
+	switch {
+	case len(matching) == 0:
+		return ClusterPoint{}, false
+	case len(matching) == cp.NumPoints:
+		return *cp, true
+	}
+
+	load()
+	if err != nil {
+		//members are lost with the spill file
+		return ClusterPoint{}, false
+	}
+	points := make([]*ClusterPoint, 0, len(matching))
+	for _, k := range matching {
+		if k >= len(members) {
+			continue
+		}
+		id := cp.memberIDs[k]
+		b := c.basePoints[c.basePointOf(id)]
+		points = append(points, &ClusterPoint{
+			X:              b.X,
+			Y:              b.Y,
+			Id:             id,
+			NumPoints:      1,
+			IncludedPoints: []GeoPoint{members[k]},
+			memberIDs:      []int{id},
+			region:         b.region,
+		})
+	}
+	switch len(points) {
+	case 0:
+		return ClusterPoint{}, false
+	case 1:
+		return c.resultPoint(points[0]), true
+	}
+	merged := c.mergePoints(points[0], points[1:])
+	merged.Id = cp.Id
+	return c.resultPoint(merged), true
+}
+
+// GetClustersWhere returns clusters of the zoom level made of members matching the filter,
+// see Cluster.GetClustersWhere, it's nil for zoom levels without Cluster, see At
+func (l Levels) GetClustersWhere(northWest, southEast GeoCoordinates, zoom int, filter PointFilter) []ClusterPoint {
+	if c, ok := l.At(zoom); ok {
+		return c.GetClustersWhere(northWest, southEast, filter)
+	}
+	return nil
+}
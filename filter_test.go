@@ -0,0 +1,106 @@
+package cluster
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// facetPoints returns random points with "status" of every third point "active" and "side" of their hemisphere
+func facetPoints(n int) []GeoPoint {
+	points := randomPoints(n, 8, -20, -20, 20, 20)
+	for i, p := range points {
+		f := p.(*Feature)
+		f.Properties["status"] = []string{"active", "idle", "broken"}[i%3]
+		f.Properties["side"] = "west"
+		if f.Coordinates.Lon >= 0 {
+			f.Properties["side"] = "east"
+		}
+	}
+	return points
+}
+
+// checkSameClusters fails if clusters differ by ids, counts or centers
+func checkSameClusters(t *testing.T, got, want []ClusterPoint) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("got %d clusters, want %d", len(got), len(want))
+	}
+	for i := range want {
+		g, w := &got[i], &want[i]
+		if g.Id != w.Id || g.NumPoints != w.NumPoints || g.X != w.X || g.Y != w.Y {
+			t.Fatalf("cluster %d is %d of %d points at %v,%v, want %d of %d points at %v,%v",
+				i, g.Id, g.NumPoints, g.X, g.Y, w.Id, w.NumPoints, w.X, w.Y)
+		}
+	}
+}
+
+func TestFilterClustersByIndex(t *testing.T) {
+	const n = 3000
+	c, err := NewClusterForZoom(3, 256, 60)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Time = PropertyTime("n")
+	if err := c.ClusterPoints(facetPoints(n)); err != nil {
+		t.Fatal(err)
+	}
+	northWest, southEast := GeoCoordinates{Lon: -15, Lat: 15}, GeoCoordinates{Lon: 15, Lat: -15}
+	filters := []PointFilter{
+		PropertyEquals("status", "active"),
+		PropertyEquals("status", "idle", "broken"),
+		PropertyEquals("status", "missing"),
+		AllOf(PropertyEquals("status", "active"), PropertyEquals("side", "east")),
+		AllOf(PropertyEquals("side", "west"), FilterFunc(func(p GeoPoint) bool { return p.(*Feature).ID.(int)%2 == 0 })),
+	}
+	for i, filter := range filters {
+		//the same filter which is not indexed matches every member by its properties
+		byMembers := FilterFunc(filter.Match)
+		t.Run(fmt.Sprint(i), func(t *testing.T) {
+			points := c.GetClusters(northWest, southEast)
+			want := c.FilterClusters(points, byMembers)
+			checkSameClusters(t, c.FilterClusters(points, filter), want)
+			checkSameClusters(t, c.GetClustersWhere(northWest, southEast, filter), want)
+			for _, cp := range want {
+				for _, p := range cp.IncludedPoints {
+					if !filter.Match(p) {
+						t.Fatalf("cluster %d has member %v not matching the filter", cp.Id, p.(*Feature).ID)
+					}
+				}
+			}
+
+			//clusters of the time window are not ResultPoints, their members are matched by input ids
+			window := c.GetClustersBetween(northWest, southEast, time.Unix(0, 0), time.Unix(n/2, 0))
+			checkSameClusters(t, c.FilterClusters(window, filter), c.FilterClusters(window, byMembers))
+		})
+	}
+}
+
+func TestFilterClustersSkipsMembers(t *testing.T) {
+	c, err := NewClusterForZoom(2, 256, 80)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.SpillThreshold = 5
+	c.Boundaries = []Polygon{
+		{ID: "west", Ring: []GeoCoordinates{{Lon: -20, Lat: -20}, {Lon: 0, Lat: -20}, {Lon: 0, Lat: 20}, {Lon: -20, Lat: 20}}},
+		{ID: "east", Ring: []GeoCoordinates{{Lon: 0, Lat: -20}, {Lon: 20, Lat: -20}, {Lon: 20, Lat: 20}, {Lon: 0, Lat: 20}}},
+	}
+	defer c.Close()
+	if err := c.ClusterPoints(facetPoints(2000)); err != nil {
+		t.Fatal(err)
+	}
+	points := c.GetClusters(GeoCoordinates{Lon: -180, Lat: 85}, GeoCoordinates{Lon: 180, Lat: -85})
+	want := c.FilterClusters(points, FilterFunc(PropertyEquals("side", "east").Match))
+	if len(want) == 0 || len(want) == len(points) {
+		t.Fatalf("%d of %d clusters are in the east", len(want), len(points))
+	}
+
+	//clusters don't cross boundaries, so each one is in the east or in the west entirely,
+	//and the index tells them apart without members, which are lost with the spill file
+	c.Close()
+	checkSameClusters(t, c.FilterClusters(points, PropertyEquals("side", "east")), want)
+	if got := c.FilterClusters(points, FilterFunc(PropertyEquals("side", "east").Match)); len(got) >= len(want) {
+		t.Fatalf("filter of members matched %d clusters without the spill file", len(got))
+	}
+}
@@ -0,0 +1,297 @@
+package cluster
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+// Minimal FlatBuffers writer and reader, used by FlatGeobuf to avoid flatbuffers dependency.
+// Unlike the reference builder, buffer is written front to back: table first, then objects it refers to,
+// so all offsets point forward as the format requires. Scalars are aligned to their size from buffer start.
+
+// fbField is the table field, either scalar of size bytes or reference to object written by ref
+type fbField struct {
+	size   int
+	scalar uint64
+	ref    func(b *fbBuilder) int
+}
+
+func fbUint8(v uint8) fbField                  { return fbField{size: 1, scalar: uint64(v)} }
+func fbUint16(v uint16) fbField                { return fbField{size: 2, scalar: uint64(v)} }
+func fbInt32(v int32) fbField                  { return fbField{size: 4, scalar: uint64(uint32(v))} }
+func fbUint64(v uint64) fbField                { return fbField{size: 8, scalar: v} }
+func fbRef(ref func(b *fbBuilder) int) fbField { return fbField{size: 4, ref: ref} }
+
+func fbString(s string) fbField {
+	return fbRef(func(b *fbBuilder) int { return b.bytesVector([]byte(s), true) })
+}
+
+type fbBuilder struct {
+	buf []byte
+}
+
+// finish writes root table and returns the buffer
+func (b *fbBuilder) finish(root []fbField) []byte {
+	b.buf = append(b.buf[:0], 0, 0, 0, 0)
+	pos := b.table(root)
+	binary.LittleEndian.PutUint32(b.buf, uint32(pos))
+	return b.buf
+}
+
+// align pads buffer so len(buf)+extra is multiple of align
+func (b *fbBuilder) align(align, extra int) {
+	for (len(b.buf)+extra)%align != 0 {
+		b.buf = append(b.buf, 0)
+	}
+}
+
+func (b *fbBuilder) putUint32(pos int, v uint32) {
+	binary.LittleEndian.PutUint32(b.buf[pos:], v)
+}
+
+func (b *fbBuilder) appendScalar(size int, v uint64) {
+	var data [8]byte
+	binary.LittleEndian.PutUint64(data[:], v)
+	b.buf = append(b.buf, data[:size]...)
+}
+
+// table writes vtable and table with fields, absent fields have zero size, returns table position
+func (b *fbBuilder) table(fields []fbField) int {
+	//table layout: soffset, then fields by size descending, so each field is aligned
+	offsets := make([]int, len(fields))
+	tableSize, maxAlign := 4, 4
+	for _, size := range []int{8, 4, 2, 1} {
+		for i, f := range fields {
+			if f.size != size {
+				continue
+			}
+			for tableSize%size != 0 {
+				tableSize++
+			}
+			offsets[i] = tableSize
+			tableSize += size
+			if size > maxAlign {
+				maxAlign = size
+			}
+		}
+	}
+
+	b.align(2, 0)
+	vtable := len(b.buf)
+	b.appendScalar(2, uint64(4+2*len(fields)))
+	b.appendScalar(2, uint64(tableSize))
+	for _, o := range offsets {
+		b.appendScalar(2, uint64(o))
+	}
+
+	b.align(maxAlign, 0)
+	pos := len(b.buf)
+	b.buf = append(b.buf, make([]byte, tableSize)...)
+	b.putUint32(pos, uint32(pos-vtable))
+	for i, f := range fields {
+		if f.size > 0 && f.ref == nil {
+			var data [8]byte
+			binary.LittleEndian.PutUint64(data[:], f.scalar)
+			copy(b.buf[pos+offsets[i]:], data[:f.size])
+		}
+	}
+	for i, f := range fields {
+		if f.ref != nil {
+			child := f.ref(b)
+			b.putUint32(pos+offsets[i], uint32(child-pos-offsets[i]))
+		}
+	}
+	return pos
+}
+
+// bytesVector writes vector of bytes, strings are zero terminated
+func (b *fbBuilder) bytesVector(data []byte, zero bool) int {
+	b.align(4, 0)
+	pos := len(b.buf)
+	b.appendScalar(4, uint64(len(data)))
+	b.buf = append(b.buf, data...)
+	if zero {
+		b.buf = append(b.buf, 0)
+	}
+	return pos
+}
+
+func (b *fbBuilder) doublesVector(values []float64) int {
+	b.align(8, 4)
+	pos := len(b.buf)
+	b.appendScalar(4, uint64(len(values)))
+	for _, v := range values {
+		b.appendScalar(8, math.Float64bits(v))
+	}
+	return pos
+}
+
+// longStructsVector writes vector of structs of fields int64 fields each, values are fields of all structs
+func (b *fbBuilder) longStructsVector(values []int64, fields int) int {
+	b.align(8, 4)
+	pos := len(b.buf)
+	b.appendScalar(4, uint64(len(values)/fields))
+	for _, v := range values {
+		b.appendScalar(8, uint64(v))
+	}
+	return pos
+}
+
+func (b *fbBuilder) tablesVector(tables [][]fbField) int {
+	b.align(4, 0)
+	pos := len(b.buf)
+	b.appendScalar(4, uint64(len(tables)))
+	b.buf = append(b.buf, make([]byte, 4*len(tables))...)
+	for i, t := range tables {
+		child := b.table(t)
+		elem := pos + 4 + 4*i
+		b.putUint32(elem, uint32(child-elem))
+	}
+	return pos
+}
+
+var errFlatBuffer = errors.New("malformed flatbuffer")
+
+// fbTable reads table of the buffer, out of range reads panic with errFlatBuffer, see fbRecover
+type fbTable struct {
+	buf []byte
+	pos int
+}
+
+func fbRoot(buf []byte) fbTable {
+	t := fbTable{buf: buf}
+	t.pos = t.indirect(0)
+	return t
+}
+
+// fbRecover turns errFlatBuffer panic into error
+func fbRecover(err *error) {
+	if r := recover(); r != nil {
+		if r != errFlatBuffer {
+			panic(r)
+		}
+		*err = errFlatBuffer
+	}
+}
+
+func (t fbTable) check(pos, size int) {
+	if pos < 0 || size < 0 || pos+size > len(t.buf) || pos+size < pos {
+		panic(errFlatBuffer)
+	}
+}
+
+func (t fbTable) uint16At(pos int) int {
+	t.check(pos, 2)
+	return int(binary.LittleEndian.Uint16(t.buf[pos:]))
+}
+
+func (t fbTable) uint32At(pos int) uint32 {
+	t.check(pos, 4)
+	return binary.LittleEndian.Uint32(t.buf[pos:])
+}
+
+func (t fbTable) indirect(pos int) int {
+	return pos + int(t.uint32At(pos))
+}
+
+// field returns position of the field, or 0 if it's absent
+func (t fbTable) field(i int) int {
+	vtable := t.pos - int(int32(t.uint32At(t.pos)))
+	vsize := t.uint16At(vtable)
+	if 4+2*i >= vsize {
+		return 0
+	}
+	if o := t.uint16At(vtable + 4 + 2*i); o != 0 {
+		return t.pos + o
+	}
+	return 0
+}
+
+func (t fbTable) uint8(i int, def uint8) uint8 {
+	pos := t.field(i)
+	if pos == 0 {
+		return def
+	}
+	t.check(pos, 1)
+	return t.buf[pos]
+}
+
+func (t fbTable) uint16(i int, def uint16) uint16 {
+	if pos := t.field(i); pos != 0 {
+		return uint16(t.uint16At(pos))
+	}
+	return def
+}
+
+func (t fbTable) uint32(i int, def uint32) uint32 {
+	if pos := t.field(i); pos != 0 {
+		return t.uint32At(pos)
+	}
+	return def
+}
+
+func (t fbTable) uint64(i int, def uint64) uint64 {
+	pos := t.field(i)
+	if pos == 0 {
+		return def
+	}
+	t.check(pos, 8)
+	return binary.LittleEndian.Uint64(t.buf[pos:])
+}
+
+// vector returns position of the first element and length of vector field
+func (t fbTable) vector(i, elemSize int) (int, int) {
+	pos := t.field(i)
+	if pos == 0 {
+		return 0, 0
+	}
+	v := t.indirect(pos)
+	n := int(t.uint32At(v))
+	t.check(v+4, n*elemSize)
+	return v + 4, n
+}
+
+func (t fbTable) bytes(i int) []byte {
+	pos, n := t.vector(i, 1)
+	return t.buf[pos : pos+n]
+}
+
+func (t fbTable) string(i int) string {
+	return string(t.bytes(i))
+}
+
+func (t fbTable) doubles(i int) []float64 {
+	pos, n := t.vector(i, 8)
+	result := make([]float64, n)
+	for k := range result {
+		result[k] = math.Float64frombits(binary.LittleEndian.Uint64(t.buf[pos+8*k:]))
+	}
+	return result
+}
+
+func (t fbTable) uint32s(i int) []uint32 {
+	pos, n := t.vector(i, 4)
+	result := make([]uint32, n)
+	for k := range result {
+		result[k] = binary.LittleEndian.Uint32(t.buf[pos+4*k:])
+	}
+	return result
+}
+
+func (t fbTable) table(i int) (fbTable, bool) {
+	pos := t.field(i)
+	if pos == 0 {
+		return fbTable{}, false
+	}
+	return fbTable{buf: t.buf, pos: t.indirect(pos)}, true
+}
+
+func (t fbTable) tables(i int) []fbTable {
+	pos, n := t.vector(i, 4)
+	result := make([]fbTable, n)
+	for k := range result {
+		result[k] = fbTable{buf: t.buf, pos: t.indirect(pos + 4*k)}
+	}
+	return result
+}
@@ -0,0 +1,672 @@
+package cluster
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"sort"
+)
+
+// flatGeobufMagic is the signature of FlatGeobuf version 3 files
+var flatGeobufMagic = []byte{0x66, 0x67, 0x62, 0x03, 0x66, 0x67, 0x62, 0x00}
+
+// flatGeobufMaxFeatureSize limits the size of header and features, so broken files don't allocate too much
+const flatGeobufMaxFeatureSize = 1 << 30
+
+// FlatGeobuf geometry types
+const (
+	fgbUnknown         = 0
+	fgbPoint           = 1
+	fgbLineString      = 2
+	fgbPolygon         = 3
+	fgbMultiPoint      = 4
+	fgbMultiLineString = 5
+	fgbMultiPolygon    = 6
+)
+
+// FlatGeobuf column types
+const (
+	fgbByte     = 0
+	fgbUByte    = 1
+	fgbBool     = 2
+	fgbShort    = 3
+	fgbUShort   = 4
+	fgbInt      = 5
+	fgbUInt     = 6
+	fgbLong     = 7
+	fgbULong    = 8
+	fgbFloat    = 9
+	fgbDouble   = 10
+	fgbString   = 11
+	fgbJSON     = 12
+	fgbDateTime = 13
+	fgbBinary   = 14
+)
+
+// FlatGeobuf table fields
+const (
+	fgbHeaderName          = 0
+	fgbHeaderEnvelope      = 1
+	fgbHeaderGeometryType  = 2
+	fgbHeaderColumns       = 7
+	fgbHeaderFeaturesCount = 8
+	fgbHeaderIndexNodeSize = 9
+	fgbHeaderCrs           = 10
+
+	fgbColumnName = 0
+	fgbColumnType = 1
+	fgbCrsOrg     = 0
+	fgbCrsCode    = 1
+
+	fgbGeometryEnds  = 0
+	fgbGeometryXY    = 1
+	fgbGeometryType  = 6
+	fgbGeometryParts = 7
+
+	fgbFeatureGeometry   = 0
+	fgbFeatureProperties = 1
+	fgbFeatureColumns    = 2
+)
+
+type fgbColumn struct {
+	name string
+	typ  uint8
+}
+
+// FlatGeobufReader reads features of FlatGeobuf file one by one, so huge files are not loaded to memory at once
+// Coordinates are expected to be WGS84 longitude/latitude, CRS of the file is not interpreted.
+type FlatGeobufReader struct {
+	r            *bufio.Reader
+	opts         GeoJSONOptions
+	geometryType uint8
+	columns      []fgbColumn
+	count        uint64
+	headerSize   int
+	nodeSize     int
+	indexSize    uint64
+	next         uint64
+	buf          []byte
+}
+
+// NewFlatGeobufReader reads FlatGeobuf header and skips spatial index
+// opts are the same as for GeoJSON, they define representative points of lines and polygons
+func NewFlatGeobufReader(r io.Reader, opts GeoJSONOptions) (*FlatGeobufReader, error) {
+	fr, err := newFlatGeobufReader(r, opts)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.CopyN(ioutil.Discard, fr.r, int64(fr.indexSize)); err != nil {
+		return nil, fmt.Errorf("gocluster: can't skip FlatGeobuf index: %v", err)
+	}
+	return fr, nil
+}
+
+func newFlatGeobufReader(r io.Reader, opts GeoJSONOptions) (*FlatGeobufReader, error) {
+	fr := &FlatGeobufReader{r: bufio.NewReader(r), opts: opts}
+	magic := make([]byte, len(flatGeobufMagic))
+	if _, err := io.ReadFull(fr.r, magic); err != nil {
+		return nil, fmt.Errorf("gocluster: can't read FlatGeobuf signature: %v", err)
+	}
+	//patch version is not checked
+	if !bytes.Equal(magic[:7], flatGeobufMagic[:7]) {
+		return nil, errors.New("gocluster: not a FlatGeobuf v3 file")
+	}
+	if err := fr.readHeader(); err != nil {
+		return nil, fmt.Errorf("gocluster: can't read FlatGeobuf header: %v", err)
+	}
+	return fr, nil
+}
+
+func (fr *FlatGeobufReader) readHeader() (err error) {
+	data, err := fr.readSizePrefixed()
+	if err != nil {
+		return err
+	}
+	fr.headerSize = len(data)
+
+	defer fbRecover(&err)
+	header := fbRoot(data)
+	fr.geometryType = header.uint8(fgbHeaderGeometryType, fgbUnknown)
+	fr.columns = readFlatGeobufColumns(header.tables(fgbHeaderColumns))
+	fr.count = header.uint64(fgbHeaderFeaturesCount, 0)
+	fr.nodeSize = int(header.uint16(fgbHeaderIndexNodeSize, packedRTreeNodeSize))
+	fr.indexSize = rtreeSize(fr.count, fr.nodeSize)
+	return nil
+}
+
+func readFlatGeobufColumns(tables []fbTable) []fgbColumn {
+	columns := make([]fgbColumn, len(tables))
+	for i, t := range tables {
+		columns[i] = fgbColumn{name: t.string(fgbColumnName), typ: t.uint8(fgbColumnType, fgbByte)}
+	}
+	return columns
+}
+
+// Count returns number of features in the file, as written in the header, 0 could mean unknown
+func (fr *FlatGeobufReader) Count() uint64 {
+	return fr.count
+}
+
+// Next returns points of the next feature, or io.EOF at the end of the file
+// Points are *Feature, as LoadGeoJSON returns, their ID is the index of the feature in the file.
+// Features without geometry are returned as empty slice.
+func (fr *FlatGeobufReader) Next() ([]GeoPoint, error) {
+	data, err := fr.readSizePrefixed()
+	if err == io.EOF {
+		return nil, io.EOF
+	}
+	if err != nil {
+		return nil, fmt.Errorf("gocluster: can't read FlatGeobuf feature %d: %v", fr.next, err)
+	}
+	points, err := fr.decodeFeature(data, fr.next)
+	fr.next++
+	return points, err
+}
+
+// readSizePrefixed reads uint32 size and the data, buffer is reused by next call
+func (fr *FlatGeobufReader) readSizePrefixed() ([]byte, error) {
+	var size [4]byte
+	if _, err := io.ReadFull(fr.r, size[:]); err != nil {
+		return nil, err
+	}
+	n := binary.LittleEndian.Uint32(size[:])
+	if n > flatGeobufMaxFeatureSize {
+		return nil, fmt.Errorf("size %d is too big", n)
+	}
+	if cap(fr.buf) < int(n) {
+		fr.buf = make([]byte, n)
+	}
+	fr.buf = fr.buf[:n]
+	if _, err := io.ReadFull(fr.r, fr.buf); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return fr.buf, nil
+}
+
+func (fr *FlatGeobufReader) decodeFeature(data []byte, index uint64) (points []GeoPoint, err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("gocluster: FlatGeobuf feature %d: %v", index, err)
+		}
+	}()
+	defer fbRecover(&err)
+
+	feature := fbRoot(data)
+	columns := fr.columns
+	if tables := feature.tables(fgbFeatureColumns); len(tables) > 0 {
+		columns = readFlatGeobufColumns(tables)
+	}
+	properties, err := decodeFlatGeobufProperties(feature.bytes(fgbFeatureProperties), columns)
+	if err != nil {
+		return nil, err
+	}
+
+	g, ok := feature.table(fgbFeatureGeometry)
+	if !ok {
+		return []GeoPoint{}, nil
+	}
+	geometry, err := flatGeobufToGeometry(g, fr.geometryType)
+	if err != nil {
+		return nil, err
+	}
+	return featureToPoints(&geoJSONFeature{ID: int(index), Geometry: geometry, Properties: properties}, fr.opts)
+}
+
+// flatGeobufToGeometry converts geometry to GeoJSON, so the same representative points are used
+func flatGeobufToGeometry(g fbTable, geometryType uint8) (*Geometry, error) {
+	if geometryType == fgbUnknown {
+		geometryType = g.uint8(fgbGeometryType, fgbUnknown)
+	}
+	var (
+		typ         string
+		coordinates interface{}
+	)
+	switch geometryType {
+	case fgbPoint:
+		positions := flatGeobufPositions(g.doubles(fgbGeometryXY))
+		if len(positions) == 0 {
+			return nil, errors.New("empty Point geometry")
+		}
+		typ, coordinates = "Point", positions[0]
+	case fgbMultiPoint:
+		typ, coordinates = "MultiPoint", flatGeobufPositions(g.doubles(fgbGeometryXY))
+	case fgbLineString:
+		typ, coordinates = "LineString", flatGeobufPositions(g.doubles(fgbGeometryXY))
+	case fgbMultiLineString:
+		typ, coordinates = "MultiLineString", flatGeobufParts(g)
+	case fgbPolygon:
+		typ, coordinates = "Polygon", flatGeobufParts(g)
+	case fgbMultiPolygon:
+		parts := g.tables(fgbGeometryParts)
+		polygons := make([][][]position, len(parts))
+		for i, part := range parts {
+			polygons[i] = flatGeobufParts(part)
+		}
+		typ, coordinates = "MultiPolygon", polygons
+	default:
+		return nil, fmt.Errorf("unsupported geometry type %d", geometryType)
+	}
+	data, err := json.Marshal(coordinates)
+	if err != nil {
+		return nil, err
+	}
+	return &Geometry{Type: typ, Coordinates: data}, nil
+}
+
+func flatGeobufPositions(xy []float64) []position {
+	result := make([]position, len(xy)/2)
+	for i := range result {
+		result[i] = position{xy[2*i], xy[2*i+1]}
+	}
+	return result
+}
+
+// flatGeobufParts splits positions into lines or rings by ends, which are indexes of positions after each part
+func flatGeobufParts(g fbTable) [][]position {
+	positions := flatGeobufPositions(g.doubles(fgbGeometryXY))
+	ends := g.uint32s(fgbGeometryEnds)
+	if len(ends) == 0 {
+		return [][]position{positions}
+	}
+	result := make([][]position, 0, len(ends))
+	start := 0
+	for _, end := range ends {
+		if int(end) < start || int(end) > len(positions) {
+			panic(errFlatBuffer)
+		}
+		result = append(result, positions[start:end])
+		start = int(end)
+	}
+	return result
+}
+
+func decodeFlatGeobufProperties(data []byte, columns []fgbColumn) (map[string]interface{}, error) {
+	properties := map[string]interface{}{}
+	for len(data) > 0 {
+		if len(data) < 2 {
+			return nil, errors.New("truncated properties")
+		}
+		i := int(binary.LittleEndian.Uint16(data))
+		data = data[2:]
+		if i >= len(columns) {
+			return nil, fmt.Errorf("column %d is out of range", i)
+		}
+		column := columns[i]
+		size := 0
+		switch column.typ {
+		case fgbByte, fgbUByte, fgbBool:
+			size = 1
+		case fgbShort, fgbUShort:
+			size = 2
+		case fgbInt, fgbUInt, fgbFloat:
+			size = 4
+		case fgbLong, fgbULong, fgbDouble:
+			size = 8
+		case fgbString, fgbJSON, fgbDateTime, fgbBinary:
+			if len(data) < 4 {
+				return nil, errors.New("truncated properties")
+			}
+			size = 4 + int(binary.LittleEndian.Uint32(data))
+		default:
+			return nil, fmt.Errorf("unsupported column type %d", column.typ)
+		}
+		if size < 0 || len(data) < size {
+			return nil, errors.New("truncated properties")
+		}
+		v, err := decodeFlatGeobufValue(data[:size], column.typ)
+		if err != nil {
+			return nil, fmt.Errorf("column %q: %v", column.name, err)
+		}
+		properties[column.name] = v
+		data = data[size:]
+	}
+	return properties, nil
+}
+
+// decodeFlatGeobufValue returns integers as int64 or uint64, floats as float64 and Json columns decoded
+func decodeFlatGeobufValue(data []byte, typ uint8) (interface{}, error) {
+	switch typ {
+	case fgbByte:
+		return int64(int8(data[0])), nil
+	case fgbUByte:
+		return uint64(data[0]), nil
+	case fgbBool:
+		return data[0] != 0, nil
+	case fgbShort:
+		return int64(int16(binary.LittleEndian.Uint16(data))), nil
+	case fgbUShort:
+		return uint64(binary.LittleEndian.Uint16(data)), nil
+	case fgbInt:
+		return int64(int32(binary.LittleEndian.Uint32(data))), nil
+	case fgbUInt:
+		return uint64(binary.LittleEndian.Uint32(data)), nil
+	case fgbLong:
+		return int64(binary.LittleEndian.Uint64(data)), nil
+	case fgbULong:
+		return binary.LittleEndian.Uint64(data), nil
+	case fgbFloat:
+		return float64(math.Float32frombits(binary.LittleEndian.Uint32(data))), nil
+	case fgbDouble:
+		return math.Float64frombits(binary.LittleEndian.Uint64(data)), nil
+	case fgbJSON:
+		var v interface{}
+		if err := json.Unmarshal(data[4:], &v); err != nil {
+			return nil, err
+		}
+		return v, nil
+	case fgbBinary:
+		return append([]byte(nil), data[4:]...), nil
+	default:
+		return string(data[4:]), nil
+	}
+}
+
+// LoadFlatGeobuf reads all features of FlatGeobuf file, as LoadGeoJSON does
+func LoadFlatGeobuf(r io.Reader) ([]GeoPoint, error) {
+	fr, err := NewFlatGeobufReader(r, GeoJSONOptions{})
+	if err != nil {
+		return nil, err
+	}
+	result := make([]GeoPoint, 0, minInt(int(fr.count), 1<<20))
+	for {
+		points, err := fr.Next()
+		if err == io.EOF {
+			return result, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, points...)
+	}
+}
+
+// LoadFlatGeobufBBox reads features which bounding boxes intersect the box between northWest and southEast corners
+// When the file has spatial index, only matching features are read, otherwise all features are read
+// and points outside of the box are skipped.
+func LoadFlatGeobufBBox(r io.ReadSeeker, northWest, southEast GeoCoordinates, opts GeoJSONOptions) ([]GeoPoint, error) {
+	start, err := r.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, err
+	}
+	fr, err := newFlatGeobufReader(r, opts)
+	if err != nil {
+		return nil, err
+	}
+	minX, minY, maxX, maxY := northWest.Lon, southEast.Lat, southEast.Lon, northWest.Lat
+
+	if fr.indexSize == 0 {
+		var result []GeoPoint
+		for {
+			points, err := fr.Next()
+			if err == io.EOF {
+				return result, nil
+			}
+			if err != nil {
+				return nil, err
+			}
+			for _, p := range points {
+				if c := p.GetCoordinates(); c.Lon >= minX && c.Lon <= maxX && c.Lat >= minY && c.Lat <= maxY {
+					result = append(result, p)
+				}
+			}
+		}
+	}
+
+	//header is read with buffered reader, so position is calculated from the header size
+	indexStart := start + int64(len(flatGeobufMagic)) + 4 + int64(fr.headerSize)
+	if _, err := r.Seek(indexStart, io.SeekStart); err != nil {
+		return nil, err
+	}
+	matches, err := searchRTree(r, fr.count, fr.nodeSize, minX, minY, maxX, maxY)
+	if err != nil {
+		return nil, fmt.Errorf("gocluster: can't search FlatGeobuf index: %v", err)
+	}
+	featuresStart := indexStart + int64(fr.indexSize)
+	var result []GeoPoint
+	for _, m := range matches {
+		if _, err := r.Seek(featuresStart+int64(m.offset), io.SeekStart); err != nil {
+			return nil, err
+		}
+		fr.r.Reset(r)
+		data, err := fr.readSizePrefixed()
+		if err != nil {
+			return nil, fmt.Errorf("gocluster: can't read FlatGeobuf feature %d: %v", m.index, err)
+		}
+		points, err := fr.decodeFeature(data, m.index)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, points...)
+	}
+	return result, nil
+}
+
+// WriteFlatGeobuf writes clustered points, as they returned by AllClusters, to FlatGeobuf file with spatial index
+// Points are written in Hilbert order, properties are the same as in MarshalGeoJSON.
+// Column types are inferred from values: integers are Long, other numbers are Double,
+// strings and booleans keep their type, and all other values are written as Json.
+func WriteFlatGeobuf(w io.Writer, points []ClusterPoint, name string) error {
+	properties := make([]map[string]interface{}, len(points))
+	for i := range points {
+		properties[i] = ClusterProperties(&points[i])
+	}
+	columns, columnIdx := flatGeobufColumns(properties)
+
+	envelope := emptyRTreeNode()
+	for i := range points {
+		envelope.expand(&rtreeNode{minX: points[i].X, minY: points[i].Y, maxX: points[i].X, maxY: points[i].Y})
+	}
+	order := make([]int, len(points))
+	hilbertValues := make([]uint32, len(points))
+	for i := range points {
+		order[i] = i
+		hilbertValues[i] = hilbert(
+			hilbertCoordinate(points[i].X, envelope.minX, envelope.maxX),
+			hilbertCoordinate(points[i].Y, envelope.minY, envelope.maxY),
+		)
+	}
+	sort.SliceStable(order, func(i, j int) bool { return hilbertValues[order[i]] < hilbertValues[order[j]] })
+
+	features := make([][]byte, len(points))
+	leaves := make([]rtreeNode, len(points))
+	var offset uint64
+	for k, i := range order {
+		p := &points[i]
+		props, err := encodeFlatGeobufProperties(properties[i], columns, columnIdx)
+		if err != nil {
+			return err
+		}
+		var b fbBuilder
+		x, y := p.X, p.Y
+		geometry := fbRef(func(b *fbBuilder) int {
+			return b.table([]fbField{fgbGeometryXY: fbRef(func(b *fbBuilder) int { return b.doublesVector([]float64{x, y}) })})
+		})
+		fields := []fbField{fgbFeatureGeometry: geometry, fgbFeatureProperties: {}}
+		if len(props) > 0 {
+			fields[fgbFeatureProperties] = fbRef(func(b *fbBuilder) int { return b.bytesVector(props, false) })
+		}
+		features[k] = b.finish(fields)
+		leaves[k] = rtreeNode{minX: x, minY: y, maxX: x, maxY: y, offset: offset}
+		offset += 4 + uint64(len(features[k]))
+	}
+
+	header := make([]fbField, fgbHeaderCrs+1)
+	header[fgbHeaderName] = fbString(name)
+	if len(points) > 0 {
+		env := []float64{envelope.minX, envelope.minY, envelope.maxX, envelope.maxY}
+		header[fgbHeaderEnvelope] = fbRef(func(b *fbBuilder) int { return b.doublesVector(env) })
+	}
+	header[fgbHeaderGeometryType] = fbUint8(fgbPoint)
+	if len(columns) > 0 {
+		header[fgbHeaderColumns] = fbRef(func(b *fbBuilder) int {
+			tables := make([][]fbField, len(columns))
+			for i, c := range columns {
+				tables[i] = []fbField{fgbColumnName: fbString(c.name), fgbColumnType: fbUint8(c.typ)}
+			}
+			return b.tablesVector(tables)
+		})
+	}
+	header[fgbHeaderFeaturesCount] = fbUint64(uint64(len(points)))
+	header[fgbHeaderIndexNodeSize] = fbUint16(packedRTreeNodeSize)
+	header[fgbHeaderCrs] = fbRef(func(b *fbBuilder) int {
+		return b.table([]fbField{fgbCrsOrg: fbString("EPSG"), fgbCrsCode: fbInt32(4326)})
+	})
+	var hb fbBuilder
+	headerData := hb.finish(header)
+
+	bw := bufio.NewWriter(w)
+	bw.Write(flatGeobufMagic)
+	writeSizePrefixed(bw, headerData)
+	if len(points) > 0 {
+		if err := writeRTree(bw, buildRTree(leaves, packedRTreeNodeSize)); err != nil {
+			return err
+		}
+	}
+	for _, f := range features {
+		writeSizePrefixed(bw, f)
+	}
+	return bw.Flush()
+}
+
+func writeSizePrefixed(w *bufio.Writer, data []byte) {
+	var size [4]byte
+	binary.LittleEndian.PutUint32(size[:], uint32(len(data)))
+	w.Write(size[:])
+	w.Write(data)
+}
+
+// hilbertCoordinate scales v from min..max range to 16 bits
+func hilbertCoordinate(v, min, max float64) uint32 {
+	if max <= min {
+		return 0
+	}
+	return uint32(math.Floor(65535 * (v - min) / (max - min)))
+}
+
+// flatGeobufColumns returns sorted columns of all properties with their inferred types
+func flatGeobufColumns(properties []map[string]interface{}) ([]fgbColumn, map[string]int) {
+	const (
+		kindInt = 1 << iota
+		kindFloat
+		kindString
+		kindBool
+		kindOther
+	)
+	kinds := map[string]int{}
+	for _, props := range properties {
+		for k, v := range props {
+			kind := kindOther
+			switch t := v.(type) {
+			case nil:
+				kind = 0
+			case int, int32, int64, uint, uint32, uint64:
+				kind = kindInt
+			case float64:
+				kind = kindFloat
+				if t == math.Trunc(t) && math.Abs(t) < 1<<53 {
+					//numbers of decoded GeoJSON
+					kind = kindInt
+				}
+			case float32:
+				kind = kindFloat
+			case string:
+				kind = kindString
+			case bool:
+				kind = kindBool
+			}
+			kinds[k] |= kind
+		}
+	}
+
+	names := make([]string, 0, len(kinds))
+	for k := range kinds {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	columns := make([]fgbColumn, len(names))
+	columnIdx := make(map[string]int, len(names))
+	for i, k := range names {
+		typ := uint8(fgbJSON)
+		switch kinds[k] {
+		case kindInt:
+			typ = fgbLong
+		case kindFloat, kindInt | kindFloat:
+			typ = fgbDouble
+		case kindString:
+			typ = fgbString
+		case kindBool:
+			typ = fgbBool
+		}
+		columns[i] = fgbColumn{name: k, typ: typ}
+		columnIdx[k] = i
+	}
+	return columns, columnIdx
+}
+
+func encodeFlatGeobufProperties(properties map[string]interface{}, columns []fgbColumn, columnIdx map[string]int) ([]byte, error) {
+	keys := make([]string, 0, len(properties))
+	for k, v := range properties {
+		if v != nil {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	var buf []byte
+	var scalar [8]byte
+	for _, k := range keys {
+		i := columnIdx[k]
+		v := properties[k]
+		binary.LittleEndian.PutUint16(scalar[:], uint16(i))
+		buf = append(buf, scalar[:2]...)
+		switch columns[i].typ {
+		case fgbLong:
+			n, _ := toFloat(v)
+			switch t := v.(type) {
+			case int:
+				binary.LittleEndian.PutUint64(scalar[:], uint64(t))
+			case int64:
+				binary.LittleEndian.PutUint64(scalar[:], uint64(t))
+			case uint64:
+				binary.LittleEndian.PutUint64(scalar[:], t)
+			default:
+				binary.LittleEndian.PutUint64(scalar[:], uint64(int64(n)))
+			}
+			buf = append(buf, scalar[:]...)
+		case fgbDouble:
+			n, _ := toFloat(v)
+			binary.LittleEndian.PutUint64(scalar[:], math.Float64bits(n))
+			buf = append(buf, scalar[:]...)
+		case fgbBool:
+			if v.(bool) {
+				buf = append(buf, 1)
+			} else {
+				buf = append(buf, 0)
+			}
+		case fgbString:
+			buf = appendFlatGeobufBytes(buf, []byte(v.(string)))
+		default:
+			data, err := json.Marshal(v)
+			if err != nil {
+				return nil, fmt.Errorf("gocluster: property %q: %v", k, err)
+			}
+			buf = appendFlatGeobufBytes(buf, data)
+		}
+	}
+	return buf, nil
+}
+
+func appendFlatGeobufBytes(buf, data []byte) []byte {
+	var size [4]byte
+	binary.LittleEndian.PutUint32(size[:], uint32(len(data)))
+	return append(append(buf, size[:]...), data...)
+}
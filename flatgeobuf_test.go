@@ -0,0 +1,85 @@
+package cluster
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func TestFlatGeobufRoundTrip(t *testing.T) {
+	c, err := NewClusterForZoom(3, 256, 40)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.ClusterPoints(loadPlaces(t)); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := WriteFlatGeobuf(&buf, c.AllClusters(), "clusters"); err != nil {
+		t.Fatal(err)
+	}
+	points, err := LoadFlatGeobuf(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(points) != len(c.ResultPoints) {
+		t.Fatalf("read %d features, want %d", len(points), len(c.ResultPoints))
+	}
+
+	//features are written in Hilbert order, so they are matched by coordinates
+	want := map[GeoCoordinates]*ClusterPoint{}
+	for i := range c.ResultPoints {
+		cp := &c.ResultPoints[i]
+		want[GeoCoordinates{Lon: cp.X, Lat: cp.Y}] = cp
+	}
+	for _, p := range points {
+		f := p.(*Feature)
+		cp, ok := want[f.Coordinates]
+		if !ok {
+			t.Fatalf("feature at %v is not written", f.Coordinates)
+		}
+		if cp.NumPoints == 1 {
+			continue
+		}
+		if count, _ := f.Properties["point_count"].(int64); int(count) != cp.NumPoints {
+			t.Fatalf("cluster %d has point_count %v, want %d", cp.Id, f.Properties["point_count"], cp.NumPoints)
+		}
+		if id, _ := f.Properties["cluster_id"].(int64); int(id) != cp.Id {
+			t.Fatalf("cluster has cluster_id %v, want %d", f.Properties["cluster_id"], cp.Id)
+		}
+	}
+
+	//the spatial index finds features of the box only
+	northWest, southEast := GeoCoordinates{Lon: -30, Lat: 60}, GeoCoordinates{Lon: 40, Lat: 30}
+	inBox, err := LoadFlatGeobufBBox(bytes.NewReader(buf.Bytes()), northWest, southEast, GeoJSONOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inBox) != len(c.GetClusters(northWest, southEast)) {
+		t.Fatalf("read %d features in the box, want %d", len(inBox), len(c.GetClusters(northWest, southEast)))
+	}
+}
+
+func TestFlatGeobufBrokenIndex(t *testing.T) {
+	var buf bytes.Buffer
+	points := make([]ClusterPoint, 1000)
+	for i, p := range randomPoints(len(points), 6, -50, -50, 50, 50) {
+		c := p.GetCoordinates()
+		points[i] = ClusterPoint{X: c.Lon, Y: c.Lat, Id: i, NumPoints: 1}
+	}
+	if err := WriteFlatGeobuf(&buf, points, "points"); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	indexStart := len(flatGeobufMagic) + 4 + int(binary.LittleEndian.Uint32(data[len(flatGeobufMagic):]))
+	northWest, southEast := GeoCoordinates{Lon: -180, Lat: 90}, GeoCoordinates{Lon: 180, Lat: -90}
+
+	//offset of the first child of the root points out of the file, then to the root itself
+	for _, offset := range []uint64{1 << 40, 0} {
+		broken := append([]byte(nil), data...)
+		binary.LittleEndian.PutUint64(broken[indexStart+32:], offset)
+		if _, err := LoadFlatGeobufBBox(bytes.NewReader(broken), northWest, southEast, GeoJSONOptions{}); err == nil {
+			t.Errorf("child offset %d: expected error of broken index", offset)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package cluster
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// GeobufPrecision is the default number of decimal digits of Geobuf coordinates, about 10cm
+const GeobufPrecision = 6
+
+// Geobuf protobuf fields, see geobuf.proto of mapbox/geobuf
+const (
+	geobufDataKeys              = 1
+	geobufDataPrecision         = 3
+	geobufDataFeatureCollection = 4
+
+	geobufCollectionFeatures = 1
+
+	geobufFeatureGeometry   = 1
+	geobufFeatureID         = 11
+	geobufFeatureIntID      = 12
+	geobufFeatureValues     = 13
+	geobufFeatureProperties = 14
+
+	geobufGeometryType   = 1
+	geobufGeometryCoords = 3
+
+	geobufValueString = 1
+	geobufValueDouble = 2
+	geobufValuePosInt = 3
+	geobufValueNegInt = 4
+	geobufValueBool   = 5
+	geobufValueJSON   = 6
+
+	geobufGeomPoint = 0
+)
+
+// EncodeGeobuf encodes clustered points, as they returned by AllClusters, to Geobuf FeatureCollection
+// Geobuf is compact protobuf encoding of GeoJSON, it's decoded by geobuf.decode of mapbox/geobuf.
+// Properties are the same as in MarshalGeoJSON, values other than strings, numbers and booleans are encoded as JSON.
+// precision is the number of decimal digits of coordinates, GeobufPrecision is a good default.
+func EncodeGeobuf(points []ClusterPoint, precision int) ([]byte, error) {
+	if precision < 0 || precision > 12 {
+		return nil, errors.New("gocluster: Geobuf precision should be between 0 and 12")
+	}
+	e := &geobufEncoder{keyIdx: map[string]uint32{}, e: math.Pow10(precision)}
+	var collection protoBuffer
+	for i := range points {
+		f, err := e.feature(&points[i])
+		if err != nil {
+			return nil, err
+		}
+		collection.message(geobufCollectionFeatures, f)
+	}
+
+	var data protoBuffer
+	for _, k := range e.keys {
+		data.string(geobufDataKeys, k)
+	}
+	if precision != GeobufPrecision {
+		data.uint(geobufDataPrecision, uint64(precision))
+	}
+	data.message(geobufDataFeatureCollection, &collection)
+	return data.buf, nil
+}
+
+type geobufEncoder struct {
+	keys   []string
+	keyIdx map[string]uint32
+	e      float64
+}
+
+func (e *geobufEncoder) feature(p *ClusterPoint) (*protoBuffer, error) {
+	var geometry protoBuffer
+	geometry.uint(geobufGeometryType, geobufGeomPoint)
+	geometry.packedSint64(geobufGeometryCoords, []int64{
+		int64(math.Round(p.X * e.e)),
+		int64(math.Round(p.Y * e.e)),
+	})
+
+	var f protoBuffer
+	f.message(geobufFeatureGeometry, &geometry)
+	switch id := clusterFeatureID(p).(type) {
+	case nil:
+	case int:
+		f.uint(geobufFeatureIntID, zigzag(int64(id)))
+	case float64:
+		//numeric ids of decoded GeoJSON
+		if id == math.Trunc(id) && math.Abs(id) < 1<<53 {
+			f.uint(geobufFeatureIntID, zigzag(int64(id)))
+		} else {
+			f.string(geobufFeatureID, fmt.Sprint(id))
+		}
+	case string:
+		f.string(geobufFeatureID, id)
+	default:
+		f.string(geobufFeatureID, fmt.Sprint(id))
+	}
+
+	properties := ClusterProperties(p)
+	keys := make([]string, 0, len(properties))
+	for k := range properties {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]uint32, 0, 2*len(keys))
+	for i, k := range keys {
+		v, err := geobufValue(properties[k])
+		if err != nil {
+			return nil, err
+		}
+		f.message(geobufFeatureValues, v)
+		pairs = append(pairs, e.key(k), uint32(i))
+	}
+	if len(pairs) > 0 {
+		f.packedUint32(geobufFeatureProperties, pairs)
+	}
+	return &f, nil
+}
+
+func (e *geobufEncoder) key(k string) uint32 {
+	if i, ok := e.keyIdx[k]; ok {
+		return i
+	}
+	i := uint32(len(e.keys))
+	e.keys = append(e.keys, k)
+	e.keyIdx[k] = i
+	return i
+}
+
+// geobufValue encodes integers, including integral floats as geobuf.js does, as unsigned value with sign in the field
+func geobufValue(v interface{}) (*protoBuffer, error) {
+	var b protoBuffer
+	switch t := v.(type) {
+	case string:
+		b.string(geobufValueString, t)
+	case bool:
+		b.bool(geobufValueBool, t)
+	case int:
+		geobufInt(&b, int64(t))
+	case int32:
+		geobufInt(&b, int64(t))
+	case int64:
+		geobufInt(&b, t)
+	case uint:
+		b.uint(geobufValuePosInt, uint64(t))
+	case uint32:
+		b.uint(geobufValuePosInt, uint64(t))
+	case uint64:
+		b.uint(geobufValuePosInt, t)
+	case float32:
+		geobufFloat(&b, float64(t))
+	case float64:
+		geobufFloat(&b, t)
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		b.bytes(geobufValueJSON, data)
+	}
+	return &b, nil
+}
+
+func geobufInt(b *protoBuffer, v int64) {
+	if v < 0 {
+		b.uint(geobufValueNegInt, uint64(-v))
+		return
+	}
+	b.uint(geobufValuePosInt, uint64(v))
+}
+
+func geobufFloat(b *protoBuffer, v float64) {
+	if v == math.Trunc(v) && math.Abs(v) < 1<<53 {
+		geobufInt(b, int64(v))
+		return
+	}
+	b.double(geobufValueDouble, v)
+}
@@ -0,0 +1,188 @@
+package cluster
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"testing"
+)
+
+type testGeobufFeature struct {
+	lon, lat   float64
+	id         interface{}
+	properties map[string]interface{}
+}
+
+// decodeGeobuf decodes point features of Geobuf FeatureCollection, like geobuf.decode of mapbox/geobuf
+func decodeGeobuf(t testing.TB, data []byte) []testGeobufFeature {
+	t.Helper()
+	var keys []string
+	var features []protoField
+	precision := uint64(GeobufPrecision)
+	for _, f := range decodeProto(t, data) {
+		switch f.field {
+		case geobufDataKeys:
+			keys = append(keys, string(f.data))
+		case geobufDataPrecision:
+			precision = f.value
+		case geobufDataFeatureCollection:
+			for _, cf := range decodeProto(t, f.data) {
+				if cf.field == geobufCollectionFeatures {
+					features = append(features, cf)
+				}
+			}
+		}
+	}
+	e := math.Pow10(int(precision))
+
+	var result []testGeobufFeature
+	for _, f := range features {
+		feature := testGeobufFeature{properties: map[string]interface{}{}}
+		var values []interface{}
+		var pairs []uint64
+		for _, ff := range decodeProto(t, f.data) {
+			switch ff.field {
+			case geobufFeatureGeometry:
+				for _, gf := range decodeProto(t, ff.data) {
+					switch gf.field {
+					case geobufGeometryType:
+						if gf.value != geobufGeomPoint {
+							t.Fatalf("geometry type %d is not point", gf.value)
+						}
+					case geobufGeometryCoords:
+						coords := decodeVarints(t, gf.data)
+						if len(coords) != 2 {
+							t.Fatalf("point has %d coordinates", len(coords))
+						}
+						feature.lon, feature.lat = float64(unzigzag(coords[0]))/e, float64(unzigzag(coords[1]))/e
+					}
+				}
+			case geobufFeatureID:
+				feature.id = string(ff.data)
+			case geobufFeatureIntID:
+				feature.id = unzigzag(ff.value)
+			case geobufFeatureValues:
+				values = append(values, decodeGeobufValue(t, ff.data))
+			case geobufFeatureProperties:
+				pairs = decodeVarints(t, ff.data)
+			}
+		}
+		for i := 0; i+1 < len(pairs); i += 2 {
+			if pairs[i] >= uint64(len(keys)) || pairs[i+1] >= uint64(len(values)) {
+				t.Fatalf("properties %v refer missing keys or values", pairs)
+			}
+			feature.properties[keys[pairs[i]]] = values[pairs[i+1]]
+		}
+		result = append(result, feature)
+	}
+	return result
+}
+
+func decodeGeobufValue(t testing.TB, data []byte) interface{} {
+	t.Helper()
+	fields := decodeProto(t, data)
+	if len(fields) != 1 {
+		t.Fatalf("value has %d fields", len(fields))
+	}
+	switch f := fields[0]; f.field {
+	case geobufValueString:
+		return string(f.data)
+	case geobufValueDouble:
+		return math.Float64frombits(f.value)
+	case geobufValuePosInt:
+		return float64(f.value)
+	case geobufValueNegInt:
+		return -float64(f.value)
+	case geobufValueBool:
+		return f.value != 0
+	case geobufValueJSON:
+		var v interface{}
+		if err := json.Unmarshal(f.data, &v); err != nil {
+			t.Fatal(err)
+		}
+		return v
+	default:
+		t.Fatalf("unexpected value field %d", f.field)
+	}
+	return nil
+}
+
+func TestEncodeGeobuf(t *testing.T) {
+	c, err := NewClusterForZoom(3, 256, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.ClusterPoints(randomPoints(300, 8, -40, -40, 40, 40)); err != nil {
+		t.Fatal(err)
+	}
+	points := c.AllClusters()
+	for _, precision := range []int{GeobufPrecision, 2} {
+		data, err := EncodeGeobuf(points, precision)
+		if err != nil {
+			t.Fatal(err)
+		}
+		features := decodeGeobuf(t, data)
+		if len(features) != len(points) {
+			t.Fatalf("precision %d: %d features, want %d", precision, len(features), len(points))
+		}
+		e := math.Pow10(precision)
+		clusters := 0
+		for i, f := range features {
+			p := &points[i]
+			if f.lon != math.Round(p.X*e)/e || f.lat != math.Round(p.Y*e)/e {
+				t.Fatalf("precision %d: feature %d is at %v,%v, want %v,%v", precision, i, f.lon, f.lat, p.X, p.Y)
+			}
+			id, _ := clusterFeatureID(p).(int)
+			if f.id != int64(id) {
+				t.Fatalf("feature %d has id %v, want %d", i, f.id, id)
+			}
+			if p.NumPoints > 1 {
+				clusters++
+				if f.properties["point_count"] != float64(p.NumPoints) || f.properties["cluster"] != true {
+					t.Fatalf("cluster %d has properties %v", p.Id, f.properties)
+				}
+			} else if f.properties["n"] != float64(id) {
+				t.Fatalf("point %d has properties %v", id, f.properties)
+			}
+		}
+		if clusters == 0 || clusters == len(points) {
+			t.Fatalf("%d clusters of %d points, want both clusters and single points", clusters, len(points))
+		}
+	}
+}
+
+func TestEncodeGeobufValues(t *testing.T) {
+	properties := map[string]interface{}{
+		"name":     "a",
+		"negative": -3,
+		"ratio":    0.25,
+		"open":     false,
+		"tags":     []interface{}{"x", "y"},
+	}
+	points := []ClusterPoint{
+		{X: 1, Y: 2, Id: 0, NumPoints: 1, IncludedPoints: []GeoPoint{&Feature{ID: "first"}}, Properties: properties},
+		{X: 3, Y: 4, Id: 1, NumPoints: 1, IncludedPoints: []GeoPoint{&Feature{ID: 2.5}}},
+	}
+	data, err := EncodeGeobuf(points, GeobufPrecision)
+	if err != nil {
+		t.Fatal(err)
+	}
+	features := decodeGeobuf(t, data)
+	if len(features) != 2 || features[0].id != "first" || features[1].id != "2.5" {
+		t.Fatalf("features %v, want ids first and 2.5", features)
+	}
+	want := map[string]interface{}{
+		"name":     "a",
+		"negative": -3.0,
+		"ratio":    0.25,
+		"open":     false,
+		"tags":     []interface{}{"x", "y"},
+	}
+	if !reflect.DeepEqual(features[0].properties, want) {
+		t.Fatalf("properties %v, want %v", features[0].properties, want)
+	}
+
+	if _, err := EncodeGeobuf(points, 13); err == nil {
+		t.Fatal("expected error of precision 13")
+	}
+}
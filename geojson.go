@@ -0,0 +1,345 @@
+package cluster
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Feature is a GeoPoint loaded from GeoJSON
+// It keeps id and properties of the source feature, so you could get them back from IncludedPoints
+// Lines and polygons are clustered by their representative point, the source geometry is kept in Geometry
+type Feature struct {
+	ID          interface{}
+	Coordinates GeoCoordinates
+	Properties  map[string]interface{}
+	Geometry    *Geometry `json:",omitempty"`
+}
+
+// GetCoordinates implements GeoPoint interface
+func (f *Feature) GetCoordinates() GeoCoordinates {
+	return f.Coordinates
+}
+
+// GetID implements GeoPointWithID interface
+func (f *Feature) GetID() interface{} {
+	return f.ID
+}
+
+// GetProperties implements GeoPointWithProperties interface
+func (f *Feature) GetProperties() map[string]interface{} {
+	return f.Properties
+}
+
+// Geometry is the source GeoJSON geometry of the feature, coordinates are kept as is
+type Geometry struct {
+	Type        string          `json:"type"`
+	Coordinates json.RawMessage `json:"coordinates"`
+}
+
+// GeoJSONOptions configures LoadGeoJSONWithOptions
+// Representative - how LineString, Polygon and their Multi variants are turned into points
+type GeoJSONOptions struct {
+	Representative Representative
+}
+
+type geoJSONFeature struct {
+	Type       string                 `json:"type"`
+	ID         interface{}            `json:"id,omitempty"`
+	Geometry   *Geometry              `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+type geoJSONObject struct {
+	Type     string            `json:"type"`
+	Features []*geoJSONFeature `json:"features"`
+	geoJSONFeature
+}
+
+// LoadGeoJSON reads GeoJSON FeatureCollection (or single Feature) from the reader
+// and returns one GeoPoint for each Point and for each position of MultiPoint geometry.
+// LineString, Polygon and their Multi variants are represented by their centroid.
+// All returned points are *Feature, properties of MultiPoint feature are shared between its points.
+// Features without geometry are skipped, GeometryCollection is reported as error.
+func LoadGeoJSON(r io.Reader) ([]GeoPoint, error) {
+	return LoadGeoJSONWithOptions(r, GeoJSONOptions{})
+}
+
+// LoadGeoJSONWithOptions is the same as LoadGeoJSON, but representative point of lines and polygons is configurable
+func LoadGeoJSONWithOptions(r io.Reader, opts GeoJSONOptions) ([]GeoPoint, error) {
+	var obj geoJSONObject
+	if err := json.NewDecoder(r).Decode(&obj); err != nil {
+		return nil, fmt.Errorf("gocluster: can't decode GeoJSON: %v", err)
+	}
+
+	var features []*geoJSONFeature
+	switch obj.Type {
+	case "FeatureCollection":
+		features = obj.Features
+	case "Feature":
+		obj.geoJSONFeature.Type = obj.Type
+		features = []*geoJSONFeature{&obj.geoJSONFeature}
+	default:
+		return nil, fmt.Errorf("gocluster: unsupported GeoJSON object type %q", obj.Type)
+	}
+
+	result := make([]GeoPoint, 0, len(features))
+	for i, f := range features {
+		points, err := featureToPoints(f, opts)
+		if err != nil {
+			return nil, fmt.Errorf("gocluster: feature %d: %v", i, err)
+		}
+		result = append(result, points...)
+	}
+	return result, nil
+}
+
+func featureToPoints(f *geoJSONFeature, opts GeoJSONOptions) ([]GeoPoint, error) {
+	if f == nil || f.Geometry == nil {
+		return nil, nil
+	}
+	switch f.Geometry.Type {
+	case "Point":
+		var position []float64
+		if err := json.Unmarshal(f.Geometry.Coordinates, &position); err != nil {
+			return nil, err
+		}
+		c, err := positionToCoordinates(position)
+		if err != nil {
+			return nil, err
+		}
+		return []GeoPoint{&Feature{ID: f.ID, Coordinates: c, Properties: f.Properties}}, nil
+	case "MultiPoint":
+		var positions [][]float64
+		if err := json.Unmarshal(f.Geometry.Coordinates, &positions); err != nil {
+			return nil, err
+		}
+		result := make([]GeoPoint, len(positions))
+		for i := range positions {
+			c, err := positionToCoordinates(positions[i])
+			if err != nil {
+				return nil, err
+			}
+			result[i] = &Feature{ID: f.ID, Coordinates: c, Properties: f.Properties}
+		}
+		return result, nil
+	case "LineString", "MultiLineString", "Polygon", "MultiPolygon":
+		c, err := representativePoint(f.Geometry, opts.Representative)
+		if err != nil {
+			return nil, err
+		}
+		return []GeoPoint{&Feature{ID: f.ID, Coordinates: c, Properties: f.Properties, Geometry: f.Geometry}}, nil
+	default:
+		return nil, fmt.Errorf("unsupported geometry type %q", f.Geometry.Type)
+	}
+}
+
+func representativePoint(g *Geometry, rep Representative) (GeoCoordinates, error) {
+	var (
+		c  GeoCoordinates
+		ok bool
+	)
+	switch g.Type {
+	case "LineString", "MultiLineString":
+		var lines [][]position
+		if err := unmarshalNested(g, &lines); err != nil {
+			return c, err
+		}
+		if err := validatePositions(lines...); err != nil {
+			return c, err
+		}
+		c, ok = lineCentroid(lines)
+	case "Polygon", "MultiPolygon":
+		var polygons [][][]position
+		if err := unmarshalNested(g, &polygons); err != nil {
+			return c, err
+		}
+		for _, polygon := range polygons {
+			if err := validatePositions(polygon...); err != nil {
+				return c, err
+			}
+		}
+		if rep == RepresentativePoleOfInaccessibility {
+			c, ok = polygonsPole(polygons)
+		} else {
+			c, ok = polygonsCentroid(polygons)
+		}
+	}
+	if !ok {
+		return c, fmt.Errorf("empty %s geometry", g.Type)
+	}
+	return c, nil
+}
+
+func validatePositions(lines ...[]position) error {
+	for _, line := range lines {
+		for _, p := range line {
+			if _, err := positionToCoordinates(p); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// unmarshalNested decodes coordinates of single geometry as Multi geometry with one element
+func unmarshalNested(g *Geometry, v interface{}) error {
+	if strings.HasPrefix(g.Type, "Multi") {
+		return json.Unmarshal(g.Coordinates, v)
+	}
+	return json.Unmarshal(append(append([]byte{'['}, g.Coordinates...), ']'), v)
+}
+
+func positionToCoordinates(position []float64) (GeoCoordinates, error) {
+	if len(position) < 2 {
+		return GeoCoordinates{}, fmt.Errorf("position should have at least 2 elements, got %d", len(position))
+	}
+	return GeoCoordinates{Lon: position[0], Lat: position[1]}, nil
+}
+
+type geoJSONPointGeometry struct {
+	Type        string     `json:"type"`
+	Coordinates [2]float64 `json:"coordinates"`
+}
+
+type geoJSONOutFeature struct {
+	Type       string                 `json:"type"`
+	ID         interface{}            `json:"id,omitempty"`
+	Geometry   geoJSONPointGeometry   `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+type geoJSONOutCollection struct {
+	Type     string              `json:"type"`
+	Features []geoJSONOutFeature `json:"features"`
+}
+
+// MarshalGeoJSON encodes clustered points, as they returned by AllClusters, to GeoJSON FeatureCollection
+// Clusters have "cluster", "cluster_id", "point_count" and "point_count_abbreviated" properties
+// and "top_leaves" if Cluster.TopLeaves is set, "categories" if Cluster.Category is set,
+// "layers" if points are clustered by Cluster.ClusterMerged,
+// single points keep id and properties of the source point, if it is *Feature
+func MarshalGeoJSON(points []ClusterPoint) ([]byte, error) {
+	return marshalGeoJSON(points, rounding{})
+}
+
+// MarshalGeoJSONPrecision is the same as MarshalGeoJSON, but coordinates and numeric properties are rounded
+// to precision decimal digits, e.g. 5 digits are about 1m, so responses are smaller and don't expose
+// precision the clustering doesn't have. precision is between 0 and 12, GeobufPrecision is a good default.
+func MarshalGeoJSONPrecision(points []ClusterPoint, precision int) ([]byte, error) {
+	r, err := newRounding(precision)
+	if err != nil {
+		return nil, err
+	}
+	return marshalGeoJSON(points, r)
+}
+
+func marshalGeoJSON(points []ClusterPoint, r rounding) ([]byte, error) {
+	collection := geoJSONOutCollection{
+		Type:     "FeatureCollection",
+		Features: make([]geoJSONOutFeature, len(points)),
+	}
+	for i := range points {
+		collection.Features[i] = geoJSONFeatureOf(&points[i], r)
+	}
+	return json.Marshal(collection)
+}
+
+// MarshalGeoJSONFeature encodes one clustered point to GeoJSON Feature, the same as MarshalGeoJSON encodes features
+// It's used for newline delimited GeoJSON of ClusterPointsStream.
+func MarshalGeoJSONFeature(p ClusterPoint) ([]byte, error) {
+	return json.Marshal(geoJSONFeatureOf(&p, rounding{}))
+}
+
+// MarshalGeoJSONFeaturePrecision is the same as MarshalGeoJSONFeature, but numbers are rounded
+// like MarshalGeoJSONPrecision rounds them
+func MarshalGeoJSONFeaturePrecision(p ClusterPoint, precision int) ([]byte, error) {
+	r, err := newRounding(precision)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(geoJSONFeatureOf(&p, r))
+}
+
+func geoJSONFeatureOf(p *ClusterPoint, r rounding) geoJSONOutFeature {
+	return geoJSONOutFeature{
+		Type:       "Feature",
+		ID:         clusterFeatureID(p),
+		Geometry:   geoJSONPointGeometry{Type: "Point", Coordinates: [2]float64{r.round(p.X), r.round(p.Y)}},
+		Properties: r.properties(ClusterProperties(p)),
+	}
+}
+
+// clusterFeatureID returns id of the source point for single point, and cluster id for clusters
+func clusterFeatureID(p *ClusterPoint) interface{} {
+	if p.NumPoints == 1 && len(p.IncludedPoints) == 1 {
+		return pointID(p.IncludedPoints[0], p.Id)
+	}
+	return p.Id
+}
+
+// topLeavesProperty returns coordinates, id and properties of each leaf, if it has them, for top and sample leaves
+func topLeavesProperty(leaves []GeoPoint) []interface{} {
+	result := make([]interface{}, len(leaves))
+	for i, leaf := range leaves {
+		coordinates := leaf.GetCoordinates()
+		value := map[string]interface{}{"coordinates": []float64{coordinates.Lon, coordinates.Lat}}
+		if p, ok := leaf.(GeoPointWithID); ok && p.GetID() != nil {
+			value["id"] = p.GetID()
+		}
+		if properties := pointProperties(leaf); properties != nil {
+			value["properties"] = properties
+		}
+		result[i] = value
+	}
+	return result
+}
+
+// ClusterProperties returns properties encoders write for the clustered point, e.g. to describe fields of tiles
+// Properties of single points are shared with the point and should not be modified.
+func ClusterProperties(p *ClusterPoint) map[string]interface{} {
+	if p.NumPoints > 1 {
+		//points not made by Cluster have no abbreviated count
+		abbreviated := p.PointCountAbbreviated
+		if abbreviated == "" {
+			abbreviated = CountFormat{}.Format(p.NumPoints)
+		}
+		properties := map[string]interface{}{
+			"cluster":                 true,
+			"cluster_id":              p.Id,
+			"point_count":             p.NumPoints,
+			"point_count_abbreviated": abbreviated,
+		}
+		if len(p.TopLeaves) > 0 {
+			properties["top_leaves"] = topLeavesProperty(p.TopLeaves)
+		}
+		if p.Label != "" {
+			properties["label"] = p.Label
+		}
+		if len(p.Categories) > 0 {
+			properties["categories"] = p.Categories
+		}
+		if len(p.Layers) > 0 {
+			properties["layers"] = p.Layers
+		}
+		if len(p.SampleLeaves) > 0 {
+			properties["sample_leaves"] = topLeavesProperty(p.SampleLeaves)
+		}
+		for key, v := range p.Properties {
+			if _, reserved := properties[key]; !reserved {
+				properties[key] = v
+			}
+		}
+		return properties
+	}
+	if p.Properties != nil {
+		return p.Properties
+	}
+	if len(p.IncludedPoints) == 1 {
+		if properties := pointProperties(p.IncludedPoints[0]); properties != nil {
+			return properties
+		}
+	}
+	return map[string]interface{}{}
+}
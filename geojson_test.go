@@ -0,0 +1,52 @@
+package cluster
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadGeoJSON(t *testing.T) {
+	points, err := LoadGeoJSON(strings.NewReader(`{"type": "FeatureCollection", "features": [
+		{"type": "Feature", "id": 1, "geometry": {"type": "Point", "coordinates": [13.4, 52.5, 34]}, "properties": {"name": "Berlin"}},
+		{"type": "Feature", "id": "stops", "geometry": {"type": "MultiPoint", "coordinates": [[2.35, 48.85], [2.29, 48.86]]},
+			"properties": {"kind": "stop"}},
+		{"type": "Feature", "id": 3, "geometry": null, "properties": null}
+	]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []GeoPoint{
+		&Feature{ID: 1.0, Coordinates: GeoCoordinates{Lon: 13.4, Lat: 52.5}, Properties: map[string]interface{}{"name": "Berlin"}},
+		&Feature{ID: "stops", Coordinates: GeoCoordinates{Lon: 2.35, Lat: 48.85}, Properties: map[string]interface{}{"kind": "stop"}},
+		&Feature{ID: "stops", Coordinates: GeoCoordinates{Lon: 2.29, Lat: 48.86}, Properties: map[string]interface{}{"kind": "stop"}},
+	}
+	if !reflect.DeepEqual(points, want) {
+		t.Fatalf("points %v, want %v", points, want)
+	}
+
+	points, err = LoadGeoJSON(strings.NewReader(`{"type": "Feature", "geometry": {"type": "Point", "coordinates": [1, 2]}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(points) != 1 || points[0].GetCoordinates() != (GeoCoordinates{Lon: 1, Lat: 2}) {
+		t.Fatalf("points of single feature %v", points)
+	}
+}
+
+func TestLoadGeoJSONErrors(t *testing.T) {
+	inputs := map[string]string{
+		"broken json":    `{"type": "FeatureCollection", "features": [`,
+		"geometry":       `{"type": "Point", "coordinates": [1, 2]}`,
+		"collection":     `{"type": "Feature", "geometry": {"type": "GeometryCollection", "geometries": []}}`,
+		"short position": `{"type": "Feature", "geometry": {"type": "Point", "coordinates": [1]}}`,
+		"text position":  `{"type": "Feature", "geometry": {"type": "MultiPoint", "coordinates": [["1", "2"]]}}`,
+	}
+	for name, input := range inputs {
+		if _, err := LoadGeoJSON(strings.NewReader(input)); err == nil {
+			t.Errorf("%s: expected error", name)
+		} else if !strings.HasPrefix(err.Error(), "gocluster: ") {
+			t.Errorf("%s: error %q has no package prefix", name, err)
+		}
+	}
+}
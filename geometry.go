@@ -0,0 +1,220 @@
+package cluster
+
+import (
+	"container/heap"
+	"math"
+)
+
+// Representative defines how non point geometries are turned into points for clustering
+type Representative int
+
+const (
+	// RepresentativeCentroid uses length weighted centroid for lines and area weighted centroid for polygons
+	RepresentativeCentroid Representative = iota
+	// RepresentativePoleOfInaccessibility uses the most distant internal point from polygon outline,
+	// it's always inside of the polygon, unlike centroid of concave polygons. Lines use centroid.
+	RepresentativePoleOfInaccessibility
+)
+
+// position is longitude and latitude pair, as in GeoJSON
+type position = []float64
+
+// lineCentroid returns length weighted centroid of lines, or mean of vertices for zero length lines
+func lineCentroid(lines [][]position) (GeoCoordinates, bool) {
+	var sx, sy, total float64
+	var mx, my float64
+	var n int
+	for _, line := range lines {
+		for i := range line {
+			mx += line[i][0]
+			my += line[i][1]
+			n++
+			if i == 0 {
+				continue
+			}
+			a, b := line[i-1], line[i]
+			l := math.Hypot(b[0]-a[0], b[1]-a[1])
+			sx += l * (a[0] + b[0]) / 2
+			sy += l * (a[1] + b[1]) / 2
+			total += l
+		}
+	}
+	if n == 0 {
+		return GeoCoordinates{}, false
+	}
+	if total == 0 {
+		return GeoCoordinates{Lon: mx / float64(n), Lat: my / float64(n)}, true
+	}
+	return GeoCoordinates{Lon: sx / total, Lat: sy / total}, true
+}
+
+// polygonsCentroid returns area weighted centroid of polygons, holes are subtracted
+// Degenerated polygons with zero area fall back to lines centroid of their rings
+func polygonsCentroid(polygons [][][]position) (GeoCoordinates, bool) {
+	var sx, sy, total float64
+	var rings [][]position
+	for _, polygon := range polygons {
+		for i, ring := range polygon {
+			a, cx, cy := ringArea(ring)
+			//holes are subtracted from outer ring whatever their orientation is
+			a = math.Abs(a)
+			if i > 0 {
+				a = -a
+			}
+			sx += a * cx
+			sy += a * cy
+			total += a
+			rings = append(rings, ring)
+		}
+	}
+	if total == 0 {
+		return lineCentroid(rings)
+	}
+	return GeoCoordinates{Lon: sx / total, Lat: sy / total}, true
+}
+
+// ringArea returns signed area and centroid of the ring
+func ringArea(ring []position) (float64, float64, float64) {
+	var a, cx, cy float64
+	for i := range ring {
+		p := ring[i]
+		q := ring[(i+1)%len(ring)]
+		f := p[0]*q[1] - q[0]*p[1]
+		a += f
+		cx += (p[0] + q[0]) * f
+		cy += (p[1] + q[1]) * f
+	}
+	if a == 0 {
+		return 0, 0, 0
+	}
+	a /= 2
+	return a, cx / (6 * a), cy / (6 * a)
+}
+
+// polygonsPole returns pole of inaccessibility of the largest polygon
+func polygonsPole(polygons [][][]position) (GeoCoordinates, bool) {
+	var largest [][]position
+	maxArea := -1.0
+	for _, polygon := range polygons {
+		if len(polygon) == 0 || len(polygon[0]) == 0 {
+			continue
+		}
+		a, _, _ := ringArea(polygon[0])
+		if math.Abs(a) > maxArea {
+			maxArea = math.Abs(a)
+			largest = polygon
+		}
+	}
+	if largest == nil {
+		return GeoCoordinates{}, false
+	}
+	return polylabel(largest), true
+}
+
+// polylabel finds pole of inaccessibility of the polygon, the same algorithm as mapbox/polylabel
+// precision is 1/1000 of the polygon size
+func polylabel(polygon [][]position) GeoCoordinates {
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for _, p := range polygon[0] {
+		minX, maxX = math.Min(minX, p[0]), math.Max(maxX, p[0])
+		minY, maxY = math.Min(minY, p[1]), math.Max(maxY, p[1])
+	}
+	width, height := maxX-minX, maxY-minY
+	cellSize := math.Min(width, height)
+	if cellSize == 0 {
+		return GeoCoordinates{Lon: minX, Lat: minY}
+	}
+	precision := math.Max(width, height) / 1000
+
+	queue := &cellQueue{}
+	h := cellSize / 2
+	for x := minX; x < maxX; x += cellSize {
+		for y := minY; y < maxY; y += cellSize {
+			heap.Push(queue, newPoleCell(x+h, y+h, h, polygon))
+		}
+	}
+
+	best := newPoleCell(minX+width/2, minY+height/2, 0, polygon)
+	if c, ok := polygonsCentroid([][][]position{polygon}); ok {
+		if cell := newPoleCell(c.Lon, c.Lat, 0, polygon); cell.d > best.d {
+			best = cell
+		}
+	}
+
+	for queue.Len() > 0 {
+		cell := heap.Pop(queue).(*poleCell)
+		if cell.d > best.d {
+			best = cell
+		}
+		if cell.max-best.d <= precision {
+			continue
+		}
+		h = cell.h / 2
+		heap.Push(queue, newPoleCell(cell.x-h, cell.y-h, h, polygon))
+		heap.Push(queue, newPoleCell(cell.x+h, cell.y-h, h, polygon))
+		heap.Push(queue, newPoleCell(cell.x-h, cell.y+h, h, polygon))
+		heap.Push(queue, newPoleCell(cell.x+h, cell.y+h, h, polygon))
+	}
+	return GeoCoordinates{Lon: best.x, Lat: best.y}
+}
+
+type poleCell struct {
+	x, y float64
+	h    float64 //half of the cell size
+	d    float64 //distance from cell center to polygon, negative if outside
+	max  float64 //max distance to polygon within the cell
+}
+
+func newPoleCell(x, y, h float64, polygon [][]position) *poleCell {
+	d := pointToPolygonDist(x, y, polygon)
+	return &poleCell{x: x, y: y, h: h, d: d, max: d + h*math.Sqrt2}
+}
+
+// pointToPolygonDist returns signed distance from point to polygon outline, positive inside
+func pointToPolygonDist(x, y float64, polygon [][]position) float64 {
+	inside := false
+	minDist := math.Inf(1)
+	for _, ring := range polygon {
+		for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+			a, b := ring[i], ring[j]
+			if (a[1] > y) != (b[1] > y) && x < (b[0]-a[0])*(y-a[1])/(b[1]-a[1])+a[0] {
+				inside = !inside
+			}
+			minDist = math.Min(minDist, segmentDist(x, y, a, b))
+		}
+	}
+	if inside {
+		return minDist
+	}
+	return -minDist
+}
+
+func segmentDist(px, py float64, a, b position) float64 {
+	x, y := a[0], a[1]
+	dx, dy := b[0]-x, b[1]-y
+	if dx != 0 || dy != 0 {
+		t := ((px-x)*dx + (py-y)*dy) / (dx*dx + dy*dy)
+		if t > 1 {
+			x, y = b[0], b[1]
+		} else if t > 0 {
+			x += dx * t
+			y += dy * t
+		}
+	}
+	return math.Hypot(px-x, py-y)
+}
+
+// cellQueue is max heap of cells by their max distance
+type cellQueue []*poleCell
+
+func (q cellQueue) Len() int            { return len(q) }
+func (q cellQueue) Less(i, j int) bool  { return q[i].max > q[j].max }
+func (q cellQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *cellQueue) Push(x interface{}) { *q = append(*q, x.(*poleCell)) }
+func (q *cellQueue) Pop() interface{} {
+	old := *q
+	cell := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return cell
+}
@@ -0,0 +1,83 @@
+package cluster
+
+import (
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+)
+
+// uShape is a concave polygon, its centroid is outside of it, between the arms
+const uShape = `[[[0, 0], [3, 0], [3, 3], [2, 3], [2, 1], [1, 1], [1, 3], [0, 3], [0, 0]]]`
+
+func TestLoadGeoJSONLinesAndPolygons(t *testing.T) {
+	input := `{"type": "FeatureCollection", "features": [
+		{"type": "Feature", "geometry": {"type": "LineString", "coordinates": [[0, 0], [2, 0], [2, 1]]}},
+		{"type": "Feature", "geometry": {"type": "MultiLineString", "coordinates": [[[0, 0], [0, 0]]]}},
+		{"type": "Feature", "geometry": {"type": "Polygon", "coordinates": [[[0, 0], [4, 0], [4, 4], [0, 4], [0, 0]],
+			[[0, 0], [2, 0], [2, 2], [0, 2], [0, 0]]]}},
+		{"type": "Feature", "geometry": {"type": "MultiPolygon", "coordinates": [[[[10, 10], [11, 10], [11, 11], [10, 11], [10, 10]]],
+			[[[0, 0], [2, 0], [2, 2], [0, 2], [0, 0]]]]}},
+		{"type": "Feature", "geometry": {"type": "Polygon", "coordinates": ` + uShape + `}}
+	]}`
+	points, err := LoadGeoJSON(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []GeoCoordinates{
+		//length weighted: 2 units around (1, 0) and 1 unit around (2, 0.5)
+		{Lon: 4.0 / 3, Lat: 0.5 / 3},
+		//zero length line is its vertex
+		{Lon: 0, Lat: 0},
+		//the hole of south west quarter moves centroid north east
+		{Lon: 7.0 / 3, Lat: 7.0 / 3},
+		//area weighted: 1 unit of (10.5, 10.5) and 4 units of (1, 1)
+		{Lon: 14.5 / 5, Lat: 14.5 / 5},
+		//square of 9 units without the notch of 2 units
+		{Lon: 1.5, Lat: (9*1.5 - 2*2) / 7.0},
+	}
+	if len(points) != len(want) {
+		t.Fatalf("%d points, want %d", len(points), len(want))
+	}
+	for i, p := range points {
+		c := p.GetCoordinates()
+		if math.Abs(c.Lon-want[i].Lon) > 1e-9 || math.Abs(c.Lat-want[i].Lat) > 1e-9 {
+			t.Errorf("feature %d is at %v, want %v", i, c, want[i])
+		}
+		if p.(*Feature).Geometry == nil {
+			t.Errorf("feature %d has no source geometry", i)
+		}
+	}
+}
+
+func TestPoleOfInaccessibility(t *testing.T) {
+	input := `{"type": "Feature", "geometry": {"type": "Polygon", "coordinates": ` + uShape + `}}`
+	points, err := LoadGeoJSONWithOptions(strings.NewReader(input), GeoJSONOptions{Representative: RepresentativePoleOfInaccessibility})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := points[0].GetCoordinates()
+	var polygon [][]position
+	if err := json.Unmarshal([]byte(uShape), &polygon); err != nil {
+		t.Fatal(err)
+	}
+	//pole is inside of U, in the corner of its base and arm, farther from outline than arms width allows
+	if d := pointToPolygonDist(c.Lon, c.Lat, polygon); d < 0.55 {
+		t.Fatalf("pole of U shape is at %v, %v from outline", c, d)
+	}
+	if d := pointToPolygonDist(1.5, (9*1.5-2*2)/7.0, polygon); d > 0 {
+		t.Fatalf("centroid of U shape is inside, %v from outline", d)
+	}
+
+	for name, geometry := range map[string]string{
+		"empty line":    `{"type": "LineString", "coordinates": []}`,
+		"empty polygon": `{"type": "MultiPolygon", "coordinates": []}`,
+		"bad position":  `{"type": "Polygon", "coordinates": [[[0], [1, 1], [0, 1]]]}`,
+		"bad nesting":   `{"type": "Polygon", "coordinates": [[0, 0], [1, 1]]}`,
+	} {
+		input := `{"type": "Feature", "geometry": ` + geometry + `}`
+		if _, err := LoadGeoJSON(strings.NewReader(input)); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package cluster
+
+import "math"
+
+// greatCircleDist returns great-circle distance between projected points as fraction of the equator length,
+// so it's the same as projected distance at the equator
+func greatCircleDist(ax, ay, bx, by float64) float64 {
+	return 2 * math.Asin(math.Sqrt(haversine(ax, ay, bx, by))) / (2 * math.Pi)
+}
+
+// haversine returns haversine of the central angle between projected points
+// Latitude is not calculated: sin(lat) is tanh and cos(lat) is 1/cosh of mercator y in radians.
+func haversine(ax, ay, bx, by float64) float64 {
+	ta, tb := math.Pi*(1-2*ay), math.Pi*(1-2*by)
+	cosA, cosB := 1/math.Cosh(ta), 1/math.Cosh(tb)
+	cosLat := cosA*cosB + math.Tanh(ta)*math.Tanh(tb)
+	sinLon := math.Sin((bx - ax) * math.Pi)
+	return (1-cosLat)/2 + cosA*cosB*sinLon*sinLon
+}
+
+// greatCircleRadius returns projected radius around y containing all points within eps great-circle distance
+// it's used as index prefilter, its circle covers the spherical cap with some margin
+func greatCircleRadius(eps, y float64) float64 {
+	delta := eps * 2 * math.Pi
+	t := math.Abs(math.Pi * (1 - 2*y))
+	lat := math.Atan(math.Sinh(t))
+	if lat+delta >= math.Pi/2 || math.Sin(delta) >= math.Cos(lat) {
+		//the pole is inside the cap, any longitude is within distance
+		return 2
+	}
+	xExtent := math.Asin(math.Sin(delta)/math.Cos(lat)) / (2 * math.Pi)
+	yExtent := (math.Asinh(math.Tan(lat+delta)) - t) / (2 * math.Pi)
+	return math.Hypot(xExtent, yExtent)
+}
+
+// neighbourRadius returns projected radius of the index search of appendNeighbours at y
+func (c *Cluster) neighbourRadius(y float64) float64 {
+	if c.GreatCircle {
+		return greatCircleRadius(c.Epsilon, y)
+	}
+	return c.radiusAt(c.Epsilon, y)
+}
+
+// appendNeighbours appends indexes of points within Epsilon from x, y found by index over points
+// Great-circle distance is checked if GreatCircle is set, the index search is a bounding prefilter then.
+func (c *Cluster) appendNeighbours(dst []int, index spatialIndex, points []*ClusterPoint, x, y float64) []int {
+	if !c.GreatCircle {
+		return index.AppendWithin(dst, x, y, c.neighbourRadius(y))
+	}
+	start := len(dst)
+	dst = index.AppendWithin(dst, x, y, c.neighbourRadius(y))
+	maxHav := math.Pow(math.Sin(c.Epsilon*math.Pi), 2)
+	n := start
+	for _, id := range dst[start:] {
+		p := points[id]
+		if haversine(x, y, p.X, p.Y) <= maxHav {
+			dst[n] = id
+			n++
+		}
+	}
+	return dst[:n]
+}
+
+// distance returns distance between points the same way appendNeighbours measures it,
+// divided by scale for projected distance
+func (c *Cluster) distance(p, q *ClusterPoint, scale float64) float64 {
+	if c.GreatCircle {
+		return greatCircleDist(p.X, p.Y, q.X, q.Y)
+	}
+	return math.Sqrt(sqDist(p.X, p.Y, q.X, q.Y)) / scale
+}
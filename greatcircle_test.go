@@ -0,0 +1,51 @@
+package cluster
+
+import (
+	"math"
+	"sort"
+	"testing"
+)
+
+func TestGreatCircleDist(t *testing.T) {
+	tests := []struct {
+		a, b GeoCoordinates
+		want float64
+	}{
+		{GeoCoordinates{Lon: 0, Lat: 0}, GeoCoordinates{Lon: 90, Lat: 0}, 0.25},
+		{GeoCoordinates{Lon: 10, Lat: -20}, GeoCoordinates{Lon: 10, Lat: 40}, 60.0 / 360},
+		//the shortest way is over the pole
+		{GeoCoordinates{Lon: 0, Lat: 60}, GeoCoordinates{Lon: 180, Lat: 60}, 60.0 / 360},
+		{GeoCoordinates{Lon: 179, Lat: 0}, GeoCoordinates{Lon: -179, Lat: 0}, 2.0 / 360},
+	}
+	for _, test := range tests {
+		ax, ay := MercatorProjection(test.a)
+		bx, by := MercatorProjection(test.b)
+		if d := greatCircleDist(ax, ay, bx, by); math.Abs(d-test.want) > 1e-9 {
+			t.Errorf("distance between %v and %v is %v, want %v", test.a, test.b, d, test.want)
+		}
+	}
+}
+
+func TestGreatCircleNeighbours(t *testing.T) {
+	c := NewCluster(0.01)
+	c.GreatCircle = true
+	//high latitudes, where projected distances are the most distorted
+	if err := c.ClusterPoints(randomPoints(3000, 23, -180, 60, 180, 85)); err != nil {
+		t.Fatal(err)
+	}
+	for q := 0; q < 100; q += 7 {
+		p := c.basePoints[q]
+		var want []int
+		for i, o := range c.basePoints {
+			if greatCircleDist(p.X, p.Y, o.X, o.Y) <= c.Epsilon {
+				want = append(want, i)
+			}
+		}
+		got := c.appendNeighbours(nil, c.baseIndex, c.basePoints, p.X, p.Y)
+		sort.Ints(got)
+		if !equalInts(got, want) {
+			t.Fatalf("neighbours of point %d are %v, want %v", q, got, want)
+		}
+	}
+	checkAssignments(t, c, 3000)
+}
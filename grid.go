@@ -0,0 +1,127 @@
+package cluster
+
+import (
+	"errors"
+	"math"
+)
+
+// GridShape is the shape of the aggregation grid cells
+type GridShape int
+
+const (
+	// GridSquare aggregates points into square cells aligned with tiles
+	GridSquare GridShape = iota
+	// GridHexagon aggregates points into pointy-top hexagons
+	GridHexagon
+)
+
+// GridOptions configures grid aggregation
+// CellSize - distance between centers of neighbour cells in pixels at requested zoom
+type GridOptions struct {
+	Shape    GridShape
+	CellSize float64
+}
+
+// Grid aggregates points in the box between northWest and southEast corners into grid cells
+// Cells are opts.CellSize pixels at zoom for tiles of tileSize pixels, empty cells are skipped.
+// Each cell has "point_count" property. Neighbour cells share their vertices exactly after quantization,
+// so EncodeTopoJSON encodes each shared boundary once.
+func (c *Cluster) Grid(northWest, southEast GeoCoordinates, zoom, tileSize int, opts GridOptions) ([]Polygon, error) {
+	defer c.startQuery("Grid")()
+	minX, minY := MercatorProjection(northWest)
+	maxX, maxY := MercatorProjection(southEast)
+	return c.grid(minX, minY, maxX, maxY, float64(tileSize)*tileScale(zoom), opts)
+}
+
+// GridTile aggregates points of the tile of tileSize pixels into grid cells
+// Cells on the tile border are not clipped and could be returned for neighbour tiles too
+func (c *Cluster) GridTile(t Tile, tileSize int, opts GridOptions) ([]Polygon, error) {
+	defer c.startQuery("GridTile")()
+	n := tileScale(t.Z)
+	return c.grid(float64(t.X)/n, float64(t.Y)/n, float64(t.X+1)/n, float64(t.Y+1)/n, float64(tileSize)*n, opts)
+}
+
+func (c *Cluster) grid(minX, minY, maxX, maxY, scale float64, opts GridOptions) ([]Polygon, error) {
+	if c.baseIndex == nil {
+		return nil, notBuilt("Grid")
+	}
+	if !c.mercator() {
+		return nil, errors.New("gocluster: Grid needs WebMercator projection")
+	}
+	if opts.CellSize <= 0 {
+		return nil, errors.New("gocluster: grid cell size should be positive")
+	}
+	if maxX <= minX || maxY <= minY {
+		return nil, errors.New("gocluster: empty bounding box")
+	}
+
+	type gridCell struct {
+		col, row int
+		count    int
+	}
+	var cells []*gridCell
+	cellOf := map[[2]int]*gridCell{}
+	for _, id := range c.baseIndex.Range(minX, minY, maxX, maxY) {
+		p := c.basePoints[id]
+		col, row := gridCellOf(p.X*scale, p.Y*scale, opts)
+		cell, ok := cellOf[[2]int{col, row}]
+		if !ok {
+			cell = &gridCell{col: col, row: row}
+			cellOf[[2]int{col, row}] = cell
+			cells = append(cells, cell)
+		}
+		cell.count += p.NumPoints
+	}
+
+	result := make([]Polygon, len(cells))
+	for i, cell := range cells {
+		corners := gridCellCorners(cell.col, cell.row, opts)
+		ring := make([]GeoCoordinates, len(corners))
+		for j, corner := range corners {
+			ring[j] = ReverseMercatorProjection(corner[0]/scale, corner[1]/scale)
+		}
+		result[i] = Polygon{
+			Ring:       ring,
+			Properties: map[string]interface{}{"point_count": cell.count},
+		}
+	}
+	return result, nil
+}
+
+// gridCellOf returns column and row of the cell which contains pixel, rows of hexagons use axial coordinates
+func gridCellOf(x, y float64, opts GridOptions) (int, int) {
+	if opts.Shape != GridHexagon {
+		return int(math.Floor(x / opts.CellSize)), int(math.Floor(y / opts.CellSize))
+	}
+	size := opts.CellSize / math.Sqrt(3)
+	q := (math.Sqrt(3)/3*x - y/3) / size
+	r := 2 * y / 3 / size
+	//cube coordinates rounding
+	s := -q - r
+	rq, rr, rs := math.Round(q), math.Round(r), math.Round(s)
+	dq, dr, ds := math.Abs(rq-q), math.Abs(rr-r), math.Abs(rs-s)
+	if dq > dr && dq > ds {
+		rq = -rr - rs
+	} else if dr > ds {
+		rr = -rq - rs
+	}
+	return int(rq), int(rr)
+}
+
+// gridCellCorners returns pixel corners of the cell, clockwise on the screen which is counterclockwise on the map
+func gridCellCorners(col, row int, opts GridOptions) [][2]float64 {
+	if opts.Shape != GridHexagon {
+		x0, y0 := float64(col)*opts.CellSize, float64(row)*opts.CellSize
+		x1, y1 := x0+opts.CellSize, y0+opts.CellSize
+		return [][2]float64{{x0, y0}, {x1, y0}, {x1, y1}, {x0, y1}}
+	}
+	size := opts.CellSize / math.Sqrt(3)
+	cx := opts.CellSize * (float64(col) + float64(row)/2)
+	cy := 1.5 * size * float64(row)
+	corners := make([][2]float64, 6)
+	for i := range corners {
+		angle := math.Pi / 180 * float64(60*i-30)
+		corners[i] = [2]float64{cx + size*math.Cos(angle), cy + size*math.Sin(angle)}
+	}
+	return corners
+}
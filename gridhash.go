@@ -0,0 +1,192 @@
+package cluster
+
+import "math"
+
+// IndexBackend is the spatial index neighbours of projected points are searched in
+type IndexBackend int
+
+const (
+	// IndexKDTree is the flat KD-tree, it fits any distribution of points and is the default
+	IndexKDTree IndexBackend = iota
+	// IndexGrid is the uniform grid hash with cells of Epsilon size, so each neighbour query visits few cells
+	// and building is one counting sort. It's faster for evenly distributed points, e.g. global sensor networks,
+	// but dense spots fill a few cells and make queries slower. Coordinates are always kept as float64.
+	IndexGrid
+)
+
+// indexBuilder collects projected points of the index in the order of their indices, see Cluster.newIndexBuilder
+type indexBuilder interface {
+	add(x, y float64)
+	finish() spatialIndex
+}
+
+// newIndexBuilder creates empty index of IndexBackend with capacity for n points
+func (c *Cluster) newIndexBuilder(n int) indexBuilder {
+	if c.IndexBackend == IndexGrid {
+		return newGridIndex(n, c.Epsilon)
+	}
+	return newKDIndex(n, c.NodeSize, c.CoordinatesMode)
+}
+
+// indexPoints creates index of IndexBackend for points
+func (c *Cluster) indexPoints(points []*ClusterPoint) spatialIndex {
+	index := c.newIndexBuilder(len(points))
+	for _, p := range points {
+		index.add(p.X, p.Y)
+	}
+	return index.finish()
+}
+
+// gridMaxCellsPerPoint limits the number of grid cells, so sparse points don't take more memory for cells than for points
+const gridMaxCellsPerPoint = 2
+
+// gridIndex is the uniform grid of square cells over bounds of points, points are sorted by cells:
+// points of the cell are in idxs and coords from starts[cell] to starts[cell+1], cells are row by row
+type gridIndex struct {
+	size       float64 //cell size, the search radius the grid is built for
+	minX, minY float64
+	cols, rows int
+	starts     []uint32
+	idxs       []uint32
+	coords     []float64
+}
+
+// newGridIndex creates empty grid for n points with cells of size, points are added by add and sorted by finish
+func newGridIndex(n int, size float64) *gridIndex {
+	return &gridIndex{size: size, coords: make([]float64, 0, 2*n)}
+}
+
+// add appends point with the next index
+func (g *gridIndex) add(x, y float64) {
+	g.coords = append(g.coords, x, y)
+}
+
+// finish sorts added points by cells, cells are enlarged if there would be too many of them
+func (g *gridIndex) finish() spatialIndex {
+	n := len(g.coords) / 2
+	minX, minY, maxX, maxY := math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)
+	for i := 0; i < n; i++ {
+		//comparisons skip NaN coordinates, they are put into the first cell like infinite ones
+		x, y := g.coords[2*i], g.coords[2*i+1]
+		if math.IsInf(x, 0) || math.IsInf(y, 0) {
+			continue
+		}
+		if x < minX {
+			minX = x
+		}
+		if x > maxX {
+			maxX = x
+		}
+		if y < minY {
+			minY = y
+		}
+		if y > maxY {
+			maxY = y
+		}
+	}
+	if minX > maxX || minY > maxY {
+		minX, minY, maxX, maxY = 0, 0, 0, 0
+	}
+	g.minX, g.minY = minX, minY
+	width, height := maxX-minX, maxY-minY
+	maxCells := float64(gridMaxCellsPerPoint*n + 1)
+	if !(g.size > 0) || (width/g.size+1)*(height/g.size+1) > maxCells {
+		//the smallest size giving no more than maxCells cells, (w/s+1)*(h/s+1) = maxCells solved for s
+		a := maxCells - 1
+		b := width + height
+		g.size = (b + math.Sqrt(b*b+4*a*width*height)) / (2 * a)
+		if !(g.size > 0) {
+			g.size = 1
+		}
+	}
+	g.cols = int(width/g.size) + 1
+	g.rows = int(height/g.size) + 1
+
+	cells := make([]int, n)
+	g.starts = make([]uint32, g.cols*g.rows+1)
+	for i := 0; i < n; i++ {
+		cells[i] = g.cell(g.col(g.coords[2*i]), g.row(g.coords[2*i+1]))
+		g.starts[cells[i]+1]++
+	}
+	for i := 1; i < len(g.starts); i++ {
+		g.starts[i] += g.starts[i-1]
+	}
+	next := append([]uint32(nil), g.starts[:len(g.starts)-1]...)
+	coords := make([]float64, len(g.coords))
+	g.idxs = make([]uint32, n)
+	for i := 0; i < n; i++ {
+		k := next[cells[i]]
+		next[cells[i]]++
+		g.idxs[k] = uint32(i)
+		coords[2*k], coords[2*k+1] = g.coords[2*i], g.coords[2*i+1]
+	}
+	g.coords = coords
+	return g
+}
+
+// col returns column of x, clamped to the grid
+func (g *gridIndex) col(x float64) int {
+	return clampCell((x-g.minX)/g.size, g.cols)
+}
+
+// row returns row of y, clamped to the grid
+func (g *gridIndex) row(y float64) int {
+	return clampCell((y-g.minY)/g.size, g.rows)
+}
+
+func (g *gridIndex) cell(col, row int) int {
+	return row*g.cols + col
+}
+
+// clampCell returns cell of position in cells, clamped to [0..n) range, before it's converted to int
+func clampCell(position float64, n int) int {
+	if !(position > 0) {
+		return 0
+	}
+	if position >= float64(n-1) {
+		return n - 1
+	}
+	return int(position)
+}
+
+func (g *gridIndex) Bytes() int {
+	return 4*cap(g.starts) + 4*cap(g.idxs) + 8*cap(g.coords)
+}
+
+// AppendWithin finds all items within a given radius from the query point and appends their indices to result
+func (g *gridIndex) AppendWithin(result []int, qx, qy, radius float64) []int {
+	if len(g.idxs) == 0 {
+		return result
+	}
+	r2 := radius * radius
+	minCol, maxCol := g.col(qx-radius), g.col(qx+radius)
+	for row, maxRow := g.row(qy-radius), g.row(qy+radius); row <= maxRow; row++ {
+		//cells of the row are consecutive
+		end := g.starts[g.cell(maxCol, row)+1]
+		for k := g.starts[g.cell(minCol, row)]; k < end; k++ {
+			if sqDist(g.coords[2*k], g.coords[2*k+1], qx, qy) <= r2 {
+				result = append(result, int(g.idxs[k]))
+			}
+		}
+	}
+	return result
+}
+
+// Range finds all items within the bounding box and returns indices of points
+func (g *gridIndex) Range(minX, minY, maxX, maxY float64) []int {
+	if len(g.idxs) == 0 || minX > maxX || minY > maxY {
+		return nil
+	}
+	var result []int
+	minCol, maxCol := g.col(minX), g.col(maxX)
+	for row, maxRow := g.row(minY), g.row(maxY); row <= maxRow; row++ {
+		end := g.starts[g.cell(maxCol, row)+1]
+		for k := g.starts[g.cell(minCol, row)]; k < end; k++ {
+			x, y := g.coords[2*k], g.coords[2*k+1]
+			if x >= minX && x <= maxX && y >= minY && y <= maxY {
+				result = append(result, int(g.idxs[k]))
+			}
+		}
+	}
+	return result
+}
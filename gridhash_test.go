@@ -0,0 +1,104 @@
+package cluster
+
+import (
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestGridIndex(t *testing.T) {
+	random := rand.New(rand.NewSource(2))
+	points := make([]*ClusterPoint, 3000)
+	for i := range points {
+		points[i] = &ClusterPoint{X: random.Float64(), Y: random.Float64() * 0.5}
+	}
+	//dense spot, cells of tiny or no size are enlarged
+	for i := 0; i < 300; i++ {
+		points[i].X, points[i].Y = 0.3+random.Float64()*0.001, 0.2+random.Float64()*0.001
+	}
+	for _, size := range []float64{0.02, 1e-9, 0} {
+		index := newGridIndex(len(points), size)
+		for _, p := range points {
+			index.add(p.X, p.Y)
+		}
+		g := index.finish()
+		for q := 0; q < 100; q++ {
+			//queries partly outside of the grid and radiuses larger than cells
+			qx, qy, r := random.Float64()*1.2-0.1, random.Float64()*0.7-0.1, random.Float64()*0.1
+			var want []int
+			for i, p := range points {
+				if sqDist(p.X, p.Y, qx, qy) <= r*r {
+					want = append(want, i)
+				}
+			}
+			got := g.AppendWithin(nil, qx, qy, r)
+			sort.Ints(got)
+			if !equalInts(got, want) {
+				t.Fatalf("size %v: within %v of %v,%v found %d points, want %d", size, r, qx, qy, len(got), len(want))
+			}
+			want = want[:0]
+			for i, p := range points {
+				if p.X >= qx-r && p.X <= qx+r && p.Y >= qy-r && p.Y <= qy+r {
+					want = append(want, i)
+				}
+			}
+			got = g.Range(qx-r, qy-r, qx+r, qy+r)
+			sort.Ints(got)
+			if !equalInts(got, want) {
+				t.Fatalf("size %v: range around %v,%v found %d points, want %d", size, qx, qy, len(got), len(want))
+			}
+		}
+		if cells := len(g.(*gridIndex).starts) - 1; cells > gridMaxCellsPerPoint*len(points)+1 {
+			t.Fatalf("size %v: %d cells of %d points", size, cells, len(points))
+		}
+	}
+
+	empty := newGridIndex(0, 0.1).finish()
+	if got := empty.AppendWithin(nil, 0, 0, 1); len(got) != 0 || empty.Range(-1, -1, 1, 1) != nil {
+		t.Fatal("empty grid has points")
+	}
+	single := newGridIndex(1, 0.1)
+	single.add(0.5, 0.5)
+	if got := single.finish().AppendWithin(nil, 0.55, 0.5, 0.1); !equalInts(got, []int{0}) {
+		t.Fatalf("grid of single point found %v", got)
+	}
+}
+
+func TestClusterIndexGrid(t *testing.T) {
+	points := randomPoints(3000, 61, -60, -60, 60, 60)
+	var results [2]*Cluster
+	for i, backend := range []IndexBackend{IndexKDTree, IndexGrid} {
+		c, err := NewClusterForZoom(4, 256, 40)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.IndexBackend = backend
+		if err := c.ClusterPoints(points); err != nil {
+			t.Fatal(err)
+		}
+		checkAssignments(t, c, len(points))
+		results[i] = c
+	}
+	kd, grid := results[0], results[1]
+	if _, ok := grid.baseIndex.(*gridIndex); !ok {
+		t.Fatalf("index is %T, want grid", grid.baseIndex)
+	}
+	//neighbours are taken in input order, so backends give the same clusters
+	if len(grid.ResultPoints) != len(kd.ResultPoints) {
+		t.Fatalf("%d result points with grid, want %d", len(grid.ResultPoints), len(kd.ResultPoints))
+	}
+	for i := range kd.ResultPoints {
+		if !reflect.DeepEqual(grid.ResultPoints[i].memberIDs, kd.ResultPoints[i].memberIDs) {
+			t.Fatalf("result point %d differs with grid", i)
+		}
+	}
+	northWest, southEast := GeoCoordinates{Lon: -20, Lat: 30}, GeoCoordinates{Lon: 40, Lat: -10}
+	if len(grid.GetClusters(northWest, southEast)) != len(kd.GetClusters(northWest, southEast)) {
+		t.Fatal("grid finds other points in the box")
+	}
+	restored := roundTrip(t, grid)
+	if _, ok := restored.baseIndex.(*gridIndex); !ok || restored.IndexBackend != IndexGrid {
+		t.Fatalf("restored index is %T", restored.baseIndex)
+	}
+}
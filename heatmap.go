@@ -0,0 +1,151 @@
+package cluster
+
+import (
+	"errors"
+	"image"
+	"image/png"
+	"io"
+	"math"
+)
+
+// Kernel defines how each point contributes to the density of the cells around it
+type Kernel int
+
+const (
+	// KernelCount adds each point to the single cell it falls into
+	KernelCount Kernel = iota
+	// KernelLinear spreads point over cells within Radius, weight decreases linearly with distance
+	KernelLinear
+	// KernelGaussian spreads point over cells within Radius with gaussian weight, sigma is Radius/2
+	KernelGaussian
+)
+
+// HeatmapOptions configures density grid
+// CellSize - size of the grid cell in pixels at requested zoom
+// Radius - kernel radius in cells, ignored by KernelCount
+type HeatmapOptions struct {
+	CellSize int
+	Kernel   Kernel
+	Radius   float64
+}
+
+// DensityGrid is the rasterized density of points
+// Values are stored row by row from north-west corner, Max is the maximum value in the grid
+type DensityGrid struct {
+	Width     int
+	Height    int
+	NorthWest GeoCoordinates
+	SouthEast GeoCoordinates
+	Values    []float64
+	Max       float64
+}
+
+// At returns density of the cell in column x and row y
+func (g *DensityGrid) At(x, y int) float64 {
+	return g.Values[y*g.Width+x]
+}
+
+// Image returns grayscale image of the grid, normalized by Max, each cell is one pixel
+func (g *DensityGrid) Image() *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, g.Width, g.Height))
+	if g.Max <= 0 {
+		return img
+	}
+	for i, v := range g.Values {
+		img.Pix[(i/g.Width)*img.Stride+i%g.Width] = uint8(math.Round(255 * v / g.Max))
+	}
+	return img
+}
+
+// WritePNG writes grayscale PNG of the grid
+func (g *DensityGrid) WritePNG(w io.Writer) error {
+	return png.Encode(w, g.Image())
+}
+
+// Density rasterizes points density for the box between northWest and southEast corners
+// Grid cells are opts.CellSize pixels at zoom for tiles of tileSize pixels
+// Points are taken from the index built by ClusterPoints, so the same index serves clusters and heatmaps
+func (c *Cluster) Density(northWest, southEast GeoCoordinates, zoom, tileSize int, opts HeatmapOptions) (*DensityGrid, error) {
+	defer c.startQuery("Density")()
+	minX, minY := MercatorProjection(northWest)
+	maxX, maxY := MercatorProjection(southEast)
+	return c.density(minX, minY, maxX, maxY, float64(tileSize)*tileScale(zoom), opts)
+}
+
+// DensityTile rasterizes points density for the tile of tileSize pixels
+func (c *Cluster) DensityTile(t Tile, tileSize int, opts HeatmapOptions) (*DensityGrid, error) {
+	defer c.startQuery("DensityTile")()
+	n := tileScale(t.Z)
+	return c.density(float64(t.X)/n, float64(t.Y)/n, float64(t.X+1)/n, float64(t.Y+1)/n, float64(tileSize)*n, opts)
+}
+
+func (c *Cluster) density(minX, minY, maxX, maxY, scale float64, opts HeatmapOptions) (*DensityGrid, error) {
+	if c.baseIndex == nil {
+		return nil, notBuilt("Density")
+	}
+	if !c.mercator() {
+		return nil, errors.New("gocluster: Density needs WebMercator projection")
+	}
+	if opts.CellSize <= 0 {
+		return nil, errors.New("gocluster: heatmap cell size should be positive")
+	}
+	if maxX <= minX || maxY <= minY {
+		return nil, errors.New("gocluster: empty bounding box")
+	}
+
+	cell := float64(opts.CellSize) / scale
+	g := &DensityGrid{
+		Width:     int(math.Ceil((maxX - minX) / cell)),
+		Height:    int(math.Ceil((maxY - minY) / cell)),
+		NorthWest: ReverseMercatorProjection(minX, minY),
+		SouthEast: ReverseMercatorProjection(maxX, maxY),
+	}
+	g.Values = make([]float64, g.Width*g.Height)
+
+	radius := opts.Radius
+	if opts.Kernel == KernelCount {
+		radius = 0
+	}
+	// points around the box affect border cells too
+	pad := radius * cell
+	for _, id := range c.baseIndex.Range(minX-pad, minY-pad, maxX+pad, maxY+pad) {
+		p := c.basePoints[id]
+		g.add((p.X-minX)/cell, (p.Y-minY)/cell, float64(p.NumPoints), opts.Kernel, radius)
+	}
+
+	for _, v := range g.Values {
+		g.Max = math.Max(g.Max, v)
+	}
+	return g, nil
+}
+
+// add spreads weight around cell position fx, fy
+func (g *DensityGrid) add(fx, fy, weight float64, kernel Kernel, radius float64) {
+	if radius <= 0 {
+		x, y := int(math.Floor(fx)), int(math.Floor(fy))
+		if x >= 0 && y >= 0 && x < g.Width && y < g.Height {
+			g.Values[y*g.Width+x] += weight
+		}
+		return
+	}
+
+	sigma2 := 2 * (radius / 2) * (radius / 2)
+	minCX := maxInt(0, int(math.Floor(fx-radius)))
+	maxCX := minInt(g.Width-1, int(math.Floor(fx+radius)))
+	minCY := maxInt(0, int(math.Floor(fy-radius)))
+	maxCY := minInt(g.Height-1, int(math.Floor(fy+radius)))
+	for y := minCY; y <= maxCY; y++ {
+		for x := minCX; x <= maxCX; x++ {
+			//distance from point to the cell center
+			d := math.Hypot(float64(x)+0.5-fx, float64(y)+0.5-fy)
+			if d > radius {
+				continue
+			}
+			w := 1 - d/radius
+			if kernel == KernelGaussian {
+				w = math.Exp(-d * d / sigma2)
+			}
+			g.Values[y*g.Width+x] += weight * w
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package cluster
+
+import (
+	"bytes"
+	"image/png"
+	"testing"
+)
+
+func TestDensityCount(t *testing.T) {
+	tile := Tile{X: 1, Y: 1, Z: 2}
+	points := randomPoints(1000, 5, -100, 0, 0, 70)
+	c := NewCluster(0.01)
+	if err := c.ClusterPoints(points); err != nil {
+		t.Fatal(err)
+	}
+	g, err := c.DensityTile(tile, 256, HeatmapOptions{CellSize: 16})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.Width != 16 || g.Height != 16 || len(g.Values) != 256 {
+		t.Fatalf("grid is %dx%d of %d values", g.Width, g.Height, len(g.Values))
+	}
+
+	//every point of the tile is counted in the cell it falls into
+	want := make([]float64, len(g.Values))
+	for _, p := range points {
+		if LonLatToTile(p.GetCoordinates(), tile.Z) != tile {
+			continue
+		}
+		x, y := TilePixel(p.GetCoordinates(), tile, 256)
+		want[int(y)/16*16+int(x)/16]++
+	}
+	max := 0.0
+	for i, v := range want {
+		if g.Values[i] != v {
+			t.Fatalf("cell %d,%d has %v points, want %v", i%16, i/16, g.Values[i], v)
+		}
+		if v > max {
+			max = v
+		}
+	}
+	if g.Max != max || max == 0 {
+		t.Fatalf("grid max is %v, want %v", g.Max, max)
+	}
+}
+
+func TestDensityKernels(t *testing.T) {
+	point := GeoCoordinates{Lon: 0.05, Lat: -0.05}
+	c := NewCluster(0.01)
+	if err := c.ClusterPoints([]GeoPoint{&Feature{Coordinates: point}}); err != nil {
+		t.Fatal(err)
+	}
+	northWest, southEast := GeoCoordinates{Lon: 0, Lat: 0}, GeoCoordinates{Lon: 0.1, Lat: -0.1}
+	px, py := LonLatToPixel(point, 12, 256)
+	ox, oy := LonLatToPixel(northWest, 12, 256)
+	for _, kernel := range []Kernel{KernelLinear, KernelGaussian} {
+		g, err := c.Density(northWest, southEast, 12, 256, HeatmapOptions{CellSize: 1, Kernel: kernel, Radius: 5})
+		if err != nil {
+			t.Fatal(err)
+		}
+		//density is the highest in the cell of the point and decreases away from it
+		cx, cy := int(px-ox), int(py-oy)
+		if g.At(cx, cy) != g.Max || g.Max <= 0 {
+			t.Fatalf("kernel %d: center has %v, max is %v", kernel, g.At(cx, cy), g.Max)
+		}
+		for d := 1; d < 5; d++ {
+			if g.At(cx+d, cy) >= g.At(cx+d-1, cy) || g.At(cx+d, cy) <= 0 {
+				t.Fatalf("kernel %d: cell %d from the center has %v", kernel, d, g.At(cx+d, cy))
+			}
+		}
+		if v := g.At(cx+6, cy); v != 0 {
+			t.Fatalf("kernel %d: cell outside radius has %v", kernel, v)
+		}
+	}
+}
+
+func TestDensityPNG(t *testing.T) {
+	g := &DensityGrid{Width: 3, Height: 2, Values: []float64{0, 1, 2, 4, 0, 0}, Max: 4}
+	var buf bytes.Buffer
+	if err := g.WritePNG(&buf); err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Bounds(); b.Dx() != 3 || b.Dy() != 2 {
+		t.Fatalf("image is %v", b)
+	}
+	gray := g.Image()
+	if gray.GrayAt(0, 1).Y != 255 || gray.GrayAt(1, 0).Y != 64 || gray.GrayAt(0, 0).Y != 0 {
+		t.Fatalf("image pixels %v", gray.Pix)
+	}
+}
+
+func TestDensityErrors(t *testing.T) {
+	northWest, southEast := GeoCoordinates{Lon: 0, Lat: 10}, GeoCoordinates{Lon: 10, Lat: 0}
+	opts := HeatmapOptions{CellSize: 8}
+	if _, err := NewCluster(0.01).Density(northWest, southEast, 3, 256, opts); err == nil {
+		t.Fatal("expected error of Cluster without points")
+	}
+	points := []GeoPoint{&Feature{Coordinates: GeoCoordinates{Lon: 5, Lat: 5}}}
+	c := NewCluster(0.01)
+	if err := c.ClusterPoints(points); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Density(northWest, southEast, 3, 256, HeatmapOptions{}); err == nil {
+		t.Fatal("expected error of zero cell size")
+	}
+	if _, err := c.Density(southEast, northWest, 3, 256, opts); err == nil {
+		t.Fatal("expected error of empty box")
+	}
+}
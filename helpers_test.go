@@ -0,0 +1,66 @@
+package cluster
+
+import (
+	"math/rand"
+	"os"
+	"testing"
+)
+
+// loadPlaces returns points of testdata/places.json
+func loadPlaces(t testing.TB) []GeoPoint {
+	t.Helper()
+	f, err := os.Open("testdata/places.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	points, err := LoadGeoJSON(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return points
+}
+
+// randomPoints returns n features scattered over the box, the same ones for the same seed
+func randomPoints(n int, seed int64, west, south, east, north float64) []GeoPoint {
+	random := rand.New(rand.NewSource(seed))
+	points := make([]GeoPoint, n)
+	for i := range points {
+		points[i] = &Feature{
+			ID: i,
+			Coordinates: GeoCoordinates{
+				Lon: west + random.Float64()*(east-west),
+				Lat: south + random.Float64()*(north-south),
+			},
+			Properties: map[string]interface{}{"n": float64(i)},
+		}
+	}
+	return points
+}
+
+// totalPoints returns the sum of NumPoints of result points
+func totalPoints(points []ClusterPoint) int {
+	total := 0
+	for _, p := range points {
+		total += p.NumPoints
+	}
+	return total
+}
+
+// checkAssignments fails if members of ResultPoints and Assignments of c disagree or some point is lost
+func checkAssignments(t testing.TB, c *Cluster, n int) {
+	t.Helper()
+	if total := totalPoints(c.ResultPoints); total != n {
+		t.Fatalf("result points have %d points, want %d", total, n)
+	}
+	for i, cp := range c.ResultPoints {
+		if len(cp.memberIDs) != cp.NumPoints {
+			t.Fatalf("result point %d has %d members and NumPoints %d", i, len(cp.memberIDs), cp.NumPoints)
+		}
+		for _, id := range cp.memberIDs {
+			if c.assignment[id] != i {
+				t.Fatalf("point %d is in result point %d, but assigned to %d", id, i, c.assignment[id])
+			}
+		}
+	}
+}
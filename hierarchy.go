@@ -0,0 +1,228 @@
+package cluster
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"sort"
+)
+
+// HierarchyLevel is the metadata of zoom level of the hierarchy
+// Clusters is the number of clusters of several points, Points is the number of all result points.
+type HierarchyLevel struct {
+	Zoom      int     `json:"zoom"`
+	Epsilon   float64 `json:"epsilon"`
+	MinPoints int     `json:"min_points"`
+	Clusters  int     `json:"clusters"`
+	Points    int     `json:"points"`
+}
+
+// HierarchyNode is the cluster or single point of the level with its children at the next served zoom
+// Nodes of the last level have no children, Leaves are input indexes of their members instead.
+type HierarchyNode struct {
+	Zoom        int              `json:"zoom"`
+	ID          int              `json:"id"`
+	Coordinates [2]float64       `json:"coordinates"`
+	NumPoints   int              `json:"point_count"`
+	Children    []*HierarchyNode `json:"children,omitempty"`
+	Leaves      []int            `json:"leaves,omitempty"`
+}
+
+// Hierarchy is the tree of clusters of all levels, Roots are result points of the smallest zoom
+type Hierarchy struct {
+	Levels []HierarchyLevel `json:"levels"`
+	Roots  []*HierarchyNode `json:"roots"`
+}
+
+// Hierarchy returns how clusters roll up from the largest zoom to the smallest one
+// Levels are clustered independently, so the parent of the node is the cluster of the previous zoom containing
+// most of its members, the first one of equal. All levels should cluster the same points.
+func (l Levels) Hierarchy() (*Hierarchy, error) {
+	zooms, err := l.hierarchyZooms("Hierarchy")
+	if err != nil {
+		return nil, err
+	}
+
+	h := &Hierarchy{Levels: make([]HierarchyLevel, len(zooms))}
+	var parents []*HierarchyNode
+	for k, zoom := range zooms {
+		c := l[zoom]
+		level := HierarchyLevel{Zoom: zoom, Epsilon: c.Epsilon, MinPoints: c.MinPoints, Points: len(c.ResultPoints)}
+		nodes := make([]*HierarchyNode, len(c.ResultPoints))
+		for i := range c.ResultPoints {
+			p := &c.ResultPoints[i]
+			if p.NumPoints > 1 {
+				level.Clusters++
+			}
+			nodes[i] = &HierarchyNode{Zoom: zoom, ID: p.Id, Coordinates: [2]float64{p.X, p.Y}, NumPoints: p.NumPoints}
+			if k == len(zooms)-1 {
+				nodes[i].Leaves = p.memberIDs
+			}
+			if k == 0 {
+				h.Roots = append(h.Roots, nodes[i])
+				continue
+			}
+			parent := parents[l[zooms[k-1]].containing(p.memberIDs)]
+			parent.Children = append(parent.Children, nodes[i])
+		}
+		h.Levels[k] = level
+		parents = nodes
+	}
+	return h, nil
+}
+
+// WriteHierarchyJSON writes Hierarchy of the levels as nested JSON tree
+func (l Levels) WriteHierarchyJSON(w io.Writer) error {
+	h, err := l.Hierarchy()
+	if err != nil {
+		return err
+	}
+	return json.NewEncoder(w).Encode(h)
+}
+
+// hierarchyZooms returns sorted zooms of the levels, checking all of them are clustered over the same points
+func (l Levels) hierarchyZooms(method string) ([]int, error) {
+	if len(l) == 0 {
+		return nil, errors.New("gocluster: no levels")
+	}
+	zooms := make([]int, 0, len(l))
+	for zoom, c := range l {
+		if c.baseIndex == nil {
+			return nil, notBuilt(method)
+		}
+		zooms = append(zooms, zoom)
+	}
+	sort.Ints(zooms)
+	n := l[zooms[0]].numInputPoints()
+	for _, zoom := range zooms[1:] {
+		if l[zoom].numInputPoints() != n {
+			return nil, errors.New("gocluster: levels should cluster the same points")
+		}
+	}
+	return zooms, nil
+}
+
+// containing returns index in ResultPoints of the result point containing most of input points ids
+func (c *Cluster) containing(ids []int) int {
+	if len(ids) == 1 {
+		return c.assignment[ids[0]]
+	}
+	counts := map[int]int{}
+	best, bestCount := -1, 0
+	for _, id := range ids {
+		r := c.assignment[id]
+		counts[r]++
+		if n := counts[r]; n > bestCount || n == bestCount && r < best {
+			best, bestCount = r, n
+		}
+	}
+	return best
+}
+
+// ParentOf returns the cluster of the previous served zoom containing the cluster or single point with id at the zoom
+// Parents are the same as in Hierarchy, ok is false if there is no such point or the zoom is the smallest one.
+func (l Levels) ParentOf(id, zoom int) (parent ClusterPoint, ok bool) {
+	return l.AncestorOf(id, zoom, l.previousZoom(zoom))
+}
+
+// AncestorOf returns the cluster of ancestorZoom containing the cluster or single point with id at the zoom,
+// e.g. zoom 5 cluster of zoom 12 cluster, it follows parents of every served zoom between them
+func (l Levels) AncestorOf(id, zoom, ancestorZoom int) (ancestor ClusterPoint, ok bool) {
+	c, ok := l[zoom]
+	if !ok || l[ancestorZoom] == nil || ancestorZoom > zoom {
+		return ClusterPoint{}, false
+	}
+	i, ok := c.resultByID(id)
+	if !ok {
+		return ClusterPoint{}, false
+	}
+	for zoom > ancestorZoom {
+		previous := l.previousZoom(zoom)
+		p := l[previous]
+		if p.numInputPoints() != c.numInputPoints() {
+			return ClusterPoint{}, false
+		}
+		i = p.containing(c.ResultPoints[i].memberIDs)
+		zoom, c = previous, p
+	}
+	return c.ResultPoints[i], true
+}
+
+// Children returns clusters and single points of the next served zoom whose parent is the point with id at the zoom
+// They are in the same order as in AllClusters, nil for the largest zoom.
+func (l Levels) Children(id, zoom int) []ClusterPoint {
+	c, ok := l[zoom]
+	if !ok {
+		return nil
+	}
+	i, ok := c.resultByID(id)
+	if !ok {
+		return nil
+	}
+	return l.children(zoom, i)
+}
+
+// Siblings returns other children of the parent of the point with id at the zoom, nil for the smallest zoom
+func (l Levels) Siblings(id, zoom int) []ClusterPoint {
+	parent, ok := l.ParentOf(id, zoom)
+	if !ok {
+		return nil
+	}
+	previous := l.previousZoom(zoom)
+	i, _ := l[previous].resultByID(parent.Id)
+	var siblings []ClusterPoint
+	for _, child := range l.children(previous, i) {
+		if child.Id != id {
+			siblings = append(siblings, child)
+		}
+	}
+	return siblings
+}
+
+// children returns result points of the next zoom whose parent is i-th result point of the zoom
+func (l Levels) children(zoom, i int) []ClusterPoint {
+	next, ok := l[l.nextZoom(zoom)]
+	c := l[zoom]
+	if !ok || next.numInputPoints() != c.numInputPoints() {
+		return nil
+	}
+	//children contain some of the members, so only result points of members are checked
+	var candidates []int
+	seen := map[int]bool{}
+	for _, id := range c.ResultPoints[i].memberIDs {
+		if j := next.assignment[id]; !seen[j] {
+			seen[j] = true
+			candidates = append(candidates, j)
+		}
+	}
+	sort.Ints(candidates)
+	var result []ClusterPoint
+	for _, j := range candidates {
+		if c.containing(next.ResultPoints[j].memberIDs) == i {
+			result = append(result, next.ResultPoints[j])
+		}
+	}
+	return result
+}
+
+// previousZoom returns the largest served zoom smaller than zoom, -1 if there is no such one
+func (l Levels) previousZoom(zoom int) int {
+	previous := -1
+	for z := range l {
+		if z < zoom && z > previous {
+			previous = z
+		}
+	}
+	return previous
+}
+
+// nextZoom returns the smallest served zoom larger than zoom, -1 if there is no such one
+func (l Levels) nextZoom(zoom int) int {
+	next := -1
+	for z := range l {
+		if z > zoom && (next < 0 || z < next) {
+			next = z
+		}
+	}
+	return next
+}
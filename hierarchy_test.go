@@ -0,0 +1,197 @@
+package cluster
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// testLevels clusters the same points at every zoom
+func testLevels(t *testing.T, points []GeoPoint, zooms ...int) Levels {
+	levels := Levels{}
+	for _, zoom := range zooms {
+		c, err := NewClusterForZoom(zoom, 256, 40)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := c.ClusterPoints(points); err != nil {
+			t.Fatal(err)
+		}
+		levels[zoom] = c
+	}
+	return levels
+}
+
+func TestHierarchy(t *testing.T) {
+	points := randomPoints(2000, 40, -60, -60, 60, 60)
+	levels := testLevels(t, points, 5, 2, 3)
+	h, err := levels.Hierarchy()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(h.Levels) != 3 || h.Levels[0].Zoom != 2 || h.Levels[1].Zoom != 3 || h.Levels[2].Zoom != 5 {
+		t.Fatalf("levels %+v, want zooms 2, 3, 5", h.Levels)
+	}
+	for _, level := range h.Levels {
+		c := levels[level.Zoom]
+		clusters := 0
+		for _, cp := range c.ResultPoints {
+			if cp.NumPoints > 1 {
+				clusters++
+			}
+		}
+		if level.Points != len(c.ResultPoints) || level.Clusters != clusters || level.Epsilon != c.Epsilon {
+			t.Fatalf("level %+v of %d result points, %d clusters", level, len(c.ResultPoints), clusters)
+		}
+	}
+
+	//every result point is in the tree once, under the parent containing most of its members
+	counts := map[int]int{}
+	leaves := map[int]bool{}
+	var walk func(node, parent *HierarchyNode)
+	walk = func(node, parent *HierarchyNode) {
+		counts[node.Zoom]++
+		c := levels[node.Zoom]
+		i := resultIndexByID(c, node.ID)
+		if i < 0 || c.ResultPoints[i].NumPoints != node.NumPoints {
+			t.Fatalf("node %+v is not a result point", node)
+		}
+		if parent != nil {
+			p := levels[parent.Zoom]
+			inParents := map[int]int{}
+			for _, id := range c.ResultPoints[i].memberIDs {
+				inParents[p.assignment[id]]++
+			}
+			own := inParents[resultIndexByID(p, parent.ID)]
+			for _, n := range inParents {
+				if n > own {
+					t.Fatalf("node %d of zoom %d has %d members in its parent, %d in another one", node.ID, node.Zoom, own, n)
+				}
+			}
+		}
+		if node.Zoom == 5 {
+			if len(node.Children) != 0 || len(node.Leaves) != node.NumPoints {
+				t.Fatalf("last level node %d has %d children, %d leaves", node.ID, len(node.Children), len(node.Leaves))
+			}
+			for _, id := range node.Leaves {
+				leaves[id] = true
+			}
+		}
+		for _, child := range node.Children {
+			walk(child, node)
+		}
+	}
+	for _, root := range h.Roots {
+		walk(root, nil)
+	}
+	for _, level := range h.Levels {
+		if counts[level.Zoom] != level.Points {
+			t.Fatalf("%d nodes of zoom %d, want %d", counts[level.Zoom], level.Zoom, level.Points)
+		}
+	}
+	if len(leaves) != len(points) {
+		t.Fatalf("%d leaves of %d points", len(leaves), len(points))
+	}
+
+	var buf bytes.Buffer
+	if err := levels.WriteHierarchyJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var decoded Hierarchy
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(&decoded, h) {
+		t.Fatal("decoded JSON differs from Hierarchy")
+	}
+}
+
+// resultIndexByID returns index in ResultPoints of the point with id, -1 if there is none
+func resultIndexByID(c *Cluster, id int) int {
+	for i := range c.ResultPoints {
+		if c.ResultPoints[i].Id == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func TestHierarchyErrors(t *testing.T) {
+	if _, err := (Levels{}).Hierarchy(); err == nil {
+		t.Fatal("expected error of no levels")
+	}
+	if _, err := (Levels{3: NewCluster(0.01)}).Hierarchy(); err == nil {
+		t.Fatal("expected error of not clustered level")
+	}
+	levels := testLevels(t, randomPoints(100, 41, -60, -60, 60, 60), 3)
+	levels[4] = testLevels(t, randomPoints(50, 41, -60, -60, 60, 60), 4)[4]
+	if _, err := levels.Hierarchy(); err == nil {
+		t.Fatal("expected error of levels of different points")
+	}
+}
+
+func TestNavigation(t *testing.T) {
+	levels := testLevels(t, randomPoints(2000, 42, -60, -60, 60, 60), 2, 3, 5)
+	h, err := levels.Hierarchy()
+	if err != nil {
+		t.Fatal(err)
+	}
+	//navigation follows the same parents as Hierarchy
+	var walk func(node, parent, root *HierarchyNode)
+	walk = func(node, parent, root *HierarchyNode) {
+		got, ok := levels.ParentOf(node.ID, node.Zoom)
+		if parent == nil && ok || parent != nil && (!ok || got.Id != parent.ID) {
+			t.Fatalf("parent of node %d of zoom %d is %d, %v", node.ID, node.Zoom, got.Id, ok)
+		}
+		children := levels.Children(node.ID, node.Zoom)
+		if len(children) != len(node.Children) {
+			t.Fatalf("node %d of zoom %d has %d children, want %d", node.ID, node.Zoom, len(children), len(node.Children))
+		}
+		for i, child := range children {
+			if child.Id != node.Children[i].ID {
+				t.Fatalf("child %d of node %d is %d, want %d", i, node.ID, child.Id, node.Children[i].ID)
+			}
+		}
+		if parent != nil {
+			siblings := levels.Siblings(node.ID, node.Zoom)
+			if len(siblings) != len(parent.Children)-1 {
+				t.Fatalf("node %d of zoom %d has %d siblings of %d children", node.ID, node.Zoom, len(siblings), len(parent.Children))
+			}
+			for _, s := range siblings {
+				if s.Id == node.ID {
+					t.Fatalf("node %d of zoom %d is its own sibling", node.ID, node.Zoom)
+				}
+			}
+		}
+		for _, child := range node.Children {
+			walk(child, node, root)
+		}
+		if node.Zoom == 5 {
+			ancestor, ok := levels.AncestorOf(node.ID, 5, 2)
+			if !ok || ancestor.Id != root.ID {
+				t.Fatalf("ancestor of node %d is %d, want %d", node.ID, ancestor.Id, root.ID)
+			}
+		}
+	}
+	for _, root := range h.Roots {
+		walk(root, nil, root)
+	}
+
+	id := levels[5].ResultPoints[0].Id
+	if _, ok := levels.AncestorOf(id, 5, 4); ok {
+		t.Fatal("expected no ancestor at not served zoom")
+	}
+	if _, ok := levels.AncestorOf(levels[2].ResultPoints[0].Id, 2, 5); ok {
+		t.Fatal("expected no ancestor at larger zoom")
+	}
+	if cp, ok := levels.AncestorOf(id, 5, 5); !ok || cp.Id != id {
+		t.Fatalf("ancestor at the same zoom is %d, %v", cp.Id, ok)
+	}
+	if _, ok := levels.ParentOf(-1, 5); ok {
+		t.Fatal("expected no parent of unknown id")
+	}
+	if levels.Children(id, 5) != nil || levels.Children(-1, 3) != nil {
+		t.Fatal("expected no children of the largest zoom and unknown id")
+	}
+}
@@ -0,0 +1,255 @@
+package cluster
+
+import (
+	"compress/gzip"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ClustersHandler is http.Handler answering bbox queries with GeoJSON FeatureCollection, as MarshalGeoJSON encodes it:
+//
+//	GET /clusters?bbox=west,south,east,north&zoom=4
+//	GET /clusters?bbox=west,south,east,north&zoom=4&leaves=5&leaf_properties=name,rating
+//	GET /clusters?bbox=west,south,east,north&zoom=4&from=2020-01-01T00:00:00Z&to=2020-02-01T00:00:00Z
+//	GET /clusters?bbox=west,south,east,north&zoom=4&where=status:active,pending&where=type:car
+//
+// leaves embeds up to the number of sampled members into each cluster, see SampleLeaves,
+// leaf_properties limits their properties. from and to are RFC 3339 timestamps of the window, see GetClustersBetween.
+// where is the property and its values members should have, see PropertyEquals and FilterClusters; all of them
+// should match if there are several.
+// ETag is the Version of the Cluster, so unchanged viewports are answered with 304 Not Modified for If-None-Match.
+// Responses are gzipped for clients accepting it.
+type ClustersHandler struct {
+	// Cluster returns Cluster for the zoom level, ok is false if the zoom is not served
+	Cluster func(zoom int) (c *Cluster, ok bool)
+	// Cache keeps encoded responses of hot viewports if it's set
+	Cache *Cache
+	// Precision is the number of decimal digits of coordinates and numeric properties, see MarshalGeoJSONPrecision,
+	// they are not rounded if it's 0
+	Precision int
+}
+
+// NewClustersHandler returns handler serving Clusters of zoom levels, e.g. created by NewClusterForZoom
+// Clusters should not be changed while the handler serves them.
+func NewClustersHandler(levels Levels) *ClustersHandler {
+	return &ClustersHandler{Cluster: levels.At}
+}
+
+func (h *ClustersHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	query := r.URL.Query()
+	zoom, err := strconv.Atoi(query.Get("zoom"))
+	if err != nil {
+		http.Error(w, "invalid zoom", http.StatusBadRequest)
+		return
+	}
+	northWest, southEast, err := parseBBox(query.Get("bbox"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var sample SampleOptions
+	if v := query.Get("leaves"); v != "" {
+		if sample.Limit, err = strconv.Atoi(v); err != nil || sample.Limit < 0 {
+			http.Error(w, "invalid leaves", http.StatusBadRequest)
+			return
+		}
+	}
+	if v := query.Get("leaf_properties"); v != "" {
+		sample.Properties = strings.Split(v, ",")
+	}
+	window, err := parseTimeWindow(query.Get("from"), query.Get("to"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	where, err := parseWhere(query["where"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	c, ok := h.Cluster(zoom)
+	if !ok {
+		http.Error(w, fmt.Sprintf("zoom %d is not served", zoom), http.StatusNotFound)
+		return
+	}
+
+	gzipped := acceptsGzip(r)
+	etag := fmt.Sprintf(`"%d"`, c.Version())
+	if gzipped {
+		etag = fmt.Sprintf(`"%d-gzip"`, c.Version())
+	}
+	header := w.Header()
+	header.Set("ETag", etag)
+	header.Add("Vary", "Accept-Encoding")
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	data, err := h.geoJSON(c, northWest, southEast, sample, window, where)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	header.Set("Content-Type", "application/geo+json")
+	if !gzipped {
+		header.Set("Content-Length", strconv.Itoa(len(data)))
+		if r.Method == http.MethodGet {
+			w.Write(data)
+		}
+		return
+	}
+	header.Set("Content-Encoding", "gzip")
+	if r.Method == http.MethodGet {
+		gz := gzip.NewWriter(w)
+		gz.Write(data)
+		gz.Close()
+	}
+}
+
+func (h *ClustersHandler) geoJSON(c *Cluster, northWest, southEast GeoCoordinates, sample SampleOptions,
+	window *timeWindow, where *propertyFilter) ([]byte, error) {
+	encode := func() (interface{}, error) {
+		var points []ClusterPoint
+		if window != nil {
+			points = c.GetClustersBetween(northWest, southEast, window.from, window.to)
+		} else {
+			points = c.GetClusters(northWest, southEast)
+		}
+		if where != nil {
+			points = c.FilterClusters(points, where.filter)
+		}
+		if sample.Limit > 0 {
+			points = SampleLeaves(points, sample)
+		}
+		if h.Precision > 0 {
+			return MarshalGeoJSONPrecision(points, h.Precision)
+		}
+		return MarshalGeoJSON(points)
+	}
+	var data interface{}
+	var err error
+	if h.Cache == nil {
+		data, err = encode()
+	} else {
+		query := fmt.Sprintf("geojson/%v,%v,%v,%v/%d/%q", northWest.Lon, southEast.Lat, southEast.Lon, northWest.Lat,
+			sample.Limit, sample.Properties)
+		if window != nil {
+			query += fmt.Sprintf("/%d-%d", window.from.UnixNano(), window.to.UnixNano())
+		}
+		if where != nil {
+			query += fmt.Sprintf("/where%q", where.terms)
+		}
+		if h.Precision > 0 {
+			//handlers of different precision could share the Cache
+			query += fmt.Sprintf("/precision%d", h.Precision)
+		}
+		data, err = h.Cache.Get(c, query, encode)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return data.([]byte), nil
+}
+
+// parseBBox parses west,south,east,north box, west is greater than east for box crossing antimeridian
+func parseBBox(s string) (northWest, southEast GeoCoordinates, err error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != 4 {
+		return northWest, southEast, fmt.Errorf("bbox should be west,south,east,north, got %q", s)
+	}
+	var v [4]float64
+	for i, part := range parts {
+		if v[i], err = strconv.ParseFloat(strings.TrimSpace(part), 64); err != nil {
+			return northWest, southEast, fmt.Errorf("invalid bbox value %q", part)
+		}
+	}
+	if v[1] > v[3] {
+		return northWest, southEast, fmt.Errorf("bbox south %v is greater than north %v", v[1], v[3])
+	}
+	return GeoCoordinates{Lon: v[0], Lat: v[3]}, GeoCoordinates{Lon: v[2], Lat: v[1]}, nil
+}
+
+// timeWindow is the window of GetClustersBetween query
+type timeWindow struct {
+	from, to time.Time
+}
+
+// parseTimeWindow parses RFC 3339 from and to, window is nil if neither is set
+func parseTimeWindow(from, to string) (*timeWindow, error) {
+	if from == "" && to == "" {
+		return nil, nil
+	}
+	if from == "" || to == "" {
+		return nil, fmt.Errorf("from and to should be set together")
+	}
+	var window timeWindow
+	var err error
+	if window.from, err = time.Parse(time.RFC3339Nano, from); err != nil {
+		return nil, fmt.Errorf("invalid from %q", from)
+	}
+	if window.to, err = time.Parse(time.RFC3339Nano, to); err != nil {
+		return nil, fmt.Errorf("invalid to %q", to)
+	}
+	if window.to.Before(window.from) {
+		return nil, fmt.Errorf("from %s is after to %s", from, to)
+	}
+	return &window, nil
+}
+
+// propertyFilter is the filter of where parameters, terms are key:values ones, sorted
+type propertyFilter struct {
+	terms  []string
+	filter PointFilter
+}
+
+// parseWhere parses key:value,value terms into PropertyEquals filters which all should match, filter is nil without terms
+func parseWhere(terms []string) (*propertyFilter, error) {
+	if len(terms) == 0 {
+		return nil, nil
+	}
+	filters := make([]PointFilter, len(terms))
+	for i, term := range terms {
+		colon := strings.IndexByte(term, ':')
+		if colon <= 0 {
+			return nil, fmt.Errorf("invalid where %q, key:value expected", term)
+		}
+		filters[i] = PropertyEquals(term[:colon], strings.Split(term[colon+1:], ",")...)
+	}
+	sorted := append([]string(nil), terms...)
+	sort.Strings(sorted)
+	return &propertyFilter{terms: sorted, filter: AllOf(filters...)}, nil
+}
+
+func acceptsGzip(r *http.Request) bool {
+	for _, encoding := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		encoding = strings.TrimSpace(encoding)
+		if encoding == "gzip" || strings.HasPrefix(encoding, "gzip;") && !strings.HasSuffix(encoding, "q=0") {
+			return true
+		}
+	}
+	return false
+}
+
+// etagMatches checks If-None-Match header, weak comparison is used as RFC 7232 requires
+func etagMatches(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	for _, tag := range strings.Split(ifNoneMatch, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == etag {
+			return true
+		}
+	}
+	return false
+}
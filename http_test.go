@@ -0,0 +1,141 @@
+package cluster
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// serve returns the response of the handler to GET of the url with headers
+func serve(h http.Handler, url string, headers ...string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, url, nil)
+	for i := 0; i+1 < len(headers); i += 2 {
+		r.Header.Set(headers[i], headers[i+1])
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+// featuresOf decodes features of GeoJSON FeatureCollection
+func featuresOf(t *testing.T, data []byte) []map[string]interface{} {
+	t.Helper()
+	var collection struct {
+		Features []map[string]interface{} `json:"features"`
+	}
+	if err := json.Unmarshal(data, &collection); err != nil {
+		t.Fatalf("%v: %s", err, data)
+	}
+	return collection.Features
+}
+
+// testHandler returns handler of the cluster of random points at zoom 4
+func testHandler(t *testing.T) (*ClustersHandler, *Cluster) {
+	c, err := NewClusterForZoom(4, 256, 40)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.ClusterPoints(randomPoints(2000, 16, -60, -60, 60, 60)); err != nil {
+		t.Fatal(err)
+	}
+	return NewClustersHandler(Levels{4: c}), c
+}
+
+func TestClustersHandler(t *testing.T) {
+	h, c := testHandler(t)
+	const url = "/clusters?bbox=-20,-10,40,30&zoom=4"
+	want := len(c.GetClusters(GeoCoordinates{Lon: -20, Lat: 30}, GeoCoordinates{Lon: 40, Lat: -10}))
+
+	w := serve(h, url)
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/geo+json" {
+		t.Fatalf("response %d %v", w.Code, w.Header())
+	}
+	if features := featuresOf(t, w.Body.Bytes()); len(features) != want {
+		t.Fatalf("%d features, want %d", len(features), want)
+	}
+	etag := w.Header().Get("ETag")
+	if w := serve(h, url, "If-None-Match", etag); w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Fatalf("response of the same ETag %d", w.Code)
+	}
+
+	//gzipped response has its own ETag
+	w = serve(h, url, "Accept-Encoding", "deflate, gzip")
+	if w.Header().Get("Content-Encoding") != "gzip" || w.Header().Get("ETag") == etag {
+		t.Fatalf("gzipped response headers %v", w.Header())
+	}
+	gz, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadAll(gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if features := featuresOf(t, data); len(features) != want {
+		t.Fatalf("%d gzipped features, want %d", len(features), want)
+	}
+
+	//new version of clusters is not matched by old ETag
+	if err := c.ReclusterWithEpsilon(c.Epsilon * 2); err != nil {
+		t.Fatal(err)
+	}
+	if w := serve(h, url, "If-None-Match", etag); w.Code != http.StatusOK {
+		t.Fatalf("response of old ETag %d", w.Code)
+	}
+}
+
+func TestClustersHandlerErrors(t *testing.T) {
+	h, _ := testHandler(t)
+	urls := map[string]int{
+		"/clusters?bbox=-20,-10,40,30":          http.StatusBadRequest,
+		"/clusters?bbox=-20,-10,40&zoom=4":      http.StatusBadRequest,
+		"/clusters?bbox=-20,30,40,-10&zoom=4":   http.StatusBadRequest,
+		"/clusters?bbox=-20,a,40,30&zoom=4":     http.StatusBadRequest,
+		"/clusters?bbox=-20,-10,40,30&zoom=5":   http.StatusNotFound,
+		"/clusters?bbox=-20,-10,40,30&zoom=abc": http.StatusBadRequest,
+	}
+	for url, code := range urls {
+		if w := serve(h, url); w.Code != code {
+			t.Errorf("%s: response %d, want %d", url, w.Code, code)
+		}
+	}
+	r := httptest.NewRequest(http.MethodPost, "/clusters?bbox=-20,-10,40,30&zoom=4", nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusMethodNotAllowed || w.Header().Get("Allow") != "GET, HEAD" {
+		t.Fatalf("POST response %d %v", w.Code, w.Header())
+	}
+}
+
+func TestClustersHandlerLeaves(t *testing.T) {
+	h, _ := testHandler(t)
+	w := serve(h, "/clusters?bbox=-20,-10,40,30&zoom=4&leaves=2&leaf_properties=n")
+	if w.Code != http.StatusOK {
+		t.Fatalf("response %d: %s", w.Code, w.Body)
+	}
+	clusters := 0
+	for _, f := range featuresOf(t, w.Body.Bytes()) {
+		properties := f["properties"].(map[string]interface{})
+		if properties["cluster"] != true {
+			continue
+		}
+		clusters++
+		leaves, _ := properties["sample_leaves"].([]interface{})
+		if len(leaves) != 2 {
+			t.Fatalf("cluster has sample leaves %v", properties["sample_leaves"])
+		}
+		leaf := leaves[0].(map[string]interface{})
+		if _, ok := leaf["properties"].(map[string]interface{})["n"]; !ok || leaf["coordinates"] == nil || leaf["id"] == nil {
+			t.Fatalf("sampled leaf is %v", leaf)
+		}
+	}
+	if clusters == 0 {
+		t.Fatal("no clusters in the box")
+	}
+	if w := serve(h, "/clusters?bbox=-20,-10,40,30&zoom=4&leaves=-1"); w.Code != http.StatusBadRequest {
+		t.Fatalf("response of negative leaves %d", w.Code)
+	}
+}
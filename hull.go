@@ -0,0 +1,88 @@
+package cluster
+
+import "sort"
+
+// Polygon is the area output, such as cluster hull or grid cell
+// Ring is the outer ring in Lon/Lat coordinates, counterclockwise and not closed
+type Polygon struct {
+	ID         interface{}
+	Ring       []GeoCoordinates
+	Properties map[string]interface{}
+}
+
+// ConvexHull returns convex hull of the cluster members, counterclockwise and not closed
+// Hull of less than 3 points, or of points on one line, has less than 3 vertices
+func ConvexHull(cp ClusterPoint) []GeoCoordinates {
+	points := make([]GeoCoordinates, len(cp.IncludedPoints))
+	for i, p := range cp.IncludedPoints {
+		points[i] = p.GetCoordinates()
+	}
+	return convexHull(points)
+}
+
+// HullPolygons returns convex hulls of clusters, as they returned by AllClusters
+// Properties are the same as in MarshalGeoJSON, single points and clusters with degenerated hulls are skipped
+func HullPolygons(points []ClusterPoint) []Polygon {
+	var result []Polygon
+	for i := range points {
+		p := &points[i]
+		if p.NumPoints < 2 {
+			continue
+		}
+		ring := ConvexHull(*p)
+		if len(ring) < 3 {
+			continue
+		}
+		result = append(result, Polygon{
+			ID:         clusterFeatureID(p),
+			Ring:       ring,
+			Properties: ClusterProperties(p),
+		})
+	}
+	return result
+}
+
+// convexHull is Andrew's monotone chain, points slice is reordered
+func convexHull(points []GeoCoordinates) []GeoCoordinates {
+	sort.Slice(points, func(i, j int) bool {
+		if points[i].Lon == points[j].Lon {
+			return points[i].Lat < points[j].Lat
+		}
+		return points[i].Lon < points[j].Lon
+	})
+	//skip duplicates, they break the chain
+	n := 0
+	for i, p := range points {
+		if i == 0 || p != points[n-1] {
+			points[n] = p
+			n++
+		}
+	}
+	points = points[:n]
+	if n < 3 {
+		return points
+	}
+
+	cross := func(o, a, b GeoCoordinates) float64 {
+		return (a.Lon-o.Lon)*(b.Lat-o.Lat) - (a.Lat-o.Lat)*(b.Lon-o.Lon)
+	}
+	hull := make([]GeoCoordinates, 0, 2*n)
+	//lower chain
+	for _, p := range points {
+		for len(hull) >= 2 && cross(hull[len(hull)-2], hull[len(hull)-1], p) <= 0 {
+			hull = hull[:len(hull)-1]
+		}
+		hull = append(hull, p)
+	}
+	//upper chain
+	lower := len(hull) + 1
+	for i := n - 2; i >= 0; i-- {
+		p := points[i]
+		for len(hull) >= lower && cross(hull[len(hull)-2], hull[len(hull)-1], p) <= 0 {
+			hull = hull[:len(hull)-1]
+		}
+		hull = append(hull, p)
+	}
+	//the last point is the first one
+	return hull[:len(hull)-1]
+}
@@ -0,0 +1,176 @@
+package cluster
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// IDGenerator mints ids of new clusters, see Cluster.IDGenerator
+// zoom is Zoom of the Cluster and members are input ids of points of the cluster, they should not be changed.
+// Ids should be positive and different from ids of single points, which are indexes of input points.
+// Generators of the package are safe for concurrent use, so several Clusters could share one id space.
+type IDGenerator interface {
+	NextID(zoom int, members []int) int
+}
+
+// IDForgetter is implemented by generators which keep state of minted ids, e.g. UUIDIDs
+// Cluster calls Forget with ids it minted which are no longer in ResultPoints, after clustering and updates,
+// so the state doesn't grow with rebuilds. Several Clusters sharing the generator forget only their own ids.
+type IDForgetter interface {
+	Forget(ids []int)
+}
+
+// SequentialIDs mints consecutive ids, shared by several Clusters they never collide
+type SequentialIDs struct {
+	next int64
+}
+
+// NewSequentialIDs creates SequentialIDs starting from start, which should be above the number of input points
+func NewSequentialIDs(start int) *SequentialIDs {
+	return &SequentialIDs{next: int64(start)}
+}
+
+// NextID implements IDGenerator interface
+func (s *SequentialIDs) NextID(zoom int, members []int) int {
+	return int(atomic.AddInt64(&s.next, 1) - 1)
+}
+
+// snowflake ids are 41 bits of milliseconds since the epoch, 10 bits of the node and 12 bits of the sequence
+const (
+	snowflakeNodeBits     = 10
+	snowflakeSequenceBits = 12
+)
+
+// snowflakeEpoch is 2020-01-01 UTC, 41 bits of milliseconds since it last for 69 years
+var snowflakeEpoch = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// SnowflakeIDs mints Twitter snowflake ids, unique across processes with different nodes:
+// milliseconds since 2020, node and sequence number within the millisecond.
+// Ids are 63 bits, so they need 64-bit int, and JavaScript clients should read them as strings or BigInt.
+type SnowflakeIDs struct {
+	mu       sync.Mutex
+	node     int64
+	last     int64 //millisecond of the last id
+	sequence int64
+}
+
+// NewSnowflakeIDs creates SnowflakeIDs of the node, which is in [0..1023] range
+func NewSnowflakeIDs(node int) (*SnowflakeIDs, error) {
+	if node < 0 || node >= 1<<snowflakeNodeBits {
+		return nil, fmt.Errorf("gocluster: snowflake node %d is out of range", node)
+	}
+	return &SnowflakeIDs{node: int64(node)}, nil
+}
+
+// NextID implements IDGenerator interface
+func (s *SnowflakeIDs) NextID(zoom int, members []int) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Since(snowflakeEpoch).Milliseconds()
+	if now < s.last {
+		//clock went back, ids continue from the last millisecond
+		now = s.last
+	}
+	if now == s.last {
+		s.sequence = (s.sequence + 1) & (1<<snowflakeSequenceBits - 1)
+		if s.sequence == 0 {
+			//sequence is exhausted, ids borrow the next millisecond
+			now++
+		}
+	} else {
+		s.sequence = 0
+	}
+	s.last = now
+	return int(now<<(snowflakeNodeBits+snowflakeSequenceBits) | s.node<<snowflakeSequenceBits | s.sequence)
+}
+
+// UUIDIDs mints random UUIDs for clusters and maps them to consecutive int ids,
+// e.g. for stores keyed by UUID, UUID and ID convert between them. Ids of clusters which Cluster no longer has are forgotten.
+type UUIDIDs struct {
+	mu    sync.Mutex
+	next  int
+	uuids map[int]string
+	ids   map[string]int
+}
+
+// NewUUIDIDs creates UUIDIDs with int ids starting from start, which should be above the number of input points
+func NewUUIDIDs(start int) *UUIDIDs {
+	return &UUIDIDs{next: start, uuids: map[int]string{}, ids: map[string]int{}}
+}
+
+// NextID implements IDGenerator interface
+func (u *UUIDIDs) NextID(zoom int, members []int) int {
+	uuid := newUUID()
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	id := u.next
+	u.next++
+	u.uuids[id], u.ids[uuid] = uuid, id
+	return id
+}
+
+// UUID returns UUID of the cluster id, ok is false if the id was not minted by u
+func (u *UUIDIDs) UUID(id int) (uuid string, ok bool) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	uuid, ok = u.uuids[id]
+	return uuid, ok
+}
+
+// ID returns cluster id of the UUID, ok is false if the UUID was not minted by u
+func (u *UUIDIDs) ID(uuid string) (id int, ok bool) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	id, ok = u.ids[uuid]
+	return id, ok
+}
+
+// Forget implements IDForgetter interface, UUID and ID don't know forgotten ids any more
+func (u *UUIDIDs) Forget(ids []int) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	for _, id := range ids {
+		if uuid, ok := u.uuids[id]; ok {
+			delete(u.uuids, id)
+			delete(u.ids, uuid)
+		}
+	}
+}
+
+// newUUID returns random version 4 UUID
+func newUUID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(fmt.Sprintf("gocluster: can't read random bytes: %v", err))
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// ContentHashIDs mints ids from hash of the zoom and members of the cluster, so the same cluster gets the same id
+// in every build and process, e.g. for caches and client state kept across rebuilds.
+// Ids are FNV-1a hashes cut to 53 bits, so JavaScript numbers keep them exactly; clusters of the same members
+// at the same zoom share the id.
+type ContentHashIDs struct{}
+
+// NextID implements IDGenerator interface
+func (ContentHashIDs) NextID(zoom int, members []int) int {
+	sorted := append([]int(nil), members...)
+	sort.Ints(sorted)
+	h := fnv.New64a()
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], uint64(zoom))
+	h.Write(b[:])
+	for _, id := range sorted {
+		binary.LittleEndian.PutUint64(b[:], uint64(id))
+		h.Write(b[:])
+	}
+	return int(h.Sum64() >> 11)
+}
@@ -0,0 +1,73 @@
+package cluster
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// checkUUIDs fails if u doesn't know UUID of some cluster of clusters or knows other ids
+func checkUUIDs(t *testing.T, u *UUIDIDs, clusters ...*Cluster) {
+	t.Helper()
+	//clusters passed through to the next level keep their ids
+	ids := map[int]bool{}
+	for _, c := range clusters {
+		for _, cp := range c.ResultPoints {
+			if cp.NumPoints == 1 {
+				continue
+			}
+			ids[cp.Id] = true
+			uuid, ok := u.UUID(cp.Id)
+			if !ok {
+				t.Fatalf("cluster %d has no UUID", cp.Id)
+			}
+			if id, ok := u.ID(uuid); !ok || id != cp.Id {
+				t.Fatalf("UUID %s is id %d, want %d", uuid, id, cp.Id)
+			}
+		}
+	}
+	if n := len(ids); len(u.uuids) != n || len(u.ids) != n {
+		t.Fatalf("UUIDIDs keeps %d ids and %d UUIDs of %d clusters", len(u.uuids), len(u.ids), n)
+	}
+}
+
+func TestUUIDIDsForgetClusters(t *testing.T) {
+	const n = 2000
+	u := NewUUIDIDs(n)
+	c, err := NewClusterForZoom(4, 256, 60)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.IDGenerator = u
+	points := randomPoints(n, 6, -30, -30, 30, 30)
+	if err := c.ClusterPoints(points); err != nil {
+		t.Fatal(err)
+	}
+	checkUUIDs(t, u, c)
+
+	for _, epsilon := range []float64{c.Epsilon * 2, c.Epsilon / 2, c.Epsilon} {
+		if err := c.ReclusterWithEpsilon(epsilon); err != nil {
+			t.Fatal(err)
+		}
+		checkUUIDs(t, u, c)
+	}
+
+	random := rand.New(rand.NewSource(7))
+	for i := 0; i < 30; i++ {
+		if err := c.UpdatePoint(random.Intn(n), GeoCoordinates{Lon: -30 + random.Float64()*60, Lat: -30 + random.Float64()*60}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	checkUUIDs(t, u, c)
+
+	if err := c.ClusterPoints(points[:n/2]); err != nil {
+		t.Fatal(err)
+	}
+	checkUUIDs(t, u, c)
+
+	//levels share the generator and forget only their own ids
+	levels, err := c.ClusterEpsilons(points, []float64{c.Epsilon, c.Epsilon * 2, c.Epsilon * 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkUUIDs(t, u, append(levels, c)...)
+}
@@ -0,0 +1,316 @@
+package cluster
+
+import (
+	"math"
+	"sync"
+)
+
+// CoordinatesMode defines how projected coordinates are stored inside the spatial index
+type CoordinatesMode int
+
+const (
+	// CoordinatesFloat64 keeps full float64 precision, it's the default
+	CoordinatesFloat64 CoordinatesMode = iota
+	// CoordinatesFloat32 stores coordinates as float32, precision is about 2 meters
+	CoordinatesFloat32
+	// CoordinatesFixed32 stores coordinates as 32-bit fixed point in [0..1] range, precision is about 1 centimeter
+	CoordinatesFixed32
+)
+
+// spatialIndex is a static index over projected points, used to find neighbours
+// AppendWithin appends found indices to dst, so the caller could reuse the buffer
+// Bytes returns approximate memory taken by the index
+type spatialIndex interface {
+	AppendWithin(dst []int, x, y, radius float64) []int
+	Range(minX, minY, maxX, maxY float64) []int
+	Bytes() int
+}
+
+// newSpatialIndex creates index for points depending on coordinates mode
+func newSpatialIndex(points []*ClusterPoint, nodeSize int, mode CoordinatesMode) spatialIndex {
+	ki := newKDIndex(len(points), nodeSize, mode)
+	for _, p := range points {
+		ki.add(p.X, p.Y)
+	}
+	return ki.build()
+}
+
+// kdIndex is the same flat KD-tree as kdbush, coordinates are stored as float64 for CoordinatesFloat64
+// and in 32 bits for other modes, so they take about half of the memory.
+// Points are added to backing arrays directly while they are projected, see clusterInput,
+// so there is no intermediate slice of points for the index.
+type kdIndex struct {
+	nodeSize int
+	mode     CoordinatesMode
+	idxs     []uint32
+	coords   []uint32  //32-bit coordinates
+	coords64 []float64 //coordinates of CoordinatesFloat64 mode
+}
+
+// newKDIndex creates empty index with capacity for n points, points are added by add and sorted by build
+func newKDIndex(n, nodeSize int, mode CoordinatesMode) *kdIndex {
+	ki := &kdIndex{
+		nodeSize: nodeSize,
+		mode:     mode,
+		idxs:     make([]uint32, 0, n),
+	}
+	if mode == CoordinatesFloat64 {
+		ki.coords64 = make([]float64, 0, 2*n)
+	} else {
+		ki.coords = make([]uint32, 0, 2*n)
+	}
+	return ki
+}
+
+// add appends point with the next index
+func (ki *kdIndex) add(x, y float64) {
+	ki.idxs = append(ki.idxs, uint32(len(ki.idxs)))
+	if ki.mode == CoordinatesFloat64 {
+		ki.coords64 = append(ki.coords64, x, y)
+	} else {
+		ki.coords = append(ki.coords, ki.encode(x), ki.encode(y))
+	}
+}
+
+// build sorts added points into KD-tree
+func (ki *kdIndex) build() *kdIndex {
+	ki.sort(0, len(ki.idxs)-1, 0)
+	return ki
+}
+
+// finish implements indexBuilder interface
+func (ki *kdIndex) finish() spatialIndex {
+	return ki.build()
+}
+
+func (ki *kdIndex) Bytes() int {
+	return 4*cap(ki.idxs) + 4*cap(ki.coords) + 8*cap(ki.coords64)
+}
+
+// encode coordinate in [0..1] range to 32 bits
+func (ki *kdIndex) encode(v float64) uint32 {
+	if ki.mode == CoordinatesFloat32 {
+		return math.Float32bits(float32(v))
+	}
+	if v <= 0 {
+		return 0
+	}
+	if v >= 1 {
+		return math.MaxUint32
+	}
+	return uint32(math.Round(v * math.MaxUint32))
+}
+
+func (ki *kdIndex) decode(v uint32) float64 {
+	if ki.mode == CoordinatesFloat32 {
+		return float64(math.Float32frombits(v))
+	}
+	return float64(v) / math.MaxUint32
+}
+
+func (ki *kdIndex) at(i int) (float64, float64) {
+	if ki.coords64 != nil {
+		return ki.coords64[2*i], ki.coords64[2*i+1]
+	}
+	return ki.decode(ki.coords[2*i]), ki.decode(ki.coords[2*i+1])
+}
+
+// AppendWithin finds all items within a given radius from the query point and appends their indices to result
+func (ki *kdIndex) AppendWithin(result []int, qx, qy, radius float64) []int {
+	if len(ki.idxs) == 0 {
+		return result
+	}
+	stackBuf := stackPool.Get().(*[]int)
+	stack := append((*stackBuf)[:0], 0, len(ki.idxs)-1, 0)
+	r2 := radius * radius
+
+	for len(stack) > 0 {
+		axis := stack[len(stack)-1]
+		right := stack[len(stack)-2]
+		left := stack[len(stack)-3]
+		stack = stack[:len(stack)-3]
+
+		if right-left <= ki.nodeSize {
+			for i := left; i <= right; i++ {
+				x, y := ki.at(i)
+				if sqDist(x, y, qx, qy) <= r2 {
+					result = append(result, int(ki.idxs[i]))
+				}
+			}
+			continue
+		}
+
+		m := (left + right) / 2
+		x, y := ki.at(m)
+		if sqDist(x, y, qx, qy) <= r2 {
+			result = append(result, int(ki.idxs[m]))
+		}
+
+		nextAxis := (axis + 1) % 2
+		if (axis == 0 && qx-radius <= x) || (axis != 0 && qy-radius <= y) {
+			stack = append(stack, left, m-1, nextAxis)
+		}
+		if (axis == 0 && qx+radius >= x) || (axis != 0 && qy+radius >= y) {
+			stack = append(stack, m+1, right, nextAxis)
+		}
+	}
+	//keep grown stack for the next search
+	*stackBuf = stack
+	stackPool.Put(stackBuf)
+	return result
+}
+
+// Range finds all items within the bounding box and returns indices of points
+func (ki *kdIndex) Range(minX, minY, maxX, maxY float64) []int {
+	if len(ki.idxs) == 0 {
+		return nil
+	}
+	stack := []int{0, len(ki.idxs) - 1, 0}
+	var result []int
+
+	for len(stack) > 0 {
+		axis := stack[len(stack)-1]
+		right := stack[len(stack)-2]
+		left := stack[len(stack)-3]
+		stack = stack[:len(stack)-3]
+
+		if right-left <= ki.nodeSize {
+			for i := left; i <= right; i++ {
+				x, y := ki.at(i)
+				if x >= minX && x <= maxX && y >= minY && y <= maxY {
+					result = append(result, int(ki.idxs[i]))
+				}
+			}
+			continue
+		}
+
+		m := (left + right) / 2
+		x, y := ki.at(m)
+		if x >= minX && x <= maxX && y >= minY && y <= maxY {
+			result = append(result, int(ki.idxs[m]))
+		}
+
+		nextAxis := (axis + 1) % 2
+		if (axis == 0 && minX <= x) || (axis != 0 && minY <= y) {
+			stack = append(stack, left, m-1, nextAxis)
+		}
+		if (axis == 0 && maxX >= x) || (axis != 0 && maxY >= y) {
+			stack = append(stack, m+1, right, nextAxis)
+		}
+	}
+	return result
+}
+
+////////////////////////////////////////////////////////////////
+/// Sorting stuff, the same Floyd-Rivest selection as kdbush
+////////////////////////////////////////////////////////////////
+
+func (ki *kdIndex) sort(left, right, depth int) {
+	if right-left <= ki.nodeSize {
+		return
+	}
+	m := (left + right) / 2
+	ki.sselect(m, left, right, depth%2)
+	ki.sort(left, m-1, depth+1)
+	ki.sort(m+1, right, depth+1)
+}
+
+func (ki *kdIndex) value(i, axis int) float64 {
+	if ki.coords64 != nil {
+		return ki.coords64[2*i+axis]
+	}
+	return ki.decode(ki.coords[2*i+axis])
+}
+
+func (ki *kdIndex) sselect(k, left, right, axis int) {
+	for right > left {
+		if right-left > 600 {
+			n := float64(right - left + 1)
+			m := float64(k - left + 1)
+			z := math.Log(n)
+			s := 0.5 * math.Exp(2.0*z/3.0)
+			sds := 1.0
+			if m-n/2.0 < 0 {
+				sds = -1.0
+			}
+			sd := 0.5 * math.Sqrt(z*s*(n-s)/n) * sds
+			newLeft := maxInt(left, int(math.Floor(float64(k)-m*s/n+sd)))
+			newRight := minInt(right, int(math.Floor(float64(k)+(n-m)*s/n+sd)))
+			ki.sselect(k, newLeft, newRight, axis)
+		}
+
+		t := ki.value(k, axis)
+		i := left
+		j := right
+
+		ki.swap(left, k)
+		if ki.value(right, axis) > t {
+			ki.swap(left, right)
+		}
+
+		for i < j {
+			ki.swap(i, j)
+			i++
+			j--
+			for ki.value(i, axis) < t {
+				i++
+			}
+			for ki.value(j, axis) > t {
+				j--
+			}
+		}
+
+		if ki.value(left, axis) == t {
+			ki.swap(left, j)
+		} else {
+			j++
+			ki.swap(j, right)
+		}
+
+		if j <= k {
+			left = j + 1
+		}
+		if k <= j {
+			right = j - 1
+		}
+	}
+}
+
+func (ki *kdIndex) swap(i, j int) {
+	ki.idxs[i], ki.idxs[j] = ki.idxs[j], ki.idxs[i]
+	if ki.coords64 != nil {
+		ki.coords64[2*i], ki.coords64[2*j] = ki.coords64[2*j], ki.coords64[2*i]
+		ki.coords64[2*i+1], ki.coords64[2*j+1] = ki.coords64[2*j+1], ki.coords64[2*i+1]
+		return
+	}
+	ki.coords[2*i], ki.coords[2*j] = ki.coords[2*j], ki.coords[2*i]
+	ki.coords[2*i+1], ki.coords[2*j+1] = ki.coords[2*j+1], ki.coords[2*i+1]
+}
+
+// stackPool keeps traversal stacks of index searches
+var stackPool = sync.Pool{
+	New: func() interface{} {
+		stack := make([]int, 0, 64)
+		return &stack
+	},
+}
+
+func sqDist(ax, ay, bx, by float64) float64 {
+	dx := ax - bx
+	dy := ay - by
+	return dx*dx + dy*dy
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,141 @@
+package cluster
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestKDIndexModes(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	points := make([]*ClusterPoint, 5000)
+	for i := range points {
+		points[i] = &ClusterPoint{X: random.Float64(), Y: random.Float64()}
+	}
+	float64Bytes := 0
+	for _, mode := range []CoordinatesMode{CoordinatesFloat64, CoordinatesFloat32, CoordinatesFixed32} {
+		ki := newSpatialIndex(points, 16, mode).(*kdIndex)
+		//stored coordinates are close to the points, precision of 32-bit modes is better than 1e-7 of the world
+		stored := make([][2]float64, len(points))
+		for i := range ki.idxs {
+			x, y := ki.at(i)
+			p := points[ki.idxs[i]]
+			if math.Abs(x-p.X) > 1e-7 || math.Abs(y-p.Y) > 1e-7 {
+				t.Fatalf("mode %d: point %d is stored at %v,%v, want %v,%v", mode, ki.idxs[i], x, y, p.X, p.Y)
+			}
+			stored[ki.idxs[i]] = [2]float64{x, y}
+		}
+
+		for q := 0; q < 50; q++ {
+			qx, qy, r := random.Float64(), random.Float64(), random.Float64()*0.05
+			var want []int
+			for i, s := range stored {
+				if sqDist(s[0], s[1], qx, qy) <= r*r {
+					want = append(want, i)
+				}
+			}
+			got := ki.AppendWithin(nil, qx, qy, r)
+			sort.Ints(got)
+			if !equalInts(got, want) {
+				t.Fatalf("mode %d: within %v of %v,%v found %v, want %v", mode, r, qx, qy, got, want)
+			}
+
+			want = want[:0]
+			for i, s := range stored {
+				if s[0] >= qx-r && s[0] <= qx+r && s[1] >= qy-r && s[1] <= qy+r {
+					want = append(want, i)
+				}
+			}
+			got = ki.Range(qx-r, qy-r, qx+r, qy+r)
+			sort.Ints(got)
+			if !equalInts(got, want) {
+				t.Fatalf("mode %d: range around %v,%v found %v, want %v", mode, qx, qy, got, want)
+			}
+		}
+
+		if mode == CoordinatesFloat64 {
+			float64Bytes = ki.Bytes()
+		} else if ki.Bytes() >= float64Bytes*3/4 {
+			t.Fatalf("mode %d: index takes %d bytes, float64 one takes %d", mode, ki.Bytes(), float64Bytes)
+		}
+	}
+}
+
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestClusterCoordinatesModes(t *testing.T) {
+	points := loadPlaces(t)
+	counts := map[CoordinatesMode]int{}
+	for _, mode := range []CoordinatesMode{CoordinatesFloat64, CoordinatesFloat32, CoordinatesFixed32} {
+		c, err := NewClusterForZoom(3, 256, 40)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.CoordinatesMode = mode
+		if err := c.ClusterPoints(points); err != nil {
+			t.Fatal(err)
+		}
+		checkAssignments(t, c, len(points))
+		counts[mode] = len(c.ResultPoints)
+	}
+	//only points at the distance of epsilon within the precision may be clustered differently
+	for mode, n := range counts {
+		if math.Abs(float64(n-counts[CoordinatesFloat64])) > 2 {
+			t.Fatalf("clustering of mode %d has %d result points, float64 one has %d", mode, n, counts[CoordinatesFloat64])
+		}
+	}
+}
+
+func TestAppendWithinReusesBuffer(t *testing.T) {
+	random := rand.New(rand.NewSource(2))
+	points := make([]*ClusterPoint, 1000)
+	for i := range points {
+		points[i] = &ClusterPoint{X: random.Float64(), Y: random.Float64()}
+	}
+	index := newSpatialIndex(points, 16, CoordinatesFloat64)
+	dst := index.AppendWithin([]int{-1}, 0.5, 0.5, 0.1)
+	if dst[0] != -1 || len(dst) < 2 {
+		t.Fatalf("found %v after the prefix", dst)
+	}
+	allocs := testing.AllocsPerRun(100, func() {
+		dst = index.AppendWithin(dst[:0], 0.5, 0.5, 0.1)
+	})
+	if allocs > 0 {
+		t.Fatalf("search with the buffer allocates %v times", allocs)
+	}
+}
+
+func TestIndexOfProjectedPoints(t *testing.T) {
+	//every fifth point is the duplicate of the previous one
+	points := randomPoints(1000, 17, -60, -60, 60, 60)
+	for i := 5; i < len(points); i += 5 {
+		points[i].(*Feature).Coordinates = points[i-1].GetCoordinates()
+	}
+	for _, dedup := range []bool{false, true} {
+		for _, mode := range []CoordinatesMode{CoordinatesFloat64, CoordinatesFixed32} {
+			c := NewCluster(0.01)
+			c.DeduplicateCoordinates = dedup
+			c.CoordinatesMode = mode
+			if err := c.ClusterPoints(points); err != nil {
+				t.Fatal(err)
+			}
+			//index written while points are projected is the same as the one built of base points
+			want := newSpatialIndex(c.basePoints, c.NodeSize, mode)
+			if !reflect.DeepEqual(c.baseIndex, want) {
+				t.Fatalf("dedup %v, mode %d: index differs from the one of base points", dedup, mode)
+			}
+		}
+	}
+}
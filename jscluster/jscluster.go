@@ -0,0 +1,71 @@
+// Package jscluster is JSON-in/JSON-out facade of gocluster for JavaScript and WebAssembly.
+//
+// Functions take and return JSON documents only, so they are easy to expose to JavaScript
+// with syscall/js, see cmd/gocluster-wasm, and the same clustering runs in the browser for offline maps as on the server.
+// The package has no dependencies except gocluster itself.
+package jscluster
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	cluster "github.com/iahmedov/gocluster"
+)
+
+// Options are clustering parameters, zero values are replaced by defaults
+type Options struct {
+	// Zoom level to cluster for, 0..21
+	Zoom int `json:"zoom"`
+	// Radius of cluster in pixels, 40 by default
+	Radius int `json:"radius"`
+	// TileSize in pixels, radius is relative to it, 512 by default
+	TileSize int `json:"tileSize"`
+	// MinPoints to form a cluster, 2 by default
+	MinPoints int `json:"minPoints"`
+}
+
+// DefaultOptions are options used for zero fields
+var DefaultOptions = Options{Radius: 40, TileSize: 512, MinPoints: 2}
+
+// ParseOptions decodes options JSON, empty input means default options
+func ParseOptions(data []byte) (Options, error) {
+	var o Options
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &o); err != nil {
+			return o, fmt.Errorf("jscluster: invalid options: %v", err)
+		}
+	}
+	if o.Radius == 0 {
+		o.Radius = DefaultOptions.Radius
+	}
+	if o.TileSize == 0 {
+		o.TileSize = DefaultOptions.TileSize
+	}
+	if o.MinPoints == 0 {
+		o.MinPoints = DefaultOptions.MinPoints
+	}
+	return o, nil
+}
+
+// Cluster clusters points of GeoJSON document with options JSON and returns GeoJSON FeatureCollection of clusters,
+// the same as MarshalGeoJSON encodes them
+func Cluster(geojson, options []byte) ([]byte, error) {
+	o, err := ParseOptions(options)
+	if err != nil {
+		return nil, err
+	}
+	points, err := cluster.LoadGeoJSON(bytes.NewReader(geojson))
+	if err != nil {
+		return nil, err
+	}
+	c, err := cluster.NewClusterForZoom(o.Zoom, o.TileSize, o.Radius)
+	if err != nil {
+		return nil, err
+	}
+	c.MinPoints = o.MinPoints
+	if err := c.ClusterPoints(points); err != nil {
+		return nil, err
+	}
+	return cluster.MarshalGeoJSON(c.AllClusters())
+}
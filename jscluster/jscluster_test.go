@@ -0,0 +1,67 @@
+package jscluster
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+const places = `{"type": "FeatureCollection", "features": [
+	{"type": "Feature", "id": 1, "geometry": {"type": "Point", "coordinates": [13.40, 52.50]}, "properties": {"name": "a"}},
+	{"type": "Feature", "id": 2, "geometry": {"type": "Point", "coordinates": [13.41, 52.51]}, "properties": {"name": "b"}},
+	{"type": "Feature", "id": 3, "geometry": {"type": "Point", "coordinates": [-74.0, 40.7]}, "properties": {"name": "c"}}
+]}`
+
+type collection struct {
+	Type     string `json:"type"`
+	Features []struct {
+		Properties map[string]interface{} `json:"properties"`
+	} `json:"features"`
+}
+
+func TestCluster(t *testing.T) {
+	data, err := Cluster([]byte(places), []byte(`{"zoom": 5}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var result collection
+	if err := json.Unmarshal(data, &result); err != nil {
+		t.Fatal(err)
+	}
+	if result.Type != "FeatureCollection" || len(result.Features) != 2 {
+		t.Fatalf("result is %s", data)
+	}
+	if count := result.Features[0].Properties["point_count"]; count != 2.0 {
+		t.Fatalf("cluster of Berlin points has %v points", count)
+	}
+
+	//MinPoints above the group size keeps points as is
+	data, err = Cluster([]byte(places), []byte(`{"zoom": 5, "minPoints": 3}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, &result); err != nil || len(result.Features) != 3 {
+		t.Fatalf("result of minPoints 3 is %s", data)
+	}
+}
+
+func TestParseOptions(t *testing.T) {
+	o, err := ParseOptions(nil)
+	if err != nil || o != DefaultOptions {
+		t.Fatalf("options of empty input are %+v: %v", o, err)
+	}
+	o, err = ParseOptions([]byte(`{"zoom": 3, "radius": 60}`))
+	if err != nil || o != (Options{Zoom: 3, Radius: 60, TileSize: 512, MinPoints: 2}) {
+		t.Fatalf("options are %+v: %v", o, err)
+	}
+	for _, input := range [][]byte{[]byte(`{"zoom": "3"}`), []byte(`[`)} {
+		if _, err := ParseOptions(input); err == nil {
+			t.Errorf("expected error of options %s", input)
+		}
+	}
+	if _, err := Cluster([]byte(places), []byte(`{"zoom": 30}`)); err == nil {
+		t.Fatal("expected error of zoom 30")
+	}
+	if _, err := Cluster([]byte(`{"type": "Point"}`), nil); err == nil {
+		t.Fatal("expected error of invalid GeoJSON")
+	}
+}
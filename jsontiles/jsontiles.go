@@ -0,0 +1,323 @@
+// Package jsontiles exports the tile pyramid of clusters as static GeoJSON files, z/x/y.json and manifest.json,
+// so small deployments upload clustering output to object storage or any static hosting and serve it without
+// Go process at request time.
+//
+// Tiles are written for each zoom of the levels, only tiles with points are written, clients treat missing tiles
+// as empty. Each tile is FeatureCollection of points of the tile, encoded like cluster.MarshalGeoJSON encodes them.
+// The manifest lists zooms, bounds and tiles of the pyramid, Read and ReadTile load the pyramid back.
+package jsontiles
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+
+	cluster "github.com/iahmedov/gocluster"
+)
+
+// ManifestPath is the path of the manifest relative to the root of the pyramid
+const ManifestPath = "manifest.json"
+
+// TilePath is the template of paths of tiles relative to the root of the pyramid, as TileJSON templates are
+const TilePath = "{z}/{x}/{y}.json"
+
+// Storage stores files of the pyramid, e.g. Dir or the bucket of object storage
+// Paths are slash separated and relative to the root of the pyramid.
+type Storage interface {
+	Create(path string) (io.WriteCloser, error)
+	Open(path string) (io.ReadCloser, error)
+}
+
+// Dir is Storage of files in the directory, parent directories of files are created
+type Dir string
+
+// Create implements Storage interface
+func (d Dir) Create(path string) (io.WriteCloser, error) {
+	name := filepath.Join(string(d), filepath.FromSlash(path))
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return nil, err
+	}
+	return os.Create(name)
+}
+
+// Open implements Storage interface
+func (d Dir) Open(path string) (io.ReadCloser, error) {
+	return os.Open(filepath.Join(string(d), filepath.FromSlash(path)))
+}
+
+// Manifest describes the pyramid, it's written to ManifestPath
+type Manifest struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Format      string `json:"format"`
+	// Tiles is TilePath
+	Tiles   string `json:"tiles"`
+	MinZoom int    `json:"minzoom"`
+	MaxZoom int    `json:"maxzoom"`
+	// Bounds are west, south, east and north of all points
+	Bounds [4]float64 `json:"bounds"`
+	// Version is the largest Version of Clusters of the levels, it changes when any of them is rebuilt
+	Version uint64 `json:"version"`
+	// Zooms are the zoom levels in ascending order
+	Zooms []Zoom `json:"zooms"`
+}
+
+// Zoom describes tiles of one zoom level of the pyramid
+type Zoom struct {
+	Zoom     int `json:"zoom"`
+	Points   int `json:"points"`   //number of clusters and single points
+	Clusters int `json:"clusters"` //number of clusters of several points
+	// TileXY are x and y of written tiles, sorted by x and y
+	TileXY [][2]int `json:"tile_xy"`
+}
+
+// Tiles returns written tiles of the zoom level
+func (z *Zoom) Tiles() []cluster.Tile {
+	tiles := make([]cluster.Tile, len(z.TileXY))
+	for i, xy := range z.TileXY {
+		tiles[i] = cluster.Tile{X: xy[0], Y: xy[1], Z: z.Zoom}
+	}
+	return tiles
+}
+
+// Writer renders Levels into static GeoJSON tiles
+// Name and Description are written to the manifest.
+// Precision is the number of decimal digits of coordinates and numeric properties of tiles,
+// see cluster.MarshalGeoJSONPrecision, they are not rounded if it's 0.
+type Writer struct {
+	Name        string
+	Description string
+	Precision   int
+}
+
+// Write writes tiles of levels and then the manifest into storage, returns the manifest
+// Storage is not cleared, so tiles which became empty since the previous export should be removed by the caller,
+// e.g. by exporting into the new directory or prefix.
+func (w *Writer) Write(storage Storage, levels cluster.Levels) (*Manifest, error) {
+	if len(levels) == 0 {
+		return nil, errors.New("jsontiles: no levels")
+	}
+	zooms := make([]int, 0, len(levels))
+	for zoom := range levels {
+		zooms = append(zooms, zoom)
+	}
+	sort.Ints(zooms)
+
+	name := w.Name
+	if name == "" {
+		name = "clusters"
+	}
+	manifest := &Manifest{
+		Name:        name,
+		Description: w.Description,
+		Format:      "geojson",
+		Tiles:       TilePath,
+		MinZoom:     zooms[0],
+		MaxZoom:     zooms[len(zooms)-1],
+	}
+	west, south, east, north := math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)
+	for _, zoom := range zooms {
+		c := levels[zoom]
+		if v := c.Version(); v > manifest.Version {
+			manifest.Version = v
+		}
+		points := c.AllClusters()
+		level := Zoom{Zoom: zoom, Points: len(points)}
+		for i := range points {
+			p := &points[i]
+			west, east = math.Min(west, p.X), math.Max(east, p.X)
+			south, north = math.Min(south, p.Y), math.Max(north, p.Y)
+			if p.NumPoints > 1 {
+				level.Clusters++
+			}
+		}
+		tiles, byTile := tilesOf(points, zoom)
+		level.TileXY = make([][2]int, len(tiles))
+		for i, t := range tiles {
+			data, err := w.marshal(byTile[t])
+			if err != nil {
+				return nil, fmt.Errorf("jsontiles: can't encode tile %v: %v", t, err)
+			}
+			if err := write(storage, t.String()+".json", data); err != nil {
+				return nil, fmt.Errorf("jsontiles: can't write tile %v: %v", t, err)
+			}
+			level.TileXY[i] = [2]int{t.X, t.Y}
+		}
+		manifest.Zooms = append(manifest.Zooms, level)
+	}
+	if west > east {
+		west, south, east, north = -180, -85.0511, 180, 85.0511
+	}
+	manifest.Bounds = [4]float64{west, south, east, north}
+
+	//the manifest is the last one, so readers never see it before tiles it lists
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return nil, fmt.Errorf("jsontiles: %v", err)
+	}
+	if err := write(storage, ManifestPath, data); err != nil {
+		return nil, fmt.Errorf("jsontiles: can't write manifest: %v", err)
+	}
+	return manifest, nil
+}
+
+// marshal encodes points of the tile, rounded to Precision if it's set
+func (w *Writer) marshal(points []cluster.ClusterPoint) ([]byte, error) {
+	if w.Precision > 0 {
+		return cluster.MarshalGeoJSONPrecision(points, w.Precision)
+	}
+	return cluster.MarshalGeoJSON(points)
+}
+
+// tilesOf groups points by tiles of the zoom, tiles are sorted, so files are the same on every run
+func tilesOf(points []cluster.ClusterPoint, zoom int) ([]cluster.Tile, map[cluster.Tile][]cluster.ClusterPoint) {
+	byTile := map[cluster.Tile][]cluster.ClusterPoint{}
+	var tiles []cluster.Tile
+	for _, p := range points {
+		t := cluster.LonLatToTile(cluster.GeoCoordinates{Lon: p.X, Lat: p.Y}, zoom)
+		if _, ok := byTile[t]; !ok {
+			tiles = append(tiles, t)
+		}
+		byTile[t] = append(byTile[t], p)
+	}
+	sort.Slice(tiles, func(i, j int) bool {
+		if tiles[i].X != tiles[j].X {
+			return tiles[i].X < tiles[j].X
+		}
+		return tiles[i].Y < tiles[j].Y
+	})
+	return tiles, byTile
+}
+
+// write writes data to the new file of storage, errors of Close are reported, as uploads often fail there
+func write(storage Storage, path string, data []byte) error {
+	f, err := storage.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// ReadManifest reads the manifest of the pyramid in storage
+func ReadManifest(storage Storage) (*Manifest, error) {
+	f, err := storage.Open(ManifestPath)
+	if err != nil {
+		return nil, fmt.Errorf("jsontiles: can't open manifest: %v", err)
+	}
+	defer f.Close()
+	var manifest Manifest
+	if err := json.NewDecoder(f).Decode(&manifest); err != nil {
+		return nil, fmt.Errorf("jsontiles: can't decode manifest: %v", err)
+	}
+	return &manifest, nil
+}
+
+// ReadTile reads points of the tile written by Writer
+// Clusters get back position, cluster_id, counts, label, categories and layers, other properties are in Properties.
+// Single points are *cluster.Feature in IncludedPoints with id and properties of the source point,
+// their Id is -1, as tiles don't keep indexes of input points. Tiles not in the manifest are storage errors.
+func ReadTile(storage Storage, t cluster.Tile) ([]cluster.ClusterPoint, error) {
+	f, err := storage.Open(t.String() + ".json")
+	if err != nil {
+		return nil, fmt.Errorf("jsontiles: can't open tile %v: %v", t, err)
+	}
+	defer f.Close()
+	features, err := cluster.LoadGeoJSON(f)
+	if err != nil {
+		return nil, fmt.Errorf("jsontiles: tile %v: %v", t, err)
+	}
+	points := make([]cluster.ClusterPoint, len(features))
+	for i, p := range features {
+		points[i] = clusterPoint(p.(*cluster.Feature))
+	}
+	return points, nil
+}
+
+// Read reads the manifest and points of all tiles in storage, points of each zoom are in order of tiles
+// It's the reverse of Write, e.g. to check the export or to load it into the store of another service.
+func Read(storage Storage) (*Manifest, map[int][]cluster.ClusterPoint, error) {
+	manifest, err := ReadManifest(storage)
+	if err != nil {
+		return nil, nil, err
+	}
+	points := make(map[int][]cluster.ClusterPoint, len(manifest.Zooms))
+	for i := range manifest.Zooms {
+		level := &manifest.Zooms[i]
+		zoomPoints := make([]cluster.ClusterPoint, 0, level.Points)
+		for _, t := range level.Tiles() {
+			tilePoints, err := ReadTile(storage, t)
+			if err != nil {
+				return nil, nil, err
+			}
+			zoomPoints = append(zoomPoints, tilePoints...)
+		}
+		points[level.Zoom] = zoomPoints
+	}
+	return manifest, points, nil
+}
+
+// reserved are properties of clusters written by cluster.ClusterProperties, they are fields of ClusterPoint
+var reserved = map[string]bool{
+	"cluster": true, "cluster_id": true, "point_count": true, "point_count_abbreviated": true,
+	"top_leaves": true, "sample_leaves": true, "label": true, "categories": true, "layers": true,
+}
+
+// clusterPoint returns ClusterPoint of the feature of the tile
+func clusterPoint(f *cluster.Feature) cluster.ClusterPoint {
+	if isCluster, _ := f.Properties["cluster"].(bool); !isCluster {
+		return cluster.ClusterPoint{
+			X:              f.Coordinates.Lon,
+			Y:              f.Coordinates.Lat,
+			Id:             -1,
+			NumPoints:      1,
+			Weight:         1,
+			IncludedPoints: []cluster.GeoPoint{f},
+		}
+	}
+	cp := cluster.ClusterPoint{X: f.Coordinates.Lon, Y: f.Coordinates.Lat}
+	if id, ok := f.Properties["cluster_id"].(float64); ok {
+		cp.Id = int(id)
+	}
+	if count, ok := f.Properties["point_count"].(float64); ok {
+		cp.NumPoints = int(count)
+		cp.Weight = count
+	}
+	cp.PointCountAbbreviated, _ = f.Properties["point_count_abbreviated"].(string)
+	cp.Label, _ = f.Properties["label"].(string)
+	cp.Categories = counts(f.Properties["categories"])
+	cp.Layers = counts(f.Properties["layers"])
+	for k, v := range f.Properties {
+		if reserved[k] {
+			continue
+		}
+		if cp.Properties == nil {
+			cp.Properties = map[string]interface{}{}
+		}
+		cp.Properties[k] = v
+	}
+	return cp
+}
+
+// counts returns decoded categories or layers of the cluster, nil if there are none
+func counts(v interface{}) map[string]int {
+	m, ok := v.(map[string]interface{})
+	if !ok || len(m) == 0 {
+		return nil
+	}
+	result := make(map[string]int, len(m))
+	for k, n := range m {
+		if f, ok := n.(float64); ok {
+			result[k] = int(f)
+		}
+	}
+	return result
+}
@@ -0,0 +1,200 @@
+package jsontiles
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"testing"
+
+	cluster "github.com/iahmedov/gocluster"
+)
+
+// memStorage is Storage of files in memory, files are stored when they are closed, like uploads of object storage
+type memStorage struct {
+	files   map[string][]byte
+	failing string //Close of this path fails
+}
+
+func newMemStorage() *memStorage {
+	return &memStorage{files: map[string][]byte{}}
+}
+
+type memFile struct {
+	bytes.Buffer
+	s    *memStorage
+	path string
+}
+
+func (f *memFile) Close() error {
+	if f.path == f.s.failing {
+		return errors.New("upload failed")
+	}
+	f.s.files[f.path] = f.Bytes()
+	return nil
+}
+
+func (s *memStorage) Create(path string) (io.WriteCloser, error) {
+	return &memFile{s: s, path: path}, nil
+}
+
+func (s *memStorage) Open(path string) (io.ReadCloser, error) {
+	data, ok := s.files[path]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return ioutil.NopCloser(bytes.NewReader(data)), nil
+}
+
+// testLevels returns levels of the grid of points over central Europe at zooms 2 and 6
+func testLevels(t *testing.T) cluster.Levels {
+	t.Helper()
+	points := make([]cluster.GeoPoint, 200)
+	for i := range points {
+		points[i] = &cluster.Feature{
+			ID:          float64(i),
+			Coordinates: cluster.GeoCoordinates{Lon: 5 + float64(i%20), Lat: 45 + float64(i/20)},
+			Properties:  map[string]interface{}{"name": "place"},
+		}
+	}
+	levels := cluster.Levels{}
+	for _, zoom := range []int{2, 6} {
+		c, err := cluster.NewClusterForZoom(zoom, 256, 40)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := c.ClusterPoints(points); err != nil {
+			t.Fatal(err)
+		}
+		levels[zoom] = c
+	}
+	return levels
+}
+
+// checkRoundTrip fails if points read back differ from result points of levels, they are compared in order of tiles
+func checkRoundTrip(t *testing.T, levels cluster.Levels, manifest *Manifest, points map[int][]cluster.ClusterPoint) {
+	t.Helper()
+	if manifest.MinZoom != 2 || manifest.MaxZoom != 6 || len(manifest.Zooms) != 2 || manifest.Tiles != TilePath {
+		t.Fatalf("manifest %+v", manifest)
+	}
+	for _, level := range manifest.Zooms {
+		want := append([]cluster.ClusterPoint{}, levels[level.Zoom].AllClusters()...)
+		sort.SliceStable(want, func(i, j int) bool {
+			a := cluster.LonLatToTile(cluster.GeoCoordinates{Lon: want[i].X, Lat: want[i].Y}, level.Zoom)
+			b := cluster.LonLatToTile(cluster.GeoCoordinates{Lon: want[j].X, Lat: want[j].Y}, level.Zoom)
+			if a.X != b.X {
+				return a.X < b.X
+			}
+			return a.Y < b.Y
+		})
+		got := points[level.Zoom]
+		if len(got) != len(want) || level.Points != len(want) {
+			t.Fatalf("zoom %d: read %d points, manifest has %d, want %d", level.Zoom, len(got), level.Points, len(want))
+		}
+		clusters := 0
+		for i := range want {
+			g, w := &got[i], &want[i]
+			if g.X != w.X || g.Y != w.Y || g.NumPoints != w.NumPoints {
+				t.Fatalf("zoom %d: point %d of %d at %v,%v, want %d at %v,%v",
+					level.Zoom, i, g.NumPoints, g.X, g.Y, w.NumPoints, w.X, w.Y)
+			}
+			if w.NumPoints > 1 {
+				clusters++
+				if g.Id != w.Id || g.PointCountAbbreviated != w.PointCountAbbreviated {
+					t.Fatalf("zoom %d: cluster %d is read as %d", level.Zoom, w.Id, g.Id)
+				}
+				continue
+			}
+			f, ok := g.IncludedPoints[0].(*cluster.Feature)
+			source := w.IncludedPoints[0].(*cluster.Feature)
+			if !ok || g.Id != -1 || f.ID != source.ID || f.Properties["name"] != "place" {
+				t.Fatalf("zoom %d: point %v is read as %+v", level.Zoom, source.ID, g)
+			}
+		}
+		if clusters != level.Clusters {
+			t.Fatalf("zoom %d: manifest has %d clusters, want %d", level.Zoom, level.Clusters, clusters)
+		}
+	}
+}
+
+func TestWriteRead(t *testing.T) {
+	levels := testLevels(t)
+	storage := newMemStorage()
+	manifest, err := (&Writer{Name: "places"}).Write(storage, levels)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tiles := 0
+	for _, level := range manifest.Zooms {
+		tiles += len(level.TileXY)
+	}
+	if len(storage.files) != tiles+1 {
+		t.Fatalf("%d files, want %d tiles and the manifest", len(storage.files), tiles)
+	}
+	if manifest.Name != "places" || manifest.Version != levels[6].Version() {
+		t.Fatalf("manifest %q of version %d", manifest.Name, manifest.Version)
+	}
+
+	read, points, err := Read(storage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkRoundTrip(t, levels, read, points)
+}
+
+func TestWriteReadDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jsontiles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	levels := testLevels(t)
+	manifest, err := (&Writer{}).Write(Dir(dir), levels)
+	if err != nil {
+		t.Fatal(err)
+	}
+	xy := manifest.Zooms[0].TileXY[0]
+	if _, err := os.Stat(filepath.Join(dir, "2", strconv.Itoa(xy[0]), strconv.Itoa(xy[1])+".json")); err != nil {
+		t.Fatal(err)
+	}
+	read, points, err := Read(Dir(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkRoundTrip(t, levels, read, points)
+}
+
+func TestWriteFailedUpload(t *testing.T) {
+	storage := newMemStorage()
+	storage.failing = ManifestPath
+	if _, err := (&Writer{}).Write(storage, testLevels(t)); err == nil {
+		t.Fatal("expected error of failed manifest upload")
+	}
+	if _, err := ReadManifest(storage); err == nil {
+		t.Fatal("manifest is stored")
+	}
+	if _, err := (&Writer{}).Write(storage, cluster.Levels{}); err == nil {
+		t.Fatal("expected error of no levels")
+	}
+}
+
+func TestWritePrecision(t *testing.T) {
+	storage := newMemStorage()
+	if _, err := (&Writer{Precision: 1}).Write(storage, testLevels(t)); err != nil {
+		t.Fatal(err)
+	}
+	_, points, err := Read(storage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range points[2] {
+		if math.Abs(p.X*10-math.Round(p.X*10)) > 1e-9 || math.Abs(p.Y*10-math.Round(p.Y*10)) > 1e-9 {
+			t.Fatalf("point at %v,%v is not rounded to 1 digit", p.X, p.Y)
+		}
+	}
+}
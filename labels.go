@@ -0,0 +1,89 @@
+package cluster
+
+import (
+	"math"
+	"sort"
+)
+
+// LabelPlacement is the display position of the clustered point marker
+// Index is the index of the point in the slice passed to PlaceLabels, Hidden markers overlap others and should not be shown
+type LabelPlacement struct {
+	Index   int
+	Display GeoCoordinates
+	Hidden  bool
+}
+
+// labelDirections are the directions markers are nudged to, in order of preference
+var labelDirections = [][2]float64{
+	{0, -1}, {1, 0}, {0, 1}, {-1, 0},
+	{math.Sqrt2 / 2, -math.Sqrt2 / 2}, {math.Sqrt2 / 2, math.Sqrt2 / 2},
+	{-math.Sqrt2 / 2, math.Sqrt2 / 2}, {-math.Sqrt2 / 2, -math.Sqrt2 / 2},
+}
+
+// PlaceLabels resolves overlaps of round markers of markerPx diameter at zoom for tiles of tileSize pixels
+// Markers are placed from the biggest cluster to single points, marker overlapping already placed ones
+// is moved up to maxNudgePx pixels away in steps of half of the marker, and hidden if there is no free place.
+// Points are expected to be in Lon/Lat coordinates, as returned by AllClusters or GetClusters,
+// result is in the same order as points.
+func PlaceLabels(points []ClusterPoint, zoom, tileSize int, markerPx, maxNudgePx float64) []LabelPlacement {
+	result := make([]LabelPlacement, len(points))
+	if markerPx <= 0 {
+		for i := range points {
+			result[i] = LabelPlacement{Index: i, Display: GeoCoordinates{Lon: points[i].X, Lat: points[i].Y}}
+		}
+		return result
+	}
+
+	order := make([]int, len(points))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool { return points[order[i]].NumPoints > points[order[j]].NumPoints })
+
+	//placed markers in grid of marker size cells, so only neighbour cells are checked
+	placed := map[[2]int][][2]float64{}
+	cellOf := func(x, y float64) [2]int {
+		return [2]int{int(math.Floor(x / markerPx)), int(math.Floor(y / markerPx))}
+	}
+	free := func(x, y float64) bool {
+		cell := cellOf(x, y)
+		for dx := -1; dx <= 1; dx++ {
+			for dy := -1; dy <= 1; dy++ {
+				for _, p := range placed[[2]int{cell[0] + dx, cell[1] + dy}] {
+					if sqDist(x, y, p[0], p[1]) < markerPx*markerPx {
+						return false
+					}
+				}
+			}
+		}
+		return true
+	}
+
+	step := markerPx / 2
+	for _, i := range order {
+		x, y := LonLatToPixel(GeoCoordinates{Lon: points[i].X, Lat: points[i].Y}, zoom, tileSize)
+		found := free(x, y)
+		moved := false
+	nudge:
+		for d := step; !found && d <= maxNudgePx; d += step {
+			for _, dir := range labelDirections {
+				if free(x+dir[0]*d, y+dir[1]*d) {
+					x, y = x+dir[0]*d, y+dir[1]*d
+					found, moved = true, true
+					break nudge
+				}
+			}
+		}
+
+		result[i] = LabelPlacement{Index: i, Display: GeoCoordinates{Lon: points[i].X, Lat: points[i].Y}, Hidden: !found}
+		if !found {
+			continue
+		}
+		if moved {
+			result[i].Display = PixelToLonLat(x, y, zoom, tileSize)
+		}
+		cell := cellOf(x, y)
+		placed[cell] = append(placed[cell], [2]float64{x, y})
+	}
+	return result
+}
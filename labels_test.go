@@ -0,0 +1,57 @@
+package cluster
+
+import (
+	"math"
+	"testing"
+)
+
+func TestPlaceLabels(t *testing.T) {
+	//markers at the same place, the biggest one is the last
+	points := make([]ClusterPoint, 20)
+	for i := range points {
+		points[i] = ClusterPoint{X: 13.4, Y: 52.5, NumPoints: i + 1}
+	}
+	labels := PlaceLabels(points, 10, 256, 20, 40)
+	if len(labels) != len(points) {
+		t.Fatalf("%d labels of %d points", len(labels), len(points))
+	}
+	origin := GeoCoordinates{Lon: 13.4, Lat: 52.5}
+	if last := labels[len(labels)-1]; last.Hidden || last.Display != origin {
+		t.Fatalf("the biggest marker is placed at %v, hidden %v", last.Display, last.Hidden)
+	}
+	cx, cy := LonLatToPixel(origin, 10, 256)
+	hidden := 0
+	for i, l := range labels {
+		if l.Index != i {
+			t.Fatalf("label %d has index %d", i, l.Index)
+		}
+		if l.Hidden {
+			if l.Display != origin {
+				t.Fatalf("hidden label %d is moved", i)
+			}
+			hidden++
+			continue
+		}
+		x, y := LonLatToPixel(l.Display, 10, 256)
+		if d := math.Hypot(x-cx, y-cy); d > 40+1e-6 {
+			t.Fatalf("label %d is nudged %v pixels", i, d)
+		}
+		for j := 0; j < i; j++ {
+			if labels[j].Hidden {
+				continue
+			}
+			ox, oy := LonLatToPixel(labels[j].Display, 10, 256)
+			if d := math.Hypot(x-ox, y-oy); d < 20-1e-6 {
+				t.Fatalf("labels %d and %d are %v pixels apart", j, i, d)
+			}
+		}
+	}
+	//only a few markers fit within 40 pixels
+	if hidden == 0 || hidden == len(points)-1 {
+		t.Fatalf("%d labels are hidden", hidden)
+	}
+
+	if labels := PlaceLabels(points, 10, 256, 0, 40); labels[0].Hidden || labels[0].Display != origin {
+		t.Fatalf("markers without size are placed %v", labels[0])
+	}
+}
@@ -0,0 +1,61 @@
+package cluster
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Layer is one of independently loaded datasets clustered together by ClusterMerged, e.g. vehicles and depots
+type Layer struct {
+	Name   string
+	Points []GeoPoint
+}
+
+// ClusterMerged clusters the union of points of layers, like ClusterPoints of all of them, and counts members
+// of each cluster by layer into ClusterPoint.Layers, so the combined view still breaks clusters down by layer.
+// Ids of points are numbered across layers in their order, LayerOf returns the layer and the index in it.
+// Layer names should be unique.
+func (c *Cluster) ClusterMerged(layers ...Layer) error {
+	names := make([]string, len(layers))
+	starts := make([]int, len(layers))
+	seen := make(map[string]bool, len(layers))
+	var points []GeoPoint
+	for i, layer := range layers {
+		if seen[layer.Name] {
+			return fmt.Errorf("gocluster: layer %q is not unique", layer.Name)
+		}
+		seen[layer.Name] = true
+		names[i], starts[i] = layer.Name, len(points)
+		points = append(points, layer.Points...)
+	}
+	c.columnValues = nil
+	c.layerNames, c.layerStarts = names, starts
+	return c.clusterInput(len(points), func(i int) GeoCoordinates { return points[i].GetCoordinates() }, points)
+}
+
+// layerOf returns index in layerNames of the layer of input point id
+func (c *Cluster) layerOf(id int) int {
+	return sort.Search(len(c.layerStarts), func(i int) bool { return c.layerStarts[i] > id }) - 1
+}
+
+// LayerOf returns name of the layer of the point id, as ClusterMerged numbers them, and index of the point in the layer.
+// ok is false if points were not clustered by ClusterMerged or id is out of range.
+func (c *Cluster) LayerOf(id int) (layer string, index int, ok bool) {
+	if len(c.layerStarts) == 0 || id < 0 || id >= c.numInputPoints() {
+		return "", 0, false
+	}
+	l := c.layerOf(id)
+	return c.layerNames[l], id - c.layerStarts[l], true
+}
+
+// computeLayers sets Layers of the cluster of several points to counts of its members by layer of ClusterMerged
+func (c *Cluster) computeLayers(cp *ClusterPoint) {
+	cp.Layers = nil
+	if len(c.layerStarts) == 0 || cp.NumPoints < 2 {
+		return
+	}
+	cp.Layers = map[string]int{}
+	for _, id := range cp.memberIDs {
+		cp.Layers[c.layerNames[c.layerOf(id)]]++
+	}
+}
@@ -0,0 +1,76 @@
+package cluster
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestClusterMerged(t *testing.T) {
+	vehicles := randomPoints(700, 62, -30, -30, 30, 30)
+	depots := randomPoints(300, 63, -30, -30, 30, 30)
+	c, err := NewClusterForZoom(3, 256, 60)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.ClusterMerged(Layer{Name: "vehicles", Points: vehicles}, Layer{Name: "depots", Points: depots}); err != nil {
+		t.Fatal(err)
+	}
+	checkAssignments(t, c, len(vehicles)+len(depots))
+
+	//the same clusters as of all points, broken down by layer
+	all := NewCluster(c.Epsilon)
+	if err := all.ClusterPoints(append(append([]GeoPoint(nil), vehicles...), depots...)); err != nil {
+		t.Fatal(err)
+	}
+	if len(all.ResultPoints) != len(c.ResultPoints) {
+		t.Fatalf("%d merged result points, want %d", len(c.ResultPoints), len(all.ResultPoints))
+	}
+	for i, cp := range c.ResultPoints {
+		if !reflect.DeepEqual(cp.memberIDs, all.ResultPoints[i].memberIDs) {
+			t.Fatalf("merged result point %d differs", i)
+		}
+		if cp.NumPoints == 1 {
+			if cp.Layers != nil {
+				t.Fatalf("single point %d has layers %v", cp.Id, cp.Layers)
+			}
+			continue
+		}
+		want := map[string]int{}
+		for _, id := range cp.memberIDs {
+			if id < len(vehicles) {
+				want["vehicles"]++
+			} else {
+				want["depots"]++
+			}
+		}
+		if !reflect.DeepEqual(cp.Layers, want) || !reflect.DeepEqual(ClusterProperties(&cp)["layers"], want) {
+			t.Fatalf("cluster %d has layers %v, want %v", cp.Id, cp.Layers, want)
+		}
+	}
+
+	for id, want := range map[int]struct {
+		layer string
+		index int
+	}{0: {"vehicles", 0}, 699: {"vehicles", 699}, 700: {"depots", 0}, 999: {"depots", 299}} {
+		if layer, index, ok := c.LayerOf(id); !ok || layer != want.layer || index != want.index {
+			t.Errorf("point %d is %d of layer %q, want %d of %q", id, index, layer, want.index, want.layer)
+		}
+	}
+	if _, _, ok := c.LayerOf(1000); ok {
+		t.Fatal("point out of range has layer")
+	}
+	if restored := roundTrip(t, c); !reflect.DeepEqual(restored.ResultPoints[0].Layers, c.ResultPoints[0].Layers) {
+		t.Fatal("restored cluster has other layers")
+	}
+
+	//clustering without layers drops them
+	if err := c.ClusterPoints(vehicles); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, ok := c.LayerOf(0); ok || c.ResultPoints[0].Layers != nil {
+		t.Fatal("points clustered without layers have layers")
+	}
+	if err := c.ClusterMerged(Layer{Name: "a"}, Layer{Name: "a"}); err == nil {
+		t.Fatal("expected error of layers of the same name")
+	}
+}
@@ -0,0 +1,88 @@
+package cluster
+
+import "sort"
+
+// computeTopLeaves sets TopLeaves of the cluster to its members of the highest LeafRank
+// Members without rank go after ranked ones, ties keep the order of members.
+func (c *Cluster) computeTopLeaves(cp *ClusterPoint) {
+	cp.TopLeaves, cp.topLeafIDs = nil, nil
+	if c.TopLeaves <= 0 || cp.NumPoints < 2 || len(cp.IncludedPoints) == 0 {
+		return
+	}
+	order := make([]int, len(cp.IncludedPoints))
+	for i := range order {
+		order[i] = i
+	}
+	if c.LeafRank != nil {
+		ranks := make([]float64, len(order))
+		ranked := make([]bool, len(order))
+		for i, p := range cp.IncludedPoints {
+			ranks[i], ranked[i] = c.LeafRank(p)
+		}
+		sort.SliceStable(order, func(i, j int) bool {
+			a, b := order[i], order[j]
+			if ranked[a] != ranked[b] {
+				return ranked[a]
+			}
+			return ranks[a] > ranks[b]
+		})
+	}
+	n := minInt(c.TopLeaves, len(order))
+	cp.TopLeaves = make([]GeoPoint, n)
+	cp.topLeafIDs = make([]int, n)
+	for i, m := range order[:n] {
+		cp.TopLeaves[i] = cp.IncludedPoints[m]
+		cp.topLeafIDs[i] = cp.memberIDs[m]
+	}
+}
+
+// SampleOptions selects members embedded into query output by SampleLeaves
+// Limit - number of members of each cluster
+// Properties - properties of *Feature members kept in the sample, all of them if it's nil
+type SampleOptions struct {
+	Limit      int
+	Properties []string
+}
+
+// SampleLeaves returns copies of points with SampleLeaves of clusters set, so hover previews don't need
+// another request for each cluster. Up to Limit members are taken evenly over the members, so sample of the same
+// cluster is always the same. Members are *Feature with id, coordinates and selected properties,
+// id of members which are not *Feature is their input id. Points of ClusterColumns have no members to sample.
+func SampleLeaves(points []ClusterPoint, opts SampleOptions) []ClusterPoint {
+	result := make([]ClusterPoint, len(points))
+	copy(result, points)
+	if opts.Limit <= 0 {
+		return result
+	}
+	for i := range result {
+		cp := &result[i]
+		if cp.NumPoints < 2 || len(cp.IncludedPoints) == 0 {
+			continue
+		}
+		n := minInt(opts.Limit, len(cp.IncludedPoints))
+		cp.SampleLeaves = make([]GeoPoint, n)
+		for j := range cp.SampleLeaves {
+			m := j * len(cp.IncludedPoints) / n
+			cp.SampleLeaves[j] = sampleLeaf(cp.IncludedPoints[m], cp.memberIDs[m], opts.Properties)
+		}
+	}
+	return result
+}
+
+func sampleLeaf(p GeoPoint, id int, properties []string) *Feature {
+	if f, ok := p.(*Feature); ok && properties == nil {
+		return f
+	}
+	leaf := &Feature{ID: pointID(p, id), Coordinates: p.GetCoordinates(), Properties: pointProperties(p)}
+	if properties == nil || leaf.Properties == nil {
+		return leaf
+	}
+	all := leaf.Properties
+	leaf.Properties = make(map[string]interface{}, len(properties))
+	for _, name := range properties {
+		if v, ok := all[name]; ok {
+			leaf.Properties[name] = v
+		}
+	}
+	return leaf
+}
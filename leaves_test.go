@@ -0,0 +1,139 @@
+package cluster
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestTopLeaves(t *testing.T) {
+	points := randomPoints(1000, 18, -30, -30, 30, 30)
+	//points without value go after ranked ones
+	for i := 0; i < len(points); i += 3 {
+		delete(points[i].(*Feature).Properties, "n")
+	}
+	c, err := NewClusterForZoom(3, 256, 60)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.TopLeaves = 3
+	c.LeafRank = PropertyAccessor("n")
+	if err := c.ClusterPoints(points); err != nil {
+		t.Fatal(err)
+	}
+	for _, cp := range c.ResultPoints {
+		if cp.NumPoints == 1 {
+			if cp.TopLeaves != nil {
+				t.Fatalf("single point %d has top leaves", cp.Id)
+			}
+			continue
+		}
+		var ranks []float64
+		for _, p := range cp.IncludedPoints {
+			if v, ok := p.(*Feature).Properties["n"].(float64); ok {
+				ranks = append(ranks, v)
+			}
+		}
+		sort.Sort(sort.Reverse(sort.Float64Slice(ranks)))
+		if len(cp.TopLeaves) != minInt(3, cp.NumPoints) {
+			t.Fatalf("cluster %d of %d points has %d top leaves", cp.Id, cp.NumPoints, len(cp.TopLeaves))
+		}
+		for i, leaf := range cp.TopLeaves {
+			v, ok := leaf.(*Feature).Properties["n"].(float64)
+			if i < len(ranks) && (!ok || v != ranks[i]) || i >= len(ranks) && ok {
+				t.Fatalf("cluster %d has top leaf %d of rank %v, ranks are %v", cp.Id, i, v, ranks)
+			}
+			if leaf.(*Feature).ID != cp.topLeafIDs[i] {
+				t.Fatalf("cluster %d has top leaf %d of id %v, want %d", cp.Id, i, leaf.(*Feature).ID, cp.topLeafIDs[i])
+			}
+		}
+	}
+
+	restored := roundTrip(t, c)
+	for i := range c.ResultPoints {
+		if !reflect.DeepEqual(restored.ResultPoints[i].topLeafIDs, c.ResultPoints[i].topLeafIDs) {
+			t.Fatalf("result point %d has other top leaves after snapshot", i)
+		}
+	}
+
+	data, err := MarshalGeoJSON(c.Clusters()[:1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var collection struct {
+		Features []struct {
+			Properties struct {
+				TopLeaves []struct {
+					ID          int       `json:"id"`
+					Coordinates []float64 `json:"coordinates"`
+				} `json:"top_leaves"`
+			} `json:"properties"`
+		} `json:"features"`
+	}
+	if err := json.Unmarshal(data, &collection); err != nil {
+		t.Fatal(err)
+	}
+	leaves := collection.Features[0].Properties.TopLeaves
+	if len(leaves) != 3 || leaves[0].ID != c.Clusters()[0].topLeafIDs[0] || len(leaves[0].Coordinates) != 2 {
+		t.Fatalf("top_leaves of GeoJSON are %+v", leaves)
+	}
+}
+
+func TestTopLeavesInputOrder(t *testing.T) {
+	c := NewCluster(0.1)
+	c.TopLeaves = 2
+	if err := c.ClusterPoints(randomPoints(10, 19, 0, 0, 1, 1)); err != nil {
+		t.Fatal(err)
+	}
+	if len(c.ResultPoints) != 1 || !reflect.DeepEqual(c.ResultPoints[0].topLeafIDs, c.ResultPoints[0].memberIDs[:2]) {
+		t.Fatalf("top leaves without rank are %v", c.ResultPoints[0].topLeafIDs)
+	}
+}
+
+func TestSampleLeaves(t *testing.T) {
+	c, err := NewClusterForZoom(3, 256, 60)
+	if err != nil {
+		t.Fatal(err)
+	}
+	points := randomPoints(1000, 31, -30, -30, 30, 30)
+	for _, p := range points {
+		p.(*Feature).Properties["name"] = "point"
+	}
+	if err := c.ClusterPoints(points); err != nil {
+		t.Fatal(err)
+	}
+	sampled := SampleLeaves(c.ResultPoints, SampleOptions{Limit: 4, Properties: []string{"name"}})
+	for i, cp := range sampled {
+		if c.ResultPoints[i].SampleLeaves != nil {
+			t.Fatal("SampleLeaves changed the points")
+		}
+		if cp.NumPoints == 1 {
+			if cp.SampleLeaves != nil {
+				t.Fatalf("single point %d has sample", cp.Id)
+			}
+			continue
+		}
+		if len(cp.SampleLeaves) != minInt(4, cp.NumPoints) {
+			t.Fatalf("cluster %d of %d points has %d sampled leaves", cp.Id, cp.NumPoints, len(cp.SampleLeaves))
+		}
+		//members are taken evenly, only the selected properties are kept
+		for j, leaf := range cp.SampleLeaves {
+			m := j * cp.NumPoints / len(cp.SampleLeaves)
+			f := leaf.(*Feature)
+			if f.ID != cp.memberIDs[m] || f.Coordinates != cp.IncludedPoints[m].GetCoordinates() ||
+				!reflect.DeepEqual(f.Properties, map[string]interface{}{"name": "point"}) {
+				t.Fatalf("cluster %d has sampled leaf %d %+v, want member %d", cp.Id, j, f, m)
+			}
+		}
+	}
+	//sample of the same cluster is the same
+	if again := SampleLeaves(c.ResultPoints, SampleOptions{Limit: 4, Properties: []string{"name"}}); !reflect.DeepEqual(again, sampled) {
+		t.Fatal("sample is different for the same clusters")
+	}
+	//all properties are kept without selection, members are shared
+	cp := SampleLeaves(c.Clusters()[:1], SampleOptions{Limit: 1})[0]
+	if cp.SampleLeaves[0] != cp.IncludedPoints[0] {
+		t.Fatal("member without selected properties is copied")
+	}
+}
@@ -0,0 +1,159 @@
+package live
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+
+	cluster "github.com/iahmedov/gocluster"
+)
+
+// Subscription is the viewport message sent by feed clients, they send new one each time the viewport changes:
+//
+//	{"bbox": [13.0, 52.3, 13.8, 52.7], "zoom": 10}
+//
+// BBox is west, south, east and north.
+type Subscription struct {
+	BBox [4]float64 `json:"bbox"`
+	Zoom int        `json:"zoom"`
+}
+
+// Change is the message of the feed: clusters and single points created, updated and removed in the subscribed viewport
+// since the previous message. Created and Updated are GeoJSON Features as cluster.MarshalGeoJSONFeature encodes them,
+// Removed are ids of their features. Version is cluster.Cluster.Version of the clusters.
+// The first message after subscription creates all points of the viewport, points leaving the viewport are removed.
+type Change struct {
+	Version uint64            `json:"version"`
+	Created []json.RawMessage `json:"created,omitempty"`
+	Updated []json.RawMessage `json:"updated,omitempty"`
+	Removed []interface{}     `json:"removed,omitempty"`
+}
+
+// FeedHandler returns WebSocket handler pushing Changes of the subscribed viewport each time updates are applied,
+// instead of polling Handler. Changes are sent only if the viewport has any, a client gets no messages until it subscribes.
+// Pages of other origins are rejected unless CheckOrigin accepts them.
+func (p *Pipeline) FeedHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgradeWebSocket(w, r, p.CheckOrigin)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		subscriptions := make(chan Subscription, 1)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for {
+				msg, err := conn.readMessage()
+				if err != nil {
+					return
+				}
+				var s Subscription
+				if err := json.Unmarshal(msg, &s); err != nil {
+					return
+				}
+				//only the latest viewport matters
+				select {
+				case <-subscriptions:
+				default:
+				}
+				subscriptions <- s
+			}
+		}()
+
+		var view feedView
+		subscribed := false
+		changed := p.changes()
+		for {
+			select {
+			case <-done:
+				return
+			case s := <-subscriptions:
+				view.subscription, subscribed = s, true
+			case <-changed:
+				//the next channel is taken before the diff, so updates applied meanwhile are not missed
+				changed = p.changes()
+			}
+			if !subscribed {
+				continue
+			}
+			if change, ok := p.diff(&view); ok {
+				data, err := json.Marshal(change)
+				if err != nil || conn.writeText(data) != nil {
+					return
+				}
+			}
+		}
+	})
+}
+
+// feedView is the viewport of the feed client and features it has, by feature id, ids are in order they were sent
+type feedView struct {
+	subscription Subscription
+	features     map[interface{}][]byte
+	ids          []interface{}
+}
+
+// changes returns channel closed when updates are applied next time
+func (p *Pipeline) changes() <-chan struct{} {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.changed == nil {
+		p.changed = make(chan struct{})
+	}
+	return p.changed
+}
+
+// notify wakes up feeds waiting for changes, it's called with the lock held
+func (p *Pipeline) notify() {
+	if p.changed != nil {
+		close(p.changed)
+		p.changed = nil
+	}
+}
+
+// diff returns Change of the view since the features it has, and updates them, ok is false if there is no change
+func (p *Pipeline) diff(view *feedView) (change Change, ok bool) {
+	s := view.subscription
+	features := map[interface{}][]byte{}
+	var ids []interface{}
+	p.View(s.Zoom, func(c *cluster.Cluster) {
+		change.Version = c.Version()
+		northWest := cluster.GeoCoordinates{Lon: s.BBox[0], Lat: s.BBox[3]}
+		southEast := cluster.GeoCoordinates{Lon: s.BBox[2], Lat: s.BBox[1]}
+		for _, cp := range c.GetClusters(northWest, southEast) {
+			data, err := cluster.MarshalGeoJSONFeature(cp)
+			if err != nil {
+				continue
+			}
+			id := featureID(&cp)
+			features[id] = data
+			ids = append(ids, id)
+			previous, known := view.features[id]
+			switch {
+			case !known:
+				change.Created = append(change.Created, data)
+			case !bytes.Equal(previous, data):
+				change.Updated = append(change.Updated, data)
+			}
+		}
+	})
+	for _, id := range view.ids {
+		if _, ok := features[id]; !ok {
+			change.Removed = append(change.Removed, id)
+		}
+	}
+	view.features, view.ids = features, ids
+	return change, len(change.Created)+len(change.Updated)+len(change.Removed) > 0
+}
+
+// featureID returns id of the GeoJSON Feature of the point: asset id for single points and cluster id for clusters
+func featureID(cp *cluster.ClusterPoint) interface{} {
+	if cp.NumPoints == 1 {
+		if ids := cp.SourceIDs(); len(ids) == 1 {
+			return ids[0]
+		}
+	}
+	return cp.Id
+}
@@ -0,0 +1,397 @@
+// Package live keeps clusters of moving assets up to date from a message stream, e.g. Kafka topic or NATS subject.
+//
+// Pipeline consumes position updates, applies them in batches and serves clusters of every zoom meanwhile.
+// Moves of known assets recluster only dirty regions with MovePoint and RebuildDirty,
+// new and removed assets cluster all points again, as Cluster has fixed set of input points.
+//
+// The package has no client dependency, Source is easily implemented with any client:
+//
+//	// kafka-go
+//	func (s kafkaSource) Next(ctx context.Context) ([]byte, error) {
+//		m, err := s.reader.ReadMessage(ctx)
+//		return m.Value, err
+//	}
+//
+//	// nats.go
+//	func (s natsSource) Next(ctx context.Context) ([]byte, error) {
+//		m, err := s.sub.NextMsgWithContext(ctx)
+//		if err != nil {
+//			return nil, err
+//		}
+//		return m.Data, nil
+//	}
+package live
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	cluster "github.com/iahmedov/gocluster"
+)
+
+// Source returns messages of the stream one by one, blocking until the next one arrives or ctx is done
+type Source interface {
+	Next(ctx context.Context) ([]byte, error)
+}
+
+// Op is the operation of the update
+type Op int
+
+const (
+	// Upsert adds the asset or moves it to new coordinates
+	Upsert Op = iota
+	// Remove removes the asset, unknown assets are ignored
+	Remove
+)
+
+// Update is the position update of the asset
+// Expires is the time the asset drops out of clusters if there is no new update, Pipeline.TTL after the update if it's zero
+type Update struct {
+	ID          string
+	Op          Op
+	Coordinates cluster.GeoCoordinates
+	Expires     time.Time
+}
+
+// Decoder decodes message of the stream into update
+type Decoder func(msg []byte) (Update, error)
+
+// DecodeJSON is the default Decoder of messages like:
+//
+//	{"id": "truck-17", "lon": 13.38, "lat": 52.51}
+//	{"id": "truck-17", "lon": 13.38, "lat": 52.51, "expires": "2021-03-01T12:00:00Z"}
+//	{"id": "truck-17", "removed": true}
+func DecodeJSON(msg []byte) (Update, error) {
+	var m struct {
+		ID      string    `json:"id"`
+		Lon     *float64  `json:"lon"`
+		Lat     *float64  `json:"lat"`
+		Expires time.Time `json:"expires"`
+		Removed bool      `json:"removed"`
+	}
+	if err := json.Unmarshal(msg, &m); err != nil {
+		return Update{}, fmt.Errorf("live: invalid message: %v", err)
+	}
+	if m.ID == "" {
+		return Update{}, errors.New("live: message has no id")
+	}
+	if m.Removed {
+		return Update{ID: m.ID, Op: Remove}, nil
+	}
+	if m.Lon == nil || m.Lat == nil {
+		return Update{}, fmt.Errorf("live: message of %q has no coordinates", m.ID)
+	}
+	return Update{ID: m.ID, Coordinates: cluster.GeoCoordinates{Lon: *m.Lon, Lat: *m.Lat}, Expires: m.Expires}, nil
+}
+
+// Asset is the tracked point, IncludedPoints of clusters are *Asset
+// Expires is zero for assets which never expire
+type Asset struct {
+	ID          string
+	Coordinates cluster.GeoCoordinates
+	Expires     time.Time
+}
+
+// GetCoordinates implements GeoPoint interface
+func (a *Asset) GetCoordinates() cluster.GeoCoordinates {
+	return a.Coordinates
+}
+
+// GetID implements GeoPointWithID interface
+func (a *Asset) GetID() interface{} {
+	return a.ID
+}
+
+// Pipeline applies updates of the Source to clusters of all zoom levels
+// Decode - message decoder, DecodeJSON by default
+// BatchSize - updates applied at once, 1000 by default
+// FlushInterval - the longest time an update waits for the batch, 1 second by default
+// OnError - called with invalid messages which are skipped and errors of clustering, Run stops on the first of them if it's nil
+// TTL - assets without updates for TTL drop out of clusters, it's used for updates without Expires, zero means no expiry
+// Expired assets are swept by Run each FlushInterval.
+// CheckOrigin - returns true if FeedHandler accepts WebSocket handshake of the request, SameOrigin if it's nil
+type Pipeline struct {
+	Source        Source
+	Decode        Decoder
+	BatchSize     int
+	FlushInterval time.Duration
+	OnError       func(err error)
+	TTL           time.Duration
+	CheckOrigin   func(r *http.Request) bool
+
+	templates cluster.Levels
+
+	mu     sync.RWMutex
+	levels cluster.Levels
+	assets []*Asset
+	byID   map[string]int
+	stale  bool //assets are changed, but clustering of levels failed, so they are clustered again by the next update
+	//closed when updates are applied, see FeedHandler
+	changed chan struct{}
+}
+
+// NewPipeline creates Pipeline clustering assets with options of templates, e.g. created by NewClusterForZoom
+// Templates should not be clustered themselves.
+func NewPipeline(templates cluster.Levels, source Source) *Pipeline {
+	return &Pipeline{
+		Source:    source,
+		templates: templates,
+		byID:      map[string]int{},
+	}
+}
+
+// Run consumes the Source until ctx is done or the Source fails, pending updates are applied before return
+func (p *Pipeline) Run(ctx context.Context) error {
+	decode := p.Decode
+	if decode == nil {
+		decode = DecodeJSON
+	}
+	batchSize := p.BatchSize
+	if batchSize <= 0 {
+		batchSize = 1000
+	}
+	interval := p.FlushInterval
+	if interval <= 0 {
+		interval = time.Second
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	messages := make(chan []byte, batchSize)
+	failed := make(chan error, 1)
+	go func() {
+		defer close(messages)
+		for {
+			msg, err := p.Source.Next(ctx)
+			if err != nil {
+				if ctx.Err() == nil {
+					failed <- err
+				}
+				return
+			}
+			select {
+			case messages <- msg:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	var batch []Update
+	for {
+		select {
+		case msg, ok := <-messages:
+			if !ok {
+				if err := p.Apply(batch...); err != nil {
+					return err
+				}
+				select {
+				case err := <-failed:
+					return fmt.Errorf("live: %v", err)
+				default:
+					return ctx.Err()
+				}
+			}
+			u, err := decode(msg)
+			if err != nil {
+				if p.OnError == nil {
+					if applyErr := p.Apply(batch...); applyErr != nil {
+						return applyErr
+					}
+					return err
+				}
+				p.OnError(err)
+				continue
+			}
+			if batch = append(batch, u); len(batch) >= batchSize {
+				err = p.Apply(batch...)
+				batch = batch[:0]
+			}
+			if err = p.handle(err); err != nil {
+				return err
+			}
+		case <-ticker.C:
+			err := p.Apply(batch...)
+			batch = batch[:0]
+			if err = p.handle(err); err != nil {
+				return err
+			}
+			_, err = p.Sweep(time.Now())
+			if err = p.handle(err); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// handle passes error of clustering to OnError, it's returned to stop Run if OnError is nil
+func (p *Pipeline) handle(err error) error {
+	if err != nil && p.OnError != nil {
+		p.OnError(err)
+		return nil
+	}
+	return err
+}
+
+// Apply applies updates to clusters of all levels, it's called by Run and could be used without Source
+// Queries wait while updates are applied. If clustering fails, queries are served by the previous clusters
+// and the error is returned, the next update clusters all assets again.
+// Upserts with coordinates clustering would skip, e.g. NaN or out of range, are not applied and the error of the first
+// of them is returned after other updates are applied, see cluster.CheckCoordinates.
+func (p *Pipeline) Apply(updates ...Update) error {
+	if len(updates) == 0 {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.apply(updates, time.Now())
+}
+
+// Sweep removes assets expired before now, it's called by Run, returns number of removed assets
+// and error of clustering like Apply.
+func (p *Pipeline) Sweep(now time.Time) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var expired []Update
+	for _, a := range p.assets {
+		if !a.Expires.IsZero() && a.Expires.Before(now) {
+			expired = append(expired, Update{ID: a.ID, Op: Remove})
+		}
+	}
+	if len(expired) == 0 {
+		return 0, nil
+	}
+	return len(expired), p.apply(expired, now)
+}
+
+func (p *Pipeline) apply(updates []Update, now time.Time) error {
+	rebuild := p.levels == nil || p.stale
+	var moved []int
+	var invalid error
+	for _, u := range updates {
+		if u.Op == Upsert {
+			if err := p.checkCoordinates(u); err != nil {
+				//the asset keeps its previous position, other updates are applied
+				if invalid == nil {
+					invalid = err
+				}
+				continue
+			}
+		}
+		i, known := p.byID[u.ID]
+		switch {
+		case u.Op == Remove && known:
+			//last asset takes place of the removed one
+			last := len(p.assets) - 1
+			p.assets[i] = p.assets[last]
+			p.byID[p.assets[i].ID] = i
+			p.assets = p.assets[:last]
+			delete(p.byID, u.ID)
+			rebuild = true
+		case u.Op == Upsert && known:
+			p.assets[i].Coordinates, p.assets[i].Expires = u.Coordinates, p.expires(u, now)
+			moved = append(moved, i)
+		case u.Op == Upsert:
+			p.byID[u.ID] = len(p.assets)
+			p.assets = append(p.assets, &Asset{ID: u.ID, Coordinates: u.Coordinates, Expires: p.expires(u, now)})
+			rebuild = true
+		}
+	}
+
+	if rebuild {
+		if err := p.rebuild(); err != nil {
+			return err
+		}
+		return invalid
+	}
+	for _, c := range p.levels {
+		for _, i := range moved {
+			if err := c.MovePoint(i, p.assets[i].Coordinates); err != nil {
+				//the level could be half moved, all levels are clustered from scratch instead
+				if err := p.rebuild(); err != nil {
+					return err
+				}
+				return invalid
+			}
+		}
+		c.RebuildDirty()
+	}
+	p.notify()
+	return invalid
+}
+
+// checkCoordinates returns error if clustering of any level skips the asset of the update, see cluster.CheckCoordinates
+func (p *Pipeline) checkCoordinates(u Update) error {
+	for _, template := range p.templates {
+		if err := template.CheckCoordinates(u.Coordinates); err != nil {
+			return fmt.Errorf("live: update of %q: %v", u.ID, err)
+		}
+	}
+	return nil
+}
+
+// rebuild clusters all assets for each level, levels are replaced only if all of them are clustered
+func (p *Pipeline) rebuild() error {
+	points := make([]cluster.GeoPoint, len(p.assets))
+	for i, a := range p.assets {
+		points[i] = a
+	}
+	levels := make(cluster.Levels, len(p.templates))
+	for zoom, template := range p.templates {
+		c := &cluster.Cluster{}
+		*c = *template
+		if err := c.ClusterPoints(points); err != nil {
+			p.stale = true
+			return fmt.Errorf("live: can't cluster zoom %d: %v", zoom, err)
+		}
+		levels[zoom] = c
+	}
+	p.levels, p.stale = levels, false
+	p.notify()
+	return nil
+}
+
+func (p *Pipeline) expires(u Update, now time.Time) time.Time {
+	if u.Expires.IsZero() && p.TTL > 0 {
+		return now.Add(p.TTL)
+	}
+	return u.Expires
+}
+
+// Len returns number of tracked assets
+func (p *Pipeline) Len() int {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return len(p.assets)
+}
+
+// View calls fn with current clusters of the zoom, updates wait until it returns
+// ok is false if the zoom is not served or no update is applied yet. Cluster should not be changed by fn.
+func (p *Pipeline) View(zoom int, fn func(c *cluster.Cluster)) (ok bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	c, ok := p.levels.At(zoom)
+	if ok {
+		fn(c)
+	}
+	return ok
+}
+
+// Handler returns ClustersHandler serving current clusters, see cluster.ClustersHandler
+func (p *Pipeline) Handler() http.Handler {
+	h := &cluster.ClustersHandler{Cluster: func(zoom int) (*cluster.Cluster, bool) {
+		return p.levels.At(zoom)
+	}}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p.mu.RLock()
+		defer p.mu.RUnlock()
+		h.ServeHTTP(w, r)
+	})
+}
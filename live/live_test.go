@@ -0,0 +1,187 @@
+package live
+
+import (
+	"context"
+	"io"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	cluster "github.com/iahmedov/gocluster"
+)
+
+// newTestPipeline returns Pipeline without Source clustering assets at zoom 3
+func newTestPipeline(t *testing.T) *Pipeline {
+	t.Helper()
+	template, err := cluster.NewClusterForZoom(3, 256, 40)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewPipeline(cluster.Levels{3: template}, nil)
+}
+
+// points returns the number of assets in clusters of the zoom
+func points(t *testing.T, p *Pipeline, zoom int) int {
+	t.Helper()
+	n := 0
+	if !p.View(zoom, func(c *cluster.Cluster) {
+		for _, cp := range c.ResultPoints {
+			n += cp.NumPoints
+		}
+	}) {
+		t.Fatalf("zoom %d is not served", zoom)
+	}
+	return n
+}
+
+func TestApplyInvalidCoordinates(t *testing.T) {
+	p := newTestPipeline(t)
+	if err := p.Apply(Update{ID: "a", Coordinates: cluster.GeoCoordinates{Lon: 13.4, Lat: 52.5}}); err != nil {
+		t.Fatal(err)
+	}
+	err := p.Apply(
+		Update{ID: "a", Coordinates: cluster.GeoCoordinates{Lon: math.NaN(), Lat: 52.5}},
+		Update{ID: "b", Coordinates: cluster.GeoCoordinates{Lon: 500, Lat: 0}},
+		Update{ID: "c", Coordinates: cluster.GeoCoordinates{Lon: 2.35, Lat: 48.85}},
+	)
+	if err == nil {
+		t.Fatal("expected error of invalid coordinates")
+	}
+	if p.Len() != 2 || points(t, p, 3) != 2 {
+		t.Fatalf("%d assets and %d clustered points, want 2 of the valid updates", p.Len(), points(t, p, 3))
+	}
+	p.View(3, func(c *cluster.Cluster) {
+		if _, err := cluster.MarshalGeoJSON(c.AllClusters()); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+// chanSource is Source of the channel messages, it fails with io.EOF when the channel is closed
+type chanSource chan []byte
+
+func (s chanSource) Next(ctx context.Context) ([]byte, error) {
+	select {
+	case msg, ok := <-s:
+		if !ok {
+			return nil, io.EOF
+		}
+		return msg, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func TestRun(t *testing.T) {
+	template, err := cluster.NewClusterForZoom(3, 256, 40)
+	if err != nil {
+		t.Fatal(err)
+	}
+	source := make(chanSource, 10)
+	p := NewPipeline(cluster.Levels{3: template}, source)
+	p.BatchSize = 2
+	var invalid []error
+	p.OnError = func(err error) { invalid = append(invalid, err) }
+	for _, msg := range []string{
+		`{"id": "a", "lon": 13.4, "lat": 52.5}`,
+		`{"id": "b", "lon": 2.35, "lat": 48.85}`,
+		`{"id": "c", "lon": -74, "lat": 40.7}`,
+		`{"id": "a", "lon": 13.41, "lat": 52.51}`,
+		`not json`,
+		`{"id": "b", "removed": true}`,
+	} {
+		source <- []byte(msg)
+	}
+	close(source)
+
+	//pending updates are applied before the error of the Source is returned
+	if err := p.Run(context.Background()); err == nil || !strings.Contains(err.Error(), io.EOF.Error()) {
+		t.Fatalf("Run returned %v, want error of the Source", err)
+	}
+	if len(invalid) != 1 {
+		t.Fatalf("OnError got %v, want error of the invalid message", invalid)
+	}
+	if p.Len() != 2 || points(t, p, 3) != 2 {
+		t.Fatalf("%d assets and %d clustered points, want a and c", p.Len(), points(t, p, 3))
+	}
+	p.View(3, func(c *cluster.Cluster) {
+		for _, cp := range c.ResultPoints {
+			if a := cp.IncludedPoints[0].(*Asset); a.ID == "a" && a.Coordinates.Lon != 13.41 {
+				t.Fatalf("asset a is at %v, want the last update", a.Coordinates)
+			}
+		}
+	})
+	if p.View(4, func(c *cluster.Cluster) {}) {
+		t.Fatal("zoom 4 is served")
+	}
+}
+
+func TestRunStopsOnInvalidMessage(t *testing.T) {
+	source := make(chanSource, 2)
+	p := NewPipeline(cluster.Levels{}, source)
+	source <- []byte(`{"lon": 1, "lat": 2}`)
+	if err := p.Run(context.Background()); err == nil || !strings.HasPrefix(err.Error(), "live: ") {
+		t.Fatalf("Run returned %v, want error of message without id", err)
+	}
+}
+
+func TestDecodeJSON(t *testing.T) {
+	u, err := DecodeJSON([]byte(`{"id": "t", "lon": 1, "lat": 2}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (Update{ID: "t", Coordinates: cluster.GeoCoordinates{Lon: 1, Lat: 2}}); u != want {
+		t.Fatalf("update is %+v, want %+v", u, want)
+	}
+	if u, err := DecodeJSON([]byte(`{"id": "t", "removed": true}`)); err != nil || u.Op != Remove {
+		t.Fatalf("update of removed asset is %+v: %v", u, err)
+	}
+	for _, msg := range []string{`{"id": "t", "lon": 1}`, `{"lon": 1, "lat": 2}`, `[]`} {
+		if _, err := DecodeJSON([]byte(msg)); err == nil {
+			t.Errorf("expected error of message %s", msg)
+		}
+	}
+}
+
+func TestSweep(t *testing.T) {
+	p := newTestPipeline(t)
+	p.TTL = time.Minute
+	now := time.Now()
+	err := p.Apply(
+		Update{ID: "ttl", Coordinates: cluster.GeoCoordinates{Lon: 13.4, Lat: 52.5}},
+		Update{ID: "expires", Coordinates: cluster.GeoCoordinates{Lon: 2.35, Lat: 48.85}, Expires: now.Add(time.Hour)},
+		Update{ID: "soon", Coordinates: cluster.GeoCoordinates{Lon: -74, Lat: 40.7}, Expires: now.Add(time.Second)},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := p.Sweep(now); n != 0 || err != nil {
+		t.Fatalf("%d assets are swept before they expire: %v", n, err)
+	}
+	//TTL applies to updates without Expires only
+	if n, err := p.Sweep(now.Add(2 * time.Minute)); n != 2 || err != nil {
+		t.Fatalf("%d assets are swept after TTL: %v", n, err)
+	}
+	if p.Len() != 1 || points(t, p, 3) != 1 {
+		t.Fatalf("%d assets and %d clustered points after sweep", p.Len(), points(t, p, 3))
+	}
+
+	//new update replaces Expires of the previous one
+	if err := p.Apply(Update{ID: "expires", Coordinates: cluster.GeoCoordinates{Lon: 2.35, Lat: 48.85}}); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := p.Sweep(now.Add(2 * time.Minute)); n != 1 || p.Len() != 0 {
+		t.Fatal("asset is kept by Expires of the previous update")
+	}
+}
+
+func TestDecodeExpires(t *testing.T) {
+	u, err := DecodeJSON([]byte(`{"id": "t", "lon": 1, "lat": 2, "expires": "2021-03-01T12:00:00Z"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC); !u.Expires.Equal(want) {
+		t.Fatalf("update expires at %v, want %v", u.Expires, want)
+	}
+}
@@ -0,0 +1,188 @@
+package live
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	cluster "github.com/iahmedov/gocluster"
+)
+
+// wsClient is the client side of the feed connection for tests
+type wsClient struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// dialFeed opens WebSocket connection to the server with Origin header, it returns the status of the handshake
+func dialFeed(t *testing.T, server *httptest.Server, origin string) (*wsClient, int) {
+	t.Helper()
+	conn, err := net.Dial("tcp", strings.TrimPrefix(server.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := base64.StdEncoding.EncodeToString([]byte("0123456789abcdef"))
+	request := "GET /feed HTTP/1.1\r\nHost: " + strings.TrimPrefix(server.URL, "http://") + "\r\n" +
+		"Connection: Upgrade\r\nUpgrade: websocket\r\nSec-WebSocket-Version: 13\r\nSec-WebSocket-Key: " + key + "\r\n"
+	if origin != "" {
+		request += "Origin: " + origin + "\r\n"
+	}
+	if _, err := io.WriteString(conn, request+"\r\n"); err != nil {
+		t.Fatal(err)
+	}
+	r := bufio.NewReader(conn)
+	response, err := http.ReadResponse(r, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.StatusCode != http.StatusSwitchingProtocols {
+		conn.Close()
+		return nil, response.StatusCode
+	}
+	sum := sha1.Sum([]byte(key + wsGUID))
+	if accept := response.Header.Get("Sec-WebSocket-Accept"); accept != base64.StdEncoding.EncodeToString(sum[:]) {
+		t.Fatalf("invalid Sec-WebSocket-Accept %q", accept)
+	}
+	return &wsClient{conn: conn, r: r}, response.StatusCode
+}
+
+// write writes masked frame, as clients do
+func (c *wsClient) write(t *testing.T, opcode byte, payload []byte) {
+	t.Helper()
+	mask := [4]byte{1, 2, 3, 4}
+	frame := []byte{0x80 | opcode, 0x80 | byte(len(payload))}
+	frame = append(frame, mask[:]...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	if _, err := c.conn.Write(frame); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// read reads unmasked frame of the server
+func (c *wsClient) read(t *testing.T) (opcode byte, payload []byte) {
+	t.Helper()
+	c.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var header [2]byte
+	if _, err := io.ReadFull(c.r, header[:]); err != nil {
+		t.Fatal(err)
+	}
+	n := uint64(header[1] & 0x7F)
+	switch n {
+	case 126:
+		var b [2]byte
+		io.ReadFull(c.r, b[:])
+		n = uint64(binary.BigEndian.Uint16(b[:]))
+	case 127:
+		var b [8]byte
+		io.ReadFull(c.r, b[:])
+		n = binary.BigEndian.Uint64(b[:])
+	}
+	payload = make([]byte, n)
+	if _, err := io.ReadFull(c.r, payload); err != nil {
+		t.Fatal(err)
+	}
+	return header[0] & 0x0F, payload
+}
+
+func TestFeedOrigin(t *testing.T) {
+	p := newTestPipeline(t)
+	server := httptest.NewServer(p.FeedHandler())
+	defer server.Close()
+	host := strings.TrimPrefix(server.URL, "http://")
+
+	for origin, want := range map[string]int{
+		"":                         http.StatusSwitchingProtocols,
+		"http://" + host:           http.StatusSwitchingProtocols,
+		"https://evil.example.com": http.StatusForbidden,
+		"null":                     http.StatusForbidden,
+	} {
+		c, status := dialFeed(t, server, origin)
+		if status != want {
+			t.Errorf("origin %q: status %d, want %d", origin, status, want)
+		}
+		if c != nil {
+			c.conn.Close()
+		}
+	}
+
+	p.CheckOrigin = func(r *http.Request) bool { return r.Header.Get("Origin") == "https://dashboard.example.com" }
+	if _, status := dialFeed(t, server, "https://dashboard.example.com"); status != http.StatusSwitchingProtocols {
+		t.Errorf("origin accepted by CheckOrigin is rejected with %d", status)
+	}
+}
+
+func TestFeedChanges(t *testing.T) {
+	p := newTestPipeline(t)
+	if err := p.Apply(Update{ID: "truck-1", Coordinates: cluster.GeoCoordinates{Lon: 13.4, Lat: 52.5}}); err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(p.FeedHandler())
+	defer server.Close()
+	c, status := dialFeed(t, server, "")
+	if c == nil {
+		t.Fatalf("handshake failed with %d", status)
+	}
+	defer c.conn.Close()
+
+	//pings are answered while subscriptions are read
+	c.write(t, wsPing, []byte("ping"))
+	if opcode, payload := c.read(t); opcode != wsPong || string(payload) != "ping" {
+		t.Fatalf("got opcode %d %q, want pong", opcode, payload)
+	}
+
+	c.write(t, wsText, []byte(`{"bbox": [0, 40, 30, 60], "zoom": 3}`))
+	change := readChange(t, c)
+	if len(change.Created) != 1 || len(change.Updated) != 0 || len(change.Removed) != 0 {
+		t.Fatalf("first change is %+v, want one created point", change)
+	}
+
+	//the new asset of the viewport is created, the one outside of it is not sent
+	if err := p.Apply(
+		Update{ID: "truck-2", Coordinates: cluster.GeoCoordinates{Lon: 2.35, Lat: 48.85}},
+		Update{ID: "truck-3", Coordinates: cluster.GeoCoordinates{Lon: -74, Lat: 40.7}},
+	); err != nil {
+		t.Fatal(err)
+	}
+	change = readChange(t, c)
+	if len(change.Created) != 1 || !strings.Contains(string(change.Created[0]), "truck-2") {
+		t.Fatalf("change is %+v, want truck-2 created", change)
+	}
+
+	if err := p.Apply(Update{ID: "truck-2", Op: Remove}); err != nil {
+		t.Fatal(err)
+	}
+	change = readChange(t, c)
+	if len(change.Removed) != 1 || fmt.Sprint(change.Removed[0]) != "truck-2" {
+		t.Fatalf("change is %+v, want truck-2 removed", change)
+	}
+
+	c.write(t, wsClose, nil)
+	if opcode, _ := c.read(t); opcode != wsClose {
+		t.Fatalf("got opcode %d, want close", opcode)
+	}
+}
+
+func readChange(t *testing.T, c *wsClient) Change {
+	t.Helper()
+	opcode, payload := c.read(t)
+	if opcode != wsText {
+		t.Fatalf("got opcode %d, want text", opcode)
+	}
+	var change Change
+	if err := json.Unmarshal(payload, &change); err != nil {
+		t.Fatal(err)
+	}
+	return change
+}
@@ -0,0 +1,230 @@
+package cluster
+
+import (
+	"container/list"
+	"fmt"
+	"sort"
+	"sync"
+	"unsafe"
+)
+
+// Dataset describes how Manager loads the named Index
+// Template - clustering options of the dataset, see NewBuilder
+// Points - loads points of the dataset, they are clustered with Template options
+// Index - loads already clustered Index, e.g. with ReadIndex from snapshot, it's used instead of Template and Points if set
+type Dataset struct {
+	Template *Cluster
+	Points   func() ([]GeoPoint, error)
+	Index    func() (*Index, error)
+}
+
+// Manager owns Indexes of many named datasets, e.g. one per customer or layer
+// Datasets are loaded on the first Get, least recently used ones are evicted when loaded Indexes take
+// more than MemoryBudget bytes, and loaded again when they are needed. It's safe for concurrent use.
+type Manager struct {
+	// MemoryBudget is approximate memory for loaded Indexes in bytes, zero means no limit
+	// The last loaded Index is never evicted, even if it's bigger than the budget
+	MemoryBudget int
+
+	mu       sync.Mutex
+	datasets map[string]*managedDataset
+	lru      *list.List //names of loaded datasets, recently used first
+	bytes    int
+}
+
+type managedDataset struct {
+	Dataset
+	index   *Index
+	bytes   int
+	used    *list.Element
+	loading chan struct{} //closed when the running load is done
+	err     error
+}
+
+// NewManager creates Manager keeping loaded Indexes within memoryBudget bytes, zero means no limit
+func NewManager(memoryBudget int) *Manager {
+	return &Manager{
+		MemoryBudget: memoryBudget,
+		datasets:     map[string]*managedDataset{},
+		lru:          list.New(),
+	}
+}
+
+// Register adds the dataset or replaces its options, replaced dataset is loaded again on the next Get
+func (m *Manager) Register(name string, dataset Dataset) error {
+	if dataset.Index == nil && (dataset.Template == nil || dataset.Points == nil) {
+		return fmt.Errorf("gocluster: dataset %q should have Index or Template and Points", name)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if d, ok := m.datasets[name]; ok {
+		m.unload(d)
+	}
+	m.datasets[name] = &managedDataset{Dataset: dataset}
+	return nil
+}
+
+// Remove removes the dataset, Indexes returned by Get are still valid
+func (m *Manager) Remove(name string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if d, ok := m.datasets[name]; ok {
+		m.unload(d)
+		delete(m.datasets, name)
+	}
+}
+
+// Set registers already built Index, it could be evicted like loaded ones if the dataset could be loaded again
+func (m *Manager) Set(name string, index *Index) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	d, ok := m.datasets[name]
+	if !ok {
+		d = &managedDataset{}
+		m.datasets[name] = d
+	}
+	m.unload(d)
+	m.loaded(name, d, index)
+}
+
+// Get returns Index of the dataset, loading it if needed
+// Concurrent Gets of not loaded dataset wait for the same load, failed load is tried again by the next Get.
+func (m *Manager) Get(name string) (*Index, error) {
+	m.mu.Lock()
+	d, ok := m.datasets[name]
+	if !ok {
+		m.mu.Unlock()
+		return nil, fmt.Errorf("gocluster: dataset %q is not registered", name)
+	}
+	if d.index != nil {
+		m.lru.MoveToFront(d.used)
+		index := d.index
+		m.mu.Unlock()
+		return index, nil
+	}
+	if d.loading != nil {
+		loading := d.loading
+		m.mu.Unlock()
+		<-loading
+		m.mu.Lock()
+		index, err := d.index, d.err
+		m.mu.Unlock()
+		if index == nil && err == nil {
+			//evicted or replaced right after load
+			return m.Get(name)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("gocluster: can't load dataset %q: %v", name, err)
+		}
+		return index, nil
+	}
+	if d.Index == nil && d.Points == nil {
+		m.mu.Unlock()
+		return nil, fmt.Errorf("gocluster: dataset %q was evicted and can't be loaded", name)
+	}
+	d.loading = make(chan struct{})
+	m.mu.Unlock()
+
+	index, err := d.load()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	close(d.loading)
+	d.loading, d.err = nil, err
+	if err != nil {
+		return nil, fmt.Errorf("gocluster: can't load dataset %q: %v", name, err)
+	}
+	if m.datasets[name] == d && d.index == nil {
+		m.loaded(name, d, index)
+	}
+	return index, nil
+}
+
+func (d *managedDataset) load() (*Index, error) {
+	if d.Index != nil {
+		return d.Index()
+	}
+	points, err := d.Points()
+	if err != nil {
+		return nil, err
+	}
+	b := NewBuilder(d.Template)
+	b.AddPoints(points...)
+	return b.Build()
+}
+
+// loaded keeps index of the dataset and evicts least recently used datasets over the budget
+func (m *Manager) loaded(name string, d *managedDataset, index *Index) {
+	d.index, d.bytes = index, index.Bytes()
+	d.used = m.lru.PushFront(name)
+	m.bytes += d.bytes
+	for e := m.lru.Back(); e != nil && m.MemoryBudget > 0 && m.bytes > m.MemoryBudget; {
+		prev := e.Prev()
+		//Index of Set without Dataset can't be loaded again, so it's kept
+		if victim := m.datasets[e.Value.(string)]; victim != d && (victim.Index != nil || victim.Points != nil) {
+			m.unload(victim)
+		}
+		e = prev
+	}
+}
+
+func (m *Manager) unload(d *managedDataset) {
+	if d.index == nil {
+		return
+	}
+	m.lru.Remove(d.used)
+	m.bytes -= d.bytes
+	d.index, d.bytes, d.used = nil, 0, nil
+}
+
+// Names returns sorted names of registered datasets
+func (m *Manager) Names() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	names := make([]string, 0, len(m.datasets))
+	for name := range m.datasets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Loaded returns true if Index of the dataset is loaded
+func (m *Manager) Loaded(name string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	d, ok := m.datasets[name]
+	return ok && d.index != nil
+}
+
+// MemoryUsage returns approximate memory taken by loaded Indexes in bytes
+func (m *Manager) MemoryUsage() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.bytes
+}
+
+// memoryBytes returns approximate memory taken by clustered points, index and results
+// Input points themselves are not counted, they are owned by the caller.
+func (c *Cluster) memoryBytes() int {
+	pointSize := int(unsafe.Sizeof(ClusterPoint{}))
+	n := len(c.assignment)*8 + len(c.baseOf)*8 + len(c.ResultPoints)*pointSize
+	if c.baseIndex != nil {
+		n += c.baseIndex.Bytes()
+	}
+	if r := c.results; r != nil {
+		r.mu.Lock()
+		if r.temporal != nil {
+			n += r.temporal.Bytes()
+		}
+		r.mu.Unlock()
+	}
+	for _, p := range c.basePoints {
+		n += pointSize + 8 + len(p.memberIDs)*8 + len(p.IncludedPoints)*16
+	}
+	for i := range c.ResultPoints {
+		p := &c.ResultPoints[i]
+		n += len(p.memberIDs)*8 + len(p.IncludedPoints)*16
+	}
+	return n
+}
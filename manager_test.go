@@ -0,0 +1,129 @@
+package cluster
+
+import (
+	"errors"
+	"sync"
+	"testing"
+)
+
+// countingDataset returns dataset of n random points, loads are counted
+func countingDataset(t *testing.T, n int, loads *int) Dataset {
+	template, err := NewClusterForZoom(4, 256, 40)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Dataset{Template: template, Points: func() ([]GeoPoint, error) {
+		*loads++
+		return randomPoints(n, int64(n), -60, -60, 60, 60), nil
+	}}
+}
+
+func TestManagerEviction(t *testing.T) {
+	var loadsA, loadsB int
+	probe := NewBuilder(countingDataset(t, 1000, new(int)).Template)
+	probe.AddPoints(randomPoints(1000, 1000, -60, -60, 60, 60)...)
+	index, err := probe.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	//budget fits one dataset only
+	m := NewManager(index.Bytes() * 3 / 2)
+	if err := m.Register("a", countingDataset(t, 1000, &loadsA)); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Register("b", countingDataset(t, 1000, &loadsB)); err != nil {
+		t.Fatal(err)
+	}
+
+	a, err := m.Get("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := m.Get("a"); again != a || loadsA != 1 {
+		t.Fatalf("dataset a is loaded %d times", loadsA)
+	}
+	if totalPoints(a.AllClusters()) != 1000 || m.MemoryUsage() != a.Bytes() {
+		t.Fatalf("index of a has %d points, memory usage is %d", totalPoints(a.AllClusters()), m.MemoryUsage())
+	}
+	if _, err := m.Get("b"); err != nil {
+		t.Fatal(err)
+	}
+	if m.Loaded("a") || !m.Loaded("b") {
+		t.Fatal("least recently used dataset is not evicted")
+	}
+	if _, err := m.Get("a"); err != nil || loadsA != 2 {
+		t.Fatalf("evicted dataset is loaded %d times: %v", loadsA, err)
+	}
+
+	//Set Index without Dataset can't be loaded again, so it's never evicted
+	m.Set("c", index)
+	if _, err := m.Get("a"); err != nil {
+		t.Fatal(err)
+	}
+	if !m.Loaded("c") {
+		t.Fatal("index without dataset is evicted")
+	}
+	if names := m.Names(); len(names) != 3 || names[0] != "a" || names[2] != "c" {
+		t.Fatalf("names are %v", names)
+	}
+	m.Remove("c")
+	if _, err := m.Get("c"); err == nil {
+		t.Fatal("expected error of removed dataset")
+	}
+}
+
+func TestManagerConcurrentLoad(t *testing.T) {
+	loads := 0
+	m := NewManager(0)
+	if err := m.Register("a", countingDataset(t, 500, &loads)); err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	indexes := make([]*Index, 8)
+	for i := range indexes {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			indexes[i], _ = m.Get("a")
+		}(i)
+	}
+	wg.Wait()
+	for _, index := range indexes {
+		if index == nil || index != indexes[0] {
+			t.Fatal("concurrent Gets returned different indexes")
+		}
+	}
+	if loads != 1 {
+		t.Fatalf("dataset is loaded %d times", loads)
+	}
+}
+
+func TestManagerErrors(t *testing.T) {
+	m := NewManager(0)
+	if err := m.Register("a", Dataset{}); err == nil {
+		t.Fatal("expected error of dataset without loader")
+	}
+	if _, err := m.Get("a"); err == nil {
+		t.Fatal("expected error of not registered dataset")
+	}
+	fail := true
+	err := m.Register("a", Dataset{Index: func() (*Index, error) {
+		if fail {
+			return nil, errors.New("broken snapshot")
+		}
+		b := NewBuilder(NewCluster(0.01))
+		b.AddPoints(randomPoints(10, 28, 0, 0, 1, 1)...)
+		return b.Build()
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Get("a"); err == nil {
+		t.Fatal("expected error of failed load")
+	}
+	//failed load is tried again
+	fail = false
+	if _, err := m.Get("a"); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,219 @@
+// Package mbtiles writes the tile pyramid of clusters into MBTiles file, SQLite database of gzipped vector tiles,
+// so static hosting, CDNs and tile servers serve cluster tiles without Go process at request time.
+//
+// Tiles are written for each zoom of the levels, only tiles with points are stored.
+// Rows of MBTiles are in TMS scheme, the row of XYZ tile is 2^zoom - 1 - Y.
+// The package uses database/sql only, any SQLite driver could be used, e.g. mattn/go-sqlite3 or modernc.org/sqlite.
+package mbtiles
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+
+	cluster "github.com/iahmedov/gocluster"
+)
+
+// DefaultLayer is the name of the single layer of tiles if Writer has no Layers
+const DefaultLayer = "clusters"
+
+// Writer renders Levels into MBTiles database
+// Name and Description are written to metadata, Layers are the layers of each tile, e.g. cluster.ClusterLayers.
+type Writer struct {
+	Name        string
+	Description string
+	Layers      []cluster.MVTLayer
+}
+
+var schema = []string{
+	"CREATE TABLE IF NOT EXISTS metadata (name TEXT, value TEXT)",
+	"CREATE TABLE IF NOT EXISTS tiles (zoom_level INTEGER, tile_column INTEGER, tile_row INTEGER, tile_data BLOB)",
+	"CREATE UNIQUE INDEX IF NOT EXISTS tile_index ON tiles (zoom_level, tile_column, tile_row)",
+	"DELETE FROM metadata",
+	"DELETE FROM tiles",
+}
+
+// Write replaces tiles and metadata of db with tiles of levels in one transaction, returns number of written tiles
+func (w *Writer) Write(ctx context.Context, db *sql.DB, levels cluster.Levels) (int, error) {
+	if len(levels) == 0 {
+		return 0, errors.New("mbtiles: no levels")
+	}
+	layers := w.Layers
+	if len(layers) == 0 {
+		layers = []cluster.MVTLayer{{Name: DefaultLayer}}
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("mbtiles: %v", err)
+	}
+	defer tx.Rollback()
+	for _, statement := range schema {
+		if _, err := tx.ExecContext(ctx, statement); err != nil {
+			return 0, fmt.Errorf("mbtiles: can't create schema: %v", err)
+		}
+	}
+	insert, err := tx.PrepareContext(ctx, "INSERT INTO tiles (zoom_level, tile_column, tile_row, tile_data) VALUES (?, ?, ?, ?)")
+	if err != nil {
+		return 0, fmt.Errorf("mbtiles: %v", err)
+	}
+	defer insert.Close()
+
+	zooms := make([]int, 0, len(levels))
+	for zoom := range levels {
+		zooms = append(zooms, zoom)
+	}
+	sort.Ints(zooms)
+	total := 0
+	for _, zoom := range zooms {
+		points := levels[zoom].AllClusters()
+		tiles, byTile := tilesOf(points, zoom)
+		for _, t := range tiles {
+			data, err := gzipped(cluster.EncodeMVTLayers(byTile[t], t, layers))
+			if err != nil {
+				return total, fmt.Errorf("mbtiles: %v", err)
+			}
+			row := 1<<uint(zoom) - 1 - t.Y
+			if _, err := insert.ExecContext(ctx, zoom, t.X, row, data); err != nil {
+				return total, fmt.Errorf("mbtiles: can't write tile %v: %v", t, err)
+			}
+			total++
+		}
+	}
+
+	metadata, err := w.metadata(levels, zooms, layers)
+	if err != nil {
+		return total, err
+	}
+	for _, kv := range metadata {
+		if _, err := tx.ExecContext(ctx, "INSERT INTO metadata (name, value) VALUES (?, ?)", kv[0], kv[1]); err != nil {
+			return total, fmt.Errorf("mbtiles: can't write metadata: %v", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return total, fmt.Errorf("mbtiles: %v", err)
+	}
+	return total, nil
+}
+
+// tilesOf groups points by tiles of the zoom, tiles are sorted, so files are the same on every run
+func tilesOf(points []cluster.ClusterPoint, zoom int) ([]cluster.Tile, map[cluster.Tile][]cluster.ClusterPoint) {
+	byTile := map[cluster.Tile][]cluster.ClusterPoint{}
+	var tiles []cluster.Tile
+	for _, p := range points {
+		t := cluster.LonLatToTile(cluster.GeoCoordinates{Lon: p.X, Lat: p.Y}, zoom)
+		if _, ok := byTile[t]; !ok {
+			tiles = append(tiles, t)
+		}
+		byTile[t] = append(byTile[t], p)
+	}
+	sort.Slice(tiles, func(i, j int) bool {
+		if tiles[i].X != tiles[j].X {
+			return tiles[i].X < tiles[j].X
+		}
+		return tiles[i].Y < tiles[j].Y
+	})
+	return tiles, byTile
+}
+
+func gzipped(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// vectorLayer is the layer description of json metadata, fields are property names and their types
+type vectorLayer struct {
+	ID      string            `json:"id"`
+	Fields  map[string]string `json:"fields"`
+	MinZoom int               `json:"minzoom"`
+	MaxZoom int               `json:"maxzoom"`
+}
+
+// metadata returns name and value pairs of metadata table
+func (w *Writer) metadata(levels cluster.Levels, zooms []int, layers []cluster.MVTLayer) ([][2]string, error) {
+	minZoom, maxZoom := zooms[0], zooms[len(zooms)-1]
+	vectorLayers := make([]vectorLayer, len(layers))
+	for i, l := range layers {
+		vectorLayers[i] = vectorLayer{ID: l.Name, Fields: map[string]string{}, MinZoom: minZoom, MaxZoom: maxZoom}
+	}
+	west, south, east, north := math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)
+	for _, zoom := range zooms {
+		points := levels[zoom].AllClusters()
+		for i := range points {
+			p := &points[i]
+			west, east = math.Min(west, p.X), math.Max(east, p.X)
+			south, north = math.Min(south, p.Y), math.Max(north, p.Y)
+			for j, l := range layers {
+				if l.Filter == nil || l.Filter(p) {
+					addFields(vectorLayers[j].Fields, cluster.ClusterProperties(p), l.Properties)
+				}
+			}
+		}
+	}
+	if west > east {
+		west, south, east, north = -180, -85.0511, 180, 85.0511
+	}
+	layersJSON, err := json.Marshal(map[string]interface{}{"vector_layers": vectorLayers})
+	if err != nil {
+		return nil, fmt.Errorf("mbtiles: %v", err)
+	}
+	name := w.Name
+	if name == "" {
+		name = DefaultLayer
+	}
+	return [][2]string{
+		{"name", name},
+		{"description", w.Description},
+		{"format", "pbf"},
+		{"type", "overlay"},
+		{"version", "1"},
+		{"minzoom", fmt.Sprint(minZoom)},
+		{"maxzoom", fmt.Sprint(maxZoom)},
+		{"bounds", fmt.Sprintf("%g,%g,%g,%g", west, south, east, north)},
+		{"center", fmt.Sprintf("%g,%g,%d", (west+east)/2, (south+north)/2, minZoom)},
+		{"json", string(layersJSON)},
+	}, nil
+}
+
+// addFields adds types of properties to fields, only names are added if they are not nil
+// Types are the ones MVT encoder writes, properties of other types are skipped.
+func addFields(fields map[string]string, properties map[string]interface{}, names []string) {
+	for k, v := range properties {
+		if names != nil && !contains(names, k) {
+			continue
+		}
+		if _, ok := fields[k]; ok {
+			continue
+		}
+		switch v.(type) {
+		case string:
+			fields[k] = "String"
+		case bool:
+			fields[k] = "Boolean"
+		case float64, float32, int, int32, int64, uint, uint32, uint64:
+			fields[k] = "Number"
+		}
+	}
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
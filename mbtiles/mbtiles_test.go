@@ -0,0 +1,251 @@
+package mbtiles
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"strings"
+	"sync"
+	"testing"
+
+	cluster "github.com/iahmedov/gocluster"
+)
+
+// recorder keeps statements executed by connections of the fake driver
+type recorder struct {
+	mu         sync.Mutex
+	statements []statement
+	committed  bool
+	rolledBack bool
+	failOn     string //statements containing it fail
+}
+
+type statement struct {
+	query string
+	args  []driver.Value
+}
+
+var (
+	recordersMu sync.Mutex
+	recorders   = map[string]*recorder{}
+)
+
+func init() {
+	sql.Register("mbtiles-test", fakeDriver{})
+}
+
+// openFake returns database of the fake driver, which records statements instead of writing SQLite file
+func openFake(t *testing.T, failOn string) (*sql.DB, *recorder) {
+	t.Helper()
+	r := &recorder{failOn: failOn}
+	recordersMu.Lock()
+	recorders[t.Name()] = r
+	recordersMu.Unlock()
+	db, err := sql.Open("mbtiles-test", t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db, r
+}
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(name string) (driver.Conn, error) {
+	recordersMu.Lock()
+	defer recordersMu.Unlock()
+	r, ok := recorders[name]
+	if !ok {
+		return nil, errors.New("unknown database")
+	}
+	return &fakeConn{r: r}, nil
+}
+
+type fakeConn struct {
+	r *recorder
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeStmt{r: c.r, query: query}, nil
+}
+
+func (c *fakeConn) Close() error { return nil }
+
+func (c *fakeConn) Begin() (driver.Tx, error) { return fakeTx{c.r}, nil }
+
+type fakeTx struct {
+	r *recorder
+}
+
+func (tx fakeTx) Commit() error {
+	tx.r.mu.Lock()
+	defer tx.r.mu.Unlock()
+	tx.r.committed = true
+	return nil
+}
+
+func (tx fakeTx) Rollback() error {
+	tx.r.mu.Lock()
+	defer tx.r.mu.Unlock()
+	tx.r.rolledBack = true
+	return nil
+}
+
+type fakeStmt struct {
+	r     *recorder
+	query string
+}
+
+func (s *fakeStmt) Close() error { return nil }
+
+func (s *fakeStmt) NumInput() int { return -1 }
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.r.mu.Lock()
+	defer s.r.mu.Unlock()
+	if s.r.failOn != "" && strings.Contains(s.query, s.r.failOn) {
+		return nil, errors.New("disk is full")
+	}
+	s.r.statements = append(s.r.statements, statement{query: s.query, args: args})
+	return driver.RowsAffected(1), nil
+}
+
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return nil, errors.New("queries are not supported")
+}
+
+// testLevels returns levels of the grid of points over central Europe at zooms 2 and 6
+func testLevels(t *testing.T) cluster.Levels {
+	t.Helper()
+	points := make([]cluster.GeoPoint, 200)
+	for i := range points {
+		points[i] = &cluster.Feature{
+			ID:          i,
+			Coordinates: cluster.GeoCoordinates{Lon: 5 + float64(i%20), Lat: 45 + float64(i/20)},
+			Properties:  map[string]interface{}{"name": "place"},
+		}
+	}
+	levels := cluster.Levels{}
+	for _, zoom := range []int{2, 6} {
+		c, err := cluster.NewClusterForZoom(zoom, 256, 40)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := c.ClusterPoints(points); err != nil {
+			t.Fatal(err)
+		}
+		levels[zoom] = c
+	}
+	return levels
+}
+
+func gunzip(t *testing.T, data []byte) []byte {
+	t.Helper()
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := ioutil.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+func TestWrite(t *testing.T) {
+	db, r := openFake(t, "")
+	defer db.Close()
+	levels := testLevels(t)
+	layers := cluster.ClusterLayers("clusters", "points")
+	w := &Writer{Name: "places", Description: "test places", Layers: layers}
+	n, err := w.Write(context.Background(), db, levels)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.committed {
+		t.Fatal("transaction is not committed")
+	}
+
+	//tiles of each zoom have all points of the level, rows are TMS
+	tiles := 0
+	counts := map[int]int{}
+	metadata := map[string]string{}
+	for _, s := range r.statements {
+		switch {
+		case strings.HasPrefix(s.query, "INSERT INTO tiles"):
+			tiles++
+			zoom, x, row := int(s.args[0].(int64)), int(s.args[1].(int64)), int(s.args[2].(int64))
+			tile := cluster.Tile{X: x, Y: 1<<uint(zoom) - 1 - row, Z: zoom}
+			var points []cluster.ClusterPoint
+			for _, p := range levels[zoom].AllClusters() {
+				if cluster.LonLatToTile(cluster.GeoCoordinates{Lon: p.X, Lat: p.Y}, zoom) == tile {
+					points = append(points, p)
+					counts[zoom] += p.NumPoints
+				}
+			}
+			if len(points) == 0 {
+				t.Fatalf("tile %v has no points", tile)
+			}
+			if !bytes.Equal(gunzip(t, s.args[3].([]byte)), cluster.EncodeMVTLayers(points, tile, layers)) {
+				t.Fatalf("tile %v is not MVT of its points", tile)
+			}
+		case strings.HasPrefix(s.query, "INSERT INTO metadata"):
+			metadata[s.args[0].(string)] = s.args[1].(string)
+		}
+	}
+	if tiles != n || tiles == 0 {
+		t.Fatalf("%d tiles are written, Write returned %d", tiles, n)
+	}
+	if counts[2] != 200 || counts[6] != 200 {
+		t.Fatalf("tiles have %v points, want 200 at each zoom", counts)
+	}
+
+	if metadata["name"] != "places" || metadata["description"] != "test places" || metadata["format"] != "pbf" ||
+		metadata["minzoom"] != "2" || metadata["maxzoom"] != "6" {
+		t.Fatalf("metadata %v", metadata)
+	}
+	//bounds are of result points, their coordinates are projected back to lon/lat
+	var bounds [4]float64
+	if _, err := fmt.Sscanf(metadata["bounds"], "%g,%g,%g,%g", &bounds[0], &bounds[1], &bounds[2], &bounds[3]); err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range [4]float64{5, 45, 24, 54} {
+		if math.Abs(bounds[i]-want) > 1e-9 {
+			t.Fatalf("bounds %v, want %v", bounds, [4]float64{5, 45, 24, 54})
+		}
+	}
+	var vector struct {
+		Layers []vectorLayer `json:"vector_layers"`
+	}
+	if err := json.Unmarshal([]byte(metadata["json"]), &vector); err != nil {
+		t.Fatal(err)
+	}
+	if len(vector.Layers) != 2 || vector.Layers[0].ID != "clusters" || vector.Layers[0].Fields["point_count"] != "Number" ||
+		vector.Layers[1].ID != "points" || vector.Layers[1].Fields["name"] != "String" {
+		t.Fatalf("vector layers %+v", vector.Layers)
+	}
+	if _, ok := vector.Layers[0].Fields["name"]; ok {
+		t.Fatal("clusters layer has fields of single points")
+	}
+}
+
+func TestWriteRollback(t *testing.T) {
+	db, r := openFake(t, "INSERT INTO metadata")
+	defer db.Close()
+	if _, err := (&Writer{}).Write(context.Background(), db, testLevels(t)); err == nil {
+		t.Fatal("expected error of failed metadata")
+	}
+	if r.committed || !r.rolledBack {
+		t.Fatal("failed write is committed")
+	}
+
+	if _, err := (&Writer{}).Write(context.Background(), db, cluster.Levels{}); err == nil {
+		t.Fatal("expected error of no levels")
+	}
+}
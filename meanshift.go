@@ -0,0 +1,164 @@
+package cluster
+
+import (
+	"math"
+	"sort"
+)
+
+// mean-shift convergence parameters
+const (
+	meanShiftMaxIterations = 300
+	meanShiftTolerance     = 1e-3 //part of bandwidth
+)
+
+type meanShiftMode struct {
+	x, y    float64
+	support int //weight of points within bandwidth of the mode
+}
+
+// bandwidth returns mean-shift kernel radius
+func (c *Cluster) bandwidth() float64 {
+	if c.Bandwidth > 0 {
+		return c.Bandwidth
+	}
+	return c.Epsilon
+}
+
+// meanShift clusters base points around modes of their density
+// Seeds are centroids of bandwidth sized grid cells, each seed is shifted to the mean of its neighbours
+// with flat kernel until convergence. Modes closer than bandwidth are merged, the one with more support wins.
+// Each point belongs to the nearest mode within bandwidth, cluster center is the mode itself.
+func (c *Cluster) meanShift() []*ClusterPoint {
+	bw := c.bandwidth()
+	modes := c.meanShiftModes(c.meanShiftSeeds(bw), bw)
+
+	//assign points to the nearest mode
+	modePoints := make([]*ClusterPoint, len(modes))
+	for i, m := range modes {
+		modePoints[i] = &ClusterPoint{X: m.x, Y: m.y}
+	}
+	modeIndex := newSpatialIndex(modePoints, c.NodeSize, CoordinatesFloat64)
+	groups := make([][]*ClusterPoint, len(modes))
+	var orphans []*ClusterPoint
+	var found []int
+	for _, p := range c.basePoints {
+		p.visited = true
+		found = modeIndex.AppendWithin(found[:0], p.X, p.Y, bw)
+		best, bestDist := -1, math.Inf(1)
+		for _, m := range found {
+			if d := sqDist(p.X, p.Y, modes[m].x, modes[m].y); d < bestDist {
+				best, bestDist = m, d
+			}
+		}
+		if best < 0 {
+			orphans = append(orphans, p)
+			continue
+		}
+		groups[best] = append(groups[best], p)
+	}
+
+	var result []*ClusterPoint
+	for i, group := range groups {
+		weight := 0
+		for _, p := range group {
+			weight += p.NumPoints
+		}
+		switch {
+		case len(group) == 0:
+		case len(group) == 1 || weight < c.MinPoints:
+			result = append(result, group...)
+		default:
+			cluster := c.newCluster(group[0], group[1:])
+			cluster.X, cluster.Y = modes[i].x, modes[i].y
+			result = append(result, cluster)
+		}
+	}
+	return append(result, orphans...)
+}
+
+// meanShiftSeeds returns centroids of grid cells of bandwidth size
+func (c *Cluster) meanShiftSeeds(bw float64) [][2]float64 {
+	type bin struct {
+		x, y   float64
+		weight float64
+		order  int
+	}
+	bins := map[[2]int64]*bin{}
+	for _, p := range c.basePoints {
+		key := [2]int64{int64(math.Floor(p.X / bw)), int64(math.Floor(p.Y / bw))}
+		b, ok := bins[key]
+		if !ok {
+			b = &bin{order: len(bins)}
+			bins[key] = b
+		}
+		w := float64(p.NumPoints)
+		b.x += p.X * w
+		b.y += p.Y * w
+		b.weight += w
+	}
+	seeds := make([][2]float64, len(bins))
+	for _, b := range bins {
+		seeds[b.order] = [2]float64{b.x / b.weight, b.y / b.weight}
+	}
+	return seeds
+}
+
+// meanShiftModes shifts seeds to density modes and merges close modes
+func (c *Cluster) meanShiftModes(seeds [][2]float64, bw float64) []meanShiftMode {
+	var neighbours []int
+	var modes []meanShiftMode
+	for _, s := range seeds {
+		x, y := s[0], s[1]
+		support := 0
+		for it := 0; it < meanShiftMaxIterations; it++ {
+			neighbours = c.baseIndex.AppendWithin(neighbours[:0], x, y, bw)
+			if len(neighbours) == 0 {
+				break
+			}
+			var wx, wy float64
+			support = 0
+			for _, id := range neighbours {
+				p := c.basePoints[id]
+				wx += p.X * float64(p.NumPoints)
+				wy += p.Y * float64(p.NumPoints)
+				support += p.NumPoints
+			}
+			nx, ny := wx/float64(support), wy/float64(support)
+			shift := math.Hypot(nx-x, ny-y)
+			x, y = nx, ny
+			if shift < bw*meanShiftTolerance {
+				break
+			}
+		}
+		if support > 0 {
+			modes = append(modes, meanShiftMode{x: x, y: y, support: support})
+		}
+	}
+
+	//modes with more support suppress their close neighbours
+	sort.SliceStable(modes, func(i, j int) bool { return modes[i].support > modes[j].support })
+	//accepted modes are kept in grid of bandwidth cells, so only 9 cells are checked for each mode
+	var result []meanShiftMode
+	grid := map[[2]int64][]int{}
+	bw2 := bw * bw
+	for _, m := range modes {
+		cx, cy := int64(math.Floor(m.x/bw)), int64(math.Floor(m.y/bw))
+		unique := true
+		for dx := int64(-1); dx <= 1 && unique; dx++ {
+			for dy := int64(-1); dy <= 1 && unique; dy++ {
+				for _, r := range grid[[2]int64{cx + dx, cy + dy}] {
+					if sqDist(m.x, m.y, result[r].x, result[r].y) < bw2 {
+						unique = false
+						break
+					}
+				}
+			}
+		}
+		if unique {
+			key := [2]int64{cx, cy}
+			grid[key] = append(grid[key], len(result))
+			result = append(result, m)
+		}
+	}
+	return result
+}
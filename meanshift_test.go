@@ -0,0 +1,56 @@
+package cluster
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestMeanShiftFindsBlobs(t *testing.T) {
+	centers := []GeoCoordinates{{Lon: -40, Lat: 0}, {Lon: 0, Lat: 20}, {Lon: 40, Lat: -10}}
+	random := rand.New(rand.NewSource(12))
+	var points []GeoPoint
+	for _, center := range centers {
+		for i := 0; i < 200; i++ {
+			points = append(points, &Feature{ID: len(points), Coordinates: GeoCoordinates{
+				Lon: center.Lon + random.NormFloat64(),
+				Lat: center.Lat + random.NormFloat64(),
+			}})
+		}
+	}
+	c := NewCluster(0.001)
+	c.Strategy = StrategyMeanShift
+	c.Bandwidth = 0.02
+	if err := c.ClusterPoints(points); err != nil {
+		t.Fatal(err)
+	}
+	checkAssignments(t, c, len(points))
+
+	//each blob is one cluster around its center, though Epsilon is much smaller than blobs
+	clusters := c.Clusters()
+	if len(clusters) != len(centers) {
+		t.Fatalf("%d clusters of %d blobs", len(clusters), len(centers))
+	}
+	for _, cp := range clusters {
+		found := false
+		for _, center := range centers {
+			if math.Abs(cp.X-center.Lon) < 1 && math.Abs(cp.Y-center.Lat) < 1 {
+				found = true
+			}
+		}
+		if !found || cp.NumPoints < 150 {
+			t.Fatalf("cluster of %d points at %v,%v is not around any blob", cp.NumPoints, cp.X, cp.Y)
+		}
+	}
+}
+
+func TestMeanShiftBandwidth(t *testing.T) {
+	c := NewCluster(0.01)
+	if c.bandwidth() != c.Epsilon {
+		t.Fatalf("bandwidth is %v without Bandwidth, Epsilon is %v", c.bandwidth(), c.Epsilon)
+	}
+	c.Bandwidth = 0.5
+	if c.bandwidth() != 0.5 {
+		t.Fatalf("bandwidth is %v, want Bandwidth", c.bandwidth())
+	}
+}
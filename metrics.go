@@ -0,0 +1,101 @@
+package cluster
+
+import (
+	"expvar"
+	"time"
+)
+
+// Metrics receives measurements of the Cluster, set Cluster.Metrics to monitor long running cluster servers
+// Methods are called synchronously by the Cluster, so they should be cheap and safe for concurrent use.
+// Prometheus collectors could be updated the same way ExpvarMetrics updates its variables.
+type Metrics interface {
+	// ObserveBuild is called after points are clustered by ClusterPoints, ClusterColumns or ReclusterWithEpsilon
+	ObserveBuild(m BuildMetrics)
+	// ObserveQuery is called after each query, name is the name of the method, e.g. "Grid"
+	ObserveQuery(name string, duration time.Duration)
+}
+
+// BuildMetrics are measurements of one clustering
+type BuildMetrics struct {
+	// PointsIngested is the number of new input points, zero when the same points are clustered again
+	PointsIngested int
+	// PointsSkipped is the number of new input points skipped by invalid coordinates, see Cluster.Report
+	PointsSkipped int
+	// Clusters is the number of ResultPoints, including single points
+	Clusters int
+	// Duration of projection, indexing and clustering
+	Duration time.Duration
+	// IndexBytes is approximate memory taken by the spatial index
+	IndexBytes int
+}
+
+func (c *Cluster) observeBuild(pointsIngested int, start time.Time) {
+	if c.Metrics == nil {
+		return
+	}
+	skipped := 0
+	if pointsIngested > 0 {
+		skipped = len(c.report.Skipped)
+	}
+	c.Metrics.ObserveBuild(BuildMetrics{
+		PointsIngested: pointsIngested,
+		PointsSkipped:  skipped,
+		Clusters:       len(c.ResultPoints),
+		Duration:       time.Since(start),
+		IndexBytes:     c.baseIndex.Bytes(),
+	})
+}
+
+// observeQuery reports duration of the query started at start
+func (c *Cluster) observeQuery(name string, start time.Time) {
+	if c.Metrics != nil {
+		c.Metrics.ObserveQuery(name, time.Since(start))
+	}
+}
+
+// ExpvarMetrics is Metrics published with expvar, so they are served by /debug/vars:
+// points_ingested, points_skipped, builds, build_seconds and query counts and durations are totals,
+// clusters, index_bytes and last_build_seconds are values of the last build.
+type ExpvarMetrics struct {
+	vars *expvar.Map
+}
+
+// NewExpvarMetrics publishes metrics as expvar map with the name
+// expvar panics if the name is already published, so it should be called once for each name
+func NewExpvarMetrics(name string) *ExpvarMetrics {
+	return &ExpvarMetrics{vars: expvar.NewMap(name)}
+}
+
+// Vars returns published map of the metrics
+func (m *ExpvarMetrics) Vars() *expvar.Map {
+	return m.vars
+}
+
+// ObserveBuild implements Metrics interface
+func (m *ExpvarMetrics) ObserveBuild(b BuildMetrics) {
+	m.vars.Add("points_ingested", int64(b.PointsIngested))
+	m.vars.Add("points_skipped", int64(b.PointsSkipped))
+	m.vars.Add("builds", 1)
+	m.vars.AddFloat("build_seconds", b.Duration.Seconds())
+	m.setInt("clusters", int64(b.Clusters))
+	m.setInt("index_bytes", int64(b.IndexBytes))
+	m.setFloat("last_build_seconds", b.Duration.Seconds())
+}
+
+// ObserveQuery implements Metrics interface
+func (m *ExpvarMetrics) ObserveQuery(name string, duration time.Duration) {
+	m.vars.Add("queries."+name, 1)
+	m.vars.AddFloat("query_seconds."+name, duration.Seconds())
+}
+
+func (m *ExpvarMetrics) setInt(key string, v int64) {
+	value := new(expvar.Int)
+	value.Set(v)
+	m.vars.Set(key, value)
+}
+
+func (m *ExpvarMetrics) setFloat(key string, v float64) {
+	value := new(expvar.Float)
+	value.Set(v)
+	m.vars.Set(key, value)
+}
@@ -0,0 +1,90 @@
+package cluster
+
+import (
+	"expvar"
+	"math"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingMetrics keeps all observations
+type recordingMetrics struct {
+	mu      sync.Mutex
+	builds  []BuildMetrics
+	queries []string
+}
+
+func (m *recordingMetrics) ObserveBuild(b BuildMetrics) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.builds = append(m.builds, b)
+}
+
+func (m *recordingMetrics) ObserveQuery(name string, duration time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.queries = append(m.queries, name)
+}
+
+func TestMetrics(t *testing.T) {
+	points := randomPoints(500, 13, -30, -30, 30, 30)
+	points = append(points, &Feature{Coordinates: GeoCoordinates{Lon: math.NaN(), Lat: 0}})
+	m := &recordingMetrics{}
+	c, err := NewClusterForZoom(3, 256, 60)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Metrics = m
+	if err := c.ClusterPoints(points); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.ReclusterWithEpsilon(c.Epsilon * 2); err != nil {
+		t.Fatal(err)
+	}
+	if len(m.builds) != 2 {
+		t.Fatalf("%d builds are observed", len(m.builds))
+	}
+	first, second := m.builds[0], m.builds[1]
+	if first.PointsIngested != len(points) || first.PointsSkipped != 1 || first.IndexBytes <= 0 || first.Clusters == 0 {
+		t.Fatalf("first build %+v", first)
+	}
+	//the same points are clustered again
+	if second.PointsIngested != 0 || second.PointsSkipped != 0 || second.Clusters != len(c.ResultPoints) {
+		t.Fatalf("second build %+v", second)
+	}
+
+	c.GetClusters(GeoCoordinates{Lon: -10, Lat: 10}, GeoCoordinates{Lon: 10, Lat: -10})
+	if _, err := c.GridTile(Tile{X: 3, Y: 3, Z: 3}, 256, GridOptions{CellSize: 64}); err != nil {
+		t.Fatal(err)
+	}
+	if len(m.queries) != 2 || m.queries[0] != "GetClusters" || m.queries[1] != "GridTile" {
+		t.Fatalf("queries %v are observed", m.queries)
+	}
+}
+
+func TestExpvarMetrics(t *testing.T) {
+	m := NewExpvarMetrics("gocluster_test")
+	if expvar.Get("gocluster_test") != m.Vars() {
+		t.Fatal("metrics are not published")
+	}
+	m.ObserveBuild(BuildMetrics{PointsIngested: 10, PointsSkipped: 1, Clusters: 4, Duration: time.Second, IndexBytes: 100})
+	m.ObserveBuild(BuildMetrics{PointsIngested: 5, Clusters: 3, Duration: time.Second / 2, IndexBytes: 80})
+	m.ObserveQuery("Grid", time.Second)
+	want := map[string]string{
+		"points_ingested":    "15",
+		"points_skipped":     "1",
+		"builds":             "2",
+		"build_seconds":      "1.5",
+		"clusters":           "3",
+		"index_bytes":        "80",
+		"last_build_seconds": "0.5",
+		"queries.Grid":       "1",
+		"query_seconds.Grid": "1",
+	}
+	for key, value := range want {
+		if v := m.Vars().Get(key); v == nil || v.String() != value {
+			t.Errorf("%s is %v, want %s", key, v, value)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+// Package mongogeo reads MongoDB documents with GeoJSON Point fields, as 2dsphere indexes them, into gocluster GeoPoints.
+//
+// The package has no driver dependency: Cursor is satisfied by *mongo.Cursor of the official driver,
+// documents are decoded into map[string]interface{} and fields are found by dotted paths.
+// bson.M, bson.D and bson.A values of nested documents are walked with reflection, so driver types need no conversion.
+package mongogeo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	cluster "github.com/iahmedov/gocluster"
+)
+
+// Cursor iterates query results, the same as Next/Decode/Err of *mongo.Cursor
+type Cursor interface {
+	Next(ctx context.Context) bool
+	Decode(val interface{}) error
+	Err() error
+}
+
+// ErrUnsupportedGeometry is returned when the location field is not a Point
+var ErrUnsupportedGeometry = errors.New("mongogeo: only Point geometries are supported")
+
+// Options maps document fields to the point, paths are dotted as in Mongo queries, e.g. "address.location"
+// GeometryField - GeoJSON Point field, legacy [lon, lat] pairs are accepted too, "location" by default
+// IDField - id of the point, "_id" by default
+// PropertyFields - fields copied to properties by their path, all top level fields except geometry and id if empty
+// SkipMissing - skip documents without geometry instead of failing
+type Options struct {
+	GeometryField  string
+	IDField        string
+	PropertyFields []string
+	SkipMissing    bool
+}
+
+func (o Options) withDefaults() Options {
+	if o.GeometryField == "" {
+		o.GeometryField = "location"
+	}
+	if o.IDField == "" {
+		o.IDField = "_id"
+	}
+	return o
+}
+
+// Load reads all documents of the cursor into b, returns number of added points
+// Points are *cluster.Feature, the cursor is not closed.
+func Load(ctx context.Context, cursor Cursor, b *cluster.Builder, opts Options) (int, error) {
+	opts = opts.withDefaults()
+	n := 0
+	for i := 0; cursor.Next(ctx); i++ {
+		var doc map[string]interface{}
+		if err := cursor.Decode(&doc); err != nil {
+			return n, fmt.Errorf("mongogeo: document %d: %v", i, err)
+		}
+		f, err := feature(doc, opts)
+		if err == errMissing && opts.SkipMissing {
+			continue
+		}
+		if err != nil {
+			return n, fmt.Errorf("mongogeo: document %d: %v", i, err)
+		}
+		b.AddPoints(f)
+		n++
+	}
+	if err := cursor.Err(); err != nil {
+		return n, fmt.Errorf("mongogeo: %v", err)
+	}
+	return n, nil
+}
+
+// Feature maps decoded document to the point by options
+func Feature(doc map[string]interface{}, opts Options) (*cluster.Feature, error) {
+	opts = opts.withDefaults()
+	f, err := feature(doc, opts)
+	if err == errMissing {
+		return nil, fmt.Errorf("mongogeo: no %q field", opts.GeometryField)
+	}
+	return f, err
+}
+
+var errMissing = errors.New("mongogeo: no geometry field")
+
+func feature(doc map[string]interface{}, opts Options) (*cluster.Feature, error) {
+	geometry, ok := lookup(doc, opts.GeometryField)
+	if !ok || geometry == nil {
+		return nil, errMissing
+	}
+	coordinates, err := pointCoordinates(geometry)
+	if err != nil {
+		return nil, err
+	}
+	id, _ := lookup(doc, opts.IDField)
+	f := &cluster.Feature{ID: id, Coordinates: coordinates, Properties: map[string]interface{}{}}
+	if len(opts.PropertyFields) == 0 {
+		for name, v := range doc {
+			if name != opts.IDField && name != opts.GeometryField && !strings.HasPrefix(opts.GeometryField, name+".") {
+				f.Properties[name] = v
+			}
+		}
+		return f, nil
+	}
+	for _, path := range opts.PropertyFields {
+		if v, ok := lookup(doc, path); ok && v != nil {
+			f.Properties[path] = v
+		}
+	}
+	return f, nil
+}
+
+// pointCoordinates reads GeoJSON Point document or legacy [lon, lat] pair
+func pointCoordinates(v interface{}) (cluster.GeoCoordinates, error) {
+	if t, ok := lookup(v, "type"); ok {
+		if t != "Point" {
+			return cluster.GeoCoordinates{}, ErrUnsupportedGeometry
+		}
+		if v, ok = lookup(v, "coordinates"); !ok {
+			return cluster.GeoCoordinates{}, errors.New("mongogeo: Point has no coordinates")
+		}
+	}
+	lon, okLon := lookup(v, "0")
+	lat, okLat := lookup(v, "1")
+	if !okLon || !okLat {
+		return cluster.GeoCoordinates{}, errors.New("mongogeo: coordinates should be [lon, lat] array")
+	}
+	x, okX := number(lon)
+	y, okY := number(lat)
+	if !okX || !okY {
+		return cluster.GeoCoordinates{}, fmt.Errorf("mongogeo: invalid coordinates [%v, %v]", lon, lat)
+	}
+	return cluster.GeoCoordinates{Lon: x, Lat: y}, nil
+}
+
+// lookup returns value by dotted path, path parts are map keys, Key of bson.D elements or array indexes
+func lookup(v interface{}, path string) (interface{}, bool) {
+	for _, part := range strings.Split(path, ".") {
+		rv := reflect.ValueOf(v)
+		switch rv.Kind() {
+		case reflect.Map:
+			if rv.Type().Key().Kind() != reflect.String {
+				return nil, false
+			}
+			item := rv.MapIndex(reflect.ValueOf(part).Convert(rv.Type().Key()))
+			if !item.IsValid() {
+				return nil, false
+			}
+			v = item.Interface()
+		case reflect.Slice, reflect.Array:
+			found := false
+			if elem := rv.Type().Elem(); elem.Kind() == reflect.Struct {
+				//bson.D is slice of Key/Value structs
+				for i := 0; i < rv.Len() && !found; i++ {
+					e := rv.Index(i)
+					key, value := e.FieldByName("Key"), e.FieldByName("Value")
+					if key.IsValid() && value.IsValid() && key.Kind() == reflect.String && key.String() == part {
+						v, found = value.Interface(), true
+					}
+				}
+			} else if i, err := strconv.Atoi(part); err == nil && i >= 0 && i < rv.Len() {
+				v, found = rv.Index(i).Interface(), true
+			}
+			if !found {
+				return nil, false
+			}
+		default:
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+// number converts numeric BSON values: double, int32 and int64
+func number(v interface{}) (float64, bool) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	}
+	return 0, false
+}
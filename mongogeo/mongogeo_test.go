@@ -0,0 +1,155 @@
+package mongogeo
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	cluster "github.com/iahmedov/gocluster"
+)
+
+// m, d and a have the shapes of bson.M, bson.D and bson.A of the official driver
+type m map[string]interface{}
+
+type e struct {
+	Key   string
+	Value interface{}
+}
+
+type d []e
+
+type a []interface{}
+
+// fakeCursor is Cursor of decoded documents, err is returned by Err after the last one
+type fakeCursor struct {
+	docs      []map[string]interface{}
+	next      int
+	err       error
+	decodeErr int //1-based index of the document which fails to decode
+}
+
+func (c *fakeCursor) Next(ctx context.Context) bool {
+	if c.next >= len(c.docs) {
+		return false
+	}
+	c.next++
+	return true
+}
+
+func (c *fakeCursor) Decode(val interface{}) error {
+	if c.next == c.decodeErr {
+		return errors.New("invalid BSON")
+	}
+	*val.(*map[string]interface{}) = c.docs[c.next-1]
+	return nil
+}
+
+func (c *fakeCursor) Err() error { return c.err }
+
+func newTestBuilder(t *testing.T) *cluster.Builder {
+	t.Helper()
+	template, err := cluster.NewClusterForZoom(4, 256, 40)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cluster.NewBuilder(template)
+}
+
+func TestLoad(t *testing.T) {
+	docs := []map[string]interface{}{
+		{"_id": "a", "location": m{"type": "Point", "coordinates": a{13.4, 52.5}}, "name": "Berlin"},
+		{"_id": "b", "location": d{{"type", "Point"}, {"coordinates", a{2.35, 48.85}}}, "name": "Paris"},
+		{"_id": "c", "location": []interface{}{int32(-74), int64(40)}, "name": "New York"},
+		{"_id": "d", "name": "nowhere"},
+	}
+	cursor := &fakeCursor{docs: docs}
+	b := newTestBuilder(t)
+	n, err := Load(context.Background(), cursor, b, Options{SkipMissing: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 || b.Len() != 3 {
+		t.Fatalf("loaded %d points, builder has %d, want 3", n, b.Len())
+	}
+	index, err := b.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[interface{}]cluster.GeoCoordinates{"a": {Lon: 13.4, Lat: 52.5}, "b": {Lon: 2.35, Lat: 48.85}, "c": {Lon: -74, Lat: 40}}
+	for _, cp := range index.AllClusters() {
+		leaves, err := index.Leaves(cp.Id, 0, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, p := range leaves {
+			f := p.(*cluster.Feature)
+			if f.Coordinates != want[f.ID] || f.Properties["name"] == nil {
+				t.Fatalf("point %v is %+v, want at %v", f.ID, f, want[f.ID])
+			}
+			delete(want, f.ID)
+		}
+	}
+	if len(want) != 0 {
+		t.Fatalf("points %v are not loaded", want)
+	}
+
+	if _, err := Load(context.Background(), &fakeCursor{docs: docs}, newTestBuilder(t), Options{}); err == nil {
+		t.Fatal("expected error of the document without location")
+	}
+	if _, err := Load(context.Background(), &fakeCursor{docs: docs, decodeErr: 2}, newTestBuilder(t), Options{}); err == nil {
+		t.Fatal("expected error of decoding")
+	}
+	n, err = Load(context.Background(), &fakeCursor{docs: docs[:1], err: errors.New("cursor killed")}, newTestBuilder(t), Options{})
+	if err == nil || n != 1 {
+		t.Fatalf("loaded %d points: %v, want error of the cursor after 1 point", n, err)
+	}
+}
+
+func TestFeature(t *testing.T) {
+	doc := map[string]interface{}{
+		"_id":     int64(7),
+		"key":     "shop-7",
+		"address": m{"city": "Berlin", "location": d{{"type", "Point"}, {"coordinates", a{13.4, 52.5}}}},
+		"tags":    a{"bakery", "cafe"},
+		"rating":  4.5,
+	}
+	f, err := Feature(doc, Options{GeometryField: "address.location", IDField: "key"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{"_id": int64(7), "tags": a{"bakery", "cafe"}, "rating": 4.5}
+	if f.ID != "shop-7" || f.Coordinates != (cluster.GeoCoordinates{Lon: 13.4, Lat: 52.5}) {
+		t.Fatalf("feature %v at %v", f.ID, f.Coordinates)
+	}
+	if !reflect.DeepEqual(f.Properties, want) {
+		t.Fatalf("properties %v, want top level fields except id and geometry %v", f.Properties, want)
+	}
+
+	f, err = Feature(doc, Options{GeometryField: "address.location", PropertyFields: []string{"address.city", "tags.1", "missing"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = map[string]interface{}{"address.city": "Berlin", "tags.1": "cafe"}
+	if f.ID != int64(7) || !reflect.DeepEqual(f.Properties, want) {
+		t.Fatalf("feature %v has properties %v, want %v", f.ID, f.Properties, want)
+	}
+}
+
+func TestFeatureInvalidGeometry(t *testing.T) {
+	docs := map[string]map[string]interface{}{
+		"line":           {"location": m{"type": "LineString", "coordinates": a{a{0.0, 0.0}, a{1.0, 1.0}}}},
+		"no coordinates": {"location": m{"type": "Point"}},
+		"short":          {"location": a{1.0}},
+		"text":           {"location": a{"1", "2"}},
+		"missing":        {"name": "nowhere"},
+	}
+	for name, doc := range docs {
+		if _, err := Feature(doc, Options{}); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+	if _, err := Feature(docs["line"], Options{}); err != ErrUnsupportedGeometry {
+		t.Fatalf("LineString error is %v, want ErrUnsupportedGeometry", err)
+	}
+}
@@ -0,0 +1,105 @@
+package cluster
+
+import "sort"
+
+// ClusterMove is the change of the cluster between two clusterings of the same points, e.g. frames of live data
+// Previous and Current are indexes of the cluster in previous and current points, -1 if the cluster appeared or disappeared.
+// Marker of the cluster is animated from From to To: appeared cluster starts at the previous cluster
+// most of its points come from, disappeared one ends at the current cluster most of its points go to.
+// CountDelta is the change of NumPoints.
+type ClusterMove struct {
+	Previous   int
+	Current    int
+	From       GeoCoordinates
+	To         GeoCoordinates
+	CountDelta int
+}
+
+// MatchClusters matches clusters of two clusterings by their common points, as they are returned by AllClusters
+// Points are matched by SourceIDs, so points without GeoPointWithID should keep their indexes between clusterings.
+// Each cluster is matched at most once, pairs sharing more points are matched first.
+// Moves of current clusters are in the order of current points, followed by disappeared clusters.
+func MatchClusters(previous, current []ClusterPoint) []ClusterMove {
+	previousOf := map[interface{}]int{}
+	for i := range previous {
+		for _, id := range previous[i].SourceIDs() {
+			previousOf[id] = i
+		}
+	}
+
+	//number of common points of current and previous clusters
+	type pair struct{ previous, current, common int }
+	var pairs []pair
+	previousMost := make([]int, len(previous)) //current cluster taking most points of previous one
+	previousBest := make([]int, len(previous))
+	currentMost := make([]int, len(current)) //previous cluster giving most points to current one
+	for i := range previousMost {
+		previousMost[i] = -1
+	}
+	for i := range current {
+		common := map[int]int{}
+		for _, id := range current[i].SourceIDs() {
+			if p, ok := previousOf[id]; ok {
+				common[p]++
+			}
+		}
+		currentMost[i] = -1
+		best := 0
+		for p, n := range common {
+			pairs = append(pairs, pair{p, i, n})
+			if n > best || n == best && p < currentMost[i] {
+				best, currentMost[i] = n, p
+			}
+			if n > previousBest[p] || n == previousBest[p] && i < previousMost[p] {
+				previousBest[p], previousMost[p] = n, i
+			}
+		}
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i].common != pairs[j].common {
+			return pairs[i].common > pairs[j].common
+		}
+		if pairs[i].current != pairs[j].current {
+			return pairs[i].current < pairs[j].current
+		}
+		return pairs[i].previous < pairs[j].previous
+	})
+
+	matchedPrevious := make([]bool, len(previous))
+	matchedCurrent := make([]int, len(current))
+	for i := range matchedCurrent {
+		matchedCurrent[i] = -1
+	}
+	for _, p := range pairs {
+		if !matchedPrevious[p.previous] && matchedCurrent[p.current] < 0 {
+			matchedPrevious[p.previous] = true
+			matchedCurrent[p.current] = p.previous
+		}
+	}
+
+	coordinates := func(p *ClusterPoint) GeoCoordinates { return GeoCoordinates{Lon: p.X, Lat: p.Y} }
+	moves := make([]ClusterMove, 0, len(current))
+	for i := range current {
+		c := &current[i]
+		move := ClusterMove{Previous: matchedCurrent[i], Current: i, From: coordinates(c), To: coordinates(c), CountDelta: c.NumPoints}
+		if p := matchedCurrent[i]; p >= 0 {
+			move.From = coordinates(&previous[p])
+			move.CountDelta -= previous[p].NumPoints
+		} else if p := currentMost[i]; p >= 0 {
+			move.From = coordinates(&previous[p])
+		}
+		moves = append(moves, move)
+	}
+	for i := range previous {
+		if matchedPrevious[i] {
+			continue
+		}
+		p := &previous[i]
+		move := ClusterMove{Previous: i, Current: -1, From: coordinates(p), To: coordinates(p), CountDelta: -p.NumPoints}
+		if c := previousMost[i]; c >= 0 {
+			move.To = coordinates(&current[c])
+		}
+		moves = append(moves, move)
+	}
+	return moves
+}
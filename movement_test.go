@@ -0,0 +1,105 @@
+package cluster
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+// group returns cluster point at lon of the input points with ids
+func group(lon float64, ids ...int) ClusterPoint {
+	return ClusterPoint{X: lon, NumPoints: len(ids), memberIDs: ids}
+}
+
+// onEquator returns coordinates of lon at the equator
+func onEquator(lon float64) GeoCoordinates {
+	return GeoCoordinates{Lon: lon}
+}
+
+func TestMatchClusters(t *testing.T) {
+	previous := []ClusterPoint{
+		group(0, 1, 2, 3, 4),
+		group(10, 5, 6),
+		group(20, 7),
+		group(40, 9, 10),
+		group(50, 11),
+		group(52, 12),
+	}
+	current := []ClusterPoint{
+		//loses point 4 to the next one
+		group(1, 1, 2, 3),
+		group(11, 4, 5, 6),
+		//new point
+		group(30, 8),
+		//split into two
+		group(41, 9),
+		group(45, 10),
+		//merge of two
+		group(51, 11, 12),
+	}
+	want := []ClusterMove{
+		{Previous: 0, Current: 0, From: onEquator(0), To: onEquator(1), CountDelta: -1},
+		{Previous: 1, Current: 1, From: onEquator(10), To: onEquator(11), CountDelta: 1},
+		{Previous: -1, Current: 2, From: onEquator(30), To: onEquator(30), CountDelta: 1},
+		{Previous: 3, Current: 3, From: onEquator(40), To: onEquator(41), CountDelta: -1},
+		//appeared cluster starts at the cluster its points come from
+		{Previous: -1, Current: 4, From: onEquator(40), To: onEquator(45), CountDelta: 1},
+		{Previous: 4, Current: 5, From: onEquator(50), To: onEquator(51), CountDelta: 1},
+		//point 7 is removed
+		{Previous: 2, Current: -1, From: onEquator(20), To: onEquator(20), CountDelta: -1},
+		//disappeared cluster ends at the cluster its points go to
+		{Previous: 5, Current: -1, From: onEquator(52), To: onEquator(51), CountDelta: -1},
+	}
+	if moves := MatchClusters(previous, current); !reflect.DeepEqual(moves, want) {
+		t.Fatalf("moves are\n%+v\nwant\n%+v", moves, want)
+	}
+}
+
+func TestMatchClustersOfFrames(t *testing.T) {
+	points := randomPoints(1000, 29, -30, -30, 30, 30)
+	c, err := NewClusterForZoom(3, 256, 60)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.ClusterPoints(points); err != nil {
+		t.Fatal(err)
+	}
+	//the same frame matches itself
+	for i, m := range MatchClusters(c.AllClusters(), c.AllClusters()) {
+		if m.Previous != i || m.Current != i || m.CountDelta != 0 || m.From != m.To {
+			t.Fatalf("move %d of the same frame is %+v", i, m)
+		}
+	}
+}
+
+func TestMatchClustersOfReorderedPoints(t *testing.T) {
+	//separate groups form the same clusters in any input order, their points keep ids but not indexes
+	points := randomPoints(200, 39, -1, -1, 1, 1)
+	for i, p := range points {
+		f := p.(*Feature)
+		f.Coordinates.Lon += float64(i%10)*30 - 150
+	}
+	reversed := make([]GeoPoint, len(points))
+	for i, p := range points {
+		reversed[len(points)-1-i] = p
+	}
+	var frames [2][]ClusterPoint
+	for i, input := range [][]GeoPoint{points, reversed} {
+		c, err := NewClusterForZoom(3, 256, 60)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := c.ClusterPoints(input); err != nil {
+			t.Fatal(err)
+		}
+		frames[i] = c.AllClusters()
+	}
+	if len(frames[0]) != 10 || len(frames[1]) != 10 {
+		t.Fatalf("%d and %d clusters, want 10", len(frames[0]), len(frames[1]))
+	}
+	for _, m := range MatchClusters(frames[0], frames[1]) {
+		if m.Previous < 0 || m.Current < 0 || m.CountDelta != 0 || math.Abs(m.From.Lon-m.To.Lon) > 1e-9 {
+			t.Fatalf("move of the same cluster is %+v", m)
+		}
+	}
+}
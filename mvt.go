@@ -0,0 +1,232 @@
+package cluster
+
+import (
+	"math"
+	"sort"
+)
+
+// MVTExtent is the extent of encoded vector tiles
+const MVTExtent = 4096
+
+// Mapbox Vector Tile protobuf fields
+const (
+	mvtTileLayers = 3
+
+	mvtLayerName     = 1
+	mvtLayerFeatures = 2
+	mvtLayerKeys     = 3
+	mvtLayerValues   = 4
+	mvtLayerExtent   = 5
+	mvtLayerVersion  = 15
+
+	mvtFeatureID       = 1
+	mvtFeatureTags     = 2
+	mvtFeatureType     = 3
+	mvtFeatureGeometry = 4
+
+	mvtValueString = 1
+	mvtValueDouble = 3
+	mvtValueInt    = 4
+	mvtValueUint   = 5
+	mvtValueBool   = 7
+
+	mvtGeomPoint = 1
+	mvtCmdMoveTo = 1
+	mvtVersion   = 2
+)
+
+// EncodeMVT encodes clustered points, as they returned by AllClusters, for the tile
+// to Mapbox Vector Tile with single layer. Points outside of the tile are skipped.
+// Properties are the same as in MarshalGeoJSON, values other than strings, numbers and booleans are skipped.
+func EncodeMVT(points []ClusterPoint, t Tile, layerName string) []byte {
+	return EncodeMVTLayers(points, t, []MVTLayer{{Name: layerName}})
+}
+
+// MVTLayer is the layer of vector tile encoded by EncodeMVTLayers
+// Filter - selects points of the layer, all points if it's nil
+// Properties - names of encoded properties, all properties if it's nil
+// Precision - decimal digits numeric properties are rounded to, so equal rounded values share one value of the layer,
+// they are not rounded if it's 0, coordinates are tile pixels anyway
+type MVTLayer struct {
+	Name       string
+	Filter     func(p *ClusterPoint) bool
+	Properties []string
+	Precision  int
+}
+
+// IsClusterPoint selects clusters of several points, e.g. for Filter of MVTLayer
+func IsClusterPoint(p *ClusterPoint) bool { return p.NumPoints > 1 }
+
+// IsSinglePoint selects points which are not merged with others, e.g. for Filter of MVTLayer
+func IsSinglePoint(p *ClusterPoint) bool { return p.NumPoints <= 1 }
+
+// ClusterLayers returns layers of clusters and single points, like sources of Mapbox GL cluster examples,
+// clusters have only point_count, point_count_abbreviated, cluster and cluster_id properties
+func ClusterLayers(clusters, unclustered string) []MVTLayer {
+	return []MVTLayer{
+		{Name: clusters, Filter: IsClusterPoint, Properties: []string{"cluster", "cluster_id", "point_count", "point_count_abbreviated"}},
+		{Name: unclustered, Filter: IsSinglePoint},
+	}
+}
+
+// EncodeMVTLayers encodes clustered points for the tile to Mapbox Vector Tile with the layers, in their order
+// Point is added to each layer its Filter selects, empty layers are skipped. Properties are the same as in EncodeMVT.
+func EncodeMVTLayers(points []ClusterPoint, t Tile, layers []MVTLayer) []byte {
+	encoded := make([]*mvtLayer, len(layers))
+	for i, l := range layers {
+		encoded[i] = newMVTLayer(l.Name)
+		if l.Precision > 0 {
+			encoded[i].rounding = rounding{e: math.Pow10(l.Precision)}
+		}
+	}
+	for i := range points {
+		p := &points[i]
+		x, y := TilePixel(GeoCoordinates{Lon: p.X, Lat: p.Y}, t, MVTExtent)
+		px, py := int64(math.Floor(x)), int64(math.Floor(y))
+		if px < 0 || py < 0 || px >= MVTExtent || py >= MVTExtent {
+			continue
+		}
+		for j, l := range layers {
+			if l.Filter == nil || l.Filter(p) {
+				encoded[j].addPoint(p, px, py, l.Properties)
+			}
+		}
+	}
+
+	var tile protoBuffer
+	for _, layer := range encoded {
+		if len(layer.features) > 0 {
+			tile.message(mvtTileLayers, layer.encode())
+		}
+	}
+	return tile.buf
+}
+
+type mvtLayer struct {
+	name     string
+	features []*protoBuffer
+	keys     []string
+	keyIdx   map[string]uint32
+	values   []*protoBuffer
+	valueIdx map[interface{}]uint32
+	rounding rounding //of numeric properties
+}
+
+func newMVTLayer(name string) *mvtLayer {
+	return &mvtLayer{
+		name:     name,
+		keyIdx:   map[string]uint32{},
+		valueIdx: map[interface{}]uint32{},
+	}
+}
+
+// addPoint adds feature of the point, only properties of names are encoded if they are not nil
+func (l *mvtLayer) addPoint(p *ClusterPoint, px, py int64, names []string) {
+	properties := l.rounding.properties(ClusterProperties(p))
+	var keys []string
+	if names == nil {
+		keys = make([]string, 0, len(properties))
+		for k := range properties {
+			keys = append(keys, k)
+		}
+	} else {
+		for _, k := range names {
+			if _, ok := properties[k]; ok {
+				keys = append(keys, k)
+			}
+		}
+	}
+	sort.Strings(keys)
+
+	var tags []uint32
+	for _, k := range keys {
+		v, ok := l.value(properties[k])
+		if !ok {
+			continue
+		}
+		tags = append(tags, l.key(k), v)
+	}
+
+	var f protoBuffer
+	if id, ok := clusterFeatureID(p).(int); ok && id >= 0 {
+		f.uint(mvtFeatureID, uint64(id))
+	}
+	if len(tags) > 0 {
+		f.packedUint32(mvtFeatureTags, tags)
+	}
+	f.uint(mvtFeatureType, mvtGeomPoint)
+	f.packedUint32(mvtFeatureGeometry, []uint32{
+		mvtCmdMoveTo&0x7 | 1<<3,
+		uint32(zigzag(px)),
+		uint32(zigzag(py)),
+	})
+	l.features = append(l.features, &f)
+}
+
+func (l *mvtLayer) key(k string) uint32 {
+	if i, ok := l.keyIdx[k]; ok {
+		return i
+	}
+	i := uint32(len(l.keys))
+	l.keys = append(l.keys, k)
+	l.keyIdx[k] = i
+	return i
+}
+
+// value returns index of the value in layer values, unsupported types are skipped
+func (l *mvtLayer) value(v interface{}) (uint32, bool) {
+	var b protoBuffer
+	switch t := v.(type) {
+	case string:
+		b.string(mvtValueString, t)
+	case bool:
+		b.bool(mvtValueBool, t)
+	case float64:
+		b.double(mvtValueDouble, t)
+	case float32:
+		b.double(mvtValueDouble, float64(t))
+		v = float64(t)
+	case int:
+		b.uint(mvtValueInt, uint64(t))
+		v = int64(t)
+	case int32:
+		b.uint(mvtValueInt, uint64(t))
+		v = int64(t)
+	case int64:
+		b.uint(mvtValueInt, uint64(t))
+	case uint:
+		b.uint(mvtValueUint, uint64(t))
+		v = uint64(t)
+	case uint32:
+		b.uint(mvtValueUint, uint64(t))
+		v = uint64(t)
+	case uint64:
+		b.uint(mvtValueUint, t)
+	default:
+		return 0, false
+	}
+	if i, ok := l.valueIdx[v]; ok {
+		return i, true
+	}
+	i := uint32(len(l.values))
+	l.values = append(l.values, &b)
+	l.valueIdx[v] = i
+	return i, true
+}
+
+func (l *mvtLayer) encode() *protoBuffer {
+	var b protoBuffer
+	b.uint(mvtLayerVersion, mvtVersion)
+	b.string(mvtLayerName, l.name)
+	for _, f := range l.features {
+		b.message(mvtLayerFeatures, f)
+	}
+	for _, k := range l.keys {
+		b.string(mvtLayerKeys, k)
+	}
+	for _, v := range l.values {
+		b.message(mvtLayerValues, v)
+	}
+	b.uint(mvtLayerExtent, MVTExtent)
+	return &b
+}
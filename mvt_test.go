@@ -0,0 +1,287 @@
+package cluster
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+)
+
+// protoField is the field of decoded protobuf message, data is set for length delimited fields
+type protoField struct {
+	field int
+	value uint64
+	data  []byte
+}
+
+// decodeProto splits protobuf message into fields, encoders write only varint, fixed64 and bytes fields
+func decodeProto(t testing.TB, data []byte) []protoField {
+	t.Helper()
+	var fields []protoField
+	for len(data) > 0 {
+		key, n := binary.Uvarint(data)
+		if n <= 0 {
+			t.Fatal("broken protobuf field key")
+		}
+		data = data[n:]
+		f := protoField{field: int(key >> 3)}
+		switch key & 7 {
+		case wireVarint:
+			if f.value, n = binary.Uvarint(data); n <= 0 {
+				t.Fatalf("broken varint of field %d", f.field)
+			}
+			data = data[n:]
+		case wireFixed64:
+			if len(data) < 8 {
+				t.Fatalf("truncated fixed64 of field %d", f.field)
+			}
+			f.value, data = binary.LittleEndian.Uint64(data), data[8:]
+		case wireBytes:
+			size, n := binary.Uvarint(data)
+			if n <= 0 || size > uint64(len(data)-n) {
+				t.Fatalf("broken length of field %d", f.field)
+			}
+			f.data, data = data[n:n+int(size)], data[n+int(size):]
+		default:
+			t.Fatalf("unexpected wire type %d of field %d", key&7, f.field)
+		}
+		fields = append(fields, f)
+	}
+	return fields
+}
+
+// decodeVarints returns values of packed varint field
+func decodeVarints(t testing.TB, data []byte) []uint64 {
+	t.Helper()
+	var values []uint64
+	for len(data) > 0 {
+		v, n := binary.Uvarint(data)
+		if n <= 0 {
+			t.Fatal("broken packed varint")
+		}
+		values, data = append(values, v), data[n:]
+	}
+	return values
+}
+
+func unzigzag(v uint64) int64 {
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+type testMVTLayer struct {
+	name            string
+	version, extent uint64
+	keys            []string
+	values          []interface{}
+	features        []testMVTFeature
+}
+
+type testMVTFeature struct {
+	id         uint64
+	hasID      bool
+	geomType   uint64
+	x, y       int64
+	properties map[string]interface{}
+}
+
+// decodeMVT decodes layers of the vector tile of points
+func decodeMVT(t testing.TB, data []byte) []testMVTLayer {
+	t.Helper()
+	var layers []testMVTLayer
+	for _, f := range decodeProto(t, data) {
+		if f.field != mvtTileLayers {
+			t.Fatalf("unexpected tile field %d", f.field)
+		}
+		var l testMVTLayer
+		var features [][]protoField
+		for _, lf := range decodeProto(t, f.data) {
+			switch lf.field {
+			case mvtLayerName:
+				l.name = string(lf.data)
+			case mvtLayerVersion:
+				l.version = lf.value
+			case mvtLayerExtent:
+				l.extent = lf.value
+			case mvtLayerKeys:
+				l.keys = append(l.keys, string(lf.data))
+			case mvtLayerValues:
+				l.values = append(l.values, decodeMVTValue(t, lf.data))
+			case mvtLayerFeatures:
+				features = append(features, decodeProto(t, lf.data))
+			}
+		}
+		//keys and values follow features, so tags are resolved after the whole layer is read
+		for _, fields := range features {
+			l.features = append(l.features, decodeMVTFeature(t, fields, l.keys, l.values))
+		}
+		layers = append(layers, l)
+	}
+	return layers
+}
+
+func decodeMVTValue(t testing.TB, data []byte) interface{} {
+	t.Helper()
+	fields := decodeProto(t, data)
+	if len(fields) != 1 {
+		t.Fatalf("value has %d fields", len(fields))
+	}
+	switch f := fields[0]; f.field {
+	case mvtValueString:
+		return string(f.data)
+	case mvtValueDouble:
+		return math.Float64frombits(f.value)
+	case mvtValueInt:
+		return int64(f.value)
+	case mvtValueUint:
+		return f.value
+	case mvtValueBool:
+		return f.value != 0
+	default:
+		t.Fatalf("unexpected value field %d", f.field)
+	}
+	return nil
+}
+
+func decodeMVTFeature(t testing.TB, fields []protoField, keys []string, values []interface{}) testMVTFeature {
+	t.Helper()
+	feature := testMVTFeature{properties: map[string]interface{}{}}
+	for _, f := range fields {
+		switch f.field {
+		case mvtFeatureID:
+			feature.id, feature.hasID = f.value, true
+		case mvtFeatureType:
+			feature.geomType = f.value
+		case mvtFeatureTags:
+			tags := decodeVarints(t, f.data)
+			if len(tags)%2 != 0 {
+				t.Fatalf("odd number of tags %v", tags)
+			}
+			for i := 0; i < len(tags); i += 2 {
+				if tags[i] >= uint64(len(keys)) || tags[i+1] >= uint64(len(values)) {
+					t.Fatalf("tags %v refer missing keys or values", tags)
+				}
+				feature.properties[keys[tags[i]]] = values[tags[i+1]]
+			}
+		case mvtFeatureGeometry:
+			geometry := decodeVarints(t, f.data)
+			if len(geometry) != 3 || geometry[0] != mvtCmdMoveTo|1<<3 {
+				t.Fatalf("geometry %v is not a single point", geometry)
+			}
+			feature.x, feature.y = unzigzag(geometry[1]), unzigzag(geometry[2])
+		}
+	}
+	return feature
+}
+
+// mvtTestPoints returns result points of random points, all of them are in mvtTestTile
+func mvtTestPoints(t *testing.T) []ClusterPoint {
+	c, err := NewClusterForZoom(3, 256, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.ClusterPoints(randomPoints(300, 7, 1, 1, 40, 40)); err != nil {
+		t.Fatal(err)
+	}
+	return c.AllClusters()
+}
+
+var mvtTestTile = Tile{X: 4, Y: 3, Z: 3}
+
+func TestEncodeMVT(t *testing.T) {
+	points := mvtTestPoints(t)
+	outside := ClusterPoint{X: -100, Y: 10, Id: 1 << 20, NumPoints: 1}
+	tile := EncodeMVT(append(append([]ClusterPoint{}, points...), outside), mvtTestTile, "points")
+
+	layers := decodeMVT(t, tile)
+	if len(layers) != 1 {
+		t.Fatalf("%d layers, want 1", len(layers))
+	}
+	l := layers[0]
+	if l.name != "points" || l.version != mvtVersion || l.extent != MVTExtent {
+		t.Fatalf("layer %q of version %d and extent %d", l.name, l.version, l.extent)
+	}
+	if len(l.features) != len(points) {
+		t.Fatalf("%d features, want %d points of the tile", len(l.features), len(points))
+	}
+	clusters := 0
+	for i, f := range l.features {
+		p := &points[i]
+		x, y := TilePixel(GeoCoordinates{Lon: p.X, Lat: p.Y}, mvtTestTile, MVTExtent)
+		if f.geomType != mvtGeomPoint || f.x != int64(math.Floor(x)) || f.y != int64(math.Floor(y)) {
+			t.Fatalf("feature %d of type %d is at %d,%d, want point at %v,%v", i, f.geomType, f.x, f.y, x, y)
+		}
+		id, _ := clusterFeatureID(p).(int)
+		if !f.hasID || f.id != uint64(id) {
+			t.Fatalf("feature %d has id %d, want %d", i, f.id, id)
+		}
+		if p.NumPoints > 1 {
+			clusters++
+			if f.properties["point_count"] != int64(p.NumPoints) || f.properties["cluster"] != true ||
+				f.properties["cluster_id"] != int64(p.Id) {
+				t.Fatalf("cluster %d has properties %v", p.Id, f.properties)
+			}
+			continue
+		}
+		if f.properties["n"] != float64(id) {
+			t.Fatalf("point %d has properties %v", id, f.properties)
+		}
+	}
+	if clusters == 0 || clusters == len(points) {
+		t.Fatalf("%d clusters of %d points, want both clusters and single points", clusters, len(points))
+	}
+}
+
+func TestEncodeMVTLayers(t *testing.T) {
+	points := mvtTestPoints(t)
+	layers := decodeMVT(t, EncodeMVTLayers(points, mvtTestTile, ClusterLayers("clusters", "unclustered")))
+	if len(layers) != 2 || layers[0].name != "clusters" || layers[1].name != "unclustered" {
+		t.Fatalf("layers %v, want clusters and unclustered", layers)
+	}
+	if len(layers[0].features)+len(layers[1].features) != len(points) {
+		t.Fatalf("%d and %d features, want %d points", len(layers[0].features), len(layers[1].features), len(points))
+	}
+	if len(layers[0].keys) != 4 {
+		t.Fatalf("clusters layer has keys %v, want only cluster properties", layers[0].keys)
+	}
+	for _, f := range layers[0].features {
+		if _, ok := f.properties["point_count_abbreviated"].(string); !ok || f.properties["point_count"].(int64) < 2 {
+			t.Fatalf("cluster feature has properties %v", f.properties)
+		}
+	}
+	for _, f := range layers[1].features {
+		if _, ok := f.properties["n"]; !ok || f.properties["cluster"] != nil {
+			t.Fatalf("single point feature has properties %v", f.properties)
+		}
+	}
+
+	//layers without points are skipped
+	none := func(p *ClusterPoint) bool { return false }
+	layers = decodeMVT(t, EncodeMVTLayers(points, mvtTestTile, []MVTLayer{{Name: "none", Filter: none}, {Name: "all"}}))
+	if len(layers) != 1 || layers[0].name != "all" || len(layers[0].features) != len(points) {
+		t.Fatalf("layers %v, want only all", layers)
+	}
+}
+
+func TestEncodeMVTLayersPrecision(t *testing.T) {
+	points := []ClusterPoint{
+		{X: 10, Y: 10, Id: 0, NumPoints: 1, Properties: map[string]interface{}{"v": 1.04, "name": "a"}},
+		{X: 11, Y: 11, Id: 1, NumPoints: 1, Properties: map[string]interface{}{"v": 0.96, "name": "a"}},
+		{X: 12, Y: 12, Id: 2, NumPoints: 1, Properties: map[string]interface{}{"v": 2.5, "skipped": []int{1}}},
+	}
+	layers := decodeMVT(t, EncodeMVTLayers(points, mvtTestTile, []MVTLayer{{Name: "points", Precision: 1}}))
+	if len(layers) != 1 || len(layers[0].features) != 3 {
+		t.Fatalf("layers %v, want one layer of 3 features", layers)
+	}
+	//rounded values are shared, values of other types are skipped
+	l := layers[0]
+	if len(l.values) != 3 {
+		t.Fatalf("layer has values %v, want 1, \"a\" and 2.5", l.values)
+	}
+	for i, want := range []float64{1, 1, 2.5} {
+		if v := l.features[i].properties["v"]; v != want {
+			t.Fatalf("feature %d has v %v, want %v", i, v, want)
+		}
+	}
+	if _, ok := l.features[2].properties["skipped"]; ok {
+		t.Fatal("slice property is encoded")
+	}
+}
@@ -0,0 +1,265 @@
+package cluster
+
+import (
+	"container/heap"
+	"errors"
+	"math"
+	"sort"
+)
+
+// OPTICSPoint is the element of OPTICS reachability ordering
+// ID is the index of base point, which is the index of input point unless points are deduplicated
+// Reachability and CoreDistance are in projected coordinates, +Inf means undefined
+type OPTICSPoint struct {
+	ID           int
+	Reachability float64
+	CoreDistance float64
+}
+
+// Reachability returns OPTICS ordering built by ClusterPoints with StrategyOPTICS
+func (c *Cluster) Reachability() ([]OPTICSPoint, error) {
+	if c.baseIndex == nil {
+		return nil, notBuilt("Reachability")
+	}
+	if c.opticsOrdering == nil {
+		return nil, errors.New("gocluster: ClusterPoints with StrategyOPTICS should be called before Reachability")
+	}
+	return c.opticsOrdering, nil
+}
+
+// ExtractOPTICS returns clusters for eps, which should not be greater than Epsilon, from the reachability ordering
+// Points are not clustered again, so it's cheap to extract clusters for many density thresholds
+// Points that don't belong to any cluster are returned as single points. ResultPoints are not changed.
+// Ids of clusters continue the ClusterIdxSeed sequence of ResultPoints, the same ones on every call, so extraction
+// changes no state and is safe to call concurrently with other queries; they are not ids of ResultPoints.
+func (c *Cluster) ExtractOPTICS(eps float64) ([]ClusterPoint, error) {
+	defer c.startQuery("ExtractOPTICS")()
+	if c.baseIndex == nil {
+		return nil, notBuilt("ExtractOPTICS")
+	}
+	if c.opticsOrdering == nil {
+		return nil, errors.New("gocluster: ClusterPoints with StrategyOPTICS should be called before ExtractOPTICS")
+	}
+	if eps > c.Epsilon {
+		return nil, errors.New("gocluster: OPTICS eps should not be greater than Epsilon")
+	}
+	//ids are minted from the copy of the sequence, so ids of later clusters don't depend on extractions
+	seq := c.clusterSeq
+	clusters := c.extractOPTICS(c.opticsOrdering, eps, func(members []int) int {
+		id := c.ClusterIdxSeed + seq<<zoomBits + c.Zoom
+		seq++
+		return id
+	})
+	sortByFirstMember(clusters)
+	result := make([]ClusterPoint, len(clusters))
+	for i, cp := range clusters {
+		result[i] = c.resultPoint(cp)
+	}
+	return result, nil
+}
+
+// optics builds reachability ordering of base points with Epsilon as maximum radius
+// MinPoints is counted with weights of deduplicated points, including the point itself
+// With LatitudeCorrection distances are divided by mercator scale at the point, so they are equator distances
+// With GreatCircle distances are great-circle distances
+func (c *Cluster) optics() []OPTICSPoint {
+	n := len(c.basePoints)
+	ordering := make([]OPTICSPoint, 0, n)
+	processed := make([]bool, n)
+	reachability := make([]float64, n)
+	for i := range reachability {
+		reachability[i] = math.Inf(1)
+	}
+
+	var neighbours []int
+	seeds := &opticsQueue{position: map[int]int{}, reachability: reachability}
+	expand := func(id int) {
+		p := c.basePoints[id]
+		scale := c.radiusAt(1, p.Y)
+		neighbours = c.appendNeighboursOf(neighbours[:0], c.baseIndex, c.basePoints, p)
+		processed[id] = true
+		core := c.coreDistance(p, neighbours, scale)
+		ordering = append(ordering, OPTICSPoint{ID: id, Reachability: reachability[id], CoreDistance: core})
+		if math.IsInf(core, 1) {
+			return
+		}
+		for _, o := range neighbours {
+			if processed[o] {
+				continue
+			}
+			q := c.basePoints[o]
+			reach := math.Max(core, c.distance(p, q, scale))
+			if reach < reachability[o] {
+				reachability[o] = reach
+				seeds.update(o)
+			}
+		}
+	}
+
+	for id := range c.basePoints {
+		if processed[id] {
+			continue
+		}
+		expand(id)
+		for seeds.Len() > 0 {
+			expand(heap.Pop(seeds).(int))
+		}
+	}
+	return ordering
+}
+
+// coreDistance returns the distance at which neighbourhood of p has MinPoints weight, or +Inf
+// distances are divided by scale
+func (c *Cluster) coreDistance(p *ClusterPoint, neighbours []int, scale float64) float64 {
+	type neighbour struct {
+		dist   float64
+		weight int
+	}
+	total := 0
+	list := make([]neighbour, len(neighbours))
+	for i, id := range neighbours {
+		q := c.basePoints[id]
+		list[i] = neighbour{dist: c.distance(p, q, scale), weight: q.NumPoints}
+		total += q.NumPoints
+	}
+	if total < c.MinPoints || len(list) == 0 {
+		return math.Inf(1)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].dist < list[j].dist })
+	weight := 0
+	for _, nb := range list {
+		weight += nb.weight
+		if weight >= c.MinPoints {
+			return nb.dist
+		}
+	}
+	return list[len(list)-1].dist
+}
+
+// BorderPolicy defines which cluster border points of density based clustering join, see Cluster.BorderPolicy
+// Border points are not core points, but they are within eps of core points of one or more clusters.
+type BorderPolicy int
+
+const (
+	// BorderFirstReached puts border point into the cluster which reaches it first in the reachability ordering,
+	// so it depends on the order clusters are expanded in
+	BorderFirstReached BorderPolicy = iota
+	// BorderNearestCore puts border point into the cluster of the nearest core point within eps
+	BorderNearestCore
+)
+
+// extractOPTICS makes DBSCAN-like clusters of the ordering for eps, nextID mints ids of clusters of several points
+// Border points join clusters by BorderPolicy.
+func (c *Cluster) extractOPTICS(ordering []OPTICSPoint, eps float64, nextID func(members []int) int) []*ClusterPoint {
+	//group of each base point in the ordering, -1 for noise
+	groupOf := make([]int, len(c.basePoints))
+	groups, open := 0, false
+	for _, o := range ordering {
+		if o.Reachability > eps {
+			open = false
+			if o.CoreDistance > eps {
+				groupOf[o.ID] = -1
+				continue
+			}
+		}
+		if !open {
+			groups, open = groups+1, true
+		}
+		groupOf[o.ID] = groups - 1
+	}
+	if c.BorderPolicy == BorderNearestCore {
+		c.nearestCoreBorders(ordering, groupOf, eps)
+	}
+
+	members := make([][]*ClusterPoint, groups)
+	var result []*ClusterPoint
+	for _, o := range ordering {
+		p := c.basePoints[o.ID]
+		if g := groupOf[o.ID]; g >= 0 {
+			members[g] = append(members[g], p)
+		} else {
+			//noise
+			result = append(result, p)
+		}
+	}
+	for _, group := range members {
+		switch len(group) {
+		case 0:
+		case 1:
+			result = append(result, group[0])
+		default:
+			cp := c.mergePoints(group[0], group[1:])
+			cp.Id = nextID(cp.memberIDs)
+			result = append(result, cp)
+		}
+	}
+	return result
+}
+
+// nearestCoreBorders moves border points of groups to the group of the nearest core point within eps
+// Distances are scaled at the core point, as reachability is.
+func (c *Cluster) nearestCoreBorders(ordering []OPTICSPoint, groupOf []int, eps float64) {
+	core := make([]bool, len(c.basePoints))
+	for _, o := range ordering {
+		core[o.ID] = o.CoreDistance <= eps
+	}
+	var neighbours []int
+	for _, o := range ordering {
+		if core[o.ID] || groupOf[o.ID] < 0 {
+			continue
+		}
+		p := c.basePoints[o.ID]
+		best := math.Inf(1)
+		neighbours = c.appendNeighboursOf(neighbours[:0], c.baseIndex, c.basePoints, p)
+		for _, id := range neighbours {
+			if !core[id] {
+				continue
+			}
+			q := c.basePoints[id]
+			if d := c.distance(q, p, c.radiusAt(1, q.Y)); d <= eps && d < best {
+				best = d
+				groupOf[o.ID] = groupOf[id]
+			}
+		}
+	}
+}
+
+// opticsQueue is min heap of point ids by reachability, with position tracking for decrease-key
+type opticsQueue struct {
+	ids          []int
+	position     map[int]int
+	reachability []float64
+}
+
+func (q *opticsQueue) update(id int) {
+	if i, ok := q.position[id]; ok {
+		heap.Fix(q, i)
+		return
+	}
+	heap.Push(q, id)
+}
+
+func (q *opticsQueue) Len() int { return len(q.ids) }
+func (q *opticsQueue) Less(i, j int) bool {
+	ri, rj := q.reachability[q.ids[i]], q.reachability[q.ids[j]]
+	if ri == rj {
+		return q.ids[i] < q.ids[j]
+	}
+	return ri < rj
+}
+func (q *opticsQueue) Swap(i, j int) {
+	q.ids[i], q.ids[j] = q.ids[j], q.ids[i]
+	q.position[q.ids[i]] = i
+	q.position[q.ids[j]] = j
+}
+func (q *opticsQueue) Push(x interface{}) {
+	id := x.(int)
+	q.position[id] = len(q.ids)
+	q.ids = append(q.ids, id)
+}
+func (q *opticsQueue) Pop() interface{} {
+	id := q.ids[len(q.ids)-1]
+	q.ids = q.ids[:len(q.ids)-1]
+	delete(q.position, id)
+	return id
+}
@@ -0,0 +1,87 @@
+package cluster
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestExtractOPTICSConcurrent(t *testing.T) {
+	template, err := NewClusterForZoom(3, 256, 60)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template.Strategy = StrategyOPTICS
+	b := NewBuilder(template)
+	b.AddPoints(loadPlaces(t)...)
+	index, err := b.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	seq := index.c.clusterSeq
+
+	want, err := index.ExtractOPTICS(template.Epsilon / 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	results := make([][]ClusterPoint, 4)
+	for g := range results {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 20; i++ {
+				result, err := index.ExtractOPTICS(template.Epsilon / 2)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				results[g] = result
+			}
+		}(g)
+	}
+	wg.Wait()
+	for g, result := range results {
+		if !reflect.DeepEqual(result, want) {
+			t.Errorf("goroutine %d extracted different clusters", g)
+		}
+	}
+	if index.c.clusterSeq != seq {
+		t.Errorf("extraction advanced cluster sequence from %d to %d", seq, index.c.clusterSeq)
+	}
+	if total := totalPoints(want); total != len(index.c.assignment) {
+		t.Errorf("extracted clusters have %d points, want %d", total, len(index.c.assignment))
+	}
+}
+
+func TestBorderPolicy(t *testing.T) {
+	//border point 4 is reached from cluster of points 0-3 first, but it's closer to core point 5
+	var points []GeoPoint
+	for _, lon := range []float64{-0.3, -0.2, -0.1, 0.1, 1, 1.85, 2.05, 2.1, 2.15} {
+		points = append(points, &Feature{Coordinates: onEquator(lon)})
+	}
+	borderOf := func(policy BorderPolicy) (border, first, last int) {
+		c := NewCluster(1.0 / 360)
+		c.Strategy = StrategyOPTICS
+		c.MinPoints = 4
+		c.BorderPolicy = policy
+		if err := c.ClusterPoints(points); err != nil {
+			t.Fatal(err)
+		}
+		checkAssignments(t, c, len(points))
+		if len(c.ResultPoints) != 2 {
+			t.Fatalf("%d result points of border policy %d, want 2", len(c.ResultPoints), policy)
+		}
+		restored := roundTrip(t, c)
+		if restored.BorderPolicy != policy {
+			t.Fatalf("restored cluster has border policy %d, want %d", restored.BorderPolicy, policy)
+		}
+		return c.assignment[4], c.assignment[3], c.assignment[5]
+	}
+	if border, first, _ := borderOf(BorderFirstReached); border != first {
+		t.Fatal("border point is not in the cluster reaching it first")
+	}
+	if border, _, last := borderOf(BorderNearestCore); border != last {
+		t.Fatal("border point is not in the cluster of the nearest core point")
+	}
+}
@@ -0,0 +1,42 @@
+package cluster
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCheckOptions(t *testing.T) {
+	options := map[string]func(c *Cluster){
+		"zero NodeSize":     func(c *Cluster) { c.NodeSize = 0 },
+		"negative NodeSize": func(c *Cluster) { c.NodeSize = -4 },
+		"negative Epsilon":  func(c *Cluster) { c.Epsilon = -0.1 },
+		"NaN Epsilon":       func(c *Cluster) { c.Epsilon = math.NaN() },
+		"NaN Bandwidth":     func(c *Cluster) { c.Bandwidth = math.NaN() },
+		"negative Capacity": func(c *Cluster) { c.Strategy, c.Capacity = StrategyBalanced, -1 },
+	}
+	points := loadPlaces(t)
+	for name, set := range options {
+		c, err := NewClusterForZoom(3, 256, 40)
+		if err != nil {
+			t.Fatal(err)
+		}
+		set(c)
+		if err := c.ClusterPoints(points); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+		if c.Built() {
+			t.Errorf("%s: points are clustered", name)
+		}
+	}
+
+	c, err := NewClusterForZoom(3, 256, 40)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.ClusterPoints(points); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.ReclusterWithEpsilon(math.Inf(1)); err == nil {
+		t.Error("ReclusterWithEpsilon accepted infinite epsilon")
+	}
+}
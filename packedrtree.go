@@ -0,0 +1,217 @@
+package cluster
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+)
+
+// packedRTreeNodeSize is the node size of FlatGeobuf index written by WriteFlatGeobuf
+const packedRTreeNodeSize = 16
+
+// packedRTreeItemSize is the size of encoded node: 4 doubles of bbox and uint64 offset
+const packedRTreeItemSize = 40
+
+// rtreeNode is the node of packed Hilbert R-tree, as FlatGeobuf stores it
+// offset is byte offset of the feature for leaves and index of the first child node for others
+type rtreeNode struct {
+	minX, minY, maxX, maxY float64
+	offset                 uint64
+}
+
+func (n *rtreeNode) expand(o *rtreeNode) {
+	n.minX, n.minY = math.Min(n.minX, o.minX), math.Min(n.minY, o.minY)
+	n.maxX, n.maxY = math.Max(n.maxX, o.maxX), math.Max(n.maxY, o.maxY)
+}
+
+func (n *rtreeNode) intersects(minX, minY, maxX, maxY float64) bool {
+	return n.maxX >= minX && n.maxY >= minY && n.minX <= maxX && n.minY <= maxY
+}
+
+func emptyRTreeNode() rtreeNode {
+	return rtreeNode{minX: math.Inf(1), minY: math.Inf(1), maxX: math.Inf(-1), maxY: math.Inf(-1)}
+}
+
+// rtreeLevels returns [start, end) node indexes of each level, from leaves to root
+// Root is the first node and leaves are the last ones, the same layout as FlatGeobuf packedrtree.cpp
+func rtreeLevels(numItems uint64, nodeSize int) [][2]uint64 {
+	counts := []uint64{numItems}
+	n := numItems
+	numNodes := n
+	for {
+		n = (n + uint64(nodeSize) - 1) / uint64(nodeSize)
+		numNodes += n
+		counts = append(counts, n)
+		if n == 1 {
+			break
+		}
+	}
+	levels := make([][2]uint64, len(counts))
+	end := numNodes
+	for i, count := range counts {
+		levels[i] = [2]uint64{end - count, end}
+		end -= count
+	}
+	return levels
+}
+
+// rtreeSize returns the size of encoded index in bytes
+func rtreeSize(numItems uint64, nodeSize int) uint64 {
+	if numItems == 0 || nodeSize < 2 {
+		return 0
+	}
+	levels := rtreeLevels(numItems, nodeSize)
+	return levels[0][1] * packedRTreeItemSize
+}
+
+// buildRTree returns all nodes of the tree with leaves, which should be sorted already
+func buildRTree(leaves []rtreeNode, nodeSize int) []rtreeNode {
+	levels := rtreeLevels(uint64(len(leaves)), nodeSize)
+	nodes := make([]rtreeNode, levels[0][1])
+	copy(nodes[levels[0][0]:], leaves)
+	for i := 0; i < len(levels)-1; i++ {
+		child := levels[i][0]
+		for parent := levels[i+1][0]; parent < levels[i+1][1]; parent++ {
+			node := emptyRTreeNode()
+			node.offset = child
+			for k := 0; k < nodeSize && child < levels[i][1]; k++ {
+				node.expand(&nodes[child])
+				child++
+			}
+			nodes[parent] = node
+		}
+	}
+	return nodes
+}
+
+func writeRTree(w io.Writer, nodes []rtreeNode) error {
+	buf := make([]byte, packedRTreeItemSize)
+	for _, n := range nodes {
+		binary.LittleEndian.PutUint64(buf[0:], math.Float64bits(n.minX))
+		binary.LittleEndian.PutUint64(buf[8:], math.Float64bits(n.minY))
+		binary.LittleEndian.PutUint64(buf[16:], math.Float64bits(n.maxX))
+		binary.LittleEndian.PutUint64(buf[24:], math.Float64bits(n.maxY))
+		binary.LittleEndian.PutUint64(buf[32:], n.offset)
+		if _, err := w.Write(buf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rtreeMatch is the leaf found by searchRTree, index is the position of the feature in the file
+type rtreeMatch struct {
+	index  uint64
+	offset uint64
+}
+
+// searchRTree finds leaves intersecting the box, only visited nodes are read from r, which is positioned at index start
+// Matches are sorted by offset, so features could be read sequentially.
+// Offsets of interior nodes are read from the file, children outside of the next level are errors of broken index.
+func searchRTree(r io.ReadSeeker, numItems uint64, nodeSize int, minX, minY, maxX, maxY float64) ([]rtreeMatch, error) {
+	start, err := r.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, err
+	}
+	levels := rtreeLevels(numItems, nodeSize)
+	leaves := levels[0][0]
+
+	type queued struct {
+		node  uint64
+		level int
+	}
+	queue := []queued{{node: 0, level: len(levels) - 1}}
+	buf := make([]byte, nodeSize*packedRTreeItemSize)
+	var result []rtreeMatch
+	for len(queue) > 0 {
+		q := queue[0]
+		queue = queue[1:]
+		end := q.node + uint64(nodeSize)
+		if levelEnd := levels[q.level][1]; end > levelEnd {
+			end = levelEnd
+		}
+		if end <= q.node {
+			return nil, fmt.Errorf("node %d is out of index level %d", q.node, q.level)
+		}
+		if _, err := r.Seek(start+int64(q.node*packedRTreeItemSize), io.SeekStart); err != nil {
+			return nil, err
+		}
+		data := buf[:(end-q.node)*packedRTreeItemSize]
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, err
+		}
+		for i := q.node; i < end; i++ {
+			b := data[(i-q.node)*packedRTreeItemSize:]
+			n := rtreeNode{
+				minX:   math.Float64frombits(binary.LittleEndian.Uint64(b[0:])),
+				minY:   math.Float64frombits(binary.LittleEndian.Uint64(b[8:])),
+				maxX:   math.Float64frombits(binary.LittleEndian.Uint64(b[16:])),
+				maxY:   math.Float64frombits(binary.LittleEndian.Uint64(b[24:])),
+				offset: binary.LittleEndian.Uint64(b[32:]),
+			}
+			if !n.intersects(minX, minY, maxX, maxY) {
+				continue
+			}
+			if i >= leaves {
+				result = append(result, rtreeMatch{index: i - leaves, offset: n.offset})
+			} else {
+				if child := levels[q.level-1]; n.offset < child[0] || n.offset >= child[1] {
+					return nil, fmt.Errorf("child %d of node %d is out of index level %d", n.offset, i, q.level-1)
+				}
+				queue = append(queue, queued{node: n.offset, level: q.level - 1})
+			}
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].offset < result[j].offset })
+	return result, nil
+}
+
+// hilbert returns position of x, y on Hilbert curve of 16 bits order
+// The same function as FlatGeobuf uses, from https://github.com/rawrunprotected/hilbert_curves
+func hilbert(x, y uint32) uint32 {
+	a := x ^ y
+	b := 0xFFFF ^ a
+	c := 0xFFFF ^ (x | y)
+	d := x & (y ^ 0xFFFF)
+
+	A := a | (b >> 1)
+	B := (a >> 1) ^ a
+	C := ((c >> 1) ^ (b & (d >> 1))) ^ c
+	D := ((a & (c >> 1)) ^ (d >> 1)) ^ d
+
+	a, b, c, d = A, B, C, D
+	A = (a & (a >> 2)) ^ (b & (b >> 2))
+	B = (a & (b >> 2)) ^ (b & ((a ^ b) >> 2))
+	C ^= (a & (c >> 2)) ^ (b & (d >> 2))
+	D ^= (b & (c >> 2)) ^ ((a ^ b) & (d >> 2))
+
+	a, b, c, d = A, B, C, D
+	A = (a & (a >> 4)) ^ (b & (b >> 4))
+	B = (a & (b >> 4)) ^ (b & ((a ^ b) >> 4))
+	C ^= (a & (c >> 4)) ^ (b & (d >> 4))
+	D ^= (b & (c >> 4)) ^ ((a ^ b) & (d >> 4))
+
+	a, b, c, d = A, B, C, D
+	C ^= (a & (c >> 8)) ^ (b & (d >> 8))
+	D ^= (b & (c >> 8)) ^ ((a ^ b) & (d >> 8))
+
+	a = C ^ (C >> 1)
+	b = D ^ (D >> 1)
+
+	i0 := x ^ y
+	i1 := b | (0xFFFF ^ (i0 | a))
+	i0 = interleave16(i0)
+	i1 = interleave16(i1)
+	return (i1 << 1) | i0
+}
+
+// interleave16 spreads 16 bits of v to even bits
+func interleave16(v uint32) uint32 {
+	v = (v | (v << 8)) & 0x00FF00FF
+	v = (v | (v << 4)) & 0x0F0F0F0F
+	v = (v | (v << 2)) & 0x33333333
+	v = (v | (v << 1)) & 0x55555555
+	return v
+}
@@ -0,0 +1,340 @@
+package cluster
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// prefetchBlock is the number of seeds a worker finds neighbours for at once
+const prefetchBlock = 64
+
+// prefetchAhead is the number of blocks each worker could be ahead of the clustering loop
+const prefetchAhead = 2
+
+// prefetchProbe is the number of seeds neighbours are found for before prefetch is checked to pay off
+const prefetchProbe = 8 * prefetchBlock
+
+// stripPoints is the least number of points of strips Workers cluster concurrently, see clusterizeStrips
+const stripPoints = 1 << 14
+
+// maxStrips limits the number of strips, so few points are near their borders
+const maxStrips = 256
+
+// stripBins is the number of bins of the histogram of x strip borders are taken from
+const stripBins = 4096
+
+// parallel calls fn for blocks of [0..n) range in Workers goroutines, or once in the caller one
+func (c *Cluster) parallel(n int, fn func(start, end int)) {
+	if c.Workers <= 1 || n < 2*prefetchBlock {
+		fn(0, n)
+		return
+	}
+	var next uint32
+	var wg sync.WaitGroup
+	for w := 0; w < c.Workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				start := int(atomic.AddUint32(&next, 1)-1) * prefetchBlock
+				if start >= n {
+					return
+				}
+				fn(start, minInt(start+prefetchBlock, n))
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// neighbourPrefetch finds neighbours of seeds in Workers goroutines ahead of the clustering loop, which only merges them
+// Neighbours don't depend on clustering state, so clusters are the same as found by one goroutine.
+// Seeds already visited by the loop are skipped by workers, the loop marks visited points with visit.
+// Most seeds of dense points are visited only after workers found their neighbours, so prefetch is turned off
+// when less than 1/Workers of found neighbours are used, and the loop finds them itself.
+type neighbourPrefetch struct {
+	c       *Cluster
+	seeds   []*ClusterPoint
+	order   []int //index in points of each seed, nil if seeds are points
+	points  []*ClusterPoint
+	index   spatialIndex
+	visited []uint32 //visited flags of points by index
+
+	blocks  []blockNeighbours
+	next    uint32        //the next block taken by workers
+	off     uint32        //set by the loop when prefetch doesn't pay off
+	current int           //the block read by the loop
+	tokens  chan struct{} //blocks taken by workers and not read by the loop yet
+	quit    chan struct{}
+	wg      sync.WaitGroup
+
+	found       int //seeds of read blocks neighbours were found for
+	used        int //seeds of read blocks the loop used neighbours of
+	usedCurrent int //seeds of the current block the loop used neighbours of
+}
+
+type blockNeighbours struct {
+	ready      chan struct{}
+	neighbours [][]int
+	found      int
+}
+
+// prefetchNeighbours starts Workers finding neighbours of seeds, it's nil if Workers is not set or there are few seeds
+func (c *Cluster) prefetchNeighbours(seeds []*ClusterPoint, order []int, points []*ClusterPoint, index spatialIndex) *neighbourPrefetch {
+	if c.Workers <= 1 || len(seeds) < 2*prefetchProbe {
+		return nil
+	}
+	p := &neighbourPrefetch{
+		c:       c,
+		seeds:   seeds,
+		order:   order,
+		points:  points,
+		index:   index,
+		visited: make([]uint32, len(points)),
+		blocks:  make([]blockNeighbours, (len(seeds)+prefetchBlock-1)/prefetchBlock),
+		tokens:  make(chan struct{}, c.Workers*prefetchAhead),
+		quit:    make(chan struct{}),
+	}
+	for i, q := range points {
+		if q.visited {
+			p.visited[i] = 1
+		}
+	}
+	for i := range p.blocks {
+		p.blocks[i].ready = make(chan struct{})
+	}
+	for w := 0; w < c.Workers; w++ {
+		p.wg.Add(1)
+		go p.work()
+	}
+	return p
+}
+
+func (p *neighbourPrefetch) work() {
+	defer p.wg.Done()
+	for {
+		select {
+		case p.tokens <- struct{}{}:
+		case <-p.quit:
+			return
+		}
+		b := int(atomic.AddUint32(&p.next, 1) - 1)
+		if b >= len(p.blocks) || atomic.LoadUint32(&p.off) != 0 {
+			return
+		}
+		start := b * prefetchBlock
+		end := minInt(start+prefetchBlock, len(p.seeds))
+		neighbours := make([][]int, end-start)
+		found := 0
+		for i := start; i < end; i++ {
+			if atomic.LoadUint32(&p.visited[p.pointOf(i)]) != 0 {
+				continue
+			}
+			neighbours[i-start] = p.c.appendNeighboursOf(nil, p.index, p.points, p.seeds[i])
+			sort.Ints(neighbours[i-start])
+			found++
+		}
+		p.blocks[b].neighbours, p.blocks[b].found = neighbours, found
+		close(p.blocks[b].ready)
+	}
+}
+
+// pointOf returns index in points of the seed
+func (p *neighbourPrefetch) pointOf(seed int) int {
+	if p.order == nil {
+		return seed
+	}
+	return p.order[seed]
+}
+
+// visit marks point with index in points as visited, so workers skip it
+func (p *neighbourPrefetch) visit(i int) {
+	atomic.StoreUint32(&p.visited[i], 1)
+}
+
+// neighbours returns neighbours of the seed, seeds should be read in increasing order
+func (p *neighbourPrefetch) neighbours(seed int) []int {
+	b := seed / prefetchBlock
+	for ; p.current < b; p.current++ {
+		p.release(p.current)
+	}
+	//off is only set by the loop, so the block is ready unless it's set
+	if atomic.LoadUint32(&p.off) == 0 {
+		<-p.blocks[b].ready
+		if found := p.blocks[b].neighbours[seed-b*prefetchBlock]; found != nil {
+			p.usedCurrent++
+			return found
+		}
+	}
+	//prefetch is off, the seed was visited when the worker got to it, or it has no neighbours at all
+	found := p.c.appendNeighboursOf(nil, p.index, p.points, p.seeds[seed])
+	sort.Ints(found)
+	return found
+}
+
+// release frees the read block, so workers take next blocks, and turns prefetch off if it doesn't pay off
+func (p *neighbourPrefetch) release(b int) {
+	if atomic.LoadUint32(&p.off) != 0 {
+		return
+	}
+	<-p.blocks[b].ready
+	p.found += p.blocks[b].found
+	p.used += p.usedCurrent
+	p.usedCurrent = 0
+	p.blocks[b].neighbours = nil
+	<-p.tokens
+	if p.found >= prefetchProbe && p.used*p.c.Workers < p.found {
+		atomic.StoreUint32(&p.off, 1)
+	}
+}
+
+// stop stops workers, it's safe to call for nil
+func (p *neighbourPrefetch) stop() {
+	if p == nil {
+		return
+	}
+	close(p.quit)
+	p.wg.Wait()
+}
+
+// stripBounds returns borders of strips of points by projected x for clusterizeStrips, from -Inf to +Inf,
+// or nil if Workers is not set or there are too few points for two strips.
+// Strips have about the same number of points and are wider than 4 neighbour radii, so most seeds are inside of them.
+// Borders depend on points and options only, not on Workers, so clusters are the same for any number of workers.
+func (c *Cluster) stripBounds(points []*ClusterPoint) []float64 {
+	if c.Workers <= 1 || len(points) < 2*stripPoints {
+		return nil
+	}
+	minX, maxX := math.Inf(1), math.Inf(-1)
+	minY, maxY := math.Inf(1), math.Inf(-1)
+	for _, p := range points {
+		minX, maxX = math.Min(minX, p.X), math.Max(maxX, p.X)
+		minY, maxY = math.Min(minY, p.Y), math.Max(maxY, p.Y)
+	}
+	binWidth := (maxX - minX) / stripBins
+	if !(binWidth > 0) || math.IsInf(binWidth, 0) {
+		return nil
+	}
+	var histogram [stripBins]int
+	for _, p := range points {
+		histogram[minInt(int((p.X-minX)/binWidth), stripBins-1)]++
+	}
+
+	target := maxInt(stripPoints, len(points)/maxStrips)
+	minWidth := 4 * c.neighbourRadius((minY+maxY)/2)
+	bounds := []float64{math.Inf(-1)}
+	last, count := minX, 0
+	for b, n := range histogram[:stripBins-1] {
+		count += n
+		x := minX + float64(b+1)*binWidth
+		if count >= target && x-last >= minWidth && len(points)-count >= stripPoints/2 {
+			bounds = append(bounds, x)
+			last, count = x, 0
+		}
+	}
+	if len(bounds) < 2 {
+		return nil
+	}
+	return append(bounds, math.Inf(1))
+}
+
+// clusterizeStrips clusters seeds of StrategyGreedy in strips of points between bounds by Workers concurrently,
+// order is index in points of each seed, nil if seeds are points.
+// Seeds are taken in their order within each strip, a seed is clustered in the first phase only if all its neighbours
+// are in its strip, so strips share no points and their clusters are independent. Seeds near strip borders and
+// the ones whose neighbours cross them are taken in the second phase by one goroutine in the order of all seeds,
+// they merge border points left by both strips. The result is in the order of first members, and ids of clusters
+// of the first phase are given after the second one in this order, so they don't depend on scheduling.
+func (c *Cluster) clusterizeStrips(seeds []*ClusterPoint, order []int, points []*ClusterPoint, index spatialIndex, bounds []float64) []*ClusterPoint {
+	stripOf := make([]int32, len(points))
+	c.parallel(len(points), func(start, end int) {
+		for i := start; i < end; i++ {
+			stripOf[i] = int32(sort.SearchFloat64s(bounds, points[i].X) - 1)
+			if points[i].X == bounds[stripOf[i]+1] {
+				//bounds are inclusive at the left
+				stripOf[i]++
+			}
+		}
+	})
+	strips := make([][]int, len(bounds)-1)
+	for pi := range seeds {
+		point := pi
+		if order != nil {
+			point = order[pi]
+		}
+		strips[stripOf[point]] = append(strips[stripOf[point]], pi)
+	}
+
+	done := make([]bool, len(points))
+	results := make([][]*ClusterPoint, len(strips))
+	var next uint32
+	var wg sync.WaitGroup
+	for w := 0; w < minInt(c.Workers, len(strips)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var neighbours []int
+			var found []*ClusterPoint
+			for {
+				k := int(atomic.AddUint32(&next, 1) - 1)
+				if k >= len(strips) {
+					return
+				}
+				minX, maxX := bounds[k], bounds[k+1]
+				for _, pi := range strips[k] {
+					p := seeds[pi]
+					if p.visited {
+						continue
+					}
+					if r := c.neighbourRadius(p.Y); p.X-r <= minX || p.X+r >= maxX {
+						continue
+					}
+					neighbours = c.appendNeighboursOf(neighbours[:0], index, points, p)
+					inside := true
+					for _, id := range neighbours {
+						if stripOf[id] != int32(k) {
+							inside = false
+							break
+						}
+					}
+					if !inside {
+						continue
+					}
+					sort.Ints(neighbours)
+					p.visited = true
+					found, nPoints := absorb(p, neighbours, points, done, found[:0], nil)
+					switch {
+					case len(found) > 0 && nPoints < c.MinPoints:
+						results[k] = append(results[k], p)
+						results[k] = append(results[k], found...)
+					case len(found) > 0:
+						//ids are given after the second phase, nextClusterID is not safe for concurrent use
+						cluster := c.mergePoints(p, found)
+						cluster.Id = -1
+						results[k] = append(results[k], cluster)
+					default:
+						results[k] = append(results[k], p)
+					}
+				}
+				for i := range found {
+					found[i] = nil
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	result := c.clusterizeSeeds(seeds, points, index, nil, nil)
+	for k := range strips {
+		result = append(result, results[k]...)
+	}
+	sortByFirstMember(result)
+	for _, cp := range result {
+		if cp.Id == -1 {
+			cp.Id = c.nextClusterID(cp.memberIDs)
+		}
+	}
+	return result
+}
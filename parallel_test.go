@@ -0,0 +1,94 @@
+package cluster
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestWorkersSameAsSequential(t *testing.T) {
+	//too few points for strips, workers only prefetch neighbours
+	points := randomPoints(20000, 5, -60, -60, 60, 60)
+	for _, order := range []SeedOrder{SeedInput, SeedHilbert, SeedDensity} {
+		var results [2][]ClusterPoint
+		for i, workers := range []int{1, 4} {
+			c, err := NewClusterForZoom(5, 256, 20)
+			if err != nil {
+				t.Fatal(err)
+			}
+			c.SeedOrder = order
+			c.Workers = workers
+			if err := c.ClusterPoints(points); err != nil {
+				t.Fatal(err)
+			}
+			checkAssignments(t, c, len(points))
+			results[i] = c.ResultPoints
+		}
+		sequential, parallel := results[0], results[1]
+		if len(parallel) != len(sequential) {
+			t.Fatalf("seed order %d: %d result points with workers, want %d", order, len(parallel), len(sequential))
+		}
+		for i := range sequential {
+			want, got := &sequential[i], &parallel[i]
+			if got.Id != want.Id || got.X != want.X || got.Y != want.Y || !reflect.DeepEqual(got.memberIDs, want.memberIDs) {
+				t.Fatalf("seed order %d: result point %d differs with workers", order, i)
+			}
+		}
+	}
+}
+
+func TestWorkersStrips(t *testing.T) {
+	points := randomPoints(100000, 6, -120, -60, 120, 60)
+	cluster := func(workers int, order SeedOrder) *Cluster {
+		c, err := NewClusterForZoom(6, 256, 40)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.SeedOrder = order
+		c.Workers = workers
+		c.MinPoints = 3
+		if err := c.ClusterPoints(points); err != nil {
+			t.Fatal(err)
+		}
+		checkAssignments(t, c, len(points))
+		return c
+	}
+	for _, order := range []SeedOrder{SeedInput, SeedHilbert, SeedDensity} {
+		sequential := cluster(1, order)
+		if bounds := sequential.stripBounds(sequential.basePoints); bounds != nil {
+			t.Fatal("points are partitioned without Workers")
+		}
+		two := cluster(2, order)
+		if bounds := two.stripBounds(two.basePoints); len(bounds) < 4 {
+			t.Fatalf("seed order %d: points are partitioned into %d strips", order, len(bounds)-1)
+		}
+		//clusters near strip borders could differ from sequential ones, the number of them is about the same
+		if n, want := len(two.ResultPoints), len(sequential.ResultPoints); n < want*99/100 || n > want*101/100 {
+			t.Fatalf("seed order %d: %d result points in strips, want about %d", order, n, want)
+		}
+		first := func(cp *ClusterPoint) int {
+			m := cp.memberIDs[0]
+			for _, id := range cp.memberIDs {
+				m = minInt(m, id)
+			}
+			return m
+		}
+		for i := 1; i < len(two.ResultPoints); i++ {
+			if first(&two.ResultPoints[i-1]) >= first(&two.ResultPoints[i]) {
+				t.Fatalf("seed order %d: result points are not in order of first members", order)
+			}
+		}
+		//strips don't depend on the number of workers, so neither do clusters
+		for _, workers := range []int{3, 8} {
+			other := cluster(workers, order)
+			if len(other.ResultPoints) != len(two.ResultPoints) {
+				t.Fatalf("seed order %d: %d result points with %d workers, want %d", order, len(other.ResultPoints), workers, len(two.ResultPoints))
+			}
+			for i := range two.ResultPoints {
+				want, got := &two.ResultPoints[i], &other.ResultPoints[i]
+				if got.Id != want.Id || got.X != want.X || got.Y != want.Y || !reflect.DeepEqual(got.memberIDs, want.memberIDs) {
+					t.Fatalf("seed order %d: result point %d differs with %d workers", order, i, workers)
+				}
+			}
+		}
+	}
+}
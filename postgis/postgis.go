@@ -0,0 +1,133 @@
+// Package postgis streams points of PostGIS query into gocluster Builder, without intermediate GeoJSON export.
+//
+// Rows are read with server side cursor in batches, so memory is used only for the points themselves.
+// The package uses database/sql only, any PostgreSQL driver could be used, e.g. lib/pq or pgx stdlib.
+package postgis
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	cluster "github.com/iahmedov/gocluster"
+)
+
+// DefaultBatchSize is the number of rows fetched from the cursor at once if BatchSize is not set
+const DefaultBatchSize = 10000
+
+// cursorName is the name of the cursor, it's local to the transaction so it never collides
+const cursorName = "gocluster_cursor"
+
+// Loader reads points of Query into Builder
+// Query should return id, longitude and latitude columns followed by any property columns:
+//
+//	SELECT id, ST_X(geom), ST_Y(geom), name, kind FROM shops WHERE region = $1
+//
+// Geometries should be in WGS84, use ST_Transform(geom, 4326) otherwise.
+// Points are *cluster.Feature with the id and properties named by columns, text values are strings.
+type Loader struct {
+	Query     string
+	Args      []interface{}
+	BatchSize int
+}
+
+// Load streams rows of the query into b, returns number of added points
+// Rows with null coordinates are reported as error, null properties are skipped.
+func (l *Loader) Load(ctx context.Context, db *sql.DB, b *cluster.Builder) (int, error) {
+	if l.Query == "" {
+		return 0, errors.New("postgis: empty query")
+	}
+	batchSize := l.BatchSize
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return 0, fmt.Errorf("postgis: %v", err)
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, "DECLARE "+cursorName+" NO SCROLL CURSOR FOR "+l.Query, l.Args...); err != nil {
+		return 0, fmt.Errorf("postgis: can't declare cursor: %v", err)
+	}
+
+	fetch := fmt.Sprintf("FETCH FORWARD %d FROM %s", batchSize, cursorName)
+	total := 0
+	for {
+		points, err := l.fetch(ctx, tx, fetch, total)
+		if err != nil {
+			return total, err
+		}
+		b.AddPoints(points...)
+		total += len(points)
+		if len(points) < batchSize {
+			break
+		}
+	}
+	if _, err := tx.ExecContext(ctx, "CLOSE "+cursorName); err != nil {
+		return total, fmt.Errorf("postgis: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return total, fmt.Errorf("postgis: %v", err)
+	}
+	return total, nil
+}
+
+// fetch reads next batch of the cursor, offset is the number of already read rows for error messages
+func (l *Loader) fetch(ctx context.Context, tx *sql.Tx, query string, offset int) ([]cluster.GeoPoint, error) {
+	rows, err := tx.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("postgis: %v", err)
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, fmt.Errorf("postgis: %v", err)
+	}
+	if len(columns) < 3 {
+		return nil, fmt.Errorf("postgis: query should return id, longitude and latitude columns, got %d columns", len(columns))
+	}
+
+	var points []cluster.GeoPoint
+	values := make([]interface{}, len(columns))
+	dest := make([]interface{}, len(columns))
+	for rows.Next() {
+		var lon, lat sql.NullFloat64
+		dest[1], dest[2] = &lon, &lat
+		for i := range dest {
+			if i != 1 && i != 2 {
+				dest[i] = &values[i]
+			}
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("postgis: row %d: %v", offset+len(points), err)
+		}
+		if !lon.Valid || !lat.Valid {
+			return nil, fmt.Errorf("postgis: row %d has null coordinates", offset+len(points))
+		}
+		f := &cluster.Feature{
+			ID:          columnValue(values[0]),
+			Coordinates: cluster.GeoCoordinates{Lon: lon.Float64, Lat: lat.Float64},
+			Properties:  make(map[string]interface{}, len(columns)-3),
+		}
+		for i := 3; i < len(columns); i++ {
+			if values[i] != nil {
+				f.Properties[columns[i]] = columnValue(values[i])
+			}
+		}
+		points = append(points, f)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("postgis: %v", err)
+	}
+	return points, nil
+}
+
+// columnValue converts text values, returned by drivers as []byte, to strings
+func columnValue(v interface{}) interface{} {
+	if b, ok := v.([]byte); ok {
+		return string(b)
+	}
+	return v
+}
@@ -0,0 +1,280 @@
+package postgis
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	cluster "github.com/iahmedov/gocluster"
+)
+
+// fakeDatabase serves FETCH of the cursor from rows and records statements of the fake driver
+type fakeDatabase struct {
+	mu          sync.Mutex
+	columns     []string
+	rows        [][]driver.Value
+	next        int //the next row of the cursor
+	statements  []string
+	declareArgs []driver.Value
+	readOnly    bool
+	committed   bool
+}
+
+var (
+	databasesMu sync.Mutex
+	databases   = map[string]*fakeDatabase{}
+)
+
+func init() {
+	sql.Register("postgis-test", fakeDriver{})
+}
+
+// openFake returns database of the fake driver, whose cursors return rows
+func openFake(t *testing.T, columns []string, rows [][]driver.Value) (*sql.DB, *fakeDatabase) {
+	t.Helper()
+	d := &fakeDatabase{columns: columns, rows: rows}
+	databasesMu.Lock()
+	databases[t.Name()] = d
+	databasesMu.Unlock()
+	db, err := sql.Open("postgis-test", t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db, d
+}
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(name string) (driver.Conn, error) {
+	databasesMu.Lock()
+	defer databasesMu.Unlock()
+	d, ok := databases[name]
+	if !ok {
+		return nil, errors.New("unknown database")
+	}
+	return &fakeConn{d: d}, nil
+}
+
+type fakeConn struct {
+	d *fakeDatabase
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeStmt{d: c.d, query: query}, nil
+}
+
+func (c *fakeConn) Close() error { return nil }
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *fakeConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	c.d.mu.Lock()
+	defer c.d.mu.Unlock()
+	c.d.readOnly = opts.ReadOnly
+	return fakeTx{c.d}, nil
+}
+
+type fakeTx struct {
+	d *fakeDatabase
+}
+
+func (tx fakeTx) Commit() error {
+	tx.d.mu.Lock()
+	defer tx.d.mu.Unlock()
+	tx.d.committed = true
+	return nil
+}
+
+func (tx fakeTx) Rollback() error { return nil }
+
+type fakeStmt struct {
+	d     *fakeDatabase
+	query string
+}
+
+func (s *fakeStmt) Close() error { return nil }
+
+func (s *fakeStmt) NumInput() int { return -1 }
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.d.mu.Lock()
+	defer s.d.mu.Unlock()
+	s.d.statements = append(s.d.statements, s.query)
+	if strings.HasPrefix(s.query, "DECLARE") {
+		s.d.declareArgs = args
+	}
+	return driver.RowsAffected(0), nil
+}
+
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.d.mu.Lock()
+	defer s.d.mu.Unlock()
+	s.d.statements = append(s.d.statements, s.query)
+	var n int
+	if _, err := fmt.Sscanf(s.query, "FETCH FORWARD %d FROM "+cursorName, &n); err != nil {
+		return nil, fmt.Errorf("unexpected query %q", s.query)
+	}
+	end := s.d.next + n
+	if end > len(s.d.rows) {
+		end = len(s.d.rows)
+	}
+	rows := &fakeRows{columns: s.d.columns, rows: s.d.rows[s.d.next:end]}
+	s.d.next = end
+	return rows, nil
+}
+
+type fakeRows struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+
+func (r *fakeRows) Close() error { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+// shopRows returns n rows of id, lon, lat, name and kind columns, kind of odd rows is null
+func shopRows(n int) [][]driver.Value {
+	rows := make([][]driver.Value, n)
+	for i := range rows {
+		var kind driver.Value
+		if i%2 == 0 {
+			kind = []byte("bakery")
+		}
+		rows[i] = []driver.Value{int64(i), float64(i%50) - 25, float64(i/50) - 25, []byte(fmt.Sprintf("shop %d", i)), kind}
+	}
+	return rows
+}
+
+var shopColumns = []string{"id", "lon", "lat", "name", "kind"}
+
+// leaves returns loaded points by their ids
+func leaves(t *testing.T, b *cluster.Builder) map[interface{}]*cluster.Feature {
+	t.Helper()
+	index, err := b.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := map[interface{}]*cluster.Feature{}
+	for _, cp := range index.AllClusters() {
+		points, err := index.Leaves(cp.Id, 0, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, p := range points {
+			f := p.(*cluster.Feature)
+			result[f.ID] = f
+		}
+	}
+	return result
+}
+
+func newTestBuilder(t *testing.T) *cluster.Builder {
+	t.Helper()
+	template, err := cluster.NewClusterForZoom(4, 256, 40)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cluster.NewBuilder(template)
+}
+
+func TestLoad(t *testing.T) {
+	db, d := openFake(t, shopColumns, shopRows(2500))
+	defer db.Close()
+	b := newTestBuilder(t)
+	l := &Loader{
+		Query:     "SELECT id, ST_X(geom), ST_Y(geom), name, kind FROM shops WHERE region = $1",
+		Args:      []interface{}{"north"},
+		BatchSize: 1000,
+	}
+	n, err := l.Load(context.Background(), db, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2500 || b.Len() != 2500 {
+		t.Fatalf("loaded %d points, builder has %d, want 2500", n, b.Len())
+	}
+	if !d.readOnly || !d.committed {
+		t.Fatal("points are not read in committed read only transaction")
+	}
+	want := []string{
+		"DECLARE " + cursorName + " NO SCROLL CURSOR FOR " + l.Query,
+		"FETCH FORWARD 1000 FROM " + cursorName,
+		"FETCH FORWARD 1000 FROM " + cursorName,
+		"FETCH FORWARD 1000 FROM " + cursorName,
+		"CLOSE " + cursorName,
+	}
+	if fmt.Sprint(d.statements) != fmt.Sprint(want) {
+		t.Fatalf("statements %q, want %q", d.statements, want)
+	}
+	if len(d.declareArgs) != 1 || d.declareArgs[0] != "north" {
+		t.Fatalf("cursor is declared with %v", d.declareArgs)
+	}
+
+	points := leaves(t, b)
+	for i := 0; i < 2500; i++ {
+		f, ok := points[int64(i)]
+		if !ok {
+			t.Fatalf("point %d is not loaded", i)
+		}
+		if f.Coordinates.Lon != float64(i%50)-25 || f.Coordinates.Lat != float64(i/50)-25 ||
+			f.Properties["name"] != fmt.Sprintf("shop %d", i) {
+			t.Fatalf("point %d is %+v", i, f)
+		}
+		if kind, ok := f.Properties["kind"]; (i%2 == 0) != ok || (ok && kind != "bakery") {
+			t.Fatalf("point %d has kind %v", i, f.Properties["kind"])
+		}
+	}
+}
+
+func TestLoadBatchBoundary(t *testing.T) {
+	//the last full batch is followed by the empty one
+	db, d := openFake(t, shopColumns, shopRows(20))
+	defer db.Close()
+	n, err := (&Loader{Query: "SELECT * FROM shops", BatchSize: 10}).Load(context.Background(), db, newTestBuilder(t))
+	if err != nil || n != 20 {
+		t.Fatalf("loaded %d points: %v", n, err)
+	}
+	if fetches := len(d.statements) - 2; fetches != 3 {
+		t.Fatalf("%d fetches, want 3", fetches)
+	}
+}
+
+func TestLoadInvalidRows(t *testing.T) {
+	rows := shopRows(5)
+	rows[3][2] = nil
+	db, d := openFake(t, shopColumns, rows)
+	defer db.Close()
+	_, err := (&Loader{Query: "SELECT * FROM shops"}).Load(context.Background(), db, newTestBuilder(t))
+	if err == nil || !strings.Contains(err.Error(), "row 3") {
+		t.Fatalf("expected error of null coordinates of row 3, got %v", err)
+	}
+	if d.committed {
+		t.Fatal("failed load is committed")
+	}
+
+	db, _ = openFake(t, []string{"id", "lon"}, [][]driver.Value{{int64(1), 1.0}})
+	defer db.Close()
+	if _, err := (&Loader{Query: "SELECT id, lon FROM shops"}).Load(context.Background(), db, newTestBuilder(t)); err == nil {
+		t.Fatal("expected error of missing latitude column")
+	}
+	if _, err := (&Loader{}).Load(context.Background(), db, newTestBuilder(t)); err == nil {
+		t.Fatal("expected error of empty query")
+	}
+}
@@ -0,0 +1,204 @@
+package cluster
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Projection converts geographic coordinates to planar ones points are clustered in, and back
+// Epsilon is the distance in projected coordinates, see EpsilonForZoom for WebMercator ones.
+// CoordinatesFixed32 needs coordinates in [0..1] range, so it's supported only for WebMercator.
+type Projection interface {
+	Project(coordinates GeoCoordinates) (x, y float64)
+	Unproject(x, y float64) GeoCoordinates
+}
+
+// WebMercator is spherical mercator projection of web maps, it's the default Projection
+// Mercator y is infinite at the poles, so points beyond the square world of ±85.0511 latitudes are put on its edges.
+// MaxLatitude keeps them apart instead: latitudes are clamped to ±MaxLatitude, which should be less than 90,
+// and projected outside of [0..1] range, CoordinatesFixed32 still puts them on the edges.
+type WebMercator struct {
+	MaxLatitude float64
+}
+
+// Project implements Projection interface
+func (m WebMercator) Project(coordinates GeoCoordinates) (float64, float64) {
+	if m.MaxLatitude <= 0 {
+		return MercatorProjection(coordinates)
+	}
+	lat := math.Max(-m.MaxLatitude, math.Min(m.MaxLatitude, coordinates.Lat))
+	return coordinates.Lon/360.0 + 0.5, mercatorY(lat)
+}
+
+// Unproject implements Projection interface
+func (m WebMercator) Unproject(x, y float64) GeoCoordinates {
+	return ReverseMercatorProjection(x, y)
+}
+
+// LonLat is no projection: x and y are longitude and latitude as is, so Epsilon is in degrees,
+// 360 times larger than WebMercator one for the same radius at the equator.
+// Coordinates of single points are returned exactly as they are, and there is no projection cost.
+// It fits datasets of small areas, whose distances are not distorted much by degrees of longitude getting shorter.
+type LonLat struct{}
+
+// Project implements Projection interface
+func (LonLat) Project(coordinates GeoCoordinates) (float64, float64) {
+	return coordinates.Lon, coordinates.Lat
+}
+
+// Unproject implements Projection interface
+func (LonLat) Unproject(x, y float64) GeoCoordinates {
+	return GeoCoordinates{Lon: x, Lat: y}
+}
+
+// Equirectangular is linear projection with longitude scaled by cosine of the standard parallel Lat0,
+// so distances around Lat0 are not distorted, unlike LonLat ones. Epsilon is in degrees of latitude.
+// It's much faster than WebMercator and exactly reversible, streets of a city or a country are clustered well with it.
+type Equirectangular struct {
+	Lat0 float64
+}
+
+// NewEquirectangular returns Equirectangular with standard parallel in the middle of latitudes of points
+func NewEquirectangular(points []GeoPoint) Equirectangular {
+	if len(points) == 0 {
+		return Equirectangular{}
+	}
+	minLat, maxLat := math.Inf(1), math.Inf(-1)
+	for _, p := range points {
+		lat := p.GetCoordinates().Lat
+		minLat, maxLat = math.Min(minLat, lat), math.Max(maxLat, lat)
+	}
+	return Equirectangular{Lat0: (minLat + maxLat) / 2}
+}
+
+// Project implements Projection interface
+func (e Equirectangular) Project(coordinates GeoCoordinates) (float64, float64) {
+	return coordinates.Lon * e.scale(), coordinates.Lat
+}
+
+// Unproject implements Projection interface
+func (e Equirectangular) Unproject(x, y float64) GeoCoordinates {
+	return GeoCoordinates{Lon: x / e.scale(), Lat: y}
+}
+
+func (e Equirectangular) scale() float64 {
+	return math.Cos(e.Lat0 * math.Pi / 180)
+}
+
+// projection returns Projection of the Cluster, WebMercator if it's not set
+func (c *Cluster) projection() Projection {
+	if c.Projection == nil {
+		return WebMercator{}
+	}
+	return c.Projection
+}
+
+// mercator returns true if points are projected with WebMercator, which grids, tiles and great-circle distances expect
+func (c *Cluster) mercator() bool {
+	_, ok := c.projection().(WebMercator)
+	return ok
+}
+
+// checkProjection returns error if options need WebMercator and points are projected with other projection
+func (c *Cluster) checkProjection() error {
+	if c.mercator() {
+		return nil
+	}
+	if c.LatitudeCorrection || c.GreatCircle || c.CentroidMode == CentroidGeodesic || c.PixelSnap.TileSize > 0 ||
+		c.CenterDamping.TileSize > 0 {
+		return errors.New("gocluster: LatitudeCorrection, GreatCircle, CentroidGeodesic, PixelSnap and CenterDamping need WebMercator projection")
+	}
+	if c.CoordinatesMode == CoordinatesFixed32 {
+		return errors.New("gocluster: CoordinatesFixed32 needs WebMercator projection")
+	}
+	return nil
+}
+
+// checkOptions returns error if options are out of range or could not be used together
+func (c *Cluster) checkOptions() error {
+	if err := c.checkRanges(); err != nil {
+		return err
+	}
+	if err := c.checkProjection(); err != nil {
+		return err
+	}
+	return c.checkBoundaries()
+}
+
+// checkRanges returns error if sizes and radiuses are invalid, before they get to index builders
+func (c *Cluster) checkRanges() error {
+	if c.NodeSize <= 0 {
+		return fmt.Errorf("gocluster: NodeSize should be positive, got %d", c.NodeSize)
+	}
+	if err := checkEpsilon(c.Epsilon); err != nil {
+		return err
+	}
+	if c.Bandwidth < 0 || math.IsNaN(c.Bandwidth) || math.IsInf(c.Bandwidth, 0) {
+		return fmt.Errorf("gocluster: Bandwidth should be finite and not negative, got %v", c.Bandwidth)
+	}
+	if c.Capacity < 0 {
+		return fmt.Errorf("gocluster: Capacity should not be negative, got %d", c.Capacity)
+	}
+	return nil
+}
+
+// checkEpsilon returns error if eps is negative, NaN or infinite
+func checkEpsilon(eps float64) error {
+	if eps < 0 || math.IsNaN(eps) || math.IsInf(eps, 0) {
+		return fmt.Errorf("gocluster: Epsilon should be finite and not negative, got %v", eps)
+	}
+	return nil
+}
+
+// projections of the package are written to snapshots by kind and parameters
+const (
+	projectionDefault = iota
+	projectionWebMercator
+	projectionLonLat
+	projectionEquirectangular
+	projectionUTM
+	projectionCartesian
+)
+
+// projectionParams returns kind and parameters of the projection for snapshot
+func projectionParams(p Projection) (int, []float64, error) {
+	switch t := p.(type) {
+	case nil:
+		return projectionDefault, nil, nil
+	case WebMercator:
+		return projectionWebMercator, []float64{t.MaxLatitude}, nil
+	case LonLat:
+		return projectionLonLat, nil, nil
+	case Equirectangular:
+		return projectionEquirectangular, []float64{t.Lat0}, nil
+	case UTM:
+		south := 0.0
+		if t.South {
+			south = 1
+		}
+		return projectionUTM, []float64{float64(t.Zone), south}, nil
+	case Cartesian:
+		return projectionCartesian, nil, nil
+	}
+	return 0, nil, fmt.Errorf("gocluster: projection %T could not be written to snapshot", p)
+}
+
+// projectionFromParams is reverse of projectionParams
+func projectionFromParams(kind int, params []float64) (Projection, error) {
+	switch {
+	case kind == projectionDefault:
+		return nil, nil
+	case kind == projectionWebMercator && len(params) == 1:
+		return WebMercator{MaxLatitude: params[0]}, nil
+	case kind == projectionLonLat:
+		return LonLat{}, nil
+	case kind == projectionEquirectangular && len(params) == 1:
+		return Equirectangular{Lat0: params[0]}, nil
+	case kind == projectionUTM && len(params) == 2:
+		return UTM{Zone: int(params[0]), South: params[1] != 0}, nil
+	case kind == projectionCartesian:
+		return Cartesian{}, nil
+	}
+	return nil, fmt.Errorf("gocluster: unsupported snapshot projection %d", kind)
+}
@@ -0,0 +1,110 @@
+package cluster
+
+import (
+	"math"
+	"testing"
+)
+
+func TestProjections(t *testing.T) {
+	//points beyond the mercator square are merged on its edge, unless MaxLatitude keeps them apart
+	polar := []GeoPoint{
+		&Feature{Coordinates: GeoCoordinates{Lon: 0, Lat: 86}},
+		&Feature{Coordinates: GeoCoordinates{Lon: 0, Lat: 89}},
+	}
+	for projection, want := range map[Projection]int{nil: 1, WebMercator{MaxLatitude: 89.9}: 2} {
+		c := NewCluster(0.01)
+		c.Projection = projection
+		if err := c.ClusterPoints(polar); err != nil {
+			t.Fatal(err)
+		}
+		if len(c.ResultPoints) != want {
+			t.Fatalf("projection %v: %d result points, want %d", projection, len(c.ResultPoints), want)
+		}
+	}
+
+	//LonLat keeps coordinates as they are, Epsilon is in degrees
+	points := randomPoints(500, 44, 10, 40, 12, 42)
+	c := NewCluster(0.05)
+	c.Projection = LonLat{}
+	if err := c.ClusterPoints(points); err != nil {
+		t.Fatal(err)
+	}
+	checkAssignments(t, c, len(points))
+	for _, cp := range c.Singles() {
+		if cp.IncludedPoints[0].GetCoordinates() != (GeoCoordinates{Lon: cp.X, Lat: cp.Y}) {
+			t.Fatalf("single point %d is moved to %v,%v", cp.Id, cp.X, cp.Y)
+		}
+	}
+	for _, cp := range c.ResultPoints {
+		for _, p := range cp.IncludedPoints {
+			coordinates := p.GetCoordinates()
+			if dx, dy := coordinates.Lon-cp.X, coordinates.Lat-cp.Y; cp.NumPoints > 1 && dx*dx+dy*dy > 4*0.05*0.05 {
+				t.Fatalf("cluster %d has member %v far from its center", cp.Id, coordinates)
+			}
+		}
+	}
+	restored := roundTrip(t, c)
+	if restored.Projection != (LonLat{}) {
+		t.Fatalf("restored projection %v", restored.Projection)
+	}
+	checkSameResult(t, c, restored)
+
+	//options of mercator tiles and spheres don't work with other projections
+	for name, set := range map[string]func(c *Cluster){
+		"LatitudeCorrection": func(c *Cluster) { c.LatitudeCorrection = true },
+		"GreatCircle":        func(c *Cluster) { c.GreatCircle = true },
+		"CentroidGeodesic":   func(c *Cluster) { c.CentroidMode = CentroidGeodesic },
+		"PixelSnap":          func(c *Cluster) { c.PixelSnap = PixelGrid{TileSize: 256} },
+		"CoordinatesFixed32": func(c *Cluster) { c.CoordinatesMode = CoordinatesFixed32 },
+	} {
+		c := NewCluster(0.05)
+		c.Projection = LonLat{}
+		set(c)
+		if err := c.ClusterPoints(points); err == nil {
+			t.Errorf("%s: expected error of LonLat projection", name)
+		}
+	}
+	northWest, southEast := GeoCoordinates{Lon: 10, Lat: 42}, GeoCoordinates{Lon: 12, Lat: 40}
+	if _, err := c.Grid(northWest, southEast, 8, 256, GridOptions{CellSize: 64}); err == nil {
+		t.Fatal("expected error of Grid of LonLat projection")
+	}
+	if _, err := c.Density(northWest, southEast, 8, 256, HeatmapOptions{CellSize: 8}); err == nil {
+		t.Fatal("expected error of Density of LonLat projection")
+	}
+}
+
+func TestEquirectangular(t *testing.T) {
+	points := randomPoints(500, 45, 10, 50, 14, 56)
+	e := NewEquirectangular(points)
+	if math.Abs(e.Lat0-53) > 0.1 {
+		t.Fatalf("standard parallel %v, want about 53", e.Lat0)
+	}
+	if (NewEquirectangular(nil) != Equirectangular{}) {
+		t.Fatal("standard parallel of no points is not the equator")
+	}
+	//the projection is reversible and degrees of longitude are as long as degrees of latitude at Lat0
+	for _, p := range points {
+		coordinates := p.GetCoordinates()
+		x, y := e.Project(coordinates)
+		if back := e.Unproject(x, y); math.Abs(back.Lon-coordinates.Lon) > 1e-9 || back.Lat != coordinates.Lat {
+			t.Fatalf("%v is back at %v", coordinates, back)
+		}
+	}
+	x0, _ := e.Project(GeoCoordinates{Lon: 10, Lat: e.Lat0})
+	x1, _ := e.Project(GeoCoordinates{Lon: 11, Lat: e.Lat0})
+	if want := math.Cos(e.Lat0 * math.Pi / 180); math.Abs(x1-x0-want) > 1e-9 {
+		t.Fatalf("degree of longitude is %v, want %v", x1-x0, want)
+	}
+
+	c := NewCluster(0.05)
+	c.Projection = e
+	if err := c.ClusterPoints(points); err != nil {
+		t.Fatal(err)
+	}
+	checkAssignments(t, c, len(points))
+	restored := roundTrip(t, c)
+	if restored.Projection != e {
+		t.Fatalf("restored projection %v, want %v", restored.Projection, e)
+	}
+	checkSameResult(t, c, restored)
+}
@@ -0,0 +1,52 @@
+package cluster
+
+// GeoPointWithProperties is the point with attributes, e.g. *Feature, they are carried into leaves of the output
+// and projected into ClusterPoint.Properties by Cluster.PropertyKeys
+type GeoPointWithProperties interface {
+	GeoPoint
+	GetProperties() map[string]interface{}
+}
+
+// pointProperties returns properties of the point, nil if it has none
+func pointProperties(p GeoPoint) map[string]interface{} {
+	if p, ok := p.(GeoPointWithProperties); ok {
+		return p.GetProperties()
+	}
+	return nil
+}
+
+// computeProperties sets Properties of the point to PropertyKeys of its members
+// Single points get their values, clusters get values which are the same for all members.
+func (c *Cluster) computeProperties(cp *ClusterPoint) {
+	cp.Properties = nil
+	if len(c.PropertyKeys) == 0 || len(cp.IncludedPoints) == 0 {
+		return
+	}
+	first := pointProperties(cp.IncludedPoints[0])
+	properties := make(map[string]interface{}, len(c.PropertyKeys))
+	for _, key := range c.PropertyKeys {
+		v, ok := first[key]
+		if !ok {
+			continue
+		}
+		for _, p := range cp.IncludedPoints[1:] {
+			if other, found := pointProperties(p)[key]; !found || !sameValue(v, other) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			properties[key] = v
+		}
+	}
+	cp.Properties = properties
+}
+
+// sameValue compares scalar property values, other values, like maps and slices, are never the same
+func sameValue(a, b interface{}) bool {
+	switch a.(type) {
+	case nil, string, bool, float64, float32, int, int32, int64, uint, uint32, uint64:
+		return a == b
+	}
+	return false
+}
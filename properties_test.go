@@ -0,0 +1,67 @@
+package cluster
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPropertyKeys(t *testing.T) {
+	at := GeoCoordinates{Lon: 10, Lat: 10}
+	var points []GeoPoint
+	for i := 0; i < 4; i++ {
+		points = append(points, &Feature{ID: i, Coordinates: at, Properties: map[string]interface{}{
+			"kind": "cafe", "n": float64(i), "tags": []interface{}{"a"}, "point_count": 0.0, "open": i > 0,
+		}})
+	}
+	//open is missing in one of members
+	delete(points[0].(*Feature).Properties, "open")
+	points = append(points, &Feature{ID: 4, Coordinates: GeoCoordinates{Lon: -10, Lat: -10},
+		Properties: map[string]interface{}{"kind": "bar", "n": 4.0, "name": "single"}})
+
+	c, err := NewClusterForZoom(3, 256, 60)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.PropertyKeys = []string{"kind", "n", "tags", "point_count", "open"}
+	if err := c.ClusterPoints(points); err != nil {
+		t.Fatal(err)
+	}
+	if len(c.ResultPoints) != 2 {
+		t.Fatalf("%d result points, want 2", len(c.ResultPoints))
+	}
+	//clusters keep scalar values shared by all members
+	cluster, single := &c.ResultPoints[0], &c.ResultPoints[1]
+	if want := map[string]interface{}{"kind": "cafe", "point_count": 0.0}; !reflect.DeepEqual(cluster.Properties, want) {
+		t.Fatalf("cluster properties %v, want %v", cluster.Properties, want)
+	}
+	if want := map[string]interface{}{"kind": "bar", "n": 4.0}; !reflect.DeepEqual(single.Properties, want) {
+		t.Fatalf("single point properties %v, want %v", single.Properties, want)
+	}
+	//reserved properties of clusters are not replaced
+	properties := ClusterProperties(cluster)
+	if properties["kind"] != "cafe" || properties["point_count"] != 4 {
+		t.Fatalf("cluster output properties %v", properties)
+	}
+	if properties := ClusterProperties(single); !reflect.DeepEqual(properties, single.Properties) {
+		t.Fatalf("single point output properties %v", properties)
+	}
+
+	restored := roundTrip(t, c)
+	if !reflect.DeepEqual(restored.PropertyKeys, c.PropertyKeys) {
+		t.Fatalf("restored property keys %v", restored.PropertyKeys)
+	}
+	for i := range c.ResultPoints {
+		if !reflect.DeepEqual(restored.ResultPoints[i].Properties, c.ResultPoints[i].Properties) {
+			t.Fatalf("restored result point %d has properties %v", i, restored.ResultPoints[i].Properties)
+		}
+	}
+
+	//single points are output with all properties without PropertyKeys
+	c.PropertyKeys = nil
+	if err := c.ClusterPoints(points); err != nil {
+		t.Fatal(err)
+	}
+	if properties := ClusterProperties(&c.ResultPoints[1]); properties["name"] != "single" {
+		t.Fatalf("single point output properties %v", properties)
+	}
+}
@@ -0,0 +1,86 @@
+package cluster
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// protobuf wire types
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+)
+
+// protoBuffer is minimal protobuf writer, used by binary encoders to avoid protobuf dependency
+type protoBuffer struct {
+	buf []byte
+}
+
+func (b *protoBuffer) varint(v uint64) {
+	for v >= 0x80 {
+		b.buf = append(b.buf, byte(v)|0x80)
+		v >>= 7
+	}
+	b.buf = append(b.buf, byte(v))
+}
+
+func (b *protoBuffer) key(field, wireType int) {
+	b.varint(uint64(field<<3 | wireType))
+}
+
+func (b *protoBuffer) uint(field int, v uint64) {
+	b.key(field, wireVarint)
+	b.varint(v)
+}
+
+func (b *protoBuffer) bool(field int, v bool) {
+	var i uint64
+	if v {
+		i = 1
+	}
+	b.uint(field, i)
+}
+
+func (b *protoBuffer) double(field int, v float64) {
+	b.key(field, wireFixed64)
+	var data [8]byte
+	binary.LittleEndian.PutUint64(data[:], math.Float64bits(v))
+	b.buf = append(b.buf, data[:]...)
+}
+
+func (b *protoBuffer) bytes(field int, data []byte) {
+	b.key(field, wireBytes)
+	b.varint(uint64(len(data)))
+	b.buf = append(b.buf, data...)
+}
+
+func (b *protoBuffer) string(field int, s string) {
+	b.key(field, wireBytes)
+	b.varint(uint64(len(s)))
+	b.buf = append(b.buf, s...)
+}
+
+func (b *protoBuffer) message(field int, m *protoBuffer) {
+	b.bytes(field, m.buf)
+}
+
+func (b *protoBuffer) packedUint32(field int, values []uint32) {
+	var p protoBuffer
+	for _, v := range values {
+		p.varint(uint64(v))
+	}
+	b.bytes(field, p.buf)
+}
+
+func zigzag(v int64) uint64 {
+	return uint64((v << 1) ^ (v >> 63))
+}
+
+func (b *protoBuffer) packedSint64(field int, values []int64) {
+	var p protoBuffer
+	for _, v := range values {
+		p.varint(zigzag(v))
+	}
+	b.bytes(field, p.buf)
+}
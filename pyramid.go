@@ -0,0 +1,95 @@
+package cluster
+
+import (
+	"errors"
+	"sort"
+	"time"
+)
+
+// ClusterEpsilons clusters points for each of epsilons, e.g. marker density presets, Clusters are in the same order
+// Points are projected and indexed once, options of c are used for all levels, c itself is not clustered.
+// StrategyGreedy levels are built bottom-up like zoom pyramid: the smallest epsilon clusters points and each larger one
+// clusters clusters of the previous level, so clusters are nested and their ids are unique across levels.
+// Other strategies cluster all points for each epsilon, like ReclusterWithEpsilon.
+func (c *Cluster) ClusterEpsilons(points []GeoPoint, epsilons []float64) ([]*Cluster, error) {
+	if len(epsilons) == 0 {
+		return nil, errors.New("gocluster: no epsilons")
+	}
+	base := &Cluster{}
+	*base = *c
+	base.columnValues, base.spill = nil, nil
+	base.layerNames, base.layerStarts = nil, nil
+	base.mintedIDs = nil
+	if err := base.checkOptions(); err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	base.indexInput(len(points), func(i int) GeoCoordinates { return points[i].GetCoordinates() }, points)
+
+	order := make([]int, len(epsilons))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool { return epsilons[order[i]] < epsilons[order[j]] })
+
+	levels := make([]*Cluster, len(epsilons))
+	var previous *Cluster
+	var clusters []*ClusterPoint
+	ingested := len(points)
+	for _, i := range order {
+		level := base.levelOf(epsilons[i])
+		if level.Strategy == StrategyGreedy && !level.passthrough() {
+			clusters = level.clusterLevel(previous, clusters)
+		} else {
+			level.buildResultPoints()
+		}
+		level.observeBuild(ingested, start)
+		levels[i], previous = level, level
+		ingested, start = 0, time.Now()
+	}
+	return levels, nil
+}
+
+// levelOf returns Cluster of the epsilon with copy of base points of c, so levels are updated independently
+func (c *Cluster) levelOf(epsilon float64) *Cluster {
+	level := &Cluster{}
+	*level = *c
+	level.Epsilon = epsilon
+	level.mintedIDs = nil
+	points := make([]ClusterPoint, len(c.basePoints))
+	level.basePoints = make([]*ClusterPoint, len(c.basePoints))
+	for i, p := range c.basePoints {
+		points[i] = *p
+		level.basePoints[i] = &points[i]
+	}
+	if c.baseOf != nil {
+		level.baseOf = append([]int(nil), c.baseOf...)
+	}
+	return level
+}
+
+// clusterLevel clusters projected clusters of the previous level, or base points for the first one,
+// and returns projected clusters of the level
+func (c *Cluster) clusterLevel(previous *Cluster, clusters []*ClusterPoint) []*ClusterPoint {
+	span := c.startSpan("clusterize", "strategy", c.Strategy, "epsilon", c.Epsilon)
+	c.assignment = c.newAssignment()
+	seeds, index := c.basePoints, c.baseIndex
+	if previous != nil {
+		//clusters passed through keep their ids, so new ones continue the sequence of the previous level
+		c.clusterSeq = previous.clusterSeq
+		seeds = make([]*ClusterPoint, len(clusters))
+		for i, cp := range clusters {
+			seed := *cp
+			seed.visited = false
+			seeds[i] = &seed
+		}
+		index = c.indexPoints(seeds)
+	}
+	result := c.clusterize(seeds, index)
+	sortByFirstMember(result)
+	c.setResultPoints(result)
+	c.dirty, c.dirtyAll = nil, false
+	c.resultsChanged()
+	span.End("clusters", len(c.ResultPoints))
+	return result
+}
@@ -0,0 +1,78 @@
+package cluster
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestClusterEpsilons(t *testing.T) {
+	points := randomPoints(3000, 47, -60, -60, 60, 60)
+	epsilons := []float64{EpsilonForZoom(3, 256, 40), EpsilonForZoom(6, 256, 40), EpsilonForZoom(4, 256, 40)}
+	c := NewCluster(0)
+	levels, err := c.ClusterEpsilons(points, epsilons)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Built() {
+		t.Fatal("template cluster is clustered")
+	}
+	if len(levels) != len(epsilons) {
+		t.Fatalf("%d levels of %d epsilons", len(levels), len(epsilons))
+	}
+	for i, level := range levels {
+		if level.Epsilon != epsilons[i] {
+			t.Fatalf("level %d has epsilon %v, want %v", i, level.Epsilon, epsilons[i])
+		}
+		checkAssignments(t, level, len(points))
+	}
+
+	//clusters of smaller epsilon are nested into larger ones, equal ids have equal members
+	members := map[int][]int{}
+	for _, i := range []int{1, 2, 0} {
+		level := levels[i]
+		for _, cp := range level.ResultPoints {
+			if previous, ok := members[cp.Id]; ok && !reflect.DeepEqual(previous, cp.memberIDs) {
+				t.Fatalf("cluster %d of epsilon %v has other members than the previous one", cp.Id, level.Epsilon)
+			}
+			members[cp.Id] = cp.memberIDs
+		}
+	}
+	for _, pair := range [][2]int{{1, 2}, {2, 0}} {
+		small, large := levels[pair[0]], levels[pair[1]]
+		for _, cp := range small.ResultPoints {
+			parent := large.assignment[cp.memberIDs[0]]
+			for _, id := range cp.memberIDs {
+				if large.assignment[id] != parent {
+					t.Fatalf("cluster %d of epsilon %v is split at epsilon %v", cp.Id, small.Epsilon, large.Epsilon)
+				}
+			}
+		}
+	}
+
+	//other strategies cluster all points for each epsilon
+	c.Strategy = StrategyBalanced
+	c.Capacity = 50
+	levels, err = c.ClusterEpsilons(points, epsilons[:2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, level := range levels {
+		fresh := NewCluster(epsilons[i])
+		fresh.Strategy, fresh.Capacity = StrategyBalanced, 50
+		if err := fresh.ClusterPoints(points); err != nil {
+			t.Fatal(err)
+		}
+		if len(level.ResultPoints) != len(fresh.ResultPoints) {
+			t.Fatalf("balanced level %d has %d result points, want %d", i, len(level.ResultPoints), len(fresh.ResultPoints))
+		}
+		for j := range fresh.ResultPoints {
+			if !reflect.DeepEqual(level.ResultPoints[j].memberIDs, fresh.ResultPoints[j].memberIDs) {
+				t.Fatalf("balanced level %d result point %d differs from clustering of the epsilon", i, j)
+			}
+		}
+	}
+
+	if _, err := c.ClusterEpsilons(points, nil); err == nil {
+		t.Fatal("expected error of no epsilons")
+	}
+}
@@ -0,0 +1,247 @@
+package cluster
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/MadAppGang/kdbush"
+)
+
+// lastVersion is the last version given to clustering result in the process
+// It starts from the start time of the process, so versions are larger than the ones of previous processes,
+// e.g. ETags cached by clients across server restarts.
+var lastVersion = uint64(time.Now().UnixNano())
+
+// resultIndex is the index of ResultPoints by longitude and latitude, built on the first bbox query
+type resultIndex struct {
+	mu   sync.Mutex
+	bush *kdbush.KDBush
+	byID map[int]int //index of ResultPoints by Id, built on the first lookup
+
+	temporal *temporalIndex //index of points with timestamps, built on the first GetClustersBetween
+	//indexes of property values by keys, built on the first FilterClusters of PropertyEquals filter of the key
+	properties map[string]*propertyIndex
+}
+
+// resultsChanged gives new version to ResultPoints and drops their index
+func (c *Cluster) resultsChanged() {
+	c.version = atomic.AddUint64(&lastVersion, 1)
+	c.results = &resultIndex{}
+	c.forgetIDs()
+}
+
+// forgetIDs tells IDForgetter generator ids minted by c which are not in ResultPoints any more,
+// ids of merged, split and rebuilt clusters and of intermediate clusters of strategies
+func (c *Cluster) forgetIDs() {
+	forgetter, ok := c.IDGenerator.(IDForgetter)
+	if !ok || len(c.mintedIDs) == 0 {
+		return
+	}
+	live := make(map[int]bool, len(c.ResultPoints))
+	for i := range c.ResultPoints {
+		live[c.ResultPoints[i].Id] = true
+	}
+	var kept, forgotten []int
+	for _, id := range c.mintedIDs {
+		if live[id] {
+			kept = append(kept, id)
+		} else {
+			forgotten = append(forgotten, id)
+		}
+	}
+	c.mintedIDs = kept
+	if len(forgotten) > 0 {
+		forgetter.Forget(forgotten)
+	}
+}
+
+// restoreVersion gives ResultPoints restored from snapshot the version they were written with,
+// versions given after it are larger
+func (c *Cluster) restoreVersion(version uint64) {
+	c.version = version
+	c.results = &resultIndex{}
+	for {
+		last := atomic.LoadUint64(&lastVersion)
+		if last >= version || atomic.CompareAndSwapUint64(&lastVersion, last, version) {
+			return
+		}
+	}
+}
+
+// Version returns version of ResultPoints, it's changed each time points are clustered or updated
+// Versions increase monotonically and are unique in the process, so they tell apart results of different Clusters too,
+// e.g. for ETag. Snapshots keep the version, so Cluster restored by ReadSnapshot has the version of the written one.
+func (c *Cluster) Version() uint64 {
+	return c.version
+}
+
+// GetClusters returns clusters and single points inside the box between northWest and southEast corners
+// Box crossing antimeridian, where northWest longitude is greater than southEast one, is supported,
+// except for Cartesian points, the box is empty for them.
+// Points are returned in the same order as in AllClusters. It's safe to call concurrently with other queries.
+// It returns nil before points are clustered, Built tells empty box from it.
+func (c *Cluster) GetClusters(northWest, southEast GeoCoordinates) []ClusterPoint {
+	defer c.startQuery("GetClusters")()
+	if c.results == nil {
+		return nil
+	}
+	var ids []int
+	if northWest.Lon <= southEast.Lon || c.planar() {
+		ids = c.resultsIndex().Range(northWest.Lon, southEast.Lat, southEast.Lon, northWest.Lat)
+	} else {
+		bush := c.resultsIndex()
+		ids = append(bush.Range(northWest.Lon, southEast.Lat, 180, northWest.Lat),
+			bush.Range(-180, southEast.Lat, southEast.Lon, northWest.Lat)...)
+	}
+	sort.Ints(ids)
+	result := make([]ClusterPoint, len(ids))
+	for i, id := range ids {
+		result[i] = c.ResultPoints[id]
+	}
+	return result
+}
+
+func (c *Cluster) resultsIndex() *kdbush.KDBush {
+	r := c.results
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.bush == nil {
+		points := make([]kdbush.Point, len(c.ResultPoints))
+		for i := range c.ResultPoints {
+			points[i] = &c.ResultPoints[i]
+		}
+		r.bush = kdbush.NewBush(points, c.NodeSize)
+	}
+	return r.bush
+}
+
+// resultByID returns index in ResultPoints of the point with id
+func (c *Cluster) resultByID(id int) (int, bool) {
+	r := c.results
+	if r == nil {
+		return 0, false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.byID == nil {
+		r.byID = make(map[int]int, len(c.ResultPoints))
+		for i := range c.ResultPoints {
+			r.byID[c.ResultPoints[i].Id] = i
+		}
+	}
+	i, ok := r.byID[id]
+	return i, ok
+}
+
+// At returns Cluster of the zoom level. Zooms above the largest level are served by it if its points are not clustered
+// because of MaxZoom, so one more level above MaxZoom serves raw points of all street level zooms.
+func (l Levels) At(zoom int) (*Cluster, bool) {
+	if c, ok := l[zoom]; ok {
+		return c, true
+	}
+	largest := -1
+	for z := range l {
+		largest = maxInt(largest, z)
+	}
+	if c := l[largest]; c != nil && zoom > largest && c.passthrough() {
+		return c, true
+	}
+	return nil, false
+}
+
+// GetClustersBetween returns clusters of the zoom level made of points with timestamps between from and to,
+// see Cluster.GetClustersBetween, it's nil for zoom levels without Cluster, see At
+func (l Levels) GetClustersBetween(northWest, southEast GeoCoordinates, zoom int, from, to time.Time) []ClusterPoint {
+	if c, ok := l.At(zoom); ok {
+		return c.GetClustersBetween(northWest, southEast, from, to)
+	}
+	return nil
+}
+
+// BBoxZoom is the viewport query of Levels.GetClustersMulti
+type BBoxZoom struct {
+	NorthWest GeoCoordinates
+	SouthEast GeoCoordinates
+	Zoom      int
+}
+
+// Levels are Clusters of zoom levels, e.g. created by NewClusterForZoom for each zoom
+type Levels map[int]*Cluster
+
+// GetClustersMulti answers many viewport queries in one call, e.g. for tiles prefetched around the user's viewport
+// Overlapping and adjacent boxes of the same zoom are searched with one index traversal and filtered after it.
+// Result is in the same order as requests, it's nil for zoom levels without Cluster, see At.
+func (l Levels) GetClustersMulti(requests []BBoxZoom) [][]ClusterPoint {
+	result := make([][]ClusterPoint, len(requests))
+	byZoom := map[int][]int{}
+	for i, r := range requests {
+		byZoom[r.Zoom] = append(byZoom[r.Zoom], i)
+	}
+	for zoom, indexes := range byZoom {
+		if c, ok := l.At(zoom); ok {
+			c.getClustersMulti(requests, indexes, result)
+		}
+	}
+	return result
+}
+
+// bboxGroup is the union of query boxes searched together
+type bboxGroup struct {
+	minLon, minLat, maxLon, maxLat float64
+	requests                       []int
+}
+
+func (g *bboxGroup) intersects(o *bboxGroup) bool {
+	return o.minLon <= g.maxLon && o.maxLon >= g.minLon && o.minLat <= g.maxLat && o.maxLat >= g.minLat
+}
+
+// getClustersMulti answers requests of the indexes and writes results by the same indexes
+func (c *Cluster) getClustersMulti(requests []BBoxZoom, indexes []int, result [][]ClusterPoint) {
+	defer c.startQuery("GetClustersMulti")()
+	if c.results == nil {
+		return
+	}
+	var groups []*bboxGroup
+	for _, i := range indexes {
+		r := requests[i]
+		if r.NorthWest.Lon > r.SouthEast.Lon && !c.planar() {
+			//boxes crossing antimeridian are searched separately
+			result[i] = c.GetClusters(r.NorthWest, r.SouthEast)
+			continue
+		}
+		group := &bboxGroup{minLon: r.NorthWest.Lon, minLat: r.SouthEast.Lat, maxLon: r.SouthEast.Lon, maxLat: r.NorthWest.Lat}
+		//merge groups the box connects
+		merged := groups[:0]
+		for _, g := range groups {
+			if g.intersects(group) {
+				group.minLon, group.minLat = math.Min(group.minLon, g.minLon), math.Min(group.minLat, g.minLat)
+				group.maxLon, group.maxLat = math.Max(group.maxLon, g.maxLon), math.Max(group.maxLat, g.maxLat)
+				group.requests = append(group.requests, g.requests...)
+				continue
+			}
+			merged = append(merged, g)
+		}
+		group.requests = append(group.requests, i)
+		groups = append(merged, group)
+	}
+
+	bush := c.resultsIndex()
+	for _, g := range groups {
+		ids := bush.Range(g.minLon, g.minLat, g.maxLon, g.maxLat)
+		sort.Ints(ids)
+		for _, i := range g.requests {
+			r := requests[i]
+			points := []ClusterPoint{}
+			for _, id := range ids {
+				p := &c.ResultPoints[id]
+				if p.X >= r.NorthWest.Lon && p.X <= r.SouthEast.Lon && p.Y >= r.SouthEast.Lat && p.Y <= r.NorthWest.Lat {
+					points = append(points, *p)
+				}
+			}
+			result[i] = points
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package cluster
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// checkInBox fails if points are not the result points inside the box, in their order
+func checkInBox(t *testing.T, c *Cluster, points []ClusterPoint, inside func(cp ClusterPoint) bool) {
+	t.Helper()
+	var want []int
+	for _, cp := range c.ResultPoints {
+		if inside(cp) {
+			want = append(want, cp.Id)
+		}
+	}
+	got := make([]int, len(points))
+	for i, cp := range points {
+		got[i] = cp.Id
+	}
+	if len(want) == 0 || !equalInts(got, want) {
+		t.Fatalf("box has points %v, want %v", got, want)
+	}
+}
+
+func TestGetClusters(t *testing.T) {
+	c, err := NewClusterForZoom(4, 256, 40)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.GetClusters(GeoCoordinates{Lon: -10, Lat: 10}, GeoCoordinates{Lon: 10, Lat: -10}) != nil {
+		t.Fatal("Cluster without points has clusters")
+	}
+	if err := c.ClusterPoints(randomPoints(3000, 15, -180, -60, 180, 60)); err != nil {
+		t.Fatal(err)
+	}
+	version := c.Version()
+
+	points := c.GetClusters(GeoCoordinates{Lon: -20, Lat: 30}, GeoCoordinates{Lon: 40, Lat: -10})
+	checkInBox(t, c, points, func(cp ClusterPoint) bool {
+		return cp.X >= -20 && cp.X <= 40 && cp.Y >= -10 && cp.Y <= 30
+	})
+	//box crosses antimeridian
+	points = c.GetClusters(GeoCoordinates{Lon: 150, Lat: 30}, GeoCoordinates{Lon: -170, Lat: -10})
+	checkInBox(t, c, points, func(cp ClusterPoint) bool {
+		return (cp.X >= 150 || cp.X <= -170) && cp.Y >= -10 && cp.Y <= 30
+	})
+
+	if err := c.ReclusterWithEpsilon(c.Epsilon * 2); err != nil {
+		t.Fatal(err)
+	}
+	if c.Version() <= version {
+		t.Fatalf("version %d is not increased from %d", c.Version(), version)
+	}
+	//index of the previous result points is dropped
+	points = c.GetClusters(GeoCoordinates{Lon: -180, Lat: 90}, GeoCoordinates{Lon: 180, Lat: -90})
+	if len(points) != len(c.ResultPoints) {
+		t.Fatalf("%d points in the world, %d result points", len(points), len(c.ResultPoints))
+	}
+}
+
+func TestGetClustersMulti(t *testing.T) {
+	points := randomPoints(3000, 20, -180, -60, 180, 60)
+	levels := Levels{}
+	for _, zoom := range []int{3, 4} {
+		c, err := NewClusterForZoom(zoom, 256, 40)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := c.ClusterPoints(points); err != nil {
+			t.Fatal(err)
+		}
+		levels[zoom] = c
+	}
+
+	random := rand.New(rand.NewSource(21))
+	var requests []BBoxZoom
+	for i := 0; i < 40; i++ {
+		//overlapping, adjacent and separate boxes
+		west, north := -180+random.Float64()*300, -50+random.Float64()*100
+		requests = append(requests, BBoxZoom{
+			NorthWest: GeoCoordinates{Lon: west, Lat: north},
+			SouthEast: GeoCoordinates{Lon: west + random.Float64()*50, Lat: north - random.Float64()*40},
+			Zoom:      3 + i%2,
+		})
+	}
+	requests = append(requests,
+		BBoxZoom{NorthWest: GeoCoordinates{Lon: 170, Lat: 30}, SouthEast: GeoCoordinates{Lon: -170, Lat: -30}, Zoom: 4},
+		BBoxZoom{NorthWest: GeoCoordinates{Lon: -10, Lat: 10}, SouthEast: GeoCoordinates{Lon: 10, Lat: -10}, Zoom: 5},
+	)
+
+	result := levels.GetClustersMulti(requests)
+	if len(result) != len(requests) {
+		t.Fatalf("%d results of %d requests", len(result), len(requests))
+	}
+	for i, r := range requests {
+		c, ok := levels.At(r.Zoom)
+		if !ok {
+			if result[i] != nil {
+				t.Fatalf("request %d of zoom %d without Cluster has result", i, r.Zoom)
+			}
+			continue
+		}
+		want := c.GetClusters(r.NorthWest, r.SouthEast)
+		if len(result[i]) != len(want) {
+			t.Fatalf("request %d has %d points, GetClusters has %d", i, len(result[i]), len(want))
+		}
+		for j := range want {
+			if result[i][j].Id != want[j].Id {
+				t.Fatalf("request %d has point %d, GetClusters has %d", i, result[i][j].Id, want[j].Id)
+			}
+		}
+	}
+}
+
+func TestMaxZoom(t *testing.T) {
+	points := randomPoints(2000, 54, -1, -1, 1, 1)
+	levels := Levels{}
+	for _, zoom := range []int{10, 14, 15} {
+		c, err := NewClusterForZoom(zoom, 256, 60)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.MaxZoom = 14
+		if err := c.ClusterPoints(points); err != nil {
+			t.Fatal(err)
+		}
+		levels[zoom] = c
+	}
+	//levels up to MaxZoom are clustered, the level above it has input points as they are
+	if n := len(levels[14].ResultPoints); n >= len(points) {
+		t.Fatalf("%d result points at MaxZoom", n)
+	}
+	raw := levels[15]
+	if len(raw.ResultPoints) != len(points) {
+		t.Fatalf("%d result points above MaxZoom, want %d", len(raw.ResultPoints), len(points))
+	}
+	for i, cp := range raw.ResultPoints {
+		if cp.NumPoints != 1 || cp.IncludedPoints[0] != points[i] {
+			t.Fatalf("result point %d above MaxZoom is not input point", i)
+		}
+	}
+	if restored := roundTrip(t, raw); restored.MaxZoom != 14 {
+		t.Fatalf("restored MaxZoom %d", restored.MaxZoom)
+	}
+
+	//the level above MaxZoom serves all larger zooms
+	for zoom, want := range map[int]*Cluster{10: levels[10], 15: raw, 18: raw, 12: nil, 9: nil} {
+		if c, ok := levels.At(zoom); c != want || ok != (want != nil) {
+			t.Errorf("zoom %d is served by %p, ok %v, want %p", zoom, c, ok, want)
+		}
+	}
+	delete(levels, 15)
+	if _, ok := levels.At(18); ok {
+		t.Fatal("clustered largest level serves larger zooms")
+	}
+	levels[15] = raw
+	box := BBoxZoom{NorthWest: GeoCoordinates{Lon: -1, Lat: 1}, SouthEast: GeoCoordinates{Lon: 1, Lat: -1}, Zoom: 18}
+	if results := levels.GetClustersMulti([]BBoxZoom{box}); len(results[0]) != len(points) {
+		t.Fatalf("%d points at zoom 18, want %d", len(results[0]), len(points))
+	}
+}
@@ -0,0 +1,261 @@
+// Package redisstore persists clustering results in Redis, so stateless API instances serve the same clustering.
+//
+// Clusters of each dataset and zoom are stored in three keys:
+//
+//	prefix:dataset:zoom:geo      GEO set of cluster ids
+//	prefix:dataset:zoom:features hash of cluster id to GeoJSON Feature, as cluster.MarshalGeoJSONFeature encodes it
+//	prefix:dataset:zoom:assign   hash of input point id to cluster id, ids are ClusterPoint.SourceIDs
+//
+// Save writes new results to temporary keys and renames them in one transaction,
+// so readers never see partially written results. GEOSEARCH requires Redis 6.2 or later.
+//
+// The package has no Redis client dependency, Conn is satisfied by redigo connection
+// and is easily implemented with other clients.
+package redisstore
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+
+	cluster "github.com/iahmedov/gocluster"
+)
+
+// Conn is Redis connection, the same as Do of redigo redis.Conn
+// MULTI and EXEC are sent with Do, so it should be a single connection, not a pool.
+type Conn interface {
+	Do(commandName string, args ...interface{}) (reply interface{}, err error)
+}
+
+// batchSize is the number of members written with one command
+const batchSize = 1000
+
+// Store reads and writes clustering results with Redis connection
+type Store struct {
+	conn   Conn
+	prefix string
+}
+
+// New returns Store using conn, all keys start with prefix
+func New(conn Conn, prefix string) *Store {
+	return &Store{conn: conn, prefix: prefix}
+}
+
+// Cluster is the stored clustered point: its id, coordinates and GeoJSON Feature
+type Cluster struct {
+	ID          int
+	Coordinates cluster.GeoCoordinates
+	Feature     json.RawMessage
+}
+
+func (s *Store) key(dataset string, zoom int, kind string) string {
+	return fmt.Sprintf("%s:%s:%d:%s", s.prefix, dataset, zoom, kind)
+}
+
+var kinds = []string{"geo", "features", "assign"}
+
+// Save replaces stored results of dataset and zoom with points, as they are returned by AllClusters
+func (s *Store) Save(dataset string, zoom int, points []cluster.ClusterPoint) error {
+	tmp := make([]interface{}, len(kinds))
+	for i, kind := range kinds {
+		tmp[i] = s.key(dataset, zoom, kind) + ":tmp"
+	}
+	if _, err := s.conn.Do("DEL", tmp...); err != nil {
+		return fmt.Errorf("redisstore: %v", err)
+	}
+
+	var geo, features, assign []interface{}
+	flush := func() error {
+		for i, args := range [][]interface{}{geo, features, assign} {
+			if len(args) == 0 {
+				continue
+			}
+			command := "HSET"
+			if i == 0 {
+				command = "GEOADD"
+			}
+			if _, err := s.conn.Do(command, append([]interface{}{tmp[i]}, args...)...); err != nil {
+				return fmt.Errorf("redisstore: %v", err)
+			}
+		}
+		geo, features, assign = geo[:0], features[:0], assign[:0]
+		return nil
+	}
+	for i := range points {
+		p := &points[i]
+		feature, err := cluster.MarshalGeoJSONFeature(*p)
+		if err != nil {
+			return err
+		}
+		id := strconv.Itoa(p.Id)
+		geo = append(geo, p.X, p.Y, id)
+		features = append(features, id, feature)
+		for _, member := range p.SourceIDs() {
+			assign = append(assign, fmt.Sprint(member), id)
+		}
+		if len(geo) >= 3*batchSize || len(assign) >= 2*batchSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return err
+	}
+
+	//swap new results in atomically
+	if _, err := s.conn.Do("MULTI"); err != nil {
+		return fmt.Errorf("redisstore: %v", err)
+	}
+	for i, kind := range kinds {
+		live := s.key(dataset, zoom, kind)
+		var err error
+		if len(points) == 0 {
+			_, err = s.conn.Do("DEL", live)
+		} else {
+			_, err = s.conn.Do("RENAME", tmp[i], live)
+		}
+		if err != nil {
+			s.conn.Do("DISCARD")
+			return fmt.Errorf("redisstore: %v", err)
+		}
+	}
+	if _, err := s.conn.Do("EXEC"); err != nil {
+		return fmt.Errorf("redisstore: %v", err)
+	}
+	return nil
+}
+
+// GetClusters returns stored clusters inside the box between northWest and southEast corners, ordered by id
+// Box crossing antimeridian, where northWest longitude is greater than southEast one, is supported.
+func (s *Store) GetClusters(dataset string, zoom int, northWest, southEast cluster.GeoCoordinates) ([]Cluster, error) {
+	if northWest.Lon > southEast.Lon {
+		west, err := s.GetClusters(dataset, zoom, northWest, cluster.GeoCoordinates{Lon: 180, Lat: southEast.Lat})
+		if err != nil {
+			return nil, err
+		}
+		east, err := s.GetClusters(dataset, zoom, cluster.GeoCoordinates{Lon: -180, Lat: northWest.Lat}, southEast)
+		if err != nil {
+			return nil, err
+		}
+		result := append(west, east...)
+		sort.Slice(result, func(i, j int) bool { return result[i].ID < result[j].ID })
+		return result, nil
+	}
+
+	ids, err := s.searchBox(s.key(dataset, zoom, "geo"), northWest, southEast)
+	if err != nil || len(ids) == 0 {
+		return nil, err
+	}
+	reply, err := s.conn.Do("HMGET", append([]interface{}{s.key(dataset, zoom, "features")}, ids...)...)
+	if err != nil {
+		return nil, fmt.Errorf("redisstore: %v", err)
+	}
+	values, ok := reply.([]interface{})
+	if !ok || len(values) != len(ids) {
+		return nil, errors.New("redisstore: unexpected HMGET reply")
+	}
+
+	result := make([]Cluster, 0, len(values))
+	for i, v := range values {
+		data, ok := replyBytes(v)
+		if !ok {
+			//removed by concurrent Save
+			continue
+		}
+		var feature struct {
+			Geometry struct {
+				Coordinates [2]float64 `json:"coordinates"`
+			} `json:"geometry"`
+		}
+		if err := json.Unmarshal(data, &feature); err != nil {
+			return nil, fmt.Errorf("redisstore: invalid feature: %v", err)
+		}
+		lon, lat := feature.Geometry.Coordinates[0], feature.Geometry.Coordinates[1]
+		//GEOSEARCH box is approximate, filter by exact coordinates
+		if lon < northWest.Lon || lon > southEast.Lon || lat < southEast.Lat || lat > northWest.Lat {
+			continue
+		}
+		id, _ := strconv.Atoi(ids[i].(string))
+		result = append(result, Cluster{ID: id, Coordinates: cluster.GeoCoordinates{Lon: lon, Lat: lat}, Feature: data})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ID < result[j].ID })
+	return result, nil
+}
+
+// searchBox returns ids of GEO set members in the box, and some members around it
+func (s *Store) searchBox(key string, northWest, southEast cluster.GeoCoordinates) ([]interface{}, error) {
+	const kmPerDegree = 6372.797560856 * math.Pi / 180
+	//Redis measures box width along the latitude of each point, so it should be as wide as the box at the equator side
+	minAbsLat := math.Min(math.Abs(northWest.Lat), math.Abs(southEast.Lat))
+	if northWest.Lat >= 0 && southEast.Lat <= 0 {
+		minAbsLat = 0
+	}
+	width := (southEast.Lon-northWest.Lon)*kmPerDegree*math.Cos(minAbsLat*math.Pi/180)*1.01 + 0.01
+	height := (northWest.Lat-southEast.Lat)*kmPerDegree*1.01 + 0.01
+	reply, err := s.conn.Do("GEOSEARCH", key, "FROMLONLAT",
+		(northWest.Lon+southEast.Lon)/2, (northWest.Lat+southEast.Lat)/2, "BYBOX", width, height, "km")
+	if err != nil {
+		return nil, fmt.Errorf("redisstore: %v", err)
+	}
+	members, ok := reply.([]interface{})
+	if !ok && reply != nil {
+		return nil, errors.New("redisstore: unexpected GEOSEARCH reply")
+	}
+	ids := make([]interface{}, 0, len(members))
+	for _, m := range members {
+		data, ok := replyBytes(m)
+		if !ok {
+			return nil, errors.New("redisstore: unexpected GEOSEARCH reply")
+		}
+		ids = append(ids, string(data))
+	}
+	return ids, nil
+}
+
+// ClusterOf returns id of the stored cluster of input point, ok is false if the point is unknown
+// pointID is the id of GeoPointWithID point or input index of other points.
+func (s *Store) ClusterOf(dataset string, zoom int, pointID interface{}) (id int, ok bool, err error) {
+	reply, err := s.conn.Do("HGET", s.key(dataset, zoom, "assign"), fmt.Sprint(pointID))
+	if err != nil {
+		return 0, false, fmt.Errorf("redisstore: %v", err)
+	}
+	data, ok := replyBytes(reply)
+	if !ok {
+		return 0, false, nil
+	}
+	id, err = strconv.Atoi(string(data))
+	if err != nil {
+		return 0, false, fmt.Errorf("redisstore: invalid cluster id %q", data)
+	}
+	return id, true, nil
+}
+
+// FeatureCollection returns GeoJSON FeatureCollection of stored clusters
+func FeatureCollection(clusters []Cluster) []byte {
+	var b bytes.Buffer
+	b.WriteString(`{"type":"FeatureCollection","features":[`)
+	for i, c := range clusters {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.Write(c.Feature)
+	}
+	b.WriteString("]}")
+	return b.Bytes()
+}
+
+// replyBytes returns bulk string reply, redigo returns []byte and other clients return string
+func replyBytes(reply interface{}) ([]byte, bool) {
+	switch v := reply.(type) {
+	case []byte:
+		return v, true
+	case string:
+		return []byte(v), true
+	}
+	return nil, false
+}
@@ -0,0 +1,282 @@
+package redisstore
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"testing"
+
+	cluster "github.com/iahmedov/gocluster"
+)
+
+// fakeRedis is Conn of in-memory Redis, which supports commands of Store
+// Replies are the ones of redigo: bulk strings are []byte and arrays are []interface{}.
+type fakeRedis struct {
+	geo      map[string]map[string]cluster.GeoCoordinates
+	hashes   map[string]map[string][]byte
+	queued   [][]interface{} //commands of MULTI
+	multi    bool
+	failOn   string //this command fails
+	commands []string
+}
+
+func newFakeRedis() *fakeRedis {
+	return &fakeRedis{geo: map[string]map[string]cluster.GeoCoordinates{}, hashes: map[string]map[string][]byte{}}
+}
+
+func (r *fakeRedis) Do(command string, args ...interface{}) (interface{}, error) {
+	r.commands = append(r.commands, command)
+	if command == r.failOn {
+		return nil, errors.New("connection reset")
+	}
+	switch command {
+	case "MULTI":
+		r.multi = true
+		return "OK", nil
+	case "DISCARD":
+		r.multi, r.queued = false, nil
+		return "OK", nil
+	case "EXEC":
+		replies := make([]interface{}, len(r.queued))
+		for i, c := range r.queued {
+			replies[i], _ = r.do(c[0].(string), c[1:]...)
+		}
+		r.multi, r.queued = false, nil
+		return replies, nil
+	}
+	if r.multi {
+		r.queued = append(r.queued, append([]interface{}{command}, args...))
+		return "QUEUED", nil
+	}
+	return r.do(command, args...)
+}
+
+func (r *fakeRedis) do(command string, args ...interface{}) (interface{}, error) {
+	switch command {
+	case "DEL":
+		for _, key := range args {
+			delete(r.geo, key.(string))
+			delete(r.hashes, key.(string))
+		}
+	case "RENAME":
+		from, to := args[0].(string), args[1].(string)
+		if set, ok := r.geo[from]; ok {
+			r.geo[to] = set
+		} else if hash, ok := r.hashes[from]; ok {
+			r.hashes[to] = hash
+		} else {
+			return nil, errors.New("ERR no such key")
+		}
+		delete(r.geo, from)
+		delete(r.hashes, from)
+	case "GEOADD":
+		set := r.geo[args[0].(string)]
+		if set == nil {
+			set = map[string]cluster.GeoCoordinates{}
+			r.geo[args[0].(string)] = set
+		}
+		for i := 1; i+2 < len(args); i += 3 {
+			set[args[i+2].(string)] = cluster.GeoCoordinates{Lon: args[i].(float64), Lat: args[i+1].(float64)}
+		}
+	case "HSET":
+		hash := r.hashes[args[0].(string)]
+		if hash == nil {
+			hash = map[string][]byte{}
+			r.hashes[args[0].(string)] = hash
+		}
+		for i := 1; i+1 < len(args); i += 2 {
+			switch v := args[i+1].(type) {
+			case string:
+				hash[args[i].(string)] = []byte(v)
+			case []byte:
+				hash[args[i].(string)] = v
+			}
+		}
+	case "HGET":
+		if v, ok := r.hashes[args[0].(string)][args[1].(string)]; ok {
+			return v, nil
+		}
+		return nil, nil
+	case "HMGET":
+		values := make([]interface{}, len(args)-1)
+		for i, field := range args[1:] {
+			if v, ok := r.hashes[args[0].(string)][field.(string)]; ok {
+				values[i] = v
+			}
+		}
+		return values, nil
+	case "GEOSEARCH":
+		return r.searchBox(args...), nil
+	default:
+		return nil, fmt.Errorf("ERR unknown command %q", command)
+	}
+	return "OK", nil
+}
+
+// searchBox returns members in the box as Redis does: width is measured along the latitude of each member
+func (r *fakeRedis) searchBox(args ...interface{}) []interface{} {
+	const kmPerDegree = 6372.797560856 * math.Pi / 180
+	lon, lat := args[2].(float64), args[3].(float64)
+	width, height := args[5].(float64), args[6].(float64)
+	var members []interface{}
+	for id, c := range r.geo[args[0].(string)] {
+		dx := math.Abs(c.Lon-lon) * kmPerDegree * math.Cos(c.Lat*math.Pi/180)
+		dy := math.Abs(c.Lat-lat) * kmPerDegree
+		if dx <= width/2 && dy <= height/2 {
+			members = append(members, []byte(id))
+		}
+	}
+	return members
+}
+
+// testPoints returns result points of points scattered over the world at zoom 3
+func testPoints(t *testing.T) []cluster.ClusterPoint {
+	t.Helper()
+	random := rand.New(rand.NewSource(1))
+	points := make([]cluster.GeoPoint, 500)
+	for i := range points {
+		points[i] = &cluster.Feature{
+			ID:          i,
+			Coordinates: cluster.GeoCoordinates{Lon: random.Float64()*360 - 180, Lat: random.Float64()*140 - 70},
+		}
+	}
+	c, err := cluster.NewClusterForZoom(3, 256, 40)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.ClusterPoints(points); err != nil {
+		t.Fatal(err)
+	}
+	return c.AllClusters()
+}
+
+// idsInside returns sorted ids of points inside the box, which may cross antimeridian
+func idsInside(points []cluster.ClusterPoint, northWest, southEast cluster.GeoCoordinates) []int {
+	var ids []int
+	for _, p := range points {
+		inLon := p.X >= northWest.Lon && p.X <= southEast.Lon
+		if northWest.Lon > southEast.Lon {
+			inLon = p.X >= northWest.Lon || p.X <= southEast.Lon
+		}
+		if inLon && p.Y >= southEast.Lat && p.Y <= northWest.Lat {
+			ids = append(ids, p.Id)
+		}
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+func clusterIDs(clusters []Cluster) []int {
+	ids := make([]int, len(clusters))
+	for i, c := range clusters {
+		ids[i] = c.ID
+	}
+	return ids
+}
+
+func TestSaveGetClusters(t *testing.T) {
+	points := testPoints(t)
+	conn := newFakeRedis()
+	s := New(conn, "test")
+	if err := s.Save("places", 3, points); err != nil {
+		t.Fatal(err)
+	}
+	if len(conn.geo) != 1 || len(conn.hashes) != 2 {
+		t.Fatalf("%d GEO sets and %d hashes are kept, want only live keys", len(conn.geo), len(conn.hashes))
+	}
+
+	boxes := [][2]cluster.GeoCoordinates{
+		{{Lon: -180, Lat: 85}, {Lon: 180, Lat: -85}},
+		{{Lon: 10, Lat: 65}, {Lon: 60, Lat: 40}}, //narrower at the north side
+		{{Lon: -30, Lat: 20}, {Lon: 30, Lat: -20}},
+		{{Lon: 150, Lat: 50}, {Lon: -150, Lat: -50}}, //crossing antimeridian
+	}
+	for _, box := range boxes {
+		clusters, err := s.GetClusters("places", 3, box[0], box[1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := idsInside(points, box[0], box[1])
+		if len(want) == 0 {
+			t.Fatalf("box %v has no points", box)
+		}
+		if got := clusterIDs(clusters); fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Fatalf("box %v has clusters %v, want %v", box, got, want)
+		}
+	}
+
+	all, err := s.GetClusters("places", 3, boxes[0][0], boxes[0][1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var collection struct {
+		Features []struct {
+			Properties map[string]interface{}
+		}
+	}
+	if err := json.Unmarshal(FeatureCollection(all), &collection); err != nil {
+		t.Fatal(err)
+	}
+	if len(collection.Features) != len(points) {
+		t.Fatalf("collection has %d features, want %d", len(collection.Features), len(points))
+	}
+}
+
+func TestClusterOf(t *testing.T) {
+	points := testPoints(t)
+	s := New(newFakeRedis(), "test")
+	if err := s.Save("places", 3, points); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range points {
+		for _, member := range p.SourceIDs() {
+			id, ok, err := s.ClusterOf("places", 3, member)
+			if err != nil || !ok || id != p.Id {
+				t.Fatalf("point %v is in cluster %d, %v, %v, want %d", member, id, ok, err, p.Id)
+			}
+		}
+	}
+	if _, ok, err := s.ClusterOf("places", 3, "unknown"); ok || err != nil {
+		t.Fatalf("unknown point is found: %v", err)
+	}
+	if _, ok, _ := s.ClusterOf("places", 4, strconv.Itoa(0)); ok {
+		t.Fatal("point is found at unsaved zoom")
+	}
+}
+
+func TestSaveReplaces(t *testing.T) {
+	points := testPoints(t)
+	conn := newFakeRedis()
+	s := New(conn, "test")
+	if err := s.Save("places", 3, points); err != nil {
+		t.Fatal(err)
+	}
+	world := [2]cluster.GeoCoordinates{{Lon: -180, Lat: 85}, {Lon: 180, Lat: -85}}
+
+	//failed swap keeps previous results
+	conn.failOn = "RENAME"
+	if err := s.Save("places", 3, points[:1]); err == nil {
+		t.Fatal("expected error of failed RENAME")
+	}
+	if conn.commands[len(conn.commands)-1] != "DISCARD" {
+		t.Fatalf("failed transaction ends with %s, want DISCARD", conn.commands[len(conn.commands)-1])
+	}
+	conn.failOn = ""
+	if clusters, err := s.GetClusters("places", 3, world[0], world[1]); err != nil || len(clusters) != len(points) {
+		t.Fatalf("%d clusters after failed save, want %d: %v", len(clusters), len(points), err)
+	}
+
+	if err := s.Save("places", 3, nil); err != nil {
+		t.Fatal(err)
+	}
+	if clusters, err := s.GetClusters("places", 3, world[0], world[1]); err != nil || len(clusters) != 0 {
+		t.Fatalf("%d clusters after empty save: %v", len(clusters), err)
+	}
+	if len(conn.geo) != 0 || len(conn.hashes) != 0 {
+		t.Fatal("keys of empty results are kept")
+	}
+}
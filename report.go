@@ -0,0 +1,122 @@
+package cluster
+
+import (
+	"fmt"
+	"math"
+)
+
+// ReportReason tells why the input point is listed in BuildReport
+type ReportReason int
+
+const (
+	// ReasonInvalidCoordinates is NaN or infinite coordinate, or longitude and latitude out of ±180 and ±90,
+	// the point is skipped. Cartesian coordinates only need to be finite.
+	ReasonInvalidCoordinates ReportReason = iota
+	// ReasonClampedLatitude is latitude beyond the edge of WebMercator world, ±85.0511 or ±MaxLatitude,
+	// the point is clustered at the edge
+	ReasonClampedLatitude
+	// ReasonDuplicate is coordinates of the previous point, the point is merged into its base point by DeduplicateCoordinates
+	ReasonDuplicate
+)
+
+func (r ReportReason) String() string {
+	switch r {
+	case ReasonInvalidCoordinates:
+		return "invalid coordinates"
+	case ReasonClampedLatitude:
+		return "clamped latitude"
+	case ReasonDuplicate:
+		return "duplicate"
+	}
+	return "unknown"
+}
+
+// ReportEntry is the input point skipped or adjusted by clustering
+type ReportEntry struct {
+	// Index is the index of the point in the slice passed to ClusterPoints or the row of ClusterColumns
+	Index       int
+	Reason      ReportReason
+	Coordinates GeoCoordinates
+}
+
+// BuildReport lists input points which were not clustered as they are, in input order
+type BuildReport struct {
+	// Points is the number of input points
+	Points int
+	// Skipped points are in no result point, their Assignments are -1
+	Skipped []ReportEntry
+	// Adjusted points are clustered with changed coordinates or merged with other points
+	Adjusted []ReportEntry
+	// Thinned are cells thinned out by ThinOut, their dropped points are not listed one by one
+	Thinned []ThinnedCell
+}
+
+// Report returns BuildReport of points of the last ClusterPoints, ClusterColumns or ClusterMerged,
+// e.g. to log bad rows of the dataset instead of finding them missing or moved on the map.
+// Snapshots don't keep it, so it's empty after ReadSnapshot.
+func (c *Cluster) Report() BuildReport {
+	report := c.report
+	report.Skipped = append([]ReportEntry(nil), report.Skipped...)
+	report.Adjusted = append([]ReportEntry(nil), report.Adjusted...)
+	report.Thinned = append([]ThinnedCell(nil), report.Thinned...)
+	return report
+}
+
+// inputCheck validates coordinates of input points while they are projected and collects BuildReport
+type inputCheck struct {
+	geographic  bool    //longitudes and latitudes are checked
+	maxLatitude float64 //latitudes beyond it are clamped, 0 if there is no clamping
+	report      BuildReport
+}
+
+// newInputCheck creates inputCheck of n input points for the projection of c
+func (c *Cluster) newInputCheck(n int) *inputCheck {
+	check := &inputCheck{geographic: !c.planar(), report: BuildReport{Points: n}}
+	if m, ok := c.projection().(WebMercator); ok {
+		check.maxLatitude = m.MaxLatitude
+		if m.MaxLatitude <= 0 {
+			//latitude of mercator y 0, the edge of the square world
+			check.maxLatitude = 85.0511287798066
+		}
+	}
+	return check
+}
+
+// accept returns true if input point i could be clustered, adjustments of its coordinates are reported
+func (ic *inputCheck) accept(i int, coordinates GeoCoordinates) bool {
+	lon, lat := coordinates.Lon, coordinates.Lat
+	if math.IsNaN(lon) || math.IsNaN(lat) || math.IsInf(lon, 0) || math.IsInf(lat, 0) ||
+		ic.geographic && (lon < -180 || lon > 180 || lat < -90 || lat > 90) {
+		ic.report.Skipped = append(ic.report.Skipped, ReportEntry{Index: i, Reason: ReasonInvalidCoordinates, Coordinates: coordinates})
+		return false
+	}
+	if ic.maxLatitude > 0 && math.Abs(lat) > ic.maxLatitude {
+		ic.report.Adjusted = append(ic.report.Adjusted, ReportEntry{Index: i, Reason: ReasonClampedLatitude, Coordinates: coordinates})
+	}
+	return true
+}
+
+// CheckCoordinates returns error if clustering skips the point with coordinates, see ReasonInvalidCoordinates,
+// e.g. to validate updates before MovePoint, which returns the same error
+func (c *Cluster) CheckCoordinates(coordinates GeoCoordinates) error {
+	if !c.newInputCheck(1).accept(0, coordinates) {
+		return fmt.Errorf("gocluster: invalid coordinates %v,%v", coordinates.Lon, coordinates.Lat)
+	}
+	return nil
+}
+
+// duplicate reports input point i merged into base point of the previous point with the same coordinates
+func (ic *inputCheck) duplicate(i int, coordinates GeoCoordinates) {
+	ic.report.Adjusted = append(ic.report.Adjusted, ReportEntry{Index: i, Reason: ReasonDuplicate, Coordinates: coordinates})
+}
+
+// newAssignment returns assignment of input points to fill by clustering, skipped points are in no result point
+func (c *Cluster) newAssignment() []int {
+	assignment := make([]int, c.numInputPoints())
+	for id, b := range c.baseOf {
+		if b < 0 {
+			assignment[id] = -1
+		}
+	}
+	return assignment
+}
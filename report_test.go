@@ -0,0 +1,104 @@
+package cluster
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestReport(t *testing.T) {
+	points := randomPoints(500, 64, -30, -30, 30, 30)
+	bad := map[int]GeoCoordinates{
+		3:   {Lon: math.NaN(), Lat: 0},
+		10:  {Lon: math.Inf(1), Lat: 10},
+		100: {Lon: 200, Lat: 10},
+		499: {Lon: 10, Lat: -91},
+	}
+	for i, coordinates := range bad {
+		points[i] = &Feature{ID: i, Coordinates: coordinates}
+	}
+	points[42] = &Feature{ID: 42, Coordinates: GeoCoordinates{Lon: 10, Lat: 89}}
+	//the next point has coordinates of the previous one
+	points[201] = &Feature{ID: 201, Coordinates: points[200].GetCoordinates()}
+
+	for _, mode := range []string{"input", "presort", "deduplicate"} {
+		c, err := NewClusterForZoom(3, 256, 60)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.HilbertPresort = mode == "presort"
+		c.DeduplicateCoordinates = mode == "deduplicate"
+		if err := c.ClusterPoints(points); err != nil {
+			t.Fatal(err)
+		}
+		report := c.Report()
+		if report.Points != len(points) || len(report.Skipped) != len(bad) {
+			t.Fatalf("%s report of %d points has %d skipped points", mode, report.Points, len(report.Skipped))
+		}
+		for i, e := range report.Skipped {
+			if e.Reason != ReasonInvalidCoordinates || i > 0 && report.Skipped[i-1].Index >= e.Index {
+				t.Fatalf("%s skipped entry %d is %+v", mode, i, e)
+			}
+			if _, ok := bad[e.Index]; !ok {
+				t.Fatalf("%s point %d is skipped", mode, e.Index)
+			}
+		}
+		adjusted := []ReportEntry{{Index: 42, Reason: ReasonClampedLatitude, Coordinates: GeoCoordinates{Lon: 10, Lat: 89}}}
+		if c.DeduplicateCoordinates {
+			adjusted = append(adjusted, ReportEntry{Index: 201, Reason: ReasonDuplicate, Coordinates: points[201].GetCoordinates()})
+		}
+		if !reflect.DeepEqual(report.Adjusted, adjusted) {
+			t.Fatalf("%s adjusted points are %+v, want %+v", mode, report.Adjusted, adjusted)
+		}
+
+		//skipped points are in no result point, others are in one
+		assignments := c.Assignments()
+		total := 0
+		for _, cp := range c.ResultPoints {
+			total += cp.NumPoints
+			for _, id := range cp.memberIDs {
+				if _, ok := bad[id]; ok {
+					t.Fatalf("%s skipped point %d is in cluster %d", mode, id, cp.Id)
+				}
+				if assignments[id] < 0 || c.ResultPoints[assignments[id]].Id != cp.Id {
+					t.Fatalf("%s point %d of cluster %d is assigned to %d", mode, id, cp.Id, assignments[id])
+				}
+			}
+		}
+		if total != len(points)-len(bad) {
+			t.Fatalf("%s result points have %d points, want %d", mode, total, len(points)-len(bad))
+		}
+		for id := range bad {
+			if _, ok := c.ResultIndex(id); ok || assignments[id] != -1 || c.assignmentRows()[1][id] != -1 {
+				t.Fatalf("%s skipped point %d is assigned to %d", mode, id, assignments[id])
+			}
+			if err := c.MovePoint(id, GeoCoordinates{Lon: 0, Lat: 0}); err == nil {
+				t.Fatalf("%s expected error of moving skipped point %d", mode, id)
+			}
+		}
+		restored := roundTrip(t, c)
+		if !reflect.DeepEqual(restored.Assignments(), assignments) {
+			t.Fatalf("%s restored cluster has other assignments", mode)
+		}
+		if len(restored.Report().Skipped) != 0 {
+			t.Fatalf("%s restored cluster has report", mode)
+		}
+	}
+}
+
+func TestCheckCoordinates(t *testing.T) {
+	c := NewCluster(0.01)
+	for _, coordinates := range []GeoCoordinates{{Lon: math.NaN(), Lat: 0}, {Lon: 0, Lat: math.Inf(-1)}, {Lon: -181, Lat: 0}, {Lon: 0, Lat: 90.5}} {
+		if err := c.CheckCoordinates(coordinates); err == nil {
+			t.Fatalf("expected error of coordinates %v", coordinates)
+		}
+	}
+	for _, coordinates := range []GeoCoordinates{{Lon: 180, Lat: 90}, {Lon: -180, Lat: -89}, {Lon: 0, Lat: 0}} {
+		if err := c.CheckCoordinates(coordinates); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if ReasonDuplicate.String() != "duplicate" || ReportReason(-1).String() != "unknown" {
+		t.Fatal("unexpected names of reasons")
+	}
+}
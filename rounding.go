@@ -0,0 +1,63 @@
+package cluster
+
+import (
+	"errors"
+	"math"
+)
+
+// rounding rounds numbers of encoded points to decimal digits, the zero value keeps them as they are
+type rounding struct {
+	e float64 //10^digits, 0 if numbers are not rounded
+}
+
+// newRounding returns rounding to precision decimal digits, which is between 0 and 12
+func newRounding(precision int) (rounding, error) {
+	if precision < 0 || precision > 12 {
+		return rounding{}, errors.New("gocluster: precision should be between 0 and 12")
+	}
+	return rounding{e: math.Pow10(precision)}, nil
+}
+
+func (r rounding) round(v float64) float64 {
+	if r.e == 0 {
+		return v
+	}
+	return math.Round(v*r.e) / r.e
+}
+
+// properties returns copy of properties with rounded numbers, properties are returned as they are without rounding
+// Numbers of nested maps and slices, e.g. coordinates of top_leaves, are rounded too, integers are kept.
+func (r rounding) properties(properties map[string]interface{}) map[string]interface{} {
+	if r.e == 0 || properties == nil {
+		return properties
+	}
+	result := make(map[string]interface{}, len(properties))
+	for k, v := range properties {
+		result[k] = r.value(v)
+	}
+	return result
+}
+
+func (r rounding) value(v interface{}) interface{} {
+	switch t := v.(type) {
+	case float64:
+		return r.round(t)
+	case float32:
+		return r.round(float64(t))
+	case []float64:
+		rounded := make([]float64, len(t))
+		for i, f := range t {
+			rounded[i] = r.round(f)
+		}
+		return rounded
+	case []interface{}:
+		rounded := make([]interface{}, len(t))
+		for i, e := range t {
+			rounded[i] = r.value(e)
+		}
+		return rounded
+	case map[string]interface{}:
+		return r.properties(t)
+	}
+	return v
+}
@@ -0,0 +1,73 @@
+package cluster
+
+import "sync"
+
+// ServingIndex serves the current Index to concurrent queries of long-running servers and swaps in new ones atomically,
+// e.g. rebuilt by Builder in background or read by ReadIndex. Queries started before Swap finish on the previous Index,
+// Swap tells when they are done, so it could be closed safely. It's safe for concurrent use.
+type ServingIndex struct {
+	mu      sync.Mutex
+	current *servedIndex
+}
+
+// servedIndex is the Index and its running queries
+type servedIndex struct {
+	index   *Index
+	queries sync.WaitGroup
+}
+
+// NewServingIndex creates ServingIndex serving the index, it could be nil until the first Swap
+func NewServingIndex(index *Index) *ServingIndex {
+	return &ServingIndex{current: &servedIndex{index: index}}
+}
+
+// Acquire returns the current Index, nil if there is none, and release, which should be called once the query is done
+func (s *ServingIndex) Acquire() (index *Index, release func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	served := s.current
+	served.queries.Add(1)
+	var once sync.Once
+	return served.index, func() { once.Do(served.queries.Done) }
+}
+
+// Query calls fn with the current Index, it's not reported drained by Swap until fn returns
+func (s *ServingIndex) Query(fn func(index *Index)) {
+	index, release := s.Acquire()
+	defer release()
+	fn(index)
+}
+
+// Index returns the current Index without acquiring it, e.g. for queries which don't need draining
+func (s *ServingIndex) Index() *Index {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.current.index
+}
+
+// Version returns Version of the current Index, e.g. for ETag, 0 if there is none
+// Versions of Indexes built later are larger, so clients could tell if they have results of the current one.
+func (s *ServingIndex) Version() uint64 {
+	if index := s.Index(); index != nil {
+		return index.Version()
+	}
+	return 0
+}
+
+// Swap replaces the current Index, queries acquiring it after Swap get the new one.
+// It returns the previous Index and the channel closed when all its queries are done,
+// e.g. to Close it or drop the last reference to its memory.
+func (s *ServingIndex) Swap(index *Index) (previous *Index, drained <-chan struct{}) {
+	s.mu.Lock()
+	served := s.current
+	s.current = &servedIndex{index: index}
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		//no queries are added to the previous Index after the swap, so Wait could not miss them
+		served.queries.Wait()
+		close(done)
+	}()
+	return served.index, done
+}
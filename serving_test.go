@@ -0,0 +1,92 @@
+package cluster
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestServingIndex(t *testing.T) {
+	template, err := NewClusterForZoom(4, 256, 40)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := NewBuilder(template)
+	b.AddPoints(randomPoints(500, 58, -60, -60, 60, 60)...)
+	first, err := b.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	serving := NewServingIndex(nil)
+	if index, release := serving.Acquire(); index != nil || serving.Version() != 0 {
+		t.Fatal("empty ServingIndex has index")
+	} else {
+		release()
+	}
+	if previous, drained := serving.Swap(first); previous != nil {
+		t.Fatal("previous index of empty ServingIndex")
+	} else {
+		<-drained
+	}
+	if serving.Index() != first || serving.Version() != first.Version() {
+		t.Fatal("ServingIndex doesn't serve swapped index")
+	}
+
+	//queries of the previous index keep it from draining until they are done
+	index, release := serving.Acquire()
+	second, err := b.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous, drained := serving.Swap(second)
+	if previous != first || index != first {
+		t.Fatal("swap returns other index than the one served")
+	}
+	serving.Query(func(index *Index) {
+		if index != second {
+			t.Fatal("query after swap is served by the previous index")
+		}
+	})
+	select {
+	case <-drained:
+		t.Fatal("previous index is drained while its query runs")
+	case <-time.After(10 * time.Millisecond):
+	}
+	release()
+	release()
+	select {
+	case <-drained:
+	case <-time.After(time.Second):
+		t.Fatal("previous index is not drained after its query is done")
+	}
+	if serving.Version() <= first.Version() {
+		t.Fatalf("version %d of the new index is not larger than %d", serving.Version(), first.Version())
+	}
+}
+
+func TestSnapshotVersion(t *testing.T) {
+	c, err := NewClusterForZoom(4, 256, 40)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.ClusterPoints(randomPoints(500, 59, -60, -60, 60, 60)); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := c.WriteSnapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+	index, err := ReadIndex(&buf)
+	if err != nil || index.Version() != c.Version() {
+		t.Fatalf("index read from snapshot has version %d, want %d: %v", index.Version(), c.Version(), err)
+	}
+	//versions given after restoring larger one of another process are larger still
+	future := c.Version() + uint64(time.Hour)
+	index.c.restoreVersion(future)
+	if err := c.ClusterPoints(randomPoints(10, 60, -60, -60, 60, 60)); err != nil {
+		t.Fatal(err)
+	}
+	if c.Version() <= future {
+		t.Fatalf("version %d after restored %d", c.Version(), future)
+	}
+}
@@ -0,0 +1,108 @@
+package shapefile
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+type dbfField struct {
+	name   string
+	kind   byte
+	length int
+}
+
+// readDBF reads all records of dBASE file, deleted records are kept as nil to stay aligned with .shp records
+func readDBF(r io.Reader) ([]map[string]interface{}, error) {
+	header := make([]byte, 32)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, fmt.Errorf("shapefile: can't read dbf header: %v", err)
+	}
+	numRecords := int(binary.LittleEndian.Uint32(header[4:8]))
+	headerLength := int(binary.LittleEndian.Uint16(header[8:10]))
+	recordLength := int(binary.LittleEndian.Uint16(header[10:12]))
+	if headerLength < 33 {
+		return nil, errors.New("shapefile: invalid dbf header length")
+	}
+	if recordLength < 1 {
+		return nil, errors.New("shapefile: invalid dbf record length")
+	}
+
+	//header and record lengths are 16-bit, so they are allocated as is
+	descriptors := make([]byte, headerLength-32)
+	if _, err := io.ReadFull(r, descriptors); err != nil {
+		return nil, fmt.Errorf("shapefile: can't read dbf fields: %v", err)
+	}
+	var fields []dbfField
+	for i := 0; i+32 <= len(descriptors) && descriptors[i] != 0x0D; i += 32 {
+		d := descriptors[i : i+32]
+		name := d[:11]
+		if n := bytes.IndexByte(name, 0); n >= 0 {
+			name = name[:n]
+		}
+		fields = append(fields, dbfField{
+			name:   string(name),
+			kind:   d[11],
+			length: int(d[16]),
+		})
+	}
+
+	//number of records is not trusted, result grows as records are read
+	result := make([]map[string]interface{}, 0, minInt(numRecords, 1<<16))
+	record := make([]byte, recordLength)
+	for n := 0; n < numRecords; n++ {
+		if _, err := io.ReadFull(r, record); err != nil {
+			return nil, fmt.Errorf("shapefile: dbf record %d: %v", n, err)
+		}
+		if record[0] == '*' {
+			result = append(result, nil)
+			continue
+		}
+		properties := make(map[string]interface{}, len(fields))
+		offset := 1
+		for _, f := range fields {
+			if offset+f.length > len(record) {
+				return nil, fmt.Errorf("shapefile: dbf record %d is too short", n)
+			}
+			properties[f.name] = parseDBFValue(f, record[offset:offset+f.length])
+			offset += f.length
+		}
+		result = append(result, properties)
+	}
+	return result, nil
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func parseDBFValue(f dbfField, raw []byte) interface{} {
+	value := strings.TrimSpace(string(raw))
+	switch f.kind {
+	case 'N', 'F':
+		if value == "" {
+			return nil
+		}
+		if v, err := strconv.ParseFloat(value, 64); err == nil {
+			return v
+		}
+		return nil
+	case 'L':
+		switch value {
+		case "T", "t", "Y", "y":
+			return true
+		case "F", "f", "N", "n":
+			return false
+		}
+		return nil
+	default:
+		return value
+	}
+}
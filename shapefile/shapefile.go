@@ -0,0 +1,191 @@
+// Package shapefile reads point layers of ESRI Shapefiles into gocluster GeoPoints.
+//
+// Only point geometries are supported: Point, MultiPoint and their Z and M variants.
+// Coordinates are expected to be WGS84 longitude/latitude, .prj file is not interpreted.
+// Attributes from .dbf file are stored as properties of the resulting *cluster.Feature.
+package shapefile
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	cluster "github.com/iahmedov/gocluster"
+)
+
+// Shape types of the main file
+const (
+	shapeNull       = 0
+	shapePoint      = 1
+	shapeMultiPoint = 8
+	shapePointZ     = 11
+	shapeMultiZ     = 18
+	shapePointM     = 21
+	shapeMultiM     = 28
+)
+
+const shpFileCode = 9994
+
+// maxRecordLength limits content length of .shp records, 4M points of MultiPointZ, so broken lengths don't allocate too much
+const maxRecordLength = 1 << 27
+
+// ErrUnsupportedShape is returned when shapefile contains non point geometries
+var ErrUnsupportedShape = errors.New("shapefile: only point layers are supported")
+
+// LoadFile reads shapefile by path to .shp file, attributes are read from .dbf file with the same name if it exists
+// Extensions are matched case insensitively, ROADS.SHP is read with ROADS.DBF or ROADS.dbf.
+func LoadFile(path string) ([]cluster.GeoPoint, error) {
+	base := path
+	if ext := filepath.Ext(path); strings.EqualFold(ext, ".shp") {
+		base = strings.TrimSuffix(path, ext)
+	} else if name, ok := sibling(base, ".shp"); ok {
+		path = name
+	} else {
+		path = base + ".shp"
+	}
+	shp, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer shp.Close()
+
+	name, ok := sibling(base, ".dbf")
+	if !ok {
+		return Load(shp, nil)
+	}
+	dbf, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer dbf.Close()
+	return Load(shp, dbf)
+}
+
+// sibling returns path of file of the shapefile with path base without extension, in any case of ext
+// base+ext is preferred, as case sensitive file systems could have several of them
+func sibling(base, ext string) (string, bool) {
+	if _, err := os.Stat(base + ext); err == nil {
+		return base + ext, true
+	}
+	entries, err := ioutil.ReadDir(filepath.Dir(base))
+	if err != nil {
+		return "", false
+	}
+	want := filepath.Base(base) + ext
+	for _, e := range entries {
+		if !e.IsDir() && strings.EqualFold(e.Name(), want) && strings.HasPrefix(e.Name(), filepath.Base(base)) {
+			return filepath.Join(filepath.Dir(base), e.Name()), true
+		}
+	}
+	return "", false
+}
+
+// Load reads points from .shp reader and attributes from .dbf reader
+// dbf could be nil, in this case points have no properties
+// Id of each feature is the record number in the shapefile, starting from 1
+func Load(shp, dbf io.Reader) ([]cluster.GeoPoint, error) {
+	var attrs []map[string]interface{}
+	if dbf != nil {
+		var err error
+		if attrs, err = readDBF(bufio.NewReader(dbf)); err != nil {
+			return nil, err
+		}
+	}
+
+	r := bufio.NewReader(shp)
+	header := make([]byte, 100)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, fmt.Errorf("shapefile: can't read header: %v", err)
+	}
+	if binary.BigEndian.Uint32(header[0:4]) != shpFileCode {
+		return nil, errors.New("shapefile: invalid file code")
+	}
+	switch binary.LittleEndian.Uint32(header[32:36]) {
+	case shapeNull, shapePoint, shapePointZ, shapePointM, shapeMultiPoint, shapeMultiZ, shapeMultiM:
+	default:
+		return nil, ErrUnsupportedShape
+	}
+	//file length is in 16-bit words, records should fit into it
+	remaining := 2*int64(binary.BigEndian.Uint32(header[24:28])) - int64(len(header))
+
+	var result []cluster.GeoPoint
+	recordHeader := make([]byte, 8)
+	for n := 0; ; n++ {
+		if _, err := io.ReadFull(r, recordHeader); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, fmt.Errorf("shapefile: record %d: %v", n, err)
+		}
+		number := int(binary.BigEndian.Uint32(recordHeader[0:4]))
+		length := 2 * int64(binary.BigEndian.Uint32(recordHeader[4:8]))
+		if length > maxRecordLength {
+			return nil, fmt.Errorf("shapefile: record %d is too long, %d bytes", number, length)
+		}
+		if remaining -= int64(len(recordHeader)) + length; remaining < 0 {
+			return nil, fmt.Errorf("shapefile: record %d is out of file length", number)
+		}
+		content := make([]byte, length)
+		if _, err := io.ReadFull(r, content); err != nil {
+			return nil, fmt.Errorf("shapefile: record %d: %v", number, err)
+		}
+
+		coordinates, err := parseShape(content)
+		if err != nil {
+			return nil, fmt.Errorf("shapefile: record %d: %v", number, err)
+		}
+		var properties map[string]interface{}
+		if n < len(attrs) {
+			properties = attrs[n]
+		}
+		for _, c := range coordinates {
+			result = append(result, &cluster.Feature{ID: number, Coordinates: c, Properties: properties})
+		}
+	}
+	return result, nil
+}
+
+func parseShape(content []byte) ([]cluster.GeoCoordinates, error) {
+	if len(content) < 4 {
+		return nil, errors.New("record is too short")
+	}
+	switch binary.LittleEndian.Uint32(content[0:4]) {
+	case shapeNull:
+		return nil, nil
+	case shapePoint, shapePointZ, shapePointM:
+		if len(content) < 20 {
+			return nil, errors.New("point record is too short")
+		}
+		return []cluster.GeoCoordinates{readCoordinates(content[4:])}, nil
+	case shapeMultiPoint, shapeMultiZ, shapeMultiM:
+		//shape type, bounding box and number of points
+		if len(content) < 40 {
+			return nil, errors.New("multipoint record is too short")
+		}
+		num := int(binary.LittleEndian.Uint32(content[36:40]))
+		if len(content) < 40+16*num {
+			return nil, errors.New("multipoint record is too short")
+		}
+		result := make([]cluster.GeoCoordinates, num)
+		for i := range result {
+			result[i] = readCoordinates(content[40+16*i:])
+		}
+		return result, nil
+	default:
+		return nil, ErrUnsupportedShape
+	}
+}
+
+func readCoordinates(b []byte) cluster.GeoCoordinates {
+	return cluster.GeoCoordinates{
+		Lon: math.Float64frombits(binary.LittleEndian.Uint64(b[0:8])),
+		Lat: math.Float64frombits(binary.LittleEndian.Uint64(b[8:16])),
+	}
+}
@@ -0,0 +1,187 @@
+package shapefile
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	cluster "github.com/iahmedov/gocluster"
+)
+
+// encodeShp returns .shp file of records, each one is Point of one coordinate or MultiPoint of several
+func encodeShp(records [][]cluster.GeoCoordinates) []byte {
+	var body bytes.Buffer
+	for i, coordinates := range records {
+		var content []byte
+		if len(coordinates) == 1 {
+			content = make([]byte, 20)
+			binary.LittleEndian.PutUint32(content, shapePoint)
+			putCoordinates(content[4:], coordinates[0])
+		} else {
+			content = make([]byte, 40+16*len(coordinates))
+			binary.LittleEndian.PutUint32(content, shapeMultiPoint)
+			binary.LittleEndian.PutUint32(content[36:], uint32(len(coordinates)))
+			for k, c := range coordinates {
+				putCoordinates(content[40+16*k:], c)
+			}
+		}
+		header := make([]byte, 8)
+		binary.BigEndian.PutUint32(header, uint32(i+1))
+		binary.BigEndian.PutUint32(header[4:], uint32(len(content)/2))
+		body.Write(header)
+		body.Write(content)
+	}
+	header := make([]byte, 100)
+	binary.BigEndian.PutUint32(header, shpFileCode)
+	binary.BigEndian.PutUint32(header[24:], uint32((100+body.Len())/2))
+	binary.LittleEndian.PutUint32(header[28:], 1000)
+	binary.LittleEndian.PutUint32(header[32:], shapeMultiPoint)
+	return append(header, body.Bytes()...)
+}
+
+func putCoordinates(b []byte, c cluster.GeoCoordinates) {
+	binary.LittleEndian.PutUint64(b, math.Float64bits(c.Lon))
+	binary.LittleEndian.PutUint64(b[8:], math.Float64bits(c.Lat))
+}
+
+// encodeDBF returns .dbf file with character column "name" and numeric column "n", deleted records are nil
+func encodeDBF(names []string) []byte {
+	const nameLength, numberLength = 10, 8
+	header := make([]byte, 32)
+	binary.LittleEndian.PutUint32(header[4:], uint32(len(names)))
+	binary.LittleEndian.PutUint16(header[8:], 32+2*32+1)
+	binary.LittleEndian.PutUint16(header[10:], 1+nameLength+numberLength)
+	field := func(name string, kind byte, length int) []byte {
+		d := make([]byte, 32)
+		copy(d, name)
+		d[11], d[16] = kind, byte(length)
+		return d
+	}
+	data := append(header, field("name", 'C', nameLength)...)
+	data = append(data, field("n", 'N', numberLength)...)
+	data = append(data, 0x0D)
+	for i, name := range names {
+		if name == "" {
+			data = append(data, '*')
+		} else {
+			data = append(data, ' ')
+		}
+		data = append(data, []byte(padRight(name, nameLength))...)
+		data = append(data, []byte(padLeft(string(rune('0'+i)), numberLength))...)
+	}
+	return append(data, 0x1A)
+}
+
+func padRight(s string, n int) string {
+	for len(s) < n {
+		s += " "
+	}
+	return s
+}
+
+func padLeft(s string, n int) string {
+	for len(s) < n {
+		s = " " + s
+	}
+	return s
+}
+
+var testRecords = [][]cluster.GeoCoordinates{
+	{{Lon: 13.4, Lat: 52.5}},
+	{{Lon: 2.35, Lat: 48.85}, {Lon: 2.36, Lat: 48.86}},
+	{{Lon: -74, Lat: 40.7}},
+}
+
+func TestLoad(t *testing.T) {
+	points, err := Load(bytes.NewReader(encodeShp(testRecords)), bytes.NewReader(encodeDBF([]string{"berlin", "paris", ""})))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var coordinates []cluster.GeoCoordinates
+	for _, r := range testRecords {
+		coordinates = append(coordinates, r...)
+	}
+	if len(points) != len(coordinates) {
+		t.Fatalf("loaded %d points, want %d", len(points), len(coordinates))
+	}
+	for i, p := range points {
+		if p.GetCoordinates() != coordinates[i] {
+			t.Errorf("point %d at %v, want %v", i, p.GetCoordinates(), coordinates[i])
+		}
+	}
+	want := []map[string]interface{}{
+		{"name": "berlin", "n": 0.0},
+		{"name": "paris", "n": 1.0},
+		{"name": "paris", "n": 1.0},
+		nil,
+	}
+	for i, p := range points {
+		f := p.(*cluster.Feature)
+		if !reflect.DeepEqual(f.Properties, want[i]) {
+			t.Errorf("point %d has properties %v, want %v", i, f.Properties, want[i])
+		}
+	}
+	//features of one multipoint record share its number
+	if ids := []interface{}{points[1].(*cluster.Feature).ID, points[2].(*cluster.Feature).ID}; ids[0] != 2 || ids[1] != 2 {
+		t.Errorf("multipoint features have ids %v, want record number 2", ids)
+	}
+}
+
+func TestLoadBroken(t *testing.T) {
+	shp := encodeShp(testRecords)
+	tooLong := append([]byte(nil), shp...)
+	binary.BigEndian.PutUint32(tooLong[104:], 0xFFFFFFFF)
+	beyondFile := append([]byte(nil), shp...)
+	binary.BigEndian.PutUint32(beyondFile[24:], 60)
+	for name, data := range map[string][]byte{"record too long": tooLong, "record beyond file length": beyondFile, "truncated": shp[:130]} {
+		if _, err := Load(bytes.NewReader(data), nil); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+
+	//the number of records claims 4G records, but there are none
+	dbf := encodeDBF(nil)
+	binary.LittleEndian.PutUint32(dbf[4:], 0xFFFFFFFF)
+	if _, err := Load(bytes.NewReader(shp), bytes.NewReader(dbf)); err == nil {
+		t.Error("expected error of truncated dbf")
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "shapefile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for name, data := range map[string][]byte{
+		"ROADS.SHP":  encodeShp(testRecords),
+		"ROADS.DBF":  encodeDBF([]string{"berlin", "paris", "new york"}),
+		"rivers.shp": encodeShp(testRecords[:1]),
+	} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, path := range []string{"ROADS.SHP", "ROADS"} {
+		points, err := LoadFile(filepath.Join(dir, path))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(points) != 4 || points[0].(*cluster.Feature).Properties["name"] != "berlin" {
+			t.Errorf("%s: loaded %d points without attributes of ROADS.DBF", path, len(points))
+		}
+	}
+	points, err := LoadFile(filepath.Join(dir, "rivers.shp"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(points) != 1 || points[0].(*cluster.Feature).Properties != nil {
+		t.Errorf("loaded %v, want one point without properties", points)
+	}
+}
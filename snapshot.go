@@ -0,0 +1,589 @@
+package cluster
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+)
+
+// snapshotMagic starts snapshot data, the last byte is the format version
+var snapshotMagic = []byte("GOCLUSTER\x01")
+
+// snapshotMaxLength limits lengths read from snapshot, so broken data doesn't allocate too much
+const snapshotMaxLength = 1 << 31
+
+func init() {
+	//types of decoded GeoJSON properties
+	gob.Register(&Feature{})
+	gob.Register(XY{})
+	gob.Register(map[string]interface{}{})
+	gob.Register([]interface{}{})
+}
+
+// WriteSnapshot writes full state of the clustered Cluster in compact binary form: options, projected points,
+// ResultPoints, id counters, Version and Boundaries, so ReadSnapshot restores it in another process without clustering again.
+// Input points and Boundaries are encoded with encoding/gob, types of input points, IDs and Properties of polygons
+// should be registered with gob.Register, *Feature and XY are registered.
+// Numeric stats accessors, LeafRank, Weight and Label are functions and could not be written,
+// Stats, TopLeaves, Weight and Label of ResultPoints are kept, Properties are taken from restored input points.
+func (c *Cluster) WriteSnapshot(w io.Writer) error {
+	if c.baseIndex == nil {
+		return notBuilt("WriteSnapshot")
+	}
+	if c.Dirty() {
+		return fmt.Errorf("%w, RebuildDirty should be called before WriteSnapshot", ErrDirty)
+	}
+	sw := &snapshotWriter{w: bufio.NewWriter(w)}
+	sw.w.Write(snapshotMagic)
+
+	sw.float(c.Epsilon)
+	sw.int(c.Zoom)
+	sw.int(c.NodeSize)
+	sw.int(c.MinPoints)
+	sw.int(int(c.Strategy))
+	sw.float(c.Bandwidth)
+	sw.int(c.Capacity)
+	sw.int(int(c.SeedOrder))
+	sw.bool(c.LatitudeCorrection)
+	sw.bool(c.GreatCircle)
+	sw.int(int(c.CentroidMode))
+	sw.int(c.PixelSnap.TileSize)
+	sw.float(c.PixelSnap.CellPx)
+	kind, params, err := projectionParams(c.Projection)
+	if err != nil {
+		return err
+	}
+	sw.int(kind)
+	sw.floats(params)
+	sw.int(int(c.CoordinatesMode))
+	sw.bool(c.DeduplicateCoordinates)
+	sw.floats(c.StatPercentiles)
+	sw.strings(c.PropertyKeys)
+	sw.int(c.MaxZoom)
+	sw.bool(c.HilbertPresort)
+	sw.int(int(c.IndexBackend))
+	sw.int(c.ClusterIdxSeed)
+	sw.int(c.clusterSeq)
+	sw.int(int(c.version))
+	sw.bool(c.generatedIDs)
+	sw.int(c.ThinOut.MaxPoints)
+	sw.float(c.ThinOut.CellSize)
+	sw.int(int(c.ThinOut.Seed))
+	sw.int(int(c.BorderPolicy))
+	sw.int(c.CenterDamping.TileSize)
+	sw.float(c.CenterDamping.Pixels)
+
+	sw.int(len(c.basePoints))
+	for _, p := range c.basePoints {
+		sw.point(p)
+	}
+	sw.bool(c.baseOf != nil)
+	if c.baseOf != nil {
+		sw.ints(c.baseOf)
+	}
+
+	sw.int(len(c.ResultPoints))
+	for i := range c.ResultPoints {
+		p := &c.ResultPoints[i]
+		sw.point(p)
+		sw.stats(p.Stats)
+		sw.ints(p.topLeafIDs)
+		sw.float(p.Weight)
+		sw.string(p.Label)
+		sw.string(p.PointCountAbbreviated)
+		sw.counts(p.Categories)
+		sw.counts(p.Layers)
+	}
+
+	names := make([]string, 0, len(c.columnValues))
+	for name := range c.columnValues {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	sw.int(len(names))
+	for _, name := range names {
+		sw.string(name)
+		sw.floats(c.columnValues[name])
+	}
+
+	sw.bool(c.opticsOrdering != nil)
+	sw.int(len(c.opticsOrdering))
+	for _, o := range c.opticsOrdering {
+		sw.int(o.ID)
+		sw.float(o.Reachability)
+		sw.float(o.CoreDistance)
+	}
+
+	sw.strings(c.layerNames)
+	sw.ints(c.layerStarts)
+
+	//input points by id, members of clusters are restored from them
+	points := c.inputPoints()
+	sw.bool(points != nil)
+	if sw.err == nil && points != nil {
+		sw.err = gob.NewEncoder(sw.w).Encode(points)
+	}
+	//Boundaries are encoded the same way, for IDs and Properties of polygons, regions of points are found again
+	sw.bool(len(c.Boundaries) > 0)
+	if sw.err == nil && len(c.Boundaries) > 0 {
+		sw.err = gob.NewEncoder(sw.w).Encode(c.Boundaries)
+	}
+	if sw.err != nil {
+		return fmt.Errorf("gocluster: can't write snapshot: %v", sw.err)
+	}
+	return sw.w.Flush()
+}
+
+// inputPoints returns points passed to ClusterPoints by their ids, or nil for ClusterColumns
+func (c *Cluster) inputPoints() []GeoPoint {
+	var points []GeoPoint
+	for _, p := range c.basePoints {
+		if len(p.IncludedPoints) == 0 {
+			continue
+		}
+		if points == nil {
+			points = make([]GeoPoint, c.numInputPoints())
+		}
+		for i, id := range p.memberIDs {
+			points[id] = p.IncludedPoints[i]
+		}
+	}
+	return points
+}
+
+// ReadSnapshot restores Cluster written by WriteSnapshot, spatial index is built again
+// Numeric stats accessors, Category and IDGenerator should be set again before ReclusterWithEpsilon or UpdatePoint,
+// Time before GetClustersBetween.
+func ReadSnapshot(r io.Reader) (*Cluster, error) {
+	br := bufio.NewReader(r)
+	magic := make([]byte, len(snapshotMagic))
+	if _, err := io.ReadFull(br, magic); err != nil || string(magic) != string(snapshotMagic) {
+		return nil, errors.New("gocluster: not a snapshot or unsupported snapshot version")
+	}
+	sr := &snapshotReader{r: br}
+	c := &Cluster{}
+	c.Epsilon = sr.float()
+	c.Zoom = sr.int()
+	c.NodeSize = sr.int()
+	c.MinPoints = sr.int()
+	c.Strategy = Strategy(sr.int())
+	c.Bandwidth = sr.float()
+	c.Capacity = sr.int()
+	c.SeedOrder = SeedOrder(sr.int())
+	c.LatitudeCorrection = sr.bool()
+	c.GreatCircle = sr.bool()
+	c.CentroidMode = CentroidMode(sr.int())
+	c.PixelSnap.TileSize = sr.int()
+	c.PixelSnap.CellPx = sr.float()
+	kind := sr.int()
+	projection, err := projectionFromParams(kind, sr.floats())
+	if err != nil && sr.err == nil {
+		return nil, err
+	}
+	c.Projection = projection
+	c.CoordinatesMode = CoordinatesMode(sr.int())
+	c.DeduplicateCoordinates = sr.bool()
+	c.StatPercentiles = sr.floats()
+	c.PropertyKeys = sr.strings()
+	c.MaxZoom = sr.int()
+	c.HilbertPresort = sr.bool()
+	c.IndexBackend = IndexBackend(sr.int())
+	c.ClusterIdxSeed = sr.int()
+	c.clusterSeq = sr.int()
+	version := uint64(sr.int())
+	c.generatedIDs = sr.bool()
+	c.ThinOut.MaxPoints = sr.int()
+	c.ThinOut.CellSize = sr.float()
+	c.ThinOut.Seed = int64(sr.int())
+	c.BorderPolicy = BorderPolicy(sr.int())
+	c.CenterDamping.TileSize = sr.int()
+	c.CenterDamping.Pixels = sr.float()
+
+	n := sr.length()
+	c.basePoints = make([]*ClusterPoint, 0, minInt(n, 1<<16))
+	for i := 0; i < n && sr.err == nil; i++ {
+		p := &ClusterPoint{}
+		sr.point(p)
+		c.basePoints = append(c.basePoints, p)
+	}
+	if sr.bool() {
+		c.baseOf = sr.ints()
+	}
+
+	n = sr.length()
+	c.ResultPoints = make([]ClusterPoint, 0, minInt(n, 1<<16))
+	for i := 0; i < n && sr.err == nil; i++ {
+		c.ResultPoints = append(c.ResultPoints, ClusterPoint{})
+		p := &c.ResultPoints[i]
+		sr.point(p)
+		p.Stats = sr.stats()
+		p.topLeafIDs = sr.ints()
+		p.Weight = sr.float()
+		p.Label = sr.string()
+		p.PointCountAbbreviated = sr.string()
+		p.Categories = sr.counts()
+		p.Layers = sr.counts()
+	}
+
+	n = sr.length()
+	if n > 0 {
+		c.columnValues = make(map[string][]float64, minInt(n, 1<<10))
+	}
+	for i := 0; i < n && sr.err == nil; i++ {
+		name := sr.string()
+		c.columnValues[name] = sr.floats()
+	}
+
+	hasOrdering := sr.bool()
+	n = sr.length()
+	if hasOrdering {
+		c.opticsOrdering = make([]OPTICSPoint, 0, minInt(n, 1<<16))
+	}
+	for i := 0; i < n && sr.err == nil; i++ {
+		c.opticsOrdering = append(c.opticsOrdering, OPTICSPoint{ID: sr.int(), Reachability: sr.float(), CoreDistance: sr.float()})
+	}
+
+	c.layerNames = sr.strings()
+	c.layerStarts = sr.ints()
+
+	var points []GeoPoint
+	if sr.bool() && sr.err == nil {
+		sr.err = gob.NewDecoder(br).Decode(&points)
+	}
+	if sr.bool() && sr.err == nil {
+		sr.err = gob.NewDecoder(br).Decode(&c.Boundaries)
+	}
+	if sr.err != nil {
+		return nil, fmt.Errorf("gocluster: can't read snapshot: %v", sr.err)
+	}
+	if err := c.restoreMembers(points); err != nil {
+		return nil, err
+	}
+	if len(c.layerNames) != len(c.layerStarts) {
+		return nil, errors.New("gocluster: snapshot layers are corrupted")
+	}
+	for i, start := range c.layerStarts {
+		if i == 0 && start != 0 || start > c.numInputPoints() || i > 0 && start < c.layerStarts[i-1] {
+			return nil, fmt.Errorf("gocluster: snapshot layer %q is out of range", c.layerNames[i])
+		}
+	}
+	c.restoreRegions()
+	c.baseIndex = c.indexPoints(c.basePoints)
+	c.restoreVersion(version)
+	return c, nil
+}
+
+// restoreRegions sets regions of base points by Boundaries, and of ResultPoints by their first member,
+// the one clusters take the region of
+func (c *Cluster) restoreRegions() {
+	if len(c.Boundaries) == 0 {
+		return
+	}
+	c.assignRegions()
+	for i := range c.ResultPoints {
+		p := &c.ResultPoints[i]
+		if len(p.memberIDs) == 0 {
+			continue
+		}
+		if b := c.basePointOf(p.memberIDs[0]); b >= 0 {
+			p.region = c.basePoints[b].region
+		}
+	}
+}
+
+// restoreMembers validates member ids, sets IncludedPoints from input points and assignment of points
+func (c *Cluster) restoreMembers(points []GeoPoint) error {
+	n := c.numInputPoints()
+	if points != nil && len(points) != n {
+		return fmt.Errorf("gocluster: snapshot has %d points, expected %d", len(points), n)
+	}
+	members := func(p *ClusterPoint) error {
+		if points != nil {
+			p.IncludedPoints = make([]GeoPoint, len(p.memberIDs))
+		}
+		for i, id := range p.memberIDs {
+			if id < 0 || id >= n {
+				return fmt.Errorf("gocluster: snapshot point id %d is out of range", id)
+			}
+			if points != nil {
+				p.IncludedPoints[i] = points[id]
+			}
+		}
+		return nil
+	}
+	for _, p := range c.basePoints {
+		if err := members(p); err != nil {
+			return err
+		}
+	}
+	for _, b := range c.baseOf {
+		//skipped points have no base point
+		if b < -1 || b >= len(c.basePoints) {
+			return fmt.Errorf("gocluster: snapshot base point %d is out of range", b)
+		}
+	}
+	c.assignment = c.newAssignment()
+	for i := range c.ResultPoints {
+		p := &c.ResultPoints[i]
+		if err := members(p); err != nil {
+			return err
+		}
+		for _, id := range p.memberIDs {
+			c.assignment[id] = i
+		}
+		c.computeProperties(p)
+		if len(p.topLeafIDs) == 0 {
+			p.topLeafIDs = nil
+			continue
+		}
+		if points != nil {
+			p.TopLeaves = make([]GeoPoint, len(p.topLeafIDs))
+		}
+		for k, id := range p.topLeafIDs {
+			if id < 0 || id >= n {
+				return fmt.Errorf("gocluster: snapshot point id %d is out of range", id)
+			}
+			if points != nil {
+				p.TopLeaves[k] = points[id]
+			}
+		}
+	}
+	for _, o := range c.opticsOrdering {
+		if o.ID < 0 || o.ID >= len(c.basePoints) {
+			return fmt.Errorf("gocluster: snapshot point id %d is out of range", o.ID)
+		}
+	}
+	return nil
+}
+
+// snapshotWriter writes varints and little endian floats, the first error is kept
+type snapshotWriter struct {
+	w   *bufio.Writer
+	err error
+	buf [binary.MaxVarintLen64]byte
+}
+
+func (sw *snapshotWriter) int(v int) {
+	if sw.err == nil {
+		_, sw.err = sw.w.Write(sw.buf[:binary.PutVarint(sw.buf[:], int64(v))])
+	}
+}
+
+func (sw *snapshotWriter) float(v float64) {
+	if sw.err == nil {
+		binary.LittleEndian.PutUint64(sw.buf[:], math.Float64bits(v))
+		_, sw.err = sw.w.Write(sw.buf[:8])
+	}
+}
+
+func (sw *snapshotWriter) bool(v bool) {
+	if v {
+		sw.int(1)
+	} else {
+		sw.int(0)
+	}
+}
+
+func (sw *snapshotWriter) string(s string) {
+	sw.int(len(s))
+	if sw.err == nil {
+		_, sw.err = sw.w.WriteString(s)
+	}
+}
+
+func (sw *snapshotWriter) ints(values []int) {
+	sw.int(len(values))
+	for _, v := range values {
+		sw.int(v)
+	}
+}
+
+func (sw *snapshotWriter) floats(values []float64) {
+	sw.int(len(values))
+	for _, v := range values {
+		sw.float(v)
+	}
+}
+
+func (sw *snapshotWriter) strings(values []string) {
+	sw.int(len(values))
+	for _, v := range values {
+		sw.string(v)
+	}
+}
+
+func (sw *snapshotWriter) point(p *ClusterPoint) {
+	sw.float(p.X)
+	sw.float(p.Y)
+	sw.int(p.Id)
+	sw.int(p.NumPoints)
+	sw.ints(p.memberIDs)
+	sw.bool(p.visited)
+}
+
+func (sw *snapshotWriter) stats(stats map[string]*NumericStats) {
+	names := make([]string, 0, len(stats))
+	for name := range stats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	sw.int(len(names))
+	for _, name := range names {
+		s := stats[name]
+		sw.string(name)
+		sw.int(s.Count)
+		sw.float(s.Min)
+		sw.float(s.Max)
+		sw.float(s.Sum)
+		sw.float(s.Mean)
+		sw.floats(s.Percentiles)
+	}
+}
+
+func (sw *snapshotWriter) counts(counts map[string]int) {
+	names := make([]string, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	sw.int(len(names))
+	for _, name := range names {
+		sw.string(name)
+		sw.int(counts[name])
+	}
+}
+
+// snapshotReader is the reverse of snapshotWriter, zero values are returned after the first error
+type snapshotReader struct {
+	r   *bufio.Reader
+	err error
+}
+
+func (sr *snapshotReader) int() int {
+	if sr.err != nil {
+		return 0
+	}
+	v, err := binary.ReadVarint(sr.r)
+	sr.err = err
+	return int(v)
+}
+
+func (sr *snapshotReader) length() int {
+	n := sr.int()
+	if sr.err == nil && (n < 0 || n > snapshotMaxLength) {
+		sr.err = fmt.Errorf("invalid length %d", n)
+	}
+	if sr.err != nil {
+		return 0
+	}
+	return n
+}
+
+func (sr *snapshotReader) float() float64 {
+	if sr.err != nil {
+		return 0
+	}
+	var buf [8]byte
+	_, sr.err = io.ReadFull(sr.r, buf[:])
+	return math.Float64frombits(binary.LittleEndian.Uint64(buf[:]))
+}
+
+func (sr *snapshotReader) bool() bool {
+	return sr.int() != 0
+}
+
+func (sr *snapshotReader) string() string {
+	n := sr.length()
+	if sr.err != nil {
+		return ""
+	}
+	//the buffer grows as bytes arrive, so broken length fails at the end of data instead of allocating it
+	var buf bytes.Buffer
+	if _, err := io.CopyN(&buf, sr.r, int64(n)); err != nil {
+		sr.err = err
+		return ""
+	}
+	return buf.String()
+}
+
+// ints, floats and strings grow slices while reading, so broken length doesn't allocate too much at once
+func (sr *snapshotReader) ints() []int {
+	n := sr.length()
+	values := make([]int, 0, minInt(n, 1<<16))
+	for i := 0; i < n && sr.err == nil; i++ {
+		values = append(values, sr.int())
+	}
+	return values
+}
+
+func (sr *snapshotReader) floats() []float64 {
+	n := sr.length()
+	if n == 0 {
+		return nil
+	}
+	values := make([]float64, 0, minInt(n, 1<<16))
+	for i := 0; i < n && sr.err == nil; i++ {
+		values = append(values, sr.float())
+	}
+	return values
+}
+
+func (sr *snapshotReader) strings() []string {
+	n := sr.length()
+	if n == 0 {
+		return nil
+	}
+	values := make([]string, 0, minInt(n, 1<<10))
+	for i := 0; i < n && sr.err == nil; i++ {
+		values = append(values, sr.string())
+	}
+	return values
+}
+
+func (sr *snapshotReader) point(p *ClusterPoint) {
+	p.X = sr.float()
+	p.Y = sr.float()
+	p.Id = sr.int()
+	p.NumPoints = sr.int()
+	p.memberIDs = sr.ints()
+	p.visited = sr.bool()
+}
+
+func (sr *snapshotReader) stats() map[string]*NumericStats {
+	n := sr.length()
+	if n == 0 {
+		return nil
+	}
+	stats := make(map[string]*NumericStats, minInt(n, 1<<10))
+	for i := 0; i < n && sr.err == nil; i++ {
+		name := sr.string()
+		stats[name] = &NumericStats{
+			Count:       sr.int(),
+			Min:         sr.float(),
+			Max:         sr.float(),
+			Sum:         sr.float(),
+			Mean:        sr.float(),
+			Percentiles: sr.floats(),
+		}
+	}
+	return stats
+}
+
+func (sr *snapshotReader) counts() map[string]int {
+	n := sr.length()
+	if n == 0 {
+		return nil
+	}
+	counts := make(map[string]int, minInt(n, 1<<10))
+	for i := 0; i < n && sr.err == nil; i++ {
+		name := sr.string()
+		counts[name] = sr.int()
+	}
+	return counts
+}
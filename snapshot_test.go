@@ -0,0 +1,164 @@
+package cluster
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+func TestSnapshotReaderBrokenLengths(t *testing.T) {
+	//each stream claims 1<<30 elements and ends right after the length
+	readers := map[string]func(sr *snapshotReader){
+		"string":  func(sr *snapshotReader) { sr.string() },
+		"strings": func(sr *snapshotReader) { sr.strings() },
+		"ints":    func(sr *snapshotReader) { sr.ints() },
+		"floats":  func(sr *snapshotReader) { sr.floats() },
+		"stats":   func(sr *snapshotReader) { sr.stats() },
+		"counts":  func(sr *snapshotReader) { sr.counts() },
+	}
+	for name, read := range readers {
+		buf := make([]byte, binary.MaxVarintLen64)
+		data := buf[:binary.PutVarint(buf, 1<<30)]
+		sr := &snapshotReader{r: bufio.NewReader(bytes.NewReader(data))}
+
+		var before, after runtime.MemStats
+		runtime.ReadMemStats(&before)
+		read(sr)
+		runtime.ReadMemStats(&after)
+		if sr.err == nil {
+			t.Errorf("%s: expected error of truncated data", name)
+		}
+		if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 4<<20 {
+			t.Errorf("%s: allocated %d bytes for truncated data", name, allocated)
+		}
+	}
+}
+
+// roundTrip writes the snapshot of c and reads it back
+func roundTrip(t *testing.T, c *Cluster) *Cluster {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := c.WriteSnapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+	restored, err := ReadSnapshot(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return restored
+}
+
+// checkSameResult fails if restored has other ResultPoints or members than c
+func checkSameResult(t *testing.T, c, restored *Cluster) {
+	t.Helper()
+	if len(restored.ResultPoints) != len(c.ResultPoints) {
+		t.Fatalf("restored %d result points, want %d", len(restored.ResultPoints), len(c.ResultPoints))
+	}
+	for i := range c.ResultPoints {
+		want, got := &c.ResultPoints[i], &restored.ResultPoints[i]
+		if got.Id != want.Id || got.X != want.X || got.Y != want.Y || got.NumPoints != want.NumPoints {
+			t.Fatalf("result point %d is %d at %v,%v of %d points, want %d at %v,%v of %d points",
+				i, got.Id, got.X, got.Y, got.NumPoints, want.Id, want.X, want.Y, want.NumPoints)
+		}
+		if !reflect.DeepEqual(got.memberIDs, want.memberIDs) || got.region != want.region {
+			t.Fatalf("result point %d has other members or region", i)
+		}
+		wantLeaves, err := c.Leaves(want.Id, 0, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		gotLeaves, err := restored.Leaves(got.Id, 0, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(gotLeaves, wantLeaves) {
+			t.Fatalf("result point %d has other leaves", i)
+		}
+	}
+	if restored.Version() != c.Version() {
+		t.Fatalf("restored version %d, want %d", restored.Version(), c.Version())
+	}
+	checkAssignments(t, restored, totalPoints(c.ResultPoints))
+}
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	c, err := NewClusterForZoom(4, 256, 60)
+	if err != nil {
+		t.Fatal(err)
+	}
+	points := loadPlaces(t)
+	if err := c.ClusterPoints(points); err != nil {
+		t.Fatal(err)
+	}
+	checkSameResult(t, c, roundTrip(t, c))
+}
+
+func TestSnapshotRoundTripSpilled(t *testing.T) {
+	c, err := NewClusterForZoom(2, 256, 80)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.SpillThreshold = 5
+	defer c.Close()
+	if err := c.ClusterPoints(randomPoints(2000, 1, -20, -20, 20, 20)); err != nil {
+		t.Fatal(err)
+	}
+	spilled := 0
+	for _, cp := range c.ResultPoints {
+		if cp.spill != nil {
+			spilled++
+		}
+	}
+	if spilled == 0 {
+		t.Fatal("no cluster is spilled")
+	}
+	restored := roundTrip(t, c)
+	checkSameResult(t, c, restored)
+	//members are restored from input points, the spill file of c is not needed
+	c.Close()
+	for _, cp := range restored.ResultPoints {
+		if leaves, err := restored.Leaves(cp.Id, 0, 0); err != nil || len(leaves) != cp.NumPoints {
+			t.Fatalf("cluster %d has %d leaves, want %d: %v", cp.Id, len(leaves), cp.NumPoints, err)
+		}
+	}
+}
+
+func TestSnapshotRoundTripBoundaries(t *testing.T) {
+	c, err := NewClusterForZoom(2, 256, 120)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Boundaries = []Polygon{
+		{ID: "west", Ring: []GeoCoordinates{{Lon: -20, Lat: -20}, {Lon: 0, Lat: -20}, {Lon: 0, Lat: 20}, {Lon: -20, Lat: 20}}},
+		{ID: "east", Ring: []GeoCoordinates{{Lon: 0, Lat: -20}, {Lon: 20, Lat: -20}, {Lon: 20, Lat: 20}, {Lon: 0, Lat: 20}},
+			Properties: map[string]interface{}{"name": "east"}},
+	}
+	if err := c.ClusterPoints(randomPoints(1000, 2, -20, -20, 20, 20)); err != nil {
+		t.Fatal(err)
+	}
+	restored := roundTrip(t, c)
+	if !reflect.DeepEqual(restored.Boundaries, c.Boundaries) {
+		t.Fatalf("restored boundaries %v, want %v", restored.Boundaries, c.Boundaries)
+	}
+	for i, p := range c.basePoints {
+		if restored.basePoints[i].region != p.region {
+			t.Fatalf("base point %d is in region %d, want %d", i, restored.basePoints[i].region, p.region)
+		}
+	}
+	checkSameResult(t, c, restored)
+
+	//clusters clustered again after restore still don't cross boundaries
+	if err := restored.ReclusterWithEpsilon(restored.Epsilon * 2); err != nil {
+		t.Fatal(err)
+	}
+	for _, cp := range restored.ResultPoints {
+		for _, id := range cp.memberIDs {
+			if region := restored.basePoints[restored.basePointOf(id)].region; region != cp.region {
+				t.Fatalf("cluster %d of region %d has member %d of region %d", cp.Id, cp.region, id, region)
+			}
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package cluster
+
+import "math"
+
+// Spiderfy layout parameters in pixels, the same as Leaflet.markercluster uses
+const (
+	spiderCircleSwitchover   = 9
+	spiderCircleSeparation   = 25.0
+	spiderSpiralSeparation   = 28.0
+	spiderSpiralLengthStart  = 11.0
+	spiderSpiralLengthFactor = 5.0
+)
+
+// SpiderPoint is the member of the cluster with its display coordinates
+// Original coordinates are from GetCoordinates, Display coordinates are spread around cluster center
+type SpiderPoint struct {
+	Point    GeoPoint
+	Original GeoCoordinates
+	Display  GeoCoordinates
+}
+
+// Spiderfy spreads members of the cluster around the cluster center, so all of them could be displayed
+// It's useful for clusters which members share (nearly) the same coordinates and could not be expanded by zooming in
+// Up to 8 members are placed on the circle, more members are placed on the spiral.
+// Offsets are calculated in pixels for zoom and tileSize, so markers don't overlap at that zoom.
+// cp is expected to be in Lon/Lat coordinates, as returned by AllClusters
+func Spiderfy(cp ClusterPoint, zoom, tileSize int) []SpiderPoint {
+	count := len(cp.IncludedPoints)
+	if count == 0 {
+		return nil
+	}
+	cx, cy := LonLatToPixel(GeoCoordinates{Lon: cp.X, Lat: cp.Y}, zoom, tileSize)
+
+	var offsets [][2]float64
+	if count < spiderCircleSwitchover {
+		offsets = spiderCircle(count)
+	} else {
+		offsets = spiderSpiral(count)
+	}
+
+	result := make([]SpiderPoint, count)
+	for i, p := range cp.IncludedPoints {
+		result[i] = SpiderPoint{
+			Point:    p,
+			Original: p.GetCoordinates(),
+			Display:  PixelToLonLat(cx+offsets[i][0], cy+offsets[i][1], zoom, tileSize),
+		}
+	}
+	return result
+}
+
+// MembersCoincide returns true if all members of the cluster are within pixels distance from each other at zoom,
+// so zooming in would not split the cluster, and it should be spiderfied instead
+func MembersCoincide(cp ClusterPoint, zoom, tileSize int, pixels float64) bool {
+	if len(cp.IncludedPoints) < 2 {
+		return false
+	}
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for _, p := range cp.IncludedPoints {
+		x, y := LonLatToPixel(p.GetCoordinates(), zoom, tileSize)
+		minX, maxX = math.Min(minX, x), math.Max(maxX, x)
+		minY, maxY = math.Min(minY, y), math.Max(maxY, y)
+	}
+	return math.Hypot(maxX-minX, maxY-minY) <= pixels
+}
+
+// spiderCircle returns pixel offsets for markers on the circle
+func spiderCircle(count int) [][2]float64 {
+	circumference := spiderCircleSeparation * float64(2+count)
+	legLength := circumference / (2 * math.Pi)
+	angleStep := 2 * math.Pi / float64(count)
+
+	result := make([][2]float64, count)
+	for i := range result {
+		angle := float64(i) * angleStep
+		result[i] = [2]float64{legLength * math.Cos(angle), legLength * math.Sin(angle)}
+	}
+	return result
+}
+
+// spiderSpiral returns pixel offsets for markers on the spiral
+func spiderSpiral(count int) [][2]float64 {
+	legLength := spiderSpiralLengthStart
+	lengthFactor := spiderSpiralLengthFactor * 2 * math.Pi
+	angle := 0.0
+
+	result := make([][2]float64, count)
+	for i := count - 1; i >= 0; i-- {
+		angle += spiderSpiralSeparation/legLength + float64(i)*0.0005
+		result[i] = [2]float64{legLength * math.Cos(angle), legLength * math.Sin(angle)}
+		legLength += lengthFactor / angle
+	}
+	return result
+}
@@ -0,0 +1,65 @@
+package cluster
+
+import (
+	"math"
+	"testing"
+)
+
+// colocated returns the cluster of n members at the same coordinates
+func colocated(n int, c GeoCoordinates) ClusterPoint {
+	cp := ClusterPoint{X: c.Lon, Y: c.Lat, NumPoints: n}
+	for i := 0; i < n; i++ {
+		cp.IncludedPoints = append(cp.IncludedPoints, &Feature{ID: i, Coordinates: c})
+	}
+	return cp
+}
+
+func TestSpiderfy(t *testing.T) {
+	center := GeoCoordinates{Lon: 13.4, Lat: 52.5}
+	//members are on the circle up to 8 of them and on the spiral above
+	for _, n := range []int{2, 8, 30} {
+		cp := colocated(n, center)
+		spider := Spiderfy(cp, 15, 256)
+		if len(spider) != n {
+			t.Fatalf("%d members are spiderfied to %d points", n, len(spider))
+		}
+		cx, cy := LonLatToPixel(center, 15, 256)
+		for i, s := range spider {
+			if s.Point != cp.IncludedPoints[i] || s.Original != center {
+				t.Fatalf("spider point %d is of other member", i)
+			}
+			x, y := LonLatToPixel(s.Display, 15, 256)
+			if d := math.Hypot(x-cx, y-cy); d < 10 || d > 200 {
+				t.Fatalf("%d members: member %d is %v pixels from the center", n, i, d)
+			}
+			//markers don't overlap
+			for j := 0; j < i; j++ {
+				ox, oy := LonLatToPixel(spider[j].Display, 15, 256)
+				if d := math.Hypot(x-ox, y-oy); d < 20 {
+					t.Fatalf("%d members: members %d and %d are %v pixels apart", n, j, i, d)
+				}
+			}
+		}
+	}
+	if spider := Spiderfy(ClusterPoint{X: 1, Y: 2}, 15, 256); spider != nil {
+		t.Fatalf("cluster without members is spiderfied to %v", spider)
+	}
+}
+
+func TestMembersCoincide(t *testing.T) {
+	center := GeoCoordinates{Lon: 13.4, Lat: 52.5}
+	cp := colocated(5, center)
+	if !MembersCoincide(cp, 18, 256, 1) {
+		t.Fatal("members at the same coordinates don't coincide")
+	}
+	cp.IncludedPoints = append(cp.IncludedPoints, &Feature{Coordinates: GeoCoordinates{Lon: 13.41, Lat: 52.5}})
+	if MembersCoincide(cp, 18, 256, 1) {
+		t.Fatal("members 700m apart coincide at zoom 18")
+	}
+	if !MembersCoincide(cp, 2, 256, 1) {
+		t.Fatal("members 700m apart don't coincide at zoom 2")
+	}
+	if MembersCoincide(colocated(1, center), 18, 256, 1) {
+		t.Fatal("single member coincides")
+	}
+}
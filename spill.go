@@ -0,0 +1,108 @@
+package cluster
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"io/ioutil"
+	"os"
+)
+
+// spillStore is the temporary file members of large clusters are written to, see Cluster.SpillThreshold
+// Segments are only appended while clustering, so they are read concurrently by Leaves.
+type spillStore struct {
+	file *os.File
+	size int64
+}
+
+// spillRef is the position of gob encoded IncludedPoints of the cluster in the spill file
+type spillRef struct {
+	offset, size int64
+}
+
+// spillMembers writes IncludedPoints of the cluster larger than SpillThreshold to the spill file and drops them
+// Members are kept in memory if they could not be written, e.g. their types are not registered with gob.Register.
+func (c *Cluster) spillMembers(cp *ClusterPoint) {
+	cp.spill = nil
+	if c.SpillThreshold <= 0 || len(cp.IncludedPoints) <= c.SpillThreshold {
+		return
+	}
+	if c.spill == nil {
+		file, err := ioutil.TempFile(c.SpillDir, "gocluster-spill-")
+		if err != nil {
+			return
+		}
+		c.spill = &spillStore{file: file}
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(cp.IncludedPoints); err != nil {
+		return
+	}
+	if _, err := c.spill.file.WriteAt(buf.Bytes(), c.spill.size); err != nil {
+		return
+	}
+	cp.spill = &spillRef{offset: c.spill.size, size: int64(buf.Len())}
+	c.spill.size += int64(buf.Len())
+	cp.IncludedPoints = nil
+}
+
+// resetSpill drops all spilled members before points are clustered from scratch
+func (c *Cluster) resetSpill() {
+	if c.spill != nil && c.spill.file.Truncate(0) == nil {
+		c.spill.size = 0
+	}
+}
+
+// members returns IncludedPoints of the result point, reading them from the spill file if they are spilled
+func (c *Cluster) members(cp *ClusterPoint) ([]GeoPoint, error) {
+	if cp.spill == nil {
+		return cp.IncludedPoints, nil
+	}
+	if c.spill == nil {
+		return nil, fmt.Errorf("gocluster: spill file of cluster %d is removed", cp.Id)
+	}
+	data := make([]byte, cp.spill.size)
+	if _, err := c.spill.file.ReadAt(data, cp.spill.offset); err != nil {
+		return nil, fmt.Errorf("gocluster: can't read spilled members of cluster %d: %v", cp.Id, err)
+	}
+	var points []GeoPoint
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&points); err != nil {
+		return nil, fmt.Errorf("gocluster: can't decode spilled members of cluster %d: %v", cp.Id, err)
+	}
+	return points, nil
+}
+
+// Leaves returns members of the cluster or single point with id, up to limit of them after skipping offset ones,
+// all of them if limit is 0. Members of spilled clusters are read from the spill file, see SpillThreshold.
+func (c *Cluster) Leaves(id, limit, offset int) ([]GeoPoint, error) {
+	if !c.Built() {
+		return nil, notBuilt("Leaves")
+	}
+	i, ok := c.resultByID(id)
+	if !ok {
+		return nil, fmt.Errorf("gocluster: unknown cluster id %d", id)
+	}
+	points, err := c.members(&c.ResultPoints[i])
+	if err != nil {
+		return nil, err
+	}
+	if offset >= len(points) {
+		return nil, nil
+	}
+	points = points[offset:]
+	if limit > 0 && limit < len(points) {
+		points = points[:limit]
+	}
+	return points, nil
+}
+
+// Close removes the spill file, members of spilled clusters could not be read after it
+func (c *Cluster) Close() error {
+	if c.spill == nil {
+		return nil
+	}
+	s := c.spill
+	c.spill = nil
+	s.file.Close()
+	return os.Remove(s.file.Name())
+}
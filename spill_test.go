@@ -0,0 +1,105 @@
+package cluster
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestSpillThreshold(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gocluster-spill-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	points := randomPoints(2000, 49, -20, -20, 20, 20)
+	memory, err := NewClusterForZoom(2, 256, 80)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := memory.ClusterPoints(points); err != nil {
+		t.Fatal(err)
+	}
+	c, err := NewClusterForZoom(2, 256, 80)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.SpillThreshold = 5
+	c.SpillDir = dir
+	if err := c.ClusterPoints(points); err != nil {
+		t.Fatal(err)
+	}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil || len(files) != 1 {
+		t.Fatalf("%d spill files in SpillDir: %v", len(files), err)
+	}
+
+	//spilled members are read back the same as kept in memory
+	for i, cp := range c.ResultPoints {
+		spilled := cp.NumPoints > c.SpillThreshold
+		if spilled != (cp.spill != nil) || spilled != (cp.IncludedPoints == nil) {
+			t.Fatalf("cluster %d of %d points is spilled %v", cp.Id, cp.NumPoints, cp.spill != nil)
+		}
+		leaves, err := c.Leaves(cp.Id, 0, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(leaves, memory.ResultPoints[i].IncludedPoints) {
+			t.Fatalf("cluster %d has other leaves when spilled", cp.Id)
+		}
+		if page, err := c.Leaves(cp.Id, 2, 3); spilled && (err != nil || !reflect.DeepEqual(page, leaves[3:5])) {
+			t.Fatalf("cluster %d has page of leaves %v: %v", cp.Id, page, err)
+		}
+	}
+
+	//clustering again starts the file over
+	size := files[0].Size()
+	if err := c.ClusterPoints(points[:1000]); err != nil {
+		t.Fatal(err)
+	}
+	if files, err = ioutil.ReadDir(dir); err != nil || len(files) != 1 || files[0].Size() >= size {
+		t.Fatalf("spill files after clustering again %v: %v", files, err)
+	}
+	var spilled *ClusterPoint
+	for i := range c.ResultPoints {
+		if c.ResultPoints[i].spill != nil {
+			spilled = &c.ResultPoints[i]
+		}
+	}
+	if spilled == nil {
+		t.Fatal("no cluster is spilled")
+	}
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if files, _ := ioutil.ReadDir(dir); len(files) != 0 {
+		t.Fatalf("%d spill files after Close", len(files))
+	}
+	if _, err := c.Leaves(spilled.Id, 0, 0); err == nil {
+		t.Fatal("expected error of spilled members after Close")
+	}
+}
+
+func TestSpillUnregisteredType(t *testing.T) {
+	//members gob can't encode are kept in memory
+	var points []GeoPoint
+	for _, p := range randomPoints(100, 50, 0, 0, 1, 1) {
+		points = append(points, bareGeoPoint(p.GetCoordinates()))
+	}
+	c, err := NewClusterForZoom(2, 256, 80)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.SpillThreshold = 5
+	defer c.Close()
+	if err := c.ClusterPoints(points); err != nil {
+		t.Fatal(err)
+	}
+	for _, cp := range c.ResultPoints {
+		if cp.spill != nil || len(cp.IncludedPoints) != cp.NumPoints {
+			t.Fatalf("cluster %d of unregistered type is spilled", cp.Id)
+		}
+	}
+}
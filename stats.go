@@ -0,0 +1,149 @@
+package cluster
+
+import (
+	"math"
+	"sort"
+)
+
+// NumericAccessor returns numeric value of the point, ok is false if the point has no value
+type NumericAccessor func(p GeoPoint) (value float64, ok bool)
+
+// NumericStats is the aggregation of numeric values of cluster members
+// Percentiles are in the same order as Cluster.StatPercentiles
+type NumericStats struct {
+	Count       int
+	Min         float64
+	Max         float64
+	Sum         float64
+	Mean        float64
+	Percentiles []float64 `json:",omitempty"`
+}
+
+type numericStat struct {
+	name     string
+	accessor NumericAccessor
+}
+
+// AddNumericStat registers numeric accessor, stats for it are stored in ClusterPoint.Stats by name
+// Should be called before ClusterPoints
+func (c *Cluster) AddNumericStat(name string, accessor NumericAccessor) {
+	c.numericStats = append(c.numericStats, numericStat{name: name, accessor: accessor})
+}
+
+// PropertyAccessor returns NumericAccessor for numeric property of GeoPointWithProperties points, e.g. *Feature
+func PropertyAccessor(key string) NumericAccessor {
+	return func(p GeoPoint) (float64, bool) {
+		return toFloat(pointProperties(p)[key])
+	}
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch t := v.(type) {
+	case float64:
+		return t, true
+	case float32:
+		return float64(t), true
+	case int:
+		return float64(t), true
+	case int32:
+		return float64(t), true
+	case int64:
+		return float64(t), true
+	case uint:
+		return float64(t), true
+	case uint32:
+		return float64(t), true
+	case uint64:
+		return float64(t), true
+	}
+	return 0, false
+}
+
+// computeStats fills Stats of the cluster from its members
+// Property columns of ClusterColumns are aggregated by member ids
+func (c *Cluster) computeStats(cp *ClusterPoint) {
+	c.computeWeight(cp)
+	if len(c.numericStats) == 0 && len(c.columnValues) == 0 {
+		return
+	}
+	cp.Stats = make(map[string]*NumericStats, len(c.numericStats)+len(c.columnValues))
+	values := make([]float64, 0, len(cp.memberIDs))
+	for _, stat := range c.numericStats {
+		values = values[:0]
+		for _, p := range cp.IncludedPoints {
+			if v, ok := stat.accessor(p); ok {
+				values = append(values, v)
+			}
+		}
+		cp.Stats[stat.name] = newNumericStats(values, c.StatPercentiles)
+	}
+	for name, column := range c.columnValues {
+		values = values[:0]
+		for _, id := range cp.memberIDs {
+			if v := column[id]; !math.IsNaN(v) {
+				values = append(values, v)
+			}
+		}
+		cp.Stats[name] = newNumericStats(values, c.StatPercentiles)
+	}
+}
+
+// computeWeight sets Weight of the cluster to the sum of Weight or WeightColumn of members, values missing are zero
+func (c *Cluster) computeWeight(cp *ClusterPoint) {
+	column, ok := c.columnValues[c.WeightColumn]
+	switch {
+	case ok:
+		cp.Weight = 0
+		for _, id := range cp.memberIDs {
+			if v := column[id]; !math.IsNaN(v) {
+				cp.Weight += v
+			}
+		}
+	case c.Weight != nil:
+		cp.Weight = 0
+		for _, p := range cp.IncludedPoints {
+			if v, ok := c.Weight(p); ok {
+				cp.Weight += v
+			}
+		}
+	default:
+		cp.Weight = float64(cp.NumPoints)
+	}
+}
+
+func newNumericStats(values []float64, percentiles []float64) *NumericStats {
+	s := &NumericStats{Count: len(values)}
+	if len(values) == 0 {
+		return s
+	}
+	s.Min, s.Max = math.Inf(1), math.Inf(-1)
+	for _, v := range values {
+		s.Sum += v
+		s.Min = math.Min(s.Min, v)
+		s.Max = math.Max(s.Max, v)
+	}
+	s.Mean = s.Sum / float64(len(values))
+
+	if len(percentiles) > 0 {
+		sort.Float64s(values)
+		s.Percentiles = make([]float64, len(percentiles))
+		for i, p := range percentiles {
+			s.Percentiles[i] = percentile(values, p)
+		}
+	}
+	return s
+}
+
+// percentile returns linear interpolated value of p in [0..1] range for sorted values
+func percentile(sorted []float64, p float64) float64 {
+	if p <= 0 {
+		return sorted[0]
+	}
+	if p >= 1 {
+		return sorted[len(sorted)-1]
+	}
+	pos := p * float64(len(sorted)-1)
+	lower := int(math.Floor(pos))
+	upper := int(math.Ceil(pos))
+	return sorted[lower] + (sorted[upper]-sorted[lower])*(pos-float64(lower))
+}
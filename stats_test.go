@@ -0,0 +1,133 @@
+package cluster
+
+import (
+	"math"
+	"sort"
+	"testing"
+)
+
+func TestNumericStats(t *testing.T) {
+	points := randomPoints(2000, 3, -30, -30, 30, 30)
+	//every tenth point has no value
+	for i := 0; i < len(points); i += 10 {
+		delete(points[i].(*Feature).Properties, "n")
+	}
+	c, err := NewClusterForZoom(3, 256, 60)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.AddNumericStat("n", PropertyAccessor("n"))
+	c.StatPercentiles = []float64{0, 0.5, 1}
+	if err := c.ClusterPoints(points); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, cp := range c.ResultPoints {
+		var values []float64
+		for _, p := range cp.IncludedPoints {
+			if v, ok := p.(*Feature).Properties["n"].(float64); ok {
+				values = append(values, v)
+			}
+		}
+		s := cp.Stats["n"]
+		if s == nil || s.Count != len(values) {
+			t.Fatalf("cluster %d has stats %+v of %d values", cp.Id, s, len(values))
+		}
+		if len(values) == 0 {
+			continue
+		}
+		sort.Float64s(values)
+		sum := 0.0
+		for _, v := range values {
+			sum += v
+		}
+		median := values[len(values)/2]
+		if len(values)%2 == 0 {
+			median = (values[len(values)/2-1] + values[len(values)/2]) / 2
+		}
+		if s.Min != values[0] || s.Max != values[len(values)-1] || s.Sum != sum ||
+			math.Abs(s.Mean-sum/float64(len(values))) > 1e-9 {
+			t.Fatalf("cluster %d has stats %+v", cp.Id, s)
+		}
+		if len(s.Percentiles) != 3 || s.Percentiles[0] != s.Min || s.Percentiles[1] != median || s.Percentiles[2] != s.Max {
+			t.Fatalf("cluster %d has percentiles %v, want median %v", cp.Id, s.Percentiles, median)
+		}
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	sorted := []float64{1, 2, 4, 8}
+	tests := map[float64]float64{-1: 1, 0: 1, 0.5: 3, 0.9: 6.8, 1: 8, 2: 8}
+	for p, want := range tests {
+		if got := percentile(sorted, p); math.Abs(got-want) > 1e-9 {
+			t.Errorf("percentile %v is %v, want %v", p, got, want)
+		}
+	}
+}
+
+func TestWeight(t *testing.T) {
+	points := randomPoints(1000, 30, -30, -30, 30, 30)
+	//points without value weigh nothing
+	for i := 0; i < len(points); i += 4 {
+		delete(points[i].(*Feature).Properties, "n")
+	}
+	c, err := NewClusterForZoom(3, 256, 60)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.ClusterPoints(points); err != nil {
+		t.Fatal(err)
+	}
+	for _, cp := range c.ResultPoints {
+		if cp.Weight != float64(cp.NumPoints) {
+			t.Fatalf("cluster %d of %d points has default weight %v", cp.Id, cp.NumPoints, cp.Weight)
+		}
+	}
+
+	c.Weight = PropertyAccessor("n")
+	if err := c.ClusterPoints(points); err != nil {
+		t.Fatal(err)
+	}
+	for _, cp := range c.ResultPoints {
+		want := 0.0
+		for _, p := range cp.IncludedPoints {
+			if v, ok := p.(*Feature).Properties["n"].(float64); ok {
+				want += v
+			}
+		}
+		if cp.Weight != want {
+			t.Fatalf("cluster %d has weight %v, want %v", cp.Id, cp.Weight, want)
+		}
+	}
+
+	//columns are weighed by WeightColumn, null values weigh nothing
+	lons, lats := make(Float64Values, len(points)), make(Float64Values, len(points))
+	values := make(Float64Values, len(points))
+	for i, p := range points {
+		lons[i], lats[i] = p.GetCoordinates().Lon, p.GetCoordinates().Lat
+		values[i] = math.NaN()
+		if v, ok := p.(*Feature).Properties["n"].(float64); ok {
+			values[i] = v
+		}
+	}
+	columns, err := NewClusterForZoom(3, 256, 60)
+	if err != nil {
+		t.Fatal(err)
+	}
+	columns.WeightColumn = "n"
+	if err := columns.ClusterColumns(ColumnBatch{Lon: lons, Lat: lats, Properties: map[string]Float64Column{"n": nullNaN(values)}}); err != nil {
+		t.Fatal(err)
+	}
+	for i := range c.ResultPoints {
+		if columns.ResultPoints[i].Weight != c.ResultPoints[i].Weight {
+			t.Fatalf("cluster %d of columns has weight %v, want %v", i, columns.ResultPoints[i].Weight, c.ResultPoints[i].Weight)
+		}
+	}
+}
+
+// nullNaN is Float64Column with NaN values as nulls
+type nullNaN Float64Values
+
+func (v nullNaN) Len() int            { return len(v) }
+func (v nullNaN) Value(i int) float64 { return v[i] }
+func (v nullNaN) IsNull(i int) bool   { return math.IsNaN(v[i]) }
@@ -0,0 +1,37 @@
+package cluster
+
+import "testing"
+
+func TestClusterPointsStream(t *testing.T) {
+	points := loadPlaces(t)
+	c, err := NewClusterForZoom(3, 256, 40)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var streamed []ClusterPoint
+	for cp := range c.ClusterPointsStream(points) {
+		streamed = append(streamed, cp)
+	}
+	if err := c.StreamErr(); err != nil {
+		t.Fatal(err)
+	}
+	if len(streamed) != len(c.ResultPoints) || totalPoints(streamed) != len(points) {
+		t.Fatalf("streamed %d points of %d, want %d of %d", len(streamed), totalPoints(streamed), len(c.ResultPoints), len(points))
+	}
+}
+
+func TestClusterPointsStreamErr(t *testing.T) {
+	c, err := NewClusterForZoom(3, 256, 40)
+	if err != nil {
+		t.Fatal(err)
+	}
+	//Boundaries are not supported by StrategyMeanShift
+	c.Strategy = StrategyMeanShift
+	c.Boundaries = []Polygon{{Ring: []GeoCoordinates{{Lon: 0, Lat: 0}, {Lon: 1, Lat: 0}, {Lon: 1, Lat: 1}}}}
+	for range c.ClusterPointsStream(loadPlaces(t)) {
+		t.Fatal("points are streamed with invalid options")
+	}
+	if c.StreamErr() == nil {
+		t.Fatal("expected error of invalid options")
+	}
+}
@@ -0,0 +1,331 @@
+package cluster
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// GeoPointWithTime is the point with timestamp, e.g. time of the event, see Cluster.GetClustersBetween
+type GeoPointWithTime interface {
+	GeoPoint
+	GetTime() time.Time
+}
+
+// TimeAccessor returns timestamp of the point, ok is false if the point has none
+type TimeAccessor func(p GeoPoint) (t time.Time, ok bool)
+
+// PropertyTime returns TimeAccessor for property of GeoPointWithProperties points, e.g. *Feature:
+// RFC 3339 strings and numbers of seconds since Unix epoch, values of other types are no timestamp
+func PropertyTime(key string) TimeAccessor {
+	return func(p GeoPoint) (time.Time, bool) {
+		v := pointProperties(p)[key]
+		if s, ok := v.(string); ok {
+			t, err := time.Parse(time.RFC3339Nano, s)
+			return t, err == nil
+		}
+		seconds, ok := toFloat(v)
+		if !ok || math.IsNaN(seconds) || math.IsInf(seconds, 0) {
+			return time.Time{}, false
+		}
+		whole, fraction := math.Modf(seconds)
+		return time.Unix(int64(whole), int64(fraction*1e9)), true
+	}
+}
+
+// pointTime returns timestamp of the point by Time, or by GetTime of GeoPointWithTime if Time is nil
+func (c *Cluster) pointTime(p GeoPoint) (time.Time, bool) {
+	if c.Time != nil {
+		return c.Time(p)
+	}
+	if p, ok := p.(GeoPointWithTime); ok {
+		return p.GetTime(), true
+	}
+	return time.Time{}, false
+}
+
+// unixSeconds returns seconds since Unix epoch, the time axis of temporalIndex, it's precise to a microsecond
+func unixSeconds(t time.Time) float64 {
+	return float64(t.Unix()) + float64(t.Nanosecond())/1e9
+}
+
+// temporalIndex is the KD-tree of input points with timestamps by projected coordinates and time
+// Axes cycle through x, y and time, so the time window prunes the tree like the box does and
+// points outside of the window are never visited.
+type temporalIndex struct {
+	nodeSize int
+	ids      []uint32   //input ids
+	coords   []float64  //x, y and time of each point
+	points   []GeoPoint //input points by position in the tree
+}
+
+// newTemporalIndex indexes members of base points which have timestamps
+func (c *Cluster) newTemporalIndex() *temporalIndex {
+	ti := &temporalIndex{nodeSize: maxInt(c.NodeSize, 1)}
+	for _, p := range c.basePoints {
+		//points of ClusterColumns have no members and no timestamps
+		for k := 0; k < len(p.IncludedPoints) && k < len(p.memberIDs); k++ {
+			t, ok := c.pointTime(p.IncludedPoints[k])
+			if !ok {
+				continue
+			}
+			ti.ids = append(ti.ids, uint32(p.memberIDs[k]))
+			ti.coords = append(ti.coords, p.X, p.Y, unixSeconds(t))
+			ti.points = append(ti.points, p.IncludedPoints[k])
+		}
+	}
+	ti.sort(0, len(ti.ids)-1, 0)
+	return ti
+}
+
+// Bytes returns approximate memory taken by the index
+func (ti *temporalIndex) Bytes() int {
+	return len(ti.ids)*4 + len(ti.coords)*8 + len(ti.points)*16
+}
+
+// Range returns positions in the tree of points inside the box and the time window, bounds are inclusive
+func (ti *temporalIndex) Range(minX, minY, maxX, maxY, from, to float64) []int {
+	if len(ti.ids) == 0 {
+		return nil
+	}
+	min := [3]float64{minX, minY, from}
+	max := [3]float64{maxX, maxY, to}
+	inside := func(i int) bool {
+		for axis := 0; axis < 3; axis++ {
+			if v := ti.coords[3*i+axis]; v < min[axis] || v > max[axis] {
+				return false
+			}
+		}
+		return true
+	}
+	stack := []int{0, len(ti.ids) - 1, 0}
+	var result []int
+
+	for len(stack) > 0 {
+		axis := stack[len(stack)-1]
+		right := stack[len(stack)-2]
+		left := stack[len(stack)-3]
+		stack = stack[:len(stack)-3]
+
+		if right-left <= ti.nodeSize {
+			for i := left; i <= right; i++ {
+				if inside(i) {
+					result = append(result, i)
+				}
+			}
+			continue
+		}
+
+		m := (left + right) / 2
+		if inside(m) {
+			result = append(result, m)
+		}
+
+		v := ti.coords[3*m+axis]
+		nextAxis := (axis + 1) % 3
+		if min[axis] <= v {
+			stack = append(stack, left, m-1, nextAxis)
+		}
+		if max[axis] >= v {
+			stack = append(stack, m+1, right, nextAxis)
+		}
+	}
+	return result
+}
+
+func (ti *temporalIndex) sort(left, right, depth int) {
+	if right-left <= ti.nodeSize {
+		return
+	}
+	m := (left + right) / 2
+	ti.sselect(m, left, right, depth%3)
+	ti.sort(left, m-1, depth+1)
+	ti.sort(m+1, right, depth+1)
+}
+
+// sselect is the same Floyd-Rivest selection as kdIndex one, by one of three axes
+func (ti *temporalIndex) sselect(k, left, right, axis int) {
+	value := func(i int) float64 { return ti.coords[3*i+axis] }
+	for right > left {
+		if right-left > 600 {
+			n := float64(right - left + 1)
+			m := float64(k - left + 1)
+			z := math.Log(n)
+			s := 0.5 * math.Exp(2.0*z/3.0)
+			sds := 1.0
+			if m-n/2.0 < 0 {
+				sds = -1.0
+			}
+			sd := 0.5 * math.Sqrt(z*s*(n-s)/n) * sds
+			newLeft := maxInt(left, int(math.Floor(float64(k)-m*s/n+sd)))
+			newRight := minInt(right, int(math.Floor(float64(k)+(n-m)*s/n+sd)))
+			ti.sselect(k, newLeft, newRight, axis)
+		}
+
+		t := value(k)
+		i := left
+		j := right
+
+		ti.swap(left, k)
+		if value(right) > t {
+			ti.swap(left, right)
+		}
+
+		for i < j {
+			ti.swap(i, j)
+			i++
+			j--
+			for value(i) < t {
+				i++
+			}
+			for value(j) > t {
+				j--
+			}
+		}
+
+		if value(left) == t {
+			ti.swap(left, j)
+		} else {
+			j++
+			ti.swap(j, right)
+		}
+
+		if j <= k {
+			left = j + 1
+		}
+		if k <= j {
+			right = j - 1
+		}
+	}
+}
+
+func (ti *temporalIndex) swap(i, j int) {
+	ti.ids[i], ti.ids[j] = ti.ids[j], ti.ids[i]
+	ti.points[i], ti.points[j] = ti.points[j], ti.points[i]
+	for axis := 0; axis < 3; axis++ {
+		ti.coords[3*i+axis], ti.coords[3*j+axis] = ti.coords[3*j+axis], ti.coords[3*i+axis]
+	}
+}
+
+// temporalIndex returns index of points with timestamps, it's built on the first windowed query after clustering
+func (c *Cluster) temporalIndex() *temporalIndex {
+	r := c.results
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.temporal == nil {
+		r.temporal = c.newTemporalIndex()
+	}
+	return r.temporal
+}
+
+// GetClustersBetween returns clusters and single points inside the box, like GetClusters, made only of points
+// with timestamps between from and to inclusive, e.g. for history scrubber of the map.
+// Points of the window are found by the index of coordinates and time and clustered with options of c on the fly,
+// so the cost depends on the number of points in the box and the window, not on all points.
+// Points within 2*Epsilon around the box are clustered too, so clusters at its edges are complete.
+// Timestamps are GetTime of GeoPointWithTime points or Time, points without them are never returned.
+// Ids of clusters are unique only within the result, Leaves doesn't know them; members are in IncludedPoints.
+// It's safe to call concurrently with other queries.
+func (c *Cluster) GetClustersBetween(northWest, southEast GeoCoordinates, from, to time.Time) []ClusterPoint {
+	defer c.startQuery("GetClustersBetween")()
+	if c.results == nil || to.Before(from) {
+		return nil
+	}
+	index := c.temporalIndex()
+	crosses := northWest.Lon > southEast.Lon && !c.planar()
+	var found []int
+	if crosses {
+		found = append(c.temporalRange(index, northWest.Lon, southEast.Lat, 180, northWest.Lat, from, to),
+			c.temporalRange(index, -180, southEast.Lat, southEast.Lon, northWest.Lat, from, to)...)
+	} else {
+		found = c.temporalRange(index, northWest.Lon, southEast.Lat, southEast.Lon, northWest.Lat, from, to)
+	}
+	if len(found) == 0 {
+		return []ClusterPoint{}
+	}
+
+	window := c.windowCluster(index, found)
+	result := []ClusterPoint{}
+	for _, p := range window.ResultPoints {
+		inLon := p.X >= northWest.Lon && p.X <= southEast.Lon
+		if crosses {
+			inLon = p.X >= northWest.Lon || p.X <= southEast.Lon
+		}
+		if inLon && p.Y >= southEast.Lat && p.Y <= northWest.Lat {
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+// temporalRange returns positions in the index of points inside the box expanded by 2*Epsilon and the time window
+func (c *Cluster) temporalRange(index *temporalIndex, west, south, east, north float64, from, to time.Time) []int {
+	projection := c.projection()
+	x1, y1 := projection.Project(GeoCoordinates{Lon: west, Lat: north})
+	x2, y2 := projection.Project(GeoCoordinates{Lon: east, Lat: south})
+	margin := 2 * c.Epsilon
+	return index.Range(math.Min(x1, x2)-margin, math.Min(y1, y2)-margin, math.Max(x1, x2)+margin, math.Max(y1, y2)+margin,
+		unixSeconds(from), unixSeconds(to))
+}
+
+// windowCluster clusters points of the index at found positions with options of c, c itself is not changed
+// Window points are numbered from zero while they are clustered, member ids are input ids again in the result.
+// Ids of window clusters are the local sequence, IDGenerator is not called, so queries don't consume its ids
+// and generators get only input ids of members.
+func (c *Cluster) windowCluster(index *temporalIndex, found []int) *Cluster {
+	sort.Slice(found, func(i, j int) bool { return index.ids[found[i]] < index.ids[found[j]] })
+	ids := make([]int, len(found))
+	points := make([]*ClusterPoint, 0, len(found))
+	duplicates := map[[2]float64]*ClusterPoint{}
+	for k, i := range found {
+		ids[k] = int(index.ids[i])
+		x, y := index.coords[3*i], index.coords[3*i+1]
+		if p := duplicates[[2]float64{x, y}]; p != nil {
+			p.NumPoints++
+			p.IncludedPoints = append(p.IncludedPoints, index.points[i])
+			p.memberIDs = append(p.memberIDs, k)
+			continue
+		}
+		p := &ClusterPoint{
+			X:              x,
+			Y:              y,
+			Id:             ids[k],
+			NumPoints:      1,
+			IncludedPoints: []GeoPoint{index.points[i]},
+			memberIDs:      []int{k},
+			region:         c.regionOf(x, y),
+		}
+		if c.DeduplicateCoordinates {
+			duplicates[[2]float64{x, y}] = p
+		}
+		points = append(points, p)
+	}
+
+	window := &Cluster{}
+	*window = *c
+	window.basePoints, window.baseOf = points, nil
+	window.baseIndex = c.indexPoints(points)
+	window.columnValues, window.spill, window.emit = nil, nil, nil
+	window.SpillThreshold = 0
+	window.IDGenerator, window.generatedIDs, window.mintedIDs = nil, false, nil
+	window.clusterSeq = 0
+	window.buildResultPoints()
+	for i := range window.ResultPoints {
+		p := &window.ResultPoints[i]
+		p.memberIDs = inputIDs(p.memberIDs, ids)
+		p.topLeafIDs = inputIDs(p.topLeafIDs, ids)
+	}
+	return window
+}
+
+// inputIDs returns input ids of window points, slices of members could be shared, so new one is created
+func inputIDs(window []int, ids []int) []int {
+	if window == nil {
+		return nil
+	}
+	result := make([]int, len(window))
+	for i, id := range window {
+		result[i] = ids[id]
+	}
+	return result
+}
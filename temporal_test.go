@@ -0,0 +1,90 @@
+package cluster
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingIDs is SequentialIDs which counts calls and fails the test if members are not input ids in [min..max]
+type recordingIDs struct {
+	SequentialIDs
+	t        *testing.T
+	min, max int
+	mu       sync.Mutex
+	calls    int
+}
+
+func (r *recordingIDs) NextID(zoom int, members []int) int {
+	r.mu.Lock()
+	r.calls++
+	r.mu.Unlock()
+	for _, id := range members {
+		if id < r.min || id > r.max {
+			r.t.Errorf("NextID is called with member %d, not input id", id)
+		}
+	}
+	return r.SequentialIDs.NextID(zoom, members)
+}
+
+func TestGetClustersBetween(t *testing.T) {
+	const n = 3000
+	c, err := NewClusterForZoom(3, 256, 60)
+	if err != nil {
+		t.Fatal(err)
+	}
+	generator := &recordingIDs{SequentialIDs: SequentialIDs{next: n}, t: t, max: n - 1}
+	c.IDGenerator = generator
+	c.Time = PropertyTime("n")
+	points := randomPoints(n, 5, -30, -30, 30, 30)
+	if err := c.ClusterPoints(points); err != nil {
+		t.Fatal(err)
+	}
+	calls := generator.calls
+
+	//timestamps are ids of the points, so members of the window are input points [1000..1999]
+	from, to := time.Unix(1000, 0), time.Unix(1999, 0)
+	generator.min, generator.max = 1000, 1999
+	northWest, southEast := GeoCoordinates{Lon: -20, Lat: 20}, GeoCoordinates{Lon: 20, Lat: -20}
+	var wg sync.WaitGroup
+	results := make([][]ClusterPoint, 4)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = c.GetClustersBetween(northWest, southEast, from, to)
+		}(i)
+	}
+	wg.Wait()
+	if generator.calls != calls {
+		t.Errorf("windowed queries minted %d ids of IDGenerator", generator.calls-calls)
+	}
+
+	seen := map[int]bool{}
+	for _, cp := range results[0] {
+		if len(cp.memberIDs) != cp.NumPoints || len(cp.IncludedPoints) != cp.NumPoints {
+			t.Fatalf("cluster %d has %d members and %d included points, want %d", cp.Id, len(cp.memberIDs), len(cp.IncludedPoints), cp.NumPoints)
+		}
+		for k, id := range cp.memberIDs {
+			if seen[id] {
+				t.Fatalf("point %d is in several clusters", id)
+			}
+			seen[id] = true
+			f := cp.IncludedPoints[k].(*Feature)
+			if f.ID != id {
+				t.Fatalf("member %d of cluster %d is input point %v", id, cp.Id, f.ID)
+			}
+			if v := f.Properties["n"].(float64); v < 1000 || v > 1999 {
+				t.Fatalf("point %d of time %v is outside of the window", id, v)
+			}
+		}
+	}
+	if len(seen) == 0 {
+		t.Fatal("no point is in the window")
+	}
+	for i := 1; i < len(results); i++ {
+		if totalPoints(results[i]) != len(seen) || len(results[i]) != len(results[0]) {
+			t.Fatalf("concurrent query %d returned other result", i)
+		}
+	}
+}
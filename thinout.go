@@ -0,0 +1,163 @@
+package cluster
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// ThinOut thins out over-dense cells before clustering, see Cluster.ThinOut
+// Points of the cell beyond MaxPoints are dropped from the index and merged into the closest kept point of the cell,
+// like duplicates are, so counts, members and assignments of clusters still include them and clustering
+// of billions of telemetry points only queries neighbours of kept ones.
+type ThinOut struct {
+	// MaxPoints is the number of points kept in each cell, 0 disables thinning out
+	MaxPoints int
+	// CellSize is the size of square cells in projected coordinates, Epsilon if it's 0
+	CellSize float64
+	// Rank keeps points of the cell with the highest rank, e.g. the latest or the most important ones,
+	// points without rank are dropped first. Points are sampled at random if it's nil or they have no members.
+	Rank NumericAccessor
+	// Seed of the random sampling, the same points and Seed keep the same points
+	Seed int64
+}
+
+// ThinnedCell is the cell whose points were thinned out, in BuildReport
+type ThinnedCell struct {
+	NorthWest, SouthEast GeoCoordinates
+	// Points is the number of base points of the cell before thinning out, Dropped of them were merged into kept ones
+	Points  int
+	Dropped int
+}
+
+// thinOutCell is the key of the cell, points of different boundary regions are never merged
+type thinOutCell struct {
+	col, row int64
+	region   int
+}
+
+// thinOut drops base points of cells with more than MaxPoints of them, returns true if any were dropped
+// Dropped points are merged into kept ones, baseOf maps their members to the kept ones.
+func (c *Cluster) thinOut() bool {
+	t := c.ThinOut
+	if t.MaxPoints <= 0 {
+		return false
+	}
+	size := t.CellSize
+	if size <= 0 {
+		size = c.Epsilon
+	}
+	if !(size > 0) {
+		return false
+	}
+
+	//cells in order of their first points, so sampling doesn't depend on map order
+	cells := map[thinOutCell][]int{}
+	var order []thinOutCell
+	for b, p := range c.basePoints {
+		key := thinOutCell{col: int64(math.Floor(p.X / size)), row: int64(math.Floor(p.Y / size)), region: p.region}
+		if _, ok := cells[key]; !ok {
+			order = append(order, key)
+		}
+		cells[key] = append(cells[key], b)
+	}
+
+	random := rand.New(rand.NewSource(t.Seed))
+	into := make([]int, len(c.basePoints)) //base point each one is merged into, itself if it's kept
+	for b := range into {
+		into[b] = b
+	}
+	projection := c.projection()
+	for _, key := range order {
+		points := cells[key]
+		if len(points) <= t.MaxPoints {
+			continue
+		}
+		c.thinOutRank(points, random)
+		kept, dropped := points[:t.MaxPoints], points[t.MaxPoints:]
+		for _, d := range dropped {
+			p := c.basePoints[d]
+			best, nearest := math.Inf(1), kept[0]
+			for _, k := range kept {
+				if dist := sqDist(p.X, p.Y, c.basePoints[k].X, c.basePoints[k].Y); dist < best {
+					best, nearest = dist, k
+				}
+			}
+			into[d] = nearest
+			k := c.basePoints[nearest]
+			k.NumPoints += p.NumPoints
+			k.IncludedPoints = append(k.IncludedPoints, p.IncludedPoints...)
+			k.memberIDs = append(k.memberIDs, p.memberIDs...)
+		}
+		nw := projection.Unproject(float64(key.col)*size, float64(key.row)*size)
+		se := projection.Unproject(float64(key.col+1)*size, float64(key.row+1)*size)
+		c.report.Thinned = append(c.report.Thinned, ThinnedCell{
+			NorthWest: GeoCoordinates{Lon: math.Min(nw.Lon, se.Lon), Lat: math.Max(nw.Lat, se.Lat)},
+			SouthEast: GeoCoordinates{Lon: math.Max(nw.Lon, se.Lon), Lat: math.Min(nw.Lat, se.Lat)},
+			Points:    len(points),
+			Dropped:   len(dropped),
+		})
+	}
+	if len(c.report.Thinned) == 0 {
+		return false
+	}
+
+	//kept points keep their order, members of dropped ones are mapped to them
+	moved := make([]int, len(c.basePoints))
+	kept := c.basePoints[:0]
+	for b, p := range c.basePoints {
+		if into[b] == b {
+			moved[b] = len(kept)
+			kept = append(kept, p)
+		}
+	}
+	for b := range moved {
+		moved[b] = moved[into[b]]
+	}
+	if c.baseOf == nil {
+		c.baseOf = make([]int, c.numInputPoints())
+		for id := range c.baseOf {
+			c.baseOf[id] = id
+		}
+	}
+	for id, b := range c.baseOf {
+		if b >= 0 {
+			c.baseOf[id] = moved[b]
+		}
+	}
+	for b := len(kept); b < len(c.basePoints); b++ {
+		c.basePoints[b] = nil
+	}
+	c.basePoints = kept
+	return true
+}
+
+// thinOutRank orders base points of the cell, kept ones first: by descending Rank of their first members or at random
+func (c *Cluster) thinOutRank(points []int, random *rand.Rand) {
+	rank := c.ThinOut.Rank
+	if rank != nil {
+		ranks := make(map[int]float64, len(points))
+		ranked := true
+		for _, b := range points {
+			p := c.basePoints[b]
+			if len(p.IncludedPoints) == 0 {
+				ranked = false
+				break
+			}
+			ranks[b] = math.Inf(-1)
+			if v, ok := rank(p.IncludedPoints[0]); ok && !math.IsNaN(v) {
+				ranks[b] = v
+			}
+		}
+		if ranked {
+			//stable, so points of the same rank keep input order
+			sort.SliceStable(points, func(i, j int) bool { return ranks[points[i]] > ranks[points[j]] })
+			return
+		}
+	}
+	//partial Fisher-Yates shuffle, only kept points need to be chosen
+	for i := 0; i < c.ThinOut.MaxPoints && i < len(points)-1; i++ {
+		j := i + random.Intn(len(points)-i)
+		points[i], points[j] = points[j], points[i]
+	}
+}
@@ -0,0 +1,90 @@
+package cluster
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestThinOut(t *testing.T) {
+	//dense spot of points among sparse ones
+	points := randomPoints(300, 66, 10, 10, 10.05, 10.05)
+	for _, p := range randomPoints(300, 67, -30, -30, 30, 30) {
+		points = append(points, &Feature{ID: len(points), Coordinates: p.GetCoordinates()})
+	}
+	const size = 1e-4
+	rank := func(p GeoPoint) (float64, bool) {
+		v, ok := p.(*Feature).Properties["n"].(float64)
+		return v, ok
+	}
+	cellOf := func(p *ClusterPoint) [2]int64 {
+		x, y := MercatorProjection(p.IncludedPoints[0].GetCoordinates())
+		return [2]int64{int64(math.Floor(x / size)), int64(math.Floor(y / size))}
+	}
+	newCluster := func(thinOut ThinOut) *Cluster {
+		c, err := NewClusterForZoom(3, 256, 60)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.ThinOut = thinOut
+		if err := c.ClusterPoints(points); err != nil {
+			t.Fatal(err)
+		}
+		checkAssignments(t, c, len(points))
+		return c
+	}
+
+	for _, rank := range []NumericAccessor{nil, rank} {
+		c := newCluster(ThinOut{MaxPoints: 5, CellSize: size, Rank: rank, Seed: 1})
+		report := c.Report()
+		dropped := 0
+		for _, cell := range report.Thinned {
+			if cell.Points-cell.Dropped != 5 || cell.NorthWest.Lon >= cell.SouthEast.Lon || cell.NorthWest.Lat <= cell.SouthEast.Lat {
+				t.Fatalf("thinned cell %+v", cell)
+			}
+			dropped += cell.Dropped
+		}
+		if len(report.Thinned) == 0 || len(c.basePoints) != len(points)-dropped {
+			t.Fatalf("%d cells thinned out, %d base points of %d points, %d dropped", len(report.Thinned), len(c.basePoints), len(points), dropped)
+		}
+		//cells keep at most MaxPoints base points, the highest ranks of the cell with Rank
+		cells := map[[2]int64][]*ClusterPoint{}
+		for _, p := range c.basePoints {
+			cells[cellOf(p)] = append(cells[cellOf(p)], p)
+		}
+		for cell, kept := range cells {
+			if len(kept) > 5 {
+				t.Fatalf("cell %v keeps %d points", cell, len(kept))
+			}
+			if rank == nil {
+				continue
+			}
+			lowest, highest := math.Inf(1), math.Inf(-1)
+			for _, p := range kept {
+				v, _ := rank(p.IncludedPoints[0])
+				lowest = math.Min(lowest, v)
+				for _, m := range p.IncludedPoints[1:] {
+					v, _ := rank(m)
+					highest = math.Max(highest, v)
+				}
+			}
+			if highest > lowest {
+				t.Fatalf("cell %v keeps point of rank %v and drops point of rank %v", cell, lowest, highest)
+			}
+		}
+	}
+
+	//the same seed keeps the same points, another one samples others
+	a := newCluster(ThinOut{MaxPoints: 5, CellSize: size, Seed: 1})
+	b := newCluster(ThinOut{MaxPoints: 5, CellSize: size, Seed: 1})
+	other := newCluster(ThinOut{MaxPoints: 5, CellSize: size, Seed: 2})
+	if !reflect.DeepEqual(a.baseOf, b.baseOf) || reflect.DeepEqual(a.baseOf, other.baseOf) {
+		t.Fatal("sampling doesn't depend on Seed")
+	}
+	if restored := roundTrip(t, a).ThinOut; restored.MaxPoints != 5 || restored.CellSize != size || restored.Seed != 1 {
+		t.Fatalf("restored cluster has ThinOut %+v", restored)
+	}
+	if plain := newCluster(ThinOut{}); len(plain.basePoints) != len(points) || len(plain.Report().Thinned) != 0 {
+		t.Fatal("points are thinned out without MaxPoints")
+	}
+}
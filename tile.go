@@ -0,0 +1,120 @@
+package cluster
+
+import (
+	"fmt"
+	"math"
+)
+
+// MaxZoomLevel is the maximum supported zoom level
+const MaxZoomLevel = 21
+
+// Tile is XYZ address of the map tile, as used by OSM and Google maps
+type Tile struct {
+	X, Y, Z int
+}
+
+// String returns tile in z/x/y form
+func (t Tile) String() string {
+	return fmt.Sprintf("%d/%d/%d", t.Z, t.X, t.Y)
+}
+
+// tileScale returns number of tiles on each axis for zoom
+func tileScale(zoom int) float64 {
+	return float64(uint64(1) << uint(zoom))
+}
+
+// EpsilonForZoom returns clustering radius in projected [0..1] coordinates
+// for marker pointSize pixels on tiles of tileSize pixels at zoom
+func EpsilonForZoom(zoom, tileSize, pointSize int) float64 {
+	return float64(pointSize) / (float64(tileSize) * tileScale(zoom))
+}
+
+// LonLatToTile returns the tile which contains coordinates at zoom
+func LonLatToTile(coordinates GeoCoordinates, zoom int) Tile {
+	x, y := MercatorProjection(coordinates)
+	return projectedToTile(x, y, zoom)
+}
+
+func projectedToTile(x, y float64, zoom int) Tile {
+	n := tileScale(zoom)
+	max := int(n) - 1
+	return Tile{
+		X: maxInt(0, minInt(max, int(math.Floor(x*n)))),
+		Y: maxInt(0, minInt(max, int(math.Floor(y*n)))),
+		Z: zoom,
+	}
+}
+
+// TileBounds returns north-west and south-east corners of the tile
+func TileBounds(t Tile) (northWest, southEast GeoCoordinates) {
+	n := tileScale(t.Z)
+	northWest = ReverseMercatorProjection(float64(t.X)/n, float64(t.Y)/n)
+	southEast = ReverseMercatorProjection(float64(t.X+1)/n, float64(t.Y+1)/n)
+	return northWest, southEast
+}
+
+// LonLatToPixel returns global pixel coordinates at zoom for tiles of tileSize pixels
+func LonLatToPixel(coordinates GeoCoordinates, zoom, tileSize int) (float64, float64) {
+	x, y := MercatorProjection(coordinates)
+	scale := float64(tileSize) * tileScale(zoom)
+	return x * scale, y * scale
+}
+
+// PixelToLonLat is reverse of LonLatToPixel
+func PixelToLonLat(x, y float64, zoom, tileSize int) GeoCoordinates {
+	scale := float64(tileSize) * tileScale(zoom)
+	return ReverseMercatorProjection(x/scale, y/scale)
+}
+
+// TilePixel returns pixel coordinates of the point relative to top-left corner of the tile with extent pixels
+// Coordinates are outside of [0..extent) range if point is outside of the tile
+func TilePixel(coordinates GeoCoordinates, t Tile, extent int) (float64, float64) {
+	x, y := MercatorProjection(coordinates)
+	return projectedToTilePixel(x, y, t, extent)
+}
+
+func projectedToTilePixel(x, y float64, t Tile, extent int) (float64, float64) {
+	n := tileScale(t.Z)
+	return (x*n - float64(t.X)) * float64(extent), (y*n - float64(t.Y)) * float64(extent)
+}
+
+// TileToQuadkey returns Bing maps quadkey of the tile
+func TileToQuadkey(t Tile) string {
+	key := make([]byte, t.Z)
+	for i := t.Z; i > 0; i-- {
+		digit := byte('0')
+		mask := 1 << uint(i-1)
+		if t.X&mask != 0 {
+			digit++
+		}
+		if t.Y&mask != 0 {
+			digit += 2
+		}
+		key[t.Z-i] = digit
+	}
+	return string(key)
+}
+
+// QuadkeyToTile is reverse of TileToQuadkey
+func QuadkeyToTile(quadkey string) (Tile, error) {
+	t := Tile{Z: len(quadkey)}
+	if t.Z > MaxZoomLevel {
+		return Tile{}, fmt.Errorf("gocluster: quadkey %q is too long", quadkey)
+	}
+	for i := t.Z; i > 0; i-- {
+		mask := 1 << uint(i-1)
+		switch quadkey[t.Z-i] {
+		case '0':
+		case '1':
+			t.X |= mask
+		case '2':
+			t.Y |= mask
+		case '3':
+			t.X |= mask
+			t.Y |= mask
+		default:
+			return Tile{}, fmt.Errorf("gocluster: invalid quadkey %q", quadkey)
+		}
+	}
+	return t, nil
+}
@@ -0,0 +1,72 @@
+package cluster
+
+import (
+	"math"
+	"testing"
+)
+
+func TestLonLatToTile(t *testing.T) {
+	tests := []struct {
+		c    GeoCoordinates
+		zoom int
+		want Tile
+	}{
+		{GeoCoordinates{Lon: 0, Lat: 0}, 0, Tile{0, 0, 0}},
+		{GeoCoordinates{Lon: 13.4, Lat: 52.5}, 10, Tile{550, 335, 10}},
+		{GeoCoordinates{Lon: -74.0, Lat: 40.7}, 12, Tile{1206, 1540, 12}},
+		//coordinates at edges of the world are clamped to its tiles
+		{GeoCoordinates{Lon: 180, Lat: -90}, 3, Tile{7, 7, 3}},
+		{GeoCoordinates{Lon: -180, Lat: 90}, 3, Tile{0, 0, 3}},
+	}
+	for _, test := range tests {
+		if got := LonLatToTile(test.c, test.zoom); got != test.want {
+			t.Errorf("tile of %v at zoom %d is %v, want %v", test.c, test.zoom, got, test.want)
+		}
+	}
+}
+
+func TestTileBoundsAndPixels(t *testing.T) {
+	tile := Tile{X: 550, Y: 335, Z: 10}
+	northWest, southEast := TileBounds(tile)
+	if !(northWest.Lon < 13.4 && southEast.Lon > 13.4 && northWest.Lat > 52.5 && southEast.Lat < 52.5) {
+		t.Fatalf("tile %v bounds %v %v don't contain Berlin", tile, northWest, southEast)
+	}
+	x, y := TilePixel(northWest, tile, 4096)
+	if math.Abs(x) > 1e-6 || math.Abs(y) > 1e-6 {
+		t.Fatalf("north west corner is at pixel %v,%v", x, y)
+	}
+	x, y = TilePixel(southEast, tile, 4096)
+	if math.Abs(x-4096) > 1e-6 || math.Abs(y-4096) > 1e-6 {
+		t.Fatalf("south east corner is at pixel %v,%v", x, y)
+	}
+
+	for _, c := range []GeoCoordinates{{Lon: 13.4, Lat: 52.5}, {Lon: -122.4, Lat: -37.8}} {
+		x, y := LonLatToPixel(c, 7, 512)
+		back := PixelToLonLat(x, y, 7, 512)
+		if math.Abs(back.Lon-c.Lon) > 1e-9 || math.Abs(back.Lat-c.Lat) > 1e-9 {
+			t.Fatalf("pixel %v,%v of %v is back at %v", x, y, c, back)
+		}
+	}
+	if s := tile.String(); s != "10/550/335" {
+		t.Fatalf("tile is %q, want z/x/y", s)
+	}
+}
+
+func TestQuadkey(t *testing.T) {
+	//example of Bing maps tile system documentation
+	tile := Tile{X: 3, Y: 5, Z: 3}
+	if key := TileToQuadkey(tile); key != "213" {
+		t.Fatalf("quadkey of %v is %q, want 213", tile, key)
+	}
+	for _, tile := range []Tile{{0, 0, 0}, {3, 5, 3}, {550, 335, 10}, {1<<21 - 1, 0, 21}} {
+		back, err := QuadkeyToTile(TileToQuadkey(tile))
+		if err != nil || back != tile {
+			t.Fatalf("quadkey of %v is back %v: %v", tile, back, err)
+		}
+	}
+	for _, key := range []string{"0124", "0000000000000000000000"} {
+		if _, err := QuadkeyToTile(key); err == nil {
+			t.Errorf("expected error of quadkey %q", key)
+		}
+	}
+}
@@ -0,0 +1,233 @@
+package cluster
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"math"
+	"sort"
+)
+
+// TopoJSONQuantization is the usual quantization of TopoJSON encoders
+const TopoJSONQuantization = 1e5
+
+type topoPoint [2]int64
+
+type topoGeometry struct {
+	Type       interface{}            `json:"type"` // nil for collapsed rings
+	ID         interface{}            `json:"id,omitempty"`
+	Arcs       [][]int                `json:"arcs,omitempty"`
+	Properties map[string]interface{} `json:"properties,omitempty"`
+}
+
+type topoCollection struct {
+	Type       string         `json:"type"`
+	Geometries []topoGeometry `json:"geometries"`
+}
+
+type topoTransform struct {
+	Scale     [2]float64 `json:"scale"`
+	Translate [2]float64 `json:"translate"`
+}
+
+type topology struct {
+	Type      string                    `json:"type"`
+	BBox      [4]float64                `json:"bbox"`
+	Transform topoTransform             `json:"transform"`
+	Objects   map[string]topoCollection `json:"objects"`
+	Arcs      [][]topoPoint             `json:"arcs"`
+}
+
+// EncodeTopoJSON encodes polygons, such as Grid cells or HullPolygons, to TopoJSON topology
+// with single GeometryCollection object. Coordinates are quantized to quantization x quantization grid,
+// TopoJSONQuantization is a good default. Boundaries shared by neighbour polygons are encoded as one arc,
+// so grids take much less space than in GeoJSON.
+// Polygons which collapse to less than 3 points after quantization have null geometry.
+func EncodeTopoJSON(polygons []Polygon, objectName string, quantization int) ([]byte, error) {
+	if quantization < 2 {
+		return nil, errors.New("gocluster: TopoJSON quantization should be at least 2")
+	}
+
+	minLon, minLat := math.Inf(1), math.Inf(1)
+	maxLon, maxLat := math.Inf(-1), math.Inf(-1)
+	for _, p := range polygons {
+		for _, c := range p.Ring {
+			minLon, maxLon = math.Min(minLon, c.Lon), math.Max(maxLon, c.Lon)
+			minLat, maxLat = math.Min(minLat, c.Lat), math.Max(maxLat, c.Lat)
+		}
+	}
+	if math.IsInf(minLon, 1) {
+		minLon, minLat, maxLon, maxLat = 0, 0, 0, 0
+	}
+	kx, ky := 1.0, 1.0
+	if maxLon > minLon {
+		kx = (maxLon - minLon) / float64(quantization-1)
+	}
+	if maxLat > minLat {
+		ky = (maxLat - minLat) / float64(quantization-1)
+	}
+
+	rings := make([][]topoPoint, len(polygons))
+	for i, p := range polygons {
+		rings[i] = quantizeRing(p.Ring, minLon, minLat, kx, ky)
+	}
+
+	t := topology{
+		Type:      "Topology",
+		BBox:      [4]float64{minLon, minLat, maxLon, maxLat},
+		Transform: topoTransform{Scale: [2]float64{kx, ky}, Translate: [2]float64{minLon, minLat}},
+		Arcs:      [][]topoPoint{},
+	}
+	collection := topoCollection{Type: "GeometryCollection", Geometries: make([]topoGeometry, len(polygons))}
+	arcs := newTopoArcs(rings)
+	for i, p := range polygons {
+		g := topoGeometry{ID: p.ID, Properties: p.Properties}
+		if len(rings[i]) >= 3 {
+			g.Type = "Polygon"
+			g.Arcs = [][]int{arcs.ringArcs(i)}
+		}
+		collection.Geometries[i] = g
+	}
+	t.Objects = map[string]topoCollection{objectName: collection}
+	for _, arc := range arcs.arcs {
+		t.Arcs = append(t.Arcs, deltaEncode(arc))
+	}
+	return json.Marshal(t)
+}
+
+// quantizeRing returns quantized ring without repeated points and without closing point
+func quantizeRing(ring []GeoCoordinates, minLon, minLat, kx, ky float64) []topoPoint {
+	result := make([]topoPoint, 0, len(ring))
+	for _, c := range ring {
+		p := topoPoint{int64(math.Round((c.Lon - minLon) / kx)), int64(math.Round((c.Lat - minLat) / ky))}
+		if len(result) == 0 || result[len(result)-1] != p {
+			result = append(result, p)
+		}
+	}
+	for len(result) > 1 && result[len(result)-1] == result[0] {
+		result = result[:len(result)-1]
+	}
+	return result
+}
+
+// topoArcs splits rings into arcs at junctions, where the set of rings sharing the boundary changes
+// Arcs shared by rings are stored once, rings refer them by index, or by ^index if arc is reversed
+type topoArcs struct {
+	rings  [][]topoPoint
+	owners map[[2]topoPoint][]int
+	arcs   [][]topoPoint
+	known  map[string]int
+}
+
+func newTopoArcs(rings [][]topoPoint) *topoArcs {
+	a := &topoArcs{rings: rings, owners: map[[2]topoPoint][]int{}, known: map[string]int{}}
+	for i, ring := range rings {
+		if len(ring) < 3 {
+			continue
+		}
+		for j := range ring {
+			key := edgeKey(ring[j], ring[(j+1)%len(ring)])
+			if owners := a.owners[key]; len(owners) == 0 || owners[len(owners)-1] != i {
+				a.owners[key] = append(owners, i)
+			}
+		}
+	}
+	for _, owners := range a.owners {
+		sort.Ints(owners)
+	}
+	return a
+}
+
+// edgeKey doesn't depend on the edge direction
+func edgeKey(a, b topoPoint) [2]topoPoint {
+	if b[0] < a[0] || (b[0] == a[0] && b[1] < a[1]) {
+		a, b = b, a
+	}
+	return [2]topoPoint{a, b}
+}
+
+func (a *topoArcs) edgeOwners(ring []topoPoint, j int) []int {
+	return a.owners[edgeKey(ring[j], ring[(j+1)%len(ring)])]
+}
+
+// ringArcs returns arc indexes of the ring, adding new arcs
+func (a *topoArcs) ringArcs(i int) []int {
+	ring := a.rings[i]
+	n := len(ring)
+	//start from junction, so no arc wraps around the ring start
+	start := -1
+	for j := 0; j < n && start < 0; j++ {
+		if !sameOwners(a.edgeOwners(ring, (j+n-1)%n), a.edgeOwners(ring, j)) {
+			start = j
+		}
+	}
+	if start < 0 {
+		//no junctions, the whole ring is one closed arc
+		arc := append(append(make([]topoPoint, 0, n+1), ring...), ring[0])
+		return []int{a.arcIndex(arc)}
+	}
+
+	var result []int
+	arc := []topoPoint{ring[start]}
+	for k := 0; k < n; k++ {
+		j := (start + k) % n
+		arc = append(arc, ring[(j+1)%n])
+		next := (j + 1) % n
+		if k == n-1 || !sameOwners(a.edgeOwners(ring, j), a.edgeOwners(ring, next)) {
+			result = append(result, a.arcIndex(arc))
+			arc = []topoPoint{ring[next]}
+		}
+	}
+	return result
+}
+
+// arcIndex returns index of the arc, or ^index of the reversed arc if it's already known
+func (a *topoArcs) arcIndex(arc []topoPoint) int {
+	if i, ok := a.known[arcKey(arc, false)]; ok {
+		return i
+	}
+	if i, ok := a.known[arcKey(arc, true)]; ok {
+		return ^i
+	}
+	i := len(a.arcs)
+	a.arcs = append(a.arcs, arc)
+	a.known[arcKey(arc, false)] = i
+	return i
+}
+
+func arcKey(arc []topoPoint, reversed bool) string {
+	buf := make([]byte, len(arc)*2*binary.MaxVarintLen64)
+	n := 0
+	for k := range arc {
+		p := arc[k]
+		if reversed {
+			p = arc[len(arc)-1-k]
+		}
+		n += binary.PutVarint(buf[n:], p[0])
+		n += binary.PutVarint(buf[n:], p[1])
+	}
+	return string(buf[:n])
+}
+
+func sameOwners(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// deltaEncode returns the first point and differences of the following ones, as TopoJSON stores quantized arcs
+func deltaEncode(arc []topoPoint) []topoPoint {
+	result := make([]topoPoint, len(arc))
+	prev := topoPoint{}
+	for i, p := range arc {
+		result[i] = topoPoint{p[0] - prev[0], p[1] - prev[1]}
+		prev = p
+	}
+	return result
+}
@@ -0,0 +1,169 @@
+package cluster
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+type testTopology struct {
+	Type      string
+	BBox      [4]float64
+	Transform struct {
+		Scale     [2]float64
+		Translate [2]float64
+	}
+	Objects map[string]struct {
+		Type       string
+		Geometries []struct {
+			Type       *string
+			ID         interface{}
+			Arcs       [][]int
+			Properties map[string]interface{}
+		}
+	}
+	Arcs [][][2]int64
+}
+
+// decodeArcs returns absolute quantized points of delta encoded arcs
+func (topo *testTopology) decodeArcs() [][]topoPoint {
+	arcs := make([][]topoPoint, len(topo.Arcs))
+	for i, arc := range topo.Arcs {
+		var prev topoPoint
+		for _, d := range arc {
+			prev = topoPoint{prev[0] + d[0], prev[1] + d[1]}
+			arcs[i] = append(arcs[i], prev)
+		}
+	}
+	return arcs
+}
+
+// ringOf joins arcs of the ring, reversed arcs are referred by ^index, the closing point is dropped
+func ringOf(t *testing.T, arcs [][]topoPoint, indexes []int) []topoPoint {
+	t.Helper()
+	var ring []topoPoint
+	for _, i := range indexes {
+		reversed := i < 0
+		if reversed {
+			i = ^i
+		}
+		if i >= len(arcs) {
+			t.Fatalf("ring refers arc %d of %d", i, len(arcs))
+		}
+		arc := append([]topoPoint{}, arcs[i]...)
+		if reversed {
+			for l, r := 0, len(arc)-1; l < r; l, r = l+1, r-1 {
+				arc[l], arc[r] = arc[r], arc[l]
+			}
+		}
+		if len(ring) > 0 {
+			if ring[len(ring)-1] != arc[0] {
+				t.Fatalf("arc %d doesn't continue the ring", i)
+			}
+			arc = arc[1:]
+		}
+		ring = append(ring, arc...)
+	}
+	if len(ring) < 2 || ring[0] != ring[len(ring)-1] {
+		t.Fatalf("ring %v is not closed", ring)
+	}
+	return ring[:len(ring)-1]
+}
+
+// sameRing returns true if b is a rotation of a, as rings start at the first junction
+func sameRing(a, b []topoPoint) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for shift := range b {
+		same := true
+		for i := range a {
+			same = same && a[i] == b[(i+shift)%len(b)]
+		}
+		if same {
+			return true
+		}
+	}
+	return false
+}
+
+func square(id interface{}, lon, lat, size float64) Polygon {
+	return Polygon{ID: id, Ring: []GeoCoordinates{
+		{Lon: lon, Lat: lat}, {Lon: lon + size, Lat: lat}, {Lon: lon + size, Lat: lat + size},
+		{Lon: lon, Lat: lat + size}, {Lon: lon, Lat: lat},
+	}}
+}
+
+func TestEncodeTopoJSON(t *testing.T) {
+	polygons := []Polygon{square("a", 0, 0, 1), square("b", 1, 0, 1), square("c", 0, 1, 1), square("d", 1, 1, 1)}
+	polygons[0].Properties = map[string]interface{}{"count": 3.0}
+	//it collapses to a point of the quantized grid
+	polygons = append(polygons, square("tiny", 0.5, 0.5, 0.01))
+
+	data, err := EncodeTopoJSON(polygons, "cells", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var topo testTopology
+	if err := json.Unmarshal(data, &topo); err != nil {
+		t.Fatal(err)
+	}
+	if topo.Type != "Topology" || topo.BBox != [4]float64{0, 0, 2, 2} ||
+		topo.Transform.Scale != [2]float64{1, 1} || topo.Transform.Translate != [2]float64{0, 0} {
+		t.Fatalf("topology %s of bbox %v and transform %v", topo.Type, topo.BBox, topo.Transform)
+	}
+	cells, ok := topo.Objects["cells"]
+	if !ok || cells.Type != "GeometryCollection" || len(cells.Geometries) != len(polygons) {
+		t.Fatalf("objects %v, want collection of %d cells", topo.Objects, len(polygons))
+	}
+
+	arcs := topo.decodeArcs()
+	for i, p := range polygons[:4] {
+		g := cells.Geometries[i]
+		if g.Type == nil || *g.Type != "Polygon" || g.ID != p.ID || len(g.Arcs) != 1 {
+			t.Fatalf("geometry %d is %v", i, g)
+		}
+		want := quantizeRing(p.Ring, 0, 0, 1, 1)
+		if ring := ringOf(t, arcs, g.Arcs[0]); !sameRing(ring, want) {
+			t.Fatalf("ring of %v is %v, want %v", p.ID, ring, want)
+		}
+	}
+	if cells.Geometries[0].Properties["count"] != 3.0 {
+		t.Fatalf("properties %v, want count", cells.Geometries[0].Properties)
+	}
+	if g := cells.Geometries[4]; g.Type != nil || g.ID != "tiny" || len(g.Arcs) != 0 {
+		t.Fatalf("collapsed geometry is %v, want null geometry", g)
+	}
+
+	//each of 12 edges of the grid is in one arc
+	edges := map[[2]topoPoint]int{}
+	for _, arc := range arcs {
+		for k := 0; k+1 < len(arc); k++ {
+			edges[edgeKey(arc[k], arc[k+1])]++
+		}
+	}
+	if len(edges) != 12 {
+		t.Fatalf("arcs have %d edges, want 12", len(edges))
+	}
+	for edge, n := range edges {
+		if n != 1 {
+			t.Fatalf("edge %v is in %d arcs", edge, n)
+		}
+	}
+}
+
+func TestEncodeTopoJSONEmpty(t *testing.T) {
+	data, err := EncodeTopoJSON(nil, "cells", TopoJSONQuantization)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var topo testTopology
+	if err := json.Unmarshal(data, &topo); err != nil {
+		t.Fatal(err)
+	}
+	if len(topo.Arcs) != 0 || len(topo.Objects["cells"].Geometries) != 0 {
+		t.Fatalf("empty topology is %s", data)
+	}
+	if _, err := EncodeTopoJSON(nil, "cells", 1); err == nil {
+		t.Fatal("expected error of quantization 1")
+	}
+}
@@ -0,0 +1,69 @@
+package cluster
+
+import "time"
+
+// Tracer starts spans around clustering stages: "project", "index" and "clusterize", and queries, e.g. "Grid"
+// Attributes are key value pairs, the same as arguments of slog.Logger methods.
+// Set Cluster.Tracer to diagnose slow clustering in production tracing systems.
+type Tracer interface {
+	StartSpan(name string, attrs ...interface{}) Span
+}
+
+// Span is the stage started by Tracer, End is called with result attributes when the stage is finished
+type Span interface {
+	End(attrs ...interface{})
+}
+
+// Logger is the subset of *slog.Logger methods used by LogTracer
+type Logger interface {
+	Debug(msg string, args ...interface{})
+}
+
+// LogTracer is Tracer, that logs each span with its duration at debug level when it ends
+type LogTracer struct {
+	Logger Logger
+}
+
+// NewLogTracer returns Tracer logging spans to logger, e.g. slog.Default()
+func NewLogTracer(logger Logger) *LogTracer {
+	return &LogTracer{Logger: logger}
+}
+
+// StartSpan implements Tracer interface
+func (t *LogTracer) StartSpan(name string, attrs ...interface{}) Span {
+	return &logSpan{logger: t.Logger, name: name, attrs: attrs, start: time.Now()}
+}
+
+type logSpan struct {
+	logger Logger
+	name   string
+	attrs  []interface{}
+	start  time.Time
+}
+
+func (s *logSpan) End(attrs ...interface{}) {
+	args := append([]interface{}{"duration", time.Since(s.start)}, s.attrs...)
+	s.logger.Debug("gocluster: "+s.name, append(args, attrs...)...)
+}
+
+type noopSpan struct{}
+
+func (noopSpan) End(attrs ...interface{}) {}
+
+// startSpan starts span with Tracer if it's set
+func (c *Cluster) startSpan(name string, attrs ...interface{}) Span {
+	if c.Tracer == nil {
+		return noopSpan{}
+	}
+	return c.Tracer.StartSpan(name, attrs...)
+}
+
+// startQuery starts span of the query, returned function ends it and reports the query to Metrics
+func (c *Cluster) startQuery(name string) func() {
+	start := time.Now()
+	span := c.startSpan(name)
+	return func() {
+		span.End()
+		c.observeQuery(name, start)
+	}
+}
@@ -0,0 +1,51 @@
+package cluster
+
+import (
+	"fmt"
+	"testing"
+)
+
+// recordingLogger keeps debug messages with their arguments
+type recordingLogger struct {
+	messages []string
+	args     [][]interface{}
+}
+
+func (l *recordingLogger) Debug(msg string, args ...interface{}) {
+	l.messages = append(l.messages, msg)
+	l.args = append(l.args, args)
+}
+
+func TestLogTracer(t *testing.T) {
+	logger := &recordingLogger{}
+	c, err := NewClusterForZoom(3, 256, 60)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Tracer = NewLogTracer(logger)
+	if err := c.ClusterPoints(randomPoints(100, 14, -30, -30, 30, 30)); err != nil {
+		t.Fatal(err)
+	}
+	c.GetClusters(GeoCoordinates{Lon: -10, Lat: 10}, GeoCoordinates{Lon: 10, Lat: -10})
+
+	want := []string{"gocluster: project", "gocluster: index", "gocluster: clusterize", "gocluster: GetClusters"}
+	if fmt.Sprint(logger.messages) != fmt.Sprint(want) {
+		t.Fatalf("spans %q, want %q", logger.messages, want)
+	}
+	//duration goes first, then attributes of the start and of the end
+	for i, args := range logger.args {
+		if len(args)%2 != 0 || args[0] != "duration" {
+			t.Fatalf("span %s has arguments %v", logger.messages[i], args)
+		}
+	}
+	if args := logger.args[0]; len(args) < 4 || args[2] != "points" || args[3] != 100 {
+		t.Fatalf("project span has arguments %v", args)
+	}
+}
+
+func TestNoTracer(t *testing.T) {
+	c := NewCluster(0.1)
+	if _, ok := c.startSpan("project").(noopSpan); !ok {
+		t.Fatal("span is started without Tracer")
+	}
+}
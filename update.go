@@ -0,0 +1,351 @@
+package cluster
+
+import (
+	"fmt"
+	"sort"
+)
+
+// movingIndex is the static index with overlay of moved points
+// Moved points are filtered out of static index results and checked one by one,
+// when there are too many of them, static index is rebuilt
+type movingIndex struct {
+	static   spatialIndex
+	points   []*ClusterPoint
+	moved    map[int]bool
+	movedIDs []int
+}
+
+func (mi *movingIndex) move(id int) {
+	if !mi.moved[id] {
+		mi.moved[id] = true
+		mi.movedIDs = append(mi.movedIDs, id)
+	}
+}
+
+func (mi *movingIndex) AppendWithin(result []int, x, y, radius float64) []int {
+	start := len(result)
+	result = mi.static.AppendWithin(result, x, y, radius)
+	//filter moved points in place
+	n := start
+	for _, id := range result[start:] {
+		if !mi.moved[id] {
+			result[n] = id
+			n++
+		}
+	}
+	result = result[:n]
+	r2 := radius * radius
+	for _, id := range mi.movedIDs {
+		p := mi.points[id]
+		if sqDist(p.X, p.Y, x, y) <= r2 {
+			result = append(result, id)
+		}
+	}
+	return result
+}
+
+func (mi *movingIndex) Range(minX, minY, maxX, maxY float64) []int {
+	var result []int
+	for _, id := range mi.static.Range(minX, minY, maxX, maxY) {
+		if !mi.moved[id] {
+			result = append(result, id)
+		}
+	}
+	for _, id := range mi.movedIDs {
+		p := mi.points[id]
+		if p.X >= minX && p.X <= maxX && p.Y >= minY && p.Y <= maxY {
+			result = append(result, id)
+		}
+	}
+	return result
+}
+
+// Bytes adds moved points overlay to the static index
+func (mi *movingIndex) Bytes() int {
+	return mi.static.Bytes() + len(mi.movedIDs)*(8+16)
+}
+
+// movingIndex returns base index wrapped with moved points overlay
+func (c *Cluster) movingIndex() *movingIndex {
+	if mi, ok := c.baseIndex.(*movingIndex); ok {
+		return mi
+	}
+	mi := &movingIndex{
+		static: c.baseIndex,
+		points: c.basePoints,
+		moved:  map[int]bool{},
+	}
+	c.baseIndex = mi
+	return mi
+}
+
+// maxMovedPoints is the number of moved points after which the index is rebuilt
+func (c *Cluster) maxMovedPoints() int {
+	return maxInt(16*c.NodeSize, len(c.basePoints)/32)
+}
+
+// UpdatePoint moves the point to new coordinates, id is the index of the point in the slice passed to ClusterPoints
+// Deduplicated point is split out of its duplicates.
+// Only the cluster of the point and clusters around new position are clustered again, all other clusters are kept,
+// so the result could slightly differ from clustering all points from scratch.
+// GetCoordinates of the point is not called, the point is placed by coordinates argument.
+// Indexes of clusters in ResultPoints are changed by update.
+// Strategies other than StrategyGreedy cluster all points again.
+// It's the same as MovePoint followed by RebuildDirty.
+func (c *Cluster) UpdatePoint(id int, coordinates GeoCoordinates) error {
+	if err := c.MovePoint(id, coordinates); err != nil {
+		return err
+	}
+	c.RebuildDirty()
+	return nil
+}
+
+// MovePoint moves the point to new coordinates like UpdatePoint, but clusters are not changed until RebuildDirty,
+// clusters of the regions the point leaves and enters are marked dirty instead.
+// Live data moves many points at once, and their dirty clusters are clustered again once.
+// Coordinates are checked like input points are, invalid ones are errors, see CheckCoordinates.
+func (c *Cluster) MovePoint(id int, coordinates GeoCoordinates) error {
+	if c.baseIndex == nil {
+		return notBuilt("MovePoint")
+	}
+	if id < 0 || id >= c.numInputPoints() {
+		return fmt.Errorf("gocluster: point id %d is out of range", id)
+	}
+	if err := c.CheckCoordinates(coordinates); err != nil {
+		return fmt.Errorf("%v of point %d", err, id)
+	}
+
+	if c.basePointOf(id) < 0 {
+		return fmt.Errorf("gocluster: point id %d is skipped, see Report", id)
+	}
+
+	index := c.movingIndex()
+	b := c.splitBasePoint(id)
+	index.points = c.basePoints
+	p := c.basePoints[b]
+	p.X, p.Y = c.projection().Project(coordinates)
+	p.region = c.regionOf(p.X, p.Y)
+	index.move(b)
+	defer c.rebuildIndexIfNeeded(index)
+
+	if c.Strategy != StrategyGreedy || c.passthrough() {
+		c.dirtyAll = true
+		return nil
+	}
+
+	//clusters that could change: the old cluster of the point and clusters of new neighbours
+	if c.dirty == nil {
+		c.dirty = map[int]bool{}
+	}
+	c.dirty[c.assignment[id]] = true
+	for _, n := range c.appendNeighbours(nil, index, c.basePoints, p.X, p.Y) {
+		c.dirty[c.assignment[c.basePoints[n].memberIDs[0]]] = true
+	}
+	return nil
+}
+
+// Dirty returns true if points were moved by MovePoint after the last RebuildDirty
+func (c *Cluster) Dirty() bool {
+	return c.dirtyAll || len(c.dirty) > 0
+}
+
+// RebuildDirty clusters again dirty clusters marked by MovePoint, all other clusters are kept intact
+// Returns the number of clusters dissolved, ResultPoints are not changed if nothing is dirty.
+// Points of dirty clusters are taken as seeds in input order whatever SeedOrder is, so with SeedHilbert, SeedDensity
+// or HilbertPresort the result could differ from ClusterPoints of the same points.
+func (c *Cluster) RebuildDirty() int {
+	if !c.Dirty() {
+		return 0
+	}
+	n := len(c.dirty)
+	if c.dirtyAll {
+		n = len(c.ResultPoints)
+		c.rebuildResultPoints()
+		return n
+	}
+	c.reclusterResultPoints(c.dirty)
+	c.dirty = nil
+	return n
+}
+
+func (c *Cluster) rebuildIndexIfNeeded(index *movingIndex) {
+	if len(index.movedIDs) > c.maxMovedPoints() {
+		c.baseIndex = c.indexPoints(c.basePoints)
+	}
+}
+
+// splitBasePoint separates input point from its duplicates into new base point and returns its index
+func (c *Cluster) splitBasePoint(id int) int {
+	b := c.basePointOf(id)
+	dup := c.basePoints[b]
+	if dup.NumPoints == 1 {
+		return b
+	}
+
+	var included []GeoPoint
+	for i, m := range dup.memberIDs {
+		if m == id {
+			//points of ClusterColumns have no members
+			if dup.IncludedPoints != nil {
+				included = []GeoPoint{dup.IncludedPoints[i]}
+				dup.IncludedPoints = append(dup.IncludedPoints[:i:i], dup.IncludedPoints[i+1:]...)
+			}
+			dup.memberIDs = append(dup.memberIDs[:i:i], dup.memberIDs[i+1:]...)
+			break
+		}
+	}
+	dup.NumPoints--
+	dup.Id = dup.memberIDs[0]
+
+	c.baseOf[id] = len(c.basePoints)
+	c.basePoints = append(c.basePoints, &ClusterPoint{
+		X:              dup.X,
+		Y:              dup.Y,
+		visited:        true,
+		Id:             id,
+		NumPoints:      1,
+		IncludedPoints: included,
+		memberIDs:      []int{id},
+		region:         dup.region,
+	})
+	return c.baseOf[id]
+}
+
+// reclusterResultPoints dissolves clusters with indexes in ResultPoints and clusters their members again
+func (c *Cluster) reclusterResultPoints(affected map[int]bool) {
+	indexes := make([]int, 0, len(affected))
+	var seeds, replaced []*ClusterPoint
+	for i := range affected {
+		indexes = append(indexes, i)
+		replaced = append(replaced, &ClusterPoint{X: c.ResultPoints[i].X, Y: c.ResultPoints[i].Y,
+			NumPoints: c.ResultPoints[i].NumPoints, memberIDs: c.ResultPoints[i].memberIDs})
+		for _, id := range c.ResultPoints[i].memberIDs {
+			//duplicates share one base point
+			if p := c.basePoints[c.basePointOf(id)]; p.visited {
+				p.visited = false
+				seeds = append(seeds, p)
+			}
+		}
+	}
+	//seeds are taken in input order, which is the order of full clustering only with SeedInput and without
+	//HilbertPresort; SeedHilbert, SeedDensity and presorted points are taken in other order from scratch,
+	//so clusters of dirty regions could differ from a full rebuild more than by the kept clusters around them
+	sort.Slice(seeds, func(i, j int) bool { return seeds[i].Id < seeds[j].Id })
+	clusters := c.clusterizeSeeds(seeds, c.basePoints, c.baseIndex, nil, nil)
+	damper := c.newCenterDamper(replaced)
+
+	//remove old clusters from the end, so indexes stay valid
+	sort.Sort(sort.Reverse(sort.IntSlice(indexes)))
+	for _, i := range indexes {
+		last := len(c.ResultPoints) - 1
+		if i != last {
+			c.ResultPoints[i] = c.ResultPoints[last]
+			for _, id := range c.ResultPoints[i].memberIDs {
+				c.assignment[id] = i
+			}
+		}
+		c.ResultPoints[last] = ClusterPoint{}
+		c.ResultPoints = c.ResultPoints[:last]
+	}
+
+	for _, cp := range clusters {
+		c.appendResultPoint(c.damp(damper, cp))
+	}
+	c.resultsChanged()
+}
+
+// ReassignPoint moves the point from its cluster into the cluster or single point with id target, e.g. for manual
+// curation of automatic clustering; id is the index of the point in the slice passed to ClusterPoints.
+// Centers, counts and aggregates of both are computed again, all other clusters are kept intact.
+// The target keeps its id if it's a cluster, single point becomes new cluster; the cluster left with one point
+// becomes single point and the single point left with none is removed.
+// Deduplicated point is split out of its duplicates. Indexes of clusters in ResultPoints are changed like by UpdatePoint,
+// and ReclusterWithEpsilon, RebuildDirty and ClusterPoints drop reassignments.
+func (c *Cluster) ReassignPoint(id, target int) error {
+	if c.baseIndex == nil {
+		return notBuilt("ReassignPoint")
+	}
+	if id < 0 || id >= c.numInputPoints() {
+		return fmt.Errorf("gocluster: point id %d is out of range", id)
+	}
+	if c.basePointOf(id) < 0 {
+		return fmt.Errorf("gocluster: point id %d is skipped, see Report", id)
+	}
+	if c.Dirty() {
+		return fmt.Errorf("gocluster: moved points should be clustered by RebuildDirty before ReassignPoint")
+	}
+	to, ok := c.resultByID(target)
+	if !ok {
+		return fmt.Errorf("gocluster: cluster id %d is not found", target)
+	}
+	from := c.assignment[id]
+	if from == to {
+		return nil
+	}
+
+	if c.basePoints[c.basePointOf(id)].NumPoints > 1 {
+		//the point split out of duplicates is not in the index yet
+		index := c.movingIndex()
+		b := c.splitBasePoint(id)
+		index.points = c.basePoints
+		index.move(b)
+		c.rebuildIndexIfNeeded(index)
+	}
+
+	var left []int
+	for _, m := range c.ResultPoints[from].memberIDs {
+		if m != id {
+			left = append(left, m)
+		}
+	}
+	joined := append(append([]int(nil), c.ResultPoints[to].memberIDs...), id)
+	c.ResultPoints[to] = c.regroupResultPoint(joined, &c.ResultPoints[to])
+	c.assignment[id] = to
+	if len(left) > 0 {
+		c.ResultPoints[from] = c.regroupResultPoint(left, &c.ResultPoints[from])
+	} else {
+		//the last result point takes place of the removed one
+		last := len(c.ResultPoints) - 1
+		if from != last {
+			c.ResultPoints[from] = c.ResultPoints[last]
+			for _, m := range c.ResultPoints[from].memberIDs {
+				c.assignment[m] = from
+			}
+		}
+		c.ResultPoints[last] = ClusterPoint{}
+		c.ResultPoints = c.ResultPoints[:last]
+	}
+	c.resultsChanged()
+	return nil
+}
+
+// regroupResultPoint returns result point of members, which replaces previous result point
+// Members of one base point are the base point itself, cluster keeps id of the previous cluster,
+// which is never the id of its first member, unlike ids of base points.
+func (c *Cluster) regroupResultPoint(members []int, previous *ClusterPoint) ClusterPoint {
+	var points []*ClusterPoint
+	seen := map[int]bool{}
+	for _, m := range members {
+		if b := c.basePointOf(m); !seen[b] {
+			seen[b] = true
+			points = append(points, c.basePoints[b])
+		}
+	}
+	var cp *ClusterPoint
+	switch {
+	case len(points) == 1:
+		cp = points[0]
+	case previous.Id != previous.memberIDs[0]:
+		cp = c.mergePoints(points[0], points[1:])
+		cp.Id = previous.Id
+	default:
+		cp = c.newCluster(points[0], points[1:])
+	}
+	if cp.NumPoints > 1 {
+		replaced := &ClusterPoint{X: previous.X, Y: previous.Y, NumPoints: previous.NumPoints, memberIDs: cp.memberIDs[:1]}
+		cp = c.damp(c.newCenterDamper([]*ClusterPoint{replaced}), cp)
+	}
+	result := c.resultPoint(cp)
+	c.spillMembers(&result)
+	return result
+}
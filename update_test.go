@@ -0,0 +1,230 @@
+package cluster
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestUpdateKeepsPoints(t *testing.T) {
+	const n = 2000
+	c, err := NewClusterForZoom(4, 256, 60)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.ClusterPoints(randomPoints(n, 3, -30, -30, 30, 30)); err != nil {
+		t.Fatal(err)
+	}
+	checkAssignments(t, c, n)
+
+	random := rand.New(rand.NewSource(4))
+	move := func() GeoCoordinates {
+		return GeoCoordinates{Lon: -30 + random.Float64()*60, Lat: -30 + random.Float64()*60}
+	}
+	for i := 0; i < 50; i++ {
+		if err := c.UpdatePoint(random.Intn(n), move()); err != nil {
+			t.Fatal(err)
+		}
+		checkAssignments(t, c, n)
+	}
+
+	for i := 0; i < 50; i++ {
+		if err := c.MovePoint(random.Intn(n), move()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !c.Dirty() {
+		t.Fatal("moved points are not dirty")
+	}
+	if c.RebuildDirty() == 0 {
+		t.Fatal("no cluster is rebuilt")
+	}
+	if c.Dirty() {
+		t.Fatal("points are dirty after RebuildDirty")
+	}
+	checkAssignments(t, c, n)
+
+	for i := 0; i < 50; i++ {
+		id := random.Intn(n)
+		target := &c.ResultPoints[random.Intn(len(c.ResultPoints))]
+		other := target.memberIDs[0]
+		if other == id {
+			continue
+		}
+		if err := c.ReassignPoint(id, target.Id); err != nil {
+			t.Fatal(err)
+		}
+		checkAssignments(t, c, n)
+		if a, b := c.assignment[id], c.assignment[other]; a != b {
+			t.Fatalf("point %d is in result point %d, not with point %d in %d", id, a, other, b)
+		}
+	}
+}
+
+func TestMovePointInvalidCoordinates(t *testing.T) {
+	c, err := NewClusterForZoom(4, 256, 60)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.ClusterPoints(randomPoints(100, 7, -30, -30, 30, 30)); err != nil {
+		t.Fatal(err)
+	}
+	for _, coordinates := range []GeoCoordinates{
+		{Lon: math.NaN(), Lat: 0}, {Lon: 0, Lat: math.Inf(1)}, {Lon: 500, Lat: 0}, {Lon: 0, Lat: -91},
+	} {
+		if err := c.UpdatePoint(0, coordinates); err == nil {
+			t.Errorf("UpdatePoint accepted %v", coordinates)
+		}
+		if err := c.MovePoint(1, coordinates); err == nil {
+			t.Errorf("MovePoint accepted %v", coordinates)
+		}
+	}
+	if c.Dirty() {
+		t.Fatal("invalid moves made clusters dirty")
+	}
+	if _, err := MarshalGeoJSON(c.AllClusters()); err != nil {
+		t.Fatal(err)
+	}
+	checkAssignments(t, c, 100)
+}
+
+func TestRebuildDirtyKeepsCleanClusters(t *testing.T) {
+	const n = 2000
+	c, err := NewClusterForZoom(4, 256, 60)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.ClusterPoints(randomPoints(n, 22, -60, -30, 60, 30)); err != nil {
+		t.Fatal(err)
+	}
+	if c.RebuildDirty() != 0 {
+		t.Fatal("clusters are rebuilt without moves")
+	}
+	before := map[int]int{}
+	for _, cp := range c.ResultPoints {
+		before[cp.Id] = cp.NumPoints
+	}
+
+	//points move within the west, clusters of the east are kept
+	var moved []int
+	for id := 0; id < n && len(moved) < 20; id++ {
+		if p := c.ResultPoints[c.assignment[id]]; p.X < -30 {
+			moved = append(moved, id)
+		}
+	}
+	for i, id := range moved {
+		if err := c.MovePoint(id, GeoCoordinates{Lon: -50 + float64(i), Lat: 0}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, cp := range c.ResultPoints {
+		if before[cp.Id] != cp.NumPoints {
+			t.Fatalf("MovePoint changed cluster %d", cp.Id)
+		}
+	}
+	if c.RebuildDirty() == 0 || c.Dirty() {
+		t.Fatal("dirty clusters are not rebuilt")
+	}
+	checkAssignments(t, c, n)
+	for _, cp := range c.ResultPoints {
+		if cp.X > 0 && before[cp.Id] != cp.NumPoints {
+			t.Fatalf("cluster %d of the east has %d points, %d before", cp.Id, cp.NumPoints, before[cp.Id])
+		}
+	}
+
+	//other strategies cluster all points again
+	c.Strategy = StrategyMeanShift
+	if err := c.MovePoint(moved[0], GeoCoordinates{Lon: 50, Lat: 0}); err != nil {
+		t.Fatal(err)
+	}
+	if !c.dirtyAll {
+		t.Fatal("points of mean-shift are not dirty")
+	}
+	if rebuilt, total := c.RebuildDirty(), len(c.ResultPoints); rebuilt == 0 || c.Dirty() {
+		t.Fatalf("%d of %d mean-shift clusters are rebuilt", rebuilt, total)
+	}
+	checkAssignments(t, c, n)
+}
+
+func TestReassignPoint(t *testing.T) {
+	points := randomPoints(1000, 68, -30, -30, 30, 30)
+	c, err := NewClusterForZoom(6, 256, 60)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.ReassignPoint(0, 0); err == nil {
+		t.Fatal("expected error before points are clustered")
+	}
+	if err := c.ClusterPoints(points); err != nil {
+		t.Fatal(err)
+	}
+	var large, pair, single *ClusterPoint
+	for i := range c.ResultPoints {
+		cp := &c.ResultPoints[i]
+		switch {
+		case cp.NumPoints > 3 && large == nil:
+			large = cp
+		case cp.NumPoints == 2 && pair == nil:
+			pair = cp
+		case cp.NumPoints == 1 && single == nil:
+			single = cp
+		}
+	}
+	if large == nil || pair == nil || single == nil {
+		t.Fatal("no clusters of all sizes")
+	}
+	largeID, largeSize, pairID, singleID := large.Id, large.NumPoints, pair.Id, single.Id
+	moved, left := pair.memberIDs[0], pair.memberIDs[1]
+	n := len(c.ResultPoints)
+	unchanged := map[int]int{}
+	for _, cp := range c.ResultPoints {
+		if cp.Id != largeID && cp.Id != pairID && cp.Id != singleID {
+			unchanged[cp.Id] = cp.NumPoints
+		}
+	}
+
+	//the point of the pair joins the large cluster, the pair becomes single point
+	if err := c.ReassignPoint(moved, largeID); err != nil {
+		t.Fatal(err)
+	}
+	checkAssignments(t, c, len(points))
+	if r := resultIndexByID(c, largeID); r < 0 || c.assignment[moved] != r || c.ResultPoints[r].NumPoints != largeSize+1 {
+		t.Fatalf("point %d is not moved into cluster %d", moved, largeID)
+	}
+	if r := c.assignment[left]; c.ResultPoints[r].NumPoints != 1 || c.ResultPoints[r].Id != left {
+		t.Fatalf("point %d left of the pair is in cluster %d", left, c.ResultPoints[r].Id)
+	}
+	//the single point joins the point left, the single point is removed and they make new cluster
+	if err := c.ReassignPoint(singleID, left); err != nil {
+		t.Fatal(err)
+	}
+	checkAssignments(t, c, len(points))
+	if len(c.ResultPoints) != n-1 {
+		t.Fatalf("%d result points after reassignments, want %d", len(c.ResultPoints), n-1)
+	}
+	if r := c.assignment[left]; c.assignment[singleID] != r || !c.IsCluster(c.ResultPoints[r].Id) || c.ResultPoints[r].NumPoints != 2 {
+		t.Fatalf("points %d and %d are in result points %d and %d", singleID, left, c.assignment[singleID], r)
+	}
+	for _, cp := range c.ResultPoints {
+		if size, ok := unchanged[cp.Id]; ok {
+			if size != cp.NumPoints {
+				t.Fatalf("cluster %d has %d points, want %d", cp.Id, cp.NumPoints, size)
+			}
+			delete(unchanged, cp.Id)
+		}
+	}
+	if len(unchanged) != 0 {
+		t.Fatalf("%d clusters are changed by reassignments", len(unchanged))
+	}
+
+	//reassignment into the cluster of the point changes nothing
+	if err := c.ReassignPoint(moved, largeID); err != nil || len(c.ResultPoints) != n-1 {
+		t.Fatalf("reassignment into the same cluster: %v", err)
+	}
+	if err := c.ReassignPoint(len(points), largeID); err == nil {
+		t.Fatal("expected error of point out of range")
+	}
+	if err := c.ReassignPoint(moved, -1); err == nil {
+		t.Fatal("expected error of unknown cluster")
+	}
+}
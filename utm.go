@@ -0,0 +1,116 @@
+package cluster
+
+import "math"
+
+// UTM is Universal Transverse Mercator projection of WGS84 ellipsoid, coordinates are easting and northing in meters,
+// so Epsilon is in meters too. Zone is in 1..60 range, South is true for zones of the southern hemisphere.
+// Distances are accurate to 0.04% inside of the zone and the projection is accurate to millimeters,
+// points far from the zone are still clustered, but with larger distortion.
+// Other planar CRS, like national grids, are used by implementing Projection, e.g. with PROJ bindings.
+type UTM struct {
+	Zone  int
+	South bool
+}
+
+// UTMZoneOf returns UTM zone containing coordinates
+func UTMZoneOf(coordinates GeoCoordinates) UTM {
+	zone := int(math.Floor((coordinates.Lon+180)/6)) + 1
+	if zone > 60 {
+		zone = 60
+	}
+	if zone < 1 {
+		zone = 1
+	}
+	return UTM{Zone: zone, South: coordinates.Lat < 0}
+}
+
+// WGS84 ellipsoid and UTM constants, Krüger series are from Karney "Transverse Mercator with an accuracy of a few nanometers"
+const (
+	utmScale    = 0.9996
+	utmEasting  = 500000.0
+	utmNorthing = 10000000.0
+	wgs84A      = 6378137.0
+	wgs84F      = 1 / 298.257223563
+)
+
+var (
+	utmN     = wgs84F / (2 - wgs84F)
+	utmA     = wgs84A / (1 + utmN) * (1 + utmN*utmN/4 + utmN*utmN*utmN*utmN/64)
+	utmE     = 2 * math.Sqrt(utmN) / (1 + utmN)
+	utmAlpha = [3]float64{
+		utmN/2 - 2*utmN*utmN/3 + 5*utmN*utmN*utmN/16,
+		13*utmN*utmN/48 - 3*utmN*utmN*utmN/5,
+		61 * utmN * utmN * utmN / 240,
+	}
+	utmBeta = [3]float64{
+		utmN/2 - 2*utmN*utmN/3 + 37*utmN*utmN*utmN/96,
+		utmN*utmN/48 + utmN*utmN*utmN/15,
+		17 * utmN * utmN * utmN / 480,
+	}
+	utmDelta = [3]float64{
+		2*utmN - 2*utmN*utmN/3 - 2*utmN*utmN*utmN,
+		7*utmN*utmN/3 - 8*utmN*utmN*utmN/5,
+		56 * utmN * utmN * utmN / 15,
+	}
+)
+
+// Project implements Projection interface
+func (u UTM) Project(coordinates GeoCoordinates) (float64, float64) {
+	lat := coordinates.Lat * math.Pi / 180
+	lon := (coordinates.Lon - u.centralMeridian()) * math.Pi / 180
+	sin := math.Sin(lat)
+	t := math.Sinh(math.Atanh(sin) - utmE*math.Atanh(utmE*sin))
+	xi := math.Atan2(t, math.Cos(lon))
+	eta := math.Atanh(math.Sin(lon) / math.Sqrt(1+t*t))
+
+	x, y := eta, xi
+	for j, a := range utmAlpha {
+		k := float64(2 * (j + 1))
+		x += a * math.Cos(k*xi) * math.Sinh(k*eta)
+		y += a * math.Sin(k*xi) * math.Cosh(k*eta)
+	}
+	x, y = utmEasting+utmScale*utmA*x, utmScale*utmA*y
+	if u.South {
+		y += utmNorthing
+	}
+	return x, y
+}
+
+// Unproject implements Projection interface
+func (u UTM) Unproject(x, y float64) GeoCoordinates {
+	if u.South {
+		y -= utmNorthing
+	}
+	xi := y / (utmScale * utmA)
+	eta := (x - utmEasting) / (utmScale * utmA)
+
+	xi1, eta1 := xi, eta
+	for j, b := range utmBeta {
+		k := float64(2 * (j + 1))
+		xi1 -= b * math.Sin(k*xi) * math.Cosh(k*eta)
+		eta1 -= b * math.Cos(k*xi) * math.Sinh(k*eta)
+	}
+	chi := math.Asin(math.Sin(xi1) / math.Cosh(eta1))
+	lat := chi
+	for j, d := range utmDelta {
+		lat += d * math.Sin(float64(2*(j+1))*chi)
+	}
+	lon := math.Atan2(math.Sinh(eta1), math.Cos(xi1))
+	return GeoCoordinates{Lon: u.centralMeridian() + lon*180/math.Pi, Lat: lat * 180 / math.Pi}
+}
+
+func (u UTM) centralMeridian() float64 {
+	return float64(u.Zone*6 - 183)
+}
+
+// ProjectedClusters returns AllClusters with X and Y in coordinates of Projection, e.g. UTM meters
+// Other fields are the same, the slice is a copy.
+func (c *Cluster) ProjectedClusters() []ClusterPoint {
+	projection := c.projection()
+	result := make([]ClusterPoint, len(c.ResultPoints))
+	for i, p := range c.ResultPoints {
+		p.X, p.Y = projection.Project(GeoCoordinates{Lon: p.X, Lat: p.Y})
+		result[i] = p
+	}
+	return result
+}
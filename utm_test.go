@@ -0,0 +1,73 @@
+package cluster
+
+import (
+	"math"
+	"testing"
+)
+
+func TestUTM(t *testing.T) {
+	if z := UTMZoneOf(GeoCoordinates{Lon: 13.4, Lat: 52.5}); z != (UTM{Zone: 33}) {
+		t.Fatalf("zone of Berlin is %+v", z)
+	}
+	if z := UTMZoneOf(GeoCoordinates{Lon: 180, Lat: -33}); z != (UTM{Zone: 60, South: true}) {
+		t.Fatalf("zone of antimeridian is %+v", z)
+	}
+
+	u := UTM{Zone: 31}
+	//the central meridian is at false easting, distances on it are scaled by 0.9996
+	if x, y := u.Project(GeoCoordinates{Lon: 3, Lat: 0}); math.Abs(x-500000) > 1e-6 || math.Abs(y) > 1e-6 {
+		t.Fatalf("origin of the zone is at %v,%v", x, y)
+	}
+	//meridian arc of WGS84 from the equator to 1 degree is 110574.389 m
+	if x, y := u.Project(GeoCoordinates{Lon: 3, Lat: 1}); math.Abs(x-500000) > 1e-6 || math.Abs(y-0.9996*110574.389) > 0.01 {
+		t.Fatalf("1 degree north of the origin is at %v,%v", x, y)
+	}
+	x, _ := u.Project(GeoCoordinates{Lon: 3.001, Lat: 0})
+	if want := 500000 + 0.9996*wgs84A*0.001*math.Pi/180; math.Abs(x-want) > 0.001 {
+		t.Fatalf("easting of 0.001 degree east is %v, want %v", x, want)
+	}
+	south := UTM{Zone: 31, South: true}
+	if _, y := south.Project(GeoCoordinates{Lon: 3, Lat: -1}); math.Abs(y-(10000000-0.9996*110574.389)) > 0.01 {
+		t.Fatalf("1 degree south of the origin is at northing %v", y)
+	}
+
+	for _, c := range []GeoCoordinates{{Lon: 13.4, Lat: 52.5}, {Lon: 15.9, Lat: 70}, {Lon: 10.1, Lat: -45}} {
+		zone := UTMZoneOf(c)
+		x, y := zone.Project(c)
+		//1e-8 degree is about a millimeter
+		if back := zone.Unproject(x, y); math.Abs(back.Lon-c.Lon) > 1e-8 || math.Abs(back.Lat-c.Lat) > 1e-8 {
+			t.Fatalf("%v is back at %v", c, back)
+		}
+	}
+}
+
+func TestProjectedClusters(t *testing.T) {
+	points := randomPoints(500, 46, 12, 52, 14, 53)
+	c := NewCluster(2000)
+	c.Projection = UTM{Zone: 33}
+	if err := c.ClusterPoints(points); err != nil {
+		t.Fatal(err)
+	}
+	checkAssignments(t, c, len(points))
+	projected := c.ProjectedClusters()
+	if len(projected) != len(c.ResultPoints) {
+		t.Fatalf("%d projected clusters of %d result points", len(projected), len(c.ResultPoints))
+	}
+	for i, p := range projected {
+		cp := &c.ResultPoints[i]
+		x, y := c.Projection.Project(GeoCoordinates{Lon: cp.X, Lat: cp.Y})
+		if p.X != x || p.Y != y || p.Id != cp.Id || p.NumPoints != cp.NumPoints {
+			t.Fatalf("projected cluster %d is %d at %v,%v, want %d at %v,%v", i, p.Id, p.X, p.Y, cp.Id, x, y)
+		}
+		//members are within Epsilon meters of the center
+		for _, member := range p.IncludedPoints {
+			mx, my := c.Projection.Project(member.GetCoordinates())
+			if d := math.Hypot(mx-p.X, my-p.Y); p.NumPoints > 1 && d > 2*c.Epsilon {
+				t.Fatalf("cluster %d has member %v m from its center", p.Id, d)
+			}
+		}
+	}
+	if restored := roundTrip(t, c); restored.Projection != c.Projection {
+		t.Fatalf("restored projection %v", restored.Projection)
+	}
+}